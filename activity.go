@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// activity is a PR's last activity and the source it was taken from.
+type activity struct {
+	At     time.Time
+	Source string
+}
+
+// updatedActivity is the default activity source: the PR's last update,
+// which includes edits to its title and description.
+func updatedActivity(pr *github.PullRequest) activity {
+	return activity{At: pr.GetUpdatedAt().Time, Source: "last update, including edits"}
+}
+
+// timelineActivity computes a PR's last activity from its timeline, ignoring
+// title and description edits and the bot's own events. Commits count at
+// their committer date and force-pushes at the time of the push, since a
+// rebase keeps the commits' original author dates.
+func timelineActivity(pr *github.PullRequest, events []*github.Timeline, botLogin string) activity {
+	last := activity{At: pr.GetCreatedAt().Time, Source: "opened"}
+	seen := func(at time.Time, source string) {
+		if at.After(last.At) {
+			last = activity{At: at, Source: source}
+		}
+	}
+	for _, ev := range events {
+		actor := timelineActor(ev)
+		if botLogin != "" && strings.EqualFold(actor, botLogin) {
+			continue
+		}
+		if a, ok := eventActivity(ev, actor); ok {
+			seen(a.At, a.Source)
+		}
+	}
+	return last
+}
+
+// eventActivity returns the activity a timeline event represents, or false if
+// the event does not count as activity.
+func eventActivity(ev *github.Timeline, actor string) (activity, bool) {
+	switch ev.GetEvent() {
+	case "committed":
+		return activity{At: ev.GetCommitter().GetDate().Time, Source: fmt.Sprintf("commit %.7s (committer date)", ev.GetSHA())}, true
+	case "head_ref_force_pushed":
+		return activity{At: ev.GetCreatedAt().Time, Source: fmt.Sprintf("force-push by %s", actor)}, true
+	case "reviewed":
+		return activity{At: ev.GetSubmittedAt().Time, Source: fmt.Sprintf("review by %s", actor)}, true
+	case "commented", "reopened", "ready_for_review", "review_requested", "convert_to_draft", "demilestoned":
+		return activity{At: ev.GetCreatedAt().Time, Source: fmt.Sprintf("%s by %s", strings.ReplaceAll(ev.GetEvent(), "_", " "), actor)}, true
+	}
+	return activity{}, false
+}
+
+// waitingOnReview returns how the author handed a PR over to its reviewers,
+// or "" if they have not: the author's last action was re-requesting a review
+// or replying to review comments, and no reviewer has acted since. Warning
+// the author about such a PR would be wrong.
+func waitingOnReview(pr *github.PullRequest, events []*github.Timeline, botLogin string) string {
+	author := pr.GetUser().GetLogin()
+	handoff := ""
+	for _, ev := range events {
+		actor := timelineActor(ev)
+		if botLogin != "" && strings.EqualFold(actor, botLogin) {
+			continue
+		}
+		byAuthor := strings.EqualFold(actor, author)
+		switch ev.GetEvent() {
+		case "review_requested":
+			if byAuthor {
+				handoff = fmt.Sprintf("the author re-requested a review from %s", requestedReviewer(ev))
+			}
+		case "reviewed":
+			// The author's replies to review comments appear as reviews
+			// of their own PR.
+			if byAuthor {
+				handoff = "the author replied to the review comments"
+			} else {
+				handoff = ""
+			}
+		case "commented":
+			if !byAuthor {
+				handoff = ""
+			}
+		case "committed", "head_ref_force_pushed", "convert_to_draft":
+			handoff = ""
+		}
+	}
+	return handoff
+}
+
+// reopenedAfterClosure returns when a PR was last reopened after a closure
+// for inactivity, or the zero time if it never was. A closure counts if the
+// bot made it, or if the PR carries the 'closed-stale' label.
+func reopenedAfterClosure(pr *github.PullRequest, events []*github.Timeline, botLogin string) time.Time {
+	labeled := hasLabel(pr, "closed-stale")
+	closedStale := false
+	var reopened time.Time
+	for _, ev := range events {
+		switch ev.GetEvent() {
+		case "closed":
+			closedStale = labeled || (botLogin != "" && strings.EqualFold(timelineActor(ev), botLogin))
+		case "reopened":
+			if closedStale {
+				reopened = ev.GetCreatedAt().Time
+			}
+			closedStale = false
+		}
+	}
+	return reopened
+}
+
+// draftTransitions are the draft transitions of a PR that change how it is
+// evaluated.
+type draftTransitions struct {
+	// ReadyAt is when the PR was last marked ready for review, and ReadyBy
+	// by whom.
+	ReadyAt time.Time
+	ReadyBy string
+	// PausedAt is when the author converted the PR to a draft while it
+	// carried the stale label, if it has not been marked ready since.
+	PausedAt time.Time
+}
+
+// draftTransitionsOf reads a PR's draft transitions from its timeline.
+func draftTransitionsOf(pr *github.PullRequest, events []*github.Timeline, staleLabel string) draftTransitions {
+	var t draftTransitions
+	warned := false
+	for _, ev := range events {
+		switch ev.GetEvent() {
+		case "labeled", "unlabeled":
+			if strings.EqualFold(ev.GetLabel().GetName(), staleLabel) {
+				warned = ev.GetEvent() == "labeled"
+			}
+		case "convert_to_draft":
+			if warned && strings.EqualFold(timelineActor(ev), pr.GetUser().GetLogin()) {
+				t.PausedAt = ev.GetCreatedAt().Time
+			}
+		case "ready_for_review":
+			t.ReadyAt, t.ReadyBy = ev.GetCreatedAt().Time, timelineActor(ev)
+			t.PausedAt = time.Time{}
+		}
+	}
+	return t
+}
+
+// timelineActor returns the login of whoever caused a timeline event.
+func timelineActor(ev *github.Timeline) string {
+	if login := ev.GetActor().GetLogin(); login != "" {
+		return login
+	}
+	return ev.GetUser().GetLogin()
+}
+
+// requestedReviewer names the reviewer or team of a review_requested event.
+func requestedReviewer(ev *github.Timeline) string {
+	if login := ev.GetReviewer().GetLogin(); login != "" {
+		return "@" + login
+	}
+	if slug := ev.GetRequestedTeam().GetSlug(); slug != "" {
+		return "team " + slug
+	}
+	return "a reviewer"
+}
+
+// getTimeline returns all timeline events of a PR.
+func getTimeline(client *github.Client, owner, repo string, number int) ([]*github.Timeline, error) {
+	ctx := context.Background()
+	var events []*github.Timeline
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := client.Issues.ListIssueTimeline(ctx, owner, repo, number, opt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list timeline of PR #%d: %v", number, err)
+		}
+		events = append(events, page...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return events, nil
+}
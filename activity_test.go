@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// timelineEvent returns a timeline event of kind by actor at at.
+func timelineEvent(kind, actor string, at time.Time) *github.Timeline {
+	return &github.Timeline{
+		Event:     github.Ptr(kind),
+		Actor:     &github.User{Login: github.Ptr(actor)},
+		CreatedAt: &github.Timestamp{Time: at},
+	}
+}
+
+// labelTimelineEvent returns a labeled or unlabeled timeline event for label.
+func labelTimelineEvent(kind, label, actor string, at time.Time) *github.Timeline {
+	ev := timelineEvent(kind, actor, at)
+	ev.Label = &github.Label{Name: github.Ptr(label)}
+	return ev
+}
+
+func TestDraftTransitionsOf(t *testing.T) {
+	day := func(n int) time.Time { return time.Date(2026, 3, n, 9, 0, 0, 0, time.UTC) }
+	warned := labelTimelineEvent("labeled", "stale-warning", "stale-bot", day(1))
+	for _, tc := range []struct {
+		name   string
+		events []*github.Timeline
+		want   draftTransitions
+	}{
+		{name: "no events"},
+		{
+			name:   "converted while warned",
+			events: []*github.Timeline{warned, timelineEvent("convert_to_draft", "alice", day(2))},
+			want:   draftTransitions{PausedAt: day(2)},
+		},
+		{
+			name:   "converted before the warning",
+			events: []*github.Timeline{timelineEvent("convert_to_draft", "alice", day(1)), labelTimelineEvent("labeled", "stale-warning", "stale-bot", day(2))},
+		},
+		{
+			name:   "converted after the warning was removed",
+			events: []*github.Timeline{warned, labelTimelineEvent("unlabeled", "Stale-Warning", "stale-bot", day(2)), timelineEvent("convert_to_draft", "alice", day(3))},
+		},
+		{
+			name:   "converted by someone else",
+			events: []*github.Timeline{warned, timelineEvent("convert_to_draft", "bob", day(2))},
+		},
+		{
+			name:   "other labels",
+			events: []*github.Timeline{labelTimelineEvent("labeled", "wip", "alice", day(1)), timelineEvent("convert_to_draft", "alice", day(2))},
+		},
+		{
+			name:   "marked ready again",
+			events: []*github.Timeline{warned, timelineEvent("convert_to_draft", "alice", day(2)), timelineEvent("ready_for_review", "bob", day(3))},
+			want:   draftTransitions{ReadyAt: day(3), ReadyBy: "bob"},
+		},
+		{
+			name: "converted again",
+			events: []*github.Timeline{
+				timelineEvent("ready_for_review", "alice", day(1)),
+				labelTimelineEvent("labeled", "stale-warning", "stale-bot", day(2)),
+				timelineEvent("convert_to_draft", "Alice", day(3)),
+			},
+			want: draftTransitions{ReadyAt: day(1), ReadyBy: "alice", PausedAt: day(3)},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := draftTransitionsOf(testPR("alice", day(1)), tc.events, "stale-warning")
+			if !got.ReadyAt.Equal(tc.want.ReadyAt) || got.ReadyBy != tc.want.ReadyBy || !got.PausedAt.Equal(tc.want.PausedAt) {
+				t.Errorf("draftTransitionsOf = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDraftTransitionDecisions(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	old := now.AddDate(0, 0, -40)
+	for _, tc := range []struct {
+		name        string
+		draft       bool
+		transitions draftTransitions
+		rules       func(r *evaluationRules)
+		want        string
+		rule        string
+		paused      bool
+	}{
+		{name: "paused draft", draft: true, transitions: draftTransitions{PausedAt: old}, want: actionExempt, rule: ruleDraft, paused: true},
+		{name: "pausing disabled", draft: true, transitions: draftTransitions{PausedAt: old}, rules: func(r *evaluationRules) { r.PauseDrafts = false }, want: actionWarn, rule: ruleLifecycle},
+		{name: "draft not paused", draft: true, want: actionWarn, rule: ruleLifecycle},
+		{name: "marked ready recently", transitions: draftTransitions{ReadyAt: now.AddDate(0, 0, -2), ReadyBy: "alice"}, want: actionActive, rule: ruleActivity},
+		{name: "marked ready long ago", transitions: draftTransitions{ReadyAt: old.AddDate(0, 0, -1), ReadyBy: "alice"}, want: actionWarn, rule: ruleLifecycle},
+		{
+			name:        "ready resets disabled",
+			transitions: draftTransitions{ReadyAt: now.AddDate(0, 0, -2), ReadyBy: "alice"},
+			rules:       func(r *evaluationRules) { r.ReadyResetsActivity = false },
+			want:        actionWarn,
+			rule:        ruleLifecycle,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rules := testRules()
+			rules.SkipDrafts = false
+			rules.PauseDrafts, rules.ReadyResetsActivity = true, true
+			if tc.rules != nil {
+				tc.rules(&rules)
+			}
+			pr := testPR("alice", old)
+			pr.Draft = github.Ptr(tc.draft)
+			d := evaluatePR(pr, prSignals{Transitions: tc.transitions}, rules, now)
+			if d.Action != tc.want || d.Rule != tc.rule || d.Paused != tc.paused {
+				t.Errorf("decided %s by the '%s' rule, paused %v; want %s by '%s', paused %v; trace:\n%v", d.Action, d.Rule, d.Paused, tc.want, tc.rule, tc.paused, d.Trace)
+			}
+		})
+	}
+}
+
+// readTimeline reads a timeline fixture from testdata/timeline.
+func readTimeline(t *testing.T, name string) []*github.Timeline {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "timeline", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []*github.Timeline
+	if err := json.Unmarshal(data, &events); err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+// TestTimelineActivityRebased computes the activity of a PR rebased and
+// force-pushed long after its commits were made, then renamed: the
+// force-push is its last activity, not the commits' dates, the rename or
+// the bot's warning.
+func TestTimelineActivityRebased(t *testing.T) {
+	pr := testPR("alice", time.Date(2026, 3, 13, 8, 0, 0, 0, time.UTC))
+	pr.CreatedAt = &github.Timestamp{Time: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)}
+	events := readTimeline(t, "rebased.json")
+	got := timelineActivity(pr, events, "stale-bot")
+	if want := (activity{At: time.Date(2026, 3, 12, 18, 45, 0, 0, time.UTC), Source: "force-push by alice"}); got != want {
+		t.Errorf("timelineActivity = %+v, want %+v", got, want)
+	}
+
+	// Without the force-push the commits count at their dates, and the
+	// bot's own comment is still ignored.
+	got = timelineActivity(pr, events[:5], "stale-bot")
+	if want := (activity{At: time.Date(2025, 6, 10, 9, 0, 0, 0, time.UTC), Source: "commented by bob"}); got != want {
+		t.Errorf("timelineActivity without the force-push = %+v, want %+v", got, want)
+	}
+	got = timelineActivity(pr, events[:2], "stale-bot")
+	if want := (activity{At: time.Date(2025, 6, 5, 16, 30, 0, 0, time.UTC), Source: "commit 2b3c4d5 (committer date)"}); got != want {
+		t.Errorf("timelineActivity of the commits = %+v, want %+v", got, want)
+	}
+}
+
+// TestEditsCountAsActivity scans a PR whose only recent update is an edit of
+// its title: it is active when edits count as activity, and stale when they
+// do not, with the source of its last activity in the trace.
+func TestEditsCountAsActivity(t *testing.T) {
+	events := readTimeline(t, "rebased.json")
+	// Drop the force-push: the rename is the only recent update.
+	data, _ := json.Marshal(append(events[:5:5], events[6]))
+	for _, tc := range []struct {
+		edits     bool
+		want      string
+		wantTrace string
+	}{
+		{edits: true, want: actionActive, wantTrace: "last activity (last update, including edits) 2 days ago"},
+		{edits: false, want: actionWarn, wantTrace: "(last: commented by bob)"},
+	} {
+		t.Run(fmt.Sprintf("edits-count-as-activity=%v", tc.edits), func(t *testing.T) {
+			gh := &fakeGitHub{get: map[string]string{"/repos/acme/api/issues/42/timeline": string(data)}}
+			s := newTestRepoScanner(t, gh, nil, fmt.Sprintf("--edits-count-as-activity=%v", tc.edits))
+			pr := testPR("alice", time.Now().Add(-48*time.Hour))
+			pr.CreatedAt = &github.Timestamp{Time: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)}
+			_, d := s.evaluate(pr)
+			if d.Action != tc.want || !strings.Contains(strings.Join(d.Trace, "\n"), tc.wantTrace) {
+				t.Errorf("decided %s with trace:\n%s\nwant %s with %q", d.Action, strings.Join(d.Trace, "\n"), tc.want, tc.wantTrace)
+			}
+		})
+	}
+}
+
+// TestReopenedAfterClosure finds when a PR was reopened after a closure for
+// inactivity: the bot's own, or one marked by the 'closed-stale' label.
+func TestReopenedAfterClosure(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 12, 0, 0, 0, time.UTC) }
+	for _, tc := range []struct {
+		name   string
+		labels []string
+		events []*github.Timeline
+		want   time.Time
+	}{
+		{name: "never closed"},
+		{
+			name:   "closed by the bot",
+			events: []*github.Timeline{timelineEvent("closed", "stale-bot", day(1)), timelineEvent("reopened", "carol", day(2))},
+			want:   day(2),
+		},
+		{
+			name:   "closed by a maintainer",
+			events: []*github.Timeline{timelineEvent("closed", "carol", day(1)), timelineEvent("reopened", "alice", day(2))},
+		},
+		{
+			name:   "closed by a maintainer, labeled closed-stale",
+			labels: []string{"closed-stale"},
+			events: []*github.Timeline{timelineEvent("closed", "carol", day(1)), timelineEvent("reopened", "alice", day(2))},
+			want:   day(2),
+		},
+		{
+			name:   "still closed",
+			events: []*github.Timeline{timelineEvent("closed", "stale-bot", day(1))},
+		},
+		{
+			name: "closed by the bot twice",
+			events: []*github.Timeline{
+				timelineEvent("closed", "stale-bot", day(1)), timelineEvent("reopened", "carol", day(2)),
+				timelineEvent("closed", "stale-bot", day(10)), timelineEvent("reopened", "carol", day(12)),
+			},
+			want: day(12),
+		},
+		{
+			name: "later closed by its author",
+			events: []*github.Timeline{
+				timelineEvent("closed", "stale-bot", day(1)), timelineEvent("reopened", "carol", day(2)),
+				timelineEvent("closed", "alice", day(10)), timelineEvent("reopened", "alice", day(12)),
+			},
+			want: day(2),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := reopenedAfterClosure(testPR("alice", day(20), tc.labels...), tc.events, "stale-bot"); !got.Equal(tc.want) {
+				t.Errorf("reopenedAfterClosure = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestReopenGraceAcrossRuns simulates runs at several times after a
+// maintainer reopened a PR the bot closed: the PR stays active for the grace
+// period and is then warned with a note of its history.
+func TestReopenGraceAcrossRuns(t *testing.T) {
+	cfg := &config{Rules: testRules(), DisplayLocation: time.UTC, NotifyVia: notifyComment}
+	cfg.Rules.ReopenGraceDays = 60
+	for _, tc := range []struct {
+		daysSinceReopen int
+		want            string
+		rule            string
+	}{
+		{daysSinceReopen: 1, want: actionActive, rule: ruleActivity},
+		{daysSinceReopen: 45, want: actionActive, rule: ruleReopenGrace},
+		{daysSinceReopen: 65, want: actionWarn, rule: ruleLifecycle},
+	} {
+		t.Run(fmt.Sprintf("%d days after reopening", tc.daysSinceReopen), func(t *testing.T) {
+			reopened := time.Now().AddDate(0, 0, -tc.daysSinceReopen).UTC().Truncate(time.Second)
+			events := []*github.Timeline{
+				timelineEvent("closed", "stale-bot", reopened.AddDate(0, 0, -1)),
+				timelineEvent("reopened", "carol", reopened),
+			}
+			data, err := json.Marshal(events)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gh := &fakeGitHub{get: map[string]string{"/repos/acme/api/issues/42/timeline": string(data)}}
+			s := newTestRepoScanner(t, gh, cfg)
+			d := s.actOn(testPR("alice", reopened, "closed-stale"))
+			if d.Action != tc.want || d.Rule != tc.rule {
+				t.Fatalf("decided %s by %s, want %s by %s; trace:\n%s", d.Action, d.Rule, tc.want, tc.rule, strings.Join(d.Trace, "\n"))
+			}
+			if tc.rule == ruleActivity {
+				return
+			}
+			note := "reopened on " + reopened.Format("2006-01-02")
+			if !strings.Contains(strings.Join(d.Trace, "\n"), note) {
+				t.Errorf("the trace does not note the reopening:\n%s", strings.Join(d.Trace, "\n"))
+			}
+			if tc.want != actionWarn {
+				return
+			}
+			warning := ""
+			for _, w := range gh.written() {
+				if strings.HasPrefix(w, "POST /repos/acme/api/issues/42/comments") {
+					warning = w
+				}
+			}
+			if !strings.Contains(warning, "previously closed for inactivity and reopened on "+reopened.Format("January 2, 2006")) {
+				t.Errorf("the warning %q does not note the reopening", warning)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// apiRetryBaseDelay is the delay before the first retry of a failed GitHub
+// API call; it doubles with each further retry.
+const apiRetryBaseDelay = time.Second
+
+// retryTransport retries GitHub API calls that fail with a server error or a
+// network error, with exponential backoff and jitter. Client errors are
+// returned at once, and so is a spent API budget. Only calls that are safe to repeat are retried: those
+// with an idempotent method, and adding labels, which does nothing for
+// labels already there. Creating a comment twice would post it twice.
+type retryTransport struct {
+	base    http.RoundTripper
+	retries int
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if attempt >= t.retries || !retryable(req) || errors.Is(err, errAPIBudgetExhausted) || (err == nil && resp.StatusCode < 500) {
+			return resp, err
+		}
+		reason := fmt.Sprint(err)
+		if err == nil {
+			reason = resp.Status
+			resp.Body.Close()
+		}
+		delay := backoff(attempt, rand.Int64N)
+		slog.Warn("GitHub API call failed, retrying", "method", req.Method, "path", req.URL.Path, "reason", reason, "delay", delay.Round(time.Millisecond), "attempt", attempt+1, "retries", t.retries)
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		if req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// retryable reports whether a request is safe to repeat.
+func retryable(req *http.Request) bool {
+	if req.Body != nil && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	case http.MethodPost:
+		return strings.HasSuffix(req.URL.Path, "/labels")
+	}
+	return false
+}
+
+// backoff returns the delay before retry attempt+1: apiRetryBaseDelay
+// doubled per earlier attempt, reduced by a random jitter of up to half so
+// that concurrent callers spread out. rnd returns a random number in [0, n).
+func backoff(attempt int, rnd func(n int64) int64) time.Duration {
+	d := apiRetryBaseDelay << attempt
+	return d - time.Duration(rnd(int64(d/2)+1))
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	none := func(int64) int64 { return 0 }
+	most := func(n int64) int64 { return n - 1 }
+	for _, tc := range []struct {
+		attempt  int
+		rnd      func(int64) int64
+		min, max time.Duration
+	}{
+		{0, none, time.Second, time.Second},
+		{0, most, time.Second / 2, time.Second / 2},
+		{1, none, 2 * time.Second, 2 * time.Second},
+		{3, most, 4 * time.Second, 4 * time.Second},
+	} {
+		if got := backoff(tc.attempt, tc.rnd); got < tc.min || got > tc.max {
+			t.Errorf("backoff(%d) = %v, want %v to %v", tc.attempt, got, tc.min, tc.max)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	for _, tc := range []struct {
+		method, path string
+		body         bool
+		want         bool
+	}{
+		{http.MethodGet, "/repos/acme/api/pulls", false, true},
+		{http.MethodPatch, "/repos/acme/api/issues/7", true, true},
+		{http.MethodDelete, "/repos/acme/api/issues/7/labels/stale", false, true},
+		{http.MethodPost, "/repos/acme/api/issues/7/labels", true, true},
+		{http.MethodPost, "/repos/acme/api/issues/7/comments", true, false},
+		{http.MethodPost, "/graphql", true, false},
+	} {
+		var body io.Reader
+		if tc.body {
+			body = strings.NewReader("{}")
+		}
+		req, err := http.NewRequest(tc.method, "https://api.github.com"+tc.path, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := retryable(req); got != tc.want {
+			t.Errorf("retryable(%s %s) = %v, want %v", tc.method, tc.path, got, tc.want)
+		}
+	}
+	// A body that cannot be read again cannot be sent again.
+	req, _ := http.NewRequest(http.MethodPatch, "https://api.github.com/repos/acme/api/issues/7", io.NopCloser(strings.NewReader("{}")))
+	if retryable(req) {
+		t.Error("a request whose body cannot be replayed is retryable")
+	}
+}
+
+func TestRetryTransport(t *testing.T) {
+	var mu sync.Mutex
+	// failures is how many more requests to each path fail, and bodies the
+	// bodies each path received.
+	failures := map[string]int{}
+	bodies := map[string][]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		bodies[r.URL.Path] = append(bodies[r.URL.Path], string(body))
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		if failures[r.URL.Path] > 0 {
+			failures[r.URL.Path]--
+			http.Error(w, "unavailable", http.StatusBadGateway)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: &retryTransport{base: http.DefaultTransport, retries: 1}}
+
+	for _, tc := range []struct {
+		name, method, path string
+		fail               int
+		want               int
+		// attempts is how many requests the server must receive.
+		attempts int
+	}{
+		{"retried", http.MethodPatch, "/issues/1", 1, http.StatusOK, 2},
+		{"retries exhausted", http.MethodGet, "/issues/2", 2, http.StatusBadGateway, 2},
+		{"client error", http.MethodGet, "/issues/3/missing", 0, http.StatusNotFound, 1},
+		{"comment not repeated", http.MethodPost, "/issues/4/comments", 1, http.StatusBadGateway, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mu.Lock()
+			failures[tc.path] = tc.fail
+			mu.Unlock()
+			req, err := http.NewRequest(tc.method, srv.URL+tc.path, strings.NewReader(`{"state":"closed"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.want {
+				t.Errorf("status %d, want %d", resp.StatusCode, tc.want)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if got := bodies[tc.path]; len(got) != tc.attempts {
+				t.Errorf("the server received %d requests, want %d", len(got), tc.attempts)
+			}
+			for i, body := range bodies[tc.path] {
+				if body != `{"state":"closed"}` {
+					t.Errorf("request %d had the body %q", i+1, body)
+				}
+			}
+		})
+	}
+
+	t.Run("cancelled while waiting", func(t *testing.T) {
+		mu.Lock()
+		failures["/issues/5"] = 1
+		mu.Unlock()
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/issues/5", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("the request returned %v, want the context's error", err)
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+)
+
+// Where per-author stale statistics are reported, selected with
+// --author-stats: nowhere, only in the run's own output, or also in the
+// published run summary.
+const (
+	authorStatsOff      = "off"
+	authorStatsInternal = "internal"
+	authorStatsFull     = "full"
+)
+
+// authorHistory records, by PR number, what the bot did to an author's PRs
+// over time.
+type authorHistory struct {
+	Warned      []int `json:"warned,omitempty"`
+	Closed      []int `json:"closed,omitempty"`
+	Resurrected []int `json:"resurrected,omitempty"`
+}
+
+// addPR adds a PR number to a list unless it is already there.
+func addPR(numbers []int, number int) []int {
+	for _, n := range numbers {
+		if n == number {
+			return numbers
+		}
+	}
+	return append(numbers, number)
+}
+
+// recordTransition adds a PR's change of classification to its author's
+// history: a warning, a closure by the bot, or a warned PR becoming active
+// again.
+func (rs *repoState) recordTransition(author string, number int, prev, cur string) {
+	if author == "" {
+		return
+	}
+	h := rs.Authors[author]
+	if h == nil {
+		h = &authorHistory{}
+	}
+	switch {
+	case cur == actionWarn:
+		h.Warned = addPR(h.Warned, number)
+	case cur == actionClose || cur == actionCloseNow:
+		h.Closed = addPR(h.Closed, number)
+	case (prev == actionWarn || prev == actionWait) && !isStaleAction(cur):
+		h.Resurrected = addPR(h.Resurrected, number)
+	default:
+		return
+	}
+	rs.Authors[author] = h
+}
+
+// authorStats are an author's stale statistics.
+type authorStats struct {
+	Login string
+	// Stale counts the author's open PRs currently classified as stale.
+	Stale       int
+	Warned      int
+	Closed      int
+	Resurrected int
+}
+
+// ResurrectionRate is the share of the author's warned PRs that became
+// active again.
+func (s authorStats) ResurrectionRate() string {
+	if s.Warned == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.0f%%", 100*float64(s.Resurrected)/float64(s.Warned))
+}
+
+// collectAuthorStats combines the current classification of open PRs with
+// the authors' history. Authors with fewer than minPRs stale or closed PRs
+// are left out, so that a one-off does not appear. The rest are ranked by
+// stale PRs, then closures.
+func collectAuthorStats(rs *repoState, minPRs int) []authorStats {
+	byLogin := map[string]*authorStats{}
+	get := func(login string) *authorStats {
+		s, ok := byLogin[login]
+		if !ok {
+			s = &authorStats{Login: login}
+			byLogin[login] = s
+		}
+		return s
+	}
+	// PRs closed this run are counted as closed, not stale.
+	for number, action := range rs.Classifications {
+		closing := action == actionClose || action == actionCloseNow
+		if author := rs.PRAuthors[number]; author != "" && isStaleAction(action) && !closing {
+			get(author).Stale++
+		}
+	}
+	for login, h := range rs.Authors {
+		s := get(login)
+		s.Warned, s.Closed, s.Resurrected = len(h.Warned), len(h.Closed), len(h.Resurrected)
+	}
+	var stats []authorStats
+	for _, s := range byLogin {
+		if s.Stale+s.Closed >= minPRs && s.Stale+s.Closed > 0 {
+			stats = append(stats, *s)
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Stale != stats[j].Stale {
+			return stats[i].Stale > stats[j].Stale
+		}
+		if stats[i].Closed != stats[j].Closed {
+			return stats[i].Closed > stats[j].Closed
+		}
+		return stats[i].Login < stats[j].Login
+	})
+	return stats
+}
+
+// logAuthorStats logs the per-author statistics of a run summary, one record
+// per author.
+func logAuthorStats(logger *slog.Logger, stats []authorStats) {
+	for _, s := range stats {
+		logger.Info("author stale PRs", "author", s.Login, "stale", s.Stale, "closed", s.Closed,
+			"warned", s.Warned, "resurrected", s.Resurrected, "resurrection_rate", s.ResurrectionRate())
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRecordTransition(t *testing.T) {
+	rs := &repoState{}
+	rs.initMaps()
+	for _, tr := range []struct {
+		author    string
+		number    int
+		prev, cur string
+	}{
+		{"alice", 1, "", actionWarn},
+		{"alice", 1, actionWarn, actionWait},
+		{"alice", 1, actionWait, actionActive},
+		{"alice", 1, actionActive, actionWarn},
+		{"alice", 2, actionWarn, actionClose},
+		{"alice", 3, "", actionCloseNow},
+		// Warned PRs exempted or escalated did not come back to life.
+		{"bob", 4, actionWarn, actionEscalate},
+		{"bob", 5, actionActive, actionExempt},
+		{"", 6, "", actionWarn},
+	} {
+		rs.recordTransition(tr.author, tr.number, tr.prev, tr.cur)
+	}
+	if got, want := fmt.Sprint(rs.Authors["alice"]), fmt.Sprint(&authorHistory{Warned: []int{1}, Closed: []int{2, 3}, Resurrected: []int{1}}); got != want {
+		t.Errorf("alice's history %s, want %s", got, want)
+	}
+	if h, ok := rs.Authors["bob"]; ok {
+		t.Errorf("bob has a history %+v without any transition", h)
+	}
+	if len(rs.Authors) != 1 {
+		t.Errorf("histories %v, want only alice's", rs.Authors)
+	}
+}
+
+func TestCollectAuthorStats(t *testing.T) {
+	rs := &repoState{}
+	rs.initMaps()
+	for number, c := range map[int]struct{ author, action string }{
+		1: {"alice", actionWarn},
+		2: {"alice", actionWait},
+		3: {"alice", actionClose},
+		4: {"bob", actionWarn},
+		5: {"bob", actionActive},
+		6: {"carol", actionEscalate},
+		7: {"dave", actionExempt},
+	} {
+		rs.PRAuthors[number] = c.author
+		rs.Classifications[number] = c.action
+	}
+	rs.Authors = map[string]*authorHistory{
+		"alice": {Warned: []int{1, 2, 3, 10}, Closed: []int{3, 11}, Resurrected: []int{10}},
+		"bob":   {Warned: []int{4}, Closed: []int{12}},
+		"erin":  {Warned: []int{13}, Resurrected: []int{13}},
+	}
+	for _, tc := range []struct {
+		minPRs int
+		want   []authorStats
+	}{
+		{1, []authorStats{
+			{Login: "alice", Stale: 2, Warned: 4, Closed: 2, Resurrected: 1},
+			{Login: "bob", Stale: 1, Warned: 1, Closed: 1},
+			{Login: "carol", Stale: 1},
+		}},
+		{3, []authorStats{{Login: "alice", Stale: 2, Warned: 4, Closed: 2, Resurrected: 1}}},
+	} {
+		if got := collectAuthorStats(rs, tc.minPRs); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("with at least %d PRs the stats are %+v, want %+v", tc.minPRs, got, tc.want)
+		}
+	}
+}
+
+func TestResurrectionRate(t *testing.T) {
+	for _, tc := range []struct {
+		s    authorStats
+		want string
+	}{
+		{authorStats{}, "n/a"},
+		{authorStats{Warned: 4, Resurrected: 1}, "25%"},
+		{authorStats{Warned: 3, Resurrected: 2}, "67%"},
+	} {
+		if got := tc.s.ResurrectionRate(); got != tc.want {
+			t.Errorf("%+v has a resurrection rate of %s, want %s", tc.s, got, tc.want)
+		}
+	}
+}
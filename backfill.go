@@ -0,0 +1,34 @@
+package main
+
+import "time"
+
+// backfillState tracks the gentle first pass of --backfill across runs.
+type backfillState struct {
+	// Day is the UTC date Warned counts for.
+	Day    string `json:"day,omitempty"`
+	Warned int    `json:"warned,omitempty"`
+	// Complete is set once a run found no backlog; later runs behave normally.
+	Complete bool `json:"complete,omitempty"`
+}
+
+// takeWarning reserves one of the day's backfill warnings, reporting false
+// once dailyCap warnings were sent that day.
+func (b *backfillState) takeWarning(dailyCap int, now time.Time) bool {
+	day := now.UTC().Format("2006-01-02")
+	if b.Day != day {
+		b.Day = day
+		b.Warned = 0
+	}
+	if b.Warned >= dailyCap {
+		return false
+	}
+	b.Warned++
+	return true
+}
+
+// releaseWarning returns a reserved warning that was not sent.
+func (b *backfillState) releaseWarning() {
+	if b.Warned > 0 {
+		b.Warned--
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// TestBackfillDailyCap drains a backlog of 7 PRs with a cap of 3 warnings a
+// day and two runs a day, advancing the clock from run to run.
+func TestBackfillDailyCap(t *testing.T) {
+	var b backfillState
+	backlog := 7
+	start := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	var perDay []int
+	for run := 0; backlog > 0; run++ {
+		now := start.Add(time.Duration(run) * 12 * time.Hour)
+		if run%2 == 0 {
+			perDay = append(perDay, 0)
+		}
+		for backlog > 0 && b.takeWarning(3, now) {
+			backlog--
+			perDay[len(perDay)-1]++
+		}
+	}
+	if fmt.Sprint(perDay) != "[3 3 1]" {
+		t.Errorf("warnings per day %v, want [3 3 1]", perDay)
+	}
+
+	// A warning that could not be sent does not count against the cap.
+	b = backfillState{}
+	now := start
+	b.takeWarning(1, now)
+	b.releaseWarning()
+	if !b.takeWarning(1, now) || b.takeWarning(1, now) {
+		t.Errorf("backfill state %+v, want a released warning taken again and the cap kept", b)
+	}
+	// Days are UTC days: this is still March 1.
+	if b.takeWarning(1, time.Date(2026, 3, 2, 0, 30, 0, 0, time.FixedZone("CET", 3600))) {
+		t.Errorf("took a warning on 2026-03-01 UTC after reaching the cap")
+	}
+}
+
+// backfillRepo is a repository with stale PRs that remembers the warnings
+// the bot labels them with, for scanning it day after day.
+type backfillRepo struct {
+	gh     *fakeGitHub
+	prs    []*github.PullRequest
+	warned map[int]bool
+}
+
+func newBackfillRepo(count int) *backfillRepo {
+	r := &backfillRepo{gh: &fakeGitHub{get: map[string]string{"/search/issues": `{"total_count":5}`}}, warned: map[int]bool{}}
+	for i := 1; i <= count; i++ {
+		// PR #1 is the newest.
+		pr := testPR("alice", time.Now().AddDate(0, 0, -40-i))
+		pr.Number = github.Ptr(i)
+		r.prs = append(r.prs, pr)
+	}
+	r.sync()
+	return r
+}
+
+// sync labels the PRs the bot warned and serves them.
+func (r *backfillRepo) sync() {
+	for _, w := range r.gh.written() {
+		var number int
+		if _, err := fmt.Sscanf(w, "POST /repos/acme/api/issues/%d/labels", &number); err == nil {
+			r.warned[number] = true
+		}
+	}
+	r.gh.writes = nil
+	for _, pr := range r.prs {
+		if r.warned[pr.GetNumber()] && !hasLabel(pr, "stale-warning") {
+			pr.Labels = append(pr.Labels, &github.Label{Name: github.Ptr("stale-warning")})
+			events, _ := json.Marshal([]*github.IssueEvent{labelEvent("labeled", "stale-warning", time.Now())})
+			r.gh.get[fmt.Sprintf("/repos/acme/api/issues/%d/events", pr.GetNumber())] = string(events)
+		}
+	}
+	prs, _ := json.Marshal(r.prs)
+	r.gh.get["/repos/acme/api/pulls"] = string(prs)
+}
+
+// TestBackfillScanDrain scans a repository with 5 stale PRs in backfill mode
+// with a cap of 2 warnings a day: each day warns the oldest unwarned PRs, a
+// second run on the same day warns none, and the backlog is reported until
+// a run finds none left.
+func TestBackfillScanDrain(t *testing.T) {
+	repo := newBackfillRepo(5)
+	s := newTestRepoScanner(t, repo.gh, nil, "--backfill", "--backfill-daily-cap", "2")
+	for _, run := range []struct {
+		newDay    bool
+		warned    string
+		remaining int
+		complete  bool
+	}{
+		{newDay: true, warned: "[5 4]", remaining: 3},
+		{warned: "[]", remaining: 3},
+		{newDay: true, warned: "[3 2]", remaining: 1},
+		{newDay: true, warned: "[1]", remaining: 0, complete: true},
+	} {
+		if run.newDay {
+			// The day's cap was used up on an earlier day.
+			s.repoSt.Backfill.Day = "2000-01-01"
+		}
+		s = newRepoScanner(s.scanner, "acme", "api")
+		if err := s.scan(); err != nil {
+			t.Fatal(err)
+		}
+		repo.sync()
+		var warned []int
+		for _, pr := range s.summary.Warned {
+			warned = append(warned, pr.GetNumber())
+		}
+		if fmt.Sprint(warned) != run.warned || s.summary.BackfillRemaining != run.remaining || s.summary.BackfillComplete != run.complete {
+			t.Errorf("run warned %v, %d remaining, complete %v; want %s, %d remaining, complete %v",
+				warned, s.summary.BackfillRemaining, s.summary.BackfillComplete, run.warned, run.remaining, run.complete)
+		}
+	}
+	if !s.repoSt.Backfill.Complete {
+		t.Error("the state does not record the backfill as complete")
+	}
+
+	// Once complete, runs are no longer capped.
+	s = newRepoScanner(s.scanner, "acme", "api")
+	if err := s.scan(); err != nil {
+		t.Fatal(err)
+	}
+	if s.summary.Backfilling {
+		t.Error("a run after the backfill completed is still backfilling")
+	}
+}
+
+// TestBackfillComposes runs backfill mode in a dry run and in warn-only mode:
+// the dry run reports the capped warnings it would send, and warn-only mode
+// caps warnings the same way.
+func TestBackfillComposes(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		args   []string
+		dryRun bool
+	}{
+		{"dry run", []string{"--dry-run"}, true},
+		{"warn-only", []string{"--auto-soften-min-activity", "20"}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := newBackfillRepo(5)
+			s := newTestRepoScanner(t, repo.gh, nil, append([]string{"--backfill", "--backfill-daily-cap", "2"}, tc.args...)...)
+			if err := s.scan(); err != nil {
+				t.Fatal(err)
+			}
+			warned := len(s.summary.Warned) + s.summary.Would["warn"]
+			if warned != 2 || s.summary.BackfillRemaining != 3 {
+				t.Errorf("warned %d with %d remaining, want 2 with 3 remaining", warned, s.summary.BackfillRemaining)
+			}
+			if writes := repo.gh.written(); tc.dryRun && len(writes) != 0 {
+				t.Errorf("a dry run wrote %q", writes)
+			}
+			if softened := s.summary.Softened != ""; softened == tc.dryRun {
+				t.Errorf("warn-only mode %q, want it only without the dry run", s.summary.Softened)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"path"
+)
+
+// baseBranchFilter selects PRs by the branch they target. Entries are globs
+// such as "release/*"; exclusions win over inclusions.
+type baseBranchFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// parseBaseBranchFilter parses comma-separated lists of branches to include
+// and exclude. An empty include list includes every branch.
+func parseBaseBranchFilter(include, exclude string) (baseBranchFilter, error) {
+	f := baseBranchFilter{Include: splitList(include), Exclude: splitList(exclude)}
+	for _, glob := range append(append([]string(nil), f.Include...), f.Exclude...) {
+		if _, err := path.Match(glob, ""); err != nil {
+			return baseBranchFilter{}, fmt.Errorf("invalid branch pattern %q: %v", glob, err)
+		}
+	}
+	return f, nil
+}
+
+// allows reports whether PRs targeting ref are in scope.
+func (f baseBranchFilter) allows(ref string) bool {
+	if matchBranch(f.Exclude, ref) {
+		return false
+	}
+	return len(f.Include) == 0 || matchBranch(f.Include, ref)
+}
+
+func matchBranch(globs []string, ref string) bool {
+	for _, g := range globs {
+		if ok, _ := path.Match(g, ref); ok {
+			return true
+		}
+	}
+	return false
+}
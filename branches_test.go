@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBaseBranchFilter(t *testing.T) {
+	for _, tc := range []struct {
+		include, exclude string
+		allowed          []string
+		denied           []string
+	}{
+		{"", "", []string{"main", "release/1.0", "feature/x/y"}, nil},
+		{"main, release/*", "", []string{"main", "release/1.0"}, []string{"develop", "release/1.0/hotfix", "Main"}},
+		{"", "gh-pages,dependabot/*", []string{"main"}, []string{"gh-pages", "dependabot/npm"}},
+		// Exclusions win over inclusions.
+		{"release/*", "release/legacy-*", []string{"release/2.0"}, []string{"release/legacy-1", "main"}},
+	} {
+		f, err := parseBaseBranchFilter(tc.include, tc.exclude)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, ref := range tc.allowed {
+			if !f.allows(ref) {
+				t.Errorf("--base-branches %q --exclude-base-branches %q leaves out %s", tc.include, tc.exclude, ref)
+			}
+		}
+		for _, ref := range tc.denied {
+			if f.allows(ref) {
+				t.Errorf("--base-branches %q --exclude-base-branches %q allows %s", tc.include, tc.exclude, ref)
+			}
+		}
+	}
+}
+
+func TestParseBaseBranchFilterErrors(t *testing.T) {
+	for _, tc := range []struct{ include, exclude string }{{"release/[", ""}, {"", "main,["}} {
+		if _, err := parseBaseBranchFilter(tc.include, tc.exclude); err == nil || !strings.Contains(err.Error(), "invalid branch pattern") {
+			t.Errorf("parseBaseBranchFilter(%q, %q) returned %v, want an invalid pattern", tc.include, tc.exclude, err)
+		}
+	}
+}
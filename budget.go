@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// errAPIBudgetExhausted is returned for GitHub API calls made after the
+// --max-api-calls budget has been used up.
+var errAPIBudgetExhausted = errors.New("GitHub API call budget exhausted")
+
+// errEmailBudgetExhausted is returned for emails sent after the --max-emails
+// budget has been used up.
+var errEmailBudgetExhausted = errors.New("email budget exhausted")
+
+// runBudget enforces the per-run limits on GitHub API calls and emails. A
+// limit of zero means unlimited.
+type runBudget struct {
+	mu          sync.Mutex
+	maxAPICalls int
+	maxEmails   int
+	apiCalls    int
+	emails      int
+}
+
+func newRunBudget(maxAPICalls, maxEmails int) *runBudget {
+	return &runBudget{maxAPICalls: maxAPICalls, maxEmails: maxEmails}
+}
+
+// reset starts the budget over for a new run of a daemon.
+func (b *runBudget) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.apiCalls, b.emails = 0, 0
+}
+
+// takeAPICall reserves one API call, reporting false if the budget is spent.
+func (b *runBudget) takeAPICall() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.maxAPICalls > 0 && b.apiCalls >= b.maxAPICalls {
+		return false
+	}
+	b.apiCalls++
+	return true
+}
+
+// takeEmail reserves one email, reporting false if the budget is spent.
+func (b *runBudget) takeEmail() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.maxEmails > 0 && b.emails >= b.maxEmails {
+		return false
+	}
+	b.emails++
+	return true
+}
+
+// refundEmail gives back an email reserved with takeEmail that was not sent.
+func (b *runBudget) refundEmail() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.emails--
+}
+
+// exhausted returns a description of the first spent budget relevant to an
+// action, or "" if the action may proceed. Actions that send email check the
+// email budget as well as the API budget.
+func (b *runBudget) exhausted(needsEmail bool) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.maxAPICalls > 0 && b.apiCalls >= b.maxAPICalls {
+		return fmt.Sprintf("API call budget (%d/%d)", b.apiCalls, b.maxAPICalls)
+	}
+	if needsEmail && b.maxEmails > 0 && b.emails >= b.maxEmails {
+		return fmt.Sprintf("email budget (%d/%d)", b.emails, b.maxEmails)
+	}
+	return ""
+}
+
+// usage returns the calls and emails used so far.
+func (b *runBudget) usage() (apiCalls, emails int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.apiCalls, b.emails
+}
+
+// budgetTransport counts GitHub API requests against the run budget and
+// refuses requests once it is exhausted. It sits below the retry and
+// rate-limit transports, so that every attempt they send is counted.
+type budgetTransport struct {
+	base   http.RoundTripper
+	budget *runBudget
+}
+
+func (t *budgetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.budget.takeAPICall() {
+		return nil, errAPIBudgetExhausted
+	}
+	return t.base.RoundTrip(req)
+}
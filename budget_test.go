@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+func TestAPIBudgetRunsOutMidRun(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	failed := map[string]bool{}
+	_, srv := newTestGitHubClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, r.Method+" "+r.URL.Path)
+		first := !failed[r.URL.Path]
+		failed[r.URL.Path] = true
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/repos/acme/api/pulls" && first:
+			http.Error(w, `{"message":"Bad Gateway"}`, http.StatusBadGateway)
+		case r.URL.Path == "/repos/acme/api/issues/1" && first:
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, `{"message":"Too Many Requests"}`, http.StatusTooManyRequests)
+		case r.URL.Path == "/repos/acme/api/pulls":
+			w.Write([]byte(`[{"number":1},{"number":2}]`))
+		default:
+			w.Write([]byte(`{"number":1}`))
+		}
+	}))
+	budget := newRunBudget(4, 0)
+	client, err := getGithubClient("token", srv.URL+"/", "", "", sshProxyOptions{}, budget, 1, newRateLimitWait(time.Minute), newWriteGuard(true, false), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// Listing the PRs is retried once and the first PR is fetched again
+	// after a rate limit: each attempt is charged.
+	prs, _, err := client.PullRequests.List(ctx, "acme", "api", nil)
+	if err != nil || len(prs) != 2 {
+		t.Fatalf("listing PRs returned %d PRs, %v; want 2 after a retry", len(prs), err)
+	}
+	if _, _, err := client.Issues.Get(ctx, "acme", "api", 1); err != nil {
+		t.Fatalf("fetching PR #1 after the rate limit: %v", err)
+	}
+	if calls, _ := budget.usage(); calls != 4 {
+		t.Errorf("charged %d API calls, want 4 for 2 calls each sent twice", calls)
+	}
+
+	// A write refused in read-only mode is never sent, so it is not
+	// charged, and the budget is spent: the run defers what is left.
+	if _, _, err := client.Issues.CreateComment(ctx, "acme", "api", 1, &github.IssueComment{Body: github.Ptr("stale")}); !errors.Is(err, errReadOnly) {
+		t.Errorf("commenting returned %v, want the write blocked", err)
+	}
+	if calls, _ := budget.usage(); calls != 4 {
+		t.Errorf("charged %d API calls after a blocked write, want 4", calls)
+	}
+	if got, want := budget.exhausted(false), "API call budget (4/4)"; got != want {
+		t.Errorf("exhausted = %q, want %q", got, want)
+	}
+	if _, _, err := client.Issues.Get(ctx, "acme", "api", 2); !errors.Is(err, errAPIBudgetExhausted) {
+		t.Errorf("fetching PR #2 returned %v, want the budget exhausted", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"GET /repos/acme/api/pulls", "GET /repos/acme/api/pulls", "GET /repos/acme/api/issues/1", "GET /repos/acme/api/issues/1"}
+	if len(received) != len(want) {
+		t.Fatalf("the server received %q, want %q", received, want)
+	}
+	for i := range want {
+		if received[i] != want[i] {
+			t.Errorf("request %d was %s, want %s", i+1, received[i], want[i])
+		}
+	}
+}
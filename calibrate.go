@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// calibrationSample is a closed PR and the longest period it went without
+// activity before it was merged or closed.
+type calibrationSample struct {
+	Number int
+	Merged bool
+	MaxGap time.Duration
+}
+
+// longestGap returns the longest period without activity between a PR's
+// opening and its end (merge or closure), ignoring the bot's own events.
+func longestGap(opened, ended time.Time, events []*github.Timeline, botLogin string) time.Duration {
+	times := []time.Time{opened, ended}
+	for _, ev := range events {
+		actor := timelineActor(ev)
+		if botLogin != "" && strings.EqualFold(actor, botLogin) {
+			continue
+		}
+		if a, ok := eventActivity(ev, actor); ok && a.At.After(opened) && a.At.Before(ended) {
+			times = append(times, a.At)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	var longest time.Duration
+	for i := 1; i < len(times); i++ {
+		if gap := times[i].Sub(times[i-1]); gap > longest {
+			longest = gap
+		}
+	}
+	return longest
+}
+
+// calibrationRow summarizes the closed PRs a --days-inactive threshold would
+// have flagged.
+type calibrationRow struct {
+	Days int
+	// LookedDead counts PRs that went at least Days without activity.
+	LookedDead int
+	// Merged counts the PRs among them that were merged anyway.
+	Merged int
+	// FalsePositiveRate is Merged / LookedDead: how often a PR that looked
+	// dead for Days still got merged.
+	FalsePositiveRate float64
+	// MergedAffected is the share of all merged PRs the threshold would
+	// have flagged.
+	MergedAffected float64
+}
+
+// calibrate computes a row per threshold in days.
+func calibrate(samples []calibrationSample, thresholds []int) []calibrationRow {
+	merged := 0
+	for _, s := range samples {
+		if s.Merged {
+			merged++
+		}
+	}
+	rows := make([]calibrationRow, 0, len(thresholds))
+	for _, days := range thresholds {
+		row := calibrationRow{Days: days}
+		for _, s := range samples {
+			if s.MaxGap < time.Duration(days)*24*time.Hour {
+				continue
+			}
+			row.LookedDead++
+			if s.Merged {
+				row.Merged++
+			}
+		}
+		if row.LookedDead > 0 {
+			row.FalsePositiveRate = float64(row.Merged) / float64(row.LookedDead)
+		}
+		if merged > 0 {
+			row.MergedAffected = float64(row.Merged) / float64(merged)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// suggestThreshold returns the smallest threshold whose false-positive rate
+// is at most target, ignoring thresholds that flag fewer than minSamples PRs.
+func suggestThreshold(rows []calibrationRow, target float64, minSamples int) (calibrationRow, bool) {
+	for _, row := range rows {
+		if row.LookedDead >= minSamples && row.FalsePositiveRate <= target {
+			return row, true
+		}
+	}
+	return calibrationRow{}, false
+}
+
+// getCalibrationSamples collects the PRs of a repository closed since since,
+// at most max of them, with their longest period without activity. PRs the
+// bot closed for inactivity are left out: their outcome was decided by the
+// current threshold.
+func getCalibrationSamples(client *github.Client, owner, repo string, since time.Time, max int, botLogin string) ([]calibrationSample, bool, error) {
+	ctx := context.Background()
+	query := fmt.Sprintf("repo:%s/%s is:pr is:closed closed:>=%s -label:closed-stale", owner, repo, since.UTC().Format("2006-01-02"))
+	opt := &github.SearchOptions{Sort: "created", Order: "desc", ListOptions: github.ListOptions{PerPage: 100}}
+	var samples []calibrationSample
+	for {
+		res, resp, err := client.Search.Issues(ctx, query, opt)
+		if err != nil {
+			return nil, false, fmt.Errorf("search %q failed: %v", query, err)
+		}
+		for _, issue := range res.Issues {
+			if max > 0 && len(samples) >= max {
+				return samples, true, nil
+			}
+			ended := issue.GetClosedAt().Time
+			merged := issue.GetPullRequestLinks().GetMergedAt()
+			if !merged.IsZero() {
+				ended = merged.Time
+			}
+			events, err := getTimeline(client, owner, repo, issue.GetNumber())
+			if err != nil {
+				return nil, false, err
+			}
+			samples = append(samples, calibrationSample{
+				Number: issue.GetNumber(),
+				Merged: !merged.IsZero(),
+				MaxGap: longestGap(issue.GetCreatedAt().Time, ended, events, botLogin),
+			})
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return samples, false, nil
+}
+
+// runCalibrateCommand implements "stale-pr-bot calibrate": a read-only report
+// of how long closed PRs went without activity, suggesting a --days-inactive
+// that rarely flags PRs that would have been merged.
+func runCalibrateCommand(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("calibrate", flag.ContinueOnError)
+	tokenFlag := fs.String("github-token", os.Getenv("GITHUB_TOKEN"), "GitHub API token")
+	baseURLFlag := fs.String("github-base-url", os.Getenv("GITHUB_BASE_URL"), "GitHub API base URL")
+	ownerFlag := fs.String("owner", os.Getenv("GITHUB_OWNER"), "GitHub repository owner; may be left out when --repo names it")
+	repoFlag := fs.String("repo", os.Getenv("GITHUB_REPO"), "GitHub repository as name, owner/name or URL")
+	monthsFlag := fs.Int("months", 6, "Analyze PRs closed in the last N months")
+	maxPRsFlag := fs.Int("max-prs", 500, "Maximum number of closed PRs to analyze, newest first (0 = all the search API returns)")
+	thresholdsFlag := fs.String("thresholds", "7,14,21,30,45,60,90,120,180", "Comma-separated --days-inactive candidates to report")
+	targetFlag := fs.Float64("target", 0.05, "Highest acceptable false-positive rate for the suggested threshold (0-1)")
+	minSamplesFlag := fs.Int("min-samples", 10, "Fewest PRs a threshold must flag to be suggested")
+	csvFlag := fs.String("csv", "", "Also write the table as CSV to this file")
+	// Handled before the subcommand runs; accepted here so they parse.
+	fs.Bool("no-dotenv", false, "Do not load a .env file")
+	fs.String("dotenv-path", "", ".env file to load instead of ./.env")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *repoFlag != "" {
+		owner, names, err := resolveRepos(*ownerFlag, []string{*repoFlag})
+		if err != nil {
+			return fmt.Errorf("invalid --owner or --repo: %v", err)
+		}
+		*ownerFlag, *repoFlag = owner, names[0]
+	}
+	if *tokenFlag == "" || *baseURLFlag == "" || *ownerFlag == "" || *repoFlag == "" {
+		return fmt.Errorf("--github-token, --github-base-url, --owner and --repo are required")
+	}
+	if *monthsFlag <= 0 {
+		return fmt.Errorf("--months must be positive")
+	}
+	if *targetFlag < 0 || *targetFlag > 1 {
+		return fmt.Errorf("--target must be between 0 and 1")
+	}
+	var thresholds []int
+	for _, t := range splitList(*thresholdsFlag) {
+		days, err := strconv.Atoi(t)
+		if err != nil || days <= 0 {
+			return fmt.Errorf("invalid threshold %q: must be a positive number of days", t)
+		}
+		thresholds = append(thresholds, days)
+	}
+	sort.Ints(thresholds)
+
+	client, err := getGithubClient(*tokenFlag, *baseURLFlag, "", "calibrate", sshProxyOptions{}, newRunBudget(0, 0), 0, nil, newWriteGuard(true, false), nil)
+	if err != nil {
+		return err
+	}
+	botLogin, err := testGitHubConnection(client)
+	if err != nil {
+		return err
+	}
+	since := time.Now().AddDate(0, -*monthsFlag, 0)
+	samples, truncated, err := getCalibrationSamples(client, *ownerFlag, *repoFlag, since, *maxPRsFlag, botLogin)
+	if err != nil {
+		return err
+	}
+
+	var mergedGaps []float64
+	for _, s := range samples {
+		if s.Merged {
+			mergedGaps = append(mergedGaps, s.MaxGap.Hours()/24)
+		}
+	}
+	fmt.Fprintf(w, "Calibration for %s/%s: %d closed PR(s) since %s, %d merged.\n", *ownerFlag, *repoFlag, len(samples), since.Format("2006-01-02"), len(mergedGaps))
+	if truncated {
+		fmt.Fprintf(w, "Only the newest %d PR(s) were analyzed; raise --max-prs to include more.\n", *maxPRsFlag)
+	}
+	if len(samples) == 0 {
+		return nil
+	}
+	if len(mergedGaps) > 0 {
+		fmt.Fprintf(w, "Longest inactivity of merged PRs, in days: p50 %.1f, p75 %.1f, p90 %.1f, p95 %.1f, p99 %.1f\n\n",
+			percentile(mergedGaps, 50), percentile(mergedGaps, 75), percentile(mergedGaps, 90), percentile(mergedGaps, 95), percentile(mergedGaps, 99))
+	}
+
+	rows := calibrate(samples, thresholds)
+	header := []string{"days_inactive", "looked_dead", "merged_anyway", "false_positive_rate", "merged_prs_affected"}
+	cells := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		cells = append(cells, []string{
+			strconv.Itoa(row.Days),
+			strconv.Itoa(row.LookedDead),
+			strconv.Itoa(row.Merged),
+			strconv.FormatFloat(row.FalsePositiveRate, 'f', 3, 64),
+			strconv.FormatFloat(row.MergedAffected, 'f', 3, 64),
+		})
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	writeTabRow(tw, header)
+	for _, c := range cells {
+		writeTabRow(tw, c)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if row, ok := suggestThreshold(rows, *targetFlag, *minSamplesFlag); ok {
+		fmt.Fprintf(w, "\nSuggested --days-inactive=%d: %d of %d PR(s) inactive that long were merged anyway (%.1f%%, target %.1f%%).\n",
+			row.Days, row.Merged, row.LookedDead, row.FalsePositiveRate*100, *targetFlag*100)
+	} else {
+		fmt.Fprintf(w, "\nNo threshold flags at least %d PR(s) with a false-positive rate of %.1f%% or less.\n", *minSamplesFlag, *targetFlag*100)
+	}
+
+	if *csvFlag != "" {
+		f, err := os.Create(*csvFlag)
+		if err != nil {
+			return fmt.Errorf("failed to create CSV file: %v", err)
+		}
+		defer f.Close()
+		cw := csv.NewWriter(f)
+		cw.Write(header)
+		cw.WriteAll(cells)
+		if err := cw.Error(); err != nil {
+			return fmt.Errorf("failed to write CSV file: %v", err)
+		}
+	}
+	return nil
+}
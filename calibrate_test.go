@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+func TestLongestGap(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 1, d, 0, 0, 0, 0, time.UTC) }
+	commit := &github.Timeline{Event: github.Ptr("committed"), SHA: github.Ptr("abc1234"), Committer: &github.CommitAuthor{Date: &github.Timestamp{Time: day(10)}}}
+	for _, tc := range []struct {
+		name   string
+		events []*github.Timeline
+		want   int
+	}{
+		{name: "no events", want: 24},
+		{name: "activity splits the gap", events: []*github.Timeline{timelineEvent("commented", "bob", day(3)), commit}, want: 15},
+		{name: "the bot's events are ignored", events: []*github.Timeline{commit, timelineEvent("commented", "stale-bot", day(20))}, want: 15},
+		{name: "events outside the PR's life are ignored", events: []*github.Timeline{timelineEvent("commented", "bob", day(28))}, want: 24},
+		{name: "label changes are not activity", events: []*github.Timeline{labelTimelineEvent("labeled", "bug", "bob", day(13))}, want: 24},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := longestGap(day(1), day(25), tc.events, "stale-bot"); got != time.Duration(tc.want)*24*time.Hour {
+				t.Errorf("longestGap = %s, want %d days", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCalibrate(t *testing.T) {
+	sample := func(number int, merged bool, gapDays int) calibrationSample {
+		return calibrationSample{Number: number, Merged: merged, MaxGap: time.Duration(gapDays) * 24 * time.Hour}
+	}
+	samples := []calibrationSample{
+		sample(1, true, 15), sample(2, true, 48), sample(3, false, 90), sample(4, false, 2), sample(5, true, 5), sample(6, false, 30),
+	}
+	rows := calibrate(samples, []int{7, 30, 60, 120})
+	want := []calibrationRow{
+		{Days: 7, LookedDead: 4, Merged: 2, FalsePositiveRate: 0.5, MergedAffected: 2.0 / 3},
+		{Days: 30, LookedDead: 3, Merged: 1, FalsePositiveRate: 1.0 / 3, MergedAffected: 1.0 / 3},
+		{Days: 60, LookedDead: 1},
+		{Days: 120},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("calibrate returned %+v, want %+v", rows, want)
+	}
+	for i, row := range rows {
+		w := want[i]
+		if row.Days != w.Days || row.LookedDead != w.LookedDead || row.Merged != w.Merged ||
+			math.Abs(row.FalsePositiveRate-w.FalsePositiveRate) > 1e-9 || math.Abs(row.MergedAffected-w.MergedAffected) > 1e-9 {
+			t.Errorf("row %d = %+v, want %+v", i, row, w)
+		}
+	}
+
+	for _, tc := range []struct {
+		target     float64
+		minSamples int
+		want       int
+	}{
+		{target: 0.5, minSamples: 1, want: 7},
+		{target: 0.4, minSamples: 1, want: 30},
+		{target: 0.05, minSamples: 1, want: 60},
+		{target: 0.4, minSamples: 4},
+		{target: 0, minSamples: 0, want: 60},
+	} {
+		row, ok := suggestThreshold(rows, tc.target, tc.minSamples)
+		if ok != (tc.want != 0) || row.Days != tc.want {
+			t.Errorf("suggestThreshold(%v, %d) = %d, %v, want %d", tc.target, tc.minSamples, row.Days, ok, tc.want)
+		}
+	}
+}
+
+// calibrationPRs are the closed PRs the fake search returns, two per page.
+var calibrationPRs = []string{
+	`{"number":1,"created_at":"2026-01-01T00:00:00Z","closed_at":"2026-01-20T00:00:00Z","pull_request":{"merged_at":"2026-01-20T00:00:00Z"}}`,
+	`{"number":2,"created_at":"2026-01-01T00:00:00Z","closed_at":"2026-03-01T00:00:00Z","pull_request":{"merged_at":"2026-03-01T00:00:00Z"}}`,
+	`{"number":3,"created_at":"2026-01-01T00:00:00Z","closed_at":"2026-04-01T00:00:00Z","pull_request":{}}`,
+	`{"number":4,"created_at":"2026-02-01T00:00:00Z","closed_at":"2026-02-03T00:00:00Z","pull_request":{}}`,
+	`{"number":5,"created_at":"2026-02-01T00:00:00Z","closed_at":"2026-02-06T00:00:00Z","pull_request":{"merged_at":"2026-02-06T00:00:00Z"}}`,
+}
+
+// serveCalibration serves the authenticated user, a paginated search of
+// calibrationPRs and their timelines.
+func serveCalibration(t *testing.T) *httptest.Server {
+	t.Helper()
+	timelines := map[string]string{
+		"/repos/acme/api/issues/1/timeline": `[{"event":"commented","actor":{"login":"bob"},"created_at":"2026-01-05T00:00:00Z"}]`,
+		"/repos/acme/api/issues/2/timeline": `[{"event":"committed","sha":"abc1234","committer":{"date":"2026-01-10T00:00:00Z"}},{"event":"reviewed","user":{"login":"bob"},"submitted_at":"2026-01-12T00:00:00Z"}]`,
+		"/repos/acme/api/issues/5/timeline": `[{"event":"commented","actor":{"login":"stale-bot"},"created_at":"2026-02-03T00:00:00Z"}]`,
+	}
+	_, srv := newTestGitHubClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/user":
+			fmt.Fprint(w, `{"login":"stale-bot"}`)
+		case r.URL.Path == "/search/issues":
+			if !strings.Contains(r.URL.Query().Get("q"), "repo:acme/api is:pr is:closed") {
+				t.Errorf("searched for %q", r.URL.Query().Get("q"))
+			}
+			page := 1
+			fmt.Sscan(r.URL.Query().Get("page"), &page)
+			end := min(2*page, len(calibrationPRs))
+			if end < len(calibrationPRs) {
+				w.Header().Set("Link", fmt.Sprintf(`<%s/search/issues?page=%d>; rel="next"`, "http://"+r.Host, page+1))
+			}
+			fmt.Fprintf(w, `{"total_count":%d,"items":[%s]}`, len(calibrationPRs), strings.Join(calibrationPRs[2*page-2:end], ","))
+		default:
+			if body, ok := timelines[r.URL.Path]; ok {
+				fmt.Fprint(w, body)
+				return
+			}
+			fmt.Fprint(w, `[]`)
+		}
+	}))
+	return srv
+}
+
+func TestGetCalibrationSamples(t *testing.T) {
+	srv := serveCalibration(t)
+	client, err := getGithubClient("token", srv.URL+"/", "", "", sshProxyOptions{}, newRunBudget(0, 0), 0, nil, newWriteGuard(true, false), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	samples, truncated, err := getCalibrationSamples(client, "acme", "api", since, 0, "stale-bot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[{1 true 360h0m0s} {2 true 1152h0m0s} {3 false 2160h0m0s} {4 false 48h0m0s} {5 true 120h0m0s}]"
+	if fmt.Sprint(samples) != want || truncated {
+		t.Errorf("samples %v (truncated %v), want all 5: %s", samples, truncated, want)
+	}
+
+	samples, truncated, err = getCalibrationSamples(client, "acme", "api", since, 3, "stale-bot")
+	if err != nil || len(samples) != 3 || !truncated {
+		t.Errorf("with a maximum of 3 got %v, %v, %v, want 3 samples, truncated", samples, truncated, err)
+	}
+}
+
+// TestCalibrateCommandGolden runs the calibrate subcommand against the fake
+// GitHub and compares its report with a golden file and its CSV table.
+func TestCalibrateCommandGolden(t *testing.T) {
+	captureLog(t)
+	srv := serveCalibration(t)
+	csvPath := filepath.Join(t.TempDir(), "calibration.csv")
+	var out bytes.Buffer
+	err := runCalibrateCommand([]string{
+		"--github-token", "token", "--github-base-url", srv.URL + "/", "--repo", "acme/api",
+		"--thresholds", "30,7,14,60", "--target", "0.1", "--min-samples", "1", "--csv", csvPath,
+	}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	since := time.Now().AddDate(0, -6, 0).Format("2006-01-02")
+	got := strings.Replace(out.String(), since, "<since>", 1)
+	golden := filepath.Join("testdata", "calibrate", "report.golden")
+	if *updateGolden {
+		if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	} else if want, err := os.ReadFile(golden); err != nil {
+		t.Fatalf("%v; run the test with -update to create it", err)
+	} else if got != string(want) {
+		t.Errorf("calibrate output differs from %s; run the test with -update to accept it:\n--- got ---\n%s--- want ---\n%s", filepath.Base(golden), got, want)
+	}
+
+	csv, err := os.ReadFile(csvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCSV := `days_inactive,looked_dead,merged_anyway,false_positive_rate,merged_prs_affected
+7,3,2,0.667,0.667
+14,3,2,0.667,0.667
+30,2,1,0.500,0.333
+60,1,0,0.000,0.000
+`
+	if string(csv) != wantCSV {
+		t.Errorf("CSV:\n%s\nwant:\n%s", csv, wantCSV)
+	}
+}
+
+func TestCalibrateCommandFlags(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GITHUB_BASE_URL", "")
+	required := []string{"--github-token", "token", "--github-base-url", "http://127.0.0.1:1/", "--repo", "acme/api"}
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"--repo", "acme/api"}, want: "are required"},
+		{args: append(required, "--months", "0"), want: "--months must be positive"},
+		{args: append(required, "--target", "1.5"), want: "--target must be between 0 and 1"},
+		{args: append(required, "--thresholds", "30,soon"), want: `invalid threshold "soon"`},
+		{args: append(required, "--thresholds", "-7"), want: `invalid threshold "-7"`},
+		{args: []string{"--repo", "a/b/c/d"}, want: "invalid --owner or --repo"},
+	} {
+		err := runCalibrateCommand(tc.args, &bytes.Buffer{})
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("runCalibrateCommand(%q) returned %v, want an error containing %q", tc.args, err, tc.want)
+		}
+	}
+}
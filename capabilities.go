@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// capability is an operation the bot may need, probed with a read request so
+// that narrowly scoped tokens can be detected before the run starts.
+type capability struct {
+	Name string
+	// Required capabilities stop the run when missing. Missing optional
+	// ones disable Feature instead.
+	Required bool
+	Feature  string
+	probe    func(ctx context.Context) error
+}
+
+// isPermissionError reports whether err means the token may not perform the
+// request. GitHub answers 404 instead of 403 for resources a token cannot see.
+func isPermissionError(err error) bool {
+	var errResp *github.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Response == nil {
+		return false
+	}
+	return errResp.Response.StatusCode == http.StatusForbidden || errResp.Response.StatusCode == http.StatusNotFound
+}
+
+// probeCapabilities runs the probes and logs what is enabled and disabled. It returns the names of the missing capabilities, or an error
+// if a required one is missing or a probe failed for another reason.
+func probeCapabilities(caps []capability) (map[string]bool, error) {
+	ctx := context.Background()
+	missing := map[string]bool{}
+	var required []string
+	for _, c := range caps {
+		err := c.probe(ctx)
+		switch {
+		case err == nil:
+			slog.Info("token capability available", "capability", c.Name)
+		case !isPermissionError(err):
+			return nil, fmt.Errorf("probing %q failed: %v", c.Name, err)
+		case c.Required:
+			slog.Error("token capability missing", "capability", c.Name, "required", true, "err", err)
+			required = append(required, c.Name)
+		default:
+			slog.Warn("token capability missing", "capability", c.Name, "disabled", c.Feature, "err", err)
+		}
+		if err != nil {
+			missing[c.Name] = true
+		}
+	}
+	if len(required) > 0 {
+		return missing, fmt.Errorf("the token lacks required capabilities: %s", strings.Join(required, ", "))
+	}
+	return missing, nil
+}
+
+// Names of the probed capabilities.
+const (
+	capabilityUser   = "identify the authenticated user"
+	capabilityPulls  = "list pull requests"
+	capabilityChecks = "read check runs and commit statuses"
+	capabilityTeams  = "list security team members"
+	capabilitySearch = "search pull requests"
+	capabilityEvents = "list repository events"
+)
+
+// capabilityNeeds says which optional features are enabled and so need to
+// be probed.
+type capabilityNeeds struct {
+	Checks       bool
+	SecurityTeam string
+	Search       bool
+	Events       bool
+}
+
+// tokenCapabilities returns the probes for the bot's required operations and
+// for the optional features in needs. The user probe stores the token's login
+// in login.
+func tokenCapabilities(client *github.Client, owner, repo string, needs capabilityNeeds, login *string) []capability {
+	caps := []capability{
+		{
+			Name:    capabilityUser,
+			Feature: "\"/stale status\" replies",
+			probe: func(ctx context.Context) error {
+				user, _, err := client.Users.Get(ctx, "")
+				*login = user.GetLogin()
+				return err
+			},
+		},
+		{
+			Name:     capabilityPulls,
+			Required: true,
+			probe: func(ctx context.Context) error {
+				_, _, err := client.PullRequests.List(ctx, owner, repo, &github.PullRequestListOptions{State: "open", ListOptions: github.ListOptions{PerPage: 1}})
+				return err
+			},
+		},
+	}
+	if needs.Checks {
+		caps = append(caps, capability{
+			Name:    capabilityChecks,
+			Feature: "staleness from failing checks",
+			probe: func(ctx context.Context) error {
+				r, _, err := client.Repositories.Get(ctx, owner, repo)
+				if err != nil {
+					return err
+				}
+				branch := r.GetDefaultBranch()
+				if _, _, err := client.Checks.ListCheckRunsForRef(ctx, owner, repo, branch, &github.ListCheckRunsOptions{ListOptions: github.ListOptions{PerPage: 1}}); err != nil {
+					return err
+				}
+				_, _, err = client.Repositories.GetCombinedStatus(ctx, owner, repo, branch, &github.ListOptions{PerPage: 1})
+				return err
+			},
+		})
+	}
+	if needs.SecurityTeam != "" {
+		caps = append(caps, capability{
+			Name:    capabilityTeams,
+			Feature: "security team exemption",
+			probe: func(ctx context.Context) error {
+				_, _, err := client.Teams.ListTeamMembersBySlug(ctx, owner, needs.SecurityTeam, &github.TeamListTeamMembersOptions{ListOptions: github.ListOptions{PerPage: 1}})
+				return err
+			},
+		})
+	}
+	if needs.Search {
+		caps = append(caps, capability{
+			Name:    capabilitySearch,
+			Feature: "auto-softening",
+			probe: func(ctx context.Context) error {
+				_, _, err := client.Search.Issues(ctx, fmt.Sprintf("repo:%s/%s is:pr", owner, repo), &github.SearchOptions{ListOptions: github.ListOptions{PerPage: 1}})
+				return err
+			},
+		})
+	}
+	if needs.Events {
+		caps = append(caps, capability{
+			Name:    capabilityEvents,
+			Feature: "incremental scans",
+			probe: func(ctx context.Context) error {
+				_, _, err := client.Activity.ListRepositoryEvents(ctx, owner, repo, &github.ListOptions{PerPage: 1})
+				return err
+			},
+		})
+	}
+	return caps
+}
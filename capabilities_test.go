@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestProbeCapabilities simulates tokens with different capability sets by
+// answering the probes of the operations a token lacks with 403 or 404.
+func TestProbeCapabilities(t *testing.T) {
+	all := capabilityNeeds{Checks: true, SecurityTeam: "security", Search: true, Events: true}
+	for _, tc := range []struct {
+		name        string
+		needs       capabilityNeeds
+		denied      []string
+		status      int
+		wantMissing map[string]bool
+		wantErr     string
+		wantLogin   string
+	}{
+		{name: "full token", needs: all, wantMissing: map[string]bool{}, wantLogin: "stale-bot"},
+		{
+			name:        "fine-grained issues and pull requests only",
+			needs:       all,
+			denied:      []string{"/user", "/repos/acme/api/commits/main/check-runs", "/orgs/acme/teams/security/members", "/repos/acme/api/events"},
+			status:      http.StatusForbidden,
+			wantMissing: map[string]bool{capabilityUser: true, capabilityChecks: true, capabilityTeams: true, capabilityEvents: true},
+		},
+		{
+			name:        "hidden team",
+			needs:       capabilityNeeds{SecurityTeam: "security"},
+			denied:      []string{"/orgs/acme/teams/security/members"},
+			status:      http.StatusNotFound,
+			wantMissing: map[string]bool{capabilityTeams: true},
+			wantLogin:   "stale-bot",
+		},
+		{
+			name:    "pull requests missing",
+			needs:   all,
+			denied:  []string{"/repos/acme/api/pulls", "/search/issues"},
+			status:  http.StatusForbidden,
+			wantErr: "the token lacks required capabilities: " + capabilityPulls,
+		},
+		{
+			name:    "server error",
+			needs:   all,
+			denied:  []string{"/search/issues"},
+			status:  http.StatusInternalServerError,
+			wantErr: "probing \"" + capabilitySearch + "\" failed",
+		},
+		{
+			name:        "features off are not probed",
+			denied:      []string{"/repos/acme/api/commits/main/check-runs", "/search/issues", "/repos/acme/api/events"},
+			status:      http.StatusForbidden,
+			wantMissing: map[string]bool{},
+			wantLogin:   "stale-bot",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			captureLog(t)
+			client, _ := newTestGitHubClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for _, path := range tc.denied {
+					if r.URL.Path == path {
+						http.Error(w, `{"message":"Resource not accessible by personal access token"}`, tc.status)
+						return
+					}
+				}
+				switch r.URL.Path {
+				case "/user":
+					w.Write([]byte(`{"login":"stale-bot"}`))
+				case "/repos/acme/api":
+					w.Write([]byte(`{"default_branch":"main"}`))
+				case "/search/issues":
+					w.Write([]byte(`{"total_count":0}`))
+				case "/repos/acme/api/commits/main/check-runs", "/repos/acme/api/commits/main/status":
+					w.Write([]byte(`{}`))
+				default:
+					w.Write([]byte(`[]`))
+				}
+			}))
+			var login string
+			missing, err := probeCapabilities(tokenCapabilities(client, "acme", "api", tc.needs, &login))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("probeCapabilities returned %v, want an error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("probeCapabilities returned %v", err)
+			}
+			if !reflect.DeepEqual(missing, tc.wantMissing) {
+				t.Errorf("missing capabilities = %v, want %v", missing, tc.wantMissing)
+			}
+			if login != tc.wantLogin {
+				t.Errorf("login = %q, want %q", login, tc.wantLogin)
+			}
+		})
+	}
+}
+
+// TestCapabilityReport checks that the startup report names each disabled
+// feature and why.
+func TestCapabilityReport(t *testing.T) {
+	logs := captureLog(t)
+	client, _ := newTestGitHubClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/search/issues" {
+			http.Error(w, `{"message":"Forbidden"}`, http.StatusForbidden)
+			return
+		}
+		if r.URL.Path == "/user" {
+			w.Write([]byte(`{"login":"stale-bot"}`))
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	var login string
+	if _, err := probeCapabilities(tokenCapabilities(client, "acme", "api", capabilityNeeds{Search: true}, &login)); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"token capability available", capabilityPulls, "token capability missing", "auto-softening", "403"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("the report does not mention %q:\n%s", want, logs)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// checkStatus summarizes the failing checks on a PR's head commit.
+type checkStatus struct {
+	// Failing lists the names of the failing required checks.
+	Failing []string
+	// FailingSince is when the earliest of them failed.
+	FailingSince time.Time
+}
+
+// summarizeChecks returns the failing checks among the check runs and commit
+// statuses of a commit. When required is non-empty only the named checks are
+// considered. Neutral, skipped, cancelled and pending checks are not failing.
+func summarizeChecks(runs []*github.CheckRun, statuses []*github.RepoStatus, required map[string]bool) checkStatus {
+	var cs checkStatus
+	fail := func(name string, at time.Time) {
+		if len(required) > 0 && !required[name] {
+			return
+		}
+		cs.Failing = append(cs.Failing, name)
+		if cs.FailingSince.IsZero() || at.Before(cs.FailingSince) {
+			cs.FailingSince = at
+		}
+	}
+	for _, run := range runs {
+		switch run.GetConclusion() {
+		case "failure", "timed_out", "startup_failure":
+			fail(run.GetName(), run.GetCompletedAt().Time)
+		}
+	}
+	for _, st := range statuses {
+		switch st.GetState() {
+		case "failure", "error":
+			fail(st.GetContext(), st.GetUpdatedAt().Time)
+		}
+	}
+	sort.Strings(cs.Failing)
+	return cs
+}
+
+// checksCache fetches the check state of PR head commits, fetching each
+// commit and each base branch's required checks once per run.
+type checksCache struct {
+	client   *github.Client
+	owner    string
+	repo     string
+	required map[string]map[string]bool
+	byHead   map[string]*checkStatus
+}
+
+func newChecksCache(client *github.Client, owner, repo string) *checksCache {
+	return &checksCache{
+		client:   client,
+		owner:    owner,
+		repo:     repo,
+		required: map[string]map[string]bool{},
+		byHead:   map[string]*checkStatus{},
+	}
+}
+
+// status returns the failing checks on the PR's head commit.
+func (c *checksCache) status(pr *github.PullRequest) (*checkStatus, error) {
+	sha := pr.GetHead().GetSHA()
+	if cs, ok := c.byHead[sha]; ok {
+		return cs, nil
+	}
+	ctx := context.Background()
+	required := c.requiredChecks(pr.GetBase().GetRef())
+
+	var runs []*github.CheckRun
+	opt := &github.ListCheckRunsOptions{Filter: github.Ptr("latest"), ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		res, resp, err := c.client.Checks.ListCheckRunsForRef(ctx, c.owner, c.repo, sha, opt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list check runs: %v", err)
+		}
+		runs = append(runs, res.CheckRuns...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	combined, _, err := c.client.Repositories.GetCombinedStatus(ctx, c.owner, c.repo, sha, &github.ListOptions{PerPage: 100})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit status: %v", err)
+	}
+
+	cs := summarizeChecks(runs, combined.Statuses, required)
+	c.byHead[sha] = &cs
+	return &cs, nil
+}
+
+// requiredChecks returns the checks required by branch protection on base.
+// An unprotected branch, or one whose protection cannot be read, yields nil
+// so that every check is considered.
+func (c *checksCache) requiredChecks(base string) map[string]bool {
+	if required, ok := c.required[base]; ok {
+		return required
+	}
+	var required map[string]bool
+	checks, _, err := c.client.Repositories.GetRequiredStatusChecks(context.Background(), c.owner, c.repo, base)
+	if err == nil {
+		required = map[string]bool{}
+		for _, name := range checks.GetContexts() {
+			required[name] = true
+		}
+		for _, check := range checks.GetChecks() {
+			required[check.Context] = true
+		}
+	}
+	c.required[base] = required
+	return required
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+func TestSummarizeChecks(t *testing.T) {
+	at := func(day int) time.Time { return time.Date(2026, 3, day, 12, 0, 0, 0, time.UTC) }
+	run := func(name, conclusion string, day int) *github.CheckRun {
+		return &github.CheckRun{Name: github.Ptr(name), Conclusion: github.Ptr(conclusion), CompletedAt: &github.Timestamp{Time: at(day)}}
+	}
+	status := func(context, state string, day int) *github.RepoStatus {
+		return &github.RepoStatus{Context: github.Ptr(context), State: github.Ptr(state), UpdatedAt: &github.Timestamp{Time: at(day)}}
+	}
+	runs := []*github.CheckRun{
+		run("test", "failure", 5),
+		run("lint", "success", 1),
+		run("docs", "neutral", 1),
+		run("e2e", "timed_out", 3),
+		run("deploy-preview", "skipped", 1),
+		run("bench", "cancelled", 1),
+		{Name: github.Ptr("build"), Status: github.Ptr("in_progress")},
+	}
+	statuses := []*github.RepoStatus{
+		status("ci/jenkins", "error", 4),
+		status("coverage", "pending", 1),
+		status("license/cla", "success", 1),
+	}
+	for _, tc := range []struct {
+		name     string
+		required map[string]bool
+		want     string
+		since    time.Time
+	}{
+		{name: "all checks", want: "[ci/jenkins e2e test]", since: at(3)},
+		{name: "required only", required: map[string]bool{"test": true, "lint": true, "ci/jenkins": true}, want: "[ci/jenkins test]", since: at(4)},
+		{name: "required passing", required: map[string]bool{"lint": true, "docs": true}, want: "[]"},
+	} {
+		cs := summarizeChecks(runs, statuses, tc.required)
+		if fmt.Sprint(cs.Failing) != tc.want || !cs.FailingSince.Equal(tc.since) {
+			t.Errorf("%s: failing %v since %v, want %s since %v", tc.name, cs.Failing, cs.FailingSince, tc.want, tc.since)
+		}
+	}
+}
+
+// serveTestChecks serves the checks of acme/api: a failing check run and a
+// passing status on every commit, and main requiring the check run. It
+// counts the requests per path.
+func serveTestChecks(t *testing.T) (*github.Client, map[string]int) {
+	t.Helper()
+	var mu sync.Mutex
+	requests := map[string]int{}
+	client, _ := newTestGitHubClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/check-runs"):
+			fmt.Fprint(w, `{"total_count":2,"check_runs":[{"name":"test","conclusion":"failure","completed_at":"2026-03-01T10:00:00Z"},{"name":"docs","conclusion":"failure","completed_at":"2026-02-01T10:00:00Z"}]}`)
+		case strings.HasSuffix(r.URL.Path, "/status"):
+			fmt.Fprint(w, `{"state":"success","statuses":[{"context":"ci/jenkins","state":"success"}]}`)
+		case r.URL.Path == "/repos/acme/api/branches/main/protection/required_status_checks":
+			fmt.Fprint(w, `{"contexts":["test"]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return client, requests
+}
+
+func TestChecksCacheFetchesOnce(t *testing.T) {
+	client, requests := serveTestChecks(t)
+	c := newChecksCache(client, "acme", "api")
+	pr := func(number int, sha, base string) *github.PullRequest {
+		return &github.PullRequest{Number: github.Ptr(number), Head: &github.PullRequestBranch{SHA: github.Ptr(sha)}, Base: &github.PullRequestBranch{Ref: github.Ptr(base)}}
+	}
+	for _, tc := range []struct {
+		pr   *github.PullRequest
+		want string
+	}{
+		{pr(1, "aaa", "main"), "[test]"},
+		// Two PRs on the same head commit share its checks.
+		{pr(2, "aaa", "main"), "[test]"},
+		{pr(3, "bbb", "main"), "[test]"},
+		// An unprotected branch requires every check.
+		{pr(4, "ccc", "release"), "[docs test]"},
+	} {
+		cs, err := c.status(tc.pr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(cs.Failing) != tc.want {
+			t.Errorf("PR #%d: failing %v, want %s", tc.pr.GetNumber(), cs.Failing, tc.want)
+		}
+	}
+	for path, want := range map[string]int{
+		"/repos/acme/api/commits/aaa/check-runs":                             1,
+		"/repos/acme/api/commits/aaa/status":                                 1,
+		"/repos/acme/api/branches/main/protection/required_status_checks":    1,
+		"/repos/acme/api/branches/release/protection/required_status_checks": 1,
+	} {
+		if requests[path] != want {
+			t.Errorf("requested %s %d times, want %d", path, requests[path], want)
+		}
+	}
+}
+
+// TestFailingChecksMakeStale decides PRs with recent activity and failing,
+// passing or neutral checks against a 14-day --failing-checks-stale-after.
+func TestFailingChecksMakeStale(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	rules := testRules()
+	rules.FailingChecksStaleAfter = 14 * 24 * time.Hour
+	for _, tc := range []struct {
+		name    string
+		checks  *checkStatus
+		want    string
+		failing string
+		staleAt time.Time
+	}{
+		{name: "failing for weeks", checks: &checkStatus{Failing: []string{"lint", "test"}, FailingSince: now.AddDate(0, 0, -20)}, want: actionWarn, failing: "[lint test]"},
+		{name: "failing recently", failing: "[]", checks: &checkStatus{Failing: []string{"test"}, FailingSince: now.AddDate(0, 0, -4)}, want: actionActive, staleAt: now.AddDate(0, 0, 10)},
+		{name: "passing or neutral", failing: "[]", checks: &checkStatus{}, want: actionActive, staleAt: now.AddDate(0, 0, 28)},
+		{name: "unknown", failing: "[]", want: actionActive, staleAt: now.AddDate(0, 0, 28)},
+	} {
+		d := evaluatePR(testPR("alice", now.AddDate(0, 0, -2)), prSignals{Checks: tc.checks}, rules, now)
+		if d.Action != tc.want || fmt.Sprint(d.FailingChecks) != tc.failing {
+			t.Errorf("%s: decided %s with failing checks %v, want %s with %s; trace:\n%v", tc.name, d.Action, d.FailingChecks, tc.want, tc.failing, d.Trace)
+		}
+		if !tc.staleAt.IsZero() && !d.StaleAt.Equal(tc.staleAt) {
+			t.Errorf("%s: stale at %v, want %v", tc.name, d.StaleAt, tc.staleAt)
+		}
+	}
+
+	// Without the knob failing checks do not matter.
+	rules.FailingChecksStaleAfter = 0
+	checks := &checkStatus{Failing: []string{"test"}, FailingSince: now.AddDate(0, 0, -60)}
+	if d := evaluatePR(testPR("alice", now.AddDate(0, 0, -2)), prSignals{Checks: checks}, rules, now); d.Action != actionActive {
+		t.Errorf("without --failing-checks-stale-after decided %s, want active", d.Action)
+	}
+}
+
+// TestFailingChecksWarning warns the author of a PR with long-failing checks
+// and names the checks in the warning.
+func TestFailingChecksWarning(t *testing.T) {
+	gh := &fakeGitHub{get: map[string]string{
+		"/repos/acme/api/commits/aaa/check-runs": `{"total_count":1,"check_runs":[{"name":"test","conclusion":"failure","completed_at":"2026-01-01T10:00:00Z"}]}`,
+		"/repos/acme/api/commits/aaa/status":     `{"state":"success","statuses":[]}`,
+	}}
+	cfg := &config{Rules: testRules(), DisplayLocation: time.UTC, NotifyVia: notifyComment}
+	cfg.Rules.FailingChecksStaleAfter = 14 * 24 * time.Hour
+	s := newTestRepoScanner(t, gh, cfg)
+	pr := testPR("alice", time.Now().Add(-time.Hour))
+	pr.Head = &github.PullRequestBranch{SHA: github.Ptr("aaa")}
+	if d := s.actOn(pr); d.Action != actionWarn {
+		t.Fatalf("decided %s, want warn; trace:\n%v", d.Action, d.Trace)
+	}
+	var comment string
+	for _, w := range gh.written() {
+		if strings.HasPrefix(w, "POST /repos/acme/api/issues/42/comments ") {
+			comment = w
+		}
+	}
+	if !strings.Contains(comment, "have been failing without a fix") || !strings.Contains(comment, `  - test`) {
+		t.Errorf("warning comment %q, want the failing checks warning naming 'test'", comment)
+	}
+}
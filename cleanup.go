@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// getLabeledIssues returns the open issues and PRs of a repository carrying
+// a label. The issues API filters by label server-side, so the cleanup pass
+// only lists the few warned PRs rather than every open one.
+func getLabeledIssues(client *github.Client, owner, repo, label string) ([]*github.Issue, error) {
+	ctx := context.Background()
+	opt := &github.IssueListByRepoOptions{State: "open", Labels: []string{label}, ListOptions: github.ListOptions{PerPage: 100}}
+	var labeled []*github.Issue
+	for {
+		page, resp, err := client.Issues.ListByRepo(ctx, owner, repo, opt)
+		if err != nil {
+			return nil, fmt.Errorf("error listing issues labeled '%s': %v", label, err)
+		}
+		labeled = append(labeled, page...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return labeled, nil
+}
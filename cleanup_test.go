@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// newTestGitHubClient returns a GitHub client for an httptest server serving
+// handler.
+func newTestGitHubClient(t *testing.T, handler http.Handler) (*github.Client, *httptest.Server) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+	return client, srv
+}
+
+func TestGetLabeledIssues(t *testing.T) {
+	var srv *httptest.Server
+	client, srv := newTestGitHubClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/acme/api/issues" {
+			http.NotFound(w, r)
+			return
+		}
+		q := r.URL.Query()
+		if q.Get("state") != "open" || q.Get("labels") != "stale-warning" {
+			t.Errorf("listed issues with %s, want the open ones labeled stale-warning", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if q.Get("page") != "2" {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/repos/acme/api/issues?state=open&labels=stale-warning&page=2>; rel="next"`, srv.URL))
+			fmt.Fprint(w, `[{"number":7,"pull_request":{"url":"x"}},{"number":8}]`)
+			return
+		}
+		fmt.Fprint(w, `[{"number":9,"pull_request":{"url":"y"}}]`)
+	}))
+
+	issues, err := getLabeledIssues(client, "acme", "api", "stale-warning")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, issue := range issues {
+		got = append(got, fmt.Sprintf("#%d pr=%v", issue.GetNumber(), issue.IsPullRequest()))
+	}
+	if want := "[#7 pr=true #8 pr=false #9 pr=true]"; fmt.Sprint(got) != want {
+		t.Errorf("labeled issues %v, want %s", got, want)
+	}
+
+	if _, err := getLabeledIssues(client, "acme", "web", "stale-warning"); err == nil {
+		t.Error("getLabeledIssues succeeded though the issues could not be listed")
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// Why a stale PR is closed, which selects the variant of the closure
+// message.
+const (
+	closeNeverReviewed      = "never-reviewed"
+	closeAuthorUnresponsive = "author-unresponsive"
+	closeDiscussionQuiet    = "discussion-quiet"
+)
+
+// closeReasons lists the close reasons, each of which has closure template
+// variants.
+var closeReasons = []string{closeNeverReviewed, closeAuthorUnresponsive, closeDiscussionQuiet}
+
+// closeReasonText describes a close reason for the decision trace.
+func closeReasonText(reason string) string {
+	switch reason {
+	case closeNeverReviewed:
+		return "it never received a review or comment"
+	case closeAuthorUnresponsive:
+		return "it was reviewed but its author did not respond"
+	case closeDiscussionQuiet:
+		return "its discussion went quiet after the author's last response"
+	}
+	return reason
+}
+
+// closeReasonOf classifies a PR from its timeline: never reviewed when nobody
+// but its author commented on or reviewed it, author unresponsive when the
+// latest review or comment by someone else is newer than the author's latest
+// comment or push, and discussion quiet otherwise. The bot and other bots do
+// not count.
+func closeReasonOf(pr *github.PullRequest, events []*github.Timeline, botLogin string) string {
+	author := pr.GetUser().GetLogin()
+	var feedback, response time.Time
+	for _, ev := range events {
+		actor := timelineActor(ev)
+		if strings.HasSuffix(strings.ToLower(actor), "[bot]") || (botLogin != "" && strings.EqualFold(actor, botLogin)) {
+			continue
+		}
+		var at time.Time
+		switch ev.GetEvent() {
+		case "commented":
+			at = ev.GetCreatedAt().Time
+		case "reviewed":
+			at = ev.GetSubmittedAt().Time
+		case "committed":
+			// Commit events carry no login; the PR's commits are taken to
+			// be its author's.
+			at, actor = ev.GetCommitter().GetDate().Time, author
+		case "head_ref_force_pushed":
+			if !strings.EqualFold(actor, author) {
+				continue
+			}
+			at = ev.GetCreatedAt().Time
+		default:
+			continue
+		}
+		switch {
+		case strings.EqualFold(actor, author):
+			if at.After(response) {
+				response = at
+			}
+		case at.After(feedback):
+			feedback = at
+		}
+	}
+	switch {
+	case feedback.IsZero():
+		return closeNeverReviewed
+	case feedback.After(response):
+		return closeAuthorUnresponsive
+	}
+	return closeDiscussionQuiet
+}
+
+// closureVariant returns the name of the variant of a closure template for a
+// close reason.
+func closureVariant(name, reason string) string {
+	return name + " (" + reason + ")"
+}
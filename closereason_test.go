@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+func TestCloseReasonOf(t *testing.T) {
+	day := func(n int) time.Time { return time.Date(2026, 3, n, 9, 0, 0, 0, time.UTC) }
+	review := func(actor string, at time.Time) *github.Timeline {
+		ev := timelineEvent("reviewed", actor, time.Time{})
+		ev.SubmittedAt = &github.Timestamp{Time: at}
+		return ev
+	}
+	commit := func(at time.Time) *github.Timeline {
+		return &github.Timeline{Event: github.Ptr("committed"), Committer: &github.CommitAuthor{Date: &github.Timestamp{Time: at}}}
+	}
+	for _, tc := range []struct {
+		name   string
+		events []*github.Timeline
+		want   string
+	}{
+		{name: "no events", want: closeNeverReviewed},
+		{name: "only the author", events: []*github.Timeline{commit(day(1)), timelineEvent("commented", "alice", day(2))}, want: closeNeverReviewed},
+		{name: "only bots", events: []*github.Timeline{timelineEvent("commented", "ci[bot]", day(2)), timelineEvent("commented", "stale-bot", day(3))}, want: closeNeverReviewed},
+		{name: "review unanswered", events: []*github.Timeline{commit(day(1)), review("bob", day(2))}, want: closeAuthorUnresponsive},
+		{name: "comment unanswered", events: []*github.Timeline{timelineEvent("commented", "alice", day(1)), timelineEvent("commented", "bob", day(2))}, want: closeAuthorUnresponsive},
+		{name: "answered by a comment", events: []*github.Timeline{review("bob", day(1)), timelineEvent("commented", "Alice", day(2))}, want: closeDiscussionQuiet},
+		{name: "answered by a commit", events: []*github.Timeline{review("bob", day(1)), commit(day(2))}, want: closeDiscussionQuiet},
+		{name: "answered by a force-push", events: []*github.Timeline{review("bob", day(1)), timelineEvent("head_ref_force_pushed", "alice", day(2))}, want: closeDiscussionQuiet},
+		{name: "force-push by someone else", events: []*github.Timeline{timelineEvent("commented", "alice", day(1)), review("bob", day(2)), timelineEvent("head_ref_force_pushed", "bob", day(3))}, want: closeAuthorUnresponsive},
+		{name: "other events", events: []*github.Timeline{review("bob", day(1)), commit(day(2)), timelineEvent("labeled", "bob", day(3))}, want: closeDiscussionQuiet},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := closeReasonOf(testPR("alice", day(1)), tc.events, "stale-bot"); got != tc.want {
+				t.Errorf("closeReasonOf = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRenderClosureVariant(t *testing.T) {
+	r := newTemplateRenderer(&config{DisplayLocation: time.UTC})
+	r.overrides = map[string]string{closureVariant("close comment", closeNeverReviewed): "Sorry nobody reviewed this, @{{.Login}}."}
+	for _, tc := range []struct {
+		reason, want string
+	}{
+		{closeNeverReviewed, "Sorry nobody reviewed this, @alice."},
+		{closeAuthorUnresponsive, "Closed, @alice."},
+		{"", "Closed, @alice."},
+	} {
+		got, err := r.renderClosure("close comment", "Closed, @{{.Login}}.", notificationData{Login: "alice", CloseReason: tc.reason})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("the closure for %q reads %q, want %q", tc.reason, got, tc.want)
+		}
+	}
+}
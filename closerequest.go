@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// closeRequest is a maintainer's request, made by applying the close-now
+// label, to close a PR on the next run.
+type closeRequest struct {
+	Label string
+	// By is who applied the label, if the PR's events say.
+	By string
+	// Allowed is set if By can push to the repository. Requests by others
+	// are ignored.
+	Allowed bool
+}
+
+// lastLabeler returns who most recently added label, or "" if the events do
+// not say.
+func lastLabeler(events []*github.IssueEvent, label string) string {
+	by := ""
+	var last github.Timestamp
+	for _, ev := range events {
+		if ev.GetEvent() == "labeled" && strings.EqualFold(ev.GetLabel().GetName(), label) && !ev.GetCreatedAt().Before(last.Time) {
+			last = ev.GetCreatedAt()
+			by = ev.GetActor().GetLogin()
+		}
+	}
+	return by
+}
+
+// hasPushAccess reports whether login can push to the repository.
+func hasPushAccess(client *github.Client, owner, repo, login string) (bool, error) {
+	level, _, err := client.Repositories.GetPermissionLevel(context.Background(), owner, repo, login)
+	if err != nil {
+		return false, fmt.Errorf("failed to get the permission of %s: %v", login, err)
+	}
+	switch level.GetPermission() {
+	case "admin", "write":
+		return true, nil
+	}
+	return false, nil
+}
+
+const closeRequestCommentTemplate = `This {{.Kind}} was closed at the request of @{{.RequestedBy}}.
+`
+
+// closeRequestData is the data passed to the close request comment template.
+type closeRequestData struct {
+	notificationData
+	RequestedBy string
+}
+
+// closeOnRequest closes a PR at a maintainer's request: it posts a comment,
+// closes the PR and removes the label the request was made with.
+func closeOnRequest(client *github.Client, tmpl *templateRenderer, owner, repo string, number int, data closeRequestData, label string) error {
+	comment, err := tmpl.render("close request comment", closeRequestCommentTemplate, data)
+	if err != nil {
+		return err
+	}
+	if err := postComment(client, owner, repo, number, comment); err != nil {
+		return fmt.Errorf("failed to post closing comment: %v", err)
+	}
+	if err := closePR(client, owner, repo, number); err != nil {
+		return err
+	}
+	if err := removeLabel(client, owner, repo, number, label); err != nil {
+		return fmt.Errorf("closed, but failed to remove the '%s' label: %v", label, err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+func TestLastLabeler(t *testing.T) {
+	day := func(n int) time.Time { return time.Date(2026, 3, n, 9, 0, 0, 0, time.UTC) }
+	labeled := func(label, by string, at time.Time) *github.IssueEvent {
+		ev := labelEvent("labeled", label, at)
+		ev.Actor = &github.User{Login: github.Ptr(by)}
+		return ev
+	}
+	for _, tc := range []struct {
+		name   string
+		events []*github.IssueEvent
+		want   string
+	}{
+		{name: "no events"},
+		{name: "labeled", events: []*github.IssueEvent{labeled("close-now", "bob", day(1))}, want: "bob"},
+		{name: "label ignores case", events: []*github.IssueEvent{labeled("Close-Now", "bob", day(1))}, want: "bob"},
+		{name: "relabeled", events: []*github.IssueEvent{labeled("close-now", "bob", day(1)), labeled("close-now", "carol", day(2))}, want: "carol"},
+		{name: "out of order", events: []*github.IssueEvent{labeled("close-now", "carol", day(2)), labeled("close-now", "bob", day(1))}, want: "carol"},
+		{name: "other labels", events: []*github.IssueEvent{labeled("close-now", "bob", day(1)), labeled("wip", "carol", day(2))}, want: "bob"},
+		{name: "unlabeled", events: []*github.IssueEvent{labelEvent("unlabeled", "close-now", day(1))}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := lastLabeler(tc.events, "close-now"); got != tc.want {
+				t.Errorf("lastLabeler = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHasPushAccess(t *testing.T) {
+	client, _ := newTestGitHubClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		login := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/repos/acme/api/collaborators/"), "/permission")
+		permission := map[string]string{"owner": "admin", "maintainer": "write", "triager": "read", "passer-by": "none"}[login]
+		if permission == "" {
+			http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"permission":%q}`, permission)
+	}))
+	for login, want := range map[string]bool{"owner": true, "maintainer": true, "triager": false, "passer-by": false} {
+		got, err := hasPushAccess(client, "acme", "api", login)
+		if err != nil || got != want {
+			t.Errorf("hasPushAccess(%s) = %v, %v; want %v", login, got, err, want)
+		}
+	}
+	if _, err := hasPushAccess(client, "acme", "api", "ghost"); err == nil {
+		t.Error("hasPushAccess succeeded though the permission could not be read")
+	}
+}
+
+func TestCloseOnRequest(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	client, _ := newTestGitHubClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, strings.TrimSpace(fmt.Sprintf("%s %s %s", r.Method, r.URL.Path, body)))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, "{}")
+	}))
+	data := closeRequestData{notificationData: notificationData{Kind: "pull request"}, RequestedBy: "maintainer"}
+	r := newTemplateRenderer(&config{DisplayLocation: time.UTC})
+	if err := closeOnRequest(client, r, "acme", "api", 7, data, "close-now"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`POST /repos/acme/api/issues/7/comments {"body":"This pull request was closed at the request of @maintainer.\n"}`,
+		`PATCH /repos/acme/api/issues/7 {"state":"closed"}`,
+		`DELETE /repos/acme/api/issues/7/labels/close-now`,
+	}
+	if strings.Join(requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests:\n%s\nwant:\n%s", strings.Join(requests, "\n"), strings.Join(want, "\n"))
+	}
+}
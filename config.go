@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// config holds the settings shared by the bot's components. It is built once
+// from the command line and handed to constructors explicitly: the bot keeps
+// no mutable package-level state, so components can safely be used for
+// several repositories at once.
+type config struct {
+	// FallbackEmailDomain is used to guess the address of users without a
+	// public or commit email, unless NoEmailGuess is set.
+	FallbackEmailDomain string
+	NoEmailGuess        bool
+	// EmailMap lists the addresses of users, from --email-map.
+	EmailMap *emailMap
+	// DisplayLocation is the default timezone dates are rendered in.
+	DisplayLocation *time.Location
+	// Timezones maps logins to the timezone used in their notifications.
+	Timezones userTimezones
+	// Rules drive the decision taken for each PR.
+	Rules evaluationRules
+
+	SMTPServer   string
+	SMTPPort     int
+	SMTPUser     string
+	SMTPPassword string
+	// SMTPFrom is the sender address, if other than SMTPUser.
+	SMTPFrom string
+	// NotifyVia is how PR authors are notified: notifyEmail, notifyComment,
+	// notifyBoth, notifySlack or notifyTeams.
+	NotifyVia string
+	// SlackWebhookURL is the incoming webhook notifySlack posts to, and
+	// SlackChannel the channel it overrides the webhook's with, if set.
+	SlackWebhookURL string
+	SlackChannel    string
+	// TeamsWebhookURL is the webhook notifyTeams posts to.
+	TeamsWebhookURL string
+	// NotifyPrefs overrides NotifyVia per author.
+	NotifyPrefs notificationPrefs
+	// GreetingName is the name notifications greet their recipient by:
+	// greetDisplayName or greetLogin.
+	GreetingName string
+}
+
+// location returns the timezone to render dates in for login.
+func (c *config) location(login string) *time.Location {
+	if loc, ok := c.Timezones[strings.ToLower(login)]; ok {
+		return loc
+	}
+	return c.DisplayLocation
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig is the --config YAML file. Secrets are not stored in the file:
+// it names the environment variables holding them instead. Its JSON Schema
+// is generated from it, with the values a field allows in its enum tag.
+type fileConfig struct {
+	GitHub struct {
+		TokenEnv string `yaml:"token_env,omitempty"`
+		BaseURL  string `yaml:"base_url,omitempty"`
+	} `yaml:"github,omitempty"`
+	Owner         string   `yaml:"owner,omitempty"`
+	Repos         []string `yaml:"repos,omitempty"`
+	DaysInactive  int      `yaml:"days_inactive,omitempty"`
+	WarningPeriod int      `yaml:"warning_period,omitempty"`
+	EmailDomain   string   `yaml:"email_domain,omitempty"`
+	EmailMap      string   `yaml:"email_map,omitempty"`
+	IncludeIssues bool     `yaml:"include_issues,omitempty"`
+	NotifyVia     string   `yaml:"notify_via,omitempty" enum:"email,comment,both,slack,teams"`
+	TemplateDir   string   `yaml:"template_dir,omitempty"`
+	SMTP          struct {
+		Server      string `yaml:"server,omitempty"`
+		Port        int    `yaml:"port,omitempty"`
+		User        string `yaml:"user,omitempty"`
+		PasswordEnv string `yaml:"password_env,omitempty"`
+		Encryption  string `yaml:"encryption,omitempty" enum:"starttls,tls,none"`
+		From        string `yaml:"from,omitempty"`
+	} `yaml:"smtp,omitempty"`
+	Labels struct {
+		Stale  string   `yaml:"stale,omitempty"`
+		Exempt []string `yaml:"exempt,omitempty"`
+	} `yaml:"labels,omitempty"`
+	Slack struct {
+		WebhookURLEnv string `yaml:"webhook_url_env,omitempty"`
+		Channel       string `yaml:"channel,omitempty"`
+	} `yaml:"slack,omitempty"`
+	Teams struct {
+		WebhookURLEnv string `yaml:"webhook_url_env,omitempty"`
+	} `yaml:"teams,omitempty"`
+	// RuleOrder reorders the decision rules, as --rule-order does.
+	RuleOrder []string `yaml:"rule_order,omitempty"`
+	// NotifyOnUnstale thanks the authors of PRs that became active again,
+	// as --notify-on-unstale does.
+	NotifyOnUnstale bool `yaml:"notify_on_unstale,omitempty"`
+}
+
+// configSetting is a value from the config file for a flag.
+type configSetting struct {
+	Key   string
+	Flag  string
+	Env   string
+	Value string
+	// Secret settings hold the name of the environment variable to read
+	// the value from.
+	Secret bool
+}
+
+// loadConfigFile reads a config file. Keys it does not know are reported as
+// warnings rather than errors, so that a typo is noticed.
+func loadConfigFile(path string) (*fileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	cfg := &fileConfig{}
+	if len(root.Content) == 0 {
+		return cfg, nil
+	}
+	for _, key := range unknownConfigKeys(root.Content[0], reflect.TypeOf(*cfg), "") {
+		slog.Warn("unknown config file key, ignoring it", "file", path, "key", key)
+	}
+	if err := root.Content[0].Decode(cfg); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// unknownConfigKeys returns the keys of a YAML mapping, and of the mappings
+// nested in it, that have no field in the struct type t.
+func unknownConfigKeys(node *yaml.Node, t reflect.Type, prefix string) []string {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if node.Kind != yaml.MappingNode || t.Kind() != reflect.Struct {
+		return nil
+	}
+	fields := map[string]reflect.Type{}
+	configFields(t, fields)
+	var unknown []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i].Value
+		ft, ok := fields[key]
+		if !ok {
+			unknown = append(unknown, prefix+key)
+			continue
+		}
+		unknown = append(unknown, unknownConfigKeys(node.Content[i+1], ft, prefix+key+".")...)
+	}
+	return unknown
+}
+
+// configFields maps the YAML keys of the struct type t, including those of
+// its inlined structs, to their types.
+func configFields(t reflect.Type, fields map[string]reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		name, opts, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if opts == "inline" {
+			configFields(t.Field(i).Type, fields)
+			continue
+		}
+		fields[name] = t.Field(i).Type
+	}
+}
+
+// settings returns the flag values the file sets.
+func (c *fileConfig) settings() []configSetting {
+	var s []configSetting
+	add := func(key, flagName, env, value string) {
+		if value != "" && value != "0" {
+			s = append(s, configSetting{Key: key, Flag: flagName, Env: env, Value: value})
+		}
+	}
+	secret := func(key, flagName, env, name string) {
+		if name != "" {
+			s = append(s, configSetting{Key: key, Flag: flagName, Env: env, Value: name, Secret: true})
+		}
+	}
+
+	secret("github.token_env", "github-token", "GITHUB_TOKEN", c.GitHub.TokenEnv)
+	add("github.base_url", "github-base-url", "GITHUB_BASE_URL", c.GitHub.BaseURL)
+	add("owner", "owner", "GITHUB_OWNER", c.Owner)
+	add("repos", "repo", "GITHUB_REPO", strings.Join(c.Repos, ","))
+	add("days_inactive", "days-inactive", "DAYS_INACTIVE", strconv.Itoa(c.DaysInactive))
+	add("warning_period", "warning-period", "WARNING_PERIOD", strconv.Itoa(c.WarningPeriod))
+	add("email_domain", "email-domain", "EMAIL_DOMAIN", c.EmailDomain)
+	add("email_map", "email-map", "EMAIL_MAP", c.EmailMap)
+	if c.IncludeIssues {
+		add("include_issues", "include-issues", "INCLUDE_ISSUES", "true")
+	}
+	add("notify_via", "notify-via", "NOTIFY_VIA", c.NotifyVia)
+	add("template_dir", "template-dir", "TEMPLATE_DIR", c.TemplateDir)
+	if c.NotifyOnUnstale {
+		add("notify_on_unstale", "notify-on-unstale", "NOTIFY_ON_UNSTALE", "true")
+	}
+	add("smtp.server", "smtp-server", "SMTP_SERVER", c.SMTP.Server)
+	add("smtp.port", "smtp-port", "SMTP_PORT", strconv.Itoa(c.SMTP.Port))
+	add("smtp.user", "smtp-user", "SMTP_USER", c.SMTP.User)
+	secret("smtp.password_env", "smtp-password", "SMTP_PASSWORD", c.SMTP.PasswordEnv)
+	add("smtp.from", "smtp-from", "SMTP_FROM", c.SMTP.From)
+	add("smtp.encryption", "smtp-encryption", "SMTP_ENCRYPTION", c.SMTP.Encryption)
+	add("labels.stale", "stale-label", "STALE_LABEL", c.Labels.Stale)
+	add("labels.exempt", "exempt-labels", "EXEMPT_LABELS", strings.Join(c.Labels.Exempt, ","))
+	secret("slack.webhook_url_env", "slack-webhook-url", "SLACK_WEBHOOK_URL", c.Slack.WebhookURLEnv)
+	add("slack.channel", "slack-channel", "SLACK_CHANNEL", c.Slack.Channel)
+	secret("teams.webhook_url_env", "teams-webhook-url", "TEAMS_WEBHOOK_URL", c.Teams.WebhookURLEnv)
+	add("rule_order", "rule-order", "RULE_ORDER", strings.Join(c.RuleOrder, ","))
+	return s
+}
+
+// requiredSetting is a setting the bot cannot run without.
+type requiredSetting struct {
+	Key  string
+	Flag string
+	Env  string
+	OK   bool
+}
+
+// missingSettings describes the required settings that are missing or
+// invalid, naming their config file key, flag and environment variable.
+func missingSettings(required []requiredSetting) []string {
+	var missing []string
+	for _, r := range required {
+		if !r.OK {
+			missing = append(missing, fmt.Sprintf("%s (--%s, %s)", r.Key, r.Flag, r.Env))
+		}
+	}
+	return missing
+}
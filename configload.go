@@ -0,0 +1,254 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/joho/godotenv"
+)
+
+// Sources a setting's effective value can come from, in order of precedence.
+const (
+	sourceFlag       = "flag"
+	sourceEnv        = "environment"
+	sourceDotenv     = ".env"
+	sourceConfigFile = "config file"
+	sourceDefault    = "default"
+)
+
+// flagEnvNames lists the flags whose environment variable is not their name
+// upper-cased with dashes turned into underscores. An empty name means the
+// flag has no environment variable.
+var flagEnvNames = map[string]string{
+	"config":        "CONFIG_FILE",
+	"print-config":  "",
+	"owner":         "GITHUB_OWNER",
+	"repo":          "GITHUB_REPO",
+	"explain":       "",
+	"age":           "",
+	"labels":        "",
+	"draft":         "",
+	"author":        "",
+	"last-activity": "",
+	// The deprecated alias shares its value with
+	// --default-display-timezone, which reads TIMEZONE after its own
+	// variable.
+	"timezone": "",
+}
+
+// legacyFlagEnvNames lists the variables flags fall back to when their own is
+// unset, for settings whose variable was renamed.
+var legacyFlagEnvNames = map[string]string{
+	"default-display-timezone": "TIMEZONE",
+}
+
+// flagEnvName returns the environment variable a flag defaults to, or "".
+func flagEnvName(name string) string {
+	if env, ok := flagEnvNames[name]; ok {
+		return env
+	}
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// flagEnv returns the environment variable a flag is set from and its value,
+// or "" if neither it nor a legacy one is set.
+func flagEnv(name string) (env, value string) {
+	for _, env := range []string{flagEnvName(name), legacyFlagEnvNames[name]} {
+		if env == "" {
+			continue
+		}
+		if value := os.Getenv(env); value != "" {
+			return env, value
+		}
+	}
+	return "", ""
+}
+
+// configLoader resolves the bot's settings with a fixed precedence: a flag
+// given on the command line, then the environment, then the .env file, then
+// the config file, then the built-in default. The flags are defined with
+// their built-in defaults; the .env file is loaded into the environment
+// before parsing, the environment is applied when parsing, and the config
+// file after it.
+//
+// A flag is tracked by its value, so that setting an alias sets the flag it
+// aliases too.
+type configLoader struct {
+	fs *flag.FlagSet
+	// given holds the values of the flags set on the command line.
+	given map[flag.Value]bool
+	// env maps the values of the flags set from the environment to the
+	// variable they were read from.
+	env map[flag.Value]string
+	// dotenv holds the variables set from the .env file.
+	dotenv map[string]bool
+	// file maps the values of the flags set from the config file to their
+	// key in it.
+	file map[flag.Value]string
+}
+
+func newConfigLoader(fs *flag.FlagSet) *configLoader {
+	return &configLoader{fs: fs, given: map[flag.Value]bool{}, env: map[flag.Value]string{}, dotenv: map[string]bool{}, file: map[flag.Value]string{}}
+}
+
+// dotenvOptions finds --dotenv-path and --no-dotenv in the command line, which
+// must be known before the flags are defined, falling back to DOTENV_PATH and
+// NO_DOTENV.
+func dotenvOptions(args []string) (path string, disabled bool) {
+	path = os.Getenv("DOTENV_PATH")
+	disabled, _ = strconv.ParseBool(os.Getenv("NO_DOTENV"))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		switch name {
+		case "no-dotenv":
+			disabled = !hasValue || value == "true" || value == "1"
+		case "dotenv-path":
+			if !hasValue && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			path = value
+		}
+	}
+	return path, disabled
+}
+
+// loadDotenv sets the variables of a .env file that are not already set in
+// the environment. A missing default .env file is not an error; a missing
+// explicit path is.
+func (l *configLoader) loadDotenv(path string) error {
+	explicit := path != ""
+	if !explicit {
+		path = ".env"
+	}
+	vars, err := godotenv.Read(path)
+	if err != nil {
+		if !explicit && os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	for k, v := range vars {
+		if os.Getenv(k) != "" {
+			continue
+		}
+		if err := os.Setenv(k, v); err != nil {
+			return fmt.Errorf("failed to set %s from %s: %v", k, path, err)
+		}
+		l.dotenv[k] = true
+	}
+	return nil
+}
+
+// parse parses the command line, records the flags it sets, and sets the
+// others from their environment variables. An invalid value in the
+// environment is an error, as it is on the command line.
+func (l *configLoader) parse(args []string) error {
+	if err := l.fs.Parse(args); err != nil {
+		return err
+	}
+	l.fs.Visit(func(f *flag.Flag) { l.given[f.Value] = true })
+	var err error
+	l.fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || l.given[f.Value] || l.env[f.Value] != "" {
+			return
+		}
+		env, value := flagEnv(f.Name)
+		if env == "" {
+			return
+		}
+		if setErr := f.Value.Set(value); setErr != nil {
+			err = fmt.Errorf("invalid value %q for %s (--%s): %v", value, env, f.Name, setErr)
+			return
+		}
+		l.env[f.Value] = env
+	})
+	return err
+}
+
+// applyFile sets the flags the config file sets, unless a source with higher
+// precedence already did. Secrets are read from the environment variables
+// the file names; an unset one is an error.
+func (l *configLoader) applyFile(c *fileConfig) error {
+	for _, s := range c.settings() {
+		f := l.fs.Lookup(s.Flag)
+		if f == nil {
+			return fmt.Errorf("%s: no flag --%s", s.Key, s.Flag)
+		}
+		if l.given[f.Value] || l.env[f.Value] != "" {
+			continue
+		}
+		value := s.Value
+		if s.Secret {
+			if value = os.Getenv(s.Value); value == "" {
+				return fmt.Errorf("%s: environment variable %s is not set", s.Key, s.Value)
+			}
+		}
+		if err := l.fs.Set(s.Flag, value); err != nil {
+			return fmt.Errorf("invalid value for %s: %v", s.Key, err)
+		}
+		l.file[f.Value] = s.Key
+	}
+	return nil
+}
+
+// source returns where the effective value of a flag came from, with the
+// environment variable or config file key it was read from.
+func (l *configLoader) source(name string) string {
+	value := l.fs.Lookup(name).Value
+	if l.given[value] {
+		return sourceFlag
+	}
+	if env := l.env[value]; env != "" {
+		if l.dotenv[env] {
+			return fmt.Sprintf("%s (%s)", sourceDotenv, env)
+		}
+		return fmt.Sprintf("%s (%s)", sourceEnv, env)
+	}
+	if key, ok := l.file[value]; ok {
+		return fmt.Sprintf("%s (%s)", sourceConfigFile, key)
+	}
+	return sourceDefault
+}
+
+// isSecretFlag reports whether a flag holds a credential that must not be
+// printed.
+func isSecretFlag(name string) bool {
+	for _, s := range []string{"token", "password", "secret", "webhook-url"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// printEffectiveConfig prints every setting with its effective value and the
+// source it came from. Secrets are redacted.
+func (l *configLoader) printEffectiveConfig(w io.Writer) error {
+	var names []string
+	l.fs.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+	sort.Strings(names)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	writeTabRow(tw, []string{"SETTING", "VALUE", "SOURCE"})
+	for _, name := range names {
+		value := l.fs.Lookup(name).Value.String()
+		if isSecretFlag(name) && value != "" {
+			value = "(redacted)"
+		}
+		writeTabRow(tw, []string{name, fmt.Sprintf("%q", value), l.source(name)})
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fullConfigFile sets every config file key. Secrets name the TEST_*
+// variables, which the tests set.
+const fullConfigFile = `github:
+  token_env: TEST_GITHUB_TOKEN
+  base_url: https://github.example.com/api/v3/
+owner: file-owner
+repos: [file-repo, other-repo]
+days_inactive: 30
+warning_period: 7
+email_domain: file.example.com
+email_map: file-emails.yaml
+include_issues: true
+notify_via: comment
+template_dir: file-templates
+smtp:
+  server: smtp.file.example.com
+  port: 2525
+  user: file-user
+  password_env: TEST_SMTP_PASSWORD
+  encryption: tls
+  from: bot@file.example.com
+labels:
+  stale: file-stale
+  exempt: [file-pinned, file-keep]
+slack:
+  webhook_url_env: TEST_SLACK_WEBHOOK_URL
+  channel: "#file"
+teams:
+  webhook_url_env: TEST_TEAMS_WEBHOOK_URL
+rule_order: [draft, exempt-label]
+notify_on_unstale: true
+`
+
+// clearFlagEnv unsets the environment variables of every flag for the
+// duration of the test.
+func clearFlagEnv(t *testing.T) {
+	t.Helper()
+	fs := flag.NewFlagSet("env", flag.ContinueOnError)
+	defineFlags(fs)
+	fs.VisitAll(func(f *flag.Flag) {
+		if env := flagEnvName(f.Name); env != "" {
+			t.Setenv(env, "")
+		}
+	})
+	for _, env := range legacyFlagEnvNames {
+		t.Setenv(env, "")
+	}
+}
+
+// newTestFlagSet defines the flags on a flag set that returns parse errors
+// instead of exiting.
+func newTestFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("stale-pr-bot", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	defineFlags(fs)
+	return fs
+}
+
+// otherValue returns a value for a flag that differs from its default.
+func otherValue(t *testing.T, f *flag.Flag) string {
+	t.Helper()
+	switch def := f.Value.(flag.Getter).Get().(type) {
+	case bool:
+		return strconv.FormatBool(!def)
+	case int:
+		return strconv.Itoa(def + 7)
+	case float64:
+		return strconv.FormatFloat(def/2, 'g', -1, 64)
+	case time.Duration:
+		return (def + 7*time.Hour).String()
+	case string:
+		return def + "-from-env"
+	default:
+		t.Fatalf("--%s: unexpected flag type %T", f.Name, def)
+		return ""
+	}
+}
+
+func TestFlagsReadTheirEnvironmentVariable(t *testing.T) {
+	clearFlagEnv(t)
+	newTestFlagSet().VisitAll(func(f *flag.Flag) {
+		env := flagEnvName(f.Name)
+		if env == "" {
+			return
+		}
+		t.Run(f.Name, func(t *testing.T) {
+			value := otherValue(t, f)
+			t.Setenv(env, value)
+			fs := newTestFlagSet()
+			loader := newConfigLoader(fs)
+			if err := loader.parse(nil); err != nil {
+				t.Fatal(err)
+			}
+			want := fs.Lookup(f.Name)
+			if err := want.Value.Set(value); err != nil {
+				t.Fatalf("setting --%s to %q: %v", f.Name, value, err)
+			}
+			got := fs.Lookup(f.Name)
+			if got.DefValue != f.DefValue {
+				t.Errorf("with %s=%q the default of --%s is %q, want the built-in %q", env, value, f.Name, got.DefValue, f.DefValue)
+			}
+			if got.Value.String() != want.Value.String() {
+				t.Errorf("with %s=%q --%s = %q, want %q", env, value, f.Name, got.Value.String(), want.Value.String())
+			}
+			if src, want := loader.source(f.Name), fmt.Sprintf("%s (%s)", sourceEnv, env); src != want {
+				t.Errorf("source of --%s = %q, want %q", f.Name, src, want)
+			}
+		})
+	})
+}
+
+func TestInvalidEnvironmentValues(t *testing.T) {
+	clearFlagEnv(t)
+	newTestFlagSet().VisitAll(func(f *flag.Flag) {
+		env := flagEnvName(f.Name)
+		if _, isString := f.Value.(flag.Getter).Get().(string); env == "" || isString {
+			return
+		}
+		t.Run(f.Name, func(t *testing.T) {
+			t.Setenv(env, "abc")
+			err := newConfigLoader(newTestFlagSet()).parse(nil)
+			if want := fmt.Sprintf(`invalid value "abc" for %s (--%s)`, env, f.Name); err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("parse returned %v, want an error containing %q", err, want)
+			}
+		})
+	})
+
+	// A flag given on the command line is not read from the environment,
+	// so its variable may hold anything.
+	t.Setenv("DAYS_INACTIVE", "abc")
+	fs := newTestFlagSet()
+	loader := newConfigLoader(fs)
+	if err := loader.parse([]string{"--days-inactive=30"}); err != nil {
+		t.Errorf("parse with --days-inactive given returned %v", err)
+	}
+}
+
+func TestInvalidEnvironmentValueBeatsConfigFile(t *testing.T) {
+	clearFlagEnv(t)
+	t.Setenv("DAYS_INACTIVE", "abc")
+	fileCfg, err := loadConfigFile(writeTestFile(t, "config.yaml", "days_inactive: 30\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := newTestFlagSet()
+	loader := newConfigLoader(fs)
+	if err := loader.parse(nil); err == nil || !strings.Contains(err.Error(), "DAYS_INACTIVE") {
+		t.Fatalf("parse returned %v, want DAYS_INACTIVE rejected", err)
+	}
+
+	// An empty variable is unset: the config file applies.
+	t.Setenv("DAYS_INACTIVE", "")
+	fs = newTestFlagSet()
+	loader = newConfigLoader(fs)
+	if err := loader.parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := loader.applyFile(fileCfg); err != nil {
+		t.Fatal(err)
+	}
+	if got, src := fs.Lookup("days-inactive").Value.String(), loader.source("days-inactive"); got != "30" || src != sourceConfigFile+" (days_inactive)" {
+		t.Errorf("--days-inactive = %s from %s, want 30 from the config file", got, src)
+	}
+}
+
+func TestLegacyTimezoneVariable(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		env            map[string]string
+		args           []string
+		want, wantFrom string
+	}{
+		{name: "none", want: "", wantFrom: sourceDefault},
+		{name: "legacy", env: map[string]string{"TIMEZONE": "Europe/Berlin"}, want: "Europe/Berlin", wantFrom: sourceEnv + " (TIMEZONE)"},
+		{name: "renamed wins", env: map[string]string{"TIMEZONE": "Europe/Berlin", "DEFAULT_DISPLAY_TIMEZONE": "Asia/Tokyo"}, want: "Asia/Tokyo", wantFrom: sourceEnv + " (DEFAULT_DISPLAY_TIMEZONE)"},
+		{name: "alias flag wins", env: map[string]string{"DEFAULT_DISPLAY_TIMEZONE": "Asia/Tokyo"}, args: []string{"--timezone=America/Lima"}, want: "America/Lima", wantFrom: sourceFlag},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clearFlagEnv(t)
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			fs := newTestFlagSet()
+			loader := newConfigLoader(fs)
+			if err := loader.parse(tc.args); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range []string{"default-display-timezone", "timezone"} {
+				if got, src := fs.Lookup(name).Value.String(), loader.source(name); got != tc.want || src != tc.wantFrom {
+					t.Errorf("--%s = %q from %s, want %q from %s", name, got, src, tc.want, tc.wantFrom)
+				}
+			}
+		})
+	}
+}
+
+func TestFlagEnvNamesNameExistingFlags(t *testing.T) {
+	fs := newTestFlagSet()
+	for name := range flagEnvNames {
+		if fs.Lookup(name) == nil {
+			t.Errorf("flagEnvNames lists %q, which is not a flag", name)
+		}
+	}
+}
+
+// writeTestFile writes a file in the test's temporary directory and returns
+// its path.
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// configLeafKeys returns the keys of the settings in the config file type t.
+func configLeafKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if t.Field(i).Type.Kind() == reflect.Struct {
+			keys = append(keys, configLeafKeys(t.Field(i).Type, prefix+name+".")...)
+			continue
+		}
+		keys = append(keys, prefix+name)
+	}
+	return keys
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	clearFlagEnv(t)
+	configPath := writeTestFile(t, "config.yaml", fullConfigFile)
+	fileCfg, err := loadConfigFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings := fileCfg.settings()
+	covered := map[string]bool{}
+	for _, s := range settings {
+		covered[s.Key] = true
+	}
+	for _, key := range configLeafKeys(reflect.TypeOf(fileConfig{}), "") {
+		if !covered[key] {
+			t.Errorf("config file key %s sets no flag, or fullConfigFile does not set it", key)
+		}
+	}
+
+	// Each case sets the setting from the sources it lists; the first
+	// source in precedence order wins.
+	sources := []string{sourceFlag, sourceEnv, sourceDotenv, sourceConfigFile}
+	for _, s := range settings {
+		for mask := 0; mask < 1<<len(sources); mask++ {
+			var from []string
+			for i, src := range sources {
+				if mask&(1<<i) != 0 {
+					from = append(from, src)
+				}
+			}
+			name := s.Key + "/" + strings.Join(from, "+")
+			if len(from) == 0 {
+				name = s.Key + "/none"
+			}
+			t.Run(name, func(t *testing.T) {
+				testSettingPrecedence(t, fileCfg, s, from)
+			})
+		}
+	}
+}
+
+// settingValue returns the value a source other than the config file sets a
+// setting to, which differs from the file's and the other sources' values.
+func settingValue(t *testing.T, s configSetting, src string) string {
+	t.Helper()
+	tag := map[string]int{sourceFlag: 1, sourceEnv: 2, sourceDotenv: 3}[src]
+	switch def := newTestFlagSet().Lookup(s.Flag).Value.(flag.Getter).Get().(type) {
+	case bool:
+		// Only the sources tell apart the non-file values of a switch.
+		return strconv.FormatBool(s.Value != "true")
+	case int:
+		return strconv.Itoa(40 + tag)
+	case string:
+		return fmt.Sprintf("value-%d", tag)
+	default:
+		t.Fatalf("--%s: unexpected flag type %T", s.Flag, def)
+		return ""
+	}
+}
+
+// testSettingPrecedence sets a config file setting from the given sources
+// and checks that the one with the highest precedence wins.
+func testSettingPrecedence(t *testing.T, fileCfg *fileConfig, s configSetting, from []string) {
+	values := map[string]string{}
+	for _, src := range from {
+		switch src {
+		case sourceConfigFile:
+			values[src] = s.Value
+			if s.Secret {
+				values[src] = "secret-from-" + s.Value
+				t.Setenv(s.Value, values[src])
+			}
+		default:
+			values[src] = settingValue(t, s, src)
+		}
+	}
+	t.Setenv(s.Env, values[sourceEnv])
+
+	fs := flag.NewFlagSet("stale-pr-bot", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	loader := newConfigLoader(fs)
+	if v, ok := values[sourceDotenv]; ok {
+		if err := loader.loadDotenv(writeTestFile(t, ".env", fmt.Sprintf("%s=%q\n", s.Env, v))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defineFlags(fs)
+	var args []string
+	if v, ok := values[sourceFlag]; ok {
+		args = append(args, "--"+s.Flag+"="+v)
+	}
+	if err := loader.parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := values[sourceConfigFile]; ok {
+		for _, other := range fileCfg.settings() {
+			if other.Secret {
+				t.Setenv(other.Value, "secret-from-"+other.Value)
+			}
+		}
+		if err := loader.applyFile(fileCfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f := fs.Lookup(s.Flag)
+	wantValue, wantSource := f.DefValue, sourceDefault
+	if len(from) > 0 {
+		winner := from[0]
+		wantValue = values[winner]
+		switch winner {
+		case sourceFlag:
+			wantSource = sourceFlag
+		case sourceConfigFile:
+			wantSource = fmt.Sprintf("%s (%s)", sourceConfigFile, s.Key)
+		default:
+			wantSource = fmt.Sprintf("%s (%s)", winner, s.Env)
+		}
+	}
+	if got := f.Value.String(); got != wantValue {
+		t.Errorf("--%s = %q, want %q", s.Flag, got, wantValue)
+	}
+	if got := loader.source(s.Flag); got != wantSource {
+		t.Errorf("source of --%s = %q, want %q", s.Flag, got, wantSource)
+	}
+}
+
+func TestPrintEffectiveConfigRedactsSecrets(t *testing.T) {
+	clearFlagEnv(t)
+	t.Setenv("SMTP_PASSWORD", "env-password")
+	t.Setenv("TEST_SLACK_WEBHOOK_URL", "https://hooks.slack.example/file-secret")
+	fs := flag.NewFlagSet("stale-pr-bot", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	loader := newConfigLoader(fs)
+	defineFlags(fs)
+	err := loader.parse([]string{
+		"--github-token=flag-token",
+		"--webhook-url=https://hooks.example/flag-secret",
+		"--webhook-secret=flag-hmac",
+		"--owner=visible-owner",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileCfg := &fileConfig{}
+	fileCfg.Slack.WebhookURLEnv = "TEST_SLACK_WEBHOOK_URL"
+	if err := loader.applyFile(fileCfg); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := loader.printEffectiveConfig(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"flag-token", "flag-secret", "flag-hmac", "env-password", "file-secret"} {
+		if strings.Contains(out.String(), secret) {
+			t.Errorf("printed config contains the secret %q:\n%s", secret, out.String())
+		}
+	}
+
+	rows := map[string][]string{}
+	for _, line := range strings.Split(out.String(), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			rows[fields[0]] = fields[1:]
+		}
+	}
+	for _, tc := range []struct {
+		name  string
+		value string
+		from  string
+	}{
+		{"github-token", `"(redacted)"`, sourceFlag},
+		{"webhook-url", `"(redacted)"`, sourceFlag},
+		{"webhook-secret", `"(redacted)"`, sourceFlag},
+		{"smtp-password", `"(redacted)"`, sourceEnv},
+		{"slack-webhook-url", `"(redacted)"`, sourceConfigFile},
+		{"teams-webhook-url", `""`, sourceDefault},
+		{"owner", `"visible-owner"`, sourceFlag},
+	} {
+		row := rows[tc.name]
+		if len(row) < 2 || row[0] != tc.value || !strings.HasPrefix(strings.Join(row[1:], " "), tc.from) {
+			t.Errorf("--%s is printed as %q, want value %s from %s", tc.name, row, tc.value, tc.from)
+		}
+	}
+}
+
+func TestSubcommandsReadDotenv(t *testing.T) {
+	trends := writeTestFile(t, "trends.ndjson", `{"time":"2026-03-01T03:00:00Z","repo":"acme/api","full_scan":true,"open_prs":12,"warned":2,"closed":1}`+"\n")
+	t.Setenv("TRENDS_FILE", "")
+	loader := newConfigLoader(flag.NewFlagSet("stale-pr-bot", flag.ContinueOnError))
+	if err := loader.loadDotenv(writeTestFile(t, ".env", "TRENDS_FILE="+trends+"\n")); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	code, ok := runSubcommand("trends", []string{"--format=csv"}, &out)
+	if !ok || code != exitSuccess {
+		t.Fatalf("trends returned %d, %v", code, ok)
+	}
+	if want := "acme/api,2026-03-01T03:00:00Z,full,12,2,1,"; !strings.Contains(out.String(), want) {
+		t.Errorf("trends printed %q, want the run in the file named by the .env file, %s", out.String(), want)
+	}
+
+	if _, ok := runSubcommand("--repo", nil, &out); ok {
+		t.Error("a flag was run as a subcommand")
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configSchemaURL is the JSON Schema dialect of the config file schema.
+const configSchemaURL = "https://json-schema.org/draft/2020-12/schema"
+
+// configSchema returns the JSON Schema of the config file. It is generated
+// from fileConfig, so that it describes exactly what loadConfigFile reads.
+func configSchema() map[string]any {
+	s := typeSchema(reflect.TypeOf(fileConfig{}))
+	s["$schema"] = configSchemaURL
+	s["title"] = "stale-pr-bot config file"
+	return s
+}
+
+// typeSchema returns the schema of the values of the config file type t.
+// Structs are objects that allow only the keys of their fields.
+func typeSchema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := map[string]any{}
+		fieldSchemas(t, properties)
+		return map[string]any{"type": "object", "properties": properties, "additionalProperties": false}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	default:
+		return map[string]any{"type": "string"}
+	}
+}
+
+// fieldSchemas adds the schemas of the fields of the struct type t,
+// including those of its inlined structs, to properties by YAML key.
+func fieldSchemas(t reflect.Type, properties map[string]any) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if opts == "inline" {
+			fieldSchemas(f.Type, properties)
+			continue
+		}
+		s := typeSchema(f.Type)
+		if enum := f.Tag.Get("enum"); enum != "" {
+			s["enum"] = strings.Split(enum, ",")
+		}
+		properties[name] = s
+	}
+}
+
+// schemaErrors checks a YAML document against a schema from configSchema.
+// It returns a message for each key the schema does not allow and each value
+// of the wrong type or outside its enum, with its line and path, such as
+// "line 4: smtp.port: got string, want integer".
+func schemaErrors(node *yaml.Node, schema map[string]any, path string) []string {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	fail := func(format string, args ...any) []string {
+		where := path
+		if where == "" {
+			where = "(document)"
+		}
+		return []string{fmt.Sprintf("line %d: %s: ", node.Line, where) + fmt.Sprintf(format, args...)}
+	}
+	// An empty value leaves the setting at its default.
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil
+	}
+	want, _ := schema["type"].(string)
+	if got := yamlType(node); got != want {
+		return fail("got %s, want %s", got, want)
+	}
+
+	var errs []string
+	switch want {
+	case "object":
+		properties, _ := schema["properties"].(map[string]any)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			property, ok := properties[key].(map[string]any)
+			if !ok {
+				errs = append(errs, fmt.Sprintf("line %d: %s: unknown key", node.Content[i].Line, keyPath))
+				continue
+			}
+			errs = append(errs, schemaErrors(node.Content[i+1], property, keyPath)...)
+		}
+	case "array":
+		items, _ := schema["items"].(map[string]any)
+		for i, item := range node.Content {
+			errs = append(errs, schemaErrors(item, items, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	default:
+		if enum, ok := schema["enum"].([]string); ok && !slices.Contains(enum, node.Value) {
+			return fail("%q is not one of %s", node.Value, strings.Join(enum, ", "))
+		}
+	}
+	return errs
+}
+
+// yamlType returns the JSON Schema type of a YAML node.
+func yamlType(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	}
+	switch node.Tag {
+	case "!!int":
+		return "integer"
+	case "!!bool":
+		return "boolean"
+	case "!!float":
+		return "number"
+	default:
+		return "string"
+	}
+}
+
+// runSchemaCommand prints the JSON Schema of the config file, for editors
+// and CI to validate config files with.
+func runSchemaCommand(args []string, w io.Writer) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: schema")
+	}
+	data, err := json.MarshalIndent(configSchema(), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// runValidateConfigCommand checks a config file against the JSON Schema and
+// then parses it as a run would, printing each problem found.
+func runValidateConfigCommand(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("validate-config", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: validate-config <config file>")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: validate-config <config file>")
+	}
+	path := fs.Arg(0)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	if len(root.Content) > 0 {
+		errs := schemaErrors(root.Content[0], configSchema(), "")
+		for _, e := range errs {
+			fmt.Fprintf(w, "%s: %s\n", path, e)
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("%s does not match the config file schema: %d problem(s)", path, len(errs))
+		}
+	}
+	if _, err := loadConfigFile(path); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s: valid\n", path)
+	return err
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestSchemaCommandGolden compares the schema subcommand's output with
+// testdata/schema/config.schema.json. Run with -update to rewrite it.
+func TestSchemaCommandGolden(t *testing.T) {
+	var out strings.Builder
+	if err := runSchemaCommand(nil, &out); err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(out.String()), &doc); err != nil {
+		t.Fatalf("schema printed invalid JSON: %v", err)
+	}
+	golden := filepath.Join("testdata", "schema", "config.schema.json")
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, []byte(out.String()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v; run the test with -update to create it", err)
+	}
+	if out.String() != string(want) {
+		t.Errorf("schema output differs from %s; run the test with -update to accept it:\n--- got ---\n%s--- want ---\n%s", filepath.Base(golden), out.String(), want)
+	}
+}
+
+// TestConfigSchemaCoversFileConfig checks that the schema has a property for
+// each key loadConfigFile reads, at every level.
+func TestConfigSchemaCoversFileConfig(t *testing.T) {
+	var check func(typ reflect.Type, schema map[string]any, path string)
+	check = func(typ reflect.Type, schema map[string]any, path string) {
+		fields := map[string]reflect.Type{}
+		configFields(typ, fields)
+		properties, _ := schema["properties"].(map[string]any)
+		if len(properties) != len(fields) {
+			t.Errorf("%s: schema has %d properties, want %d", path, len(properties), len(fields))
+		}
+		for key, ft := range fields {
+			property, ok := properties[key].(map[string]any)
+			if !ok {
+				t.Errorf("%s%s: missing from the schema", path, key)
+				continue
+			}
+			if ft.Kind() == reflect.Struct {
+				check(ft, property, path+key+".")
+			}
+		}
+	}
+	check(reflect.TypeOf(fileConfig{}), configSchema(), "")
+}
+
+func TestConfigSchemaErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		doc  string
+		want []string
+	}{
+		{"full", fullConfigFile, nil},
+		{"empty values", "owner:\nsmtp:\n  port:\n", nil},
+		{"anchor", "labels:\n  exempt: &keep [pinned]\nrule_order: *keep\n", nil},
+		{"unknown key", "owner: acme\nrepo: widgets\n", []string{"line 2: repo: unknown key"}},
+		{"unknown nested key", "smtp:\n  host: mail.example.com\n", []string{"line 2: smtp.host: unknown key"}},
+		{"integer as string", "smtp:\n  port: \"2525\"\n", []string{"line 2: smtp.port: got string, want integer"}},
+		{"string for boolean", "include_issues: yes\n", []string{"line 1: include_issues: got string, want boolean"}},
+		{"scalar for list", "labels:\n  exempt: pinned\n", []string{"line 2: labels.exempt: got string, want array"}},
+		{"list item", "repos:\n  - widgets\n  - {name: gadgets}\n", []string{"line 3: repos[1]: got object, want string"}},
+		{"mapping for string", "owner:\n  name: acme\n", []string{"line 2: owner: got object, want string"}},
+		{"enum", "notify_via: pager\nsmtp:\n  encryption: ssl\n", []string{
+			`line 1: notify_via: "pager" is not one of email, comment, both, slack, teams`,
+			`line 3: smtp.encryption: "ssl" is not one of starttls, tls, none`,
+		}},
+		{"document", "- owner\n", []string{"line 1: (document): got array, want object"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var root yaml.Node
+			if err := yaml.Unmarshal([]byte(tc.doc), &root); err != nil {
+				t.Fatal(err)
+			}
+			got := schemaErrors(root.Content[0], configSchema(), "")
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("schemaErrors returned %q, want %q", got, tc.want)
+			}
+			// A document the schema accepts is one loadConfigFile reads.
+			if len(tc.want) == 0 {
+				if _, err := loadConfigFile(writeTestFile(t, "config.yaml", tc.doc)); err != nil {
+					t.Errorf("loadConfigFile: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateConfigCommand(t *testing.T) {
+	valid := writeTestFile(t, "valid.yaml", fullConfigFile)
+	var out strings.Builder
+	if err := runValidateConfigCommand([]string{valid}, &out); err != nil {
+		t.Fatalf("validate-config of a valid file: %v", err)
+	}
+	if got, want := out.String(), valid+": valid\n"; got != want {
+		t.Errorf("validate-config printed %q, want %q", got, want)
+	}
+
+	invalid := writeTestFile(t, "invalid.yaml", "owner: acme\nsmtp:\n  port: many\n  host: mail\n")
+	out.Reset()
+	err := runValidateConfigCommand([]string{invalid}, &out)
+	if err == nil || !strings.Contains(err.Error(), "2 problem(s)") {
+		t.Errorf("validate-config of an invalid file returned %v, want 2 problems", err)
+	}
+	for _, want := range []string{
+		invalid + ": line 3: smtp.port: got string, want integer\n",
+		invalid + ": line 4: smtp.host: unknown key\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("validate-config output lacks %q:\n%s", want, out.String())
+		}
+	}
+
+	for _, args := range [][]string{nil, {valid, invalid}} {
+		if err := runValidateConfigCommand(args, &out); err == nil || !strings.Contains(err.Error(), "usage") {
+			t.Errorf("validate-config %q returned %v, want a usage error", args, err)
+		}
+	}
+	if err := runValidateConfigCommand([]string{filepath.Join(t.TempDir(), "missing.yaml")}, &out); err == nil {
+		t.Error("validate-config of a missing file returned no error")
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestConvertConfigGolden converts each probot/stale configuration in
+// testdata/convert-config and compares the config file and templates it
+// writes with the .golden file next to it. Run with -update to rewrite them.
+func TestConvertConfigGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "convert-config", "*.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("no probot/stale configurations in testdata/convert-config")
+	}
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".yml")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := strings.TrimSuffix(input, ".yml") + ".golden"
+			dir := t.TempDir()
+			config := writeTestFile(t, "stale.yml", string(data))
+			var out strings.Builder
+			if err := runConvertConfigCommand([]string{"--template-dir", filepath.Join(dir, "templates"), config}, &out); err != nil {
+				t.Fatalf("convert-config: %v", err)
+			}
+			templates, err := filepath.Glob(filepath.Join(dir, "templates", "*"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, file := range templates {
+				text, err := os.ReadFile(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				out.WriteString("--- templates/" + filepath.Base(file) + " ---\n")
+				out.Write(text)
+				out.WriteString("\n")
+			}
+			// The paths in the output do not depend on where the test runs.
+			got := strings.NewReplacer(filepath.Dir(config), "$DIR", dir, "$DIR").Replace(out.String())
+
+			if *updateGolden {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v; run the test with -update to create it", err)
+			}
+			if got != string(want) {
+				t.Errorf("convert-config output differs from %s; run the test with -update to accept it:\n--- got ---\n%s--- want ---\n%s", filepath.Base(golden), got, want)
+			}
+		})
+	}
+}
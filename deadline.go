@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// defaultRunDurationMargin is how long before --max-run-duration a run stops
+// starting work on new PRs, leaving time to finish the PR in progress and to
+// write the state, reports and summaries.
+const defaultRunDurationMargin = 2 * time.Minute
+
+// runDeadline time-boxes a run for --max-run-duration. Once it is reached no
+// further PRs or repositories are started; the PRs left over are deferred to
+// the next run like those of an exhausted budget. A daemon stopping for a
+// signal ends its run in progress the same way, by cancelling ctx. The zero
+// deadline is never reached.
+type runDeadline struct {
+	ctx context.Context
+	at  time.Time
+	now func() time.Time
+	// hit is set once the deadline has been found reached.
+	hit bool
+}
+
+// newRunDeadline returns the deadline of a run started at started that may
+// take up to max, stopping margin early, or until ctx is cancelled. A zero
+// max means no limit.
+func newRunDeadline(ctx context.Context, started time.Time, max, margin time.Duration, now func() time.Time) *runDeadline {
+	d := &runDeadline{ctx: ctx, now: now}
+	if max > 0 {
+		d.at = started.Add(max - margin)
+	}
+	return d
+}
+
+// reached reports whether the run must not start further work.
+func (d *runDeadline) reached() bool {
+	if !d.hit && (d.ctx.Err() != nil || !d.at.IsZero() && !d.now().Before(d.at)) {
+		d.hit = true
+	}
+	return d.hit
+}
+
+// reason returns why the deadline was reached, for logs.
+func (d *runDeadline) reason() string {
+	if d.ctx.Err() != nil {
+		return "shutdown"
+	}
+	return "max-run-duration"
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRunDeadline(t *testing.T) {
+	started := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	clock := started
+	now := func() time.Time { return clock }
+
+	d := newRunDeadline(context.Background(), started, 10*time.Minute, 2*time.Minute, now)
+	for _, tc := range []struct {
+		at   time.Duration
+		want bool
+	}{
+		{0, false},
+		{7*time.Minute + 59*time.Second, false},
+		{8 * time.Minute, true},
+		// Once reached the deadline stays reached, even if the clock
+		// goes back.
+		{time.Minute, true},
+	} {
+		clock = started.Add(tc.at)
+		if got := d.reached(); got != tc.want {
+			t.Errorf("%v into the run reached() = %v, want %v", tc.at, got, tc.want)
+		}
+	}
+	if got := d.reason(); got != "max-run-duration" {
+		t.Errorf("reason %q, want max-run-duration", got)
+	}
+
+	unlimited := newRunDeadline(context.Background(), started, 0, 2*time.Minute, now)
+	clock = started.AddDate(1, 0, 0)
+	if unlimited.reached() {
+		t.Error("a run without --max-run-duration reached its deadline")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := newRunDeadline(ctx, started, 0, 0, now)
+	if stopped.reached() {
+		t.Error("the deadline was reached before the shutdown")
+	}
+	cancel()
+	if !stopped.reached() || stopped.reason() != "shutdown" {
+		t.Errorf("after the shutdown reached() = %v with reason %q, want true with reason shutdown", stopped.reached(), stopped.reason())
+	}
+}
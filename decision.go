@@ -0,0 +1,525 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// Actions the bot can decide to take on a PR.
+const (
+	// actionExempt: the PR is exempt from staleness; any warning is removed.
+	actionExempt = "exempt"
+	// actionActive: the PR is not stale; any warning is removed.
+	actionActive = "active"
+	// actionWarn: the PR is stale and its author is warned.
+	actionWarn = "warn"
+	// actionWait: the PR was warned and is within its warning period.
+	actionWait = "wait"
+	// actionClose: the warning period has passed and the PR is closed.
+	actionClose = "close"
+	// actionCloseNow: the PR is stale and closed without a warning.
+	actionCloseNow = "close-now"
+	// actionAway: the PR is stale but its author is out of office, so
+	// warnings and closures wait for their return.
+	actionAway = "away"
+	// actionParked: the PR is parked in the stale milestone and left alone
+	// until a human moves it out.
+	actionParked = "parked"
+	// actionQuestion: the PR is stale, but the author's last comment is an
+	// unanswered question, so maintainers are nudged instead.
+	actionQuestion = "waiting-on-maintainer"
+	// actionEscalate: the PR is a stale security update, so it is escalated
+	// to the security contact and never warned or closed.
+	actionEscalate = "escalate"
+	// actionCloseRequested: a maintainer applied the close-now label, so the
+	// PR is closed whatever its activity.
+	actionCloseRequested = "close-requested"
+)
+
+// evaluationRules are the settings the decision for a PR depends on.
+type evaluationRules struct {
+	// SkipDrafts exempts draft PRs from staleness.
+	SkipDrafts bool
+	// PauseDrafts exempts the drafts whose author converted them while
+	// warned, until they are marked ready for review again.
+	PauseDrafts bool
+	// ReadyResetsActivity restarts the inactivity clock of a PR when it is
+	// marked ready for review.
+	ReadyResetsActivity bool
+	// ExemptAuthors are logins and login globs whose PRs are exempt from
+	// staleness.
+	ExemptAuthors []string
+	// ExemptLabels exempt any PR carrying one of them from staleness.
+	ExemptLabels []string
+	// ExemptUntilPrefix starts the labels exempting a PR until the date
+	// that follows, e.g. "stale-exempt-until:". The dates are read in
+	// Location. Empty disables them.
+	ExemptUntilPrefix string
+	Location          *time.Location
+	// StaleLabel marks warned PRs.
+	StaleLabel    string
+	Policies      []authorPolicy
+	DaysInactive  int
+	WarningPeriod int
+	// ExemptSecurity exempts security work: PRs with one of SecurityLabels,
+	// PRs from advisory forks and PRs by members of the security team.
+	ExemptSecurity bool
+	SecurityLabels []string
+	// SecurityTeam holds the lower-cased logins of the security team.
+	SecurityTeam map[string]bool
+	// EscalateSecurityUpdates escalates stale Dependabot security updates
+	// instead of warning and closing them.
+	EscalateSecurityUpdates bool
+	// ParkedMilestone, when set, is the milestone stale PRs are parked in
+	// instead of being closed.
+	ParkedMilestone string
+	// FailingChecksStaleAfter makes a PR stale, despite other activity, once
+	// its required checks have been failing this long. Zero disables it.
+	FailingChecksStaleAfter time.Duration
+	// OOO is the authors' out-of-office calendar. PRs of absent authors
+	// are not warned or closed until OOOGrace after their return.
+	OOO      oooCalendar
+	OOOGrace time.Duration
+	// ReopenGraceDays is how long a PR reopened after a closure for
+	// inactivity cannot become stale again.
+	ReopenGraceDays int
+	// ExemptWaitingOnReview exempts PRs the author handed over to their
+	// reviewers.
+	ExemptWaitingOnReview bool
+	// CloseWaitingOnMaintainer enforces staleness on PRs whose author's
+	// question is unanswered instead of nudging the maintainers.
+	CloseWaitingOnMaintainer bool
+	// Order is the order of the exemption and modifier rules from
+	// --rule-order, or nil for the default order.
+	Order []string
+}
+
+// prSignals are the facts about a PR that need API calls to establish. A
+// nil field is treated as unknown.
+type prSignals struct {
+	Checks *checkStatus
+	// ReopenedAt is when the PR was reopened after a closure for
+	// inactivity, if it was.
+	ReopenedAt time.Time
+	// WarnedAt is when the 'stale-warning' label was added, if known.
+	WarnedAt time.Time
+	// TitlePrefixedAt is when the bot prefixed the title of the warned PR,
+	// if it did.
+	TitlePrefixedAt time.Time
+	// WaitingOnReview is how the author handed the PR over to its
+	// reviewers, if they did.
+	WaitingOnReview string
+	// Activity overrides the PR's last update as its last activity.
+	Activity *activity
+	// Question is the author's unanswered question, if their last comment
+	// asks one.
+	Question *question
+	// CloseRequest is set if the PR carries the close-now label.
+	CloseRequest *closeRequest
+	// CloseReason is how the PR's review went, one of the close reasons,
+	// if its timeline is known.
+	CloseReason string
+	// Transitions are the PR's draft transitions, if its timeline is known.
+	Transitions draftTransitions
+}
+
+// prDecision is the outcome of evaluating a PR, with a human-readable trace
+// of how it was reached.
+type prDecision struct {
+	Action string
+	// Rule is the rule that decided the action.
+	Rule string
+	// Draft is set when the PR was exempted as a draft, and Paused when
+	// that is because its author converted it to one while warned.
+	Draft  bool
+	Paused bool
+	// ExemptAuthor is the exempt author pattern that exempted the PR, if
+	// any.
+	ExemptAuthor string
+	// ExemptLabel is the exempt label that exempted the PR, if any.
+	ExemptLabel string
+	// TimeBoxed is what the PR's time-boxed exemption labels say. Expired
+	// ones are removed whatever the decision.
+	TimeBoxed timeBoxedExemption
+	// FailingChecks lists the failing checks that made the PR stale, if it
+	// is stale because of them.
+	FailingChecks []string
+	// SecurityReason is why the PR was exempted as security work, if it was.
+	SecurityReason string
+	// SecurityUpdate is how the PR was identified as a Dependabot security
+	// update, if it is one and those are escalated.
+	SecurityUpdate string
+	// ReopenedAt is when the PR was reopened after a closure for
+	// inactivity, if it was.
+	ReopenedAt time.Time
+	// AwayReason is why enforcement waits for the author's return, if it
+	// does.
+	AwayReason string
+	// WaitingOnReview is set when the PR was exempted as waiting on its
+	// reviewers.
+	WaitingOnReview string
+	// Question is the unanswered question the PR is waiting on a
+	// maintainer for, if it is.
+	Question *question
+	// CloseRequest is the request to close the PR now, if it carries the
+	// close-now label.
+	CloseRequest *closeRequest
+	// CloseReason selects the closure message variant of a PR being closed
+	// as stale, if its timeline is known.
+	CloseReason string
+	// Override is the author policy override that applied, if any.
+	Override *authorPolicy
+	// LastActivity is the PR's last activity and LastActivitySource what
+	// it was.
+	LastActivity       time.Time
+	LastActivitySource string
+	Trace              []string
+	// StaleAt is when the PR became, or will become, stale.
+	StaleAt time.Time
+	// CloseAt is when a warned or about to be warned PR becomes eligible
+	// for closure.
+	CloseAt time.Time
+}
+
+func (d *prDecision) tracef(format string, a ...interface{}) {
+	d.Trace = append(d.Trace, fmt.Sprintf(format, a...))
+}
+
+// labelUpdateSlack is how long after the 'stale-warning' label is added a PR
+// update is still taken to be the labeling itself.
+const labelUpdateSlack = time.Minute
+
+// evaluation is the state of evaluating one PR, which its rules share.
+type evaluation struct {
+	pr      *github.PullRequest
+	signals prSignals
+	rules   evaluationRules
+	now     time.Time
+	d       prDecision
+	// policy is the author policy the PR is subject to.
+	policy string
+	// securityUpdate is how the PR was identified as a security update to
+	// escalate, if it was.
+	securityUpdate string
+}
+
+// decide records the rule and action a PR was decided by.
+func (e *evaluation) decide(rule, action string) {
+	e.d.Rule = rule
+	e.d.Action = action
+	e.d.tracef("decided by the '%s' rule", rule)
+	if (action == actionClose || action == actionCloseNow) && e.signals.CloseReason != "" {
+		e.d.CloseReason = e.signals.CloseReason
+		e.d.tracef("closing as %s: %s", e.d.CloseReason, closeReasonText(e.d.CloseReason))
+	}
+}
+
+// evaluatePR decides what to do with a PR at time now, given whatever signals
+// are known. It makes no API calls, so the same decision can be explained
+// offline for a synthetic PR. Rules are evaluated in the order ruleorder.go
+// documents.
+func evaluatePR(pr *github.PullRequest, signals prSignals, rules evaluationRules, now time.Time) prDecision {
+	act := updatedActivity(pr)
+	if signals.Activity != nil {
+		act = *signals.Activity
+	}
+	readyReset := false
+	if ready := signals.Transitions.ReadyAt; rules.ReadyResetsActivity && !ready.IsZero() && !ready.Before(act.At) {
+		act = activity{At: ready, Source: fmt.Sprintf("marked ready for review by %s", signals.Transitions.ReadyBy)}
+		readyReset = true
+	}
+	e := &evaluation{pr: pr, signals: signals, rules: rules, now: now, policy: policyWarnThenClose}
+	e.d = prDecision{
+		LastActivity:       act.At,
+		LastActivitySource: act.Source,
+		StaleAt:            act.At.Add(time.Duration(rules.DaysInactive) * 24 * time.Hour),
+	}
+	if rules.Order != nil {
+		e.d.tracef("rule order: %s", rules.ruleOrder())
+	}
+	if readyReset {
+		e.d.tracef("marked ready for review on %s: the inactivity clock restarts then", act.At.Format("2006-01-02"))
+	}
+	if rules.EscalateSecurityUpdates {
+		e.securityUpdate = securityUpdate(pr)
+	}
+	e.d.TimeBoxed = timeBoxedExemptions(pr, rules.ExemptUntilPrefix, rules.Location, now)
+	for _, label := range e.d.TimeBoxed.Invalid {
+		e.d.tracef("ignoring the label '%s': it does not end in a YYYY-MM-DD date", label)
+	}
+	for _, label := range e.d.TimeBoxed.Expired {
+		e.d.tracef("the time-boxed exemption '%s' has expired", label)
+	}
+
+	if e.closeRequest() {
+		return e.d
+	}
+	for _, rule := range rules.phase(exemptionRules) {
+		if e.exempt(rule) {
+			return e.d
+		}
+	}
+	if e.securityUpdate != "" {
+		e.d.tracef("is a %s, escalated rather than closed if stale", e.securityUpdate)
+		e.d.SecurityUpdate = e.securityUpdate
+	}
+	if e.classify(act) {
+		return e.d
+	}
+	for _, rule := range rules.phase(modifierRules) {
+		if e.modify(rule) {
+			return e.d
+		}
+	}
+	e.lifecycle()
+	return e.d
+}
+
+// closeRequest honors a maintainer's request to close the PR.
+func (e *evaluation) closeRequest() bool {
+	cr := e.signals.CloseRequest
+	if cr == nil {
+		return false
+	}
+	e.d.CloseRequest = cr
+	switch {
+	case cr.Allowed:
+		e.d.tracef("@%s asked for it to be closed with the '%s' label", cr.By, cr.Label)
+		e.d.CloseAt = e.now
+		e.decide(ruleCloseRequest, actionCloseRequested)
+		return true
+	case cr.By == "":
+		e.d.tracef("ignoring the '%s' label: its events do not say who added it", cr.Label)
+	default:
+		e.d.tracef("ignoring the '%s' label: @%s cannot push to the repository", cr.Label, cr.By)
+	}
+	return false
+}
+
+// exempt evaluates a hard exemption rule, reporting whether it decided the
+// PR.
+func (e *evaluation) exempt(rule string) bool {
+	pr, rules, d := e.pr, e.rules, &e.d
+	switch rule {
+	case ruleParked:
+		if isParked(pr, rules.ParkedMilestone) {
+			d.tracef("is parked in the milestone '%s'", rules.ParkedMilestone)
+			e.decide(rule, actionParked)
+			return true
+		}
+	case ruleDraft:
+		if rules.SkipDrafts && pr.GetDraft() {
+			d.tracef("is a draft")
+			d.Draft = true
+			e.decide(rule, actionExempt)
+			return true
+		}
+		if paused := e.signals.Transitions.PausedAt; rules.PauseDrafts && pr.GetDraft() && !paused.IsZero() {
+			d.tracef("paused: the author converted it to a draft while warned, on %s; it resumes when marked ready for review", paused.Format("2006-01-02"))
+			d.Draft, d.Paused = true, true
+			e.decide(rule, actionExempt)
+			return true
+		}
+	case ruleExemptAuthor:
+		if pattern := matchExemptAuthor(rules.ExemptAuthors, pr.GetUser().GetLogin()); pattern != "" {
+			d.tracef("author %s is exempt ('%s')", pr.GetUser().GetLogin(), pattern)
+			d.ExemptAuthor = pattern
+			e.decide(rule, actionExempt)
+			return true
+		}
+	case ruleExemptLabel:
+		for _, label := range rules.ExemptLabels {
+			if hasLabel(pr, label) {
+				d.tracef("has the exempt label '%s'", label)
+				d.ExemptLabel = label
+				e.decide(rule, actionExempt)
+				return true
+			}
+		}
+	case ruleExemptUntil:
+		if tb := d.TimeBoxed; tb.Label != "" {
+			d.tracef("is exempt until the end of %s by the label '%s'", tb.Until.AddDate(0, 0, -1).Format("2006-01-02"), tb.Label)
+			e.decide(rule, actionExempt)
+			return true
+		}
+	case ruleSecurityExemption:
+		// Security updates are escalated rather than exempted.
+		if !rules.ExemptSecurity || e.securityUpdate != "" {
+			break
+		}
+		if reason := securityExemption(pr, rules); reason != "" {
+			d.tracef("is exempt as security work: %s", reason)
+			d.SecurityReason = reason
+			e.decide(rule, actionExempt)
+			return true
+		}
+	case ruleAuthorPolicy:
+		if override, ok := matchAuthorPolicy(rules.Policies, pr.GetUser()); ok && e.securityUpdate != "" {
+			d.tracef("author policy override '%s' does not apply to security updates", override.Pattern)
+		} else if ok {
+			d.tracef("author policy override '%s' applies (%s)", override.Pattern, override.Policy)
+			d.Override = &override
+			e.policy = override.Policy
+		}
+		if e.policy == policySkip {
+			d.tracef("skipped by author policy")
+			e.decide(rule, actionExempt)
+			return true
+		}
+	case ruleWaitingOnReview:
+		if rules.ExemptWaitingOnReview && e.signals.WaitingOnReview != "" {
+			d.tracef("is waiting on review: %s and no reviewer has acted since", e.signals.WaitingOnReview)
+			d.WaitingOnReview = e.signals.WaitingOnReview
+			e.decide(rule, actionExempt)
+			return true
+		}
+	}
+	return false
+}
+
+// classify decides whether the PR is stale, reporting whether it decided
+// that the PR is not.
+func (e *evaluation) classify(act activity) bool {
+	rules, signals, now, d := e.rules, e.signals, e.now, &e.d
+	warningPeriod := time.Duration(rules.WarningPeriod) * 24 * time.Hour
+	checks := signals.Checks
+	lastActivity := act.At
+
+	if !signals.ReopenedAt.IsZero() {
+		d.ReopenedAt = signals.ReopenedAt
+		d.tracef("was closed for inactivity and reopened on %s", signals.ReopenedAt.Format("2006-01-02"))
+		graceEnd := signals.ReopenedAt.Add(time.Duration(rules.ReopenGraceDays) * 24 * time.Hour)
+		if graceEnd.After(d.StaleAt) {
+			d.StaleAt = graceEnd
+		}
+		if now.Before(graceEnd) {
+			d.tracef("within the %d-day grace period after reopening", rules.ReopenGraceDays)
+			d.CloseAt = d.StaleAt.Add(warningPeriod)
+			e.decide(ruleReopenGrace, actionActive)
+			return true
+		}
+	}
+
+	// Adding the warning label and prefixing the title update the PR, so an
+	// update no later than those is not activity.
+	botUpdate := signals.WarnedAt
+	if signals.TitlePrefixedAt.After(botUpdate) {
+		botUpdate = signals.TitlePrefixedAt
+	}
+	quietSinceWarning := !signals.WarnedAt.IsZero() && signals.Activity == nil &&
+		!lastActivity.After(botUpdate.Add(labelUpdateSlack))
+	if quietSinceWarning {
+		d.tracef("is stale: no activity since the stale warning on %s", signals.WarnedAt.Format("2006-01-02"))
+	} else if !lastActivity.Before(now.Add(-time.Duration(rules.DaysInactive) * 24 * time.Hour)) {
+		d.tracef("last activity (%s) %s ago, within the %d-day threshold", act.Source, humanizeDuration(now.Sub(lastActivity)), rules.DaysInactive)
+		failing := rules.FailingChecksStaleAfter > 0 && checks != nil && len(checks.Failing) > 0
+		if !failing || now.Sub(checks.FailingSince) < rules.FailingChecksStaleAfter {
+			if failing && checks.FailingSince.Add(rules.FailingChecksStaleAfter).Before(d.StaleAt) {
+				d.StaleAt = checks.FailingSince.Add(rules.FailingChecksStaleAfter)
+			}
+			d.CloseAt = d.StaleAt.Add(warningPeriod)
+			e.decide(ruleActivity, actionActive)
+			return true
+		}
+		d.tracef("is stale: checks %s failing for %s", strings.Join(checks.Failing, ", "), humanizeDuration(now.Sub(checks.FailingSince)))
+		d.FailingChecks = checks.Failing
+		d.StaleAt = checks.FailingSince.Add(rules.FailingChecksStaleAfter)
+	} else {
+		d.tracef("is stale: no activity for %s (last: %s), past the %d-day threshold", humanizeDuration(now.Sub(lastActivity)), act.Source, rules.DaysInactive)
+	}
+	return false
+}
+
+// modify evaluates an action modifier on a stale PR, reporting whether it
+// decided the PR.
+func (e *evaluation) modify(rule string) bool {
+	rules, d := e.rules, &e.d
+	switch rule {
+	case ruleSecurityUpdate:
+		if d.SecurityUpdate != "" {
+			d.tracef("not enforced: escalated to the security contact")
+			d.CloseAt = time.Time{}
+			e.decide(rule, actionEscalate)
+			return true
+		}
+	case ruleQuestion:
+		if q := e.signals.Question; q != nil && !rules.CloseWaitingOnMaintainer {
+			d.tracef("not enforced: waiting on a maintainer to answer the author's question of %s", q.At.Format("2006-01-02"))
+			d.Question = q
+			d.CloseAt = time.Time{}
+			e.decide(rule, actionQuestion)
+			return true
+		}
+	case ruleAway:
+		if back, away := rules.OOO.away(e.pr.GetUser().GetLogin(), e.now); away {
+			d.AwayReason = "out of office with no return date"
+			d.CloseAt = time.Time{}
+			if !back.IsZero() {
+				d.AwayReason = fmt.Sprintf("out of office until %s", back.AddDate(0, 0, -1).Format("2006-01-02"))
+				d.CloseAt = back.Add(rules.OOOGrace)
+			}
+			d.tracef("not enforced: the author is %s", d.AwayReason)
+			e.decide(rule, actionAway)
+			return true
+		}
+	}
+	return false
+}
+
+// lifecycle decides the next step for a stale PR: warn it, wait out its
+// warning period or close it.
+func (e *evaluation) lifecycle() {
+	pr, rules, signals, now, d := e.pr, e.rules, e.signals, e.now, &e.d
+	warningPeriod := time.Duration(rules.WarningPeriod) * 24 * time.Hour
+
+	// Closures also wait out a grace period after the author's return.
+	back := rules.OOO.returned(pr.GetUser().GetLogin(), now)
+	returning := func() {
+		d.AwayReason = fmt.Sprintf("back from out of office on %s, within the grace period", back.Format("2006-01-02"))
+		d.CloseAt = back.Add(rules.OOOGrace)
+		d.tracef("not closing: the author is %s", d.AwayReason)
+		e.decide(ruleLifecycle, actionAway)
+	}
+	inGrace := now.Before(back.Add(rules.OOOGrace))
+
+	if e.policy == policyCloseImmediately && inGrace {
+		returning()
+		return
+	}
+	if e.policy == policyCloseImmediately {
+		d.tracef("closed immediately by author policy")
+		d.CloseAt = now
+		e.decide(ruleLifecycle, actionCloseNow)
+		return
+	}
+
+	if hasLabel(pr, rules.StaleLabel) {
+		d.tracef("already has a '%s' label", rules.StaleLabel)
+		since := timeSinceLabel(pr, signals.WarnedAt, now)
+		if d.FailingChecks != nil {
+			// Activity continues on such PRs, so the warning is taken to
+			// have been sent when the checks passed the threshold.
+			since = now.Sub(d.StaleAt)
+		}
+		d.CloseAt = now.Add(warningPeriod - since)
+		if since > warningPeriod && inGrace {
+			returning()
+			return
+		}
+		if since > warningPeriod {
+			d.tracef("warning period of %d %s has passed", rules.WarningPeriod, pluralize(rules.WarningPeriod, "day", "days"))
+			e.decide(ruleLifecycle, actionClose)
+			return
+		}
+		d.tracef("still within the warning period")
+		e.decide(ruleLifecycle, actionWait)
+		return
+	}
+
+	d.tracef("not warned yet")
+	d.CloseAt = now.Add(warningPeriod)
+	e.decide(ruleLifecycle, actionWarn)
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// testPR returns an open PR by login, last updated at updated, with labels.
+func testPR(login string, updated time.Time, labels ...string) *github.PullRequest {
+	pr := &github.PullRequest{
+		Number:    github.Ptr(42),
+		State:     github.Ptr("open"),
+		User:      &github.User{Login: github.Ptr(login)},
+		UpdatedAt: &github.Timestamp{Time: updated},
+		CreatedAt: &github.Timestamp{Time: updated.AddDate(0, -6, 0)},
+	}
+	for _, l := range labels {
+		pr.Labels = append(pr.Labels, &github.Label{Name: github.Ptr(l)})
+	}
+	return pr
+}
+
+// testRules returns the rules the decision tests start from: 30 days of
+// inactivity, a 7-day warning period, drafts and 'pinned' PRs exempt, and
+// stale security updates escalated.
+func testRules() evaluationRules {
+	return evaluationRules{
+		SkipDrafts:              true,
+		ExemptLabels:            []string{"pinned"},
+		Location:                time.UTC,
+		StaleLabel:              "stale-warning",
+		DaysInactive:            30,
+		WarningPeriod:           7,
+		EscalateSecurityUpdates: true,
+	}
+}
+
+// TestEvaluatePRMatrix decides every combination of an exempt label, a
+// draft, a warning and how long ago it was given, recent or old activity,
+// a security update to escalate and the order of the exemption rules, and
+// checks the action and rule against the precedence evaluatePR documents:
+// exemptions, then activity, then escalation, then the warning lifecycle.
+func TestEvaluatePRMatrix(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	warnings := []struct {
+		name string
+		// ago is how long ago the PR was warned, or 0 if it was not.
+		ago time.Duration
+	}{
+		{"unwarned", 0},
+		{"warned-2d-ago", 2 * day},
+		{"warned-10d-ago", 10 * day},
+	}
+	type matrixCase struct {
+		exempt, draft bool
+		warning       int
+		recent        bool
+		security      bool
+		labelFirst    bool
+	}
+	var cases []matrixCase
+	for _, exempt := range []bool{false, true} {
+		for _, draft := range []bool{false, true} {
+			for warning := range warnings {
+				for _, recent := range []bool{false, true} {
+					for _, security := range []bool{false, true} {
+						for _, labelFirst := range []bool{false, true} {
+							cases = append(cases, matrixCase{exempt, draft, warning, recent, security, labelFirst})
+						}
+					}
+				}
+			}
+		}
+	}
+	for _, tc := range cases {
+		warning := warnings[tc.warning]
+		name := fmt.Sprintf("exempt=%v/draft=%v/%s/recent=%v/security=%v/label-first=%v", tc.exempt, tc.draft, warning.name, tc.recent, tc.security, tc.labelFirst)
+		t.Run(name, func(t *testing.T) {
+			rules := testRules()
+			if tc.labelFirst {
+				rules.Order = []string{ruleExemptLabel, ruleDraft}
+			}
+			var labels []string
+			var signals prSignals
+			// A warned PR was last updated by its labeling, an unwarned
+			// one long ago.
+			updated := now.Add(-40 * day)
+			if warning.ago > 0 {
+				signals.WarnedAt = now.Add(-warning.ago)
+				updated = signals.WarnedAt
+				labels = append(labels, rules.StaleLabel)
+			}
+			if tc.recent {
+				updated = now.Add(-time.Hour)
+			}
+			login := "alice"
+			if tc.security {
+				login = "dependabot[bot]"
+				labels = append(labels, "security")
+			}
+			if tc.exempt {
+				labels = append(labels, "pinned")
+			}
+			pr := testPR(login, updated, labels...)
+			pr.Draft = github.Ptr(tc.draft)
+
+			wantAction, wantRule := actionWarn, ruleLifecycle
+			wantCloseAt := now.Add(7 * day)
+			switch {
+			case (tc.exempt && tc.labelFirst) || (tc.exempt && !tc.draft):
+				wantAction, wantRule = actionExempt, ruleExemptLabel
+			case tc.draft:
+				wantAction, wantRule = actionExempt, ruleDraft
+			case tc.recent:
+				wantAction, wantRule = actionActive, ruleActivity
+				wantCloseAt = updated.Add(37 * day)
+			case tc.security:
+				wantAction, wantRule = actionEscalate, ruleSecurityUpdate
+			case warning.ago > 7*day:
+				wantAction = actionClose
+				wantCloseAt = signals.WarnedAt.Add(7 * day)
+			case warning.ago > 0:
+				wantAction = actionWait
+				wantCloseAt = signals.WarnedAt.Add(7 * day)
+			}
+			d := evaluatePR(pr, signals, rules, now)
+			if d.Action != wantAction || d.Rule != wantRule {
+				t.Fatalf("decided %s by the '%s' rule, want %s by '%s'; trace:\n%v", d.Action, d.Rule, wantAction, wantRule, d.Trace)
+			}
+			switch wantAction {
+			case actionExempt, actionEscalate:
+				if !d.CloseAt.IsZero() {
+					t.Errorf("CloseAt = %v, want none", d.CloseAt)
+				}
+			default:
+				if !d.CloseAt.Equal(wantCloseAt) {
+					t.Errorf("CloseAt = %v, want %v", d.CloseAt, wantCloseAt)
+				}
+			}
+			if got := d.ExemptLabel != ""; got != (wantRule == ruleExemptLabel) {
+				t.Errorf("ExemptLabel = %q with the '%s' rule deciding", d.ExemptLabel, d.Rule)
+			}
+			if d.Draft != (wantRule == ruleDraft) {
+				t.Errorf("Draft = %v with the '%s' rule deciding", d.Draft, d.Rule)
+			}
+			if got := d.SecurityUpdate != ""; got != (tc.security && wantAction != actionExempt) {
+				t.Errorf("SecurityUpdate = %q for a PR by %s decided %s", d.SecurityUpdate, login, d.Action)
+			}
+		})
+	}
+}
+
+// TestEvaluatePR covers the rules the matrix leaves out.
+func TestEvaluatePR(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	old := now.AddDate(0, 0, -40)
+	for _, tc := range []struct {
+		name    string
+		pr      *github.PullRequest
+		signals prSignals
+		rules   func(r *evaluationRules)
+		want    string
+		rule    string
+	}{
+		{
+			name:    "close request",
+			pr:      testPR("alice", now, "pinned"),
+			signals: prSignals{CloseRequest: &closeRequest{Label: "close-now", By: "maintainer", Allowed: true}},
+			want:    actionCloseRequested,
+			rule:    ruleCloseRequest,
+		},
+		{
+			name:    "close request by someone who cannot push",
+			pr:      testPR("alice", old),
+			signals: prSignals{CloseRequest: &closeRequest{Label: "close-now", By: "passer-by"}},
+			want:    actionWarn,
+			rule:    ruleLifecycle,
+		},
+		{
+			name: "exempt author",
+			pr:   testPR("release-bot", old),
+			rules: func(r *evaluationRules) {
+				r.ExemptAuthors = []string{"*-bot"}
+			},
+			want: actionExempt,
+			rule: ruleExemptAuthor,
+		},
+		{
+			name: "skip policy",
+			pr:   testPR("alice", old),
+			rules: func(r *evaluationRules) {
+				r.Policies = []authorPolicy{{Pattern: "alice", Policy: policySkip}}
+			},
+			want: actionExempt,
+			rule: ruleAuthorPolicy,
+		},
+		{
+			name: "close-immediately policy",
+			pr:   testPR("alice", old),
+			rules: func(r *evaluationRules) {
+				r.Policies = []authorPolicy{{Pattern: "alice", Policy: policyCloseImmediately}}
+			},
+			want: actionCloseNow,
+			rule: ruleLifecycle,
+		},
+		{
+			name: "policy does not apply to security updates",
+			pr:   testPR("dependabot[bot]", old, "security"),
+			rules: func(r *evaluationRules) {
+				r.Policies = []authorPolicy{{Pattern: "*", Policy: policySkip}}
+			},
+			want: actionEscalate,
+			rule: ruleSecurityUpdate,
+		},
+		{
+			name: "parked",
+			pr: func() *github.PullRequest {
+				pr := testPR("alice", old)
+				pr.Milestone = &github.Milestone{Title: github.Ptr("Parked – stale")}
+				return pr
+			}(),
+			rules: func(r *evaluationRules) {
+				r.ParkedMilestone = "Parked – stale"
+			},
+			want: actionParked,
+			rule: ruleParked,
+		},
+		{
+			name:    "unanswered question",
+			pr:      testPR("alice", old),
+			signals: prSignals{Question: &question{At: old}},
+			want:    actionQuestion,
+			rule:    ruleQuestion,
+		},
+		{
+			name:    "unanswered question enforced",
+			pr:      testPR("alice", old),
+			signals: prSignals{Question: &question{At: old}},
+			rules: func(r *evaluationRules) {
+				r.CloseWaitingOnMaintainer = true
+			},
+			want: actionWarn,
+			rule: ruleLifecycle,
+		},
+		{
+			name:    "reopened within the grace period",
+			pr:      testPR("alice", old),
+			signals: prSignals{ReopenedAt: now.AddDate(0, 0, -3)},
+			rules: func(r *evaluationRules) {
+				r.ReopenGraceDays = 14
+			},
+			want: actionActive,
+			rule: ruleReopenGrace,
+		},
+		{
+			name:    "failing checks make an active PR stale",
+			pr:      testPR("alice", now.AddDate(0, 0, -1)),
+			signals: prSignals{Checks: &checkStatus{Failing: []string{"ci"}, FailingSince: now.AddDate(0, 0, -20)}},
+			rules: func(r *evaluationRules) {
+				r.FailingChecksStaleAfter = 14 * 24 * time.Hour
+			},
+			want: actionWarn,
+			rule: ruleLifecycle,
+		},
+		{
+			name:    "checks failing for a short while",
+			pr:      testPR("alice", now.AddDate(0, 0, -1)),
+			signals: prSignals{Checks: &checkStatus{Failing: []string{"ci"}, FailingSince: now.AddDate(0, 0, -2)}},
+			rules: func(r *evaluationRules) {
+				r.FailingChecksStaleAfter = 14 * 24 * time.Hour
+			},
+			want: actionActive,
+			rule: ruleActivity,
+		},
+		{
+			name:    "waiting on review",
+			pr:      testPR("alice", old),
+			signals: prSignals{WaitingOnReview: "re-requested review"},
+			rules: func(r *evaluationRules) {
+				r.ExemptWaitingOnReview = true
+			},
+			want: actionExempt,
+			rule: ruleWaitingOnReview,
+		},
+		{
+			name:    "activity signal overrides the update time",
+			pr:      testPR("alice", now),
+			signals: prSignals{Activity: &activity{At: old, Source: "last commit"}},
+			want:    actionWarn,
+			rule:    ruleLifecycle,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rules := testRules()
+			if tc.rules != nil {
+				tc.rules(&rules)
+			}
+			d := evaluatePR(tc.pr, tc.signals, rules, now)
+			if d.Action != tc.want || d.Rule != tc.rule {
+				t.Errorf("decided %s by the '%s' rule, want %s by '%s'; trace:\n%v", d.Action, d.Rule, tc.want, tc.rule, d.Trace)
+			}
+		})
+	}
+}
+
+// TestExemptLabels decides stale PRs carrying several exempt labels at once:
+// the first configured label the PR has exempts it, whatever the case of
+// the PR's labels and their order.
+func TestExemptLabels(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	old := now.AddDate(0, 0, -45)
+	rules := testRules()
+	rules.ExemptLabels = []string{"do not stale", "pinned"}
+	for _, tc := range []struct {
+		labels []string
+		want   string
+	}{
+		{labels: []string{"pinned", "do not stale"}, want: "do not stale"},
+		{labels: []string{"bug", "Pinned"}, want: "pinned"},
+		{labels: []string{"stale-warning", "DO NOT STALE", "pinned"}, want: "do not stale"},
+		{labels: []string{"bug", "stale-warning"}, want: ""},
+	} {
+		d := evaluatePR(testPR("alice", old, tc.labels...), prSignals{}, rules, now)
+		if d.ExemptLabel != tc.want || (d.Action == actionExempt) != (tc.want != "") {
+			t.Errorf("labels %q: decided %s, exempt label %q, want exempt by %q", tc.labels, d.Action, d.ExemptLabel, tc.want)
+		}
+	}
+
+	fs := flag.NewFlagSet("stale-pr-bot", flag.ContinueOnError)
+	if got := *defineFlags(fs).exemptLabels; fmt.Sprint(splitList(got)) != "[do not stale pinned]" {
+		t.Errorf("--exempt-labels defaults to %q, want 'do not stale' and 'pinned'", got)
+	}
+}
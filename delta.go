@@ -0,0 +1,110 @@
+package main
+
+import (
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// isStaleAction reports whether an action classifies a PR as stale.
+func isStaleAction(action string) bool {
+	switch action {
+	case actionWarn, actionWait, actionClose, actionCloseNow, actionAway, actionParked, actionQuestion, actionEscalate:
+		return true
+	}
+	return false
+}
+
+// runDelta is how this run's classification of PRs differs from the
+// previous run's, by PR number.
+type runDelta struct {
+	// FirstRun is set when no previous classification is recorded.
+	FirstRun bool `json:"first_run,omitempty"`
+	// NewlyStale are PRs that were not stale, or not yet seen, before.
+	NewlyStale []int `json:"newly_stale,omitempty"`
+	// NewlyActive are stale PRs that became active before being warned.
+	NewlyActive []int `json:"newly_active,omitempty"`
+	// NewlyWarned are PRs warned this run.
+	NewlyWarned []int `json:"newly_warned,omitempty"`
+	// Closed are PRs that crossed into closure this run.
+	Closed []int `json:"closed,omitempty"`
+	// Resurrected are warned PRs that became active again.
+	Resurrected []int `json:"resurrected,omitempty"`
+}
+
+// add records the transition of a PR from its previous classification, ""
+// if it had none, to the current one.
+func (d *runDelta) add(number int, prev, cur string) {
+	if isStaleAction(cur) && !isStaleAction(prev) {
+		d.NewlyStale = append(d.NewlyStale, number)
+	}
+	switch {
+	case cur == actionWarn:
+		d.NewlyWarned = append(d.NewlyWarned, number)
+	case cur == actionClose || cur == actionCloseNow:
+		d.Closed = append(d.Closed, number)
+	case isStaleAction(prev) && !isStaleAction(cur):
+		if prev == actionWarn || prev == actionWait {
+			d.Resurrected = append(d.Resurrected, number)
+		} else {
+			d.NewlyActive = append(d.NewlyActive, number)
+		}
+	}
+}
+
+// classify records the action decided for pr in the snapshot and the delta.
+// The first run with no snapshot records without reporting transitions.
+func (rs *repoState) classify(delta *runDelta, pr *github.PullRequest, action string) {
+	prev := rs.Classifications[pr.GetNumber()]
+	if !delta.FirstRun {
+		delta.add(pr.GetNumber(), prev, action)
+	}
+	rs.recordTransition(pr.GetUser().GetLogin(), pr.GetNumber(), prev, action)
+	rs.Classifications[pr.GetNumber()] = action
+	rs.PRAuthors[pr.GetNumber()] = pr.GetUser().GetLogin()
+}
+
+// finishClassification completes the snapshot at the end of a run. After a
+// full scan, PRs that are no longer open are dropped from it.
+func (rs *repoState) finishClassification(openPRs []*github.PullRequest, fullScan bool, now time.Time) {
+	if fullScan {
+		open := map[int]bool{}
+		for _, pr := range openPRs {
+			open[pr.GetNumber()] = true
+		}
+		for number := range rs.Classifications {
+			if !open[number] {
+				delete(rs.Classifications, number)
+			}
+		}
+		for number := range rs.PRAuthors {
+			if !open[number] {
+				delete(rs.PRAuthors, number)
+			}
+		}
+	}
+	rs.ClassifiedAt = now
+}
+
+// logDelta logs the changes since the previous run.
+func logDelta(logger *slog.Logger, d *runDelta) {
+	if d.FirstRun {
+		logger.Info("no changes recorded since a previous run; this run is the baseline")
+		return
+	}
+	logger.Info("changes since the previous run",
+		"newly_stale", sortedPRs(d.NewlyStale),
+		"newly_warned", sortedPRs(d.NewlyWarned),
+		"closed", sortedPRs(d.Closed),
+		"resurrected", sortedPRs(d.Resurrected),
+		"newly_active", sortedPRs(d.NewlyActive))
+}
+
+// sortedPRs returns PR numbers sorted, never nil.
+func sortedPRs(numbers []int) []int {
+	sorted := append([]int{}, numbers...)
+	sort.Ints(sorted)
+	return sorted
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// TestRunDeltaTransitions checks where each transition from the previous
+// classification of a PR is reported.
+func TestRunDeltaTransitions(t *testing.T) {
+	for _, tc := range []struct {
+		prev, cur string
+		want      runDelta
+	}{
+		{prev: "", cur: actionActive},
+		{prev: actionActive, cur: actionActive},
+		{prev: "", cur: actionWarn, want: runDelta{NewlyStale: []int{7}, NewlyWarned: []int{7}}},
+		{prev: actionActive, cur: actionWarn, want: runDelta{NewlyStale: []int{7}, NewlyWarned: []int{7}}},
+		{prev: actionExempt, cur: actionEscalate, want: runDelta{NewlyStale: []int{7}}},
+		{prev: actionActive, cur: actionCloseNow, want: runDelta{NewlyStale: []int{7}, Closed: []int{7}}},
+		{prev: actionWarn, cur: actionWait},
+		{prev: actionWait, cur: actionClose, want: runDelta{Closed: []int{7}}},
+		{prev: actionWarn, cur: actionActive, want: runDelta{Resurrected: []int{7}}},
+		{prev: actionWait, cur: actionExempt, want: runDelta{Resurrected: []int{7}}},
+		{prev: actionEscalate, cur: actionActive, want: runDelta{NewlyActive: []int{7}}},
+		{prev: actionAway, cur: actionActive, want: runDelta{NewlyActive: []int{7}}},
+		{prev: actionAway, cur: actionWarn, want: runDelta{NewlyWarned: []int{7}}},
+	} {
+		t.Run(fmt.Sprintf("%s to %s", tc.prev, tc.cur), func(t *testing.T) {
+			var got runDelta
+			got.add(7, tc.prev, tc.cur)
+			if fmt.Sprintf("%+v", got) != fmt.Sprintf("%+v", tc.want) {
+				t.Errorf("delta %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestClassifyAcrossRuns classifies PRs over two runs: the first, with no
+// snapshot, is the baseline, and the second reports the transitions since.
+func TestClassifyAcrossRuns(t *testing.T) {
+	rs := newBotState().repo("acme", "api")
+	pr := func(number int) *github.PullRequest {
+		pr := testPR("alice", time.Now())
+		pr.Number = github.Ptr(number)
+		return pr
+	}
+	first := &runDelta{FirstRun: rs.ClassifiedAt.IsZero()}
+	if !first.FirstRun {
+		t.Fatal("a new state is not a first run")
+	}
+	for number, action := range map[int]string{1: actionActive, 2: actionWarn, 3: actionWait, 4: actionActive} {
+		rs.classify(first, pr(number), action)
+	}
+	if fmt.Sprintf("%+v", *first) != fmt.Sprintf("%+v", runDelta{FirstRun: true}) {
+		t.Errorf("the first run reported %+v, want no transitions", *first)
+	}
+	// PR #4 was merged.
+	rs.finishClassification([]*github.PullRequest{pr(1), pr(2), pr(3)}, true, time.Now())
+
+	second := &runDelta{FirstRun: rs.ClassifiedAt.IsZero()}
+	for number, action := range map[int]string{1: actionWarn, 2: actionActive, 3: actionClose, 4: actionActive, 5: actionWarn} {
+		rs.classify(second, pr(number), action)
+	}
+	want := runDelta{NewlyStale: []int{1, 5}, NewlyWarned: []int{1, 5}, Closed: []int{3}, Resurrected: []int{2}}
+	got := runDelta{
+		NewlyStale:  sortedPRs(second.NewlyStale),
+		NewlyWarned: sortedPRs(second.NewlyWarned),
+		Closed:      sortedPRs(second.Closed),
+		Resurrected: sortedPRs(second.Resurrected),
+	}
+	if second.FirstRun || len(second.NewlyActive) != 0 || fmt.Sprintf("%+v", got) != fmt.Sprintf("%+v", want) {
+		t.Errorf("the second run reported %+v, want %+v", *second, want)
+	}
+}
+
+// TestFinishClassification drops the PRs no longer open after a full scan
+// only.
+func TestFinishClassification(t *testing.T) {
+	rs := newBotState().repo("acme", "api")
+	open := testPR("alice", time.Now())
+	merged := testPR("bob", time.Now())
+	merged.Number = github.Ptr(43)
+	var delta runDelta
+	rs.classify(&delta, open, actionActive)
+	rs.classify(&delta, merged, actionWarn)
+
+	now := time.Now()
+	rs.finishClassification([]*github.PullRequest{open}, false, now)
+	if len(rs.Classifications) != 2 || !rs.ClassifiedAt.Equal(now) {
+		t.Errorf("after an incremental scan the snapshot is %v at %s, want both PRs at %s", rs.Classifications, rs.ClassifiedAt, now)
+	}
+	rs.finishClassification([]*github.PullRequest{open}, true, now)
+	if fmt.Sprint(rs.Classifications) != "map[42:active]" || fmt.Sprint(rs.PRAuthors) != "map[42:alice]" {
+		t.Errorf("after a full scan the snapshot is %v by %v, want only PR #42", rs.Classifications, rs.PRAuthors)
+	}
+}
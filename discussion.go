@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+const (
+	discussionModePerRun        = "per-run"
+	discussionModeMonthlyRollup = "monthly-rollup"
+)
+
+// graphQLEndpoint derives the GraphQL endpoint from the REST API base URL.
+// GitHub.com serves it at https://api.github.com/graphql and GitHub Enterprise
+// at https://<host>/api/graphql.
+func graphQLEndpoint(baseURL string) string {
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	if strings.HasSuffix(baseURL, "/api/v3/") {
+		return strings.TrimSuffix(baseURL, "v3/") + "graphql"
+	}
+	return baseURL + "graphql"
+}
+
+// githubGraphQL runs a GraphQL query with the authenticated client and decodes
+// the "data" field of the response into out.
+func githubGraphQL(client *github.Client, baseURL, query string, variables map[string]interface{}, out interface{}) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode GraphQL request: %v", err)
+	}
+
+	ctx := context.Background()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, graphQLEndpoint(baseURL), bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create GraphQL request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Client().Do(req)
+	if err != nil {
+		return fmt.Errorf("GraphQL request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GraphQL request failed with status %s", resp.Status)
+	}
+
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode GraphQL response: %v", err)
+	}
+	if len(result.Errors) > 0 {
+		messages := make([]string, 0, len(result.Errors))
+		for _, e := range result.Errors {
+			messages = append(messages, e.Message)
+		}
+		return fmt.Errorf("GraphQL error: %s", strings.Join(messages, "; "))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(result.Data, out); err != nil {
+		return fmt.Errorf("failed to decode GraphQL data: %v", err)
+	}
+	return nil
+}
+
+// postDiscussionSummary publishes the PRs warned and closed during the run to
+// a GitHub Discussion. In per-run mode every run creates a new discussion; in
+// monthly-rollup mode the run is appended as a comment to the current month's
+// discussion, which is created on first use. now dates the post.
+func postDiscussionSummary(client *github.Client, tmpl *templateRenderer, baseURL, owner, repo, category, mode string, summary *runSummary, now time.Time) error {
+	if len(summary.Warned) == 0 && len(summary.Closed) == 0 {
+		slog.Info("no PRs were warned or closed; skipping discussion post")
+		return nil
+	}
+
+	var repoInfo struct {
+		Repository struct {
+			ID                    string `json:"id"`
+			HasDiscussionsEnabled bool   `json:"hasDiscussionsEnabled"`
+			DiscussionCategories  struct {
+				Nodes []struct {
+					ID   string `json:"id"`
+					Name string `json:"name"`
+				} `json:"nodes"`
+			} `json:"discussionCategories"`
+		} `json:"repository"`
+	}
+	err := githubGraphQL(client, baseURL, `query($owner: String!, $name: String!) {
+  repository(owner: $owner, name: $name) {
+    id
+    hasDiscussionsEnabled
+    discussionCategories(first: 100) { nodes { id name } }
+  }
+}`, map[string]interface{}{"owner": owner, "name": repo}, &repoInfo)
+	if err != nil {
+		return fmt.Errorf("failed to look up repository discussions: %v", err)
+	}
+	if !repoInfo.Repository.HasDiscussionsEnabled {
+		slog.Warn("discussions are not enabled; skipping discussion post", "repo", owner+"/"+repo)
+		return nil
+	}
+
+	categoryID := ""
+	for _, c := range repoInfo.Repository.DiscussionCategories.Nodes {
+		if strings.EqualFold(c.Name, category) {
+			categoryID = c.ID
+			break
+		}
+	}
+	if categoryID == "" {
+		slog.Warn("discussion category not found; skipping discussion post", "repo", owner+"/"+repo, "category", category)
+		return nil
+	}
+
+	body, err := renderDiscussionSummary(tmpl, summary, now)
+	if err != nil {
+		return err
+	}
+
+	if mode == discussionModeMonthlyRollup {
+		title := fmt.Sprintf("Stale PR bot report: %s", now.Format("January 2006"))
+		monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		discussionID, discussionURL, err := findDiscussion(client, baseURL, owner, repo, categoryID, title, monthStart)
+		if err != nil {
+			return err
+		}
+		if discussionID != "" {
+			var added struct {
+				AddDiscussionComment struct {
+					Comment struct {
+						URL string `json:"url"`
+					} `json:"comment"`
+				} `json:"addDiscussionComment"`
+			}
+			err = githubGraphQL(client, baseURL, `mutation($discussionId: ID!, $body: String!) {
+  addDiscussionComment(input: {discussionId: $discussionId, body: $body}) { comment { url } }
+}`, map[string]interface{}{"discussionId": discussionID, "body": body}, &added)
+			if err != nil {
+				return fmt.Errorf("failed to comment on discussion %s: %v", discussionURL, err)
+			}
+			slog.Info("appended run summary to discussion", "repo", owner+"/"+repo, "url", added.AddDiscussionComment.Comment.URL)
+			return nil
+		}
+		return createDiscussion(client, baseURL, repoInfo.Repository.ID, categoryID, title, body)
+	}
+
+	title := fmt.Sprintf("Stale PR bot run: %s", now.Format("2006-01-02 15:04 MST"))
+	return createDiscussion(client, baseURL, repoInfo.Repository.ID, categoryID, title, body)
+}
+
+// findDiscussion returns the ID and URL of the most recent discussion in the
+// category with the given title created since the given time, or empty
+// strings if there is none. Discussions are listed newest first, page by
+// page, until one older than since is seen.
+func findDiscussion(client *github.Client, baseURL, owner, repo, categoryID, title string, since time.Time) (string, string, error) {
+	var cursor *string
+	for {
+		var found struct {
+			Repository struct {
+				Discussions struct {
+					Nodes []struct {
+						ID        string    `json:"id"`
+						Title     string    `json:"title"`
+						URL       string    `json:"url"`
+						CreatedAt time.Time `json:"createdAt"`
+					} `json:"nodes"`
+					PageInfo struct {
+						HasNextPage bool   `json:"hasNextPage"`
+						EndCursor   string `json:"endCursor"`
+					} `json:"pageInfo"`
+				} `json:"discussions"`
+			} `json:"repository"`
+		}
+		err := githubGraphQL(client, baseURL, `query($owner: String!, $name: String!, $categoryId: ID!, $cursor: String) {
+  repository(owner: $owner, name: $name) {
+    discussions(first: 50, after: $cursor, categoryId: $categoryId, orderBy: {field: CREATED_AT, direction: DESC}) {
+      nodes { id title url createdAt }
+      pageInfo { hasNextPage endCursor }
+    }
+  }
+}`, map[string]interface{}{"owner": owner, "name": repo, "categoryId": categoryID, "cursor": cursor}, &found)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to list discussions: %v", err)
+		}
+		discussions := found.Repository.Discussions
+		for _, d := range discussions.Nodes {
+			if d.CreatedAt.Before(since) {
+				return "", "", nil
+			}
+			if d.Title == title {
+				return d.ID, d.URL, nil
+			}
+		}
+		if !discussions.PageInfo.HasNextPage {
+			return "", "", nil
+		}
+		cursor = &discussions.PageInfo.EndCursor
+	}
+}
+
+func createDiscussion(client *github.Client, baseURL, repositoryID, categoryID, title, body string) error {
+	var created struct {
+		CreateDiscussion struct {
+			Discussion struct {
+				URL string `json:"url"`
+			} `json:"discussion"`
+		} `json:"createDiscussion"`
+	}
+	err := githubGraphQL(client, baseURL, `mutation($repositoryId: ID!, $categoryId: ID!, $title: String!, $body: String!) {
+  createDiscussion(input: {repositoryId: $repositoryId, categoryId: $categoryId, title: $title, body: $body}) { discussion { url } }
+}`, map[string]interface{}{
+		"repositoryId": repositoryID,
+		"categoryId":   categoryID,
+		"title":        title,
+		"body":         body,
+	}, &created)
+	if err != nil {
+		return fmt.Errorf("failed to create discussion: %v", err)
+	}
+	slog.Info("created discussion", "url", created.CreateDiscussion.Discussion.URL)
+	return nil
+}
+
+// renderDiscussionSummary renders the run summary as Markdown.
+func renderDiscussionSummary(tmpl *templateRenderer, summary *runSummary, now time.Time) (string, error) {
+	return tmpl.render("discussion summary", discussionSummaryTemplate, struct {
+		Now         time.Time
+		Softened    string
+		ParkedIn    string
+		Unprocessed int
+		Closed      []*github.PullRequest
+		Warned      []*github.PullRequest
+		Duplicates  []duplicateGroup
+	}{now, summary.Softened, summary.ParkedIn, summary.Unprocessed, summary.Closed, summary.Warned, summary.Duplicates})
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// graphQLCall is a GraphQL request received by the recorded-response server.
+type graphQLCall struct {
+	// Op names the call: "repository", "discussions" with the page cursor,
+	// "addDiscussionComment" or "createDiscussion".
+	Op        string
+	Variables map[string]interface{}
+}
+
+// startRecordedGraphQL serves the GraphQL calls made for discussions from the
+// recorded responses in testdata/graphql. pages maps page cursors, "" for the
+// first page, to the discussions fixture to serve.
+func startRecordedGraphQL(t *testing.T, repository string, pages map[string]string, create string) (*github.Client, func() []graphQLCall) {
+	t.Helper()
+	var mu sync.Mutex
+	var calls []graphQLCall
+	client, _ := newTestGitHubClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/graphql" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding GraphQL request: %v", err)
+		}
+		call := graphQLCall{Variables: req.Variables}
+		var fixture string
+		switch {
+		case strings.Contains(req.Query, "discussionCategories"):
+			call.Op, fixture = "repository", repository
+		case strings.Contains(req.Query, "discussions("):
+			cursor, _ := req.Variables["cursor"].(string)
+			call.Op, fixture = "discussions "+cursor, pages[cursor]
+		case strings.Contains(req.Query, "addDiscussionComment"):
+			call.Op, fixture = "addDiscussionComment", "add-discussion-comment.json"
+		case strings.Contains(req.Query, "createDiscussion"):
+			call.Op, fixture = "createDiscussion", create
+		}
+		mu.Lock()
+		calls = append(calls, call)
+		mu.Unlock()
+		data, err := os.ReadFile(filepath.Join("testdata", "graphql", fixture))
+		if fixture == "" || err != nil {
+			t.Errorf("no recorded response for %s: %v", call.Op, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+	return client, func() []graphQLCall {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]graphQLCall(nil), calls...)
+	}
+}
+
+func TestPostDiscussionSummary(t *testing.T) {
+	const page2 = "Y3Vyc29yOnYyOpK5MjAyNi0wMy0xMlQxNjo0MTowNVrOAAAB2g=="
+	now := time.Date(2026, 3, 20, 3, 0, 0, 0, time.UTC)
+	closed := testPR("alice", now.AddDate(0, 0, -70))
+	closed.Number = github.Ptr(7)
+	closed.Title = github.Ptr("Add retries")
+	summary := &runSummary{Closed: []*github.PullRequest{closed}}
+
+	for _, tc := range []struct {
+		name       string
+		mode       string
+		category   string
+		repository string
+		pages      map[string]string
+		create     string
+		summary    *runSummary
+		// wantCalls lists the calls made, in order; wantVariables are
+		// variables of the last call.
+		wantCalls     []string
+		wantVariables map[string]interface{}
+		wantErr       string
+	}{
+		{
+			name:          "per run",
+			mode:          discussionModePerRun,
+			wantCalls:     []string{"repository", "createDiscussion"},
+			wantVariables: map[string]interface{}{"repositoryId": "R_kgDOHacme", "categoryId": "DIC_kwDOHacme4CAAA2", "title": "Stale PR bot run: 2026-03-20 03:00 UTC"},
+		},
+		{
+			name:          "monthly rollup appends to the discussion on the second page",
+			mode:          discussionModeMonthlyRollup,
+			pages:         map[string]string{"": "discussions-page-1.json", page2: "discussions-page-2.json"},
+			wantCalls:     []string{"repository", "discussions ", "discussions " + page2, "addDiscussionComment"},
+			wantVariables: map[string]interface{}{"discussionId": "D_kwDOHacme4AAAB1"},
+		},
+		{
+			name:          "monthly rollup starts the month's discussion",
+			mode:          discussionModeMonthlyRollup,
+			pages:         map[string]string{"": "discussions-last-month.json"},
+			wantCalls:     []string{"repository", "discussions ", "createDiscussion"},
+			wantVariables: map[string]interface{}{"repositoryId": "R_kgDOHacme", "categoryId": "DIC_kwDOHacme4CAAA2", "title": "Stale PR bot report: March 2026"},
+		},
+		{
+			name:       "discussions disabled",
+			mode:       discussionModePerRun,
+			repository: "repository-discussions-disabled.json",
+			wantCalls:  []string{"repository"},
+		},
+		{
+			name:      "unknown category",
+			mode:      discussionModePerRun,
+			category:  "Announcements",
+			wantCalls: []string{"repository"},
+		},
+		{
+			name:    "nothing to post",
+			mode:    discussionModePerRun,
+			summary: &runSummary{},
+		},
+		{
+			name:      "GraphQL error",
+			mode:      discussionModePerRun,
+			create:    "forbidden.json",
+			wantCalls: []string{"repository", "createDiscussion"},
+			wantErr:   "GraphQL error: Resource not accessible by integration",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.category == "" {
+				tc.category = "bot reports"
+			}
+			if tc.repository == "" {
+				tc.repository = "repository.json"
+			}
+			if tc.create == "" {
+				tc.create = "create-discussion.json"
+			}
+			if tc.summary == nil {
+				tc.summary = summary
+			}
+			client, calls := startRecordedGraphQL(t, tc.repository, tc.pages, tc.create)
+			tmpl := newTemplateRenderer(&config{DisplayLocation: time.UTC})
+			err := postDiscussionSummary(client, tmpl, client.BaseURL.String(), "acme", "api", tc.category, tc.mode, tc.summary, now)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Fatalf("postDiscussionSummary returned %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Fatalf("postDiscussionSummary returned %v, want an error containing %q", err, tc.wantErr)
+			}
+
+			got := calls()
+			var ops []string
+			for _, c := range got {
+				ops = append(ops, c.Op)
+			}
+			if strings.Join(ops, ", ") != strings.Join(tc.wantCalls, ", ") {
+				t.Fatalf("calls %q, want %q", ops, tc.wantCalls)
+			}
+			if len(got) == 0 {
+				return
+			}
+			last := got[len(got)-1].Variables
+			for name, want := range tc.wantVariables {
+				if last[name] != want {
+					t.Errorf("%s = %v, want %v", name, last[name], want)
+				}
+			}
+			if body, ok := last["body"].(string); ok && !strings.Contains(body, "- #7 Add retries (@alice)") {
+				t.Errorf("posted body %q, want the closed PR listed", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// dispatchEventType is the event_type of the repository dispatch events the
+// bot sends, for workflows to select with on: repository_dispatch.
+const dispatchEventType = "stale-pr-bot"
+
+// dispatchPayload is the client_payload of a repository dispatch event.
+type dispatchPayload struct {
+	Action string `json:"action"`
+	PR     int    `json:"pr"`
+	RunID  string `json:"run_id"`
+	// Event is the full bot event, in the --output ndjson schema.
+	Event botEvent `json:"event"`
+}
+
+// dispatcher sends a repository dispatch event after each bot action, at most
+// max per run. Failures are counted but never stop the run. A nil dispatcher
+// sends nothing.
+type dispatcher struct {
+	client *github.Client
+	owner  string
+	repo   string
+	runID  string
+	max    int
+	budget *runBudget
+
+	sent    int
+	failed  int
+	skipped int
+}
+
+// send dispatches ev, a warned or closed event.
+func (d *dispatcher) send(out *prOutput, ev botEvent) {
+	if d == nil {
+		return
+	}
+	if d.max > 0 && d.sent >= d.max {
+		d.skipped++
+		return
+	}
+	if reason := d.budget.exhausted(false); reason != "" {
+		d.skipped++
+		return
+	}
+	ev.Version = eventSchemaVersion
+	payload, err := json.Marshal(dispatchPayload{Action: ev.Type, PR: ev.PR, RunID: d.runID, Event: ev})
+	if err != nil {
+		out.Error("encoding dispatch payload failed", "action", "dispatch", "err", err)
+		d.failed++
+		return
+	}
+	raw := json.RawMessage(payload)
+	_, _, err = d.client.Repositories.Dispatch(context.Background(), d.owner, d.repo, github.DispatchRequestOptions{
+		EventType:     dispatchEventType,
+		ClientPayload: &raw,
+	})
+	switch {
+	case errors.Is(err, errReadOnly):
+		d.skipped++
+	case err != nil:
+		out.Error("sending repository dispatch failed", "action", "dispatch", "err", err)
+		d.failed++
+	default:
+		d.sent++
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDispatchPayload checks the shape of the repository dispatch events
+// sent for warned and closed PRs.
+func TestDispatchPayload(t *testing.T) {
+	gh := &fakeGitHub{}
+	client, _ := newTestGitHubClient(t, gh)
+	d := &dispatcher{client: client, owner: "acme", repo: "api", runID: "8123456789", budget: newRunBudget(0, 0)}
+	pr := testPR("alice", time.Now())
+	d.send(&prOutput{}, newPREvent(eventWarned, "acme/api", pr))
+	closed := newPREvent(eventClosed, "acme/api", pr)
+	closed.CloseReason = closeAuthorUnresponsive
+	d.send(&prOutput{}, closed)
+
+	if d.sent != 2 || d.failed != 0 || d.skipped != 0 {
+		t.Fatalf("sent %d, failed %d and skipped %d, want 2 sent", d.sent, d.failed, d.skipped)
+	}
+	for i, w := range gh.writes {
+		path, body, _ := strings.Cut(strings.TrimPrefix(w, "POST "), " ")
+		if path != "/repos/acme/api/dispatches" {
+			t.Errorf("dispatch %d sent to %s", i, path)
+		}
+		var got struct {
+			EventType     string `json:"event_type"`
+			ClientPayload struct {
+				Action string `json:"action"`
+				PR     int    `json:"pr"`
+				RunID  string `json:"run_id"`
+				Event  struct {
+					Version     int    `json:"version"`
+					Type        string `json:"type"`
+					Repo        string `json:"repo"`
+					PR          int    `json:"pr"`
+					Author      string `json:"author"`
+					CloseReason string `json:"close_reason"`
+				} `json:"event"`
+			} `json:"client_payload"`
+		}
+		if err := json.Unmarshal([]byte(body), &got); err != nil {
+			t.Fatalf("dispatch %d: %v: %s", i, err, body)
+		}
+		want := []string{eventWarned, eventClosed}[i]
+		p := got.ClientPayload
+		if got.EventType != "stale-pr-bot" || p.Action != want || p.PR != 42 || p.RunID != "8123456789" {
+			t.Errorf("dispatch %d = %s, want a %q event for PR #42 of run 8123456789", i, body, want)
+		}
+		if e := p.Event; e.Version != eventSchemaVersion || e.Type != want || e.Repo != "acme/api" || e.PR != 42 || e.Author != "alice" {
+			t.Errorf("dispatch %d carries the event %+v, want the full %q event", i, e, want)
+		}
+		if i == 1 && p.Event.CloseReason != closeAuthorUnresponsive {
+			t.Errorf("the closed event has close reason %q, want %q", p.Event.CloseReason, closeAuthorUnresponsive)
+		}
+	}
+}
+
+// TestDispatchLimits checks that dispatches stop at the per-run cap, are
+// suppressed in dry runs, and that failures are counted without stopping the
+// run.
+func TestDispatchLimits(t *testing.T) {
+	ev := newPREvent(eventWarned, "acme/api", testPR("alice", time.Now()))
+
+	t.Run("cap", func(t *testing.T) {
+		gh := &fakeGitHub{}
+		client, _ := newTestGitHubClient(t, gh)
+		d := &dispatcher{client: client, owner: "acme", repo: "api", max: 2, budget: newRunBudget(0, 0)}
+		for i := 0; i < 5; i++ {
+			d.send(&prOutput{}, ev)
+		}
+		if d.sent != 2 || d.skipped != 3 || len(gh.written()) != 2 {
+			t.Errorf("sent %d (%d requests) and skipped %d, want 2 sent and 3 skipped", d.sent, len(gh.written()), d.skipped)
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		gh := &fakeGitHub{}
+		_, srv := newTestGitHubClient(t, gh)
+		client, err := getGithubClient("token", srv.URL+"/", "", "", sshProxyOptions{}, newRunBudget(0, 0), 0, newRateLimitWait(0), newWriteGuard(false, true), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := &dispatcher{client: client, owner: "acme", repo: "api", budget: newRunBudget(0, 0)}
+		d.send(&prOutput{}, ev)
+		if d.sent != 0 || d.skipped != 1 || d.failed != 0 || len(gh.written()) != 0 {
+			t.Errorf("sent %d (%d requests), skipped %d and failed %d in a dry run, want 1 skipped", d.sent, len(gh.written()), d.skipped, d.failed)
+		}
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		client, _ := newTestGitHubClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"message":"Resource not accessible by integration"}`, http.StatusForbidden)
+		}))
+		d := &dispatcher{client: client, owner: "acme", repo: "api", budget: newRunBudget(0, 0)}
+		out := &prOutput{}
+		d.send(out, ev)
+		d.send(out, ev)
+		if d.failed != 2 || d.sent != 0 {
+			t.Errorf("sent %d and failed %d, want 2 failed", d.sent, d.failed)
+		}
+		if len(out.errors) != 2 || !strings.HasPrefix(out.errors[0], "sending repository dispatch failed") {
+			t.Errorf("errors %q, want each failure reported", out.errors)
+		}
+	})
+}
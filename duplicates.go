@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// defaultDuplicateSimilarity is the Jaccard similarity of their changed
+// files above which two stale PRs by the same author are taken to be the
+// same change.
+const defaultDuplicateSimilarity = 0.8
+
+// duplicateGroup is a set of stale PRs by one author that change largely the
+// same files.
+type duplicateGroup struct {
+	Author string
+	// Numbers are the PRs of the group, oldest first; the last is the
+	// newest, which the others are pointed to.
+	Numbers []int
+}
+
+// Newest returns the number of the group's newest PR.
+func (g duplicateGroup) Newest() int {
+	return g.Numbers[len(g.Numbers)-1]
+}
+
+func (g duplicateGroup) String() string {
+	prs := make([]string, len(g.Numbers))
+	for i, n := range g.Numbers {
+		prs[i] = fmt.Sprintf("#%d", n)
+	}
+	return fmt.Sprintf("@%s: %s", g.Author, strings.Join(prs, ", "))
+}
+
+// jaccard returns the Jaccard similarity of two sets of paths: the size of
+// their intersection over the size of their union. Two empty sets are not
+// similar.
+func jaccard(a, b []string) float64 {
+	set := map[string]bool{}
+	for _, p := range a {
+		set[p] = true
+	}
+	inA := len(set)
+	shared := 0
+	seen := map[string]bool{}
+	for _, p := range b {
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		if set[p] {
+			shared++
+		}
+	}
+	union := inA + len(seen) - shared
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
+
+// duplicateCandidates returns the stale PRs worth comparing: those whose
+// author has at least one other stale PR. Only they need their files listed.
+func duplicateCandidates(stale []*github.PullRequest) []*github.PullRequest {
+	byAuthor := map[string]int{}
+	for _, pr := range stale {
+		byAuthor[strings.ToLower(pr.GetUser().GetLogin())]++
+	}
+	var candidates []*github.PullRequest
+	for _, pr := range stale {
+		if byAuthor[strings.ToLower(pr.GetUser().GetLogin())] > 1 {
+			candidates = append(candidates, pr)
+		}
+	}
+	return candidates
+}
+
+// findDuplicateGroups groups the candidates by author, joining two PRs when
+// the similarity of their files, from files by PR number, is at least
+// threshold. Groups are formed transitively and sorted by author and oldest
+// PR; PRs without a file list are left out.
+func findDuplicateGroups(candidates []*github.PullRequest, files map[int][]string, threshold float64) []duplicateGroup {
+	byAuthor := map[string][]*github.PullRequest{}
+	var authors []string
+	for _, pr := range candidates {
+		if _, ok := files[pr.GetNumber()]; !ok {
+			continue
+		}
+		login := strings.ToLower(pr.GetUser().GetLogin())
+		if _, ok := byAuthor[login]; !ok {
+			authors = append(authors, login)
+		}
+		byAuthor[login] = append(byAuthor[login], pr)
+	}
+	sort.Strings(authors)
+
+	var groups []duplicateGroup
+	for _, login := range authors {
+		prs := byAuthor[login]
+		sort.Slice(prs, func(i, j int) bool { return prs[i].GetNumber() < prs[j].GetNumber() })
+		// group[i] is the index of the first PR in PR i's group.
+		group := make([]int, len(prs))
+		for i := range group {
+			group[i] = i
+		}
+		root := func(i int) int {
+			for group[i] != i {
+				i = group[i]
+			}
+			return i
+		}
+		for i := range prs {
+			for j := i + 1; j < len(prs); j++ {
+				if jaccard(files[prs[i].GetNumber()], files[prs[j].GetNumber()]) >= threshold {
+					ri, rj := root(i), root(j)
+					if ri > rj {
+						ri, rj = rj, ri
+					}
+					group[rj] = ri
+				}
+			}
+		}
+		members := map[int][]int{}
+		var roots []int
+		for i, pr := range prs {
+			r := root(i)
+			if _, ok := members[r]; !ok {
+				roots = append(roots, r)
+			}
+			members[r] = append(members[r], pr.GetNumber())
+		}
+		for _, r := range roots {
+			if len(members[r]) > 1 {
+				groups = append(groups, duplicateGroup{Author: prs[r].GetUser().GetLogin(), Numbers: members[r]})
+			}
+		}
+	}
+	return groups
+}
+
+const duplicateCommentTemplate = `@{{.Author}} this pull request changes largely the same files as your newer #{{.Newest}}{{with .Others}} (as {{if gt (len .) 1}}do{{else}}does{{end}} {{range $i, $n := .}}{{if $i}}, {{end}}#{{$n}}{{end}}){{end}}, and {{if .Others}}none of them has{{else}}neither has{{end}} had any activity for a while. If they are the same change, please consolidate it into #{{.Newest}} and close this one.
+`
+
+// duplicateCommentData is the data passed to the duplicate comment template.
+type duplicateCommentData struct {
+	Author string
+	// Newest is the PR the older duplicates are pointed to, and Others the
+	// rest of the group besides the PR commented on.
+	Newest int
+	Others []int
+}
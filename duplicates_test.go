@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+func TestJaccard(t *testing.T) {
+	for _, tc := range []struct {
+		a, b []string
+		want float64
+	}{
+		{nil, nil, 0},
+		{[]string{"a.go"}, nil, 0},
+		{[]string{"a.go", "b.go"}, []string{"b.go", "a.go"}, 1},
+		{[]string{"a.go", "b.go"}, []string{"b.go", "c.go"}, 1.0 / 3},
+		{[]string{"a.go", "b.go", "c.go", "d.go", "e.go"}, []string{"a.go", "b.go", "c.go", "d.go"}, 0.8},
+		{[]string{"a.go"}, []string{"a.go", "a.go"}, 1},
+		{[]string{"a.go", "a.go", "b.go"}, []string{"a.go"}, 0.5},
+	} {
+		if got := jaccard(tc.a, tc.b); got != tc.want {
+			t.Errorf("jaccard(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+		if got := jaccard(tc.b, tc.a); got != tc.want {
+			t.Errorf("jaccard(%q, %q) = %v, want %v", tc.b, tc.a, got, tc.want)
+		}
+	}
+}
+
+func TestFindDuplicateGroups(t *testing.T) {
+	pr := func(number int, login string) *github.PullRequest {
+		p := testPR(login, time.Now())
+		p.Number = github.Ptr(number)
+		return p
+	}
+	stale := []*github.PullRequest{
+		pr(9, "alice"), pr(3, "alice"), pr(5, "Alice"), pr(7, "alice"),
+		pr(4, "bob"), pr(6, "bob"),
+		pr(8, "carol"),
+		pr(10, "dave"), pr(11, "dave"),
+	}
+	files := map[int][]string{
+		// 3 and 5 are alike, and so are 5 and 9 but not 3 and 9: all
+		// three are one group. 7 is a different change.
+		3: {"a", "b", "c", "d"},
+		5: {"a", "b", "c", "d", "e"},
+		9: {"b", "c", "d", "e"},
+		7: {"x"},
+		// Bob's PRs share too few files.
+		4: {"a", "b"},
+		6: {"b", "c"},
+		8: {"a", "b", "c", "d"},
+		// 11's files could not be listed.
+		10: {"a"},
+	}
+	candidates := duplicateCandidates(stale)
+	var numbers []int
+	for _, c := range candidates {
+		numbers = append(numbers, c.GetNumber())
+	}
+	if fmt.Sprint(numbers) != "[9 3 5 7 4 6 10 11]" {
+		t.Errorf("candidates %v, want every stale PR but carol's", numbers)
+	}
+	groups := findDuplicateGroups(candidates, files, defaultDuplicateSimilarity)
+	if got := fmt.Sprint(groups); got != "[@alice: #3, #5, #9]" {
+		t.Fatalf("groups %s, want [@alice: #3, #5, #9]", got)
+	}
+	if groups[0].Newest() != 9 {
+		t.Errorf("the newest PR of the group is #%d, want #9", groups[0].Newest())
+	}
+	if got := fmt.Sprint(findDuplicateGroups(candidates, files, 0.3)); got != "[@alice: #3, #5, #9 @bob: #4, #6]" {
+		t.Errorf("with a threshold of 0.3 the groups are %s, want [@alice: #3, #5, #9 @bob: #4, #6]", got)
+	}
+}
+
+func TestDuplicateComment(t *testing.T) {
+	r := newTemplateRenderer(&config{DisplayLocation: time.UTC})
+	for _, tc := range []struct {
+		data duplicateCommentData
+		want string
+	}{
+		{
+			duplicateCommentData{"alice", 9, nil},
+			"@alice this pull request changes largely the same files as your newer #9, and neither has had any activity for a while. If they are the same change, please consolidate it into #9 and close this one.\n",
+		},
+		{
+			duplicateCommentData{"alice", 9, []int{5}},
+			"@alice this pull request changes largely the same files as your newer #9 (as does #5), and none of them has had any activity for a while. If they are the same change, please consolidate it into #9 and close this one.\n",
+		},
+		{
+			duplicateCommentData{"alice", 9, []int{3, 5}},
+			"@alice this pull request changes largely the same files as your newer #9 (as do #3, #5), and none of them has had any activity for a while. If they are the same change, please consolidate it into #9 and close this one.\n",
+		},
+	} {
+		got, err := r.render("duplicate comment", duplicateCommentTemplate, tc.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("comment for %+v:\n%s\nwant:\n%s", tc.data, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"net/mail"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// emailMapWildcard is the --email-map entry giving the domain for users the
+// map does not list, in place of --email-domain.
+const emailMapWildcard = "*"
+
+// emailMap maps GitHub logins to the addresses to email them at, for
+// organizations whose handles do not match their mailbox names. It is read
+// from the YAML file of --email-map, a mapping of login to email:
+//
+//	octocat: mona.lisa@example.com
+//	"*": example.com
+//
+// Logins match case-insensitively.
+type emailMap struct {
+	byLogin map[string]string
+	// domain is the wildcard entry's domain, or "".
+	domain string
+}
+
+// loadEmailMap reads an --email-map file.
+func loadEmailMap(path string) (*emailMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read email map: %v", err)
+	}
+	var entries map[string]string
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid email map %s: %v", path, err)
+	}
+	m := &emailMap{byLogin: map[string]string{}}
+	for login, email := range entries {
+		email = strings.TrimSpace(email)
+		if login == emailMapWildcard {
+			domain := strings.TrimPrefix(email, "@")
+			if domain == "" || strings.ContainsAny(domain, "@ ") {
+				return nil, fmt.Errorf("invalid email map %s: %q must map to a domain, got %q", path, emailMapWildcard, email)
+			}
+			m.domain = domain
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(login))
+		if key == "" {
+			return nil, fmt.Errorf("invalid email map %s: empty login", path)
+		}
+		if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+			return nil, fmt.Errorf("invalid email map %s: %q for %s is not an email address", path, email, login)
+		}
+		if _, dup := m.byLogin[key]; dup {
+			return nil, fmt.Errorf("invalid email map %s: %s is listed more than once", path, login)
+		}
+		m.byLogin[key] = email
+	}
+	return m, nil
+}
+
+// lookup returns the address the map lists login at, or "". A nil map lists
+// nobody.
+func (m *emailMap) lookup(login string) string {
+	if m == nil {
+		return ""
+	}
+	return m.byLogin[strings.ToLower(login)]
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadEmailMap(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		yaml   string
+		lookup map[string]string
+		domain string
+		err    string
+	}{
+		{name: "empty", yaml: "", lookup: map[string]string{"octocat": ""}},
+		{
+			name:   "logins",
+			yaml:   "octocat: mona.lisa@example.com\nHubot: \" hubot@example.org \"\n",
+			lookup: map[string]string{"octocat": "mona.lisa@example.com", "OctoCat": "mona.lisa@example.com", "hubot": "hubot@example.org", "alice": ""},
+		},
+		{name: "wildcard", yaml: "\"*\": example.com\n", lookup: map[string]string{"octocat": ""}, domain: "example.com"},
+		{name: "wildcard with @", yaml: "\"*\": \"@example.com\"\n", domain: "example.com"},
+		{name: "wildcard address", yaml: "\"*\": someone@example.com\n", err: `"*" must map to a domain`},
+		{name: "empty wildcard", yaml: "\"*\": \"\"\n", err: `"*" must map to a domain`},
+		{name: "not an address", yaml: "octocat: mona lisa\n", err: "is not an email address"},
+		{name: "display name", yaml: "octocat: Mona <mona@example.com>\n", err: "is not an email address"},
+		{name: "empty login", yaml: "\" \": mona@example.com\n", err: "empty login"},
+		{name: "duplicate login", yaml: "octocat: a@example.com\nOctocat: b@example.com\n", err: "listed more than once"},
+		{name: "not a mapping", yaml: "- octocat\n", err: "invalid email map"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := loadEmailMap(writeTestFile(t, "emails.yml", tc.yaml))
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("loadEmailMap returned %v, want an error containing %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for login, want := range tc.lookup {
+				if got := m.lookup(login); got != want {
+					t.Errorf("lookup(%q) = %q, want %q", login, got, want)
+				}
+			}
+			if m.domain != tc.domain {
+				t.Errorf("wildcard domain %q, want %q", m.domain, tc.domain)
+			}
+		})
+	}
+}
+
+func TestLoadEmailMapMissingFile(t *testing.T) {
+	if _, err := loadEmailMap(t.TempDir() + "/missing.yml"); err == nil || !strings.Contains(err.Error(), "failed to read email map") {
+		t.Errorf("loadEmailMap of a missing file returned %v", err)
+	}
+}
+
+func TestEmailResolverUsesEmailMap(t *testing.T) {
+	m, err := loadEmailMap(writeTestFile(t, "emails.yml", "octocat: mona.lisa@example.com\n\"*\": corp.example.com\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newEmailResolver(&config{FallbackEmailDomain: "example.org", EmailMap: m})
+	for login, want := range map[string]string{
+		"octocat": "mona.lisa@example.com",
+		"Alice":   "alice@corp.example.com",
+	} {
+		if got := r.address(&prOutput{}, testPR(login, time.Now())); got != want {
+			t.Errorf("the address of %s is %q, want %q", login, got, want)
+		}
+	}
+	var none *emailMap
+	if got := none.lookup("octocat"); got != "" {
+		t.Errorf("a nil map lists octocat at %q", got)
+	}
+}
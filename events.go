@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// Types of bot events.
+const (
+	eventEvaluated = "evaluated"
+	eventWarned    = "warned"
+	eventClosed    = "closed"
+	eventError     = "error"
+	eventSummary   = "summary"
+)
+
+// eventSchemaVersion is the version of the event schema, bumped whenever a
+// change could affect consumers. Version 2 added the review details of
+// evaluated events.
+const eventSchemaVersion = 2
+
+// botEvent is a single bot activity event. It is the one schema shared by
+// every machine-readable output, e.g. --output ndjson, so consumers only need
+// to understand one format.
+type botEvent struct {
+	Version int       `json:"version"`
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	Repo    string    `json:"repo"`
+	// PR fields are set for per-PR events.
+	PR     int    `json:"pr,omitempty"`
+	Title  string `json:"title,omitempty"`
+	Author string `json:"author,omitempty"`
+	URL    string `json:"url,omitempty"`
+	// Action is the decision for an evaluated PR.
+	Action string `json:"action,omitempty"`
+	// Rule is the decision rule that decided Action.
+	Rule string `json:"rule,omitempty"`
+	// CloseReason is why a closed, or about to be closed, PR was closed:
+	// never-reviewed, author-unresponsive or discussion-quiet.
+	CloseReason string `json:"close_reason,omitempty"`
+	// Review is who an evaluated PR is waiting on.
+	Review *reviewDetail `json:"review,omitempty"`
+	// Error is the message of an error event.
+	Error   string       `json:"error,omitempty"`
+	Summary *eventTotals `json:"summary,omitempty"`
+}
+
+// eventTotals holds the run totals of a summary event.
+type eventTotals struct {
+	Evaluated     int `json:"evaluated"`
+	Warned        int `json:"warned"`
+	Closed        int `json:"closed"`
+	PathProtected int `json:"path_protected"`
+	SafetyAborted int `json:"safety_aborted"`
+	APICalls      int `json:"api_calls"`
+	Emails        int `json:"emails"`
+	Deferred      int `json:"deferred"`
+	// Unprocessed counts the PRs left for the next run by
+	// --max-run-duration.
+	Unprocessed int `json:"unprocessed,omitempty"`
+	// BackfillComplete is set on the run that finishes a --backfill.
+	BackfillComplete bool `json:"backfill_complete,omitempty"`
+	// Delta is how the classification of PRs changed since the last run.
+	Delta *runDelta `json:"delta,omitempty"`
+}
+
+// newPREvent returns an event of the given type about pr.
+func newPREvent(typ, repo string, pr *github.PullRequest) botEvent {
+	return botEvent{
+		Type:   typ,
+		Time:   time.Now().UTC(),
+		Repo:   repo,
+		PR:     pr.GetNumber(),
+		Title:  pr.GetTitle(),
+		Author: pr.GetUser().GetLogin(),
+		URL:    pr.GetHTMLURL(),
+	}
+}
+
+// newSummaryEvent returns the end-of-run summary event.
+func newSummaryEvent(repo string, summary *runSummary) botEvent {
+	return botEvent{
+		Type: eventSummary,
+		Time: time.Now().UTC(),
+		Repo: repo,
+		Summary: &eventTotals{
+			Evaluated:     summary.Evaluated,
+			Warned:        len(summary.Warned),
+			Closed:        len(summary.Closed),
+			PathProtected: summary.PathProtected,
+			SafetyAborted: summary.SafetyAborted,
+			APICalls:      summary.APICalls,
+			Emails:        summary.Emails,
+			Deferred:      len(summary.Deferred),
+			Unprocessed:   summary.Unprocessed,
+
+			BackfillComplete: summary.BackfillComplete,
+			Delta:            summary.Delta,
+		},
+	}
+}
+
+// eventStream writes events as newline-delimited JSON, one write per event so
+// readers of a pipe see each event as soon as it happens. A nil stream
+// discards events.
+type eventStream struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newEventStream(w io.Writer) *eventStream {
+	return &eventStream{w: w}
+}
+
+// Emit writes ev as one JSON line.
+func (s *eventStream) Emit(ev botEvent) {
+	if s == nil {
+		return
+	}
+	ev.Version = eventSchemaVersion
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w.Write(append(line, '\n'))
+}
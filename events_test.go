@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// lineWriter records each write it gets.
+type lineWriter struct {
+	writes []string
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, string(p))
+	return len(p), nil
+}
+
+// testEventStream returns one event of each type, as a run emits them.
+func testEventStream() []botEvent {
+	pr := testPR("alice", time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC))
+	pr.Number = github.Ptr(7)
+	pr.Title = github.Ptr("Add retries")
+	pr.HTMLURL = github.Ptr("https://github.com/acme/api/pull/7")
+	pr.RequestedReviewers = []*github.User{{Login: github.Ptr("bob")}}
+
+	evaluated := newPREvent(eventEvaluated, "acme/api", pr)
+	evaluated.Action = actionWarn
+	evaluated.Rule = ruleLifecycle
+	evaluated.Review = newReviewDetail(pr, prDecision{}, map[string]string{"carol": "approved"})
+	warned := newPREvent(eventWarned, "acme/api", pr)
+	closed := newPREvent(eventClosed, "acme/api", pr)
+	closed.CloseReason = closeNeverReviewed
+	failed := botEvent{Type: eventError, Time: time.Now().UTC(), Repo: "acme/api", PR: 8, Error: "removing label failed: boom"}
+	summary := newSummaryEvent("acme/api", &runSummary{
+		Evaluated: 12,
+		Warned:    []*github.PullRequest{pr},
+		Closed:    []*github.PullRequest{pr},
+		APICalls:  40,
+		Emails:    1,
+		Delta:     &runDelta{NewlyWarned: []int{7}, Closed: []int{7}},
+	})
+	events := []botEvent{evaluated, warned, closed, failed, summary}
+	for i := range events {
+		events[i].Time = time.Date(2026, 3, 20, 3, 0, i, 0, time.UTC)
+	}
+	return events
+}
+
+func TestEventStreamSchema(t *testing.T) {
+	var w lineWriter
+	stream := newEventStream(&w)
+	events := testEventStream()
+	for _, ev := range events {
+		stream.Emit(ev)
+	}
+
+	// Each event is written on its own, so pipelines see it at once.
+	if len(w.writes) != len(events) {
+		t.Fatalf("%d events took %d writes, want one each", len(events), len(w.writes))
+	}
+	for i, line := range w.writes {
+		if strings.Count(line, "\n") != 1 || !strings.HasSuffix(line, "\n") {
+			t.Errorf("write %d is not one line: %q", i+1, line)
+		}
+	}
+
+	got := strings.Join(w.writes, "")
+	golden := filepath.Join("testdata", "events", "stream.ndjson")
+	if *updateGolden {
+		if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v; run the test with -update to create it", err)
+	}
+	if got != string(want) {
+		t.Errorf("the event stream differs from %s; if the change is deliberate, bump eventSchemaVersion and run the test with -update:\n--- got ---\n%s--- want ---\n%s", golden, got, want)
+	}
+
+	// Repository dispatches carry the same events.
+	warned := events[1]
+	warned.Version = eventSchemaVersion
+	payload, err := json.Marshal(dispatchPayload{Action: warned.Type, PR: warned.PR, RunID: "1", Event: warned})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dispatched map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &dispatched); err != nil {
+		t.Fatal(err)
+	}
+	if line := strings.TrimSuffix(w.writes[1], "\n"); string(dispatched["event"]) != line {
+		t.Errorf("dispatch payload event %s, want the streamed event %s", dispatched["event"], line)
+	}
+}
+
+func TestEventStreamJQExample(t *testing.T) {
+	jq, err := exec.LookPath("jq")
+	if err != nil {
+		t.Skip("jq is not installed")
+	}
+	out, err := exec.Command(jq, "-r", "-f", filepath.Join("testdata", "events", "stale.jq"), filepath.Join("testdata", "events", "stream.ndjson")).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "acme/api#7 warned (@alice)\nacme/api#7 closed (@alice): never-reviewed\nacme/api: 12 evaluated, 1 warned, 1 closed\n"
+	if !bytes.Equal(out, []byte(want)) {
+		t.Errorf("the jq example printed:\n%s\nwant:\n%s", out, want)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// timeBoxedExemption is what a PR's time-boxed exemption labels, such as
+// "stale-exempt-until:2024-09-30", say at a given time.
+type timeBoxedExemption struct {
+	// Label is the label that exempts the PR, if one does, and Until the
+	// end of its last day. Of several, the one lasting longest is used.
+	Label string
+	Until time.Time
+	// Expired lists the labels whose date has passed.
+	Expired []string
+	// Invalid lists the labels whose date cannot be parsed.
+	Invalid []string
+}
+
+// timeBoxedExemptions reads the labels of a PR starting with prefix, whose
+// suffix is the last day, in loc, the PR is exempt.
+func timeBoxedExemptions(pr *github.PullRequest, prefix string, loc *time.Location, now time.Time) timeBoxedExemption {
+	var e timeBoxedExemption
+	if prefix == "" {
+		return e
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	for _, l := range pr.Labels {
+		name := l.GetName()
+		if len(name) < len(prefix) || !strings.EqualFold(name[:len(prefix)], prefix) {
+			continue
+		}
+		day, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(name[len(prefix):]), loc)
+		if err != nil {
+			e.Invalid = append(e.Invalid, name)
+			continue
+		}
+		until := day.AddDate(0, 0, 1)
+		if !now.Before(until) {
+			e.Expired = append(e.Expired, name)
+			continue
+		}
+		if until.After(e.Until) {
+			e.Label, e.Until = name, until
+		}
+	}
+	return e
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTimeBoxedExemptions(t *testing.T) {
+	const prefix = "stale-exempt-until:"
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	// 23:30 UTC on March 15th is already March 16th in Berlin.
+	now := time.Date(2026, 3, 15, 23, 30, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name   string
+		labels []string
+		loc    *time.Location
+		want   timeBoxedExemption
+	}{
+		{name: "no labels"},
+		{name: "other labels", labels: []string{"pinned", "stale-exempt"}},
+		{
+			name:   "until a later day",
+			labels: []string{"stale-exempt-until:2026-03-20"},
+			want:   timeBoxedExemption{Label: "stale-exempt-until:2026-03-20", Until: time.Date(2026, 3, 21, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:   "until today",
+			labels: []string{"Stale-Exempt-Until: 2026-03-15"},
+			want:   timeBoxedExemption{Label: "Stale-Exempt-Until: 2026-03-15", Until: time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:   "until today in a timezone that is past it",
+			labels: []string{"stale-exempt-until:2026-03-15"},
+			loc:    berlin,
+			want:   timeBoxedExemption{Expired: []string{"stale-exempt-until:2026-03-15"}},
+		},
+		{name: "expired", labels: []string{"stale-exempt-until:2026-03-14"}, want: timeBoxedExemption{Expired: []string{"stale-exempt-until:2026-03-14"}}},
+		{name: "invalid", labels: []string{"stale-exempt-until:next week", "stale-exempt-until:2026-02-30"}, want: timeBoxedExemption{Invalid: []string{"stale-exempt-until:next week", "stale-exempt-until:2026-02-30"}}},
+		{
+			name:   "the longest wins",
+			labels: []string{"stale-exempt-until:2026-03-20", "stale-exempt-until:2026-04-01", "stale-exempt-until:2026-03-25", "stale-exempt-until:2026-01-01"},
+			want: timeBoxedExemption{
+				Label:   "stale-exempt-until:2026-04-01",
+				Until:   time.Date(2026, 4, 2, 0, 0, 0, 0, time.UTC),
+				Expired: []string{"stale-exempt-until:2026-01-01"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := timeBoxedExemptions(testPR("alice", now, tc.labels...), prefix, tc.loc, now)
+			if got.Label != tc.want.Label || !got.Until.Equal(tc.want.Until) ||
+				fmt.Sprint(got.Expired) != fmt.Sprint(tc.want.Expired) || fmt.Sprint(got.Invalid) != fmt.Sprint(tc.want.Invalid) {
+				t.Errorf("timeBoxedExemptions = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+	if got := timeBoxedExemptions(testPR("alice", now, "stale-exempt-until:2026-03-20"), "", nil, now); got.Label != "" {
+		t.Errorf("without a prefix %q exempts the PR", got.Label)
+	}
+}
+
+func TestExemptUntilDecision(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	old := now.AddDate(0, 0, -40)
+	rules := testRules()
+	rules.ExemptUntilPrefix = "stale-exempt-until:"
+	for label, want := range map[string]string{
+		"stale-exempt-until:2026-03-20": actionExempt,
+		"stale-exempt-until:2026-03-01": actionWarn,
+		"stale-exempt-until:soon":       actionWarn,
+	} {
+		d := evaluatePR(testPR("alice", old, label), prSignals{}, rules, now)
+		if d.Action != want {
+			t.Errorf("with %s decided %s by the '%s' rule, want %s; trace:\n%v", label, d.Action, d.Rule, want, d.Trace)
+		}
+		if want == actionExempt && d.Rule != ruleExemptUntil {
+			t.Errorf("with %s exempted by the '%s' rule, want '%s'", label, d.Rule, ruleExemptUntil)
+		}
+	}
+}
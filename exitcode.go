@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// Exit codes of a run, for schedulers to alert on. Fatal errors exit with
+// exitFatal through fatalf.
+const (
+	exitSuccess       = 0
+	exitFatal         = 1
+	exitPartialFailed = 2
+	exitRateLimited   = 3
+	exitTimeBoxed     = 4
+)
+
+// exitCodesHelp documents the exit codes in --help.
+const exitCodesHelp = `
+Exit codes:
+  0  success
+  1  fatal error, e.g. invalid configuration or failed authentication
+  2  the run completed, but actions on some PRs or repositories failed
+  3  the run was aborted after waiting --max-rate-limit-wait for rate limits
+  4  the run stopped at --max-run-duration; the PRs left are processed first
+     by the next run
+
+With --schedule, the daemon exits with the code of the run a stop signal
+interrupted, 0 if no run was in progress, or 2 if the run did not finish
+within --shutdown-grace.
+`
+
+// usage prints the flags followed by the exit codes.
+func usage() {
+	w := flag.CommandLine.Output()
+	fmt.Fprintf(w, "Usage of %s:\n", os.Args[0])
+	flag.PrintDefaults()
+	fmt.Fprint(w, exitCodesHelp)
+}
+
+// runExitCode returns the exit code of a run that scanned the given
+// repositories.
+func runExitCode(results []repoResult, rateLimited, timeBoxed bool) int {
+	if rateLimited {
+		return exitRateLimited
+	}
+	if timeBoxed {
+		return exitTimeBoxed
+	}
+	for _, r := range results {
+		if r.Err != nil || (r.Summary != nil && r.Summary.Failed > 0) {
+			return exitPartialFailed
+		}
+	}
+	return exitSuccess
+}
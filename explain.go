@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// syntheticPR describes a hypothetical PR for --explain.
+type syntheticPR struct {
+	Author       string
+	Labels       []string
+	Draft        bool
+	Age          time.Duration
+	LastActivity time.Time
+}
+
+// build returns a PullRequest with the synthetic attributes. Without an
+// explicit last activity the PR is taken to have had none since it was opened.
+// Logins ending in "[bot]" are given the Bot user type.
+func (s syntheticPR) build(now time.Time) *github.PullRequest {
+	userType := "User"
+	if strings.HasSuffix(s.Author, "[bot]") {
+		userType = "Bot"
+	}
+	created := now.Add(-s.Age)
+	updated := s.LastActivity
+	if updated.IsZero() {
+		updated = created
+	}
+	pr := &github.PullRequest{
+		Number:    github.Ptr(0),
+		Title:     github.Ptr("(synthetic PR)"),
+		State:     github.Ptr("open"),
+		Draft:     github.Ptr(s.Draft),
+		User:      &github.User{Login: github.Ptr(s.Author), Type: github.Ptr(userType)},
+		CreatedAt: &github.Timestamp{Time: created},
+		UpdatedAt: &github.Timestamp{Time: updated},
+	}
+	for _, name := range s.Labels {
+		pr.Labels = append(pr.Labels, &github.Label{Name: github.Ptr(name)})
+	}
+	return pr
+}
+
+// parseDays parses a duration that may also be given in days, e.g. "45d".
+func parseDays(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid number of days %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// explainPR prints the decision trace for a synthetic PR and the timeline of
+// the actions the bot would take on it if nothing changes.
+func explainPR(w io.Writer, s syntheticPR, cfg *config, now time.Time) {
+	pr := s.build(now)
+	d := evaluatePR(pr, prSignals{}, cfg.Rules, now)
+	loc := cfg.DisplayLocation
+
+	fmt.Fprintf(w, "Synthetic PR by %s, opened %s, last activity %s, labels [%s], draft=%t\n",
+		s.Author, formatDate(pr.GetCreatedAt().Time, loc), formatDate(pr.GetUpdatedAt().Time, loc), strings.Join(s.Labels, ", "), s.Draft)
+	fmt.Fprintf(w, "Rule order: %s\n", cfg.Rules.ruleOrder())
+	fmt.Fprintln(w, "Decision trace:")
+	for _, line := range d.Trace {
+		fmt.Fprintf(w, "  - %s\n", line)
+	}
+	fmt.Fprintf(w, "Decision: %s (rule '%s')\n", d.Action, d.Rule)
+
+	fmt.Fprintln(w, "Timeline if nothing changes:")
+	switch d.Action {
+	case actionExempt:
+		fmt.Fprintln(w, "  - no action; the PR is exempt")
+	case actionActive:
+		if d.Override != nil && d.Override.Policy == policyCloseImmediately {
+			fmt.Fprintf(w, "  - %s: close (becomes stale)\n", formatDate(d.StaleAt, loc))
+			break
+		}
+		fmt.Fprintf(w, "  - %s: warn the author (becomes stale)\n", formatDate(d.StaleAt, loc))
+		fmt.Fprintf(w, "  - %s: close\n", formatDate(d.CloseAt, loc))
+	case actionWarn:
+		fmt.Fprintf(w, "  - %s: warn the author\n", formatDate(now, loc))
+		fmt.Fprintf(w, "  - %s: close\n", formatDate(d.CloseAt, loc))
+	case actionWait:
+		fmt.Fprintf(w, "  - %s: close\n", formatDate(d.CloseAt, loc))
+	case actionClose, actionCloseNow:
+		fmt.Fprintf(w, "  - %s: close\n", formatDate(now, loc))
+	}
+	if d.Action != actionExempt {
+		fmt.Fprintln(w, "Closures are still subject to the protected-path and safety checks.")
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseDays(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"45d", 45 * 24 * time.Hour, false},
+		{"0d", 0, false},
+		{"36h", 36 * time.Hour, false},
+		{"-3d", 0, true},
+		{"xd", 0, true},
+		{"45", 0, true},
+	} {
+		got, err := parseDays(tc.in)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("parseDays(%q) = %v, %v, want %v (error %v)", tc.in, got, err, tc.want, tc.wantErr)
+		}
+	}
+}
+
+func TestSyntheticPRBuild(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	pr := syntheticPR{Author: "dependabot[bot]", Labels: []string{"bug", "pinned"}, Draft: true, Age: 45 * 24 * time.Hour}.build(now)
+	if pr.GetUser().GetType() != "Bot" || !pr.GetDraft() || pr.GetState() != "open" {
+		t.Errorf("built PR by %s (%s), draft %v, state %s, want an open draft by a bot", pr.GetUser().GetLogin(), pr.GetUser().GetType(), pr.GetDraft(), pr.GetState())
+	}
+	if want := now.AddDate(0, 0, -45); !pr.GetCreatedAt().Time.Equal(want) || !pr.GetUpdatedAt().Time.Equal(want) {
+		t.Errorf("built PR created %v, updated %v, want both %v without a last activity", pr.GetCreatedAt(), pr.GetUpdatedAt(), want)
+	}
+	if !hasLabel(pr, "bug") || !hasLabel(pr, "pinned") {
+		t.Errorf("built PR labels %v, want bug and pinned", pr.Labels)
+	}
+
+	active := now.AddDate(0, 0, -3)
+	pr = syntheticPR{Author: "alice", Age: 45 * 24 * time.Hour, LastActivity: active}.build(now)
+	if pr.GetUser().GetType() != "User" || !pr.GetUpdatedAt().Time.Equal(active) {
+		t.Errorf("built PR by a %s updated %v, want a user's PR updated %v", pr.GetUser().GetType(), pr.GetUpdatedAt(), active)
+	}
+}
+
+// TestExplainGolden explains canned synthetic PRs and compares the decision
+// trace and timeline with testdata/explain/<name>.golden. Run with -update
+// to rewrite them.
+func TestExplainGolden(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	cfg := &config{Rules: testRules(), DisplayLocation: time.UTC}
+	for _, tc := range []struct {
+		name string
+		pr   syntheticPR
+	}{
+		{"fresh", syntheticPR{Author: "alice", Age: 3 * day}},
+		{"stale", syntheticPR{Author: "alice", Age: 45 * day, Labels: []string{"bug"}}},
+		{"recent-activity", syntheticPR{Author: "alice", Age: 90 * day, LastActivity: now.AddDate(0, 0, -10)}},
+		{"exempt-label", syntheticPR{Author: "alice", Age: 45 * day, Labels: []string{"bug", "pinned"}}},
+		{"draft", syntheticPR{Author: "alice", Age: 45 * day, Draft: true}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			explainPR(&out, tc.pr, cfg, now)
+			golden := filepath.Join("testdata", "explain", tc.name+".golden")
+			if *updateGolden {
+				if err := os.WriteFile(golden, out.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v; run the test with -update to create it", err)
+			}
+			if out.String() != string(want) {
+				t.Errorf("explain output differs from %s; run the test with -update to accept it:\n--- got ---\n%s--- want ---\n%s", filepath.Base(golden), out.String(), want)
+			}
+		})
+	}
+}
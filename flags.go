@@ -0,0 +1,297 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"time"
+)
+
+// cliFlags holds the values of the bot's command-line flags.
+type cliFlags struct {
+	config                    *string
+	probotConfig              *string
+	printConfig               *bool
+	githubToken               *string
+	githubBaseURL             *string
+	owner                     *string
+	repo                      *string
+	includeIssues             *bool
+	baseBranches              *string
+	excludeBaseBranches       *string
+	repoListFile              *string
+	daysInactive              *int
+	warningPeriod             *int
+	smtpServer                *string
+	smtpPort                  *int
+	smtpUser                  *string
+	smtpPassword              *string
+	smtpFrom                  *string
+	smtpEncryption            *string
+	smtpInsecure              *bool
+	smtpCAFile                *string
+	smtpConcurrency           *int
+	titlePrefixOnWarn         *string
+	noEmailGuess              *bool
+	emailMap                  *string
+	emailDomain               *string
+	discussionCategory        *string
+	discussionMode            *string
+	authorPolicies            *string
+	stateFile                 *string
+	incremental               *bool
+	fullScanInterval          *time.Duration
+	attachICS                 *bool
+	githubDialProxy           *string
+	githubDialProxySSHKey     *string
+	githubDialProxyKnownHosts *string
+	timezone                  *string
+	timezoneFile              *string
+	closeSafetyWindow         *time.Duration
+	summaryIssue              *bool
+	summaryGistID             *string
+	quiet                     *bool
+	maxAPICalls               *int
+	apiRetries                *int
+	maxRunDuration            *time.Duration
+	runDurationMargin         *time.Duration
+	concurrency               *int
+	schedule                  *string
+	runOnce                   *bool
+	shutdownGrace             *time.Duration
+	maxRateLimitWait          *time.Duration
+	maxEmails                 *int
+	protectedPaths            *string
+	trendsFile                *string
+	slaMetrics                *bool
+	trendsMaxBytes            *int
+	notificationMaxAttempts   *int
+	explain                   *bool
+	explainAge                *string
+	explainLabels             *string
+	explainDraft              *bool
+	explainAuthor             *string
+	explainLastActivity       *string
+	editsCountAsActivity      *bool
+	reopenGraceDays           *int
+	closeWaitingOnMaintainer  *bool
+	questionPatterns          *string
+	pauseDrafts               *bool
+	readyResetsActivity       *bool
+	exemptWaitingOnReview     *bool
+	skipDrafts                *bool
+	exemptAuthors             *string
+	exemptLabels              *string
+	exemptLabel               *string
+	exemptUntilLabelPrefix    *string
+	closeNowLabel             *string
+	ruleOrder                 *string
+	staleLabel                *string
+	failingChecksStaleAfter   *time.Duration
+	exemptSecurity            *bool
+	securityLabels            *string
+	securityTeam              *string
+	escalateSecurityUpdates   *bool
+	securityContact           *string
+	statusCommand             *bool
+	noAutoSoften              *bool
+	autoSoftenMinActivity     *int
+	autoSoftenWindow          *time.Duration
+	followRenames             *bool
+	backfill                  *bool
+	backfillDailyCap          *int
+	reminders                 *string
+	notifyOnUnstale           *bool
+	unstaleCooldown           *time.Duration
+	planFile                  *string
+	executePlan               *string
+	authorStats               *string
+	authorStatsMin            *int
+	reportDetail              *string
+	output                    *string
+	staleAction               *string
+	staleMilestone            *string
+	detectDuplicates          *bool
+	duplicateSimilarity       *float64
+	duplicateMaxPRs           *int
+	commentOnDuplicates       *bool
+	webhookURL                *string
+	webhookSecret             *string
+	emitDispatch              *bool
+	dispatchMax               *int
+	oooFile                   *string
+	oooGrace                  *time.Duration
+	startJitter               *time.Duration
+	capabilities              *bool
+	templateDir               *string
+	requestTag                *string
+	notificationPrefsFile     *string
+	emailFormat               *string
+	htmlTemplate              *string
+	greetingName              *string
+	notifyVia                 *string
+	slackWebhookURL           *string
+	teamsWebhookURL           *string
+	slackChannel              *string
+	noActionDays              *string
+	holidaysFile              *string
+	dryRun                    *bool
+	enableClose               *bool
+	closeInterlockThreshold   *int
+	readOnly                  *bool
+	logLevel                  *string
+	logFormat                 *string
+	logFile                   *string
+	eventsFile                *string
+	logMaxSize                *int
+	logMaxBackups             *int
+	logMaxAge                 *time.Duration
+}
+
+// defineFlags defines the bot's flags on fs with their built-in defaults.
+// configLoader sets them from the environment when it parses the command line.
+func defineFlags(fs *flag.FlagSet) *cliFlags {
+	f := &cliFlags{}
+
+	// Define command-line flags.
+	f.config = fs.String("config", "", "YAML config file; flags, environment variables and the .env file take precedence over it. The schema subcommand prints its JSON Schema, and validate-config checks a file against it")
+	f.probotConfig = fs.String("probot-config", "", "probot/stale configuration to read in place of --config when that is not given (default: "+probotConfigPath+" if present); see the convert-config subcommand to migrate it")
+	fs.Bool("no-dotenv", false, "Do not load a .env file")
+	fs.String("dotenv-path", "", ".env file to load instead of ./.env; unlike the default, it must exist")
+	f.printConfig = fs.Bool("print-config", false, "Print each setting's effective value and where it came from (flag, environment, .env, config file or default), then exit")
+	f.githubToken = fs.String("github-token", "", "GitHub API token")
+	f.githubBaseURL = fs.String("github-base-url", "", "GitHub API base URL (e.g. https://api.github.com/)")
+	f.owner = fs.String("owner", "", "GitHub repository owner; may be left out when --repo names it")
+	f.repo = fs.String("repo", "", "GitHub repository as name, owner/name or URL, or a comma-separated list of them to scan in one run")
+	f.includeIssues = fs.Bool("include-issues", false, "Also warn about and close stale issues, not just PRs")
+	f.baseBranches = fs.String("base-branches", "", "Comma-separated base branches or globs, e.g. \"main\"; PRs targeting other branches are left alone (default: all)")
+	f.excludeBaseBranches = fs.String("exclude-base-branches", "", "Comma-separated base branches or globs, e.g. \"release/*\", whose PRs are left alone; wins over --base-branches")
+	f.repoListFile = fs.String("repo-list-file", "", "File of \"allow <glob>\" and \"deny <glob>\" lines filtering the repositories in --repo; deny wins")
+	f.daysInactive = fs.Int("days-inactive", 0, "Number of days to consider a PR stale")
+	f.warningPeriod = fs.Int("warning-period", 0, "Warning period in days before closing stale PR")
+	f.smtpServer = fs.String("smtp-server", "", "SMTP server address")
+	f.smtpPort = fs.Int("smtp-port", 587, "SMTP server port")
+	f.smtpUser = fs.String("smtp-user", "", "SMTP username; leave it and --smtp-password empty for relays that need no authentication")
+	f.smtpPassword = fs.String("smtp-password", "", "SMTP password")
+	f.smtpFrom = fs.String("smtp-from", "", "Sender address of emails (default --smtp-user; required without it)")
+	f.smtpEncryption = fs.String("smtp-encryption", "", "SMTP encryption: starttls, tls for implicit TLS (SMTPS), or none; defaults to tls on port 465 and starttls otherwise")
+	f.smtpInsecure = fs.Bool("smtp-insecure", false, "Skip verification of the SMTP server's TLS certificate, for lab relays only")
+	f.smtpCAFile = fs.String("smtp-ca-file", "", "PEM file of CA certificates to trust for the SMTP server, in addition to the system roots, for internal relays")
+	f.smtpConcurrency = fs.Int("smtp-concurrency", 1, "SMTP connections kept open and reused for the run's emails, sending that many emails at once when --concurrency processes several PRs at once")
+	f.titlePrefixOnWarn = fs.String("title-prefix-on-warn", "", "Prefix the titles of warned PRs with this, e.g. \"[stale]\", removing it when they become active or are closed (disabled when empty)")
+	f.noEmailGuess = fs.Bool("no-email-guess", false, "Do not email authors without a public or commit email at an address guessed from --email-domain; skip their emails instead")
+	f.emailMap = fs.String("email-map", "", "YAML file mapping GitHub logins to email addresses, consulted before public and commit emails; a \"*\" entry gives the domain for unlisted users in place of --email-domain")
+	f.emailDomain = fs.String("email-domain", "example.com", "Fallback email domain (used when neither the GitHub user's public email nor a commit email is available); a \"*\" entry in --email-map takes precedence")
+	f.discussionCategory = fs.String("discussion-category", "", "GitHub Discussion category to post run summaries to (disabled when empty)")
+	f.discussionMode = fs.String("discussion-mode", discussionModePerRun, "Discussion posting mode: per-run or monthly-rollup")
+	f.authorPolicies = fs.String("author-policies", "", "Comma-separated author policy overrides as pattern=policy, where pattern is a login, a glob or type:Bot and policy is skip, warn-then-close or close-immediately")
+	f.stateFile = fs.String("state-file", "", "Path to a JSON file persisting bot state between runs")
+	f.incremental = fs.Bool("incremental", false, "Re-evaluate only PRs with new repository events between full scans (requires --state-file)")
+	f.fullScanInterval = fs.Duration("full-scan-interval", 24*time.Hour, "In incremental mode, how often to run a full scan of all open PRs")
+	f.attachICS = fs.Bool("attach-ics", false, "Attach an iCalendar reminder for the closure deadline to warning emails")
+	f.githubDialProxy = fs.String("github-dial-proxy", "", "Reach the GitHub API through socks5://host:port or an SSH bastion ssh://user@host[:port]")
+	f.githubDialProxySSHKey = fs.String("github-dial-proxy-ssh-key", "", "Private key for an ssh:// dial proxy (default ~/.ssh/id_rsa)")
+	f.githubDialProxyKnownHosts = fs.String("github-dial-proxy-known-hosts", "", "known_hosts file used to verify an ssh:// dial proxy (default ~/.ssh/known_hosts)")
+	f.timezone = fs.String("default-display-timezone", "", "IANA timezone used to render dates in notifications (default UTC)")
+	fs.StringVar(f.timezone, "timezone", "", "Deprecated alias for --default-display-timezone")
+	f.timezoneFile = fs.String("timezone-file", "", "File mapping GitHub logins to timezones (\"login: Area/City\" per line) for rendering dates in their notifications")
+	f.closeSafetyWindow = fs.Duration("close-safety-window", 48*time.Hour, "Abort a closure if a human commented on the PR within this window")
+	f.summaryIssue = fs.Bool("summary-issue", false, "Keep an issue titled \"[stale-bot] last run\" updated with the latest run summary")
+	f.summaryGistID = fs.String("summary-gist-id", "", "Gist to overwrite with the latest run summary")
+	f.quiet = fs.Bool("quiet", false, "Only print per-PR output for PRs with errors, and no progress line")
+	f.maxAPICalls = fs.Int("max-api-calls", 0, "Maximum GitHub API requests per run, counting retries (0 = unlimited)")
+	f.apiRetries = fs.Int("api-retries", 3, "Times to retry a GitHub API call that fails with a server or network error, with exponential backoff (0 = never)")
+	f.maxRunDuration = fs.Duration("max-run-duration", 0, "Longest a run may take; near it no further PRs are started, the rest are deferred to the next run, and the run exits with code 4 (0 for no limit)")
+	f.runDurationMargin = fs.Duration("run-duration-margin", defaultRunDurationMargin, "With --max-run-duration: stop starting PRs this long before the limit, to finish the PR in progress and write the state and reports")
+	f.concurrency = fs.Int("concurrency", 1, "PRs of a repository to process at once; their GitHub API calls overlap, and their emails up to --smtp-concurrency (1 processes them one at a time)")
+	f.schedule = fs.String("schedule", "", "Run as a daemon, scanning on this cron schedule (e.g. \"0 3 * * *\", in the local timezone) until SIGTERM or SIGINT, instead of scanning once and exiting")
+	f.runOnce = fs.Bool("run-once", false, "Scan once and exit even if --schedule is set; the default without --schedule")
+	f.shutdownGrace = fs.Duration("shutdown-grace", defaultShutdownGrace, "With --schedule: how long a stop signal waits for the run in progress to defer its remaining PRs and save its state before exiting")
+	f.maxRateLimitWait = fs.Duration("max-rate-limit-wait", 30*time.Minute, "Longest total time per run to wait out GitHub rate limits before aborting the run with a non-zero exit")
+	f.maxEmails = fs.Int("max-emails", 0, "Maximum emails sent per run (0 = unlimited)")
+	f.protectedPaths = fs.String("protected-paths", "", "Comma-separated path globs; PRs changing a matching file may be warned but are never closed")
+	f.trendsFile = fs.String("trends-file", "", "Append one line of aggregate run metrics to this local file (see the trends subcommand)")
+	f.slaMetrics = fs.Bool("sla-metrics", false, "Record in the trends file how long PRs take from opening to their first warning and from warning and opening to being closed or merged (see trends --sla); history needs --state-file")
+	f.trendsMaxBytes = fs.Int("trends-max-bytes", 1<<20, "Rotate the trends file to <file>.1.gz, keeping --log-max-backups, once it reaches this size (0 = never)")
+	f.notificationMaxAttempts = fs.Int("notification-max-attempts", 3, "Attempts, across runs, before a failed notification email is given up and reported for manual follow-up")
+	f.explain = fs.Bool("explain", false, "Print the decision and timeline for a hypothetical PR described by --age, --labels, --draft, --author and --last-activity, without contacting GitHub")
+	f.explainAge = fs.String("age", "0d", "With --explain: age of the hypothetical PR, e.g. 45d or 36h")
+	f.explainLabels = fs.String("labels", "", "With --explain: comma-separated labels on the hypothetical PR")
+	f.explainDraft = fs.Bool("draft", false, "With --explain: whether the hypothetical PR is a draft")
+	f.explainAuthor = fs.String("author", "someone", "With --explain: login of the hypothetical PR's author (logins ending in [bot] are bots)")
+	f.explainLastActivity = fs.String("last-activity", "", "With --explain: date of the hypothetical PR's last activity (YYYY-MM-DD, default: when it was opened)")
+	f.editsCountAsActivity = fs.Bool("edits-count-as-activity", true, "Count title and description edits as activity; when false, activity is read from the PR timeline, with force-pushes counted when pushed")
+	f.reopenGraceDays = fs.Int("reopen-grace-days", 0, "Days after a PR closed for inactivity is reopened before it can become stale again (default: twice --days-inactive)")
+	f.closeWaitingOnMaintainer = fs.Bool("close-waiting-on-maintainer", false, "Warn and close stale PRs whose author's last comment is an unanswered question, instead of nudging the maintainers")
+	f.questionPatterns = fs.String("question-patterns", "", "Comma-separated regular expressions that make an author's comment a question (default: a question mark outside code)")
+	f.pauseDrafts = fs.Bool("pause-drafts", true, "Pause a warned PR its author converts to a draft: remove the warning and leave it alone until it is marked ready for review (matters with --skip-drafts=false)")
+	f.readyResetsActivity = fs.Bool("ready-resets-activity", true, "Restart a PR's inactivity clock when it is marked ready for review")
+	f.exemptWaitingOnReview = fs.Bool("exempt-waiting-on-review", true, "Exempt PRs whose author last re-requested a review or replied to review comments, with no reviewer activity since")
+	f.skipDrafts = fs.Bool("skip-drafts", true, "Exempt draft PRs from staleness, removing any stale label they carry")
+	f.exemptAuthors = fs.String("exempt-authors", "", "Comma-separated logins or globs, e.g. \"release-bot,*-bot\", whose PRs are never marked stale; a name without the [bot] suffix also matches the GitHub App")
+	f.exemptLabels = fs.String("exempt-labels", "do not stale,pinned", "Comma-separated labels that exempt a PR from being marked stale")
+	f.exemptLabel = fs.String("exempt-label", "", "Comma-separated labels that exempt a PR from being marked stale; replaces --exempt-labels when set")
+	f.exemptUntilLabelPrefix = fs.String("exempt-until-label-prefix", "stale-exempt-until:", "Prefix of labels exempting a PR until the date that follows, e.g. \"stale-exempt-until:2024-09-30\"; expired ones are removed (empty = disabled)")
+	f.closeNowLabel = fs.String("close-now-label", "stale-bot:close-now", "Label with which maintainers who can push have the next run close a PR with a short comment, bypassing thresholds and warnings; removed once done (empty = disabled)")
+	f.ruleOrder = fs.String("rule-order", "", "Comma-separated decision rules to evaluate first within their phase, e.g. \"exempt-label,draft\"; exemptions: "+strings.Join(exemptionRules, ", ")+"; modifiers of stale PRs: "+strings.Join(modifierRules, ", "))
+	f.staleLabel = fs.String("stale-label", "stale-warning", "Label applied to warned PRs")
+	f.failingChecksStaleAfter = fs.Duration("failing-checks-stale-after", 0, "Treat a PR as stale, despite other activity, once its required checks have been failing this long (0 = disabled)")
+	f.exemptSecurity = fs.Bool("exempt-security", false, "Exempt security work: PRs with a security label, PRs from advisory forks and PRs by the security team")
+	f.securityLabels = fs.String("security-labels", "security", "With --exempt-security: comma-separated labels marking security PRs")
+	f.securityTeam = fs.String("security-team", "", "With --exempt-security: slug of the owner organization's security team whose members' PRs are exempt")
+	f.escalateSecurityUpdates = fs.Bool("escalate-security-updates", true, "Never warn or close Dependabot security updates (label 'security' or an advisory link in the body); label stale ones '"+securityStaleLabel+"' and email --security-contact instead. Takes precedence over --exempt-security and author policies")
+	f.securityContact = fs.String("security-contact", "", "Email address stale Dependabot security updates are escalated to")
+	f.statusCommand = fs.Bool("status-command", true, "Reply to \"/stale status\" comments on open PRs with the bot's current assessment")
+	f.noAutoSoften = fs.Bool("no-auto-soften", false, "Never switch to warn-only mode because of low maintainer activity")
+	f.autoSoftenMinActivity = fs.Int("auto-soften-min-activity", 1, "Switch to warn-only mode for the run when fewer PRs than this were merged or approved within --auto-soften-window")
+	f.autoSoftenWindow = fs.Duration("auto-soften-window", 30*24*time.Hour, "Period over which maintainer activity is measured for auto-softening")
+	f.followRenames = fs.Bool("follow-renames", false, "If the repository was renamed or transferred, continue under its new name instead of skipping it")
+	f.backfill = fs.Bool("backfill", false, "Adopt the bot gently: warn the oldest stale PRs first, at most --backfill-daily-cap per day, until the backlog is drained (requires --state-file)")
+	f.backfillDailyCap = fs.Int("backfill-daily-cap", 50, "With --backfill: maximum warnings per day")
+	f.reminders = fs.String("reminders", "", "Comma-separated reminder offsets before the closure deadline, e.g. \"10d,3d,1d\"; each is emailed at most once per PR (requires --state-file)")
+	f.notifyOnUnstale = fs.Bool("notify-on-unstale", false, "Thank the author of a warned PR that became active again, telling them the earliest date it can next go stale")
+	f.unstaleCooldown = fs.Duration("unstale-cooldown", defaultUnstaleCooldown, "With --notify-on-unstale: do not thank the author again for the same PR within this period")
+	f.planFile = fs.String("plan-file", "", "With --read-only: write the planned per-PR actions to this file for review")
+	f.executePlan = fs.String("execute-plan", "", "Execute the actions in a plan file written by --plan-file, skipping PRs whose head, labels or state changed since")
+	f.authorStats = fs.String("author-stats", authorStatsOff, "Rank authors by stale PRs, bot closures and resurrection rate: off, internal (in the run output only) or full (also in the published run summary); history needs --state-file")
+	f.authorStatsMin = fs.Int("author-stats-min", 3, "Fewest stale or closed PRs before an author appears in --author-stats")
+	f.reportDetail = fs.String("report-detail", reportDetailBasic, "Review details in --output ndjson evaluated events: basic (requested reviewers and assignees, no extra API calls) or full (also each reviewer's latest review, fetching the timeline when not already fetched)")
+	f.output = fs.String("output", "text", "Output format: text, or ndjson for one JSON event per line on stdout with human-readable logs on stderr")
+	f.staleAction = fs.String("stale-action", staleActionClose, "What to do with a PR once its warning period has passed: close, or milestone to park it in --stale-milestone and leave it open")
+	f.staleMilestone = fs.String("stale-milestone", "Parked – stale", "With --stale-action milestone: milestone stale PRs are parked in, created if missing")
+	f.detectDuplicates = fs.Bool("detect-duplicates", false, "List groups of stale PRs by the same author that change largely the same files in the run summary")
+	f.duplicateSimilarity = fs.Float64("duplicate-similarity", defaultDuplicateSimilarity, "With --detect-duplicates: Jaccard similarity of two PRs' changed files, from 0 to 1, at which they count as duplicates")
+	f.duplicateMaxPRs = fs.Int("duplicate-max-prs", 50, "With --detect-duplicates: most stale PRs per repository whose changed files are listed and compared")
+	f.commentOnDuplicates = fs.Bool("comment-on-duplicates", false, "With --detect-duplicates: comment on the older PRs of each group, pointing the author to the newest")
+	f.webhookURL = fs.String("webhook-url", "", "POST a JSON payload to this URL for each warning, closure, stale label added or removed, and newly exempt PR")
+	f.webhookSecret = fs.String("webhook-secret", "", "Secret to sign --webhook-url payloads with, as an HMAC-SHA256 in the "+webhookSignatureHeader+" header")
+	f.emitDispatch = fs.Bool("emit-repository-dispatch", false, "Send a \"stale-pr-bot\" repository dispatch event after each warning and closure, for workflows to react to")
+	f.dispatchMax = fs.Int("repository-dispatch-max", 100, "With --emit-repository-dispatch: maximum dispatch events per run (0 = unlimited)")
+	f.oooFile = fs.String("ooo-file", "", "CSV file of \"login,start,end\" out-of-office ranges (YYYY-MM-DD, end inclusive or empty if unknown); PRs of absent authors are not warned or closed")
+	f.oooGrace = fs.Duration("ooo-grace", 7*24*time.Hour, "With --ooo-file: how long after an author's return before their PRs can be closed")
+	f.startJitter = fs.Duration("start-jitter", 0, "Wait a random delay of up to this long before starting, to spread out instances started by the same schedule (skipped when stdin is a terminal)")
+	f.capabilities = fs.Bool("capabilities", false, "Probe which operations the token can perform at startup and disable optional features it lacks, for narrowly scoped fine-grained tokens")
+	f.templateDir = fs.String("template-dir", "", "Directory of template overrides: <channel>-<action>.tmpl (channels email and comment; actions warning, failing-checks-warning, reminder, unstale, closure, close-request, status, nudge, duplicate, escalation), or <action>.tmpl shared by all channels; closure-never-reviewed, closure-author-unresponsive and closure-discussion-quiet replace closure for PRs closed for that reason")
+	f.requestTag = fs.String("request-tag", "", "Tag, e.g. a team name, appended to the bot's User-Agent and sent in the "+requestTagHeader+" header to identify its API traffic")
+	f.notificationPrefsFile = fs.String("notification-prefs-file", "", "File of \"login: channel\" lines overriding --notify-via per author; channel is email, comment, both, slack, teams or none (labels only)")
+	f.emailFormat = fs.String("email-format", emailFormatText, "Format of notification emails: text, or html or both for a multipart message with an HTML part and the plain text as its fallback")
+	f.htmlTemplate = fs.String("html-template", "", "File replacing the built-in html/template for the HTML part of notification emails; it gets the notification fields plus .Subject and .Paragraphs, the plain-text body split into paragraphs of lines")
+	f.greetingName = fs.String("greeting-name", greetDisplayName, "Name notification emails greet the author by: display (their GitHub profile name, falling back to the login) or login")
+	f.notifyVia = fs.String("notify-via", notifyEmail, "How PR authors are notified: email, comment (a PR comment mentioning them; no SMTP settings needed), both, slack or teams (a message to --slack-webhook-url or --teams-webhook-url instead)")
+	f.slackWebhookURL = fs.String("slack-webhook-url", "", "Slack incoming webhook URL that --notify-via slack posts a message per warned or closed PR to")
+	f.teamsWebhookURL = fs.String("teams-webhook-url", "", "Microsoft Teams webhook URL that --notify-via teams posts an Adaptive Card per warned or closed PR to")
+	f.slackChannel = fs.String("slack-channel", "", "Channel to post to instead of the Slack webhook's own, for webhooks that allow overriding it")
+	f.noActionDays = fs.String("no-action-days", "", "Comma-separated weekdays and YYYY-MM-DD dates, e.g. \"Sat,Sun\", on which the run is read-only, in --default-display-timezone")
+	f.holidaysFile = fs.String("holidays-file", "", "File of \"YYYY-MM-DD [name]\" lines adding holidays to --no-action-days")
+	f.dryRun = fs.Bool("dry-run", false, "Run the full decision loop but only print the labels, closures and emails that would happen (implies --read-only)")
+	f.enableClose = fs.Bool("enable-close", false, "Allow a non-interactive run to close more than --close-interlock-threshold PRs of a repository; without it such a run is switched to warn-only mode, and an interactive one asks first")
+	f.closeInterlockThreshold = fs.Int("close-interlock-threshold", defaultCloseInterlockThreshold, "Number of PRs per repository a run may close without --enable-close or confirmation")
+	f.readOnly = fs.Bool("read-only", false, "Refuse every GitHub write and email at the transport level and list them in the summary")
+	f.logLevel = fs.String("log-level", "info", "Minimum level of log records: debug, info, warn or error")
+	f.logFormat = fs.String("log-format", logFormatText, "Log format: text, or json for one JSON object per record")
+	f.logFile = fs.String("log-file", "", "Append log records to this file instead of printing them, rotating it at --log-max-size")
+	f.eventsFile = fs.String("events-file", "", "Append the ndjson event stream to this file, rotating it at --log-max-size; stdout stays human-readable")
+	f.logMaxSize = fs.Int("log-max-size", 10<<20, "Rotate --log-file and --events-file once they reach this many bytes (0 = never)")
+	f.logMaxBackups = fs.Int("log-max-backups", 5, "Rotated gzip backups (<file>.1.gz, <file>.2.gz, ...) to keep of --log-file, --events-file and --trends-file")
+	f.logMaxAge = fs.Duration("log-max-age", 0, "Remove rotated backups of --log-file, --events-file and --trends-file older than this (0 = keep them)")
+	return f
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// allowedGlobals are the package-level variables that are set once and only
+// read after, each with why it is a variable. Run state belongs in the
+// scanner, its config or its flags, where tests and scheduled runs can make
+// their own.
+var allowedGlobals = map[string]string{
+	"version":            "set with -ldflags -X at build time",
+	"closeReasons":       "lookup table",
+	"flagEnvNames":       "lookup table",
+	"legacyFlagEnvNames": "lookup table",
+	"templateSlots":      "lookup table",
+	"probotDefaults":     "lookup table",
+	"probotConverted":    "lookup table",
+	"probotUnsupported":  "lookup table",
+	"exemptionRules":     "lookup table",
+	"modifierRules":      "lookup table",
+	"cronMacros":         "lookup table",
+}
+
+// TestNoMutableGlobals fails on package-level variables other than compiled
+// regular expressions, sentinel errors and allowedGlobals.
+func TestNoMutableGlobals(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for i, name := range vs.Names {
+					if name.Name == "_" {
+						continue
+					}
+					seen[name.Name] = true
+					if _, ok := allowedGlobals[name.Name]; ok {
+						continue
+					}
+					if i < len(vs.Values) && isConstantValue(vs.Values[i]) {
+						continue
+					}
+					t.Errorf("%s: package-level variable %s; keep run state in the scanner, or add it to allowedGlobals if it is never written after initialization", fset.Position(name.Pos()), name.Name)
+				}
+			}
+		}
+	}
+	for name := range allowedGlobals {
+		if !seen[name] {
+			t.Errorf("allowedGlobals lists %s, which is no longer declared", name)
+		}
+	}
+}
+
+// isConstantValue reports whether expr is a regexp.MustCompile or
+// errors.New call, whose results are never reassigned.
+func isConstantValue(expr ast.Expr) bool {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	switch pkg.Name + "." + sel.Sel.Name {
+	case "regexp.MustCompile", "errors.New":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// repoHealth measures recent maintainer activity in a repository.
+type repoHealth struct {
+	// Window is the period the counts cover.
+	Window time.Duration
+	// Merged counts PRs merged in the window.
+	Merged int
+	// Approved counts unmerged PRs approved by a reviewer and updated in
+	// the window.
+	Approved int
+}
+
+// Activity is the total maintainer activity.
+func (h repoHealth) Activity() int {
+	return h.Merged + h.Approved
+}
+
+// softenReason returns why the repository should run in warn-only mode, or ""
+// if maintainers have been active enough. Closing contributors' PRs for
+// inactivity is unfair when maintainers are not reviewing them either.
+func softenReason(h repoHealth, minActivity int) string {
+	if h.Activity() >= minActivity {
+		return ""
+	}
+	return fmt.Sprintf("low maintainer activity: %d merged and %d approved PR(s) in the last %s, below the threshold of %d",
+		h.Merged, h.Approved, humanizeDuration(h.Window), minActivity)
+}
+
+// getRepoHealth counts merged and approved PRs over the window using the
+// search API.
+func getRepoHealth(client *github.Client, owner, repo string, window time.Duration, now time.Time) (repoHealth, error) {
+	since := now.Add(-window).UTC().Format("2006-01-02")
+	h := repoHealth{Window: window}
+	var err error
+	h.Merged, err = searchCount(client, fmt.Sprintf("repo:%s/%s is:pr is:merged merged:>=%s", owner, repo, since))
+	if err != nil {
+		return h, err
+	}
+	h.Approved, err = searchCount(client, fmt.Sprintf("repo:%s/%s is:pr is:unmerged review:approved updated:>=%s", owner, repo, since))
+	return h, err
+}
+
+// searchCount returns the number of issues and PRs matching query.
+func searchCount(client *github.Client, query string) (int, error) {
+	res, _, err := client.Search.Issues(context.Background(), query, &github.SearchOptions{ListOptions: github.ListOptions{PerPage: 1}})
+	if err != nil {
+		return 0, fmt.Errorf("search %q failed: %v", query, err)
+	}
+	return res.GetTotal(), nil
+}
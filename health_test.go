@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetRepoHealth(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	totals := map[string]int{
+		"repo:acme/api is:pr is:merged merged:>=2026-02-13":                    3,
+		"repo:acme/api is:pr is:unmerged review:approved updated:>=2026-02-13": 2,
+	}
+	client, _ := newTestGitHubClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		total, ok := totals[r.URL.Query().Get("q")]
+		if r.URL.Path != "/search/issues" || !ok {
+			http.Error(w, "unexpected search", http.StatusUnprocessableEntity)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"total_count":%d,"items":[{"number":1}]}`, total)
+	}))
+	h, err := getRepoHealth(client, "acme", "api", 30*24*time.Hour, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Merged != 3 || h.Approved != 2 || h.Activity() != 5 {
+		t.Errorf("health %+v, want 3 merged and 2 approved", h)
+	}
+	if _, err := getRepoHealth(client, "acme", "web", 30*24*time.Hour, now); err == nil || !strings.Contains(err.Error(), "repo:acme/web is:pr is:merged") {
+		t.Errorf("getRepoHealth with a failing search returned %v, want an error naming the query", err)
+	}
+}
+
+func TestSoftenReason(t *testing.T) {
+	for _, tc := range []struct {
+		health repoHealth
+		min    int
+		want   string
+	}{
+		{repoHealth{Window: 30 * 24 * time.Hour, Merged: 2, Approved: 1}, 3, ""},
+		{repoHealth{Window: 30 * 24 * time.Hour, Merged: 5}, 1, ""},
+		{repoHealth{Window: 30 * 24 * time.Hour, Merged: 1, Approved: 1}, 3, "low maintainer activity: 1 merged and 1 approved PR(s) in the last 1 month, below the threshold of 3"},
+		{repoHealth{Window: 14 * 24 * time.Hour}, 1, "low maintainer activity: 0 merged and 0 approved PR(s) in the last 2 weeks, below the threshold of 1"},
+	} {
+		if got := softenReason(tc.health, tc.min); got != tc.want {
+			t.Errorf("softenReason(%+v, %d) = %q, want %q", tc.health, tc.min, got, tc.want)
+		}
+	}
+}
+
+// TestLowMaintainerActivitySoftensScan scans a repository with a warned PR
+// past its deadline while maintainers merged and approved nothing: the run
+// switches to warn-only mode and keeps the PR open, unless --no-auto-soften
+// is set.
+func TestLowMaintainerActivitySoftensScan(t *testing.T) {
+	updated := time.Now().AddDate(0, 0, -60).UTC().Format(time.RFC3339)
+	for _, tc := range []struct {
+		name       string
+		args       []string
+		wantClosed bool
+	}{
+		{name: "auto-soften", args: []string{"--auto-soften-min-activity", "1"}},
+		{name: "disabled", args: []string{"--auto-soften-min-activity", "1", "--no-auto-soften"}, wantClosed: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			gh := &fakeGitHub{get: map[string]string{
+				"/search/issues":          `{"total_count":0,"items":[]}`,
+				"/repos/acme/api/pulls":   `[{"number":7,"state":"open","title":"Add caching","user":{"login":"alice"},"labels":[{"name":"stale-warning"}],"updated_at":"` + updated + `"}]`,
+				"/repos/acme/api/pulls/7": `{"number":7,"state":"open","title":"Add caching","user":{"login":"alice"},"labels":[{"name":"stale-warning"}],"updated_at":"` + updated + `"}`,
+			}}
+			s := newTestRepoScanner(t, gh, nil, tc.args...)
+			if err := s.scan(); err != nil {
+				t.Fatal(err)
+			}
+			closed := false
+			for _, w := range gh.written() {
+				if strings.HasPrefix(w, "PATCH /repos/acme/api/issues/7 ") && strings.Contains(w, `"state":"closed"`) {
+					closed = true
+				}
+			}
+			if closed != tc.wantClosed {
+				t.Errorf("closed the stale PR: %v, want %v; writes %q", closed, tc.wantClosed, gh.written())
+			}
+			if softened := strings.HasPrefix(s.summary.Softened, "low maintainer activity: 0 merged and 0 approved"); softened == tc.wantClosed {
+				t.Errorf("softened %q, want warn-only mode: %v", s.summary.Softened, !tc.wantClosed)
+			}
+			if tc.wantClosed {
+				return
+			}
+			body, err := renderRunSummary(s.tmpl, "acme", "api", s.summary, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(body, "> **Warn-only mode:** low maintainer activity") {
+				t.Errorf("the run summary does not note warn-only mode:\n%s", body)
+			}
+		})
+	}
+}
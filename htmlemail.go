@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"strings"
+	"time"
+)
+
+// Email formats, selected with --email-format. Every HTML email also carries
+// the plain-text body as the fallback part of a multipart/alternative
+// message, so html and both send the same message.
+const (
+	emailFormatText = "text"
+	emailFormatHTML = "html"
+	emailFormatBoth = "both"
+)
+
+// defaultHTMLEmailTemplate wraps the plain-text body of a notification in
+// simple markup that renders well in Outlook: a link to the PR, then the body
+// with its paragraphs and line breaks kept.
+const defaultHTMLEmailTemplate = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Subject}}</title></head>
+<body style="font-family: 'Segoe UI', Arial, sans-serif; font-size: 14px; color: #1f2328;">
+<p style="font-size: 16px;"><a href="{{.URL}}" style="color: #0969da; font-weight: bold;">{{.KindShort}} #{{.Number}}: {{.Title}}</a><br>
+<span style="color: #59636e;">{{.Owner}}/{{.Repo}}</span></p>
+{{range .Paragraphs}}<p>{{range $i, $line := .}}{{if $i}}<br>
+{{end}}{{$line}}{{end}}</p>
+{{end}}</body>
+</html>
+`
+
+// htmlEmailData is the data passed to the HTML email template: the
+// notification's data, its subject, and its plain-text body split into
+// paragraphs of lines.
+type htmlEmailData struct {
+	notificationData
+	Subject    string
+	Paragraphs [][]string
+}
+
+func newHTMLEmailData(data notificationData, subject, text string) htmlEmailData {
+	var paragraphs [][]string
+	for _, p := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		if p = strings.Trim(p, "\n"); p != "" {
+			paragraphs = append(paragraphs, strings.Split(p, "\n"))
+		}
+	}
+	return htmlEmailData{notificationData: data, Subject: subject, Paragraphs: paragraphs}
+}
+
+// loadHTMLEmailTemplate parses the HTML email template from path, or the
+// built-in one if path is empty, with the notification template functions
+// rendering dates in loc. It is rendered against sample data so mistakes are
+// caught at startup.
+func loadHTMLEmailTemplate(path string, loc *time.Location) (*htmltemplate.Template, error) {
+	text := defaultHTMLEmailTemplate
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read HTML email template: %v", err)
+		}
+		text = string(b)
+	}
+	t, err := htmltemplate.New("html email").Funcs(htmltemplate.FuncMap(templateFuncs(loc))).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML email template: %v", err)
+	}
+	sample := newHTMLEmailData(notificationData{Location: loc}, "Subject", "Hello,\n\nBody.")
+	if err := t.Execute(&bytes.Buffer{}, sample); err != nil {
+		return nil, fmt.Errorf("failed to render HTML email template: %v", err)
+	}
+	return t, nil
+}
+
+// renderHTMLEmail renders the HTML part of a notification email whose
+// plain-text body is text.
+func renderHTMLEmail(t *htmltemplate.Template, data notificationData, subject, text string) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, newHTMLEmailData(data, subject, text)); err != nil {
+		return "", fmt.Errorf("failed to render HTML email: %v", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+const icsTimeFormat = "20060102T150405Z"
+
+// buildClosureICS renders an iCalendar VEVENT reminding the author of the
+// closure deadline for a warned PR. The UID is derived from the repository and
+// PR number so re-sent reminders update the same calendar entry.
+func buildClosureICS(owner, repo string, pr *github.PullRequest, deadline, now time.Time) []byte {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//stale-pr-bot//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"BEGIN:VEVENT",
+		fmt.Sprintf("UID:%s-%s-pr-%d@stale-pr-bot", strings.ToLower(owner), strings.ToLower(repo), pr.GetNumber()),
+		"DTSTAMP:" + now.UTC().Format(icsTimeFormat),
+		"DTSTART:" + deadline.UTC().Format(icsTimeFormat),
+		"DTEND:" + deadline.Add(30*time.Minute).UTC().Format(icsTimeFormat),
+		"SUMMARY:" + icsEscape(fmt.Sprintf("Stale PR #%d closes: %s", pr.GetNumber(), pr.GetTitle())),
+		"DESCRIPTION:" + icsEscape(fmt.Sprintf("Pull request #%d in %s/%s will be closed for inactivity unless it is updated.\n%s",
+			pr.GetNumber(), owner, repo, pr.GetHTMLURL())),
+		"URL:" + pr.GetHTMLURL(),
+		"TRANSP:TRANSPARENT",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}
+
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(icsFold(line))
+		b.WriteString("\r\n")
+	}
+	return []byte(b.String())
+}
+
+// icsEscape escapes TEXT values per RFC 5545 section 3.3.11.
+func icsEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, ";", `\;`)
+	s = strings.ReplaceAll(s, ",", `\,`)
+	s = strings.ReplaceAll(s, "\r\n", `\n`)
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
+
+// icsFold folds a content line so no physical line exceeds 75 octets, as
+// required by RFC 5545 section 3.1. Continuation lines start with a space and
+// multi-byte UTF-8 sequences are never split.
+func icsFold(line string) string {
+	const limit = 75
+	if len(line) <= limit {
+		return line
+	}
+	var b strings.Builder
+	width := 0
+	max := limit
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > max {
+			b.WriteString("\r\n ")
+			width = 0
+			max = limit - 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// parseICS checks the framing of an iCalendar object, CRLF line endings and
+// lines of at most 75 octets, and returns its unfolded content lines.
+func parseICS(t *testing.T, ics []byte) []string {
+	t.Helper()
+	s := string(ics)
+	if !strings.HasSuffix(s, "\r\n") {
+		t.Errorf("the calendar does not end with CRLF")
+	}
+	physical := strings.Split(strings.TrimSuffix(s, "\r\n"), "\r\n")
+	var lines []string
+	for _, line := range physical {
+		if strings.ContainsAny(line, "\r\n") {
+			t.Errorf("line %q has a bare CR or LF", line)
+		}
+		if len(line) > 75 {
+			t.Errorf("line %q is %d octets long, want at most 75", line, len(line))
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("line %q splits a UTF-8 sequence", line)
+		}
+		if strings.HasPrefix(line, " ") && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// icsProperty returns the value of the named property in lines.
+func icsProperty(lines []string, name string) string {
+	for _, line := range lines {
+		if v, ok := strings.CutPrefix(line, name+":"); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+func testICSPR() *github.PullRequest {
+	pr := testPR("alice", time.Now())
+	pr.Number = github.Ptr(42)
+	pr.Title = github.Ptr("Rework the retry loop; keep backoff, jitter and the circuit breaker in one place")
+	pr.HTMLURL = github.Ptr("https://github.com/acme/api/pull/42")
+	return pr
+}
+
+func TestBuildClosureICS(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip(err)
+	}
+	deadline := time.Date(2026, 4, 18, 15, 0, 0, 0, paris)
+	now := time.Date(2026, 4, 11, 8, 30, 0, 0, time.UTC)
+	lines := parseICS(t, buildClosureICS("Acme", "API", testICSPR(), deadline, now))
+
+	var structure []string
+	for _, line := range lines {
+		if strings.HasPrefix(line, "BEGIN:") || strings.HasPrefix(line, "END:") {
+			structure = append(structure, line)
+		}
+	}
+	if got, want := strings.Join(structure, " "), "BEGIN:VCALENDAR BEGIN:VEVENT END:VEVENT END:VCALENDAR"; got != want {
+		t.Errorf("components %s, want %s", got, want)
+	}
+	if lines[0] != "BEGIN:VCALENDAR" || lines[len(lines)-1] != "END:VCALENDAR" {
+		t.Errorf("the calendar starts with %q and ends with %q", lines[0], lines[len(lines)-1])
+	}
+
+	for _, tc := range []struct{ name, want string }{
+		{"VERSION", "2.0"},
+		{"METHOD", "PUBLISH"},
+		{"UID", "acme-api-pr-42@stale-pr-bot"},
+		{"DTSTAMP", "20260411T083000Z"},
+		{"DTSTART", "20260418T130000Z"},
+		{"DTEND", "20260418T133000Z"},
+		{"SUMMARY", `Stale PR #42 closes: Rework the retry loop\; keep backoff\, jitter and the circuit breaker in one place`},
+		{"DESCRIPTION", `Pull request #42 in Acme/API will be closed for inactivity unless it is updated.\nhttps://github.com/acme/api/pull/42`},
+		{"URL", "https://github.com/acme/api/pull/42"},
+	} {
+		if got := icsProperty(lines, tc.name); got != tc.want {
+			t.Errorf("%s = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	// Re-sent reminders must update the same event.
+	again := parseICS(t, buildClosureICS("acme", "api", testICSPR(), deadline.AddDate(0, 0, 3), now.AddDate(0, 0, 3)))
+	if got := icsProperty(again, "UID"); got != "acme-api-pr-42@stale-pr-bot" {
+		t.Errorf("UID of a re-sent reminder = %q, want it unchanged", got)
+	}
+}
+
+func TestICSFold(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		line string
+		// wantLines is how many physical lines the line folds into.
+		wantLines int
+	}{
+		{name: "short", line: "SUMMARY:short", wantLines: 1},
+		{name: "exactly 75 octets", line: "SUMMARY:" + strings.Repeat("a", 67), wantLines: 1},
+		{name: "76 octets", line: "SUMMARY:" + strings.Repeat("a", 68), wantLines: 2},
+		{name: "multi-byte", line: "SUMMARY:" + strings.Repeat("é", 100), wantLines: 3},
+	} {
+		folded := icsFold(tc.line)
+		physical := strings.Split(folded, "\r\n")
+		if len(physical) != tc.wantLines {
+			t.Errorf("%s: folded into %d lines, want %d", tc.name, len(physical), tc.wantLines)
+		}
+		for i, line := range physical {
+			if len(line) > 75 || !utf8.ValidString(line) || (i > 0 && !strings.HasPrefix(line, " ")) {
+				t.Errorf("%s: bad physical line %q", tc.name, line)
+			}
+		}
+		if unfolded := strings.ReplaceAll(folded, "\r\n ", ""); unfolded != tc.line {
+			t.Errorf("%s: unfolds to %q, want %q", tc.name, unfolded, tc.line)
+		}
+	}
+}
+
+func TestWarningEmailAttachesICS(t *testing.T) {
+	s := startTestSMTPServer(t, nil, false)
+	m := newTestMailer(t, s, smtpEncryptionNone, nil)
+	attachICS := true
+	scanner := &repoScanner{scanner: &scanner{flags: &cliFlags{attachICS: &attachICS}}, owner: "acme", repo: "api"}
+	pr := testICSPR()
+	attachments := scanner.warningAttachments(pr, time.Date(2026, 4, 18, 13, 0, 0, 0, time.UTC))
+	if err := m.deliverEmail(&prOutput{}, "Alice", "alice@example.com", "Your PR is stale", "Please update it.", "", attachments...); err != nil {
+		t.Fatal(err)
+	}
+	received, _ := s.emails()
+	if len(received) != 1 {
+		t.Fatalf("the server received %d emails, want 1", len(received))
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(received[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("email Content-Type %q, want multipart/mixed", msg.Header.Get("Content-Type"))
+	}
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			t.Fatal("the email has no text/calendar part")
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		mediaType, params, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if mediaType != "text/calendar" {
+			continue
+		}
+		if params["method"] != "PUBLISH" || params["charset"] != "utf-8" {
+			t.Errorf("calendar part Content-Type %q, want method=PUBLISH and charset=utf-8", part.Header.Get("Content-Type"))
+		}
+		if _, dparams, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition")); dparams["filename"] != "pr-42-deadline.ics" {
+			t.Errorf("calendar part Content-Disposition %q, want filename pr-42-deadline.ics", part.Header.Get("Content-Disposition"))
+		}
+		if enc := part.Header.Get("Content-Transfer-Encoding"); enc != "base64" {
+			t.Fatalf("calendar part encoded as %q, want base64 so CRLFs survive", enc)
+		}
+		raw, _ := io.ReadAll(part)
+		ics, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(raw)), ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(ics, attachments[0].Data) {
+			t.Errorf("attached calendar differs from the one built")
+		}
+		if got := icsProperty(parseICS(t, ics), "UID"); got != "acme-api-pr-42@stale-pr-bot" {
+			t.Errorf("attached calendar UID = %q", got)
+		}
+		return
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// listChangedPRNumbers reads the repository events feed, newest first, down to
+// the stored cursor and returns the numbers of PRs with new activity (comments,
+// reviews, label changes, pushes). It also returns the ID of the newest event
+// and whether the feed reached back to the cursor; when it did not, events may
+// have been missed and the caller should fall back to a full scan.
+func listChangedPRNumbers(client *github.Client, owner, repo, cursor string) ([]int, string, bool, error) {
+	cursorID, _ := strconv.ParseInt(cursor, 10, 64)
+	opts := &github.ListOptions{PerPage: 100}
+	ctx := context.Background()
+	seen := map[int]bool{}
+	var numbers []int
+	newest := ""
+	reached := false
+
+	for {
+		slog.Debug("fetching repository events", "repo", owner+"/"+repo, "page", opts.Page)
+		events, resp, err := client.Activity.ListRepositoryEvents(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, "", false, fmt.Errorf("error listing repository events: %v", err)
+		}
+		for _, event := range events {
+			id, err := strconv.ParseInt(event.GetID(), 10, 64)
+			if err != nil {
+				continue
+			}
+			if newest == "" {
+				newest = event.GetID()
+			}
+			if cursorID > 0 && id <= cursorID {
+				reached = true
+				break
+			}
+			number := eventPRNumber(event)
+			if number != 0 && !seen[number] {
+				seen[number] = true
+				numbers = append(numbers, number)
+			}
+		}
+		if reached || resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	if newest == "" {
+		// No events at all: nothing can have been missed.
+		newest = cursor
+		reached = true
+	}
+	return numbers, newest, reached, nil
+}
+
+// eventPRNumber returns the number of the PR an event refers to, or 0 if the
+// event does not concern a pull request.
+func eventPRNumber(event *github.Event) int {
+	payload, err := event.ParsePayload()
+	if err != nil {
+		return 0
+	}
+	switch p := payload.(type) {
+	case *github.PullRequestEvent:
+		return p.GetPullRequest().GetNumber()
+	case *github.PullRequestReviewEvent:
+		return p.GetPullRequest().GetNumber()
+	case *github.PullRequestReviewCommentEvent:
+		return p.GetPullRequest().GetNumber()
+	case *github.IssueCommentEvent:
+		if p.GetIssue().IsPullRequest() {
+			return p.GetIssue().GetNumber()
+		}
+	}
+	return 0
+}
+
+// dueDeadlines returns the numbers of warned PRs whose closure deadline has passed.
+func dueDeadlines(rs *repoState, now time.Time) []int {
+	var numbers []int
+	for number, deadline := range rs.Deadlines {
+		if !deadline.After(now) {
+			numbers = append(numbers, number)
+		}
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+// getPRsByNumber fetches the given PRs, skipping any that are no longer open.
+func getPRsByNumber(client *github.Client, owner, repo string, numbers []int) ([]*github.PullRequest, error) {
+	ctx := context.Background()
+	seen := map[int]bool{}
+	var prs []*github.PullRequest
+	for _, number := range numbers {
+		if seen[number] {
+			continue
+		}
+		seen[number] = true
+		pr, _, err := client.PullRequests.Get(ctx, owner, repo, number)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching PR #%d: %v", number, err)
+		}
+		if pr.GetState() != "open" {
+			continue
+		}
+		prs = append(prs, pr)
+	}
+	return prs, nil
+}
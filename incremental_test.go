@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// serveTestEvents serves the repository events feed of acme/api in two pages,
+// newest first, and the PRs the events refer to. It counts the requests for
+// the second events page and for the list of open PRs.
+func serveTestEvents(t *testing.T) (*github.Client, *int, *int) {
+	t.Helper()
+	var srv *httptest.Server
+	secondPage, listed := 0, 0
+	client, srv := newTestGitHubClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/repos/acme/api/events":
+			if r.URL.Query().Get("page") != "2" {
+				w.Header().Set("Link", fmt.Sprintf(`<%s/repos/acme/api/events?page=2>; rel="next"`, srv.URL))
+				fmt.Fprint(w, `[
+					{"id":"105","type":"IssueCommentEvent","payload":{"issue":{"number":7,"pull_request":{"url":"x"}}}},
+					{"id":"104","type":"PullRequestEvent","payload":{"pull_request":{"number":8}}},
+					{"id":"103","type":"IssueCommentEvent","payload":{"issue":{"number":9}}}
+				]`)
+				return
+			}
+			secondPage++
+			fmt.Fprint(w, `[
+				{"id":"102","type":"PullRequestReviewEvent","payload":{"pull_request":{"number":7}}},
+				{"id":"101","type":"PushEvent","payload":{}},
+				{"id":"100","type":"PullRequestReviewCommentEvent","payload":{"pull_request":{"number":6}}}
+			]`)
+		case "/repos/acme/api/pulls":
+			listed++
+			fmt.Fprint(w, `[{"number":6,"state":"open"},{"number":7,"state":"open"},{"number":8,"state":"open"}]`)
+		case "/repos/acme/api/pulls/6", "/repos/acme/api/pulls/7", "/repos/acme/api/pulls/8":
+			fmt.Fprintf(w, `{"number":%s,"state":"open"}`, r.URL.Path[len("/repos/acme/api/pulls/"):])
+		case "/repos/acme/api/pulls/5":
+			fmt.Fprint(w, `{"number":5,"state":"closed"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return client, &secondPage, &listed
+}
+
+func TestListChangedPRNumbers(t *testing.T) {
+	tests := []struct {
+		cursor     string
+		want       string
+		reached    bool
+		secondPage int
+	}{
+		// The cursor is on the first page: the second one is not fetched.
+		{cursor: "104", want: "[7]", reached: true},
+		{cursor: "102", want: "[7 8]", reached: true, secondPage: 1},
+		{cursor: "100", want: "[7 8]", reached: true, secondPage: 1},
+		// The feed ends before the cursor: events may have been missed.
+		{cursor: "50", want: "[7 8 6]", reached: false, secondPage: 1},
+		{cursor: "", want: "[7 8 6]", reached: false, secondPage: 1},
+	}
+	for _, tt := range tests {
+		client, secondPage, _ := serveTestEvents(t)
+		numbers, newest, reached, err := listChangedPRNumbers(client, "acme", "api", tt.cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(numbers) != tt.want || newest != "105" || reached != tt.reached {
+			t.Errorf("listChangedPRNumbers(cursor %q) = %v, %q, %v, want %s, \"105\", %v", tt.cursor, numbers, newest, reached, tt.want, tt.reached)
+		}
+		if *secondPage != tt.secondPage {
+			t.Errorf("cursor %q: fetched the second events page %d times, want %d", tt.cursor, *secondPage, tt.secondPage)
+		}
+	}
+}
+
+func TestListChangedPRNumbersEmptyFeed(t *testing.T) {
+	client, _ := newTestGitHubClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	}))
+	numbers, newest, reached, err := listChangedPRNumbers(client, "acme", "api", "104")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(numbers) != 0 || newest != "104" || !reached {
+		t.Errorf("listChangedPRNumbers on an empty feed = %v, %q, %v, want no PRs and the cursor kept", numbers, newest, reached)
+	}
+}
+
+func TestDueDeadlines(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	rs := &repoState{}
+	rs.initMaps()
+	rs.Deadlines[4] = now.Add(time.Hour)
+	rs.Deadlines[9] = now
+	rs.Deadlines[2] = now.Add(-48 * time.Hour)
+	if got := dueDeadlines(rs, now); fmt.Sprint(got) != "[2 9]" {
+		t.Errorf("dueDeadlines returned %v, want [2 9]", got)
+	}
+}
+
+// TestIncrementalScanRecoversMissedEvents runs incremental scans against the
+// events feed: a run whose cursor fell off the feed, or whose full-scan
+// cadence is due, lists every open PR; otherwise only the PRs with new events
+// and those past their deadline are fetched.
+func TestIncrementalScanRecoversMissedEvents(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name         string
+		cursor       string
+		lastFullScan time.Time
+		wantFull     bool
+		wantMissed   bool
+	}{
+		{name: "cursor reached", cursor: "104", lastFullScan: now.Add(-time.Hour)},
+		{name: "events missed", cursor: "50", lastFullScan: now.Add(-time.Hour), wantFull: true, wantMissed: true},
+		{name: "no cursor yet", lastFullScan: now.Add(-time.Hour), wantFull: true, wantMissed: true},
+		{name: "full scan due", cursor: "104", lastFullScan: now.Add(-25 * time.Hour), wantFull: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := captureLog(t)
+			client, _, listed := serveTestEvents(t)
+			incremental, interval, includeIssues := true, 24*time.Hour, false
+			rs := &repoState{EventsCursor: tt.cursor, LastFullScan: tt.lastFullScan}
+			rs.initMaps()
+			// PR #5 was closed since it was warned; #4 is not due yet.
+			rs.Deadlines[5] = now.Add(-time.Minute)
+			rs.Deadlines[4] = now.Add(time.Hour)
+			s := &repoScanner{
+				scanner: &scanner{
+					client: client,
+					flags:  &cliFlags{incremental: &incremental, fullScanInterval: &interval, includeIssues: &includeIssues},
+				},
+				owner:   "acme",
+				repo:    "api",
+				repoSt:  rs,
+				issues:  map[int]bool{},
+				logger:  slog.Default(),
+				summary: &runSummary{SLA: &slaSample{}},
+			}
+			if err := s.listPRs(); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []int
+			for _, pr := range s.openPRs {
+				got = append(got, pr.GetNumber())
+			}
+			// An incremental scan fetches #7, which has new events, and #5,
+			// which is past its deadline but no longer open.
+			want := "[7]"
+			if tt.wantFull {
+				want = "[6 7 8]"
+			}
+			if fmt.Sprint(got) != want {
+				t.Errorf("evaluated PRs %v, want %s", got, want)
+			}
+			if s.fullScan != tt.wantFull || (*listed == 1) != tt.wantFull {
+				t.Errorf("full scan %v, listed open PRs %d times, want full scan %v", s.fullScan, *listed, tt.wantFull)
+			}
+			if missed := strings.Contains(log.String(), "events may have been missed"); missed != tt.wantMissed {
+				t.Errorf("warned about missed events: %v, want %v", missed, tt.wantMissed)
+			}
+			if rs.EventsCursor != "105" {
+				t.Errorf("stored events cursor %q, want 105", rs.EventsCursor)
+			}
+			if tt.wantFull {
+				if !rs.LastFullScan.After(tt.lastFullScan) {
+					t.Error("a full scan did not record its time")
+				}
+				if _, ok := rs.Deadlines[5]; ok {
+					t.Error("the full scan kept the deadline of a PR that is no longer open")
+				}
+			} else if !rs.LastFullScan.Equal(tt.lastFullScan) {
+				t.Error("an incremental scan recorded a full scan")
+			}
+		})
+	}
+}
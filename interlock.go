@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// defaultCloseInterlockThreshold is how many PRs of a repository a run may
+// close without --enable-close.
+const defaultCloseInterlockThreshold = 10
+
+// closeInterlock keeps a run from closing PRs in bulk by accident, e.g. with
+// production credentials in what was meant to be a dry run. Above threshold
+// closures per repository, an unattended run needs --enable-close, and an
+// interactive one asks for confirmation; otherwise the repository is switched
+// to warn-only mode.
+type closeInterlock struct {
+	// enabled lifts the interlock: --enable-close was set, or the run is
+	// read-only and closes nothing anyway.
+	enabled   bool
+	threshold int
+	// interactive is set when a person can answer the prompt on in.
+	interactive bool
+	in          *bufio.Reader
+	out         io.Writer
+}
+
+// newCloseInterlock returns the interlock for the run, prompting on in and
+// out when isTTY reports that in is a terminal. isTTY is only called when the
+// interlock is not lifted.
+func newCloseInterlock(enabled bool, threshold int, in io.Reader, out io.Writer, isTTY func(io.Reader) bool) *closeInterlock {
+	return &closeInterlock{
+		enabled:     enabled,
+		threshold:   threshold,
+		interactive: !enabled && isTTY(in),
+		in:          bufio.NewReader(in),
+		out:         out,
+	}
+}
+
+// isTerminalInput reports whether r is a terminal a person can answer
+// prompts on.
+func isTerminalInput(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	return ok && isInteractive(f)
+}
+
+// check is called before acting on repo, whose evaluation would close n PRs.
+// It returns why the closures must not happen, for the warn-only notice, or
+// "" if they may.
+func (c *closeInterlock) check(repo string, n int) string {
+	if c.enabled || n <= c.threshold {
+		return ""
+	}
+	if !c.interactive {
+		return fmt.Sprintf("%d %s would be closed, more than --close-interlock-threshold %d, and --enable-close is not set", n, pluralize(n, "PR", "PRs"), c.threshold)
+	}
+	fmt.Fprintf(c.out, "This run would close %d %s of %s. Close them? [y/N] ", n, pluralize(n, "PR", "PRs"), repo)
+	answer, _ := c.in.ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return ""
+	}
+	return fmt.Sprintf("closing %d %s was not confirmed", n, pluralize(n, "PR", "PRs"))
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCloseInterlock(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		enabled bool
+		tty     bool
+		answer  string
+		n       int
+		// want is part of the reason the closures must not happen, or ""
+		// if they may.
+		want string
+		// prompted is whether the person is asked.
+		prompted bool
+	}{
+		{name: "under the threshold", n: 10},
+		{name: "unattended", n: 11, want: "11 PRs would be closed, more than --close-interlock-threshold 10, and --enable-close is not set"},
+		{name: "enabled", enabled: true, n: 500},
+		{name: "enabled on a terminal", enabled: true, tty: true, answer: "n\n", n: 500},
+		{name: "accepted", tty: true, answer: "y\n", n: 11, prompted: true},
+		{name: "accepted in full", tty: true, answer: " YES \n", n: 11, prompted: true},
+		{name: "declined", tty: true, answer: "n\n", n: 11, want: "closing 11 PRs was not confirmed", prompted: true},
+		{name: "no answer", tty: true, answer: "", n: 11, want: "was not confirmed", prompted: true},
+		{name: "terminal under the threshold", tty: true, n: 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			in := strings.NewReader(tc.answer)
+			var prompt strings.Builder
+			var checked io.Reader
+			c := newCloseInterlock(tc.enabled, defaultCloseInterlockThreshold, in, &prompt, func(r io.Reader) bool {
+				checked = r
+				return tc.tty
+			})
+			if tc.enabled && checked != nil {
+				t.Error("the TTY check ran with --enable-close set")
+			}
+			if !tc.enabled && checked != in {
+				t.Errorf("the TTY check was given %v, want the prompt input", checked)
+			}
+
+			got := c.check("acme/api", tc.n)
+			if (got == "") != (tc.want == "") || !strings.Contains(got, tc.want) {
+				t.Errorf("check = %q, want %q", got, tc.want)
+			}
+			if tc.prompted != (prompt.Len() > 0) {
+				t.Errorf("prompted %q, want a prompt %v", prompt.String(), tc.prompted)
+			}
+			if tc.prompted && !strings.Contains(prompt.String(), "close 11 PRs of acme/api") {
+				t.Errorf("the prompt %q does not give the count and repository", prompt.String())
+			}
+			if !tc.prompted && in.Len() != len(tc.answer) {
+				t.Error("the interlock read an answer without prompting")
+			}
+		})
+	}
+}
+
+func TestCloseInterlockAsksPerRepository(t *testing.T) {
+	var prompt strings.Builder
+	c := newCloseInterlock(false, 1, strings.NewReader("y\nn\n"), &prompt, func(io.Reader) bool { return true })
+	if got := c.check("acme/api", 2); got != "" {
+		t.Errorf("acme/api: check = %q after accepting", got)
+	}
+	if got := c.check("acme/web", 3); !strings.Contains(got, "closing 3 PRs was not confirmed") {
+		t.Errorf("acme/web: check = %q after declining", got)
+	}
+	if got := strings.Count(prompt.String(), "[y/N]"); got != 2 {
+		t.Errorf("prompted %d times, want once per repository", got)
+	}
+}
+
+func TestIsTerminalInput(t *testing.T) {
+	null, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer null.Close()
+	if isTerminalInput(null) {
+		t.Errorf("%s is taken for a terminal", os.DevNull)
+	}
+	f, err := os.Open(writeTestFile(t, "stdin", "y\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if isTerminalInput(f) {
+		t.Error("a regular file is taken for a terminal")
+	}
+	if isTerminalInput(strings.NewReader("y\n")) {
+		t.Error("a reader that is not a file is taken for a terminal")
+	}
+}
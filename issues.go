@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// Kinds of items the bot processes, as named in notifications.
+const (
+	kindPullRequest = "pull request"
+	kindIssue       = "issue"
+)
+
+// getOpenIssues returns the open issues of a repository. The issues API also
+// lists pull requests; they are left out.
+func getOpenIssues(client *github.Client, owner, repo string) ([]*github.Issue, error) {
+	ctx := context.Background()
+	opt := &github.IssueListByRepoOptions{State: "open", ListOptions: github.ListOptions{PerPage: 100}}
+	var issues []*github.Issue
+	for {
+		page, resp, err := client.Issues.ListByRepo(ctx, owner, repo, opt)
+		if err != nil {
+			return nil, fmt.Errorf("error listing issues: %v", err)
+		}
+		for _, issue := range page {
+			if !issue.IsPullRequest() {
+				issues = append(issues, issue)
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return issues, nil
+}
+
+// issueAsPR converts an issue to the PR type the decision and notification
+// code works on, as explain does for synthetic PRs. Fields only PRs have,
+// such as the head branch, are left unset.
+func issueAsPR(issue *github.Issue) *github.PullRequest {
+	return &github.PullRequest{
+		Number:    issue.Number,
+		Title:     issue.Title,
+		Body:      issue.Body,
+		State:     issue.State,
+		User:      issue.User,
+		Labels:    issue.Labels,
+		Milestone: issue.Milestone,
+		HTMLURL:   issue.HTMLURL,
+		CreatedAt: issue.CreatedAt,
+		UpdatedAt: issue.UpdatedAt,
+		ClosedAt:  issue.ClosedAt,
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetOpenIssues(t *testing.T) {
+	client, _ := newTestGitHubClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/acme/api/issues" || r.URL.Query().Get("state") != "open" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, `[{"number":3},{"number":4,"pull_request":{"url":"https://api.github.com/repos/acme/api/pulls/4"}}]`)
+			return
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s/repos/acme/api/issues?state=open&page=2>; rel="next"`, r.Host))
+		fmt.Fprint(w, `[{"number":1},{"number":2,"pull_request":{"url":"https://api.github.com/repos/acme/api/pulls/2"}}]`)
+	}))
+	issues, err := getOpenIssues(client, "acme", "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var numbers []int
+	for _, issue := range issues {
+		numbers = append(numbers, issue.GetNumber())
+	}
+	if fmt.Sprint(numbers) != "[1 3]" {
+		t.Errorf("listed issues %v, want 1 and 3 without the pull requests", numbers)
+	}
+}
+
+// TestScanIncludesIssues scans a repository with --include-issues: stale
+// issues go through the same lifecycle as PRs, with the same exempt and
+// warning labels, are closed with the issues API and are called issues in
+// their notifications.
+func TestScanIncludesIssues(t *testing.T) {
+	old := time.Now().AddDate(0, 0, -60).UTC().Format(time.RFC3339)
+	item := func(number int, labels ...string) string {
+		var ls []string
+		for _, l := range labels {
+			ls = append(ls, fmt.Sprintf(`{"name":%q}`, l))
+		}
+		return fmt.Sprintf(`{"number":%d,"state":"open","title":"Item %d","user":{"login":"alice"},"labels":[%s],"updated_at":%q}`, number, number, strings.Join(ls, ","), old)
+	}
+	pr := item(1)
+	issues := []string{
+		item(5, "stale-warning"),
+		item(6, "pinned"),
+		item(7),
+		// The issues API lists PR #1 too.
+		strings.TrimSuffix(pr, "}") + `,"pull_request":{"url":"https://api.github.com/repos/acme/api/pulls/1"}}`,
+	}
+	for _, include := range []bool{false, true} {
+		t.Run(fmt.Sprintf("include-issues=%v", include), func(t *testing.T) {
+			gh := &fakeGitHub{get: map[string]string{
+				"/repos/acme/api/pulls":    "[" + pr + "]",
+				"/repos/acme/api/issues":   "[" + strings.Join(issues, ",") + "]",
+				"/repos/acme/api/issues/5": issues[0],
+				"/search/issues":           `{"total_count":5}`,
+			}}
+			s := newTestRepoScanner(t, gh, nil, fmt.Sprintf("--include-issues=%v", include))
+			if err := s.scan(); err != nil {
+				t.Fatal(err)
+			}
+			byNumber := map[int][]string{}
+			for _, w := range gh.written() {
+				var number int
+				fmt.Sscanf(w[strings.Index(w, "/issues/")+len("/issues/"):], "%d", &number)
+				byNumber[number] = append(byNumber[number], w)
+			}
+			if !include {
+				if len(byNumber) != 1 || len(byNumber[1]) == 0 {
+					t.Errorf("writes %q, want only PR #1 warned", byNumber)
+				}
+				return
+			}
+			if s.summary.Evaluated != 4 {
+				t.Errorf("evaluated %d items, want PR #1 and issues #5, #6 and #7", s.summary.Evaluated)
+			}
+			for number, want := range map[int][]string{
+				1: {`POST /repos/acme/api/issues/1/labels ["stale-warning"]`, "Your pull request #1"},
+				5: {`PATCH /repos/acme/api/issues/5 {"state":"closed"}`, "Your issue #5"},
+				7: {`POST /repos/acme/api/issues/7/labels ["stale-warning"]`, "Your issue #7"},
+			} {
+				for _, w := range want {
+					if !strings.Contains(strings.Join(byNumber[number], "\n"), w) {
+						t.Errorf("writes for #%d %q, want %q", number, byNumber[number], w)
+					}
+				}
+			}
+			if len(byNumber[6]) != 0 {
+				t.Errorf("the exempt issue #6 was written to: %q", byNumber[6])
+			}
+		})
+	}
+}
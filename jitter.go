@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// startJitter returns a uniformly random delay in [0, max) so that instances
+// started by the same cron entry do not hit GitHub at the same moment. rnd
+// returns a random number in [0, n), e.g. rand.Int64N.
+func startJitter(max time.Duration, rnd func(n int64) int64) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rnd(int64(max)))
+}
+
+// isInteractive reports whether f is a terminal, i.e. a person started the
+// run and should not be kept waiting. /dev/null, which schedulers commonly
+// attach to stdin, is a character device too but not a terminal.
+func isInteractive(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// labelEvent returns an issue event of kind ("labeled" or "unlabeled") for
+// label at t.
+func labelEvent(kind, label string, t time.Time) *github.IssueEvent {
+	return &github.IssueEvent{
+		Event:     github.Ptr(kind),
+		Label:     &github.Label{Name: github.Ptr(label)},
+		CreatedAt: &github.Timestamp{Time: t},
+	}
+}
+
+func TestLastLabeled(t *testing.T) {
+	day1 := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	day2, day3 := day1.AddDate(0, 0, 1), day1.AddDate(0, 0, 2)
+	for _, tc := range []struct {
+		name   string
+		events []*github.IssueEvent
+		want   time.Time
+	}{
+		{name: "no events"},
+		{name: "labeled", events: []*github.IssueEvent{labelEvent("labeled", "stale-warning", day1)}, want: day1},
+		{name: "label name ignores case", events: []*github.IssueEvent{labelEvent("labeled", "Stale-Warning", day1)}, want: day1},
+		{name: "other labels", events: []*github.IssueEvent{labelEvent("labeled", "wip", day1)}},
+		{name: "unlabeled", events: []*github.IssueEvent{labelEvent("unlabeled", "stale-warning", day1)}},
+		{
+			name: "relabeled",
+			events: []*github.IssueEvent{
+				labelEvent("labeled", "stale-warning", day1),
+				labelEvent("unlabeled", "stale-warning", day2),
+				labelEvent("labeled", "stale-warning", day3),
+			},
+			want: day3,
+		},
+		{
+			name: "out of order",
+			events: []*github.IssueEvent{
+				labelEvent("labeled", "stale-warning", day3),
+				labelEvent("labeled", "stale-warning", day1),
+			},
+			want: day3,
+		},
+		{
+			name: "other events",
+			events: []*github.IssueEvent{
+				labelEvent("labeled", "stale-warning", day1),
+				{Event: github.Ptr("commented"), CreatedAt: &github.Timestamp{Time: day2}},
+				{Event: github.Ptr("labeled"), CreatedAt: &github.Timestamp{Time: day3}},
+			},
+			want: day1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := lastLabeled(tc.events, "stale-warning"); !got.Equal(tc.want) {
+				t.Errorf("lastLabeled = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTimeSinceLabel(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	pr := testPR("alice", now.AddDate(0, 0, -2), "stale-warning")
+	if got, want := timeSinceLabel(pr, now.AddDate(0, 0, -5), now), 5*24*time.Hour; got != want {
+		t.Errorf("with the label added 5 days ago timeSinceLabel = %v, want %v", got, want)
+	}
+	if got, want := timeSinceLabel(pr, time.Time{}, now), 2*24*time.Hour; got != want {
+		t.Errorf("without the labeling time timeSinceLabel = %v, want the time since the update, %v", got, want)
+	}
+}
+
+func TestGetLabeledAt(t *testing.T) {
+	labeled := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	mux := http.NewServeMux()
+	var srv *httptest.Server
+	// The events are listed on two pages, the labeling on the second.
+	mux.HandleFunc("/repos/acme/api/issues/7/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("page") != "2" {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/repos/acme/api/issues/7/events?page=2>; rel="next"`, srv.URL))
+			fmt.Fprint(w, `[{"event":"labeled","label":{"name":"stale-warning"},"created_at":"2026-03-01T09:00:00Z"},{"event":"unlabeled","label":{"name":"stale-warning"},"created_at":"2026-03-01T10:00:00Z"}]`)
+			return
+		}
+		fmt.Fprintf(w, `[{"event":"labeled","label":{"name":"stale-warning"},"created_at":%q},{"event":"labeled","label":{"name":"wip"},"created_at":"2026-03-03T09:00:00Z"}]`, labeled.Format(time.RFC3339))
+	})
+	mux.HandleFunc("/repos/acme/api/issues/8/events", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"Server Error"}`, http.StatusInternalServerError)
+	})
+	srv = httptest.NewServer(mux)
+	defer srv.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+
+	got, err := getLabeledAt(client, "acme", "api", 7, "stale-warning")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(labeled) {
+		t.Errorf("getLabeledAt = %v, want %v", got, labeled)
+	}
+	if _, err := getLabeledAt(client, "acme", "api", 8, "stale-warning"); err == nil {
+		t.Error("getLabeledAt succeeded though the events could not be listed")
+	}
+}
@@ -1,30 +1,34 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"flag"
 	"fmt"
+	htmltemplate "html/template"
+	"io"
 	"log"
+	"log/slog"
+	"math/rand/v2"
 	"net"
+	"net/http"
 	"net/smtp"
 	"net/url"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/google/go-github/v68/github"
-	"github.com/joho/godotenv"
 	"github.com/jordan-wright/email"
 	"golang.org/x/oauth2"
 )
 
-// Global variable to hold the fallback email domain.
-var fallbackEmailDomain string
-
-// printBanner prints a beautified header banner for the tool.
-func printBanner() {
+// printBanner prints a beautified header banner for the tool to w.
+func printBanner(w io.Writer) {
 	banner := `
 #############################################################
 #                                                           #
@@ -32,196 +36,917 @@ func printBanner() {
 #                                                           #
 ############################################################-
 `
-	fmt.Println(banner)
+	fmt.Fprintln(w, banner)
 }
 
 func main() {
-	printBanner()
-
-	// Load .env file (if available)
-	if err := godotenv.Load(); err != nil {
-		log.Printf("No .env file found or error loading it: %v", err)
-	}
-
-	// Get defaults from environment variables (if available)
-	defaultGithubToken := os.Getenv("GITHUB_TOKEN")
-	defaultGithubBaseURL := os.Getenv("GITHUB_BASE_URL")
-	defaultOwner := os.Getenv("GITHUB_OWNER")
-	defaultRepo := os.Getenv("GITHUB_REPO")
-	defaultDaysInactive := 0
-	if v := os.Getenv("DAYS_INACTIVE"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil {
-			defaultDaysInactive = n
-		}
-	}
-	defaultWarningPeriod := 0
-	if v := os.Getenv("WARNING_PERIOD"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil {
-			defaultWarningPeriod = n
-		}
-	}
-	defaultSMTPServer := os.Getenv("SMTP_SERVER")
-	defaultSMTPPort := 587
-	if v := os.Getenv("SMTP_PORT"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil {
-			defaultSMTPPort = n
-		}
-	}
-	defaultSMTPUser := os.Getenv("SMTP_USER")
-	defaultSMTPPassword := os.Getenv("SMTP_PASSWORD")
-	// New default for the email fallback domain.
-	defaultEmailDomain := os.Getenv("EMAIL_DOMAIN")
-	if defaultEmailDomain == "" {
-		defaultEmailDomain = "example.com"
-	}
-
-	// Define command-line flags.
-	githubTokenFlag := flag.String("github-token", defaultGithubToken, "GitHub API token")
-	githubBaseURLFlag := flag.String("github-base-url", defaultGithubBaseURL, "GitHub API base URL (e.g. https://api.github.com/)")
-	ownerFlag := flag.String("owner", defaultOwner, "GitHub repository owner")
-	repoFlag := flag.String("repo", defaultRepo, "GitHub repository name")
-	daysInactiveFlag := flag.Int("days-inactive", defaultDaysInactive, "Number of days to consider a PR stale")
-	warningPeriodFlag := flag.Int("warning-period", defaultWarningPeriod, "Warning period in days before closing stale PR")
-	smtpServerFlag := flag.String("smtp-server", defaultSMTPServer, "SMTP server address")
-	smtpPortFlag := flag.Int("smtp-port", defaultSMTPPort, "SMTP server port")
-	smtpUserFlag := flag.String("smtp-user", defaultSMTPUser, "SMTP username")
-	smtpPasswordFlag := flag.String("smtp-password", defaultSMTPPassword, "SMTP password")
-	emailDomainFlag := flag.String("email-domain", defaultEmailDomain, "Fallback email domain (used when GitHub user's public email is unavailable)")
-	flag.Parse()
-
-	// Set the fallback email domain globally.
-	fallbackEmailDomain = *emailDomainFlag
+	os.Exit(run())
+}
+
+// run runs the bot and returns its exit code. main exits only once run has
+// returned, so the log and event files it defers closing are flushed.
+func run() int {
+	started := time.Now()
+
+	// Load the .env file into the environment before the flags are set from
+	// it, and before any subcommand, so that they all see its settings.
+	// Variables already set take precedence over it.
+	loader := newConfigLoader(flag.CommandLine)
+	if path, disabled := dotenvOptions(os.Args[1:]); !disabled {
+		if err := loader.loadDotenv(path); err != nil {
+			return fatalf("Error loading .env file: %v", err)
+		}
+	}
+
+	if len(os.Args) > 1 {
+		if code, ok := runSubcommand(os.Args[1], os.Args[2:], os.Stdout); ok {
+			return code
+		}
+	}
+
+	flags := defineFlags(flag.CommandLine)
+	flag.Usage = usage
+	if err := loader.parse(os.Args[1:]); err != nil {
+		return fatalf("%v", err)
+	}
+
+	// Logs go to stdout, or to stderr when stdout carries ndjson events, or
+	// to --log-file.
+	logLevel, err := parseLogLevel(*flags.logLevel)
+	if err != nil {
+		return fatalf("%v", err)
+	}
+	if *flags.logMaxSize < 0 || *flags.logMaxBackups < 0 || *flags.logMaxAge < 0 {
+		return fatalf("Invalid --log-max-size, --log-max-backups or --log-max-age: must not be negative.")
+	}
+	rotate := rotation{maxSize: int64(*flags.logMaxSize), maxBackups: *flags.logMaxBackups, maxAge: *flags.logMaxAge, now: time.Now}
+	var logOutput io.Writer = os.Stdout
+	if *flags.output == "ndjson" {
+		logOutput = os.Stderr
+	}
+	if *flags.logFile != "" {
+		logFile, err := openRotatingFile(*flags.logFile, rotate)
+		if err != nil {
+			return fatalf("Error opening log file: %v", err)
+		}
+		defer logFile.Close()
+		logOutput = logFile
+	}
+	if err := setupLogging(logOutput, logLevel, *flags.logFormat); err != nil {
+		return fatalf("%v", err)
+	}
+
+	if *flags.config != "" {
+		fileCfg, err := loadConfigFile(*flags.config)
+		if err != nil {
+			return fatalf("Error loading config file: %v", err)
+		}
+		if err := loader.applyFile(fileCfg); err != nil {
+			return fatalf("Error in config file %s: %v", *flags.config, err)
+		}
+	}
+	// Without a config file, read a probot/stale one to ease migrating.
+	var probotPath string
+	var probotTemplates []probotTemplate
+	if *flags.config == "" {
+		probotPath, err = findProbotConfig(*flags.probotConfig)
+		if err != nil {
+			return fatalf("Error loading probot/stale configuration: %v", err)
+		}
+	}
+	if probotPath != "" {
+		conv, err := loadProbotConfig(probotPath)
+		if err != nil {
+			return fatalf("Error loading probot/stale configuration: %v", err)
+		}
+		slog.Info("using the probot/stale configuration; the convert-config subcommand converts it to a config file", "file", probotPath)
+		for _, w := range conv.Warnings {
+			slog.Warn("probot/stale setting not fully converted", "file", probotPath, "key", w.Key, "reason", w.Reason)
+		}
+		if err := loader.applyFile(conv.Config); err != nil {
+			return fatalf("Error in probot/stale configuration %s: %v", probotPath, err)
+		}
+		probotTemplates = conv.Templates
+	}
+	if *flags.printConfig {
+		if err := loader.printEffectiveConfig(os.Stdout); err != nil {
+			return fatalf("Error printing configuration: %v", err)
+		}
+		return exitSuccess
+	}
+
+	if *flags.dryRun {
+		*flags.readOnly = true
+	}
+
+	// In ndjson mode stdout carries only events; everything printed for humans
+	// goes to stderr instead.
+	var events *eventStream
+	var humanOutput io.Writer = os.Stdout
+	switch *flags.output {
+	case "text":
+		if *flags.eventsFile != "" {
+			eventsFile, err := openRotatingFile(*flags.eventsFile, rotate)
+			if err != nil {
+				return fatalf("Error opening events file: %v", err)
+			}
+			defer eventsFile.Close()
+			events = newEventStream(eventsFile)
+		}
+	case "ndjson":
+		if *flags.eventsFile != "" {
+			return fatalf("--events-file and --output ndjson both take the event stream; use one of them.")
+		}
+		events = newEventStream(os.Stdout)
+		humanOutput = os.Stderr
+	default:
+		return fatalf("Invalid output format %q: must be text or ndjson.", *flags.output)
+	}
+	switch *flags.authorStats {
+	case authorStatsOff, authorStatsInternal, authorStatsFull:
+	default:
+		return fatalf("Invalid author stats %q: must be %s, %s or %s.", *flags.authorStats, authorStatsOff, authorStatsInternal, authorStatsFull)
+	}
+	if *flags.reportDetail != reportDetailBasic && *flags.reportDetail != reportDetailFull {
+		return fatalf("Invalid report detail %q: must be %s or %s.", *flags.reportDetail, reportDetailBasic, reportDetailFull)
+	}
+	if *flags.logFormat == logFormatText && logLevel <= slog.LevelInfo {
+		printBanner(humanOutput)
+	}
+
+	cfg := &config{
+		FallbackEmailDomain: *flags.emailDomain,
+		NoEmailGuess:        *flags.noEmailGuess,
+		DisplayLocation:     time.UTC,
+		Timezones:           userTimezones{},
+		SMTPServer:          *flags.smtpServer,
+		SMTPPort:            *flags.smtpPort,
+		SMTPUser:            *flags.smtpUser,
+		SMTPPassword:        *flags.smtpPassword,
+		SMTPFrom:            *flags.smtpFrom,
+		NotifyVia:           *flags.notifyVia,
+		SlackWebhookURL:     *flags.slackWebhookURL,
+		SlackChannel:        *flags.slackChannel,
+		TeamsWebhookURL:     *flags.teamsWebhookURL,
+		GreetingName:        *flags.greetingName,
+	}
+	if cfg.GreetingName != greetDisplayName && cfg.GreetingName != greetLogin {
+		return fatalf("Invalid --greeting-name %q: must be %s or %s.", cfg.GreetingName, greetDisplayName, greetLogin)
+	}
+	switch cfg.NotifyVia {
+	case notifyEmail, notifyComment, notifyBoth, notifySlack, notifyTeams:
+	default:
+		return fatalf("Invalid --notify-via %q: must be email, comment, both, slack or teams.", cfg.NotifyVia)
+	}
+	if *flags.notificationPrefsFile != "" {
+		var err error
+		cfg.NotifyPrefs, err = loadNotificationPrefs(*flags.notificationPrefsFile)
+		if err != nil {
+			return fatalf("Error loading notification preferences: %v", err)
+		}
+	}
+	if *flags.timezone != "" {
+		loc, err := time.LoadLocation(*flags.timezone)
+		if err != nil {
+			return fatalf("Invalid timezone %q: %v", *flags.timezone, err)
+		}
+		cfg.DisplayLocation = loc
+	}
+	if *flags.emailMap != "" {
+		var err error
+		cfg.EmailMap, err = loadEmailMap(*flags.emailMap)
+		if err != nil {
+			return fatalf("Error loading email map: %v", err)
+		}
+	}
+	if *flags.timezoneFile != "" {
+		var err error
+		cfg.Timezones, err = loadUserTimezones(*flags.timezoneFile)
+		if err != nil {
+			return fatalf("Error loading timezone file: %v", err)
+		}
+	}
+	authorPolicies, err := parseAuthorPolicies(*flags.authorPolicies)
+	if err != nil {
+		return fatalf("Invalid author policies: %v", err)
+	}
+	exemptAuthors, err := parseExemptAuthors(*flags.exemptAuthors)
+	if err != nil {
+		return fatalf("Invalid exempt authors: %v", err)
+	}
+	ruleOrder, err := parseRuleOrder(*flags.ruleOrder)
+	if err != nil {
+		return fatalf("Invalid rule order: %v", err)
+	}
+	baseBranches, err := parseBaseBranchFilter(*flags.baseBranches, *flags.excludeBaseBranches)
+	if err != nil {
+		return fatalf("Invalid base branches: %v", err)
+	}
+	cfg.Rules = evaluationRules{
+		SkipDrafts:    *flags.skipDrafts,
+		ExemptAuthors: exemptAuthors,
+		ExemptLabels:  splitList(*flags.exemptLabels),
+
+		PauseDrafts:         *flags.pauseDrafts,
+		ReadyResetsActivity: *flags.readyResetsActivity,
+
+		ExemptUntilPrefix: *flags.exemptUntilLabelPrefix,
+		Location:          cfg.DisplayLocation,
+
+		StaleLabel:    *flags.staleLabel,
+		Policies:      authorPolicies,
+		DaysInactive:  *flags.daysInactive,
+		WarningPeriod: *flags.warningPeriod,
+
+		ExemptSecurity: *flags.exemptSecurity,
+		SecurityLabels: splitList(*flags.securityLabels),
+
+		EscalateSecurityUpdates: *flags.escalateSecurityUpdates,
+
+		FailingChecksStaleAfter: *flags.failingChecksStaleAfter,
+		ExemptWaitingOnReview:   *flags.exemptWaitingOnReview,
+		ReopenGraceDays:         *flags.reopenGraceDays,
+
+		CloseWaitingOnMaintainer: *flags.closeWaitingOnMaintainer,
+
+		Order: ruleOrder,
+	}
+	questionPatterns, err := parseQuestionPatterns(*flags.questionPatterns)
+	if err != nil {
+		return fatalf("Invalid question patterns: %v", err)
+	}
+	if *flags.exemptLabel != "" {
+		cfg.Rules.ExemptLabels = splitList(*flags.exemptLabel)
+	}
+	if strings.TrimSpace(cfg.Rules.StaleLabel) == "" {
+		return fatalf("--stale-label must not be empty.")
+	}
+	if cfg.Rules.ReopenGraceDays <= 0 {
+		cfg.Rules.ReopenGraceDays = 2 * *flags.daysInactive
+	}
+	if *flags.oooFile != "" {
+		cfg.Rules.OOO, err = loadOOOCalendar(*flags.oooFile, cfg.DisplayLocation)
+		if err != nil {
+			return fatalf("Error loading out-of-office file: %v", err)
+		}
+		cfg.Rules.OOOGrace = *flags.oooGrace
+	}
+	switch *flags.staleAction {
+	case staleActionClose:
+	case staleActionMilestone:
+		if *flags.staleMilestone == "" {
+			return fatalf("--stale-action milestone requires --stale-milestone.")
+		}
+		cfg.Rules.ParkedMilestone = *flags.staleMilestone
+	default:
+		return fatalf("Invalid stale action %q: must be close or milestone.", *flags.staleAction)
+	}
+
+	// Explain the decision for a hypothetical PR without contacting GitHub.
+	if *flags.explain {
+		if *flags.daysInactive <= 0 || *flags.warningPeriod <= 0 {
+			return fatalf("--explain requires --days-inactive and --warning-period.")
+		}
+		age, err := parseDays(*flags.explainAge)
+		if err != nil {
+			return fatalf("Invalid --age: %v", err)
+		}
+		synthetic := syntheticPR{Author: *flags.explainAuthor, Draft: *flags.explainDraft, Age: age}
+		synthetic.Labels = splitList(*flags.explainLabels)
+		if *flags.explainLastActivity != "" {
+			synthetic.LastActivity, err = time.ParseInLocation("2006-01-02", *flags.explainLastActivity, cfg.DisplayLocation)
+			if err != nil {
+				return fatalf("Invalid --last-activity: %v", err)
+			}
+		}
+		explainPR(humanOutput, synthetic, cfg, time.Now())
+		return exitSuccess
+	}
 
 	// Simple sanity check.
-	if *githubTokenFlag == "" || *githubBaseURLFlag == "" || *ownerFlag == "" || *repoFlag == "" || *daysInactiveFlag <= 0 ||
-		*warningPeriodFlag <= 0 || *smtpServerFlag == "" || *smtpUserFlag == "" || *smtpPasswordFlag == "" {
-		log.Fatal("Missing required parameter. Please ensure all required flags or environment variables are set.")
+	owner, repos, err := resolveRepos(*flags.owner, splitList(*flags.repo))
+	if err != nil {
+		return fatalf("Invalid --owner or --repo: %v", err)
+	}
+	*flags.owner = owner
+	needsSMTP := cfg.NotifyPrefs.needsEmail(cfg.NotifyVia) || (*flags.escalateSecurityUpdates && *flags.securityContact != "")
+	if missing := missingSettings([]requiredSetting{
+		{"github.token_env", "github-token", "GITHUB_TOKEN", *flags.githubToken != ""},
+		{"github.base_url", "github-base-url", "GITHUB_BASE_URL", *flags.githubBaseURL != ""},
+		{"owner", "owner", "GITHUB_OWNER", *flags.owner != ""},
+		{"repos", "repo", "GITHUB_REPO", len(repos) > 0},
+		{"days_inactive", "days-inactive", "DAYS_INACTIVE", *flags.daysInactive > 0},
+		{"warning_period", "warning-period", "WARNING_PERIOD", *flags.warningPeriod > 0},
+		{"smtp.server", "smtp-server", "SMTP_SERVER", !needsSMTP || *flags.smtpServer != ""},
+		// Authentication is optional, but needs both a user and a
+		// password; without a user the sender must be given.
+		{"smtp.user", "smtp-user", "SMTP_USER", !needsSMTP || *flags.smtpPassword == "" || *flags.smtpUser != ""},
+		{"smtp.password_env", "smtp-password", "SMTP_PASSWORD", !needsSMTP || *flags.smtpUser == "" || *flags.smtpPassword != ""},
+		{"smtp.from", "smtp-from", "SMTP_FROM", !needsSMTP || *flags.smtpUser != "" || *flags.smtpFrom != ""},
+		{"slack.webhook_url_env", "slack-webhook-url", "SLACK_WEBHOOK_URL", !cfg.NotifyPrefs.needsChat(cfg.NotifyVia, notifySlack) || cfg.SlackWebhookURL != ""},
+		{"teams.webhook_url_env", "teams-webhook-url", "TEAMS_WEBHOOK_URL", !cfg.NotifyPrefs.needsChat(cfg.NotifyVia, notifyTeams) || cfg.TeamsWebhookURL != ""},
+	}); len(missing) > 0 {
+		return fatalf("Missing or invalid required parameter(s): %s. Please set them with flags, environment variables or the config file.", strings.Join(missing, ", "))
+	}
+	if *flags.discussionMode != discussionModePerRun && *flags.discussionMode != discussionModeMonthlyRollup {
+		return fatalf("Invalid discussion mode %q: must be %q or %q.", *flags.discussionMode, discussionModePerRun, discussionModeMonthlyRollup)
+	}
+	protectedPaths, err := parseProtectedPaths(*flags.protectedPaths)
+	if err != nil {
+		return fatalf("Invalid protected paths: %v", err)
+	}
+	reminders, err := parseReminders(*flags.reminders)
+	if err != nil {
+		return fatalf("Invalid reminders: %v", err)
+	}
+	if len(reminders) > 0 && *flags.stateFile == "" {
+		return fatalf("--reminders requires --state-file to track the reminders sent.")
+	}
+	if *flags.backfill && *flags.stateFile == "" {
+		return fatalf("--backfill requires --state-file to track the daily cap.")
+	}
+	if *flags.planFile != "" && !*flags.readOnly {
+		return fatalf("--plan-file requires --read-only.")
+	}
+	var repoList *repoList
+	if *flags.repoListFile != "" {
+		repoList, err = loadRepoList(*flags.repoListFile, *flags.owner, repos)
+		if err != nil {
+			return fatalf("Error loading repository list: %v", err)
+		}
+		repos = repoList.repos
+		if len(repos) == 0 {
+			slog.Warn("no repositories left to scan")
+			return exitSuccess
+		}
+	}
+	if (*flags.planFile != "" || *flags.executePlan != "") && len(repos) > 1 {
+		return fatalf("--plan-file and --execute-plan work on a single repository.")
+	}
+	var plan *actionPlan
+	if *flags.executePlan != "" {
+		plan, err = loadPlan(*flags.executePlan, *flags.owner+"/"+repos[0])
+		if err != nil {
+			return fatalf("Error loading plan: %v", err)
+		}
+	}
+	var schedule *cronSchedule
+	if *flags.schedule != "" && !*flags.runOnce {
+		schedule, err = parseCronSchedule(*flags.schedule)
+		if err != nil {
+			return fatalf("Invalid --schedule: %v", err)
+		}
+		if *flags.executePlan != "" {
+			return fatalf("--execute-plan runs a plan once; it cannot be combined with --schedule.")
+		}
+		if *flags.shutdownGrace <= 0 {
+			return fatalf("Invalid --shutdown-grace %v: must be positive.", *flags.shutdownGrace)
+		}
+	}
+	if *flags.includeIssues && (*flags.incremental || *flags.executePlan != "") {
+		return fatalf("--include-issues needs full scans; it cannot be combined with --incremental or --execute-plan.")
+	}
+	if *flags.incremental && *flags.stateFile == "" {
+		return fatalf("--incremental requires --state-file to store the events cursor.")
+	}
+
+	// Make no changes on no-action days, whatever the schedule.
+	noAction, err := parseNoActionDays(*flags.noActionDays)
+	if err != nil {
+		return fatalf("Invalid --no-action-days: %v", err)
+	}
+	if *flags.holidaysFile != "" {
+		if err := noAction.loadHolidays(*flags.holidaysFile); err != nil {
+			return fatalf("Error loading holidays: %v", err)
+		}
+	}
+	if reason := noAction.match(time.Now().In(cfg.DisplayLocation)); reason != "" && !*flags.readOnly {
+		slog.Info("no-action day: running in read-only mode; nothing will be labeled, commented on, closed or emailed", "reason", reason)
+		*flags.readOnly = true
+	}
+
+	budget := newRunBudget(*flags.maxAPICalls, *flags.maxEmails)
+	rateLimits := newRateLimitWait(*flags.maxRateLimitWait)
+	guard := newWriteGuard(*flags.readOnly, *flags.dryRun)
+	if *flags.concurrency < 1 {
+		return fatalf("Invalid --concurrency %d: must be at least 1.", *flags.concurrency)
+	}
+	pool := newPRPool(*flags.concurrency)
+	if *flags.duplicateSimilarity <= 0 || *flags.duplicateSimilarity > 1 {
+		return fatalf("Invalid --duplicate-similarity %v: must be above 0 and at most 1.", *flags.duplicateSimilarity)
+	}
+	if *flags.closeInterlockThreshold < 0 {
+		return fatalf("Invalid --close-interlock-threshold %d: must not be negative.", *flags.closeInterlockThreshold)
+	}
+	if *flags.maxRunDuration < 0 || *flags.runDurationMargin < 0 {
+		return fatalf("Invalid --max-run-duration or --run-duration-margin: must not be negative.")
+	}
+	if *flags.maxRunDuration > 0 && *flags.runDurationMargin >= *flags.maxRunDuration {
+		return fatalf("Invalid --run-duration-margin %v: must be shorter than --max-run-duration %v.", *flags.runDurationMargin, *flags.maxRunDuration)
+	}
+	deadline := newRunDeadline(context.Background(), started, *flags.maxRunDuration, *flags.runDurationMargin, time.Now)
+	// Read-only runs close nothing, so the interlock only guards real ones.
+	interlock := newCloseInterlock(*flags.enableClose || *flags.readOnly || *flags.dryRun, *flags.closeInterlockThreshold, os.Stdin, os.Stderr, isTerminalInput)
+	tmpl := newTemplateRenderer(cfg)
+	if *flags.templateDir != "" {
+		unknown, err := checkTemplateDir(*flags.templateDir)
+		if err != nil {
+			return fatalf("Invalid template directory: %v", err)
+		}
+		for _, name := range unknown {
+			slog.Warn("template matches no channel and action, ignoring it", "template", name)
+		}
+		tmpl.overrides, err = loadTemplateOverrides(*flags.templateDir, tmpl)
+		if err != nil {
+			return fatalf("Invalid template override: %v", err)
+		}
+	} else if len(probotTemplates) > 0 {
+		tmpl.overrides, err = probotTemplateOverrides(probotPath, probotTemplates, tmpl)
+		if err != nil {
+			return fatalf("Invalid probot/stale comment: %v", err)
+		}
+	}
+	httpClient := newHTTPClient(*flags.requestTag)
+	mail := newMailer(cfg, tmpl, guard, httpClient)
+	mail.pool = pool
+	mail.budget = budget
+	if *flags.smtpConcurrency < 1 {
+		return fatalf("Invalid --smtp-concurrency %d: must be at least 1.", *flags.smtpConcurrency)
+	}
+	mail.conns = newSMTPPool(*flags.smtpConcurrency)
+	if *flags.smtpInsecure {
+		slog.Warn("SMTP TLS certificates will not be verified (--smtp-insecure)")
+	}
+	mail.tls, err = newSMTPTLSConfig(cfg.SMTPServer, *flags.smtpCAFile, *flags.smtpInsecure)
+	if err != nil {
+		return fatalf("Invalid --smtp-ca-file: %v", err)
+	}
+	mail.encryption, err = smtpEncryption(*flags.smtpEncryption, cfg.SMTPPort)
+	if err != nil {
+		return fatalf("Invalid --smtp-encryption: %v", err)
+	}
+	if needsSMTP {
+		if mail.authenticates() {
+			slog.Info("emails are sent with SMTP authentication", "server", cfg.SMTPServer, "user", cfg.SMTPUser, "from", mail.From)
+		} else {
+			slog.Info("emails are sent without SMTP authentication", "server", cfg.SMTPServer, "from", mail.From)
+		}
+	}
+	if mail.encryption == smtpEncryptionNone {
+		slog.Warn("SMTP connections will not be encrypted (--smtp-encryption none)")
+	}
+	switch *flags.emailFormat {
+	case emailFormatText:
+		if *flags.htmlTemplate != "" {
+			slog.Warn("--html-template has no effect with --email-format text")
+		}
+	case emailFormatHTML, emailFormatBoth:
+		mail.html, err = loadHTMLEmailTemplate(*flags.htmlTemplate, cfg.DisplayLocation)
+		if err != nil {
+			return fatalf("Invalid --html-template: %v", err)
+		}
+	default:
+		return fatalf("Invalid --email-format %q: must be %s, %s or %s.", *flags.emailFormat, emailFormatText, emailFormatHTML, emailFormatBoth)
+	}
+
+	// Load persisted state.
+	state := newBotState()
+	if *flags.stateFile != "" {
+		state, err = loadState(*flags.stateFile)
+		if err != nil {
+			return fatalf("Error loading state: %v", err)
+		}
+	}
+	// saveState writes the state file, reporting whether it succeeded.
+	saveState := func() bool {
+		if *flags.stateFile == "" {
+			return true
+		}
+		if *flags.readOnly {
+			slog.Info("read-only mode: state file not saved")
+			return true
+		}
+		if err := state.save(*flags.stateFile); err != nil {
+			slog.Error("saving state failed", "file", *flags.stateFile, "err", err)
+			return false
+		}
+		return true
+	}
+	if *flags.startJitter > 0 && !isInteractive(os.Stdin) {
+		delay := startJitter(*flags.startJitter, rand.Int64N)
+		slog.Info("start jitter: waiting before starting", "delay", delay.Round(time.Second))
+		time.Sleep(delay)
+		started = time.Now()
 	}
 
-	fmt.Println("-------------------------------------------------------------")
-	fmt.Println("Starting the stale PR bot in production mode...")
-	fmt.Println("-------------------------------------------------------------")
+	mode := "production"
+	if *flags.dryRun {
+		mode = "dry-run"
+	} else if *flags.readOnly {
+		mode = "read-only"
+	}
+	slog.Info("starting the stale PR bot", "mode", mode)
 
 	// Create GitHub client.
-	fmt.Println("Creating GitHub client...")
-	client, err := getGithubClient(*githubTokenFlag, *githubBaseURLFlag)
+	slog.Debug("creating GitHub client")
+	client, err := getGithubClient(*flags.githubToken, *flags.githubBaseURL, *flags.githubDialProxy, *flags.requestTag, sshProxyOptions{
+		KeyFile:        *flags.githubDialProxySSHKey,
+		KnownHostsFile: *flags.githubDialProxyKnownHosts,
+	}, budget, *flags.apiRetries, rateLimits, guard, pool)
 	if err != nil {
-		log.Fatalf("Error creating GitHub client: %v", err)
+		return fatalf("Error creating GitHub client: %v", err)
 	}
-	fmt.Println("GitHub client created successfully.")
+	slog.Debug("GitHub client created")
 
 	// Test GitHub connection.
-	fmt.Println("-------------------------------------------------------------")
-	fmt.Println("Testing GitHub connection...")
-	err = testGitHubConnection(client)
-	if err != nil {
-		log.Fatalf("GitHub connection test failed: %v", err)
+	slog.Debug("testing GitHub connection")
+	var botLogin string
+	if *flags.capabilities {
+		needs := capabilityNeeds{
+			Checks: cfg.Rules.FailingChecksStaleAfter > 0,
+			Search: !*flags.noAutoSoften,
+			Events: *flags.incremental,
+		}
+		if *flags.exemptSecurity {
+			needs.SecurityTeam = *flags.securityTeam
+		}
+		missing, err := probeCapabilities(tokenCapabilities(client, *flags.owner, repos[0], needs, &botLogin))
+		if err != nil {
+			return fatalf("GitHub connection test failed: %v", err)
+		}
+		if missing[capabilityUser] {
+			*flags.statusCommand = false
+		}
+		if missing[capabilityChecks] {
+			cfg.Rules.FailingChecksStaleAfter = 0
+		}
+		if missing[capabilityTeams] {
+			*flags.securityTeam = ""
+		}
+		if missing[capabilitySearch] {
+			*flags.noAutoSoften = true
+		}
+		if missing[capabilityEvents] {
+			*flags.incremental = false
+		}
+	} else {
+		botLogin, err = testGitHubConnection(client)
+		if err != nil {
+			return fatalf("GitHub connection test failed: %v", err)
+		}
 	}
-	fmt.Println("GitHub connection successful.")
-	fmt.Println("-------------------------------------------------------------")
+	slog.Debug("GitHub connection successful")
 
-	// Get open PRs.
-	fmt.Println("Fetching open PRs...")
-	openPRs, err := getOpenPRs(client, *ownerFlag, *repoFlag)
+	// Load the security team once per run.
+	if *flags.exemptSecurity && *flags.securityTeam != "" {
+		cfg.Rules.SecurityTeam, err = getTeamMembers(client, *flags.owner, *flags.securityTeam)
+		if err != nil {
+			return fatalf("Error loading security team: %v", err)
+		}
+		slog.Info("loaded security team", "team", *flags.securityTeam, "members", len(cfg.Rules.SecurityTeam))
+	}
+
+	sc := &scanner{
+		flags:            flags,
+		cfg:              cfg,
+		client:           client,
+		state:            state,
+		budget:           budget,
+		rateLimits:       rateLimits,
+		guard:            guard,
+		pool:             pool,
+		deadline:         deadline,
+		interlock:        interlock,
+		tmpl:             tmpl,
+		mail:             mail,
+		events:           events,
+		output:           humanOutput,
+		plan:             plan,
+		reminders:        reminders,
+		baseBranches:     baseBranches,
+		protectedPaths:   protectedPaths,
+		questionPatterns: questionPatterns,
+		botLogin:         botLogin,
+		rotate:           rotate,
+		started:          started,
+		httpClient:       httpClient,
+	}
+	if schedule == nil {
+		_, code := sc.scanAll(*flags.owner, repos)
+		if !saveState() {
+			code = max(code, exitPartialFailed)
+		}
+		return code
+	}
+
+	// Run as a daemon. Each run starts over with its own deadline, budgets
+	// and context, rereads the repository list, and saves the state when it
+	// ends.
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	return runScheduled(schedule, *flags.shutdownGrace, stop, func(ctx context.Context) ([]repoResult, int) {
+		sc.started = time.Now()
+		sc.deadline = newRunDeadline(ctx, sc.started, *flags.maxRunDuration, *flags.runDurationMargin, time.Now)
+		budget.reset()
+		rateLimits.reset()
+		if repoList != nil {
+			repos = repoList.reload()
+		}
+		results, code := sc.scanAll(*flags.owner, repos)
+		if !saveState() {
+			code = max(code, exitPartialFailed)
+		}
+		return results, code
+	})
+}
+
+// fatalf logs a fatal error and returns exitFatal for run to exit with.
+func fatalf(format string, args ...any) int {
+	log.Printf(format, args...)
+	return exitFatal
+}
+
+// repoResult is the outcome of scanning one repository in a run.
+type repoResult struct {
+	Name string
+	// Summary is nil if the repository could not be scanned.
+	Summary *runSummary
+	Err     error
+}
+
+// logRepoResults logs one record per repository scanned in a run.
+func logRepoResults(results []repoResult) {
+	slog.Info("scanned repositories", "count", len(results))
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			slog.Error("repository scan failed", "repo", r.Name, "err", r.Err)
+		case r.Summary != nil:
+			slog.Info("repository scanned", "repo", r.Name, "evaluated", r.Summary.Evaluated, "warned", len(r.Summary.Warned), "closed", len(r.Summary.Closed))
+		}
+	}
+}
+
+// splitList splits a comma-separated list, trimming spaces and dropping empty
+// entries.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// runSubcommand runs the subcommand name, if it is one, with its arguments,
+// and returns its exit code.
+func runSubcommand(name string, args []string, w io.Writer) (code int, ok bool) {
+	var err error
+	switch name {
+	case "trends":
+		err = runTrendsCommand(args, w)
+	case "convert-config":
+		err = runConvertConfigCommand(args, w)
+	case "calibrate":
+		err = runCalibrateCommand(args, w)
+	case "state":
+		err = runStateCommand(args, w)
+	case "schema":
+		err = runSchemaCommand(args, w)
+	case "validate-config":
+		err = runValidateConfigCommand(args, w)
+	default:
+		return 0, false
+	}
 	if err != nil {
-		log.Fatalf("Error fetching PRs: %v", err)
-	}
-	fmt.Printf("Found %d open PR(s).\n", len(openPRs))
-	fmt.Println("-------------------------------------------------------------")
-	if len(openPRs) == 0 {
-		fmt.Println("No open PRs found.")
-		return
-	}
-
-	staleDuration := time.Duration(*daysInactiveFlag) * 24 * time.Hour
-	staleCutoff := time.Now().Add(-staleDuration)
-
-	// Process PRs.
-	for _, pr := range openPRs {
-		fmt.Printf("\n-------------------------------------------------------------\n")
-		fmt.Printf("Processing PR #%d: %s\n", pr.GetNumber(), pr.GetTitle())
-		fmt.Println("-------------------------------------------------------------")
-
-		// Check if PR has 'do not stale' label.
-		if hasLabel(pr, "do not stale") {
-			fmt.Printf("PR #%d has 'do not stale' label.\n", pr.GetNumber())
-			if hasLabel(pr, "stale-warning") {
-				fmt.Printf("Removing 'stale-warning' label from PR #%d.\n", pr.GetNumber())
-				err = removeLabel(client, *ownerFlag, *repoFlag, pr.GetNumber(), "stale-warning")
-				if err != nil {
-					fmt.Printf("Error removing label from PR #%d: %v\n", pr.GetNumber(), err)
-				} else {
-					fmt.Printf("Removed 'stale-warning' label from PR #%d.\n", pr.GetNumber())
-				}
-			}
-			continue
-		}
-
-		// Check if PR is stale.
-		if pr.GetUpdatedAt().Time.Before(staleCutoff) {
-			fmt.Printf("PR #%d is stale.\n", pr.GetNumber())
-			if hasLabel(pr, "stale-warning") {
-				fmt.Printf("PR #%d already has a 'stale-warning' label.\n", pr.GetNumber())
-				// Check if warning period has passed.
-				if timeSinceLabel(pr) > time.Duration(*warningPeriodFlag)*24*time.Hour {
-					fmt.Printf("Closing PR #%d as it has been inactive after the warning period.\n", pr.GetNumber())
-					err := closePR(client, *ownerFlag, *repoFlag, pr.GetNumber())
-					if err != nil {
-						fmt.Printf("Error closing PR #%d: %v\n", pr.GetNumber(), err)
-					} else {
-						fmt.Printf("Closed PR #%d.\n", pr.GetNumber())
-						// Notify PR author of closure.
-						err = notifyPRClosure(pr, *smtpServerFlag, *smtpPortFlag, *smtpUserFlag, *smtpPasswordFlag)
-						if err != nil {
-							fmt.Printf("Error sending closure email for PR #%d: %v\n", pr.GetNumber(), err)
-						} else {
-							fmt.Printf("Sent closure notification for PR #%d.\n", pr.GetNumber())
-						}
-					}
-				} else {
-					fmt.Printf("PR #%d is still within the warning period.\n", pr.GetNumber())
-				}
-			} else {
-				fmt.Printf("Sending warning for PR #%d.\n", pr.GetNumber())
-				err := warnPRAuthor(pr, *smtpServerFlag, *smtpPortFlag, *smtpUserFlag, *smtpPasswordFlag)
-				if err != nil {
-					fmt.Printf("Error sending email for PR #%d: %v\n", pr.GetNumber(), err)
-				} else {
-					fmt.Printf("Sent warning for PR #%d.\n", pr.GetNumber())
-					err = addWarningLabel(client, *ownerFlag, *repoFlag, pr.GetNumber())
-					if err != nil {
-						fmt.Printf("Error adding label to PR #%d: %v\n", pr.GetNumber(), err)
-					}
-				}
-			}
+		return fatalf("%s: %v", name, err), true
+	}
+	return exitSuccess, true
+}
+
+// envString returns the value of an environment variable, or def if it is
+// unset.
+func envString(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// prioritizePRs moves the PRs with the given numbers to the front, keeping
+// the relative order of both groups.
+func prioritizePRs(prs []*github.PullRequest, first []int) []*github.PullRequest {
+	if len(first) == 0 {
+		return prs
+	}
+	wanted := map[int]bool{}
+	for _, n := range first {
+		wanted[n] = true
+	}
+	ordered := make([]*github.PullRequest, 0, len(prs))
+	var rest []*github.PullRequest
+	for _, pr := range prs {
+		if wanted[pr.GetNumber()] {
+			ordered = append(ordered, pr)
 		} else {
-			fmt.Printf("PR #%d is active.\n", pr.GetNumber())
-			// Optionally remove 'stale-warning' label if PR is active.
-			if hasLabel(pr, "stale-warning") {
-				fmt.Printf("Removing 'stale-warning' label from active PR #%d.\n", pr.GetNumber())
-				err = removeLabel(client, *ownerFlag, *repoFlag, pr.GetNumber(), "stale-warning")
-				if err != nil {
-					fmt.Printf("Error removing label from PR #%d: %v\n", pr.GetNumber(), err)
-				} else {
-					fmt.Printf("Removed 'stale-warning' label from PR #%d.\n", pr.GetNumber())
-				}
-			}
+			rest = append(rest, pr)
+		}
+	}
+	return append(ordered, rest...)
+}
+
+// runSummary records the PRs acted upon during a run.
+type runSummary struct {
+	// Evaluated is the number of open PRs processed.
+	Evaluated int
+	Warned    []*github.PullRequest
+	Closed    []*github.PullRequest
+	// Softened is why the run was switched to warn-only mode, if it was.
+	Softened string
+	// ParkedIn is the milestone PRs counted as closed were parked in
+	// instead, if any.
+	ParkedIn string
+	// Backfilling is set for runs in --backfill mode; BackfillRemaining
+	// counts the warnings held back by the daily cap and BackfillComplete
+	// is set when no backlog remains.
+	Backfilling       bool
+	BackfillRemaining int
+	BackfillComplete  bool
+	// PathProtected counts closures skipped because the PR changes protected paths.
+	PathProtected int
+	// SafetyAborted counts closures aborted by the pre-close safety check.
+	SafetyAborted int
+	// APICalls and Emails are the budget usage for the run.
+	APICalls int
+	Emails   int
+	// BudgetExhausted names the budget that ran out, if any.
+	BudgetExhausted string
+	// Deferred lists the actions skipped because a budget ran out.
+	Deferred []string
+	// Unprocessed counts the PRs left for the next run because the run
+	// reached --max-run-duration.
+	Unprocessed int
+	// PlanDrift lists the planned actions skipped because the PR changed
+	// since the plan was made.
+	PlanDrift []string
+	// DeadLettered lists notification emails given up on after all retries.
+	DeadLettered []string
+	// DispatchesFailed and DispatchesSkipped count the repository dispatch
+	// events that failed or were skipped by the per-run cap, the budget or
+	// read-only mode.
+	DispatchesFailed  int
+	DispatchesSkipped int
+	// Duplicates lists the groups of stale PRs by one author that change
+	// largely the same files, with --detect-duplicates.
+	Duplicates []duplicateGroup
+	// SLA holds the SLA intervals completed during the run.
+	SLA *slaSample
+	// WebhooksFailed counts the --webhook-url POSTs that failed.
+	WebhooksFailed int
+	// Suppressed lists the notifications not sent because the author opted
+	// out.
+	Suppressed []string
+	// Would counts, per kind, the actions a dry run skipped.
+	Would map[string]int
+	// BlockedWrites lists the writes refused in read-only mode.
+	BlockedWrites []string
+	// SecurityExempt lists the PRs exempted as security work and why.
+	SecurityExempt []string
+	// Delta is how the classification of PRs changed since the last run.
+	Delta *runDelta
+	// Away lists the PRs whose enforcement waits for their author's return
+	// from out of office, and why.
+	Away []string
+	// WaitingOnReview counts the PRs exempted as waiting on their reviewers.
+	WaitingOnReview int
+	// CloseRequested counts the PRs closed at a maintainer's request and
+	// CloseRequestsIgnored the requests by users who cannot push.
+	CloseRequested       int
+	CloseRequestsIgnored int
+	// CleanupChecked counts the warned PRs the cleanup pass after a partial
+	// scan checked for activity and CleanupUnwarned those it found no longer
+	// stale.
+	CleanupChecked  int
+	CleanupUnwarned int
+	// ExemptUntil counts the PRs exempted by a time-boxed exemption label
+	// and ExemptionsExpired the expired ones removed.
+	ExemptUntil       int
+	ExemptionsExpired int
+	// AuthorStats ranks the authors by stale PRs with --author-stats, and
+	// PublishAuthorStats includes them in the published run summary.
+	AuthorStats        []authorStats
+	PublishAuthorStats bool
+	// Failed counts the PRs an action failed on, such as sending an email
+	// or changing a label.
+	Failed int
+	// OtherBase counts the PRs skipped for targeting a base branch out of
+	// scope.
+	OtherBase int
+	// Drafts counts the draft PRs skipped, and Paused those paused because
+	// their author converted them to drafts while warned.
+	Drafts int
+	Paused int
+	// AuthorExempt counts the PRs exempted by --exempt-authors.
+	AuthorExempt int
+	// SecurityUpdates counts the Dependabot security updates found.
+	SecurityUpdates int
+	// Escalated lists the stale security updates and NewlyEscalated counts
+	// those escalated this run.
+	Escalated      []string
+	NewlyEscalated int
+	// WaitingOnMaintainer lists the stale PRs whose author's question is
+	// unanswered.
+	WaitingOnMaintainer []string
+	// Exemptions counts the PRs exempted by each exempt label.
+	Exemptions map[string]int
+	// PolicyOverrides counts the PRs each author policy override applied to,
+	// keyed by "pattern=policy".
+	PolicyOverrides map[string]int
+	// CloseReasons counts the PRs closed as stale by close reason.
+	CloseReasons map[string]int
+}
+
+// logSummary logs the end-of-run summary of a repository as one record, with
+// the sections that apply, followed by the changes since the previous run and
+// the author statistics.
+func logSummary(logger *slog.Logger, summary *runSummary) {
+	attrs := []any{"warned", len(summary.Warned), "closed", len(summary.Closed), "api_calls", summary.APICalls, "emails", summary.Emails}
+	add := func(ok bool, args ...any) {
+		if ok {
+			attrs = append(attrs, args...)
 		}
 	}
+	add(summary.Softened != "", "warn_only", summary.Softened)
+	add(summary.ParkedIn != "", "parked_in", summary.ParkedIn)
+	add(len(summary.CloseReasons) > 0, "close_reasons", summary.CloseReasons)
+	add(summary.PathProtected > 0, "path_protected", summary.PathProtected)
+	add(summary.SafetyAborted > 0, "safety_aborted", summary.SafetyAborted)
+	add(summary.BackfillComplete, "backfill_complete", true)
+	add(summary.Backfilling && !summary.BackfillComplete, "backfill_remaining", summary.BackfillRemaining)
+	add(summary.Failed > 0, "failed", summary.Failed)
+	add(summary.Unprocessed > 0, "time_boxed", true, "unprocessed", summary.Unprocessed)
+	add(summary.BudgetExhausted != "", "budget_exhausted", summary.BudgetExhausted, "deferred", summary.Deferred)
+	add(len(summary.PlanDrift) > 0, "plan_drift", summary.PlanDrift)
+	add(len(summary.DeadLettered) > 0, "dead_lettered", summary.DeadLettered)
+	add(summary.DispatchesFailed > 0 || summary.DispatchesSkipped > 0, "dispatches_failed", summary.DispatchesFailed, "dispatches_skipped", summary.DispatchesSkipped)
+	add(len(summary.Duplicates) > 0, "duplicates", summary.Duplicates)
+	add(summary.WebhooksFailed > 0, "webhooks_failed", summary.WebhooksFailed)
+	add(len(summary.Suppressed) > 0, "suppressed", summary.Suppressed)
+	add(len(summary.Would) > 0, "would", summary.Would)
+	add(len(summary.BlockedWrites) > 0, "blocked_writes", summary.BlockedWrites)
+	add(len(summary.SecurityExempt) > 0, "security_exempt", summary.SecurityExempt)
+	add(len(summary.Away) > 0, "away", summary.Away)
+	add(summary.CloseRequested > 0 || summary.CloseRequestsIgnored > 0, "close_requested", summary.CloseRequested, "close_requests_ignored", summary.CloseRequestsIgnored)
+	add(summary.ExemptUntil > 0 || summary.ExemptionsExpired > 0, "exempt_until", summary.ExemptUntil, "exemptions_expired", summary.ExemptionsExpired)
+	add(summary.CleanupChecked > 0, "cleanup_checked", summary.CleanupChecked, "cleanup_unwarned", summary.CleanupUnwarned)
+	add(summary.OtherBase > 0, "other_base", summary.OtherBase)
+	add(summary.Drafts > 0, "drafts", summary.Drafts)
+	add(summary.Paused > 0, "paused_drafts", summary.Paused)
+	add(summary.AuthorExempt > 0, "author_exempt", summary.AuthorExempt)
+	add(summary.WaitingOnReview > 0, "waiting_on_review", summary.WaitingOnReview)
+	add(summary.SecurityUpdates > 0, "security_updates", summary.SecurityUpdates, "escalated", summary.Escalated, "newly_escalated", summary.NewlyEscalated)
+	add(len(summary.WaitingOnMaintainer) > 0, "waiting_on_maintainer", summary.WaitingOnMaintainer)
+	add(len(summary.Exemptions) > 0, "exemptions", summary.Exemptions)
+	add(len(summary.PolicyOverrides) > 0, "policy_overrides", summary.PolicyOverrides)
+	logger.Info("run summary", attrs...)
+	logDelta(logger, summary.Delta)
+	logAuthorStats(logger, summary.AuthorStats)
 }
 
-func testGitHubConnection(client *github.Client) error {
+func testGitHubConnection(client *github.Client) (string, error) {
 	ctx := context.Background()
 	user, _, err := client.Users.Get(ctx, "")
 	if err != nil {
-		return fmt.Errorf("failed to retrieve authenticated user: %v", err)
+		return "", fmt.Errorf("failed to retrieve authenticated user: %v", err)
 	}
-	fmt.Printf("Authenticated as GitHub user: %s\n", user.GetLogin())
-	return nil
+	slog.Info("authenticated with GitHub", "user", user.GetLogin())
+	return user.GetLogin(), nil
 }
 
-func getGithubClient(token, baseURL string) (*github.Client, error) {
+func getGithubClient(token, baseURL, dialProxy, requestTag string, sshOpts sshProxyOptions, budget *runBudget, retries int, rateLimits *rateLimitWait, guard *writeGuard, pool *prPool) (*github.Client, error) {
 	ctx := context.Background()
+	var base http.RoundTripper = http.DefaultTransport
+	if dialProxy != "" {
+		tr, err := newProxyTransport(dialProxy, sshOpts)
+		if err != nil {
+			return nil, err
+		}
+		base = tr
+	}
+	base = &userAgentTransport{base: base, tag: requestTag}
+	// The budget is charged for each request sent, so the retries and the
+	// requests repeated after a rate limit count too, and writes refused in
+	// read-only mode, which are never sent, do not.
+	base = &budgetTransport{base: base, budget: budget}
+	if retries > 0 {
+		base = &retryTransport{base: base, retries: retries}
+	}
+	if rateLimits != nil {
+		base = &rateLimitTransport{base: base, limits: rateLimits}
+	}
+	base = &readOnlyTransport{base: base, guard: guard}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: &poolTransport{base: base, pool: pool}})
 	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
 	tc := oauth2.NewClient(ctx, ts)
 	client := github.NewClient(tc)
@@ -252,7 +977,7 @@ func getOpenPRs(client *github.Client, owner, repo string) ([]*github.PullReques
 	var allPRs []*github.PullRequest
 
 	for {
-		fmt.Println("Fetching pull requests...")
+		slog.Debug("fetching pull requests", "repo", owner+"/"+repo, "page", opts.Page)
 		prs, resp, err := client.PullRequests.List(ctx, owner, repo, opts)
 		if err != nil {
 			return nil, fmt.Errorf("error listing PRs: %v", err)
@@ -263,7 +988,7 @@ func getOpenPRs(client *github.Client, owner, repo string) ([]*github.PullReques
 		}
 		opts.Page = resp.NextPage
 	}
-	fmt.Printf("Total open PRs fetched: %d\n", len(allPRs))
+	slog.Debug("fetched open pull requests", "repo", owner+"/"+repo, "count", len(allPRs))
 	return allPRs, nil
 }
 
@@ -276,22 +1001,93 @@ func hasLabel(pr *github.PullRequest, labelName string) bool {
 	return false
 }
 
-func timeSinceLabel(pr *github.PullRequest) time.Duration {
-	return time.Since(pr.GetUpdatedAt().Time)
+// timeSinceLabel returns how long ago the 'stale-warning' label was added,
+// given when it was labeled. If that is unknown it falls back to the PR's
+// last update.
+func timeSinceLabel(pr *github.PullRequest, labeledAt, now time.Time) time.Duration {
+	if labeledAt.IsZero() {
+		return now.Sub(pr.GetUpdatedAt().Time)
+	}
+	return now.Sub(labeledAt)
+}
+
+// lastLabeled returns when label was most recently added according to the
+// issue events, or the zero time if no such event is listed.
+func lastLabeled(events []*github.IssueEvent, label string) time.Time {
+	var last time.Time
+	for _, ev := range events {
+		if ev.GetEvent() == "labeled" && strings.EqualFold(ev.GetLabel().GetName(), label) && ev.GetCreatedAt().Time.After(last) {
+			last = ev.GetCreatedAt().Time
+		}
+	}
+	return last
+}
+
+// getLabeledAt returns when label was most recently added to an issue or PR,
+// or the zero time if its events do not say.
+func getLabeledAt(client *github.Client, owner, repo string, number int, label string) (time.Time, error) {
+	events, err := getIssueEvents(client, owner, repo, number)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return lastLabeled(events, label), nil
 }
 
-func closePR(client *github.Client, owner, repo string, prNumber int) error {
+// getIssueEvents returns the events of an issue or PR.
+func getIssueEvents(client *github.Client, owner, repo string, number int) ([]*github.IssueEvent, error) {
 	ctx := context.Background()
-	state := "closed"
-	pr := &github.PullRequest{State: &state}
-	_, _, err := client.PullRequests.Edit(ctx, owner, repo, prNumber, pr)
+	var events []*github.IssueEvent
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := client.Issues.ListIssueEvents(ctx, owner, repo, number, opt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list events of #%d: %v", number, err)
+		}
+		events = append(events, page...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return events, nil
+}
+
+// closePR closes a PR or an issue; the issues API closes both.
+func closePR(client *github.Client, owner, repo string, number int) error {
+	ctx := context.Background()
+	_, _, err := client.Issues.Edit(ctx, owner, repo, number, &github.IssueRequest{State: github.Ptr("closed")})
+	return err
+}
+
+// closeStalePRImmediately closes a stale PR without a warning period: it posts
+// a closing comment, applies the 'closed-stale' label and closes the PR. With
+// a non-zero milestone the PR is parked there instead.
+func closeStalePRImmediately(client *github.Client, tmpl *templateRenderer, owner, repo string, pr *github.PullRequest, data notificationData, milestone int) error {
+	comment, err := tmpl.renderClosure("close comment", closeCommentTemplate, data)
+	if err != nil {
+		return err
+	}
+	if err := postComment(client, owner, repo, pr.GetNumber(), comment); err != nil {
+		return fmt.Errorf("failed to post closing comment: %v", err)
+	}
+	if milestone != 0 {
+		return parkPR(client, owner, repo, pr.GetNumber(), milestone)
+	}
+	if err := addLabel(client, owner, repo, pr.GetNumber(), "closed-stale"); err != nil {
+		return fmt.Errorf("failed to add 'closed-stale' label: %v", err)
+	}
+	return closePR(client, owner, repo, pr.GetNumber())
+}
+
+func postComment(client *github.Client, owner, repo string, number int, body string) error {
+	ctx := context.Background()
+	_, _, err := client.Issues.CreateComment(ctx, owner, repo, number, &github.IssueComment{Body: &body})
 	return err
 }
 
-func addWarningLabel(client *github.Client, owner, repo string, prNumber int) error {
+func addLabel(client *github.Client, owner, repo string, number int, labelName string) error {
 	ctx := context.Background()
-	labels := []string{"stale-warning"}
-	_, _, err := client.Issues.AddLabelsToIssue(ctx, owner, repo, prNumber, labels)
+	_, _, err := client.Issues.AddLabelsToIssue(ctx, owner, repo, number, []string{labelName})
 	return err
 }
 
@@ -301,94 +1097,219 @@ func removeLabel(client *github.Client, owner, repo string, prNumber int, labelN
 	return err
 }
 
-func warnPRAuthor(pr *github.PullRequest, smtpServer string, smtpPort int, smtpUser, smtpPassword string) error {
-	emailAddress := getEmailFromGitHubUser(pr.GetUser())
-	if emailAddress == "" {
-		fmt.Printf("Email could not be determined for user %s\n", pr.GetUser().GetLogin())
-		return nil // Alternatively, handle this case as needed.
+func warnPRAuthor(out *prOutput, pr *github.PullRequest, data notificationData, mail *mailer, attachments ...emailAttachment) error {
+	subject := fmt.Sprintf("Your %s #%d is stale", data.Kind, pr.GetNumber())
+	name, text := "warning email", warningEmailTemplate
+	if len(data.FailingChecks) > 0 {
+		subject = fmt.Sprintf("Your %s #%d has failing checks", data.Kind, pr.GetNumber())
+		name, text = "failing checks warning email", failingChecksWarningEmailTemplate
+	}
+	mail.greet(out, pr.GetUser(), &data)
+	body, err := mail.templates.render(name, text, data)
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("Sending warning email to %s for PR #%d.\n", emailAddress, pr.GetNumber())
-	prLink := pr.GetHTMLURL()
-	subject := fmt.Sprintf("Your pull request #%d is stale", pr.GetNumber())
-	body := fmt.Sprintf(`Hello %s,
+	return mail.notify(out, pr, data, subject, body, attachments...)
+}
 
-Your pull request #%d has been inactive for a while. Please update it within the next few days, or it may be closed.
+func notifyPRClosure(out *prOutput, pr *github.PullRequest, data notificationData, mail *mailer) error {
+	subject := fmt.Sprintf("Your %s #%d has been closed", data.Kind, pr.GetNumber())
+	mail.greet(out, pr.GetUser(), &data)
+	body, err := mail.templates.renderClosure("closure email", closureEmailTemplate, data)
+	if err != nil {
+		return err
+	}
 
-PR Link: %s
+	return mail.notify(out, pr, data, subject, body)
+}
 
-Best regards,
-The Bot`, pr.GetUser().GetLogin(), pr.GetNumber(), prLink)
+// emailAttachment is a file attached to an outgoing email.
+type emailAttachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
 
-	return sendEmail(emailAddress, subject, body, smtpServer, smtpPort, smtpUser, smtpPassword)
+// mailer renders notification emails and sends them through an SMTP server.
+type mailer struct {
+	Server   string
+	Port     int
+	User     string
+	Password string
+	// From is the sender address: --smtp-from, or the user.
+	From      string
+	emails    *emailResolver
+	templates *templateRenderer
+	guard     *writeGuard
+	// via is where notifications go: notifyEmail, notifyComment,
+	// notifyBoth, notifySlack or notifyTeams.
+	via string
+	// prefs overrides via per author.
+	prefs notificationPrefs
+	// comment posts a comment on a PR; it is set once a GitHub client
+	// exists.
+	comment func(number int, body string) error
+	// tls configures STARTTLS and implicit TLS; see newSMTPTLSConfig.
+	tls *tls.Config
+	// encryption is how connections to the SMTP server are encrypted:
+	// smtpEncryptionSTARTTLS, smtpEncryptionTLS or smtpEncryptionNone.
+	encryption string
+	// html renders the HTML part of notification emails; nil sends plain
+	// text only.
+	html *htmltemplate.Template
+	// chats maps notifySlack and notifyTeams to the notifier posting
+	// their messages; a channel without a webhook has none.
+	chats map[string]chatNotifier
+	// suppressed lists the notifications not sent because the author
+	// opted out.
+	suppressed []string
+	// greeting is the name notifications greet their recipient by:
+	// greetDisplayName or greetLogin.
+	greeting string
+	// pool is released while emails are sent, so that other PRs are
+	// processed meanwhile.
+	pool *prPool
+	// budget is charged for each email sent to an author.
+	budget *runBudget
+	// conns keeps the SMTP connections open between emails, at most
+	// --smtp-concurrency of them.
+	conns *smtpPool
 }
 
-func notifyPRClosure(pr *github.PullRequest, smtpServer string, smtpPort int, smtpUser, smtpPassword string) error {
-	emailAddress := getEmailFromGitHubUser(pr.GetUser())
-	if emailAddress == "" {
-		fmt.Printf("Email could not be determined for user %s\n", pr.GetUser().GetLogin())
-		return nil
+func newMailer(cfg *config, templates *templateRenderer, guard *writeGuard, httpClient *http.Client) *mailer {
+	chats := map[string]chatNotifier{}
+	if cfg.SlackWebhookURL != "" {
+		chats[notifySlack] = newSlackNotifier(httpClient, cfg.SlackWebhookURL, cfg.SlackChannel)
 	}
+	if cfg.TeamsWebhookURL != "" {
+		chats[notifyTeams] = newTeamsNotifier(httpClient, cfg.TeamsWebhookURL)
+	}
+	from := cfg.SMTPFrom
+	if from == "" {
+		from = cfg.SMTPUser
+	}
+	return &mailer{
+		Server:    cfg.SMTPServer,
+		Port:      cfg.SMTPPort,
+		User:      cfg.SMTPUser,
+		Password:  cfg.SMTPPassword,
+		From:      from,
+		emails:    newEmailResolver(cfg),
+		templates: templates,
+		guard:     guard,
+		via:       cfg.NotifyVia,
+		prefs:     cfg.NotifyPrefs,
+		greeting:  cfg.GreetingName,
+		chats:     chats,
+	}
+}
 
-	fmt.Printf("Sending closure notification email to %s for PR #%d.\n", emailAddress, pr.GetNumber())
-	prLink := pr.GetHTMLURL()
-	subject := fmt.Sprintf("Your pull request #%d has been closed", pr.GetNumber())
-	body := fmt.Sprintf(`Hello %s,
-
-Your pull request #%d has been closed due to inactivity.
-
-PR Link: %s
-
-If you wish to continue working, please feel free to reopen it or submit a new pull request.
+// authenticates reports whether emails are sent with SMTP authentication,
+// which relays that trust the network can do without.
+func (m *mailer) authenticates() bool {
+	return m.User != "" || m.Password != ""
+}
 
-Best regards,
-The Bot`, pr.GetUser().GetLogin(), pr.GetNumber(), prLink)
+// sendEmail sends an email to toEmail, naming the recipient toName in the
+// headers if it is set. If html is set the email is a multipart/alternative
+// message with body as its plain-text part. Other PRs are processed while it
+// waits for a free SMTP connection and talks to the SMTP server.
+func (m *mailer) sendEmail(out *prOutput, toName, toEmail, subject, body, html string, attachments ...emailAttachment) (err error) {
+	m.pool.blocking(func() { err = m.deliverEmail(out, toName, toEmail, subject, body, html, attachments...) })
+	return err
+}
 
-	return sendEmail(emailAddress, subject, body, smtpServer, smtpPort, smtpUser, smtpPassword)
+// closeConnections quits the SMTP connections kept open by the run.
+func (m *mailer) closeConnections() {
+	if m != nil {
+		m.conns.close()
+	}
 }
 
-func sendEmail(toEmail, subject, body, smtpServer string, smtpPort int, smtpUser, smtpPassword string) error {
+func (m *mailer) deliverEmail(out *prOutput, toName, toEmail, subject, body, html string, attachments ...emailAttachment) error {
+	if m.guard.block(fmt.Sprintf("email to %s: %s", toEmail, subject)) {
+		return errReadOnly
+	}
+
 	e := email.NewEmail()
-	e.From = smtpUser
-	e.To = []string{toEmail}
+	e.From = m.From
+	e.To = []string{formatRecipient(toName, toEmail)}
 	e.Subject = subject
 	e.Text = []byte(body)
+	if html != "" {
+		e.HTML = []byte(html)
+	}
+	for _, a := range attachments {
+		if _, err := e.Attach(bytes.NewReader(a.Data), a.Filename, a.ContentType); err != nil {
+			return fmt.Errorf("failed to attach %s: %v", a.Filename, err)
+		}
+	}
+
+	emailBytes, err := e.Bytes()
+	if err != nil {
+		return fmt.Errorf("failed to generate email bytes: %v", err)
+	}
+
+	client, err := m.conns.get(func() (*smtp.Client, error) { return m.dialSMTP(out) })
+	if err != nil {
+		return err
+	}
+	err = sendSMTPMessage(client, e.From, toEmail, emailBytes)
+	m.conns.put(client, err)
+	return err
+}
 
-	auth := smtp.PlainAuth("", smtpUser, smtpPassword, smtpServer)
-	addr := fmt.Sprintf("%s:%d", smtpServer, smtpPort)
+// dialSMTP opens a connection to the SMTP server, encrypted as
+// --smtp-encryption says and authenticated if credentials are set.
+func (m *mailer) dialSMTP(out *prOutput) (*smtp.Client, error) {
+	addr := net.JoinHostPort(m.Server, strconv.Itoa(m.Port))
 
 	conn, err := net.Dial("tcp", addr)
 	if err != nil {
-		return fmt.Errorf("failed to connect to SMTP server: %v", err)
+		return nil, fmt.Errorf("failed to connect to SMTP server: %v", err)
+	}
+	if m.encryption == smtpEncryptionTLS {
+		conn = tls.Client(conn, m.tls)
 	}
-	defer conn.Close()
 
-	client, err := smtp.NewClient(conn, smtpServer)
+	client, err := smtp.NewClient(conn, m.Server)
 	if err != nil {
-		return fmt.Errorf("failed to create SMTP client: %v", err)
+		conn.Close()
+		return nil, fmt.Errorf("failed to create SMTP client: %v", err)
 	}
-	defer client.Quit()
 
-	if ok, _ := client.Extension("STARTTLS"); ok {
-		config := &tls.Config{
-			InsecureSkipVerify: true,
-			ServerName:         smtpServer,
+	// Without STARTTLS the credentials and the email would cross the
+	// network in the clear; only --smtp-encryption none allows that.
+	if m.encryption == smtpEncryptionSTARTTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, fmt.Errorf("SMTP server does not support STARTTLS; use --smtp-encryption none to send without encryption")
 		}
-		if err = client.StartTLS(config); err != nil {
-			return fmt.Errorf("failed to initiate STARTTLS: %v", err)
+		if err = client.StartTLS(m.tls); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to initiate STARTTLS: %v", err)
 		}
-	} else {
-		fmt.Println("SMTP server does not support STARTTLS")
 	}
 
-	if err = client.Auth(auth); err != nil {
-		return fmt.Errorf("failed to authenticate: %v", err)
+	if m.authenticates() {
+		if err = client.Auth(smtp.PlainAuth("", m.User, m.Password, m.Server)); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to authenticate: %v", err)
+		}
+		out.Debug("authenticated with the SMTP server", "user", m.User)
+	} else {
+		out.Debug("sending without SMTP authentication")
 	}
+	return client, nil
+}
 
-	if err = client.Mail(e.From); err != nil {
+// sendSMTPMessage sends one email over an open SMTP connection.
+func sendSMTPMessage(client *smtp.Client, from, to string, msg []byte) error {
+	if err := client.Mail(from); err != nil {
 		return fmt.Errorf("failed to set sender: %v", err)
 	}
-	if err = client.Rcpt(toEmail); err != nil {
+	if err := client.Rcpt(to); err != nil {
 		return fmt.Errorf("failed to set recipient: %v", err)
 	}
 
@@ -396,33 +1317,84 @@ func sendEmail(toEmail, subject, body, smtpServer string, smtpPort int, smtpUser
 	if err != nil {
 		return fmt.Errorf("failed to send data command: %v", err)
 	}
-
-	emailBytes, err := e.Bytes()
-	if err != nil {
-		return fmt.Errorf("failed to generate email bytes: %v", err)
-	}
-
-	_, err = wc.Write(emailBytes)
-	if err != nil {
+	if _, err = wc.Write(msg); err != nil {
 		return fmt.Errorf("failed to write email body: %v", err)
 	}
-	err = wc.Close()
-	if err != nil {
+	if err = wc.Close(); err != nil {
 		return fmt.Errorf("failed to close email body writer: %v", err)
 	}
-
 	return nil
 }
 
-func getEmailFromGitHubUser(user *github.User) string {
-	email := user.GetEmail()
-	if email != "" {
-		fmt.Printf("Found public email '%s' for user '%s'.\n", email, user.GetLogin())
-		return email
+// emailResolver determines the address to notify a PR's author at.
+type emailResolver struct {
+	fallbackDomain string
+	// emailMap lists addresses to use before any other source; its
+	// wildcard domain replaces fallbackDomain.
+	emailMap *emailMap
+	// guess allows the last resort of an address made of the login and
+	// fallbackDomain; --no-email-guess disables it.
+	guess bool
+	// profiles looks up the public emails and names PR payloads lack; it
+	// is set once a GitHub client exists.
+	profiles *userProfiles
+	// commits lists the commits of a PR of the repository being scanned;
+	// it is set once a GitHub client exists.
+	commits func(number int) ([]*github.RepositoryCommit, error)
+}
+
+func newEmailResolver(cfg *config) *emailResolver {
+	r := &emailResolver{fallbackDomain: cfg.FallbackEmailDomain, emailMap: cfg.EmailMap, guess: !cfg.NoEmailGuess}
+	if cfg.EmailMap != nil && cfg.EmailMap.domain != "" {
+		r.fallbackDomain = cfg.EmailMap.domain
 	}
-	// Use the fallback email domain set from the flag.
-	username := strings.ToLower(user.GetLogin())
-	email = fmt.Sprintf("%s@%s", username, fallbackEmailDomain)
-	fmt.Printf("Constructed email '%s' for user '%s'.\n", email, user.GetLogin())
+	return r
+}
+
+// Sources of an author's address, logged for auditing.
+const (
+	emailSourceMap      = "map"
+	emailSourceProfile  = "profile"
+	emailSourceCommit   = "commit"
+	emailSourceFallback = "fallback"
+)
+
+// address returns the author's address from --email-map, or else their
+// public email, or else the email of their latest commit to the PR, or else
+// a guess from the fallback domain. It returns "" if none is usable, and the
+// PR's author is not emailed.
+func (r *emailResolver) address(out *prOutput, pr *github.PullRequest) string {
+	user := pr.GetUser()
+	email, source := r.lookup(out, pr)
+	if email == "" {
+		out.Debug("no mapped, public or commit email, and --no-email-guess is set", "user", user.GetLogin())
+		return ""
+	}
+	out.Info("resolved author email", "user", user.GetLogin(), "email", email, "source", source)
 	return email
 }
+
+// lookup returns the author's address and the source it came from.
+func (r *emailResolver) lookup(out *prOutput, pr *github.PullRequest) (email, source string) {
+	user := pr.GetUser()
+	if email = r.emailMap.lookup(user.GetLogin()); email != "" {
+		return email, emailSourceMap
+	}
+	if email = r.profiles.get(out, user).GetEmail(); email != "" {
+		return email, emailSourceProfile
+	}
+	if r.commits != nil {
+		commits, err := r.commits(pr.GetNumber())
+		if err != nil {
+			out.Warn("could not list the PR's commits for the author's email", "user", user.GetLogin(), "err", err)
+		} else if email = commitEmail(commits, user.GetLogin()); email != "" {
+			return email, emailSourceCommit
+		}
+	}
+	if !r.guess {
+		return "", ""
+	}
+	// Use the fallback email domain set from the flag or the email map.
+	username := strings.ToLower(user.GetLogin())
+	return fmt.Sprintf("%s@%s", username, r.fallbackDomain), emailSourceFallback
+}
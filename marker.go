@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+const (
+	summaryIssueTitle = "[stale-bot] last run"
+	summaryIssueLabel = "stale-bot-status"
+	summaryGistFile   = "stale-pr-bot-summary.md"
+)
+
+// renderRunSummary renders the run summary published to the marker issue or gist.
+func renderRunSummary(tmpl *templateRenderer, owner, repo string, summary *runSummary, now time.Time) (string, error) {
+	return tmpl.render("run summary", runSummaryTemplate, struct {
+		Now     time.Time
+		Owner   string
+		Repo    string
+		Summary *runSummary
+	}{now, owner, repo, summary})
+}
+
+// updateSummaryIssue finds the open issue labelled summaryIssueLabel with the
+// summaryIssueTitle title and replaces its body with the run summary. If the
+// issue does not exist, e.g. because someone deleted or closed it, a new one
+// is created.
+func updateSummaryIssue(client *github.Client, owner, repo, body string) error {
+	ctx := context.Background()
+	opts := &github.IssueListByRepoOptions{
+		State:       "open",
+		Labels:      []string{summaryIssueLabel},
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		issues, resp, err := client.Issues.ListByRepo(ctx, owner, repo, opts)
+		if err != nil {
+			return fmt.Errorf("failed to look up summary issue: %v", err)
+		}
+		for _, issue := range issues {
+			if issue.IsPullRequest() || issue.GetTitle() != summaryIssueTitle {
+				continue
+			}
+			_, _, err := client.Issues.Edit(ctx, owner, repo, issue.GetNumber(), &github.IssueRequest{Body: &body})
+			if err != nil {
+				return fmt.Errorf("failed to update summary issue #%d: %v", issue.GetNumber(), err)
+			}
+			slog.Info("updated summary issue", "issue", issue.GetNumber())
+			return nil
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	title := summaryIssueTitle
+	issue, _, err := client.Issues.Create(ctx, owner, repo, &github.IssueRequest{
+		Title:  &title,
+		Body:   &body,
+		Labels: &[]string{summaryIssueLabel},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create summary issue: %v", err)
+	}
+	slog.Info("created summary issue", "issue", issue.GetNumber())
+	return nil
+}
+
+// updateSummaryGist overwrites the summary file in the given gist. If the gist
+// no longer exists a new secret gist is created and its ID logged so the
+// operator can update --summary-gist-id.
+func updateSummaryGist(client *github.Client, gistID, owner, repo, body string) error {
+	ctx := context.Background()
+	files := map[github.GistFilename]github.GistFile{
+		summaryGistFile: {Content: &body},
+	}
+	_, _, err := client.Gists.Edit(ctx, gistID, &github.Gist{Files: files})
+	if err == nil {
+		slog.Info("updated summary gist", "gist", gistID)
+		return nil
+	}
+	var errResp *github.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Response.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to update summary gist %s: %v", gistID, err)
+	}
+
+	slog.Warn("summary gist not found; creating a new one", "gist", gistID)
+	description := fmt.Sprintf("stale-pr-bot last run for %s/%s", owner, repo)
+	gist, _, err := client.Gists.Create(ctx, &github.Gist{
+		Description: &description,
+		Public:      github.Ptr(false),
+		Files:       files,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create summary gist: %v", err)
+	}
+	slog.Info("created summary gist; set --summary-gist-id to keep updating it", "gist", gist.GetID())
+	return nil
+}
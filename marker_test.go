@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSummaryRepo is the issue tracker of acme/api as far as the summary
+// marker is concerned: it serves the open issues labelled summaryIssueLabel
+// and records every write.
+type fakeSummaryRepo struct {
+	mu     sync.Mutex
+	issues string
+	writes []string
+}
+
+func (f *fakeSummaryRepo) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	w.Header().Set("Content-Type", "application/json")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/repos/acme/api/issues":
+		if r.URL.Query().Get("labels") != summaryIssueLabel || r.URL.Query().Get("state") != "open" {
+			http.Error(w, "unexpected query "+r.URL.RawQuery, http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, f.issues)
+	case r.Method == http.MethodPatch || r.Method == http.MethodPost:
+		f.writes = append(f.writes, r.Method+" "+r.URL.Path+" "+strings.TrimSpace(string(body)))
+		fmt.Fprint(w, `{"number":42}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestUpdateSummaryIssue(t *testing.T) {
+	tests := []struct {
+		name   string
+		issues string
+		want   string
+	}{
+		{
+			name:   "create",
+			issues: `[]`,
+			want:   `POST /repos/acme/api/issues {"title":"[stale-bot] last run","body":"warned 2","labels":["stale-bot-status"]}`,
+		},
+		{
+			name:   "update in place",
+			issues: `[{"number":3,"title":"[stale-bot] last run"}]`,
+			want:   `PATCH /repos/acme/api/issues/3 {"body":"warned 2"}`,
+		},
+		{
+			// The marker was deleted or closed; only look-alikes are left.
+			name:   "recreate after delete",
+			issues: `[{"number":4,"title":"[stale-bot] last run","pull_request":{"url":"x"}},{"number":5,"title":"stale-bot notes"}]`,
+			want:   `POST /repos/acme/api/issues {"title":"[stale-bot] last run","body":"warned 2","labels":["stale-bot-status"]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeSummaryRepo{issues: tt.issues}
+			client, _ := newTestGitHubClient(t, repo)
+			if err := updateSummaryIssue(client, "acme", "api", "warned 2"); err != nil {
+				t.Fatal(err)
+			}
+			if len(repo.writes) != 1 || repo.writes[0] != tt.want {
+				t.Errorf("writes %q, want %s", repo.writes, tt.want)
+			}
+			// Running again with the marker in place updates it.
+			repo.issues = `[{"number":42,"title":"[stale-bot] last run"}]`
+			if err := updateSummaryIssue(client, "acme", "api", "warned 2"); err != nil {
+				t.Fatal(err)
+			}
+			if want := `PATCH /repos/acme/api/issues/42 {"body":"warned 2"}`; len(repo.writes) != 2 || repo.writes[1] != want {
+				t.Errorf("second run writes %q, want %s", repo.writes[1:], want)
+			}
+		})
+	}
+}
+
+func TestUpdateSummaryIssueDryRun(t *testing.T) {
+	repo := &fakeSummaryRepo{issues: `[]`}
+	_, srv := newTestGitHubClient(t, repo)
+	guard := newWriteGuard(false, true)
+	client, err := getGithubClient("token", srv.URL+"/", "", "", sshProxyOptions{}, newRunBudget(0, 0), 0, newRateLimitWait(0), guard, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := updateSummaryIssue(client, "acme", "api", "warned 2"); err == nil || !strings.Contains(err.Error(), errReadOnly.Error()) {
+		t.Errorf("updateSummaryIssue in a dry run returned %v, want the write blocked", err)
+	}
+	if len(repo.writes) != 0 {
+		t.Errorf("a dry run wrote %q", repo.writes)
+	}
+}
+
+func TestUpdateSummaryGist(t *testing.T) {
+	tests := []struct {
+		name       string
+		editStatus int
+		want       string
+		wantErr    string
+	}{
+		{name: "update", editStatus: http.StatusOK, want: "[PATCH /gists/abc]"},
+		{name: "recreate after delete", editStatus: http.StatusNotFound, want: "[PATCH /gists/abc POST /gists]"},
+		{name: "edit refused", editStatus: http.StatusForbidden, want: "[PATCH /gists/abc]", wantErr: "failed to update summary gist abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []string
+			var created string
+			client, _ := newTestGitHubClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests = append(requests, r.Method+" "+r.URL.Path)
+				body, _ := io.ReadAll(r.Body)
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case r.Method == http.MethodPatch && r.URL.Path == "/gists/abc":
+					if !strings.Contains(string(body), `"stale-pr-bot-summary.md":{"content":"warned 2"}`) {
+						t.Errorf("gist edit %s does not overwrite the summary file", body)
+					}
+					w.WriteHeader(tt.editStatus)
+					fmt.Fprint(w, `{"id":"abc"}`)
+				case r.Method == http.MethodPost && r.URL.Path == "/gists":
+					created = string(body)
+					w.WriteHeader(http.StatusCreated)
+					fmt.Fprint(w, `{"id":"def"}`)
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			err := updateSummaryGist(client, "abc", "acme", "api", "warned 2")
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("updateSummaryGist returned %v, want an error containing %q", err, tt.wantErr)
+			}
+			if fmt.Sprint(requests) != tt.want {
+				t.Errorf("requests %v, want %s", requests, tt.want)
+			}
+			if created != "" && (!strings.Contains(created, `"public":false`) || !strings.Contains(created, `"description":"stale-pr-bot last run for acme/api"`)) {
+				t.Errorf("created gist %s, want a secret gist described for acme/api", created)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// noActionDays are the days the bot makes no changes, so that no fallout
+// lands when nobody is around: days of the week and holidays.
+type noActionDays struct {
+	Weekdays map[time.Weekday]bool
+	// Holidays maps dates, as YYYY-MM-DD, to their name if known.
+	Holidays map[string]string
+}
+
+// parseNoActionDays parses a comma-separated list of weekdays, e.g.
+// "Sat,Sun", and YYYY-MM-DD dates.
+func parseNoActionDays(s string) (noActionDays, error) {
+	days := noActionDays{Weekdays: map[time.Weekday]bool{}, Holidays: map[string]string{}}
+	for _, entry := range splitList(s) {
+		if _, err := time.Parse("2006-01-02", entry); err == nil {
+			days.Holidays[entry] = ""
+			continue
+		}
+		day, ok := parseWeekday(entry)
+		if !ok {
+			return noActionDays{}, fmt.Errorf("invalid day %q: expected a weekday such as Sat or a date as YYYY-MM-DD", entry)
+		}
+		days.Weekdays[day] = true
+	}
+	return days, nil
+}
+
+// parseWeekday parses a weekday name, full or abbreviated to three letters,
+// in any case.
+func parseWeekday(s string) (time.Weekday, bool) {
+	s = strings.ToLower(s)
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || s == name[:3] {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// loadHolidays adds the holidays of a file with one "YYYY-MM-DD [name]" entry
+// per line. Blank lines and lines starting with # are ignored, and invalid
+// lines are reported and skipped.
+func (d noActionDays) loadHolidays(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open holidays file: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		date, name, _ := strings.Cut(line, " ")
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			slog.Warn("expected \"YYYY-MM-DD [name]\", ignoring line", "file", path, "line", n)
+			continue
+		}
+		d.Holidays[date] = strings.TrimSpace(name)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read holidays file: %v", err)
+	}
+	return nil
+}
+
+// match returns why t, in its own location, is a no-action day, or "" if it
+// is not one.
+func (d noActionDays) match(t time.Time) string {
+	date := t.Format("2006-01-02")
+	if name, ok := d.Holidays[date]; ok {
+		if name != "" {
+			return fmt.Sprintf("%s is a holiday (%s)", date, name)
+		}
+		return fmt.Sprintf("%s is a holiday", date)
+	}
+	if d.Weekdays[t.Weekday()] {
+		return fmt.Sprintf("it is %s", t.Weekday())
+	}
+	return ""
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNoActionDays(t *testing.T) {
+	days, err := parseNoActionDays("Sat, sunday, 2026-03-17")
+	if err != nil {
+		t.Fatal(err)
+	}
+	holidays := writeTestFile(t, "holidays.txt", "# Company holidays\n\n2026-03-18 Founders' Day\n2026-03-19\nnot a date\n2026-13-01 Nonsense\n")
+	if err := days.loadHolidays(holidays); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		day  int
+		want string
+	}{
+		{16, ""},
+		{17, "2026-03-17 is a holiday"},
+		{18, "2026-03-18 is a holiday (Founders' Day)"},
+		{19, "2026-03-19 is a holiday"},
+		{20, ""},
+		{21, "it is Saturday"},
+		{22, "it is Sunday"},
+	} {
+		if got := days.match(time.Date(2026, 3, tc.day, 12, 0, 0, 0, time.UTC)); got != tc.want {
+			t.Errorf("March %d: match = %q, want %q", tc.day, got, tc.want)
+		}
+	}
+
+	// The day is that of the time's own location.
+	tokyo := time.FixedZone("JST", 9*60*60)
+	if got := days.match(time.Date(2026, 3, 20, 20, 0, 0, 0, time.UTC).In(tokyo)); got != "it is Saturday" {
+		t.Errorf("Friday evening UTC in Tokyo: match = %q, want it is Saturday", got)
+	}
+
+	if err := days.loadHolidays(t.TempDir() + "/missing.txt"); err == nil {
+		t.Error("loading a missing holidays file succeeded")
+	}
+}
+
+func TestParseNoActionDaysErrors(t *testing.T) {
+	for _, s := range []string{"Sa", "weekend", "2026-02-30", "17.03.2026"} {
+		if _, err := parseNoActionDays(s); err == nil || !strings.Contains(err.Error(), "invalid day") {
+			t.Errorf("parseNoActionDays(%q) returned %v, want an invalid day", s, err)
+		}
+	}
+	days, err := parseNoActionDays("")
+	if err != nil || len(days.Weekdays)+len(days.Holidays) != 0 {
+		t.Errorf("parseNoActionDays(\"\") = %+v, %v; want no days", days, err)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// Channels notifications are delivered through, selected with --notify-via.
+const (
+	notifyEmail   = "email"
+	notifyComment = "comment"
+	notifyBoth    = "both"
+	// notifySlack and notifyTeams post to the --slack-webhook-url or
+	// --teams-webhook-url instead of contacting the author.
+	notifySlack = "slack"
+	notifyTeams = "teams"
+	// notifyNone is a per-author preference: only labels are applied.
+	notifyNone = "none"
+)
+
+// notificationPrefs maps lower-cased logins to how they want to be notified.
+type notificationPrefs map[string]string
+
+// loadNotificationPrefs reads a preferences file with one "login: channel"
+// entry per line, where channel is email, comment, both, slack, teams or none.
+// Blank lines and lines starting with # are ignored; invalid entries are
+// reported and skipped so those authors get the default.
+func loadNotificationPrefs(path string) (notificationPrefs, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open notification preferences file: %v", err)
+	}
+	defer f.Close()
+
+	prefs := notificationPrefs{}
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		login, pref, ok := strings.Cut(line, ":")
+		login = strings.TrimSpace(login)
+		pref = strings.ToLower(strings.TrimSpace(pref))
+		switch {
+		case !ok || login == "":
+			slog.Warn("expected \"login: channel\", ignoring line", "file", path, "line", lineNo)
+		case pref != notifyEmail && pref != notifyComment && pref != notifyBoth && pref != notifySlack && pref != notifyTeams && pref != notifyNone:
+			slog.Warn("unknown notification preference, using the default", "file", path, "line", lineNo, "preference", pref, "author", login)
+		default:
+			prefs[strings.ToLower(login)] = pref
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read notification preferences file: %v", err)
+	}
+	return prefs, nil
+}
+
+// needsEmail reports whether any author can be notified by email.
+func (p notificationPrefs) needsEmail(defaultVia string) bool {
+	if defaultVia == notifyEmail || defaultVia == notifyBoth {
+		return true
+	}
+	for _, pref := range p {
+		if pref == notifyEmail || pref == notifyBoth {
+			return true
+		}
+	}
+	return false
+}
+
+// needsChat reports whether any notification goes to the chat channel via,
+// notifySlack or notifyTeams.
+func (p notificationPrefs) needsChat(defaultVia, via string) bool {
+	if defaultVia == via {
+		return true
+	}
+	for _, pref := range p {
+		if pref == via {
+			return true
+		}
+	}
+	return false
+}
+
+// chatNotifier posts notifications to a chat webhook instead of contacting
+// the author.
+type chatNotifier interface {
+	// post sends one message about pr, retrying as the service allows.
+	post(out *prOutput, subject string, pr *github.PullRequest, data notificationData) error
+	// destination names where messages go, for logs and dry-run output.
+	destination() string
+}
+
+// viaFor returns how login is notified: their preference, or the default.
+func (m *mailer) viaFor(login string) string {
+	if pref, ok := m.prefs[strings.ToLower(login)]; ok {
+		return pref
+	}
+	return m.via
+}
+
+// notify delivers a notification to a PR's author through the configured
+// channels. In notifyBoth mode a failed email does not stop the comment, and
+// the notification counts as delivered once the comment is posted. In
+// notifySlack mode the subject and data are posted to Slack and body is not
+// used; notifyTeams is the same with Teams.
+func (m *mailer) notify(out *prOutput, pr *github.PullRequest, data notificationData, subject, body string, attachments ...emailAttachment) error {
+	via := m.viaFor(pr.GetUser().GetLogin())
+	if via == notifyNone {
+		out.Info("not notifying the author: they opted out of notifications", "author", pr.GetUser().GetLogin())
+		m.suppressed = append(m.suppressed, fmt.Sprintf("PR #%d (@%s): %s", pr.GetNumber(), pr.GetUser().GetLogin(), subject))
+		return nil
+	}
+	if via == notifySlack || via == notifyTeams {
+		chat := m.chats[via]
+		if chat == nil {
+			return fmt.Errorf("no --%s-webhook-url to notify about PR #%d", via, pr.GetNumber())
+		}
+		if m.guard.block(fmt.Sprintf("message to %s: %s", chat.destination(), subject)) {
+			return errReadOnly
+		}
+		out.Info("posting a message", "to", chat.destination(), "subject", subject)
+		return chat.post(out, subject, pr, data)
+	}
+	var emailErr error
+	if via != notifyComment {
+		emailErr = m.emailAuthor(out, pr, data, subject, body, attachments...)
+		if via == notifyEmail {
+			return emailErr
+		}
+		if emailErr != nil {
+			out.Error("sending email failed; posting the comment anyway", "action", "email", "err", emailErr)
+		}
+	}
+	if m.comment == nil {
+		return fmt.Errorf("no GitHub client to comment on PR #%d", pr.GetNumber())
+	}
+	out.Info("commenting to notify the author", "author", pr.GetUser().GetLogin())
+	if err := m.comment(pr.GetNumber(), fmt.Sprintf("@%s\n\n%s", pr.GetUser().GetLogin(), body)); err != nil {
+		if emailErr != nil {
+			return fmt.Errorf("email: %v; comment: %v", emailErr, err)
+		}
+		return fmt.Errorf("failed to post notification comment: %v", err)
+	}
+	return nil
+}
+
+// byEmail reports whether a PR's author is notified by email, so that
+// notifying them uses the email budget.
+func (m *mailer) byEmail(pr *github.PullRequest) bool {
+	via := m.viaFor(pr.GetUser().GetLogin())
+	return via == notifyEmail || via == notifyBoth
+}
+
+// emailAuthor emails a notification to a PR's author, with an HTML part
+// rendered from data and body unless --email-format is text. The email is
+// charged to the run budget before the author's address is looked up, and
+// refunded if it is not sent.
+func (m *mailer) emailAuthor(out *prOutput, pr *github.PullRequest, data notificationData, subject, body string, attachments ...emailAttachment) error {
+	if !m.budget.takeEmail() {
+		return errEmailBudgetExhausted
+	}
+	emailAddress := m.emails.address(out, pr)
+	if emailAddress == "" {
+		m.budget.refundEmail()
+		out.Warn("email address could not be determined", "author", pr.GetUser().GetLogin())
+		return nil
+	}
+	var html string
+	if m.html != nil {
+		var err error
+		if html, err = renderHTMLEmail(m.html, data, subject, body); err != nil {
+			out.Warn("sending the email as plain text only", "err", err)
+		}
+	}
+	out.Info("sending email", "subject", subject, "to", emailAddress)
+	if err := m.sendEmail(out, m.emails.displayName(out, pr.GetUser()), emailAddress, subject, body, html, attachments...); err != nil {
+		m.budget.refundEmail()
+		return err
+	}
+	return nil
+}
+
+// recipient describes where a PR's author is notified, for dry-run output.
+func (m *mailer) recipient(out *prOutput, pr *github.PullRequest) string {
+	comment := fmt.Sprintf("@%s in a comment", pr.GetUser().GetLogin())
+	via := m.viaFor(pr.GetUser().GetLogin())
+	switch via {
+	case notifyNone:
+		return fmt.Sprintf("nobody (@%s opted out)", pr.GetUser().GetLogin())
+	case notifyComment:
+		return comment
+	case notifySlack, notifyTeams:
+		if chat := m.chats[via]; chat != nil {
+			return chat.destination()
+		}
+		return "nobody (no webhook configured)"
+	}
+	email := m.emails.address(out, pr)
+	if email == "" {
+		email = "nobody by email (no address found)"
+	}
+	if via == notifyBoth {
+		return email + " and " + comment
+	}
+	return email
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+func TestLoadNotificationPrefs(t *testing.T) {
+	logs := captureLog(t)
+	prefs, err := loadNotificationPrefs(writeTestFile(t, "prefs.txt", `# collected by the signup form
+alice: email
+Bob:Comment
+carol : none
+
+dave: both
+erin: pigeon
+frank
+: email
+grace: slack
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := notificationPrefs{"alice": notifyEmail, "bob": notifyComment, "carol": notifyNone, "dave": notifyBoth, "grace": notifySlack}
+	if !reflect.DeepEqual(prefs, want) {
+		t.Errorf("loaded %v, want %v", prefs, want)
+	}
+	for _, want := range []string{"preference=pigeon", "line=8", "line=9"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("the invalid entry with %s was not reported:\n%s", want, logs)
+		}
+	}
+	if _, err := loadNotificationPrefs("testdata/no-such-prefs.txt"); err == nil {
+		t.Error("loading a missing file succeeded")
+	}
+}
+
+func TestNotificationPrefsNeeds(t *testing.T) {
+	prefs := notificationPrefs{"alice": notifyBoth, "bob": notifyTeams}
+	for _, tc := range []struct {
+		prefs      notificationPrefs
+		defaultVia string
+		email      bool
+		slack      bool
+	}{
+		{prefs: nil, defaultVia: notifyComment},
+		{prefs: nil, defaultVia: notifyEmail, email: true},
+		{prefs: nil, defaultVia: notifySlack, slack: true},
+		{prefs: prefs, defaultVia: notifyComment, email: true},
+		{prefs: notificationPrefs{"alice": notifySlack, "bob": notifyNone}, defaultVia: notifyComment, slack: true},
+	} {
+		if got := tc.prefs.needsEmail(tc.defaultVia); got != tc.email {
+			t.Errorf("%v.needsEmail(%s) = %v, want %v", tc.prefs, tc.defaultVia, got, tc.email)
+		}
+		if got := tc.prefs.needsChat(tc.defaultVia, notifySlack); got != tc.slack {
+			t.Errorf("%v.needsChat(%s, slack) = %v, want %v", tc.prefs, tc.defaultVia, got, tc.slack)
+		}
+	}
+}
+
+// TestNotificationRouting warns the PRs of authors with each preference and
+// checks how each was notified. The preference of an author who opted out
+// wins over every channel: their PR is only labeled, and the suppression is
+// recorded. Authors with no preference get the default channel.
+func TestNotificationRouting(t *testing.T) {
+	smtpd := startTestSMTPServer(t, nil, false)
+	gh := &fakeGitHub{}
+	cfg := &config{
+		SMTPServer:          "127.0.0.1",
+		SMTPPort:            smtpd.port(),
+		SMTPFrom:            "bot@example.com",
+		NotifyVia:           notifyComment,
+		FallbackEmailDomain: "example.com",
+		DisplayLocation:     time.UTC,
+		Rules:               testRules(),
+	}
+	s := newTestRepoScanner(t, gh, cfg)
+	s.mail.encryption = smtpEncryptionNone
+	s.mail.prefs = notificationPrefs{"alice": notifyEmail, "bob": notifyBoth, "carol": notifyNone}
+	authors := []string{"alice", "bob", "carol", "dave"}
+	for i, login := range authors {
+		pr := testPR(login, time.Now().AddDate(0, 0, -45))
+		pr.Number = github.Ptr(i + 1)
+		if d := s.actOn(pr); d.Action != actionWarn {
+			t.Errorf("@%s's PR decided %s, want warn", login, d.Action)
+		}
+	}
+
+	var emailed []string
+	received, _ := smtpd.emails()
+	for _, email := range received {
+		for _, login := range authors {
+			if strings.Contains(email, "To: "+login+"@example.com") || strings.Contains(email, "<"+login+"@example.com>") {
+				emailed = append(emailed, login)
+			}
+		}
+	}
+	sort.Strings(emailed)
+	if fmt.Sprint(emailed) != "[alice bob]" {
+		t.Errorf("emailed %v, want alice and bob", emailed)
+	}
+	var commented, labeled []int
+	for _, w := range gh.written() {
+		var number int
+		if _, err := fmt.Sscanf(w, "POST /repos/acme/api/issues/%d/comments", &number); err == nil {
+			commented = append(commented, number)
+		} else if _, err := fmt.Sscanf(w, "POST /repos/acme/api/issues/%d/labels", &number); err == nil {
+			labeled = append(labeled, number)
+		}
+	}
+	if fmt.Sprint(commented) != "[2 4]" || fmt.Sprint(labeled) != "[1 2 3 4]" {
+		t.Errorf("commented on %v and labeled %v, want comments on bob's and dave's PRs and all labeled", commented, labeled)
+	}
+	if len(s.mail.suppressed) != 1 || !strings.HasPrefix(s.mail.suppressed[0], "PR #3 (@carol)") {
+		t.Errorf("suppressed %q, want carol's warning", s.mail.suppressed)
+	}
+	if len(s.summary.Warned) != 4 {
+		t.Errorf("%d PRs counted as warned, want all 4", len(s.summary.Warned))
+	}
+}
+
+// TestNotificationRecipient checks how dry runs describe where each author
+// would be notified.
+func TestNotificationRecipient(t *testing.T) {
+	cfg := &config{NotifyVia: notifyEmail, FallbackEmailDomain: "example.com", DisplayLocation: time.UTC, Rules: testRules()}
+	s := newTestRepoScanner(t, &fakeGitHub{}, cfg)
+	s.mail.prefs = notificationPrefs{"bob": notifyBoth, "carol": notifyNone, "dave": notifyComment, "erin": notifyTeams}
+	for login, want := range map[string]string{
+		"alice": "alice@example.com",
+		"bob":   "bob@example.com and @bob in a comment",
+		"carol": "nobody (@carol opted out)",
+		"dave":  "@dave in a comment",
+		"erin":  "nobody (no webhook configured)",
+	} {
+		if got := s.mail.recipient(&prOutput{}, testPR(login, time.Now())); got != want {
+			t.Errorf("recipient for @%s = %q, want %q", login, got, want)
+		}
+	}
+}
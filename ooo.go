@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// oooRange is a period an author is out of office, from Start up to but not
+// including End. A zero End means no return date is known.
+type oooRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+func (r oooRange) contains(t time.Time) bool {
+	return !t.Before(r.Start) && (r.End.IsZero() || t.Before(r.End))
+}
+
+// oooCalendar maps lower-cased GitHub logins to their out-of-office ranges.
+type oooCalendar map[string][]oooRange
+
+// loadOOOCalendar reads an out-of-office file with one "login,start,end" CSV
+// record per range. Dates are YYYY-MM-DD in loc, and both are inclusive; an
+// empty end date means the author's return date is not known. Blank lines
+// and lines starting with # are ignored, and invalid records are reported and
+// skipped.
+func loadOOOCalendar(path string, loc *time.Location) (oooCalendar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open out-of-office file: %v", err)
+	}
+	defer f.Close()
+
+	cal := oooCalendar{}
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read out-of-office file: %v", err)
+		}
+		line, _ := r.FieldPos(0)
+		if len(rec) < 2 || len(rec) > 3 || strings.TrimSpace(rec[0]) == "" {
+			slog.Warn("expected \"login,start,end\", ignoring line", "file", path, "line", line)
+			continue
+		}
+		start, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(rec[1]), loc)
+		if err != nil {
+			slog.Warn("invalid start date, ignoring line", "file", path, "line", line, "date", rec[1])
+			continue
+		}
+		rng := oooRange{Start: start}
+		if len(rec) == 3 && strings.TrimSpace(rec[2]) != "" {
+			end, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(rec[2]), loc)
+			if err != nil || end.Before(start) {
+				slog.Warn("invalid end date, ignoring line", "file", path, "line", line, "date", rec[2])
+				continue
+			}
+			rng.End = end.AddDate(0, 0, 1)
+		}
+		login := strings.ToLower(strings.TrimSpace(rec[0]))
+		cal[login] = append(cal[login], rng)
+	}
+	return cal, nil
+}
+
+// away reports whether login is out of office at now and, if so, when they
+// are back. Overlapping and back-to-back ranges are treated as one absence.
+// A zero return time means no return date is known.
+func (c oooCalendar) away(login string, now time.Time) (time.Time, bool) {
+	ranges := c[strings.ToLower(login)]
+	back, away := now, false
+	for extended := true; extended; {
+		extended = false
+		for _, r := range ranges {
+			if !r.contains(back) {
+				continue
+			}
+			if r.End.IsZero() {
+				return time.Time{}, true
+			}
+			back, away, extended = r.End, true, true
+		}
+	}
+	if !away {
+		return time.Time{}, false
+	}
+	return back, true
+}
+
+// returned returns when login last came back from an absence that ended by
+// now, or the zero time if there is none. A range ending within another one
+// is not a return.
+func (c oooCalendar) returned(login string, now time.Time) time.Time {
+	var last time.Time
+	for _, r := range c[strings.ToLower(login)] {
+		if r.End.IsZero() || r.End.After(now) || !r.End.After(last) {
+			continue
+		}
+		if _, away := c.away(login, r.End); !away {
+			last = r.End
+		}
+	}
+	return last
+}
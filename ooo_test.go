@@ -0,0 +1,155 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testOOOFile = `# login,start,end
+alice,2026-03-02,2026-03-06
+alice, 2026-03-05, 2026-03-10
+alice,2026-04-01,2026-04-03
+Bob,2026-03-01,
+carol,2026-03-20,2026-03-18
+dave,next week
+erin,2026-03-01,2026-03-01
+mallory
+`
+
+func TestLoadOOOCalendar(t *testing.T) {
+	logs := captureLog(t)
+	cal, err := loadOOOCalendar(writeTestFile(t, "ooo.csv", testOOOFile), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := func(m time.Month, d int) time.Time { return time.Date(2026, m, d, 0, 0, 0, 0, time.UTC) }
+	want := oooCalendar{
+		"alice": {
+			{Start: day(3, 2), End: day(3, 7)},
+			{Start: day(3, 5), End: day(3, 11)},
+			{Start: day(4, 1), End: day(4, 4)},
+		},
+		"bob":  {{Start: day(3, 1)}},
+		"erin": {{Start: day(3, 1), End: day(3, 2)}},
+	}
+	if len(cal) != len(want) {
+		t.Errorf("loaded %v, want %v", cal, want)
+	}
+	for login, ranges := range want {
+		if got := cal[login]; len(got) != len(ranges) {
+			t.Errorf("%s has ranges %v, want %v", login, got, ranges)
+			continue
+		}
+		for i, r := range ranges {
+			if got := cal[login][i]; !got.Start.Equal(r.Start) || !got.End.Equal(r.End) {
+				t.Errorf("%s range %d = %v, want %v", login, i, got, r)
+			}
+		}
+	}
+	for _, want := range []string{"line=6", "line=7", "line=9"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("the invalid record at %s was not reported:\n%s", want, logs)
+		}
+	}
+
+	if _, err := loadOOOCalendar(writeTestFile(t, "ooo.csv", "alice,\"2026-03-01\n"), time.UTC); err == nil {
+		t.Error("loading a malformed CSV file succeeded")
+	}
+}
+
+// TestOOOAway checks the boundaries of absences, absences of several
+// overlapping ranges and absences with no return date.
+func TestOOOAway(t *testing.T) {
+	captureLog(t)
+	cal, err := loadOOOCalendar(writeTestFile(t, "ooo.csv", testOOOFile), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(m time.Month, d, h int) time.Time { return time.Date(2026, m, d, h, 0, 0, 0, time.UTC) }
+	for _, tc := range []struct {
+		login    string
+		now      time.Time
+		wantAway bool
+		wantBack time.Time
+	}{
+		{login: "alice", now: at(3, 1, 23)},
+		{login: "alice", now: at(3, 2, 0), wantAway: true, wantBack: at(3, 11, 0)},
+		// The second range overlaps the first, so alice is back after it.
+		{login: "alice", now: at(3, 6, 23), wantAway: true, wantBack: at(3, 11, 0)},
+		{login: "alice", now: at(3, 10, 23), wantAway: true, wantBack: at(3, 11, 0)},
+		{login: "alice", now: at(3, 11, 0)},
+		{login: "alice", now: at(3, 20, 12)},
+		{login: "alice", now: at(4, 3, 12), wantAway: true, wantBack: at(4, 4, 0)},
+		{login: "ALICE", now: at(4, 1, 0), wantAway: true, wantBack: at(4, 4, 0)},
+		{login: "bob", now: at(2, 28, 12)},
+		{login: "bob", now: at(3, 1, 0), wantAway: true},
+		{login: "bob", now: at(12, 31, 12), wantAway: true},
+		{login: "erin", now: at(3, 1, 23), wantAway: true, wantBack: at(3, 2, 0)},
+		{login: "erin", now: at(3, 2, 0)},
+		{login: "carol", now: at(3, 19, 0)},
+	} {
+		back, away := cal.away(tc.login, tc.now)
+		if away != tc.wantAway || !back.Equal(tc.wantBack) {
+			t.Errorf("away(%s, %s) = %s, %v, want %s, %v", tc.login, tc.now.Format(time.RFC3339), back, away, tc.wantBack, tc.wantAway)
+		}
+	}
+
+	for _, tc := range []struct {
+		now  time.Time
+		want time.Time
+	}{
+		// alice is still away when her first range ends.
+		{now: at(3, 10, 0)},
+		{now: at(3, 11, 0), want: at(3, 11, 0)},
+		{now: at(3, 30, 0), want: at(3, 11, 0)},
+		{now: at(4, 10, 0), want: at(4, 4, 0)},
+	} {
+		if got := cal.returned("alice", tc.now); !got.Equal(tc.want) {
+			t.Errorf("returned(alice, %s) = %s, want %s", tc.now.Format(time.RFC3339), got, tc.want)
+		}
+	}
+}
+
+// TestOOODefersEnforcement evaluates stale PRs of authors who are away or
+// just back: neither is warned or closed, and the deadline moves to their
+// return plus the grace period.
+func TestOOODefersEnforcement(t *testing.T) {
+	captureLog(t)
+	cal, err := loadOOOCalendar(writeTestFile(t, "ooo.csv", testOOOFile), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 8, 12, 0, 0, 0, time.UTC)
+	old := now.AddDate(0, 0, -60)
+	grace := 3 * 24 * time.Hour
+	for _, tc := range []struct {
+		name       string
+		now        time.Time
+		author     string
+		labels     []string
+		want       string
+		wantReason string
+		wantClose  time.Time
+	}{
+		{name: "away", now: now, author: "alice", want: actionAway, wantReason: "out of office until 2026-03-10", wantClose: time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)},
+		{name: "away and warned", now: now, author: "alice", labels: []string{"stale-warning"}, want: actionAway, wantReason: "out of office until 2026-03-10", wantClose: time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)},
+		{name: "no return date", now: now, author: "bob", labels: []string{"stale-warning"}, want: actionAway, wantReason: "out of office with no return date"},
+		{name: "back, within the grace period", now: time.Date(2026, 3, 12, 0, 0, 0, 0, time.UTC), author: "alice", labels: []string{"stale-warning"}, want: actionAway, wantReason: "back from out of office on 2026-03-11, within the grace period", wantClose: time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)},
+		{name: "back, after the grace period", now: time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC), author: "alice", labels: []string{"stale-warning"}, want: actionClose},
+		{name: "not away", now: now, author: "frank", want: actionWarn},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rules := testRules()
+			rules.OOO = cal
+			rules.OOOGrace = grace
+			d := evaluatePR(testPR(tc.author, old, tc.labels...), prSignals{}, rules, tc.now)
+			if d.Action != tc.want || d.AwayReason != tc.wantReason {
+				t.Fatalf("decided %s (%q), want %s (%q); trace:\n%s", d.Action, d.AwayReason, tc.want, tc.wantReason, strings.Join(d.Trace, "\n"))
+			}
+			if tc.want == actionAway && !d.CloseAt.Equal(tc.wantClose) {
+				t.Errorf("deadline %s, want %s", d.CloseAt, tc.wantClose)
+			}
+		})
+	}
+}
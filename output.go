@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// outputSink is the single destination for per-PR output. Each PR's log
+// records are buffered in a prOutput and written as one block, so output from
+// PRs processed concurrently never interleaves. When logging text to a
+// terminal a live progress line is kept at the bottom of the output.
+type outputSink struct {
+	mu        sync.Mutex
+	w         io.Writer
+	quiet     bool
+	progress  bool
+	total     int
+	processed int
+	warned    int
+	closed    int
+	events    *eventStream
+	repo      string
+	// failed counts the blocks with errors.
+	failed int
+}
+
+// newOutputSink creates a sink for a run over total PRs of repo, logging with
+// the default logger. The progress line, if enabled, is written to w. In
+// quiet mode only blocks containing errors are written and no progress is
+// shown. Errors are also emitted to events, if set.
+func newOutputSink(w io.Writer, quiet, progress bool, total int, events *eventStream, repo string) *outputSink {
+	return &outputSink{
+		w:        w,
+		quiet:    quiet,
+		progress: progress && !quiet && isTerminal(w),
+		total:    total,
+		events:   events,
+		repo:     repo,
+	}
+}
+
+// isTerminal reports whether w is a character device such as a TTY.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// prOutput buffers the log records for a single PR. Records are logged with
+// the repo and, for a PR, its number as the pr attribute.
+type prOutput struct {
+	number  int
+	records []slog.Record
+	errors  []string
+}
+
+// Begin starts a new output block for the PR with the given number, or 0 for
+// a block that is not about a PR.
+func (s *outputSink) Begin(number int) *prOutput {
+	return &prOutput{number: number}
+}
+
+func (p *prOutput) log(level slog.Level, msg string, args ...any) {
+	r := slog.NewRecord(time.Now(), level, msg, 0)
+	r.Add(args...)
+	p.records = append(p.records, r)
+}
+
+// Debug appends a debug record to the block.
+func (p *prOutput) Debug(msg string, args ...any) { p.log(slog.LevelDebug, msg, args...) }
+
+// Info appends an info record to the block.
+func (p *prOutput) Info(msg string, args ...any) { p.log(slog.LevelInfo, msg, args...) }
+
+// Warn appends a warning record to the block.
+func (p *prOutput) Warn(msg string, args ...any) { p.log(slog.LevelWarn, msg, args...) }
+
+// Error appends an error record to the block. Blocks containing errors are
+// written even in quiet mode, and each error is emitted as an event with its
+// err attribute, if any.
+func (p *prOutput) Error(msg string, args ...any) {
+	p.log(slog.LevelError, msg, args...)
+	for i := 0; i+1 < len(args); i += 2 {
+		if args[i] == "err" {
+			msg = fmt.Sprintf("%s: %v", msg, args[i+1])
+		}
+	}
+	p.errors = append(p.errors, msg)
+}
+
+// Finish writes the block atomically and updates the progress counters with
+// the run totals of warned and closed PRs.
+func (s *outputSink) Finish(p *prOutput, warned, closed int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.processed++
+	s.warned = warned
+	s.closed = closed
+	s.write(p)
+}
+
+// Log writes a block that is not about a PR, such as a run-wide
+// notification, without counting it as processed.
+func (s *outputSink) Log(p *prOutput) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.write(p)
+}
+
+func (s *outputSink) write(p *prOutput) {
+	if s.progress {
+		s.clearProgress()
+	}
+	if !s.quiet || len(p.errors) > 0 {
+		attrs := []slog.Attr{slog.String("repo", s.repo)}
+		if p.number != 0 {
+			attrs = append(attrs, slog.Int("pr", p.number))
+		}
+		h := slog.Default().Handler().WithAttrs(attrs)
+		for _, r := range p.records {
+			if h.Enabled(context.Background(), r.Level) {
+				h.Handle(context.Background(), r)
+			}
+		}
+	}
+	if len(p.errors) > 0 {
+		s.failed++
+	}
+	for _, msg := range p.errors {
+		s.events.Emit(botEvent{Type: eventError, Time: time.Now().UTC(), Repo: s.repo, PR: p.number, Error: msg})
+	}
+	if s.progress {
+		fmt.Fprintf(s.w, "processed %d/%d, warned %d, closed %d", s.processed, s.total, s.warned, s.closed)
+	}
+}
+
+// failures returns the number of blocks written with errors.
+func (s *outputSink) failures() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.failed
+}
+
+// Close removes the progress line once all PRs are processed.
+func (s *outputSink) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.progress && s.processed > 0 {
+		s.clearProgress()
+	}
+}
+
+func (s *outputSink) clearProgress() {
+	fmt.Fprint(s.w, "\r\033[K")
+}
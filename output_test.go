@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedBuffer is a buffer safe for concurrent writes.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// Write slowly, so writers that are not serialized by the sink
+	// interleave.
+	time.Sleep(100 * time.Microsecond)
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// captureLog sends the default logger's output to the returned buffer for
+// the rest of the test.
+func captureLog(t *testing.T) *lockedBuffer {
+	t.Helper()
+	var buf lockedBuffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+func TestOutputBlocksAreAtomic(t *testing.T) {
+	log := captureLog(t)
+	const prs, lines = 20, 10
+	sink := newOutputSink(&bytes.Buffer{}, false, false, prs, nil, "acme/api")
+	var wg sync.WaitGroup
+	for n := 1; n <= prs; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			out := sink.Begin(n)
+			for i := 0; i < lines; i++ {
+				out.Info("step", "i", i)
+				runtime.Gosched()
+			}
+			sink.Finish(out, 0, 0)
+		}(n)
+	}
+	wg.Wait()
+
+	got := strings.Split(strings.TrimSpace(log.String()), "\n")
+	if len(got) != prs*lines {
+		t.Fatalf("logged %d lines, want %d", len(got), prs*lines)
+	}
+	// Each block must be the PR's lines in order, with no other PR's lines
+	// in between.
+	seen := map[int]bool{}
+	for start := 0; start < len(got); start += lines {
+		var pr int
+		if _, err := fmt.Sscanf(got[start], "level=INFO msg=step repo=acme/api pr=%d", &pr); err != nil || seen[pr] {
+			t.Fatalf("line %d starts no new block: %q", start+1, got[start])
+		}
+		seen[pr] = true
+		for i, line := range got[start : start+lines] {
+			if want := fmt.Sprintf("level=INFO msg=step repo=acme/api pr=%d i=%d", pr, i); line != want {
+				t.Fatalf("line %d of the block of PR #%d is %q, want %q", i+1, pr, line, want)
+			}
+		}
+	}
+}
+
+func TestFailedBlockIsWrittenWhole(t *testing.T) {
+	for _, quiet := range []bool{false, true} {
+		t.Run(fmt.Sprintf("quiet=%v", quiet), func(t *testing.T) {
+			log := captureLog(t)
+			var events bytes.Buffer
+			sink := newOutputSink(&bytes.Buffer{}, quiet, false, 3, newEventStream(&events), "acme/api")
+
+			ok := sink.Begin(1)
+			ok.Info("warned the author")
+			sink.Finish(ok, 1, 0)
+
+			// A PR whose processing fails half-way: its earlier steps are
+			// written with the error, so the log shows what was applied.
+			failed := sink.Begin(2)
+			failed.Info("added the stale label")
+			failed.Error("closing the PR failed", "err", "502 Bad Gateway")
+			sink.Finish(failed, 1, 0)
+
+			// A PR whose processing is abandoned before it finishes writes
+			// nothing.
+			abandoned := sink.Begin(3)
+			abandoned.Info("removing the stale label")
+
+			want := []string{
+				`level=INFO msg="added the stale label" repo=acme/api pr=2`,
+				`level=ERROR msg="closing the PR failed" repo=acme/api pr=2 err="502 Bad Gateway"`,
+			}
+			if !quiet {
+				want = append([]string{`level=INFO msg="warned the author" repo=acme/api pr=1`}, want...)
+			}
+			if got := strings.TrimSpace(log.String()); got != strings.Join(want, "\n") {
+				t.Errorf("logged:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+			}
+			if got := sink.failures(); got != 1 {
+				t.Errorf("failures() = %d, want 1", got)
+			}
+			if sink.processed != 2 {
+				t.Errorf("processed %d PRs, want 2 without the abandoned one", sink.processed)
+			}
+			if got := strings.Count(events.String(), "\n"); got != 1 || !strings.Contains(events.String(), `"error":"closing the PR failed: 502 Bad Gateway"`) {
+				t.Errorf("events %s, want one error event", events.String())
+			}
+		})
+	}
+}
+
+func TestOutputProgressLine(t *testing.T) {
+	captureLog(t)
+	var w bytes.Buffer
+	sink := newOutputSink(&w, false, true, 2, nil, "acme/api")
+	if sink.progress {
+		t.Fatal("progress is shown on a writer that is not a terminal")
+	}
+	// As if w were a terminal.
+	sink.progress = true
+	for n := 1; n <= 2; n++ {
+		sink.Finish(sink.Begin(n), n, n-1)
+	}
+	sink.Close()
+	want := "\r\033[Kprocessed 1/2, warned 1, closed 0" + "\r\033[Kprocessed 2/2, warned 2, closed 1" + "\r\033[K"
+	if w.String() != want {
+		t.Errorf("progress output %q, want %q", w.String(), want)
+	}
+	if quiet := newOutputSink(&w, true, true, 2, nil, "acme/api"); quiet.progress {
+		t.Error("progress is shown in quiet mode")
+	}
+}
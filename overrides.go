@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// templateSlot is a built-in template that can be overridden from the
+// template directory, by channel and action.
+type templateSlot struct {
+	// Name is the name the template is rendered under.
+	Name    string
+	Channel string
+	Action  string
+	// Sample is a value of the template's data type, to check overrides
+	// against.
+	Sample interface{}
+}
+
+// templateSlots lists the overridable templates. Closures have a variant per
+// close reason, e.g. "email-closure-never-reviewed.tmpl", used instead of the
+// closure template when present.
+var templateSlots = append([]templateSlot{
+	{Name: "warning email", Channel: "email", Action: "warning", Sample: notificationData{}},
+	{Name: "failing checks warning email", Channel: "email", Action: "failing-checks-warning", Sample: notificationData{}},
+	{Name: "reminder email", Channel: "email", Action: "reminder", Sample: notificationData{}},
+	{Name: "unstale email", Channel: "email", Action: "unstale", Sample: notificationData{}},
+	{Name: "closure email", Channel: "email", Action: "closure", Sample: notificationData{}},
+	{Name: "close comment", Channel: "comment", Action: "closure", Sample: notificationData{}},
+	{Name: "close request comment", Channel: "comment", Action: "close-request", Sample: closeRequestData{}},
+	{Name: "status reply", Channel: "comment", Action: "status", Sample: statusReplyData{}},
+	{Name: "question nudge", Channel: "comment", Action: "nudge", Sample: questionNudgeData{}},
+	{Name: "duplicate comment", Channel: "comment", Action: "duplicate", Sample: duplicateCommentData{}},
+	{Name: "security escalation email", Channel: "email", Action: "escalation", Sample: securityEscalationData{}},
+}, closureVariantSlots()...)
+
+// closureVariantSlots returns the slots of the closure template variants.
+func closureVariantSlots() []templateSlot {
+	var slots []templateSlot
+	for _, reason := range closeReasons {
+		slots = append(slots,
+			templateSlot{Name: closureVariant("closure email", reason), Channel: "email", Action: "closure-" + reason, Sample: notificationData{}},
+			templateSlot{Name: closureVariant("close comment", reason), Channel: "comment", Action: "closure-" + reason, Sample: notificationData{}})
+	}
+	return slots
+}
+
+// loadTemplateOverrides reads template overrides from dir. For each slot the
+// file "<channel>-<action>.tmpl" is used if present, else the shared
+// "<action>.tmpl", else the built-in template. Every override is parsed and
+// rendered against sample data so mistakes are caught at startup. The result
+// maps template names to override text.
+func loadTemplateOverrides(dir string, r *templateRenderer) (map[string]string, error) {
+	return readTemplateOverrides(r, func(file string) (string, []byte, error) {
+		path := filepath.Join(dir, file)
+		data, err := os.ReadFile(path)
+		return path, data, err
+	})
+}
+
+// readTemplateOverrides reads the overrides of the template slots through
+// read, which returns where a template file is read from and its content, or
+// an error wrapping os.ErrNotExist if there is no such file.
+func readTemplateOverrides(r *templateRenderer, read func(file string) (path string, data []byte, err error)) (map[string]string, error) {
+	overrides := map[string]string{}
+	for _, slot := range templateSlots {
+		for _, file := range []string{slot.Channel + "-" + slot.Action + ".tmpl", slot.Action + ".tmpl"} {
+			path, data, err := read(file)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read template %s: %v", path, err)
+			}
+			tmpl, err := template.New(slot.Name).Funcs(r.funcs).Parse(string(data))
+			if err != nil {
+				return nil, fmt.Errorf("template %s for the %s: %v", path, slot.Name, err)
+			}
+			if err := tmpl.Execute(io.Discard, slot.Sample); err != nil {
+				return nil, fmt.Errorf("template %s for the %s: %v", path, slot.Name, err)
+			}
+			overrides[slot.Name] = string(data)
+			slog.Info("using template override", "file", path, "template", slot.Name)
+			break
+		}
+	}
+	return overrides, nil
+}
+
+// checkTemplateDir reports files in dir that match no template slot, which
+// are most likely misspelt.
+func checkTemplateDir(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template directory: %v", err)
+	}
+	known := map[string]bool{}
+	for _, slot := range templateSlots {
+		known[slot.Channel+"-"+slot.Action+".tmpl"] = true
+		known[slot.Action+".tmpl"] = true
+	}
+	var unknown []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".tmpl") && !known[e.Name()] {
+			unknown = append(unknown, e.Name())
+		}
+	}
+	return unknown, nil
+}
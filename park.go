@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// What happens to a PR once its warning period has passed.
+const (
+	// staleActionClose closes the PR.
+	staleActionClose = "close"
+	// staleActionMilestone parks the PR in a milestone and leaves it open,
+	// for audit workflows where only humans change PR state.
+	staleActionMilestone = "milestone"
+)
+
+// isParked reports whether pr is in the milestone stale PRs are parked in.
+func isParked(pr *github.PullRequest, milestone string) bool {
+	return milestone != "" && strings.EqualFold(pr.GetMilestone().GetTitle(), milestone)
+}
+
+// findOrCreateMilestone returns the number of the milestone titled title,
+// open or closed, creating it if the repository has none.
+func findOrCreateMilestone(client *github.Client, owner, repo, title string) (int, error) {
+	ctx := context.Background()
+	opt := &github.MilestoneListOptions{State: "all", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		milestones, resp, err := client.Issues.ListMilestones(ctx, owner, repo, opt)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list milestones: %v", err)
+		}
+		for _, m := range milestones {
+			if strings.EqualFold(m.GetTitle(), title) {
+				return m.GetNumber(), nil
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	m, _, err := client.Issues.CreateMilestone(ctx, owner, repo, &github.Milestone{
+		Title:       github.Ptr(title),
+		Description: github.Ptr("Pull requests parked by the stale PR bot. Move a pull request out of this milestone to have it evaluated again."),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create milestone %q: %v", title, err)
+	}
+	return m.GetNumber(), nil
+}
+
+// parkedSuffix qualifies "Closed PR #n" log lines in milestone mode.
+func parkedSuffix(milestone string) string {
+	if milestone == "" {
+		return ""
+	}
+	return fmt.Sprintf(" by parking it in milestone '%s'", milestone)
+}
+
+// closeOrParkPR closes a PR or, when milestone is non-zero, parks it there.
+func closeOrParkPR(client *github.Client, owner, repo string, number, milestone int) error {
+	if milestone == 0 {
+		return closePR(client, owner, repo, number)
+	}
+	return parkPR(client, owner, repo, number, milestone)
+}
+
+// parkPR moves a PR into the milestone and applies the 'closed-stale' label,
+// leaving it open.
+func parkPR(client *github.Client, owner, repo string, number, milestone int) error {
+	_, _, err := client.Issues.Edit(context.Background(), owner, repo, number, &github.IssueRequest{Milestone: &milestone})
+	if err != nil {
+		return fmt.Errorf("failed to set milestone: %v", err)
+	}
+	if err := addLabel(client, owner, repo, number, "closed-stale"); err != nil {
+		return fmt.Errorf("failed to add 'closed-stale' label: %v", err)
+	}
+	return nil
+}
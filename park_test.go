@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// TestParkCycle parks a stale PR in the milestone instead of closing it,
+// leaves it alone while it is parked, and evaluates it again once a human
+// has moved it out of the milestone.
+func TestParkCycle(t *testing.T) {
+	gh := &fakeGitHub{get: map[string]string{
+		"/repos/acme/api/milestones": `[{"number":2,"title":"v2.0"},{"number":3,"title":"Parked – stale"}]`,
+	}}
+	cfg := &config{Rules: testRules(), DisplayLocation: time.UTC, NotifyVia: notifyComment}
+	cfg.Rules.ParkedMilestone = "Parked – stale"
+	s := newTestRepoScanner(t, gh, cfg)
+
+	pr := testPR("alice", time.Now().AddDate(0, 0, -45), "stale-warning")
+	if d := s.actOn(pr); d.Action != actionClose {
+		t.Fatalf("a stale warned PR decided %s, want close", d.Action)
+	}
+	writes := gh.written()
+	for _, want := range []string{
+		`PATCH /repos/acme/api/issues/42 {"milestone":3}`,
+		`POST /repos/acme/api/issues/42/labels ["closed-stale"]`,
+	} {
+		if !strings.Contains(strings.Join(writes, "\n"), want) {
+			t.Errorf("writes %q, want %q", writes, want)
+		}
+	}
+	notified := false
+	for _, w := range writes {
+		if strings.Contains(w, `"state"`) {
+			t.Errorf("parking the PR changed its state: %q", w)
+		}
+		if strings.Contains(w, "/comments") {
+			notified = true
+			if !strings.Contains(w, "Parked – stale") {
+				t.Errorf("the notification %q does not name the milestone", w)
+			}
+		}
+	}
+	if !notified {
+		t.Errorf("writes %q, want the author notified", writes)
+	}
+	if len(s.summary.Closed) != 1 {
+		t.Errorf("%d PRs counted as closed, want the parked one", len(s.summary.Closed))
+	}
+
+	// While parked, the PR is left alone.
+	gh.writes = nil
+	pr.Milestone = &github.Milestone{Number: github.Ptr(3), Title: github.Ptr("Parked – stale")}
+	pr.Labels = append(pr.Labels, &github.Label{Name: github.Ptr("closed-stale")})
+	if d := s.actOn(pr); d.Action != actionParked {
+		t.Errorf("a parked PR decided %s, want parked", d.Action)
+	}
+	if got := gh.written(); len(got) != 0 {
+		t.Errorf("acting on a parked PR wrote %q", got)
+	}
+
+	// A human moves it out of the milestone, which updates it: it is active
+	// again and loses both its labels.
+	pr.Milestone = nil
+	pr.UpdatedAt = &github.Timestamp{Time: time.Now().Add(-time.Hour)}
+	if d := s.actOn(pr); d.Action != actionActive {
+		t.Errorf("an unparked PR decided %s, want active", d.Action)
+	}
+	want := []string{
+		"DELETE /repos/acme/api/issues/42/labels/closed-stale",
+		"DELETE /repos/acme/api/issues/42/labels/stale-warning",
+	}
+	if got := gh.written(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("unparking wrote %q, want %q", got, want)
+	}
+}
+
+// TestFindOrCreateMilestone creates the milestone only when the repository
+// has none with its title, open or closed.
+func TestFindOrCreateMilestone(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		milestones string
+		want       int
+		wantCreate bool
+	}{
+		{name: "open", milestones: `[{"number":3,"title":"Parked – stale","state":"open"}]`, want: 3},
+		{name: "closed, other case", milestones: `[{"number":4,"title":"parked – STALE","state":"closed"}]`, want: 4},
+		{name: "missing", milestones: `[{"number":2,"title":"v2.0"}]`, wantCreate: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			gh := &fakeGitHub{get: map[string]string{"/repos/acme/api/milestones": tc.milestones}}
+			client, _ := newTestGitHubClient(t, gh)
+			got, err := findOrCreateMilestone(client, "acme", "api", "Parked – stale")
+			if err != nil {
+				t.Fatal(err)
+			}
+			writes := gh.written()
+			if tc.wantCreate {
+				if len(writes) != 1 || !strings.HasPrefix(writes[0], `POST /repos/acme/api/milestones {"title":"Parked – stale"`) {
+					t.Errorf("writes %q, want the milestone created", writes)
+				}
+				return
+			}
+			if got != tc.want || len(writes) != 0 {
+				t.Errorf("findOrCreateMilestone returned %d and wrote %q, want %d and no writes", got, writes, tc.want)
+			}
+		})
+	}
+}
+
+// TestParkDryRun checks that a dry run neither parks a PR nor creates the
+// milestone.
+func TestParkDryRun(t *testing.T) {
+	gh := &fakeGitHub{}
+	cfg := &config{Rules: testRules(), DisplayLocation: time.UTC, NotifyVia: notifyComment}
+	cfg.Rules.ParkedMilestone = "Parked – stale"
+	s := newTestRepoScanner(t, gh, cfg, "--dry-run")
+	if d := s.actOn(testPR("alice", time.Now().AddDate(0, 0, -45), "stale-warning")); d.Action != actionClose {
+		t.Fatalf("a stale warned PR decided %s, want close", d.Action)
+	}
+	if got := gh.written(); len(got) != 0 {
+		t.Errorf("a dry run wrote %q", got)
+	}
+}
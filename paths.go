@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// protectedPath is a compiled --protected-paths glob.
+type protectedPath struct {
+	Pattern string
+	re      *regexp.Regexp
+}
+
+// parseProtectedPaths compiles a comma-separated list of path globs. "*" and
+// "?" match within a path segment, "**" matches across segments and a trailing
+// "/" matches everything below a directory. As in .gitignore, a pattern with
+// no "/" other than a trailing one matches at any depth, so "*.pem" and
+// "crypto/" match "certs/key.pem" and "vendor/crypto/aes.go".
+func parseProtectedPaths(s string) ([]protectedPath, error) {
+	var paths []protectedPath
+	for _, pattern := range strings.Split(s, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(globToRegexp(pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid protected path %q: %v", pattern, err)
+		}
+		paths = append(paths, protectedPath{Pattern: pattern, re: re})
+	}
+	return paths, nil
+}
+
+func globToRegexp(pattern string) string {
+	p := strings.TrimPrefix(pattern, "/")
+	if strings.HasSuffix(p, "/") {
+		p += "**"
+	}
+	var b strings.Builder
+	b.WriteString("^")
+	if !strings.Contains(strings.TrimSuffix(pattern, "/"), "/") {
+		b.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; c {
+		case '*':
+			if i+1 < len(p) && p[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+				// "**/" also matches zero directories.
+				if i+1 < len(p) && p[i+1] == '/' {
+					b.WriteString("/?")
+					i++
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// matchProtectedPath returns the first pattern matching file, if any.
+func matchProtectedPath(paths []protectedPath, file string) (string, bool) {
+	file = path.Clean(strings.TrimPrefix(file, "/"))
+	for _, p := range paths {
+		if p.re.MatchString(file) {
+			return p.Pattern, true
+		}
+	}
+	return "", false
+}
+
+// protectedPathMatch lists the files changed by the PR and returns the first
+// one, old or new name for renames, matching a protected path together with
+// the matching pattern. File lists are cached in the repo state per head SHA.
+func protectedPathMatch(client *github.Client, owner, repo string, pr *github.PullRequest, paths []protectedPath, rs *repoState) (string, string, error) {
+	if len(paths) == 0 {
+		return "", "", nil
+	}
+	files, err := changedFiles(client, owner, repo, pr, rs)
+	if err != nil {
+		return "", "", err
+	}
+	for _, f := range files {
+		if pattern, ok := matchProtectedPath(paths, f); ok {
+			return f, pattern, nil
+		}
+	}
+	return "", "", nil
+}
+
+// cachedProtectedPathMatch is like protectedPathMatch but only consults the
+// file list cache, never the API.
+func cachedProtectedPathMatch(pr *github.PullRequest, paths []protectedPath, rs *repoState) bool {
+	cached, ok := rs.Files[pr.GetNumber()]
+	if !ok || cached.HeadSHA != pr.GetHead().GetSHA() {
+		return false
+	}
+	for _, f := range cached.Files {
+		if _, ok := matchProtectedPath(paths, f); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// changedFiles returns the paths touched by the PR, including the previous
+// names of renamed files.
+func changedFiles(client *github.Client, owner, repo string, pr *github.PullRequest, rs *repoState) ([]string, error) {
+	sha := pr.GetHead().GetSHA()
+	if cached, ok := rs.Files[pr.GetNumber()]; ok && sha != "" && cached.HeadSHA == sha {
+		return cached.Files, nil
+	}
+
+	ctx := context.Background()
+	opts := &github.ListOptions{PerPage: 100}
+	var files []string
+	for {
+		page, resp, err := client.PullRequests.ListFiles(ctx, owner, repo, pr.GetNumber(), opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files of PR #%d: %v", pr.GetNumber(), err)
+		}
+		for _, f := range page {
+			files = append(files, f.GetFilename())
+			if f.GetPreviousFilename() != "" {
+				files = append(files, f.GetPreviousFilename())
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	if sha != "" {
+		rs.Files[pr.GetNumber()] = fileListCache{HeadSHA: sha, Files: files}
+	}
+	return files, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+func TestMatchProtectedPath(t *testing.T) {
+	for _, tc := range []struct {
+		pattern string
+		file    string
+		want    bool
+	}{
+		// A directory pattern matches everything below the directory, at
+		// any depth unless it contains another "/".
+		{"crypto/", "crypto/aes.go", true},
+		{"crypto/", "crypto/internal/gcm/gcm.go", true},
+		{"crypto/", "vendor/crypto/aes.go", true},
+		{"crypto/", "cryptography/aes.go", false},
+		{"crypto/", "crypto", false},
+		{"/auth/", "auth/login.go", true},
+		{"/auth/", "services/auth/login.go", false},
+		{"services/auth/", "services/auth/token/jwt.go", true},
+		{"services/auth/", "legacy/services/auth/jwt.go", false},
+		// A name without "/" matches at any depth.
+		{"*.pem", "key.pem", true},
+		{"*.pem", "certs/prod/key.pem", true},
+		{"*.pem", "key.pem.bak", false},
+		{"Dockerfile", "build/Dockerfile", true},
+		{"Dockerfile", "build/Dockerfile.dev", false},
+		// "*" and "?" stay within a segment.
+		{"docs/*.md", "docs/index.md", true},
+		{"docs/*.md", "docs/api/index.md", false},
+		{"config/v?.yaml", "config/v2.yaml", true},
+		{"config/v?.yaml", "config/v10.yaml", false},
+		// "**" crosses segments, and "**/" also matches none.
+		{"src/**/*.key", "src/a.key", true},
+		{"src/**/*.key", "src/x/y/a.key", true},
+		{"src/**/*.key", "lib/src/a.key", false},
+		{"**/secrets.json", "secrets.json", true},
+		{"**/secrets.json", "deploy/prod/secrets.json", true},
+		// Characters special in regular expressions are literal.
+		{"ops/(prod)+.tf", "ops/(prod)+.tf", true},
+		{"ops/(prod)+.tf", "ops/prodprod.tf", false},
+		// Changed file paths are cleaned before matching.
+		{"crypto/", "/crypto/aes.go", true},
+		{"crypto/", "docs/../crypto/aes.go", true},
+	} {
+		paths, err := parseProtectedPaths(tc.pattern)
+		if err != nil {
+			t.Fatalf("parseProtectedPaths(%q): %v", tc.pattern, err)
+		}
+		if _, got := matchProtectedPath(paths, tc.file); got != tc.want {
+			t.Errorf("%q matches %q: %v, want %v", tc.pattern, tc.file, got, tc.want)
+		}
+	}
+}
+
+func TestParseProtectedPaths(t *testing.T) {
+	paths, err := parseProtectedPaths(" crypto/ ,, *.pem,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var patterns []string
+	for _, p := range paths {
+		patterns = append(patterns, p.Pattern)
+	}
+	if got := strings.Join(patterns, " "); got != "crypto/ *.pem" {
+		t.Errorf("parsed %q, want crypto/ and *.pem", got)
+	}
+	// The first matching pattern is reported.
+	if pattern, ok := matchProtectedPath(paths, "crypto/key.pem"); !ok || pattern != "crypto/" {
+		t.Errorf("crypto/key.pem matched %q, want crypto/", pattern)
+	}
+}
+
+func TestProtectedPathMatchRenamedFiles(t *testing.T) {
+	var requests int
+	client, _ := newTestGitHubClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/acme/api/pulls/7/files" {
+			http.NotFound(w, r)
+			return
+		}
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		// Two pages: the rename out of the protected directory is on the
+		// second.
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", fmt.Sprintf(`<%s?page=2>; rel="next"`, "http://"+r.Host+r.URL.Path))
+			w.Write([]byte(`[{"filename": "README.md", "status": "modified"}]`))
+			return
+		}
+		w.Write([]byte(`[{"filename": "lib/aes.go", "previous_filename": "crypto/aes.go", "status": "renamed"}]`))
+	}))
+	paths, err := parseProtectedPaths("crypto/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr := testPR("alice", time.Now())
+	pr.Number = github.Ptr(7)
+	pr.Head = &github.PullRequestBranch{SHA: github.Ptr("abc123")}
+	rs := &repoState{}
+	rs.initMaps()
+
+	file, pattern, err := protectedPathMatch(client, "acme", "api", pr, paths, rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file != "crypto/aes.go" || pattern != "crypto/" {
+		t.Errorf("matched %q with %q, want the old name crypto/aes.go with crypto/", file, pattern)
+	}
+	if requests != 2 {
+		t.Errorf("listed files in %d requests, want 2 pages", requests)
+	}
+
+	// The file list is cached for the head commit.
+	if !cachedProtectedPathMatch(pr, paths, rs) {
+		t.Error("the cached file list does not match")
+	}
+	if _, _, err := protectedPathMatch(client, "acme", "api", pr, paths, rs); err != nil || requests != 2 {
+		t.Errorf("a second lookup made %d requests in all (err %v), want the cache used", requests, err)
+	}
+	pr.Head.SHA = github.Ptr("def456")
+	if cachedProtectedPathMatch(pr, paths, rs) {
+		t.Error("the cached file list matches after a new push")
+	}
+	if _, _, err := protectedPathMatch(client, "acme", "api", pr, paths, rs); err != nil || requests != 4 {
+		t.Errorf("a lookup after a new push made %d requests in all (err %v), want the files listed again", requests, err)
+	}
+}
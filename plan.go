@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// planVersion is the version of the plan file format.
+const planVersion = 1
+
+// actionPlan is the ordered list of actions a read-only run would have taken,
+// written with --plan-file and applied later with --execute-plan.
+type actionPlan struct {
+	Version   int             `json:"version"`
+	Repo      string          `json:"repo"`
+	CreatedAt time.Time       `json:"created_at"`
+	Actions   []plannedAction `json:"actions"`
+}
+
+// plannedAction is the action planned for one PR together with the state the
+// PR was in when it was planned.
+type plannedAction struct {
+	Number  int      `json:"number"`
+	Action  string   `json:"action"`
+	HeadSHA string   `json:"head_sha"`
+	Labels  []string `json:"labels"`
+}
+
+// add appends the action decided for pr to the plan.
+func (p *actionPlan) add(pr *github.PullRequest, action string) {
+	p.Actions = append(p.Actions, plannedAction{
+		Number:  pr.GetNumber(),
+		Action:  action,
+		HeadSHA: pr.GetHead().GetSHA(),
+		Labels:  labelSet(pr),
+	})
+}
+
+// numbers returns the planned PR numbers in plan order.
+func (p *actionPlan) numbers() []int {
+	numbers := make([]int, 0, len(p.Actions))
+	for _, a := range p.Actions {
+		numbers = append(numbers, a.Number)
+	}
+	return numbers
+}
+
+// action returns the action planned for a PR.
+func (p *actionPlan) action(number int) (plannedAction, bool) {
+	for _, a := range p.Actions {
+		if a.Number == number {
+			return a, true
+		}
+	}
+	return plannedAction{}, false
+}
+
+// drift describes how pr differs from the state it was planned in, or returns
+// "" if the planned action is still valid.
+func (a plannedAction) drift(pr *github.PullRequest) string {
+	if pr.GetState() != "open" {
+		return "no longer open"
+	}
+	if sha := pr.GetHead().GetSHA(); sha != a.HeadSHA {
+		return fmt.Sprintf("head changed from %.7s to %.7s", a.HeadSHA, sha)
+	}
+	if labels := labelSet(pr); strings.Join(labels, ",") != strings.Join(a.Labels, ",") {
+		return fmt.Sprintf("labels changed from [%s] to [%s]", strings.Join(a.Labels, ", "), strings.Join(labels, ", "))
+	}
+	return ""
+}
+
+// labelSet returns the sorted, lower-cased label names of a PR.
+func labelSet(pr *github.PullRequest) []string {
+	labels := []string{}
+	for _, l := range pr.Labels {
+		labels = append(labels, strings.ToLower(l.GetName()))
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// writePlan writes the plan to path.
+func writePlan(path string, p *actionPlan) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode plan: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write plan file: %v", err)
+	}
+	return nil
+}
+
+// loadPlan reads the plan at path and checks it was made for repo.
+func loadPlan(path, repo string) (*actionPlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan file: %v", err)
+	}
+	p := &actionPlan{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("failed to parse plan file %s: %v", path, err)
+	}
+	if p.Version != planVersion {
+		return nil, fmt.Errorf("plan file %s has unsupported version %d", path, p.Version)
+	}
+	if p.Repo != repo {
+		return nil, fmt.Errorf("plan file %s was made for %s, not %s", path, p.Repo, repo)
+	}
+	return p, nil
+}
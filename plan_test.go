@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// testPlannedPR returns open PR #number at head sha with labels.
+func testPlannedPR(number int, sha string, labels ...string) *github.PullRequest {
+	pr := testPR("alice", time.Now().AddDate(0, 0, -45), labels...)
+	pr.Number = github.Ptr(number)
+	pr.Head = &github.PullRequestBranch{SHA: github.Ptr(sha)}
+	return pr
+}
+
+func TestPlannedActionDrift(t *testing.T) {
+	plan := &actionPlan{}
+	plan.add(testPlannedPR(7, "0123456789abcdef", "stale-warning", "Bug"), actionClose)
+	planned, ok := plan.action(7)
+	if !ok || planned.Action != actionClose || fmt.Sprint(planned.Labels) != "[bug stale-warning]" {
+		t.Fatalf("planned %+v, want closing #7 with its labels", planned)
+	}
+	for _, tc := range []struct {
+		name string
+		pr   func() *github.PullRequest
+		want string
+	}{
+		{"unchanged", func() *github.PullRequest {
+			// Label order and case do not matter.
+			return testPlannedPR(7, "0123456789abcdef", "BUG", "stale-warning")
+		}, ""},
+		{"closed", func() *github.PullRequest {
+			pr := testPlannedPR(7, "0123456789abcdef", "stale-warning", "bug")
+			pr.State = github.Ptr("closed")
+			return pr
+		}, "no longer open"},
+		{"pushed", func() *github.PullRequest {
+			return testPlannedPR(7, "fedcba9876543210", "stale-warning", "bug")
+		}, "head changed from 0123456 to fedcba9"},
+		{"label removed", func() *github.PullRequest {
+			return testPlannedPR(7, "0123456789abcdef", "bug")
+		}, "labels changed from [bug, stale-warning] to [bug]"},
+		{"label added", func() *github.PullRequest {
+			return testPlannedPR(7, "0123456789abcdef", "bug", "pinned", "stale-warning")
+		}, "labels changed from [bug, stale-warning] to [bug, pinned, stale-warning]"},
+	} {
+		if got := planned.drift(tc.pr()); got != tc.want {
+			t.Errorf("%s: drift = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestWriteAndLoadPlan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan.json")
+	plan := &actionPlan{Version: planVersion, Repo: "acme/api", CreatedAt: time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)}
+	plan.add(testPlannedPR(7, "abc"), actionWarn)
+	plan.add(testPlannedPR(3, "def", "stale-warning"), actionClose)
+	if err := writePlan(path, plan); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadPlan(path, "acme/api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(loaded.numbers()) != "[7 3]" || fmt.Sprintf("%+v", loaded) != fmt.Sprintf("%+v", plan) {
+		t.Errorf("loaded plan %+v, want %+v", loaded, plan)
+	}
+
+	for _, tc := range []struct {
+		name, content, repo, wantErr string
+	}{
+		{"other repo", "", "acme/web", "was made for acme/api, not acme/web"},
+		{"other version", `{"version":2,"repo":"acme/api"}`, "acme/api", "unsupported version 2"},
+		{"not json", `actions: []`, "acme/api", "failed to parse plan file"},
+	} {
+		p := path
+		if tc.content != "" {
+			p = writeTestFile(t, "plan-"+strings.ReplaceAll(tc.name, " ", "-")+".json", tc.content)
+		}
+		if _, err := loadPlan(p, tc.repo); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: loadPlan returned %v, want an error containing %q", tc.name, err, tc.wantErr)
+		}
+	}
+	if _, err := loadPlan(filepath.Join(t.TempDir(), "missing.json"), "acme/api"); err == nil || !strings.Contains(err.Error(), "failed to read plan file") {
+		t.Errorf("loadPlan of a missing file returned %v", err)
+	}
+}
+
+// TestExecutePlanSkipsDrift executes a plan against PRs that changed since
+// it was made: only the actions of PRs still in their planned state are
+// kept, and the others are reported.
+func TestExecutePlanSkipsDrift(t *testing.T) {
+	gh := &fakeGitHub{get: map[string]string{
+		"/repos/acme/api/pulls/1": `{"number":1,"state":"open","head":{"sha":"aaa"},"labels":[{"name":"stale-warning"}]}`,
+		"/repos/acme/api/pulls/2": `{"number":2,"state":"open","head":{"sha":"bbb2"},"labels":[]}`,
+		"/repos/acme/api/pulls/3": `{"number":3,"state":"closed","head":{"sha":"ccc"},"labels":[]}`,
+		"/repos/acme/api/pulls/4": `{"number":4,"state":"open","head":{"sha":"ddd"},"labels":[{"name":"pinned"}]}`,
+	}}
+	s := newTestRepoScanner(t, gh, nil, "--execute-plan", "plan.json")
+	s.plan = &actionPlan{Version: planVersion, Repo: "acme/api", Actions: []plannedAction{
+		{Number: 1, Action: actionClose, HeadSHA: "aaa", Labels: []string{"stale-warning"}},
+		{Number: 2, Action: actionWarn, HeadSHA: "bbb", Labels: []string{}},
+		{Number: 3, Action: actionWarn, HeadSHA: "ccc", Labels: []string{}},
+		{Number: 4, Action: actionWarn, HeadSHA: "ddd", Labels: []string{}},
+	}}
+	if err := s.listPRs(); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.openPRs) != 1 || s.openPRs[0].GetNumber() != 1 || s.fullScan {
+		t.Errorf("executing the plan evaluates %d PRs (full scan %v), want only #1", len(s.openPRs), s.fullScan)
+	}
+	want := []string{
+		"PR #2: warn skipped, head changed from bbb to bbb2",
+		"PR #3: warn skipped, no longer open",
+		"PR #4: warn skipped, labels changed from [] to [pinned]",
+	}
+	if fmt.Sprint(s.summary.PlanDrift) != fmt.Sprint(want) {
+		t.Errorf("reported drift %q, want %q", s.summary.PlanDrift, want)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// Author policies that can override the default warn-then-close lifecycle.
+const (
+	policySkip             = "skip"
+	policyWarnThenClose    = "warn-then-close"
+	policyCloseImmediately = "close-immediately"
+)
+
+// botTypePattern matches any PR opened by a GitHub user of type "Bot".
+const botTypePattern = "type:Bot"
+
+// authorPolicy maps an author login pattern to the policy applied to their PRs.
+// A pattern is an exact login, a glob such as "release-*" or "*[bot]", or
+// "type:Bot" to match every bot account.
+type authorPolicy struct {
+	Pattern string
+	Policy  string
+}
+
+// parseAuthorPolicies parses a comma-separated list of pattern=policy pairs,
+// e.g. "release-please[bot]=close-immediately,type:Bot=skip".
+func parseAuthorPolicies(s string) ([]authorPolicy, error) {
+	var policies []authorPolicy
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		pattern, policy, ok := strings.Cut(entry, "=")
+		pattern = strings.TrimSpace(pattern)
+		policy = strings.TrimSpace(policy)
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("invalid author policy %q: expected pattern=policy", entry)
+		}
+		switch policy {
+		case policySkip, policyWarnThenClose, policyCloseImmediately:
+		default:
+			return nil, fmt.Errorf("invalid policy %q for pattern %q: must be %s, %s or %s",
+				policy, pattern, policySkip, policyWarnThenClose, policyCloseImmediately)
+		}
+		if _, err := path.Match(loginGlob(pattern), ""); err != nil {
+			return nil, fmt.Errorf("invalid author pattern %q: %v", pattern, err)
+		}
+		policies = append(policies, authorPolicy{Pattern: pattern, Policy: policy})
+	}
+	return policies, nil
+}
+
+// matchAuthorPolicy returns the override that applies to the PR author, if any.
+// Exact login matches take precedence over globs, which take precedence over
+// the "type:Bot" pattern; among equally specific patterns the first one listed
+// wins.
+func matchAuthorPolicy(policies []authorPolicy, user *github.User) (authorPolicy, bool) {
+	login := strings.ToLower(user.GetLogin())
+
+	for _, p := range policies {
+		if strings.ToLower(p.Pattern) == login {
+			return p, true
+		}
+	}
+	for _, p := range policies {
+		if p.Pattern == botTypePattern || !strings.ContainsAny(p.Pattern, "*?") {
+			continue
+		}
+		if matchLoginGlob(p.Pattern, login) {
+			return p, true
+		}
+	}
+	for _, p := range policies {
+		if p.Pattern == botTypePattern && user.GetType() == "Bot" {
+			return p, true
+		}
+	}
+	return authorPolicy{}, false
+}
+
+// parseExemptAuthors parses a comma-separated list of exempt author logins
+// and globs.
+func parseExemptAuthors(s string) ([]string, error) {
+	authors := splitList(s)
+	for _, a := range authors {
+		if _, err := path.Match(loginGlob(a), ""); err != nil {
+			return nil, fmt.Errorf("invalid author pattern %q: %v", a, err)
+		}
+	}
+	return authors, nil
+}
+
+// matchExemptAuthor returns the pattern exempting login, or "" if none does.
+// Patterns match case-insensitively, and a pattern without the "[bot]" suffix
+// GitHub Apps get also matches the App, so "renovate" covers "renovate[bot]".
+func matchExemptAuthor(patterns []string, login string) string {
+	bare := strings.TrimSuffix(strings.ToLower(login), "[bot]")
+	for _, p := range patterns {
+		if matchLoginGlob(p, login) || matchLoginGlob(p, bare) {
+			return p
+		}
+	}
+	return ""
+}
+
+// matchLoginGlob reports whether login matches a case-insensitive glob pattern
+// supporting the * and ? wildcards. Square brackets are matched literally so
+// GitHub App logins such as "dependabot[bot]" can be written as-is.
+func matchLoginGlob(pattern, login string) bool {
+	ok, _ := path.Match(loginGlob(pattern), strings.ToLower(login))
+	return ok
+}
+
+func loginGlob(pattern string) string {
+	pattern = strings.ToLower(pattern)
+	pattern = strings.ReplaceAll(pattern, "[", `\[`)
+	return strings.ReplaceAll(pattern, "]", `\]`)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMatchExemptAuthor(t *testing.T) {
+	patterns := []string{"release-bot", "*-BOT", "renovate", "dependabot[bot]", "team-?"}
+	for login, want := range map[string]string{
+		"release-bot":     "release-bot",
+		"Release-Bot":     "release-bot",
+		"deploy-bot":      "*-BOT",
+		"renovate":        "renovate",
+		"renovate[bot]":   "renovate",
+		"dependabot[bot]": "dependabot[bot]",
+		"team-a":          "team-?",
+		"team-ab":         "",
+		"dependabot":      "",
+		"robot":           "",
+		"alice":           "",
+		"":                "",
+	} {
+		if got := matchExemptAuthor(patterns, login); got != want {
+			t.Errorf("matchExemptAuthor(%s) = %q, want %q", login, got, want)
+		}
+	}
+	if got := matchExemptAuthor(nil, "release-bot"); got != "" {
+		t.Errorf("no patterns exempt %q", got)
+	}
+}
+
+func TestParseExemptAuthors(t *testing.T) {
+	got, err := parseExemptAuthors(" release-bot, *-bot ,,dependabot[bot]")
+	if want := []string{"release-bot", "*-bot", "dependabot[bot]"}; err != nil || !slices.Equal(got, want) {
+		t.Errorf("parseExemptAuthors = %q, %v; want %q", got, err, want)
+	}
+	if _, err := parseExemptAuthors(`bot\`); err == nil || !strings.Contains(err.Error(), "invalid author pattern") {
+		t.Errorf("parseExemptAuthors(bot\\) returned %v, want an invalid pattern", err)
+	}
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/google/go-github/v68/github"
+	"gopkg.in/yaml.v3"
+)
+
+// probotConfigPath is where probot/stale reads its configuration from, and
+// where the bot looks for one when no --config is given.
+const probotConfigPath = ".github/stale.yml"
+
+// probotDays is a number of days in a probot/stale configuration, or false
+// to skip the step.
+type probotDays struct {
+	Days     float64
+	Disabled bool
+}
+
+func (d *probotDays) UnmarshalYAML(n *yaml.Node) error {
+	if n.ShortTag() == "!!bool" {
+		if n.Value != "false" {
+			return fmt.Errorf("line %d: must be a number of days or false", n.Line)
+		}
+		d.Disabled = true
+		return nil
+	}
+	if err := n.Decode(&d.Days); err != nil {
+		return err
+	}
+	if d.Days < 0 {
+		return fmt.Errorf("line %d: must not be negative", n.Line)
+	}
+	return nil
+}
+
+// probotText is a comment in a probot/stale configuration, or false for
+// none.
+type probotText struct {
+	Text     string
+	Disabled bool
+}
+
+func (t *probotText) UnmarshalYAML(n *yaml.Node) error {
+	if n.ShortTag() == "!!bool" {
+		if n.Value != "false" {
+			return fmt.Errorf("line %d: must be a comment or false", n.Line)
+		}
+		t.Disabled = true
+		return nil
+	}
+	return n.Decode(&t.Text)
+}
+
+// probotList is a list of labels, which probot/stale also accepts as a
+// single label.
+type probotList []string
+
+func (l *probotList) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		*l = probotList{n.Value}
+		return nil
+	}
+	return n.Decode((*[]string)(l))
+}
+
+// probotSettings are the settings of a probot/stale configuration, at its
+// top level or in its pulls and issues sections. Unset settings are nil.
+type probotSettings struct {
+	DaysUntilStale   *probotDays `yaml:"daysUntilStale"`
+	DaysUntilClose   *probotDays `yaml:"daysUntilClose"`
+	OnlyLabels       *probotList `yaml:"onlyLabels"`
+	ExemptLabels     *probotList `yaml:"exemptLabels"`
+	ExemptProjects   *bool       `yaml:"exemptProjects"`
+	ExemptMilestones *bool       `yaml:"exemptMilestones"`
+	ExemptAssignees  *bool       `yaml:"exemptAssignees"`
+	StaleLabel       *string     `yaml:"staleLabel"`
+	MarkComment      *probotText `yaml:"markComment"`
+	UnmarkComment    *probotText `yaml:"unmarkComment"`
+	CloseComment     *probotText `yaml:"closeComment"`
+	LimitPerRun      *int        `yaml:"limitPerRun"`
+	Perform          *bool       `yaml:"perform"`
+}
+
+// probotConfig is a probot/stale configuration file. Its pulls and issues
+// sections override the top-level settings for each kind, and only limits
+// it to one kind.
+type probotConfig struct {
+	probotSettings `yaml:",inline"`
+	Only           string          `yaml:"only"`
+	Pulls          *probotSettings `yaml:"pulls"`
+	Issues         *probotSettings `yaml:"issues"`
+	Extends        string          `yaml:"_extends"`
+}
+
+// probotDefaults are probot/stale's defaults for the settings the bot
+// converts, which differ from the bot's own.
+var probotDefaults = probotSettings{
+	DaysUntilStale: &probotDays{Days: 60},
+	DaysUntilClose: &probotDays{Days: 7},
+	ExemptLabels:   &probotList{"pinned", "security"},
+	StaleLabel:     github.Ptr("wontfix"),
+}
+
+// probotConverted lists the probot/stale settings the bot has an equivalent
+// for.
+var probotConverted = map[string]bool{
+	"daysUntilStale": true,
+	"daysUntilClose": true,
+	"exemptLabels":   true,
+	"staleLabel":     true,
+	"markComment":    true,
+	"closeComment":   true,
+	"unmarkComment":  true,
+}
+
+// probotUnsupported lists the probot/stale settings the bot has no
+// equivalent for, and why. They are reported when set to anything other than
+// what the bot does anyway.
+var probotUnsupported = []struct {
+	key    string
+	set    func(s *probotSettings) bool
+	reason string
+}{
+	{"daysUntilClose", func(s *probotSettings) bool { return s.DaysUntilClose != nil && s.DaysUntilClose.Disabled },
+		"the bot always acts on stale PRs once the warning period has passed; use --stale-action milestone to park them instead of closing them"},
+	{"onlyLabels", func(s *probotSettings) bool { return s.OnlyLabels != nil && len(*s.OnlyLabels) > 0 },
+		"the bot processes every open PR and cannot be limited to labeled ones"},
+	{"exemptProjects", func(s *probotSettings) bool { return s.ExemptProjects != nil && *s.ExemptProjects },
+		"the bot does not look at projects; give the PRs an exempt label instead"},
+	{"exemptMilestones", func(s *probotSettings) bool { return s.ExemptMilestones != nil && *s.ExemptMilestones },
+		"the bot does not exempt PRs in milestones; give them an exempt label instead"},
+	{"exemptAssignees", func(s *probotSettings) bool { return s.ExemptAssignees != nil && *s.ExemptAssignees },
+		"the bot does not exempt assigned PRs"},
+	{"markComment", func(s *probotSettings) bool { return s.MarkComment != nil && s.MarkComment.Disabled },
+		"the bot always notifies the author when warning"},
+	{"closeComment", func(s *probotSettings) bool { return s.CloseComment != nil && s.CloseComment.Disabled },
+		"the bot always notifies the author when closing"},
+	{"limitPerRun", func(s *probotSettings) bool { return s.LimitPerRun != nil },
+		"the bot limits the API calls and emails of a run instead; see --max-api-calls and --max-emails"},
+	{"perform", func(s *probotSettings) bool { return s.Perform != nil && !*s.Perform },
+		"use --dry-run"},
+}
+
+// probotWarning is a probot/stale setting that was not converted.
+type probotWarning struct {
+	Key    string
+	Reason string
+}
+
+// probotTemplate is a comment of a probot/stale configuration converted to a
+// template override file.
+type probotTemplate struct {
+	Key  string
+	File string
+	Text string
+}
+
+// probotConversion is a probot/stale configuration converted to the bot's.
+type probotConversion struct {
+	Config    *fileConfig
+	Templates []probotTemplate
+	Warnings  []probotWarning
+}
+
+func (c *probotConversion) warn(key, format string, a ...interface{}) {
+	c.Warnings = append(c.Warnings, probotWarning{Key: key, Reason: fmt.Sprintf(format, a...)})
+}
+
+// findProbotConfig returns the probot/stale configuration to read: path, or
+// if empty .github/stale.yml, or "" if there is none. A missing default file
+// is not an error; a missing explicit path is.
+func findProbotConfig(path string) (string, error) {
+	if path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("failed to read probot/stale configuration: %v", err)
+		}
+		return path, nil
+	}
+	if _, err := os.Stat(probotConfigPath); err != nil {
+		return "", nil
+	}
+	return probotConfigPath, nil
+}
+
+// loadProbotConfig reads a probot/stale configuration file and converts it.
+// Keys it does not know are reported as warnings, as in config files.
+func loadProbotConfig(path string) (*probotConversion, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read probot/stale configuration: %v", err)
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse probot/stale configuration %s: %v", path, err)
+	}
+	c := &probotConfig{}
+	var unknown []string
+	if len(root.Content) > 0 {
+		unknown = unknownConfigKeys(root.Content[0], reflect.TypeOf(*c), "")
+		if err := root.Content[0].Decode(c); err != nil {
+			return nil, fmt.Errorf("invalid probot/stale configuration %s: %v", path, err)
+		}
+	}
+	conv, err := convertProbotConfig(c)
+	if err != nil {
+		return nil, fmt.Errorf("invalid probot/stale configuration %s: %v", path, err)
+	}
+	for _, key := range unknown {
+		conv.warn(key, "unknown setting")
+	}
+	return conv, nil
+}
+
+// mergeProbotSettings returns the settings of the last layer setting each.
+func mergeProbotSettings(layers ...*probotSettings) probotSettings {
+	var merged probotSettings
+	m := reflect.ValueOf(&merged).Elem()
+	for _, layer := range layers {
+		if layer == nil {
+			continue
+		}
+		v := reflect.ValueOf(layer).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Field(i).IsNil() {
+				m.Field(i).Set(v.Field(i))
+			}
+		}
+	}
+	return merged
+}
+
+// convertProbotConfig maps a probot/stale configuration onto the bot's
+// settings. probot/stale processes issues and PRs unless only says
+// otherwise, each with its section's settings; the bot always processes PRs,
+// with --include-issues for issues, and has one set of settings for both.
+// So the PR settings are used, and issue settings that differ are reported.
+// Comments become template overrides, and since probot/stale comments, the
+// bot is set to notify by commenting.
+func convertProbotConfig(c *probotConfig) (*probotConversion, error) {
+	conv := &probotConversion{Config: &fileConfig{NotifyVia: notifyComment}}
+	primary, secondary := "pulls", ""
+	switch c.Only {
+	case "":
+		conv.Config.IncludeIssues = true
+		secondary = "issues"
+	case "pulls":
+	case "issues":
+		conv.Config.IncludeIssues = true
+		primary = "issues"
+		conv.warn("only", "the bot always processes pull requests; they are processed with the issues settings too")
+	default:
+		return nil, fmt.Errorf("only must be pulls or issues, got %q", c.Only)
+	}
+	if c.Extends != "" {
+		conv.warn("_extends", "the bot cannot read settings from another repository; copy them into this file")
+	}
+
+	sections := map[string]*probotSettings{"": &c.probotSettings, "pulls": c.Pulls, "issues": c.Issues}
+	for _, name := range []string{"", "pulls", "issues"} {
+		s := sections[name]
+		if s == nil {
+			continue
+		}
+		prefix := ""
+		if name != "" {
+			if name != primary && name != secondary {
+				conv.warn(name, "not used: only is %s", c.Only)
+				continue
+			}
+			prefix = name + "."
+		}
+		for _, u := range probotUnsupported {
+			if u.set(s) {
+				conv.warn(prefix+u.key, "%s", u.reason)
+			}
+		}
+	}
+
+	s := mergeProbotSettings(&probotDefaults, &c.probotSettings, sections[primary])
+	if secondary != "" {
+		other := mergeProbotSettings(&probotDefaults, &c.probotSettings, sections[secondary])
+		a, b := reflect.ValueOf(s), reflect.ValueOf(other)
+		for i := 0; i < a.NumField(); i++ {
+			key, _, _ := strings.Cut(a.Type().Field(i).Tag.Get("yaml"), ",")
+			if probotConverted[key] && !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+				conv.warn(secondary+"."+key, "differs from the %s setting, which the bot uses for both", primary)
+			}
+		}
+	}
+
+	days := func(key string, d *probotDays) int {
+		n := int(math.Ceil(d.Days))
+		if float64(n) != d.Days {
+			conv.warn(key, "%v days rounded up to %d", d.Days, n)
+		}
+		return n
+	}
+	if s.DaysUntilStale.Disabled {
+		return nil, fmt.Errorf("daysUntilStale must be a number of days")
+	}
+	conv.Config.DaysInactive = days("daysUntilStale", s.DaysUntilStale)
+	switch {
+	case s.DaysUntilClose.Disabled:
+	case s.DaysUntilClose.Days == 0:
+		// probot/stale closes such PRs on its next run, but the bot has
+		// no zero warning period: it would mean the default.
+		conv.Config.WarningPeriod = 1
+		conv.warn("daysUntilClose", "0 days set to the shortest warning period, 1 day; pass --author-policies '*=close-immediately' to close stale PRs without a warning")
+	default:
+		conv.Config.WarningPeriod = days("daysUntilClose", s.DaysUntilClose)
+	}
+	conv.Config.Labels.Stale = *s.StaleLabel
+	conv.Config.Labels.Exempt = *s.ExemptLabels
+	if len(conv.Config.Labels.Exempt) == 0 {
+		conv.warn("exemptLabels", "an empty list keeps the bot's default exempt labels")
+	}
+	for _, t := range []struct {
+		key, file string
+		comment   *probotText
+	}{
+		{"markComment", "warning.tmpl", s.MarkComment},
+		{"closeComment", "closure.tmpl", s.CloseComment},
+		{"unmarkComment", "unstale.tmpl", s.UnmarkComment},
+	} {
+		if t.comment != nil && !t.comment.Disabled {
+			conv.Templates = append(conv.Templates, probotTemplate{Key: t.key, File: t.file, Text: literalTemplate(t.comment.Text)})
+		}
+	}
+	if s.UnmarkComment != nil && !s.UnmarkComment.Disabled {
+		conv.Config.NotifyOnUnstale = true
+	}
+	return conv, nil
+}
+
+// literalTemplate returns a template rendering text as is.
+func literalTemplate(text string) string {
+	return strings.ReplaceAll(text, "{{", `{{"{{"}}`)
+}
+
+// probotTemplateOverrides returns the template overrides of the comments of
+// the probot/stale configuration at path.
+func probotTemplateOverrides(path string, templates []probotTemplate, r *templateRenderer) (map[string]string, error) {
+	return readTemplateOverrides(r, func(file string) (string, []byte, error) {
+		for _, t := range templates {
+			if t.File == file {
+				return path + " " + t.Key, []byte(t.Text), nil
+			}
+		}
+		return "", nil, os.ErrNotExist
+	})
+}
+
+// writeYAML writes the converted configuration as a config file, listing
+// the settings that were not converted in comments.
+func (c *probotConversion) writeYAML(w io.Writer, source string) error {
+	var data strings.Builder
+	enc := yaml.NewEncoder(&data)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.Config); err != nil {
+		return fmt.Errorf("failed to encode config file: %v", err)
+	}
+	header := fmt.Sprintf("# Converted from the probot/stale configuration %s.\n", source)
+	header += "# Add the owner, repos and github settings, or pass them as flags.\n"
+	if len(c.Warnings) > 0 {
+		header += "#\n# Not fully converted:\n"
+		for _, warning := range c.Warnings {
+			header += fmt.Sprintf("#   %s: %s\n", warning.Key, warning.Reason)
+		}
+	}
+	_, err := io.WriteString(w, header+data.String())
+	return err
+}
+
+// runConvertConfigCommand implements the convert-config subcommand, which
+// prints a probot/stale configuration as a config file for the bot, for a
+// permanent migration. Its comments are written as template overrides to
+// --template-dir.
+func runConvertConfigCommand(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("convert-config", flag.ContinueOnError)
+	templateDirFlag := fs.String("template-dir", "", "Directory to write the configuration's comments to as template overrides; it is created if needed, and existing templates are not overwritten")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: convert-config [flags] [probot/stale configuration (default %s)]\n", probotConfigPath)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("usage: convert-config [flags] [file]")
+	}
+	path := probotConfigPath
+	if fs.NArg() == 1 {
+		path = fs.Arg(0)
+	}
+	conv, err := loadProbotConfig(path)
+	if err != nil {
+		return err
+	}
+
+	if len(conv.Templates) > 0 {
+		if *templateDirFlag == "" {
+			for _, t := range conv.Templates {
+				conv.warn(t.Key, "pass --template-dir to write it as a template override")
+			}
+		} else {
+			if err := os.MkdirAll(*templateDirFlag, 0o755); err != nil {
+				return fmt.Errorf("failed to create template directory: %v", err)
+			}
+			for _, t := range conv.Templates {
+				file := filepath.Join(*templateDirFlag, t.File)
+				f, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+				if errors.Is(err, os.ErrExist) {
+					return fmt.Errorf("template %s already exists; not overwriting it", file)
+				}
+				if err != nil {
+					return fmt.Errorf("failed to write template: %v", err)
+				}
+				_, err = f.WriteString(t.Text)
+				if cerr := f.Close(); err == nil {
+					err = cerr
+				}
+				if err != nil {
+					return fmt.Errorf("failed to write template %s: %v", file, err)
+				}
+			}
+			conv.Config.TemplateDir = *templateDirFlag
+		}
+	}
+	return conv.writeYAML(w, path)
+}
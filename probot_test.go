@@ -0,0 +1,190 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadProbotConfig(t *testing.T) {
+	type settings struct {
+		daysInactive, warningPeriod int
+		includeIssues, unstale      bool
+		stale                       string
+		exempt                      []string
+	}
+	defaults := settings{60, 7, true, false, "wontfix", []string{"pinned", "security"}}
+	with := func(change func(s *settings)) settings {
+		s := defaults
+		change(&s)
+		return s
+	}
+	for _, tc := range []struct {
+		name string
+		yaml string
+		want settings
+		// warnings are the keys of the settings reported as not fully
+		// converted, in order.
+		warnings  []string
+		templates []string
+		err       string
+	}{
+		{name: "empty", yaml: "", want: defaults},
+		{
+			name: "top level",
+			yaml: "daysUntilStale: 30\ndaysUntilClose: 5\nstaleLabel: stale\nexemptLabels: [keep, wip]\n",
+			want: with(func(s *settings) {
+				s.daysInactive, s.warningPeriod, s.stale, s.exempt = 30, 5, "stale", []string{"keep", "wip"}
+			}),
+		},
+		{
+			name: "scalar exemptLabels",
+			yaml: "exemptLabels: keep\n",
+			want: with(func(s *settings) { s.exempt = []string{"keep"} }),
+		},
+		{
+			name:     "empty exemptLabels",
+			yaml:     "exemptLabels: []\n",
+			want:     with(func(s *settings) { s.exempt = []string{} }),
+			warnings: []string{"exemptLabels"},
+		},
+		{
+			name:     "fractional days",
+			yaml:     "daysUntilStale: 0.5\n",
+			want:     with(func(s *settings) { s.daysInactive = 1 }),
+			warnings: []string{"daysUntilStale"},
+		},
+		{
+			name: "only pulls",
+			yaml: "only: pulls\ndaysUntilStale: 20\nissues:\n  daysUntilStale: 90\n",
+			want: with(func(s *settings) { s.daysInactive, s.includeIssues = 20, false }),
+			// The issues section is not used at all.
+			warnings: []string{"issues"},
+		},
+		{
+			name:     "only issues",
+			yaml:     "only: issues\nissues:\n  daysUntilStale: 90\npulls:\n  daysUntilStale: 20\n",
+			want:     with(func(s *settings) { s.daysInactive = 90 }),
+			warnings: []string{"only", "pulls"},
+		},
+		{name: "invalid only", yaml: "only: discussions\n", err: "only must be pulls or issues"},
+		{
+			name:     "pulls override the top level",
+			yaml:     "daysUntilStale: 30\npulls:\n  daysUntilStale: 10\n  staleLabel: stale-pr\n",
+			want:     with(func(s *settings) { s.daysInactive, s.stale = 10, "stale-pr" }),
+			warnings: []string{"issues.daysUntilStale", "issues.staleLabel"},
+		},
+		{
+			name:     "issues settings that differ are reported",
+			yaml:     "issues:\n  exemptLabels: [roadmap]\n",
+			want:     defaults,
+			warnings: []string{"issues.exemptLabels"},
+		},
+		{
+			name: "issues settings equal to the pulls settings are not reported",
+			yaml: "daysUntilStale: 30\nissues:\n  daysUntilStale: 30\n",
+			want: with(func(s *settings) { s.daysInactive = 30 }),
+		},
+		{
+			name:     "_extends",
+			yaml:     "_extends: .github\n",
+			want:     defaults,
+			warnings: []string{"_extends"},
+		},
+		{
+			name:     "daysUntilClose false",
+			yaml:     "daysUntilClose: false\n",
+			want:     with(func(s *settings) { s.warningPeriod = 0 }),
+			warnings: []string{"daysUntilClose"},
+		},
+		{
+			name:     "daysUntilClose 0",
+			yaml:     "daysUntilClose: 0\n",
+			want:     with(func(s *settings) { s.warningPeriod = 1 }),
+			warnings: []string{"daysUntilClose"},
+		},
+		{name: "daysUntilStale false", yaml: "daysUntilStale: false\n", err: "daysUntilStale must be a number of days"},
+		{name: "daysUntilStale true", yaml: "daysUntilStale: true\n", err: "must be a number of days or false"},
+		{name: "negative days", yaml: "daysUntilClose: -1\n", err: "must not be negative"},
+		{
+			name:     "comments false",
+			yaml:     "markComment: false\ncloseComment: false\nunmarkComment: false\n",
+			want:     defaults,
+			warnings: []string{"markComment", "closeComment"},
+		},
+		{
+			name:      "comments",
+			yaml:      "markComment: Going stale {{soon}}\ncloseComment: Closed\nunmarkComment: Thanks\n",
+			want:      with(func(s *settings) { s.unstale = true }),
+			templates: []string{"warning.tmpl", "closure.tmpl", "unstale.tmpl"},
+		},
+		{
+			name:     "unsupported settings",
+			yaml:     "onlyLabels: [triage]\nexemptProjects: true\nexemptMilestones: true\nexemptAssignees: true\nlimitPerRun: 30\nperform: false\n",
+			want:     defaults,
+			warnings: []string{"onlyLabels", "exemptProjects", "exemptMilestones", "exemptAssignees", "limitPerRun", "perform"},
+		},
+		{
+			name: "unsupported settings set to what the bot does",
+			yaml: "onlyLabels: []\nexemptProjects: false\nexemptMilestones: false\nexemptAssignees: false\nperform: true\n",
+			want: defaults,
+		},
+		{
+			name:     "unknown settings",
+			yaml:     "daysUntilStal: 30\npulls:\n  colour: red\n",
+			want:     defaults,
+			warnings: []string{"daysUntilStal", "pulls.colour"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			conv, err := loadProbotConfig(writeTestFile(t, "stale.yml", tc.yaml))
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("loadProbotConfig returned %v, want an error containing %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := conv.Config
+			got := settings{c.DaysInactive, c.WarningPeriod, c.IncludeIssues, c.NotifyOnUnstale, c.Labels.Stale, c.Labels.Exempt}
+			if got.daysInactive != tc.want.daysInactive || got.warningPeriod != tc.want.warningPeriod ||
+				got.includeIssues != tc.want.includeIssues || got.unstale != tc.want.unstale ||
+				got.stale != tc.want.stale || !slices.Equal(got.exempt, tc.want.exempt) {
+				t.Errorf("converted to %+v, want %+v", got, tc.want)
+			}
+			if c.NotifyVia != notifyComment {
+				t.Errorf("notify_via is %q, want %q", c.NotifyVia, notifyComment)
+			}
+			var warnings []string
+			for _, w := range conv.Warnings {
+				warnings = append(warnings, w.Key)
+			}
+			if !slices.Equal(warnings, tc.warnings) {
+				t.Errorf("warned about %q, want %q", warnings, tc.warnings)
+			}
+			var templates []string
+			for _, tmpl := range conv.Templates {
+				templates = append(templates, tmpl.File)
+			}
+			if !slices.Equal(templates, tc.templates) {
+				t.Errorf("templates %q, want %q", templates, tc.templates)
+			}
+		})
+	}
+}
+
+func TestLiteralTemplate(t *testing.T) {
+	r := newTemplateRenderer(&config{DisplayLocation: time.UTC})
+	for _, text := range []string{"plain", "Going stale {{soon}}", "{{.Kind}} and }}"} {
+		got, err := r.render("literal", literalTemplate(text), notificationData{Kind: "PR"})
+		if err != nil {
+			t.Fatalf("rendering the literal template of %q: %v", text, err)
+		}
+		if got != text {
+			t.Errorf("the literal template of %q renders %q", text, got)
+		}
+	}
+}
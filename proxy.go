@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/net/proxy"
+)
+
+// dialContextFunc matches http.Transport.DialContext.
+type dialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// proxyDialError marks a failure reaching GitHub through the configured proxy
+// or bastion, as opposed to an error returned by GitHub itself.
+type proxyDialError struct {
+	Proxy string
+	Err   error
+}
+
+func (e *proxyDialError) Error() string {
+	return fmt.Sprintf("bastion/proxy error via %s: %v", e.Proxy, e.Err)
+}
+
+func (e *proxyDialError) Unwrap() error {
+	return e.Err
+}
+
+// sshProxyOptions configures authentication for ssh:// dial proxies.
+type sshProxyOptions struct {
+	KeyFile        string
+	KnownHostsFile string
+}
+
+// newProxyTransport returns an HTTP transport that dials through proxyURL,
+// which is either socks5://[user:pass@]host:port or ssh://user@bastion[:port].
+func newProxyTransport(proxyURL string, sshOpts sshProxyOptions) (*http.Transport, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dial proxy %q: %v", proxyURL, err)
+	}
+
+	var dial dialContextFunc
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		dial, err = socks5Dialer(u)
+	case "ssh":
+		dial, err = sshDialer(u, sshOpts)
+	default:
+		return nil, fmt.Errorf("unsupported dial proxy scheme %q: use socks5:// or ssh://", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	redacted := u.Redacted()
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.Proxy = nil
+	tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, &proxyDialError{Proxy: redacted, Err: err}
+		}
+		return conn, nil
+	}
+	return tr, nil
+}
+
+func socks5Dialer(u *url.URL) (dialContextFunc, error) {
+	var auth *proxy.Auth
+	if u.User != nil {
+		password, _ := u.User.Password()
+		auth = &proxy.Auth{User: u.User.Username(), Password: password}
+	}
+	d, err := proxy.SOCKS5("tcp", u.Host, auth, &net.Dialer{})
+	if err != nil {
+		return nil, fmt.Errorf("invalid SOCKS5 proxy: %v", err)
+	}
+	cd, ok := d.(proxy.ContextDialer)
+	if !ok {
+		return nil, fmt.Errorf("SOCKS5 dialer does not support contexts")
+	}
+	return cd.DialContext, nil
+}
+
+// sshDialer tunnels connections through an SSH bastion using key
+// authentication. The SSH connection is opened on first use and re-opened if
+// it breaks.
+func sshDialer(u *url.URL, opts sshProxyOptions) (dialContextFunc, error) {
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("ssh dial proxy requires a user, e.g. ssh://user@bastion")
+	}
+	home, _ := os.UserHomeDir()
+	keyFile := opts.KeyFile
+	if keyFile == "" {
+		keyFile = filepath.Join(home, ".ssh", "id_rsa")
+	}
+	knownHostsFile := opts.KnownHostsFile
+	if knownHostsFile == "" {
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SSH key: %v", err)
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH key %s: %v", keyFile, err)
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known hosts: %v", err)
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "22")
+	}
+	config := &ssh.ClientConfig{
+		User:            u.User.Username(),
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+	}
+
+	var mu sync.Mutex
+	var sshClient *ssh.Client
+	return func(ctx context.Context, network, target string) (net.Conn, error) {
+		mu.Lock()
+		if sshClient == nil {
+			var d net.Dialer
+			conn, err := d.DialContext(ctx, "tcp", addr)
+			if err != nil {
+				mu.Unlock()
+				return nil, fmt.Errorf("failed to reach bastion: %v", err)
+			}
+			c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+			if err != nil {
+				conn.Close()
+				mu.Unlock()
+				return nil, fmt.Errorf("SSH handshake with bastion failed: %v", err)
+			}
+			sshClient = ssh.NewClient(c, chans, reqs)
+		}
+		c := sshClient
+		mu.Unlock()
+
+		conn, err := c.Dial(network, target)
+		if err != nil {
+			mu.Lock()
+			if sshClient == c {
+				sshClient.Close()
+				sshClient = nil
+			}
+			mu.Unlock()
+			return nil, fmt.Errorf("bastion could not connect to %s: %v", target, err)
+		}
+		return conn, nil
+	}, nil
+}
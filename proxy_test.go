@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testSOCKS5 is a minimal in-process SOCKS5 server supporting CONNECT, with
+// no authentication or with a username and password.
+type testSOCKS5 struct {
+	ln       net.Listener
+	user     string
+	password string
+
+	mu      sync.Mutex
+	targets []string
+}
+
+func startTestSOCKS5(t *testing.T, user, password string) *testSOCKS5 {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testSOCKS5{ln: ln, user: user, password: password}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *testSOCKS5) dialed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.targets...)
+}
+
+func (s *testSOCKS5) serve(conn net.Conn) {
+	defer conn.Close()
+	// Greeting: version, then the offered authentication methods.
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return
+	}
+	methods := make([]byte, head[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return
+	}
+	if s.user == "" {
+		conn.Write([]byte{5, 0})
+	} else {
+		conn.Write([]byte{5, 2})
+		// Username and password negotiation, RFC 1929.
+		var b [1]byte
+		field := func() string {
+			io.ReadFull(conn, b[:])
+			f := make([]byte, b[0])
+			io.ReadFull(conn, f)
+			return string(f)
+		}
+		io.ReadFull(conn, b[:])
+		if field() != s.user || field() != s.password {
+			conn.Write([]byte{1, 1})
+			return
+		}
+		conn.Write([]byte{1, 0})
+	}
+
+	// Request: version, command, reserved, address type, address, port.
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(conn, req); err != nil || req[1] != 1 {
+		return
+	}
+	var host string
+	switch req[3] {
+	case 1:
+		ip := make([]byte, 4)
+		io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	case 3:
+		var n [1]byte
+		io.ReadFull(conn, n[:])
+		name := make([]byte, n[0])
+		io.ReadFull(conn, name)
+		host = string(name)
+	default:
+		return
+	}
+	var port [2]byte
+	io.ReadFull(conn, port[:])
+	target := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:]))))
+	s.mu.Lock()
+	s.targets = append(s.targets, target)
+	s.mu.Unlock()
+
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer upstream.Close()
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	go io.Copy(upstream, conn)
+	io.Copy(conn, upstream)
+}
+
+func TestGitHubClientDialsThroughSOCKS5(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"number":7}`))
+	}))
+	t.Cleanup(srv.Close)
+	ghes, _ := url.Parse(srv.URL)
+
+	for _, tc := range []struct {
+		name, user, password string
+	}{
+		{name: "no authentication"},
+		{name: "password", user: "bot", password: "s3cret"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			socks := startTestSOCKS5(t, tc.user, tc.password)
+			proxyURL := &url.URL{Scheme: "socks5", Host: socks.ln.Addr().String()}
+			if tc.user != "" {
+				proxyURL.User = url.UserPassword(tc.user, tc.password)
+			}
+			client, err := getGithubClient("token", srv.URL+"/", proxyURL.String(), "", sshProxyOptions{}, newRunBudget(0, 0), 0, nil, newWriteGuard(false, false), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			issue, _, err := client.Issues.Get(context.Background(), "acme", "api", 7)
+			if err != nil {
+				t.Fatalf("getting an issue through the proxy failed: %v", err)
+			}
+			if issue.GetNumber() != 7 {
+				t.Errorf("got issue #%d, want #7", issue.GetNumber())
+			}
+			if got := socks.dialed(); len(got) != 1 || got[0] != ghes.Host {
+				t.Errorf("the proxy dialed %q, want only %s", got, ghes.Host)
+			}
+		})
+	}
+}
+
+func TestProxyErrorsAreDistinguished(t *testing.T) {
+	// A listener closed at once leaves an address nothing answers on.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	client, err := getGithubClient("token", "https://ghes.internal/api/v3/", "socks5://bot:s3cret@"+addr, "", sshProxyOptions{}, newRunBudget(0, 0), 0, nil, newWriteGuard(false, false), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = client.Issues.Get(context.Background(), "acme", "api", 7)
+	var dialErr *proxyDialError
+	if !errors.As(err, &dialErr) {
+		t.Fatalf("Get returned %v, want a proxy error", err)
+	}
+	if want := "bastion/proxy error via socks5://bot:xxxxx@" + addr; !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q, want it to contain %q", err, want)
+	}
+	if strings.Contains(err.Error(), "s3cret") {
+		t.Errorf("error %q shows the proxy password", err)
+	}
+}
+
+func TestInvalidDialProxy(t *testing.T) {
+	for _, tc := range []struct {
+		proxy   string
+		opts    sshProxyOptions
+		wantErr string
+	}{
+		{proxy: "http://proxy.internal:3128", wantErr: `unsupported dial proxy scheme "http"`},
+		{proxy: "bastion.internal:22", wantErr: "unsupported dial proxy scheme"},
+		{proxy: "ssh://bastion.internal", wantErr: "requires a user"},
+		{proxy: "ssh://bot@bastion.internal", opts: sshProxyOptions{KeyFile: filepath.Join(t.TempDir(), "missing")}, wantErr: "failed to read SSH key"},
+	} {
+		_, err := newProxyTransport(tc.proxy, tc.opts)
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("newProxyTransport(%q) returned %v, want an error containing %q", tc.proxy, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// question is an author's comment asking the maintainers something that no
+// one has answered yet.
+type question struct {
+	At time.Time
+	// Excerpt is the line of the comment holding the question.
+	Excerpt string
+}
+
+var (
+	fencedCodeRe = regexp.MustCompile("(?ms)^[ \t]*(```|~~~).*?^[ \t]*(```|~~~)[ \t]*$")
+	inlineCodeRe = regexp.MustCompile("`[^`\n]*`")
+	urlRe        = regexp.MustCompile(`https?://\S+`)
+)
+
+// defaultQuestionPattern matches a question mark.
+var defaultQuestionPattern = regexp.MustCompile(`\?`)
+
+// parseQuestionPatterns compiles a comma-separated list of regular
+// expressions. An empty list means a question mark.
+func parseQuestionPatterns(s string) ([]*regexp.Regexp, error) {
+	var patterns []*regexp.Regexp
+	for _, p := range splitList(s) {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid question pattern %q: %v", p, err)
+		}
+		patterns = append(patterns, re)
+	}
+	if len(patterns) == 0 {
+		patterns = []*regexp.Regexp{defaultQuestionPattern}
+	}
+	return patterns, nil
+}
+
+// questionLine returns the first line of a comment that asks a question, or
+// "" if none does. Code blocks, inline code, quoted lines and URLs are
+// ignored, so a question mark in a snippet or a query string does not count.
+func questionLine(body string, patterns []*regexp.Regexp) string {
+	body = fencedCodeRe.ReplaceAllString(body, "")
+	body = inlineCodeRe.ReplaceAllString(body, "")
+	body = urlRe.ReplaceAllString(body, "")
+	for _, line := range strings.Split(body, "\n") {
+		// Indented lines are code blocks too.
+		if strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, ">") {
+			continue
+		}
+		for _, re := range patterns {
+			if re.MatchString(line) {
+				return line
+			}
+		}
+	}
+	return ""
+}
+
+// pendingQuestion returns the author's question if the last human comment on
+// a PR, a conversation comment or a review, is the author asking one.
+// Comments by the bot and by other bots are skipped.
+func pendingQuestion(pr *github.PullRequest, events []*github.Timeline, botLogin string, patterns []*regexp.Regexp) *question {
+	author := pr.GetUser().GetLogin()
+	var last *github.Timeline
+	for _, ev := range events {
+		if ev.GetEvent() != "commented" && ev.GetEvent() != "reviewed" {
+			continue
+		}
+		actor := timelineActor(ev)
+		if strings.HasSuffix(actor, "[bot]") || (botLogin != "" && strings.EqualFold(actor, botLogin)) {
+			continue
+		}
+		last = ev
+	}
+	if last == nil || !strings.EqualFold(timelineActor(last), author) {
+		return nil
+	}
+	line := questionLine(last.GetBody(), patterns)
+	if line == "" {
+		return nil
+	}
+	at := last.GetCreatedAt().Time
+	if last.GetEvent() == "reviewed" {
+		at = last.GetSubmittedAt().Time
+	}
+	return &question{At: at, Excerpt: truncateLine(line, 100)}
+}
+
+// truncateLine shortens s to at most n runes, marking the cut with an
+// ellipsis.
+func truncateLine(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// codeOwnersRule is a line of a CODEOWNERS file.
+type codeOwnersRule struct {
+	re     *regexp.Regexp
+	Owners []string
+}
+
+// parseCodeOwners parses a CODEOWNERS file. Invalid lines are skipped.
+func parseCodeOwners(content string) []codeOwnersRule {
+	var rules []codeOwnersRule
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		re, err := regexp.Compile(globToRegexp(fields[0]))
+		if err != nil {
+			continue
+		}
+		rules = append(rules, codeOwnersRule{re: re, Owners: fields[1:]})
+	}
+	return rules
+}
+
+// codeOwnersOf returns the owners of the given files, sorted. As on GitHub,
+// the last matching rule of a file wins.
+func codeOwnersOf(rules []codeOwnersRule, files []string) []string {
+	seen := map[string]bool{}
+	for _, f := range files {
+		f = path.Clean(strings.TrimPrefix(f, "/"))
+		for i := len(rules) - 1; i >= 0; i-- {
+			if rules[i].re.MatchString(f) {
+				for _, o := range rules[i].Owners {
+					seen[o] = true
+				}
+				break
+			}
+		}
+	}
+	owners := make([]string, 0, len(seen))
+	for o := range seen {
+		owners = append(owners, o)
+	}
+	sort.Strings(owners)
+	return owners
+}
+
+// getCodeOwners reads the repository's CODEOWNERS file from the locations
+// GitHub looks in. It returns nil if there is none.
+func getCodeOwners(client *github.Client, owner, repo string) ([]codeOwnersRule, error) {
+	ctx := context.Background()
+	for _, p := range []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"} {
+		file, _, resp, err := client.Repositories.GetContents(ctx, owner, repo, p, nil)
+		if resp != nil && resp.StatusCode == 404 {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", p, err)
+		}
+		content, err := file.GetContent()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %v", p, err)
+		}
+		return parseCodeOwners(content), nil
+	}
+	return nil, nil
+}
+
+// maintainersToNudge returns the mentions for a PR's unanswered question:
+// its requested reviewers or, failing those, the code owners of its files.
+func maintainersToNudge(pr *github.PullRequest, codeOwners func() ([]string, error)) ([]string, error) {
+	var mentions []string
+	for _, u := range pr.RequestedReviewers {
+		mentions = append(mentions, "@"+u.GetLogin())
+	}
+	for _, t := range pr.RequestedTeams {
+		mentions = append(mentions, "@"+pr.GetBase().GetRepo().GetOwner().GetLogin()+"/"+t.GetSlug())
+	}
+	if len(mentions) > 0 {
+		return mentions, nil
+	}
+	return codeOwners()
+}
+
+const questionNudgeTemplate = `{{range $i, $m := .Mentions}}{{if $i}} {{end}}{{$m}}{{end}}{{if .Mentions}}: {{end}}@{{.Author}} asked a question on {{formatDateIn .AskedAt .Location}} that has not been answered yet:
+
+> {{.Excerpt}}
+
+This pull request has had no other activity for {{humanizeDuration .Inactive}}. It will not be closed for inactivity while it is waiting on a maintainer's reply.
+`
+
+// questionNudgeData is the data passed to the question nudge template.
+type questionNudgeData struct {
+	Mentions []string
+	Author   string
+	AskedAt  time.Time
+	Excerpt  string
+	Inactive time.Duration
+	Location *time.Location
+}
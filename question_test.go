@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// TestQuestionLineCorpus runs questionLine with the default pattern on each
+// comment in testdata/questions and compares the question it finds with the
+// .golden file next to it, which is empty if there is none. Run with -update
+// to rewrite them.
+func TestQuestionLineCorpus(t *testing.T) {
+	comments, err := filepath.Glob(filepath.Join("testdata", "questions", "*.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) == 0 {
+		t.Fatal("no comments in testdata/questions")
+	}
+	patterns, err := parseQuestionPatterns("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, comment := range comments {
+		t.Run(strings.TrimSuffix(filepath.Base(comment), ".md"), func(t *testing.T) {
+			body, err := os.ReadFile(comment)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := questionLine(string(body), patterns)
+			if got != "" {
+				got += "\n"
+			}
+			golden := strings.TrimSuffix(comment, ".md") + ".golden"
+			if *updateGolden {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v; run the test with -update to create it", err)
+			}
+			if got != string(want) {
+				t.Errorf("questionLine found %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestPendingQuestion(t *testing.T) {
+	asked := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	comment := func(login, body string) *github.Timeline {
+		return &github.Timeline{
+			Event:     github.Ptr("commented"),
+			Actor:     &github.User{Login: github.Ptr(login)},
+			Body:      github.Ptr(body),
+			CreatedAt: &github.Timestamp{Time: asked},
+		}
+	}
+	review := &github.Timeline{
+		Event:       github.Ptr("reviewed"),
+		User:        &github.User{Login: github.Ptr("alice")},
+		Body:        github.Ptr("Should this be configurable?"),
+		SubmittedAt: &github.Timestamp{Time: asked.Add(time.Hour)},
+	}
+	for _, tc := range []struct {
+		name   string
+		events []*github.Timeline
+		// want is the excerpt of the pending question, if any.
+		want string
+		at   time.Time
+	}{
+		{name: "no comments"},
+		{name: "author asks", events: []*github.Timeline{comment("alice", "Any update?")}, want: "Any update?", at: asked},
+		{name: "author asks ignoring case", events: []*github.Timeline{comment("Alice", "Any update?")}, want: "Any update?", at: asked},
+		{name: "author states", events: []*github.Timeline{comment("alice", "Rebased.")}},
+		{name: "maintainer answered", events: []*github.Timeline{comment("alice", "Any update?"), comment("bob", "Soon.")}},
+		{name: "bots are skipped", events: []*github.Timeline{comment("alice", "Any update?"), comment("ci[bot]", "Build failed."), comment("stale-bot", "This PR is stale.")}, want: "Any update?", at: asked},
+		{name: "someone else asks", events: []*github.Timeline{comment("bob", "Any update?")}},
+		{name: "review", events: []*github.Timeline{comment("bob", "Looks fine."), review}, want: "Should this be configurable?", at: asked.Add(time.Hour)},
+		{name: "long question", events: []*github.Timeline{comment("alice", strings.Repeat("a", 120)+"?")}, want: strings.Repeat("a", 99) + "…", at: asked},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pr := testPR("alice", asked)
+			q := pendingQuestion(pr, tc.events, "stale-bot", []*regexp.Regexp{defaultQuestionPattern})
+			switch {
+			case q == nil && tc.want != "":
+				t.Fatalf("no pending question, want %q", tc.want)
+			case q != nil && tc.want == "":
+				t.Fatalf("pending question %q, want none", q.Excerpt)
+			case q != nil && (q.Excerpt != tc.want || !q.At.Equal(tc.at)):
+				t.Errorf("pending question %q at %v, want %q at %v", q.Excerpt, q.At, tc.want, tc.at)
+			}
+		})
+	}
+}
+
+func TestParseQuestionPatterns(t *testing.T) {
+	patterns, err := parseQuestionPatterns("")
+	if err != nil || len(patterns) != 1 || patterns[0] != defaultQuestionPattern {
+		t.Errorf("parseQuestionPatterns(\"\") = %v, %v, want the question mark", patterns, err)
+	}
+	if _, err := parseQuestionPatterns(`\?, (unclosed`); err == nil || !strings.Contains(err.Error(), `invalid question pattern "(unclosed"`) {
+		t.Errorf("parseQuestionPatterns returned %v, want an error for the invalid pattern", err)
+	}
+
+	patterns, err = parseQuestionPatterns(`(?i)\bshould I\b, (?i)^any update`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for body, want := range map[string]string{
+		"Should I split this into two PRs.":          "Should I split this into two PRs.",
+		"Rebased.\nany update on this":               "any update on this",
+		"Is this right?":                             "",
+		"```\n// should I keep this?\n```\nRebased.": "",
+	} {
+		if got := questionLine(body, patterns); got != want {
+			t.Errorf("questionLine(%q) with custom patterns = %q, want %q", body, got, want)
+		}
+	}
+}
+
+const testCodeOwners = `# Default owners
+*           @acme/core
+*.md        @acme/docs   # documentation
+/cmd/       @carol
+docs/api/   @dave @acme/docs
+invalid[
+`
+
+func TestCodeOwnersOf(t *testing.T) {
+	rules := parseCodeOwners(testCodeOwners)
+	for _, tc := range []struct {
+		files []string
+		want  string
+	}{
+		{files: []string{"main.go"}, want: "[@acme/core]"},
+		{files: []string{"README.md"}, want: "[@acme/docs]"},
+		{files: []string{"cmd/bot/main.go"}, want: "[@carol]"},
+		{files: []string{"tools/cmd/main.go"}, want: "[@acme/core]"},
+		{files: []string{"/docs/api/index.html"}, want: "[@acme/docs @dave]"},
+		{files: []string{"cmd/README.md", "main.go"}, want: "[@acme/core @carol]"},
+		{files: nil, want: "[]"},
+	} {
+		if got := fmt.Sprint(codeOwnersOf(rules, tc.files)); got != tc.want {
+			t.Errorf("codeOwnersOf(%q) = %s, want %s", tc.files, got, tc.want)
+		}
+	}
+}
+
+func TestMaintainersToNudge(t *testing.T) {
+	codeOwners := func() ([]string, error) { return []string{"@acme/core"}, nil }
+	pr := testPR("alice", time.Now())
+	pr.Base = &github.PullRequestBranch{Repo: &github.Repository{Owner: &github.User{Login: github.Ptr("acme")}}}
+	if got, err := maintainersToNudge(pr, codeOwners); err != nil || fmt.Sprint(got) != "[@acme/core]" {
+		t.Errorf("with no requested reviewers, maintainersToNudge = %v, %v, want the code owners", got, err)
+	}
+	pr.RequestedReviewers = []*github.User{{Login: github.Ptr("bob")}}
+	pr.RequestedTeams = []*github.Team{{Slug: github.Ptr("reviewers")}}
+	if got, err := maintainersToNudge(pr, codeOwners); err != nil || fmt.Sprint(got) != "[@bob @acme/reviewers]" {
+		t.Errorf("maintainersToNudge = %v, %v, want the requested reviewers and team", got, err)
+	}
+}
+
+// TestQuestionNudgesMaintainers scans a stale PR whose author's last comment
+// is an unanswered question: the code owners of its files are nudged once
+// instead of the author being warned, unless --close-waiting-on-maintainer
+// is set.
+func TestQuestionNudgesMaintainers(t *testing.T) {
+	asked := time.Now().AddDate(0, 0, -40).UTC().Truncate(time.Second)
+	timeline, err := json.Marshal([]*github.Timeline{{
+		Event:     github.Ptr("commented"),
+		Actor:     &github.User{Login: github.Ptr("alice")},
+		Body:      github.Ptr("Rebased.\n\n```go\nx := a ? b : c\n```\nShould I split this into two PRs?"),
+		CreatedAt: &github.Timestamp{Time: asked},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	codeOwners, err := json.Marshal(&github.RepositoryContent{
+		Encoding: github.Ptr("base64"),
+		Content:  github.Ptr(base64.StdEncoding.EncodeToString([]byte(testCodeOwners))),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, closeWaiting := range []bool{false, true} {
+		t.Run(fmt.Sprintf("close-waiting-on-maintainer=%v", closeWaiting), func(t *testing.T) {
+			gh := &fakeGitHub{get: map[string]string{
+				"/repos/acme/api/issues/42/timeline":          string(timeline),
+				"/repos/acme/api/contents/.github/CODEOWNERS": string(codeOwners),
+				"/repos/acme/api/pulls/42/files":              `[{"filename":"docs/guide.md"}]`,
+			}}
+			s := newTestRepoScanner(t, gh, nil, fmt.Sprintf("--close-waiting-on-maintainer=%v", closeWaiting))
+			s.cfg.Rules.CloseWaitingOnMaintainer = closeWaiting
+			s.questionPatterns = []*regexp.Regexp{defaultQuestionPattern}
+			pr := testPR("alice", asked)
+			d := s.actOn(pr)
+			if closeWaiting {
+				if d.Action != actionWarn {
+					t.Errorf("decided %s, want warn", d.Action)
+				}
+				return
+			}
+			if d.Action != actionQuestion || d.Question == nil || d.Question.Excerpt != "Should I split this into two PRs?" {
+				t.Fatalf("decided %s with question %+v, want the question", d.Action, d.Question)
+			}
+			writes := gh.written()
+			if len(writes) != 1 || !strings.HasPrefix(writes[0], "POST /repos/acme/api/issues/42/comments") ||
+				!strings.Contains(writes[0], `@acme/docs: @alice asked a question`) || !strings.Contains(writes[0], "> Should I split this into two PRs?") {
+				t.Errorf("writes %q, want the docs owners nudged", writes)
+			}
+			if len(s.summary.WaitingOnMaintainer) != 1 {
+				t.Errorf("waiting on maintainer %q, want the PR listed", s.summary.WaitingOnMaintainer)
+			}
+
+			// The next run does not nudge again about the same question.
+			gh.writes = nil
+			if d := s.actOn(pr); d.Action != actionQuestion || len(gh.written()) != 0 {
+				t.Errorf("the next run decided %s and wrote %q, want no second nudge", d.Action, gh.written())
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// errRateLimitWaitExceeded is returned for GitHub API calls made after
+// waiting out a rate limit would have exceeded --max-rate-limit-wait.
+var errRateLimitWaitExceeded = errors.New("GitHub rate limit wait exceeds --max-rate-limit-wait")
+
+// Waits used when GitHub does not say how long to wait: for a secondary rate
+// limit without a Retry-After header, and after a primary rate limit resets,
+// to allow for clock skew.
+const (
+	defaultSecondaryRateLimitWait = time.Minute
+	rateLimitResetSlack           = 5 * time.Second
+)
+
+// rateLimitWait tracks the time the run has spent waiting out GitHub rate
+// limits. Once the next wait would take it past maxWait, the run is aborted
+// and every later API call fails.
+type rateLimitWait struct {
+	mu      sync.Mutex
+	maxWait time.Duration
+	waited  time.Duration
+	aborted bool
+	// until is when the rate limit a request is sleeping for lifts. With
+	// --concurrency, the other requests wait for it too rather than run
+	// into the limit.
+	until time.Time
+}
+
+func newRateLimitWait(maxWait time.Duration) *rateLimitWait {
+	return &rateLimitWait{maxWait: maxWait}
+}
+
+// reset starts the cap over for a new run of a daemon.
+func (w *rateLimitWait) reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.waited, w.aborted, w.until = 0, false, time.Time{}
+}
+
+// take reserves a wait, reporting false, and aborting the run, if it would
+// exceed the cap.
+func (w *rateLimitWait) take(d time.Duration) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.aborted || w.waited+d > w.maxWait {
+		w.aborted = true
+		return false
+	}
+	w.waited += d
+	return true
+}
+
+// hold makes requests wait until t for a rate limit to lift.
+func (w *rateLimitWait) hold(t time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if t.After(w.until) {
+		w.until = t
+	}
+}
+
+// held returns how long after now requests must wait for a rate limit
+// another request is sleeping for.
+func (w *rateLimitWait) held(now time.Time) time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.until.Sub(now)
+}
+
+// isAborted reports whether the run was aborted for waiting too long.
+func (w *rateLimitWait) isAborted() bool {
+	if w == nil {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.aborted
+}
+
+// total returns the time waited so far.
+func (w *rateLimitWait) total() time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.waited
+}
+
+// rateLimitTransport waits out GitHub rate limits. A request refused for a
+// primary rate limit is retried once it resets and one refused for a
+// secondary rate limit after its Retry-After. A response that spends the
+// last call of the primary rate limit is held until it resets, as the client
+// would otherwise refuse the next call without making it.
+type rateLimitTransport struct {
+	base   http.RoundTripper
+	limits *rateLimitWait
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for {
+		if t.limits.isAborted() {
+			return nil, errRateLimitWaitExceeded
+		}
+		if held := t.limits.held(time.Now()); held > 0 {
+			if err := sleepContext(req.Context(), held); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return resp, err
+		}
+		wait, reason := rateLimitDelay(resp, time.Now())
+		if wait <= 0 {
+			return resp, nil
+		}
+		refused := resp.StatusCode >= 400
+		if refused && req.Body != nil && req.GetBody == nil {
+			return resp, nil
+		}
+		if !t.limits.take(wait) {
+			slog.Error("waiting out the GitHub rate limit would exceed --max-rate-limit-wait, aborting the run",
+				"reason", reason, "method", req.Method, "path", req.URL.Path, "wait", wait.Round(time.Second), "max_wait", t.limits.maxWait)
+			return resp, nil
+		}
+		slog.Warn("GitHub rate limit hit, sleeping", "reason", reason, "method", req.Method, "path", req.URL.Path, "wait", wait.Round(time.Second))
+		t.limits.hold(time.Now().Add(wait))
+		if err := sleepContext(req.Context(), wait); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		if !refused {
+			return resp, nil
+		}
+		resp.Body.Close()
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// sleepContext sleeps for d, or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// rateLimitDelay returns how long to wait before the next request after a
+// response, and why, or zero if there is no need to.
+func rateLimitDelay(resp *http.Response, now time.Time) (time.Duration, string) {
+	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests {
+		var rateErr *github.RateLimitError
+		var abuseErr *github.AbuseRateLimitError
+		switch err := github.CheckResponse(resp); {
+		case errors.As(err, &rateErr):
+			return rateErr.Rate.Reset.Sub(now) + rateLimitResetSlack, "primary rate limit exceeded"
+		case errors.As(err, &abuseErr) && abuseErr.RetryAfter != nil:
+			return *abuseErr.RetryAfter, "secondary rate limit hit"
+		case errors.As(err, &abuseErr):
+			return defaultSecondaryRateLimitWait, "secondary rate limit hit"
+		case resp.StatusCode == http.StatusTooManyRequests:
+			if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+				return time.Duration(secs) * time.Second, "secondary rate limit hit"
+			}
+			return defaultSecondaryRateLimitWait, "secondary rate limit hit"
+		}
+		return 0, ""
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return 0, ""
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return 0, ""
+	}
+	return time.Unix(reset, 0).Sub(now) + rateLimitResetSlack, "primary rate limit used up"
+}
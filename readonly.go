@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// errReadOnly is returned for writes refused in --read-only mode.
+var errReadOnly = errors.New("blocked write: running in read-only mode")
+
+// writeGuard records and refuses every write when the bot runs in read-only
+// mode. It guards GitHub at the HTTP transport and email at the mailer, so a
+// code path that forgets to check for read-only mode still cannot mutate
+// anything.
+type writeGuard struct {
+	mu       sync.Mutex
+	readOnly bool
+	blocked  []string
+	// dryRun makes the bot report each action it would take instead of
+	// attempting it; see would.
+	dryRun  bool
+	wouldDo map[string]int
+}
+
+// newWriteGuard returns a guard for the run. A dry run is always read-only.
+func newWriteGuard(readOnly, dryRun bool) *writeGuard {
+	return &writeGuard{readOnly: readOnly || dryRun, dryRun: dryRun, wouldDo: map[string]int{}}
+}
+
+// would reports whether an action must be skipped because the run is a dry
+// run. If so it logs what it would do and counts the action by kind.
+func (g *writeGuard) would(out *prOutput, kind, format string, a ...interface{}) bool {
+	if g == nil || !g.dryRun {
+		return false
+	}
+	out.Info("dry run: skipping action", "action", kind, "would", fmt.Sprintf(format, a...))
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.wouldDo[kind]++
+	return true
+}
+
+// wouldCounts returns the number of actions skipped by the dry run per kind.
+func (g *writeGuard) wouldCounts() map[string]int {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	counts := map[string]int{}
+	for k, n := range g.wouldDo {
+		counts[k] = n
+	}
+	return counts
+}
+
+// block records a refused write if the guard is active and reports whether
+// the write must be refused.
+func (g *writeGuard) block(description string) bool {
+	if g == nil || !g.readOnly {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.blocked = append(g.blocked, description)
+	return true
+}
+
+// blockedWrites returns the writes refused so far.
+func (g *writeGuard) blockedWrites() []string {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]string(nil), g.blocked...)
+}
+
+// reset forgets the refused writes and dry-run actions recorded so far, so
+// that each repository's summary lists its own.
+func (g *writeGuard) reset() {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.blocked = nil
+	g.wouldDo = map[string]int{}
+}
+
+// readOnlyTransport refuses every GitHub API request that can change state:
+// anything but GET and HEAD, except GraphQL queries (which are POSTed but
+// only read).
+type readOnlyTransport struct {
+	base  http.RoundTripper
+	guard *writeGuard
+}
+
+func (t *readOnlyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return t.base.RoundTrip(req)
+	}
+	if strings.HasSuffix(req.URL.Path, "/graphql") && req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		if !isGraphQLMutation(body) {
+			return t.base.RoundTrip(req)
+		}
+	}
+	if t.guard.block(fmt.Sprintf("GitHub %s %s", req.Method, req.URL.Path)) {
+		return nil, errReadOnly
+	}
+	return t.base.RoundTrip(req)
+}
+
+// isGraphQLMutation reports whether a GraphQL request body holds a mutation.
+func isGraphQLMutation(body []byte) bool {
+	var q struct {
+		Query string `json:"query"`
+	}
+	if err := json.Unmarshal(body, &q); err != nil {
+		return true
+	}
+	return strings.HasPrefix(strings.TrimSpace(q.Query), "mutation")
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+func TestReadOnlyBlocksMisplumbedWrites(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	_, srv := newTestGitHubClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, strings.TrimSpace(r.Method+" "+r.URL.Path+" "+string(body)))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{}}`))
+	}))
+	guard := newWriteGuard(true, false)
+	budget := newRunBudget(0, 0)
+	client, err := getGithubClient("token", srv.URL+"/", "", "", sshProxyOptions{}, budget, 2, newRateLimitWait(0), guard, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	graphql := func(query string) error {
+		req, err := client.NewRequest(http.MethodPost, "graphql", map[string]string{"query": query})
+		if err != nil {
+			return err
+		}
+		_, err = client.Do(ctx, req, nil)
+		return err
+	}
+
+	// Writes made as if a code path forgot to check for read-only mode.
+	for _, w := range []struct {
+		name  string
+		write func() error
+	}{
+		{"POST", func() error {
+			_, _, err := client.Issues.CreateComment(ctx, "acme", "api", 7, &github.IssueComment{Body: github.Ptr("closing")})
+			return err
+		}},
+		{"PATCH", func() error {
+			_, _, err := client.Issues.Edit(ctx, "acme", "api", 7, &github.IssueRequest{State: github.Ptr("closed")})
+			return err
+		}},
+		{"GraphQL mutation", func() error {
+			return graphql(`mutation { closePullRequest(input: {pullRequestId: "PR_1"}) { clientMutationId } }`)
+		}},
+	} {
+		if err := w.write(); !errors.Is(err, errReadOnly) {
+			t.Errorf("%s returned %v, want the write blocked", w.name, err)
+		}
+	}
+	if err := graphql(`  query { viewer { login } }`); err != nil {
+		t.Errorf("a GraphQL query failed: %v", err)
+	}
+
+	mu.Lock()
+	if want := `POST /graphql {"query":"  query { viewer { login } }"}`; len(received) != 1 || received[0] != want {
+		t.Errorf("the server received %q, want only the query %s", received, want)
+	}
+	mu.Unlock()
+	if calls, _ := budget.usage(); calls != 1 {
+		t.Errorf("charged %d API calls, want 1 for the query", calls)
+	}
+
+	var buf bytes.Buffer
+	logSummary(slog.New(slog.NewTextHandler(&buf, nil)), &runSummary{BlockedWrites: guard.blockedWrites(), Delta: &runDelta{FirstRun: true}})
+	want := `blocked_writes="[GitHub POST /repos/acme/api/issues/7/comments GitHub PATCH /repos/acme/api/issues/7 GitHub POST /graphql]"`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("summary %q does not list the blocked writes %s", buf.String(), want)
+	}
+}
+
+// TestDryRunWritesNothing acts on PRs to warn, close and unwarn in a dry run
+// that notifies by email: nothing is written to GitHub, no SMTP connection
+// is opened, and each skipped action is counted.
+func TestDryRunWritesNothing(t *testing.T) {
+	smtpd := startTestSMTPServer(t, nil, false)
+	gh := &fakeGitHub{}
+	cfg := &config{
+		SMTPServer:          "127.0.0.1",
+		SMTPPort:            smtpd.port(),
+		SMTPFrom:            "bot@example.com",
+		NotifyVia:           notifyEmail,
+		FallbackEmailDomain: "example.com",
+		DisplayLocation:     time.UTC,
+		Rules:               testRules(),
+	}
+	s := newTestRepoScanner(t, gh, cfg, "--dry-run")
+	s.mail.encryption = smtpEncryptionNone
+	old := time.Now().AddDate(0, 0, -45)
+	for _, tc := range []struct {
+		pr   *github.PullRequest
+		want string
+	}{
+		{testPR("alice", old), actionWarn},
+		{testPR("bob", old, "stale-warning"), actionClose},
+		{testPR("carol", time.Now(), "stale-warning"), actionActive},
+	} {
+		if d := s.actOn(tc.pr); d.Action != tc.want {
+			t.Errorf("@%s's PR decided %s, want %s", tc.pr.GetUser().GetLogin(), d.Action, tc.want)
+		}
+	}
+
+	if got := gh.written(); len(got) != 0 {
+		t.Errorf("a dry run wrote %q", got)
+	}
+	smtpd.mu.Lock()
+	opened := smtpd.maxOpen
+	smtpd.mu.Unlock()
+	if opened != 0 {
+		t.Errorf("a dry run opened %d SMTP connections", opened)
+	}
+	if got := s.guard.wouldCounts(); fmt.Sprint(got) != "map[close:1 unwarn:1 warn:1]" {
+		t.Errorf("would-be actions %v, want one warning, closure and label removal", got)
+	}
+	if len(s.summary.Warned) != 0 || len(s.summary.Closed) != 0 || s.summary.Emails != 0 {
+		t.Errorf("a dry run counted %d warned, %d closed and %d emails as real", len(s.summary.Warned), len(s.summary.Closed), s.summary.Emails)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// parseReminders parses a comma-separated list of reminder offsets before the
+// closure deadline, e.g. "10d,3d,1d", returned largest first.
+func parseReminders(s string) ([]time.Duration, error) {
+	var offsets []time.Duration
+	seen := map[time.Duration]bool{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		d, err := parseDays(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reminder offset %q: %v", entry, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("invalid reminder offset %q: must be positive", entry)
+		}
+		if !seen[d] {
+			seen[d] = true
+			offsets = append(offsets, d)
+		}
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] > offsets[j] })
+	return offsets, nil
+}
+
+// reminderKey identifies a reminder offset in the state file.
+func reminderKey(offset time.Duration) string {
+	if offset%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", offset/(24*time.Hour))
+	}
+	return offset.String()
+}
+
+// dueReminder returns the reminder offset that is due for a PR with the given
+// closure deadline, and every due offset to mark as sent. When several
+// reminders became due since the last run only the latest one is sent.
+func dueReminder(offsets []time.Duration, sent []string, deadline, now time.Time) (time.Duration, []string) {
+	if !now.Before(deadline) {
+		return 0, nil
+	}
+	already := map[string]bool{}
+	for _, k := range sent {
+		already[k] = true
+	}
+	var due time.Duration
+	var keys []string
+	for _, offset := range offsets {
+		k := reminderKey(offset)
+		if already[k] || now.Before(deadline.Add(-offset)) {
+			continue
+		}
+		due = offset
+		keys = append(keys, k)
+	}
+	return due, keys
+}
+
+// dueReminders returns the warned PRs that have a reminder due.
+func dueReminders(rs *repoState, offsets []time.Duration, now time.Time) []int {
+	var numbers []int
+	for number, deadline := range rs.Deadlines {
+		if _, keys := dueReminder(offsets, rs.Reminders[number], deadline, now); len(keys) > 0 {
+			numbers = append(numbers, number)
+		}
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+// forget drops everything tracked for a PR that is no longer warned.
+func (rs *repoState) forget(number int) {
+	delete(rs.Deadlines, number)
+	delete(rs.Reminders, number)
+	delete(rs.TitlePrefixed, number)
+}
+
+func remindPRAuthor(out *prOutput, pr *github.PullRequest, data notificationData, mail *mailer) error {
+	subject := fmt.Sprintf("Reminder: your %s #%d will be closed in %d %s", data.Kind, pr.GetNumber(), data.DaysRemaining, pluralize(data.DaysRemaining, "day", "days"))
+	if data.PathProtected {
+		subject = fmt.Sprintf("Reminder: your %s #%d is stale", data.Kind, pr.GetNumber())
+	}
+	mail.greet(out, pr.GetUser(), &data)
+	body, err := mail.templates.render("reminder email", reminderEmailTemplate, data)
+	if err != nil {
+		return err
+	}
+
+	return mail.notify(out, pr, data, subject, body)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+func TestParseReminders(t *testing.T) {
+	day := 24 * time.Hour
+	for _, tc := range []struct {
+		in      string
+		want    []time.Duration
+		wantErr string
+	}{
+		{in: "1d, 10d,3d", want: []time.Duration{10 * day, 3 * day, day}},
+		{in: "3d,3d,12h", want: []time.Duration{3 * day, 12 * time.Hour}},
+		{in: "", want: nil},
+		{in: "0d", wantErr: "must be positive"},
+		{in: "3d,soon", wantErr: `invalid reminder offset "soon"`},
+	} {
+		got, err := parseReminders(tc.in)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("parseReminders(%q) returned %v, want an error containing %q", tc.in, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil || fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("parseReminders(%q) = %v, %v, want %v", tc.in, got, err, tc.want)
+		}
+	}
+}
+
+// TestDueReminderSchedule runs the bot at a fixed interval from the warning
+// to past the closure deadline and checks that each reminder offset is
+// marked sent exactly once. Offsets that fall between two runs are not sent
+// separately: only the latest due one is.
+func TestDueReminderSchedule(t *testing.T) {
+	day := 24 * time.Hour
+	offsets := []time.Duration{10 * day, 3 * day, day}
+	warned := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	deadline := warned.Add(14 * day)
+	for _, tc := range []struct {
+		every time.Duration
+		want  string
+	}{
+		{every: time.Hour, want: "[10d 3d 1d]"},
+		{every: day, want: "[10d 3d 1d]"},
+		// The first run after the warning is a day before the deadline, when
+		// all three reminders are due: only the last one is sent.
+		{every: 13 * day, want: "[1d]"},
+	} {
+		var sent []string
+		marked := map[string]int{}
+		// The warning marks nothing: no offset is due 14 days ahead.
+		_, state := dueReminder(offsets, nil, deadline, warned)
+		for now := warned.Add(tc.every); now.Before(deadline.Add(2 * tc.every)); now = now.Add(tc.every) {
+			offset, keys := dueReminder(offsets, state, deadline, now)
+			if len(keys) == 0 {
+				continue
+			}
+			sent = append(sent, reminderKey(offset))
+			for _, k := range keys {
+				marked[k]++
+			}
+			state = append(state, keys...)
+		}
+		if fmt.Sprint(sent) != tc.want {
+			t.Errorf("runs every %v sent reminders %v, want %s", tc.every, sent, tc.want)
+		}
+		for _, offset := range offsets {
+			if k := reminderKey(offset); marked[k] != 1 {
+				t.Errorf("runs every %v marked the %s reminder sent %d times, want once", tc.every, k, marked[k])
+			}
+		}
+	}
+}
+
+func TestDueReminderWhenWarnedLate(t *testing.T) {
+	day := 24 * time.Hour
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	// A warning period shorter than the first offset: the reminders already
+	// due are marked sent by the warning itself.
+	offset, keys := dueReminder([]time.Duration{10 * day, 3 * day, day}, nil, now.Add(5*day), now)
+	if offset != 10*day || fmt.Sprint(keys) != "[10d]" {
+		t.Errorf("dueReminder 5 days before the deadline = %v, %v, want 240h0m0s, [10d]", offset, keys)
+	}
+	if _, keys := dueReminder([]time.Duration{day}, nil, now, now); keys != nil {
+		t.Errorf("dueReminder at the deadline returned %v, want nothing", keys)
+	}
+}
+
+// TestRemindSendsOncePerOffset runs remind as the deadline of a warned PR
+// draws near and checks that the SMTP server receives one reminder per
+// offset, stating the days remaining.
+func TestRemindSendsOncePerOffset(t *testing.T) {
+	day := 24 * time.Hour
+	smtp := startTestSMTPServer(t, nil, false)
+	s := newEmailTestScanner(t, smtp.port(), 3)
+	stateFile := ""
+	s.flags.stateFile = &stateFile
+	s.reminders = []time.Duration{10 * day, 3 * day, day}
+	pr := &github.PullRequest{Number: github.Ptr(7), State: github.Ptr("open"), Title: github.Ptr("Add caching"), User: &github.User{Login: github.Ptr("alice")}}
+
+	// Each run happens with less time left until the deadline.
+	for _, left := range []time.Duration{12 * day, 9*day + 12*time.Hour, 9 * day, 2*day + 12*time.Hour, 2 * day, 12 * time.Hour, 6 * time.Hour} {
+		s.repoSt.Deadlines[7] = time.Now().Add(left)
+		data := newNotificationData(s.cfg, pr, s.owner, s.repo, time.Now())
+		data.Kind = "pull request"
+		s.remind(&prOutput{}, pr, prDecision{}, data)
+	}
+
+	received, _ := smtp.emails()
+	var subjects []string
+	for _, email := range received {
+		for _, line := range strings.Split(email, "\n") {
+			if subject, ok := strings.CutPrefix(strings.TrimSpace(line), "Subject: "); ok {
+				subjects = append(subjects, subject)
+			}
+		}
+	}
+	want := []string{
+		"Reminder: your pull request #7 will be closed in 10 days",
+		"Reminder: your pull request #7 will be closed in 3 days",
+		"Reminder: your pull request #7 will be closed in 1 day",
+	}
+	if fmt.Sprint(subjects) != fmt.Sprint(want) {
+		t.Errorf("reminders sent %q, want %q", subjects, want)
+	}
+	if got := s.repoSt.Reminders[7]; fmt.Sprint(got) != "[10d 3d 1d]" {
+		t.Errorf("reminders recorded %v, want [10d 3d 1d]", got)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// canonicalRepo returns the current owner and name of a repository. They
+// differ from the configured ones if the repository was renamed or
+// transferred, in which case GitHub redirects requests made to the old name.
+func canonicalRepo(client *github.Client, owner, repo string) (string, string, error) {
+	r, _, err := client.Repositories.Get(context.Background(), owner, repo)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to look up repository %s/%s: %v", owner, repo, err)
+	}
+	newOwner, newRepo, ok := strings.Cut(r.GetFullName(), "/")
+	if !ok {
+		return owner, repo, nil
+	}
+	return newOwner, newRepo, nil
+}
+
+// isRenamed reports whether the canonical name differs from the configured
+// one. GitHub names are case-insensitive.
+func isRenamed(owner, repo, newOwner, newRepo string) bool {
+	return !strings.EqualFold(owner+"/"+repo, newOwner+"/"+newRepo)
+}
+
+// renameRepo moves the state of a renamed repository to its new name, unless
+// state already exists under the new name.
+func (s *botState) renameRepo(oldOwner, oldRepo, newOwner, newRepo string) {
+	oldKey, newKey := oldOwner+"/"+oldRepo, newOwner+"/"+newRepo
+	rs, ok := s.Repos[oldKey]
+	if !ok {
+		return
+	}
+	if _, exists := s.Repos[newKey]; !exists {
+		s.Repos[newKey] = rs
+	}
+	delete(s.Repos, oldKey)
+}
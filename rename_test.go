@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsRenamed(t *testing.T) {
+	for _, tc := range []struct {
+		owner, repo, newOwner, newRepo string
+		want                           bool
+	}{
+		{"acme", "api", "acme", "api", false},
+		{"Acme", "API", "acme", "api", false},
+		{"acme", "api", "acme", "api-server", true},
+		{"acme", "api", "acme-platform", "api", true},
+	} {
+		if got := isRenamed(tc.owner, tc.repo, tc.newOwner, tc.newRepo); got != tc.want {
+			t.Errorf("isRenamed(%s/%s, %s/%s) = %v, want %v", tc.owner, tc.repo, tc.newOwner, tc.newRepo, got, tc.want)
+		}
+	}
+}
+
+func TestRenameRepoState(t *testing.T) {
+	warned := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	state := newBotState()
+	state.repo("acme", "old-api").Deadlines[7] = warned
+	state.renameRepo("acme", "old-api", "acme-platform", "api")
+	if _, ok := state.Repos["acme/old-api"]; ok {
+		t.Error("the state is still kept under the old name")
+	}
+	if got := state.Repos["acme-platform/api"]; got == nil || !got.Deadlines[7].Equal(warned) {
+		t.Errorf("state under the new name %+v, want the deadline of #7 moved", got)
+	}
+
+	// State already kept under the new name wins over the old one.
+	state.repo("acme", "old-api").Deadlines[9] = warned
+	state.renameRepo("acme", "old-api", "acme-platform", "api")
+	if rs := state.Repos["acme-platform/api"]; len(rs.Deadlines) != 1 || len(state.Repos) != 1 {
+		t.Errorf("state %v after a second rename, want only the state under the new name", state.Repos)
+	}
+	// Renaming a repository without state does nothing.
+	state.renameRepo("acme", "web", "acme-platform", "web")
+	if len(state.Repos) != 1 {
+		t.Errorf("state %v, want no state created for a repository without any", state.Repos)
+	}
+}
+
+// TestScanRenamedRepository scans a repository that was transferred: GitHub
+// redirects its old name to the canonical one. Without --follow-renames the
+// repository is skipped; with it, its state moves to the new name and the PR
+// past its deadline is closed there.
+func TestScanRenamedRepository(t *testing.T) {
+	updated := time.Now().AddDate(0, 0, -60).UTC().Format(time.RFC3339)
+	pr := `{"number":7,"state":"open","title":"Add caching","user":{"login":"alice"},"labels":[{"name":"stale-warning"}],"updated_at":"` + updated + `"}`
+	for _, follow := range []bool{false, true} {
+		t.Run(fmt.Sprintf("follow-renames=%v", follow), func(t *testing.T) {
+			gh := &fakeGitHub{
+				redirects: map[string]string{"/repos/acme/old-api": "/repositories/99"},
+				get: map[string]string{
+					"/repositories/99":                 `{"id":99,"full_name":"acme-platform/api"}`,
+					"/repos/acme-platform/api/pulls":   "[" + pr + "]",
+					"/repos/acme-platform/api/pulls/7": pr,
+					"/search/issues":                   `{"total_count":5}`,
+				},
+			}
+			s := newTestRepoScanner(t, gh, nil, fmt.Sprintf("--follow-renames=%v", follow))
+			deadline := time.Now().AddDate(0, 0, -1)
+			s.state.repo("acme", "old-api").Deadlines[7] = deadline
+
+			var result repoResult
+			s.scanRepo("acme", "old-api", &result)
+			writes := gh.written()
+			if !follow {
+				if result.Err == nil || result.Err.Error() != "renamed to acme-platform/api" || len(writes) != 0 {
+					t.Errorf("scan returned %v and wrote %q, want the repository skipped as renamed", result.Err, writes)
+				}
+				if _, ok := s.state.Repos["acme/old-api"]; !ok {
+					t.Error("the state of a skipped repository was moved")
+				}
+				return
+			}
+			if result.Err != nil || result.Name != "acme-platform/api" {
+				t.Fatalf("scan of %s returned %v, want the new name scanned", result.Name, result.Err)
+			}
+			if !strings.Contains(fmt.Sprint(writes), `PATCH /repos/acme-platform/api/issues/7 {"state":"closed"}`) {
+				t.Errorf("writes %q, want the PR past its deadline closed under the new name", writes)
+			}
+			for _, w := range writes {
+				if !strings.Contains(w, "/repos/acme-platform/api/") {
+					t.Errorf("wrote %q, want every write made under the new name", w)
+				}
+			}
+			if _, ok := s.state.Repos["acme/old-api"]; ok {
+				t.Error("the state is still kept under the old name")
+			}
+		})
+	}
+}
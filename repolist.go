@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// repoFilter is a repository allow/deny list. Entries are globs matched
+// against the repository name, or against "owner/name" if they contain a "/".
+type repoFilter struct {
+	Allow []string
+	Deny  []string
+}
+
+// loadRepoFilter reads a repository list file with one "allow <glob>" or
+// "deny <glob>" entry per line; a bare glob is an allow entry. Blank lines
+// and "#" comments are ignored, and malformed lines are reported and
+// skipped.
+func loadRepoFilter(file string) (repoFilter, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return repoFilter{}, fmt.Errorf("failed to open repository list: %v", err)
+	}
+	defer f.Close()
+
+	var filter repoFilter
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		kind, glob := "allow", fields[0]
+		if len(fields) == 2 {
+			kind, glob = strings.ToLower(fields[0]), fields[1]
+		}
+		if len(fields) > 2 || (kind != "allow" && kind != "deny") {
+			slog.Warn("expected \"allow <glob>\" or \"deny <glob>\", ignoring line", "file", file, "line", n)
+			continue
+		}
+		if _, err := path.Match(glob, ""); err != nil {
+			slog.Warn("invalid glob, ignoring line", "file", file, "line", n, "glob", glob)
+			continue
+		}
+		if kind == "deny" {
+			filter.Deny = append(filter.Deny, glob)
+		} else {
+			filter.Allow = append(filter.Allow, glob)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return repoFilter{}, fmt.Errorf("failed to read repository list: %v", err)
+	}
+	return filter, nil
+}
+
+// allows reports whether owner/repo may be scanned: it matches no deny entry
+// and, if there are allow entries, at least one of them. Deny wins over
+// allow.
+func (f repoFilter) allows(owner, repo string) bool {
+	if matchRepoGlob(f.Deny, owner, repo) {
+		return false
+	}
+	return len(f.Allow) == 0 || matchRepoGlob(f.Allow, owner, repo)
+}
+
+// apply returns the repositories of owner the filter allows, in order, and
+// those it excludes.
+func (f repoFilter) apply(owner string, repos []string) (allowed, excluded []string) {
+	for _, r := range repos {
+		if f.allows(owner, r) {
+			allowed = append(allowed, r)
+		} else {
+			excluded = append(excluded, r)
+		}
+	}
+	return allowed, excluded
+}
+
+func matchRepoGlob(globs []string, owner, repo string) bool {
+	for _, g := range globs {
+		name := repo
+		if strings.Contains(g, "/") {
+			name = owner + "/" + repo
+		}
+		if ok, _ := path.Match(strings.ToLower(g), strings.ToLower(name)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// repoList is the repositories of --repo that the --repo-list-file allows.
+// A daemon reloads it before each run, so edits to the file take effect
+// without a restart.
+type repoList struct {
+	file  string
+	owner string
+	// given is the repositories of --repo, before filtering.
+	given []string
+	// repos is the repositories the file allowed when last read.
+	repos []string
+}
+
+// loadRepoList reads the repository list file and filters repos with it.
+func loadRepoList(file, owner string, repos []string) (*repoList, error) {
+	l := &repoList{file: file, owner: owner, given: repos}
+	filter, err := loadRepoFilter(file)
+	if err != nil {
+		return nil, err
+	}
+	var excluded []string
+	l.repos, excluded = filter.apply(owner, repos)
+	if len(excluded) > 0 {
+		slog.Info("repositories excluded by the repository list", "file", file, "repos", excluded)
+	}
+	return l, nil
+}
+
+// reload reads the repository list file again and returns the repositories
+// to scan. If the file cannot be read the previous list is kept.
+func (l *repoList) reload() []string {
+	filter, err := loadRepoFilter(l.file)
+	if err != nil {
+		slog.Error("reloading the repository list failed, keeping the previous list", "file", l.file, "err", err)
+		return l.repos
+	}
+	repos, _ := filter.apply(l.owner, l.given)
+	added, removed := diffRepos(l.repos, repos), diffRepos(repos, l.repos)
+	if len(added) > 0 || len(removed) > 0 {
+		slog.Info("repository list changed", "file", l.file, "added", added, "removed", removed)
+	}
+	if len(repos) == 0 {
+		slog.Warn("no repositories left to scan", "file", l.file)
+	}
+	l.repos = repos
+	return repos
+}
+
+// diffRepos returns the repositories of to that are not in from.
+func diffRepos(from, to []string) []string {
+	var diff []string
+	for _, r := range to {
+		if !slices.Contains(from, r) {
+			diff = append(diff, r)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadRepoFilter(t *testing.T) {
+	logs := captureLog(t)
+	filter, err := loadRepoFilter(writeTestFile(t, "repos.txt", `# incident 1234: skip billing until it is stable
+deny billing        # temporarily
+allow api-*
+ALLOW acme/web
+web-legacy
+block tools
+allow [unclosed
+allow a b
+deny Other/*
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := repoFilter{Allow: []string{"api-*", "acme/web", "web-legacy"}, Deny: []string{"billing", "Other/*"}}
+	if fmt.Sprint(filter) != fmt.Sprint(want) {
+		t.Errorf("loaded %+v, want %+v", filter, want)
+	}
+	for _, want := range []string{"line=6", "line=7", "line=8"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("the malformed %s was not reported:\n%s", want, logs)
+		}
+	}
+	if _, err := loadRepoFilter("testdata/no-such-repos.txt"); err == nil {
+		t.Error("loading a missing file succeeded")
+	}
+}
+
+func TestRepoFilterAllows(t *testing.T) {
+	for _, tc := range []struct {
+		filter repoFilter
+		repo   string
+		want   bool
+	}{
+		{filter: repoFilter{}, repo: "api", want: true},
+		{filter: repoFilter{Allow: []string{"api-*"}}, repo: "api-gateway", want: true},
+		{filter: repoFilter{Allow: []string{"api-*"}}, repo: "web", want: false},
+		{filter: repoFilter{Deny: []string{"legacy-*"}}, repo: "legacy-web", want: false},
+		{filter: repoFilter{Deny: []string{"legacy-*"}}, repo: "web", want: true},
+		// Deny wins over allow, whichever entry is more specific.
+		{filter: repoFilter{Allow: []string{"legacy-web"}, Deny: []string{"legacy-*"}}, repo: "legacy-web", want: false},
+		{filter: repoFilter{Allow: []string{"*"}, Deny: []string{"acme/billing"}}, repo: "billing", want: false},
+		// Entries with a "/" match the owner too, ignoring case.
+		{filter: repoFilter{Allow: []string{"acme/*"}}, repo: "Web", want: true},
+		{filter: repoFilter{Allow: []string{"other/*"}}, repo: "web", want: false},
+		{filter: repoFilter{Deny: []string{"ACME/API"}}, repo: "api", want: false},
+		{filter: repoFilter{Allow: []string{"*/api"}}, repo: "api", want: true},
+	} {
+		if got := tc.filter.allows("acme", tc.repo); got != tc.want {
+			t.Errorf("%+v allows acme/%s = %v, want %v", tc.filter, tc.repo, got, tc.want)
+		}
+	}
+}
+
+func TestRepoListReload(t *testing.T) {
+	logs := captureLog(t)
+	file := writeTestFile(t, "repos.txt", "deny legacy-*\n")
+	given := []string{"api", "legacy-web", "web"}
+	l, err := loadRepoList(file, "acme", given)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"api", "web"}; !slices.Equal(l.repos, want) {
+		t.Fatalf("loaded %v, want %v", l.repos, want)
+	}
+
+	if err := os.WriteFile(file, []byte("allow legacy-*\nallow api\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := l.reload(), []string{"api", "legacy-web"}; !slices.Equal(got, want) {
+		t.Errorf("after editing the file reload returned %v, want %v", got, want)
+	}
+	if !strings.Contains(logs.String(), `msg="repository list changed"`) || !strings.Contains(logs.String(), "added=[legacy-web] removed=[web]") {
+		t.Errorf("the change was not logged:\n%s", logs)
+	}
+
+	if err := os.WriteFile(file, []byte("deny *\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.reload(); len(got) != 0 || !strings.Contains(logs.String(), "no repositories left to scan") {
+		t.Errorf("denying every repository reload returned %v, want none and a warning:\n%s", got, logs)
+	}
+	if err := os.WriteFile(file, []byte("allow legacy-*\nallow api\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l.reload()
+
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := l.reload(), []string{"api", "legacy-web"}; !slices.Equal(got, want) {
+		t.Errorf("with the file gone reload returned %v, want the previous list %v", got, want)
+	}
+}
+
+func TestDiffRepos(t *testing.T) {
+	for _, tc := range []struct {
+		from, to, want []string
+	}{
+		{nil, nil, nil},
+		{[]string{"a"}, []string{"a"}, nil},
+		{[]string{"a"}, []string{"a", "b"}, []string{"b"}},
+		{[]string{"a", "b"}, []string{"c"}, []string{"c"}},
+	} {
+		if got := diffRepos(tc.from, tc.to); !slices.Equal(got, tc.want) {
+			t.Errorf("diffRepos(%v, %v) = %v, want %v", tc.from, tc.to, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// GitHub owner and repository names: owners are alphanumerics and hyphens,
+// repository names also allow dots and underscores.
+var (
+	ownerNamePattern = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?$`)
+	repoNamePattern  = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+)
+
+// repoRef is a repository given on the command line. Owner is empty when
+// only the name was given.
+type repoRef struct {
+	Owner string
+	Name  string
+}
+
+// parseRepoRef parses a repository given as "name", "owner/name" or the URL
+// of the repository, or of one of its pages, on GitHub or a GitHub
+// Enterprise host, e.g. https://github.example.com/owner/name/pull/7.
+// Whitespace and a trailing ".git" are rejected rather than guessed around.
+func parseRepoRef(s string) (repoRef, error) {
+	if s == "" {
+		return repoRef{}, fmt.Errorf("empty repository")
+	}
+	if strings.IndexFunc(s, func(r rune) bool { return r == ' ' || r == '\t' || r == '\n' || r == '\r' }) >= 0 {
+		return repoRef{}, fmt.Errorf("repository %q contains whitespace", s)
+	}
+	var parts []string
+	if strings.Contains(s, "://") {
+		u, err := url.Parse(s)
+		if err != nil {
+			return repoRef{}, fmt.Errorf("invalid repository URL %q: %v", s, err)
+		}
+		if u.Scheme != "https" && u.Scheme != "http" {
+			return repoRef{}, fmt.Errorf("repository URL %q is not an http(s) URL", s)
+		}
+		parts = strings.Split(strings.Trim(u.Path, "/"), "/")
+		if len(parts) < 2 {
+			return repoRef{}, fmt.Errorf("repository URL %q does not name an owner and a repository", s)
+		}
+		parts = parts[:2]
+	} else {
+		parts = strings.Split(s, "/")
+		if len(parts) > 2 {
+			return repoRef{}, fmt.Errorf("repository %q is not \"name\" or \"owner/name\"", s)
+		}
+	}
+	var ref repoRef
+	if len(parts) == 2 {
+		ref.Owner = parts[0]
+		if !ownerNamePattern.MatchString(ref.Owner) {
+			return repoRef{}, fmt.Errorf("invalid owner %q in repository %q", ref.Owner, s)
+		}
+	}
+	ref.Name = parts[len(parts)-1]
+	if strings.HasSuffix(ref.Name, ".git") {
+		return repoRef{}, fmt.Errorf("repository %q ends in \".git\"; give the repository name without it", s)
+	}
+	if !repoNamePattern.MatchString(ref.Name) || ref.Name == "." || ref.Name == ".." {
+		return repoRef{}, fmt.Errorf("invalid repository name %q in %q", ref.Name, s)
+	}
+	return ref, nil
+}
+
+// resolveRepos parses the repositories given with --repo, all of which must
+// belong to one owner. The owner is derived from them if --owner is empty,
+// and cross-checked against it otherwise. It returns the owner and the
+// repository names.
+func resolveRepos(owner string, entries []string) (string, []string, error) {
+	if owner != "" && !ownerNamePattern.MatchString(owner) {
+		return "", nil, fmt.Errorf("invalid owner %q: give the user or organization name, not a URL or owner/name", owner)
+	}
+	ownerFrom := "--owner"
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ref, err := parseRepoRef(entry)
+		if err != nil {
+			return "", nil, err
+		}
+		switch {
+		case ref.Owner == "":
+		case owner == "":
+			owner, ownerFrom = ref.Owner, entry
+		case !strings.EqualFold(ref.Owner, owner):
+			return "", nil, fmt.Errorf("repository %q belongs to %s, but the owner is %s (from %s); one run scans the repositories of one owner", entry, ref.Owner, owner, ownerFrom)
+		}
+		names = append(names, ref.Name)
+	}
+	return owner, names, nil
+}
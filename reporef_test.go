@@ -0,0 +1,99 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseRepoRef(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want repoRef
+		err  string
+	}{
+		{in: "api", want: repoRef{Name: "api"}},
+		{in: "acme/api", want: repoRef{Owner: "acme", Name: "api"}},
+		{in: "acme-corp/my_repo.js", want: repoRef{Owner: "acme-corp", Name: "my_repo.js"}},
+		{in: "Acme/API", want: repoRef{Owner: "Acme", Name: "API"}},
+		{in: "https://github.com/acme/api", want: repoRef{Owner: "acme", Name: "api"}},
+		{in: "http://github.com/acme/api", want: repoRef{Owner: "acme", Name: "api"}},
+		{in: "https://github.com/acme/api/", want: repoRef{Owner: "acme", Name: "api"}},
+		{in: "https://github.com/acme/api/pull/7", want: repoRef{Owner: "acme", Name: "api"}},
+		{in: "https://github.com/acme/api?tab=readme", want: repoRef{Owner: "acme", Name: "api"}},
+		{in: "https://github.example.com/acme/api/tree/main/docs", want: repoRef{Owner: "acme", Name: "api"}},
+		{in: "", err: "empty repository"},
+		{in: " acme/api", err: "contains whitespace"},
+		{in: "acme/api\n", err: "contains whitespace"},
+		{in: "acme/\tapi", err: "contains whitespace"},
+		{in: "acme/my api", err: "contains whitespace"},
+		{in: "acme/api.git", err: `ends in ".git"`},
+		{in: "api.git", err: `ends in ".git"`},
+		{in: "https://github.com/acme/api.git", err: `ends in ".git"`},
+		{in: "acme/api/pull", err: `is not "name" or "owner/name"`},
+		{in: "acme/", err: "invalid repository name"},
+		{in: "/api", err: "invalid owner"},
+		{in: "-acme/api", err: "invalid owner"},
+		{in: "acme-/api", err: "invalid owner"},
+		{in: "ac_me/api", err: "invalid owner"},
+		{in: "git@github.com:acme/api", err: "invalid owner"},
+		{in: "acme/.", err: "invalid repository name"},
+		{in: "acme/..", err: "invalid repository name"},
+		{in: "acme/api!", err: "invalid repository name"},
+		{in: "https://github.com/acme", err: "does not name an owner and a repository"},
+		{in: "https://github.com/", err: "does not name an owner and a repository"},
+		{in: "ftp://github.com/acme/api", err: "is not an http(s) URL"},
+		{in: "https://github.com/acme/api%zz", err: "invalid repository URL"},
+	} {
+		got, err := parseRepoRef(tc.in)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("parseRepoRef(%q) = %+v, %v; want an error containing %q", tc.in, got, err, tc.err)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("parseRepoRef(%q) = %+v, %v; want %+v", tc.in, got, err, tc.want)
+		}
+	}
+}
+
+func TestResolveRepos(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		owner     string
+		entries   []string
+		wantOwner string
+		wantNames []string
+		err       string
+	}{
+		{name: "no repositories", owner: "acme", wantOwner: "acme", wantNames: []string{}},
+		{name: "names", owner: "acme", entries: []string{"api", "web"}, wantOwner: "acme", wantNames: []string{"api", "web"}},
+		{name: "owner from a repository", entries: []string{"api", "acme/web"}, wantOwner: "acme", wantNames: []string{"api", "web"}},
+		{name: "owner from a URL", entries: []string{"https://github.com/acme/api/pull/7"}, wantOwner: "acme", wantNames: []string{"api"}},
+		{name: "no owner anywhere", entries: []string{"api"}, wantNames: []string{"api"}},
+		{name: "owner matches", owner: "acme", entries: []string{"acme/api"}, wantOwner: "acme", wantNames: []string{"api"}},
+		{name: "owner matches ignoring case", owner: "acme", entries: []string{"ACME/api"}, wantOwner: "acme", wantNames: []string{"api"}},
+		{name: "owner differs", owner: "acme", entries: []string{"other/api"}, err: "belongs to other, but the owner is acme (from --owner)"},
+		{name: "repositories differ", entries: []string{"acme/api", "other/web"}, err: "belongs to other, but the owner is acme (from acme/api)"},
+		{name: "owner given as a URL", owner: "https://github.com/acme", err: "invalid owner"},
+		{name: "owner given as owner/name", owner: "acme/api", err: "invalid owner"},
+		{name: "invalid repository", owner: "acme", entries: []string{"api", "web.git"}, err: `ends in ".git"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			owner, names, err := resolveRepos(tc.owner, tc.entries)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("resolveRepos returned %v, want an error containing %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if owner != tc.wantOwner || !slices.Equal(names, tc.wantNames) {
+				t.Errorf("resolveRepos = %q, %q; want %q, %q", owner, names, tc.wantOwner, tc.wantNames)
+			}
+		})
+	}
+}
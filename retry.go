@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// Kinds of notification emails that can be queued for retry.
+const (
+	notificationWarning = "warning"
+	notificationClosure = "closure"
+)
+
+// sentNotificationRetention is how long idempotency keys of delivered
+// notifications are kept in the state file.
+const sentNotificationRetention = 90 * 24 * time.Hour
+
+// pendingNotification is a notification email that failed and is retried at
+// the start of the following runs.
+type pendingNotification struct {
+	// Key identifies the notification; a key present in the sent set is
+	// never delivered again.
+	Key         string    `json:"key"`
+	Kind        string    `json:"kind"`
+	Number      int       `json:"number"`
+	Recipient   string    `json:"recipient"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error"`
+	LastAttempt time.Time `json:"last_attempt"`
+	// Issue is set for notifications about issues rather than PRs.
+	Issue bool `json:"issue,omitempty"`
+	// CloseReason is the close reason of a closure notification, which
+	// selects its variant.
+	CloseReason string `json:"close_reason,omitempty"`
+}
+
+// notificationKey returns the idempotency key of a notification. It changes
+// when the PR is updated, so a PR that goes stale again is notified again.
+func notificationKey(kind string, pr *github.PullRequest) string {
+	return fmt.Sprintf("%s#%d@%d", kind, pr.GetNumber(), pr.GetUpdatedAt().Unix())
+}
+
+// queueNotification records a failed attempt at notification n, adding it
+// to the retry queue or updating the queued entry with the same key, and
+// returns the queued entry.
+func (rs *repoState) queueNotification(n pendingNotification, sendErr error, now time.Time) pendingNotification {
+	for i := range rs.Pending {
+		if rs.Pending[i].Key == n.Key {
+			rs.Pending[i].Attempts++
+			rs.Pending[i].LastError = sendErr.Error()
+			rs.Pending[i].LastAttempt = now
+			return rs.Pending[i]
+		}
+	}
+	n.Attempts = 1
+	n.LastError = sendErr.Error()
+	n.LastAttempt = now
+	rs.Pending = append(rs.Pending, n)
+	return n
+}
+
+// dropNotification removes the notification with the given key from the
+// retry queue.
+func (rs *repoState) dropNotification(key string) {
+	kept := rs.Pending[:0]
+	for _, n := range rs.Pending {
+		if n.Key != key {
+			kept = append(kept, n)
+		}
+	}
+	rs.Pending = kept
+}
+
+// newPendingNotification describes a notification of the given kind for pr.
+func newPendingNotification(kind string, pr *github.PullRequest) pendingNotification {
+	return pendingNotification{
+		Key:       notificationKey(kind, pr),
+		Kind:      kind,
+		Number:    pr.GetNumber(),
+		Recipient: pr.GetUser().GetLogin(),
+	}
+}
+
+// hasPendingNotification reports whether a notification for the PR is queued
+// for retry.
+func (rs *repoState) hasPendingNotification(number int) bool {
+	for _, n := range rs.Pending {
+		if n.Number == number {
+			return true
+		}
+	}
+	return false
+}
+
+// markNotificationSent records a delivered notification and forgets keys
+// older than the retention period.
+func (rs *repoState) markNotificationSent(key string, now time.Time) {
+	rs.Sent[key] = now
+	for k, t := range rs.Sent {
+		if now.Sub(t) > sentNotificationRetention {
+			delete(rs.Sent, k)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// newEmailTestScanner returns a scanner for acme/api that emails authors
+// through an SMTP server on port, and whose PR #7, by @alice, is closed.
+func newEmailTestScanner(t *testing.T, port, maxAttempts int) *repoScanner {
+	t.Helper()
+	captureLog(t)
+	client, _ := newTestGitHubClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/acme/api/pulls/7" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"number":7,"state":"closed","title":"Add caching","user":{"login":"alice"},"updated_at":"2026-03-01T10:00:00Z"}`)
+	}))
+	cfg := &config{
+		SMTPServer:          "127.0.0.1",
+		SMTPPort:            port,
+		SMTPFrom:            "bot@example.com",
+		NotifyVia:           notifyEmail,
+		FallbackEmailDomain: "example.com",
+		DisplayLocation:     time.UTC,
+		Rules:               testRules(),
+	}
+	guard := newWriteGuard(false, false)
+	mail := newMailer(cfg, newTemplateRenderer(cfg), guard, nil)
+	mail.encryption = smtpEncryptionNone
+	mail.budget = newRunBudget(0, 0)
+	quiet, logFormat := true, logFormatText
+	rs := &repoState{}
+	rs.initMaps()
+	return &repoScanner{
+		scanner: &scanner{
+			cfg:    cfg,
+			client: client,
+			mail:   mail,
+			guard:  guard,
+			budget: mail.budget,
+			output: &bytes.Buffer{},
+			flags:  &cliFlags{quiet: &quiet, logFormat: &logFormat, notificationMaxAttempts: &maxAttempts},
+		},
+		owner:    "acme",
+		repo:     "api",
+		repoName: "acme/api",
+		repoSt:   rs,
+		issues:   map[int]bool{},
+		logger:   slog.Default(),
+		summary:  &runSummary{},
+	}
+}
+
+// closedPort returns a local port nothing listens on.
+func closedPort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	return port
+}
+
+// TestRetryNotificationAfterSMTPOutage fails a closure email while the SMTP
+// server is down, then retries it on the next runs: once while the server is
+// still down and once after it recovered. The email is delivered exactly once.
+func TestRetryNotificationAfterSMTPOutage(t *testing.T) {
+	smtp := startTestSMTPServer(t, nil, false)
+	s := newEmailTestScanner(t, closedPort(t), 3)
+	pr := &github.PullRequest{Number: github.Ptr(7), User: &github.User{Login: github.Ptr("alice")}, UpdatedAt: &github.Timestamp{Time: time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)}}
+	n := newPendingNotification(notificationClosure, pr)
+	n.CloseReason = closeNeverReviewed
+
+	// The run that closed the PR could not reach the SMTP server.
+	s.failNotification(&prOutput{}, n, errors.New("dial tcp: connection refused"))
+	// The next run finds it still down.
+	s.retryPass()
+	if len(s.repoSt.Pending) != 1 || s.repoSt.Pending[0].Attempts != 2 {
+		t.Fatalf("retry queue %+v, want the email queued after 2 attempts", s.repoSt.Pending)
+	}
+	if got := s.repoSt.Pending[0].LastError; !strings.Contains(got, "connect") && !strings.Contains(got, "refused") {
+		t.Errorf("queued email's last error %q, want the connection failure", got)
+	}
+	if s.summary.Failed != 1 {
+		t.Errorf("the failed retry counted %d failures, want 1", s.summary.Failed)
+	}
+
+	// The server recovered: the retry delivers the email and drops it.
+	s.mail.Port = smtp.port()
+	s.retryPass()
+	s.retryPass()
+	received, _ := smtp.emails()
+	if len(received) != 1 || !strings.Contains(received[0], "alice@example.com") {
+		t.Fatalf("the SMTP server received %d emails, want 1 to alice@example.com", len(received))
+	}
+	if len(s.repoSt.Pending) != 0 {
+		t.Errorf("retry queue %+v after delivery, want it empty", s.repoSt.Pending)
+	}
+	if _, sent := s.repoSt.Sent[n.Key]; !sent {
+		t.Error("the delivered email's idempotency key was not recorded")
+	}
+	if len(s.summary.DeadLettered) != 0 {
+		t.Errorf("dead-lettered %q, want nothing", s.summary.DeadLettered)
+	}
+}
+
+func TestRetryNotificationSkipsDelivered(t *testing.T) {
+	smtp := startTestSMTPServer(t, nil, false)
+	s := newEmailTestScanner(t, smtp.port(), 3)
+	n := pendingNotification{Key: "closure#7@1772359200", Kind: notificationClosure, Number: 7, Recipient: "alice"}
+	s.repoSt.queueNotification(n, errors.New("timeout"), time.Now())
+	// The email went through although the run saw an error.
+	s.repoSt.markNotificationSent(n.Key, time.Now())
+	s.retryPass()
+	if received, _ := smtp.emails(); len(received) != 0 {
+		t.Errorf("the SMTP server received %d emails, want none for a delivered notification", len(received))
+	}
+	if len(s.repoSt.Pending) != 0 {
+		t.Errorf("retry queue %+v, want the delivered email dropped", s.repoSt.Pending)
+	}
+}
+
+func TestRetryNotificationDeadLetters(t *testing.T) {
+	s := newEmailTestScanner(t, closedPort(t), 2)
+	n := pendingNotification{Key: "closure#7@1772359200", Kind: notificationClosure, Number: 7, Recipient: "alice"}
+	s.failNotification(&prOutput{}, n, errors.New("connection refused"))
+	s.retryPass()
+	if len(s.repoSt.Pending) != 0 {
+		t.Errorf("retry queue %+v, want the email dropped after its last attempt", s.repoSt.Pending)
+	}
+	if len(s.summary.DeadLettered) != 1 || !strings.HasPrefix(s.summary.DeadLettered[0], "PR #7: closure email to @alice (") {
+		t.Errorf("dead-lettered %q, want the closure email to @alice", s.summary.DeadLettered)
+	}
+	// Nothing is left to retry on the following run.
+	s.retryPass()
+	if len(s.summary.DeadLettered) != 1 {
+		t.Errorf("dead-lettered %q after another run, want it reported once", s.summary.DeadLettered)
+	}
+}
+
+func TestQueueNotification(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	rs := &repoState{}
+	rs.initMaps()
+	a := pendingNotification{Key: "warning#3@1", Kind: notificationWarning, Number: 3}
+	b := pendingNotification{Key: "closure#4@1", Kind: notificationClosure, Number: 4}
+	rs.queueNotification(a, errors.New("first"), now)
+	rs.queueNotification(b, errors.New("other"), now)
+	got := rs.queueNotification(a, errors.New("second"), now.Add(time.Hour))
+	if got.Attempts != 2 || got.LastError != "second" || !got.LastAttempt.Equal(now.Add(time.Hour)) {
+		t.Errorf("requeued notification %+v, want attempt 2 with the latest error", got)
+	}
+	if len(rs.Pending) != 2 || !rs.hasPendingNotification(4) || rs.hasPendingNotification(5) {
+		t.Errorf("retry queue %+v, want one entry per key", rs.Pending)
+	}
+	rs.dropNotification(a.Key)
+	if len(rs.Pending) != 1 || rs.Pending[0].Key != b.Key {
+		t.Errorf("retry queue %+v after dropping %s, want only %s", rs.Pending, a.Key, b.Key)
+	}
+
+	rs.markNotificationSent("old", now.Add(-sentNotificationRetention-time.Hour))
+	rs.markNotificationSent(b.Key, now)
+	if _, ok := rs.Sent["old"]; ok || len(rs.Sent) != 1 {
+		t.Errorf("sent keys %v, want keys older than the retention period forgotten", rs.Sent)
+	}
+}
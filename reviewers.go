@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// Levels of detail of the review information in evaluated events.
+const (
+	reportDetailBasic = "basic"
+	reportDetailFull  = "full"
+)
+
+// What a PR is waiting on, as reported in evaluated events.
+const (
+	waitingOnAuthor     = "author"
+	waitingOnReviewers  = "reviewers"
+	waitingOnMaintainer = "maintainer"
+)
+
+// reviewDetail is who is on the hook for a PR. Requested reviewers and
+// assignees come with the PR; the latest reviews need its timeline, which is
+// only fetched for them with --report-detail full.
+type reviewDetail struct {
+	RequestedReviewers []string `json:"requested_reviewers"`
+	RequestedTeams     []string `json:"requested_teams"`
+	Assignees          []string `json:"assignees"`
+	// Reviews maps each reviewer to the state of their latest review, e.g.
+	// "approved" or "changes_requested". It is nil if unknown.
+	Reviews   map[string]string `json:"reviews,omitempty"`
+	WaitingOn string            `json:"waiting_on"`
+}
+
+// latestReviews returns the state of each reviewer's latest review on a PR.
+// As on GitHub, a comment-only review does not replace an approval or a
+// request for changes.
+func latestReviews(events []*github.Timeline) map[string]string {
+	reviews := map[string]string{}
+	for _, ev := range events {
+		if ev.GetEvent() != "reviewed" {
+			continue
+		}
+		reviewer := timelineActor(ev)
+		state := strings.ToLower(ev.GetState())
+		if reviewer == "" || (state == "commented" && reviews[reviewer] != "") {
+			continue
+		}
+		reviews[reviewer] = state
+	}
+	return reviews
+}
+
+// newReviewDetail describes who is on the hook for a PR, given its decision
+// and its latest reviews if known.
+func newReviewDetail(pr *github.PullRequest, decision prDecision, reviews map[string]string) *reviewDetail {
+	r := &reviewDetail{
+		RequestedReviewers: []string{},
+		RequestedTeams:     []string{},
+		Assignees:          []string{},
+		Reviews:            reviews,
+	}
+	for _, u := range pr.RequestedReviewers {
+		r.RequestedReviewers = append(r.RequestedReviewers, u.GetLogin())
+	}
+	for _, t := range pr.RequestedTeams {
+		r.RequestedTeams = append(r.RequestedTeams, t.GetSlug())
+	}
+	for _, u := range pr.Assignees {
+		r.Assignees = append(r.Assignees, u.GetLogin())
+	}
+	r.WaitingOn = waitingOn(r, decision)
+	return r
+}
+
+// waitingOn classifies who a PR is waiting on: a maintainer to answer the
+// author's question, the author to address requested changes, the reviewers
+// it was handed over or requested from, or else its author.
+func waitingOn(r *reviewDetail, decision prDecision) string {
+	if decision.Question != nil {
+		return waitingOnMaintainer
+	}
+	if decision.WaitingOnReview != "" {
+		return waitingOnReviewers
+	}
+	requested := map[string]bool{}
+	for _, login := range r.RequestedReviewers {
+		requested[strings.ToLower(login)] = true
+	}
+	// Re-requesting a review from a reviewer who requested changes hands
+	// the PR back to them.
+	for reviewer, state := range r.Reviews {
+		if state == "changes_requested" && !requested[strings.ToLower(reviewer)] {
+			return waitingOnAuthor
+		}
+	}
+	if len(r.RequestedReviewers) > 0 || len(r.RequestedTeams) > 0 {
+		return waitingOnReviewers
+	}
+	return waitingOnAuthor
+}
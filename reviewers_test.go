@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+func TestLatestReviews(t *testing.T) {
+	day := func(n int) time.Time { return time.Date(2026, 3, n, 9, 0, 0, 0, time.UTC) }
+	reviewed := func(by, state string, n int) *github.Timeline {
+		ev := timelineEvent("reviewed", "", day(n))
+		ev.Actor = nil
+		ev.User = &github.User{Login: github.Ptr(by)}
+		ev.State = github.Ptr(state)
+		return ev
+	}
+	events := []*github.Timeline{
+		reviewed("alice", "CHANGES_REQUESTED", 1),
+		reviewed("alice", "COMMENTED", 2),
+		reviewed("bob", "COMMENTED", 2),
+		reviewed("carol", "APPROVED", 3),
+		reviewed("carol", "CHANGES_REQUESTED", 4),
+		reviewed("", "APPROVED", 4),
+		timelineEvent("commented", "dave", day(5)),
+	}
+	want := "map[alice:changes_requested bob:commented carol:changes_requested]"
+	if got := fmt.Sprint(latestReviews(events)); got != want {
+		t.Errorf("latestReviews = %s, want %s", got, want)
+	}
+	if got := latestReviews(nil); got == nil || len(got) != 0 {
+		t.Errorf("latestReviews(nil) = %#v, want an empty map", got)
+	}
+}
+
+func TestNewReviewDetail(t *testing.T) {
+	users := func(logins ...string) []*github.User {
+		var us []*github.User
+		for _, login := range logins {
+			us = append(us, &github.User{Login: github.Ptr(login)})
+		}
+		return us
+	}
+	for _, tc := range []struct {
+		name      string
+		reviewers []string
+		teams     []string
+		reviews   map[string]string
+		decision  prDecision
+		want      string
+	}{
+		{name: "nobody", want: waitingOnAuthor},
+		{name: "review requested", reviewers: []string{"bob"}, want: waitingOnReviewers},
+		{name: "team requested", teams: []string{"core"}, want: waitingOnReviewers},
+		{name: "approved", reviews: map[string]string{"bob": "approved"}, want: waitingOnAuthor},
+		{name: "changes requested", reviewers: []string{"carol"}, reviews: map[string]string{"bob": "changes_requested"}, want: waitingOnAuthor},
+		{name: "review re-requested", reviewers: []string{"Bob"}, reviews: map[string]string{"bob": "changes_requested"}, want: waitingOnReviewers},
+		{name: "handed over", decision: prDecision{WaitingOnReview: "@bob"}, reviews: map[string]string{"bob": "changes_requested"}, want: waitingOnReviewers},
+		{name: "question", reviewers: []string{"bob"}, decision: prDecision{Question: &question{}, WaitingOnReview: "@bob"}, want: waitingOnMaintainer},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pr := &github.PullRequest{RequestedReviewers: users(tc.reviewers...), Assignees: users("alice")}
+			for _, slug := range tc.teams {
+				pr.RequestedTeams = append(pr.RequestedTeams, &github.Team{Slug: github.Ptr(slug)})
+			}
+			r := newReviewDetail(pr, tc.decision, tc.reviews)
+			if r.WaitingOn != tc.want {
+				t.Errorf("waiting on %s, want %s", r.WaitingOn, tc.want)
+			}
+			if got, want := fmt.Sprint(r.RequestedReviewers, r.RequestedTeams, r.Assignees), fmt.Sprint(tc.reviewers, tc.teams, []string{"alice"}); got != want {
+				t.Errorf("reviewers, teams and assignees %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+// TestWaitingOnReview finds the handoffs to reviewers: the author's last
+// action re-requested a review or replied to the review comments, and no
+// reviewer has acted since.
+func TestWaitingOnReview(t *testing.T) {
+	replied := readTimeline(t, "replied-no-push.json")
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 12, 0, 0, 0, time.UTC) }
+	reviewRequest := func(actor, reviewer string, at time.Time) *github.Timeline {
+		ev := timelineEvent("review_requested", actor, at)
+		ev.Reviewer = &github.User{Login: github.Ptr(reviewer)}
+		return ev
+	}
+	review := func(user string, at time.Time) *github.Timeline {
+		return &github.Timeline{Event: github.Ptr("reviewed"), User: &github.User{Login: github.Ptr(user)}, SubmittedAt: &github.Timestamp{Time: at}}
+	}
+	for _, tc := range []struct {
+		name   string
+		events []*github.Timeline
+		want   string
+	}{
+		{name: "replied but never pushed", events: replied, want: "the author replied to the review comments"},
+		{name: "replied, then the reviewer commented", events: append(replied[:4:4], timelineEvent("commented", "bob", day(1))), want: ""},
+		{name: "replied, then pushed", events: append(replied[:4:4], timelineEvent("head_ref_force_pushed", "alice", day(1))), want: ""},
+		{name: "reviewed, no reply", events: replied[:3], want: ""},
+		{name: "re-requested", events: []*github.Timeline{review("bob", day(1)), reviewRequest("alice", "bob", day(2))}, want: "the author re-requested a review from @bob"},
+		{name: "requested by a maintainer", events: []*github.Timeline{review("bob", day(1)), reviewRequest("carol", "bob", day(2))}, want: ""},
+		{name: "author comments keep the handoff", events: []*github.Timeline{reviewRequest("alice", "bob", day(1)), timelineEvent("commented", "alice", day(2))}, want: "the author re-requested a review from @bob"},
+		{name: "converted to draft", events: []*github.Timeline{reviewRequest("alice", "bob", day(1)), timelineEvent("convert_to_draft", "alice", day(2))}, want: ""},
+		{name: "no events", want: ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := waitingOnReview(testPR("alice", day(3)), tc.events, "stale-bot"); got != tc.want {
+				t.Errorf("waitingOnReview returned %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestWaitingOnReviewExempt scans a long inactive PR whose author replied to
+// the review comments but never pushed: it is exempt rather than warned,
+// unless the exemption is turned off.
+func TestWaitingOnReviewExempt(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "timeline", "replied-no-push.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, exempt := range []bool{true, false} {
+		t.Run(fmt.Sprintf("exempt=%v", exempt), func(t *testing.T) {
+			gh := &fakeGitHub{get: map[string]string{"/repos/acme/api/issues/42/timeline": string(data)}}
+			cfg := &config{Rules: testRules(), DisplayLocation: time.UTC, NotifyVia: notifyComment}
+			cfg.Rules.ExemptWaitingOnReview = exempt
+			s := newTestRepoScanner(t, gh, cfg)
+			d := s.actOn(testPR("alice", time.Now().AddDate(0, 0, -45)))
+			if !exempt {
+				if d.Action != actionWarn {
+					t.Errorf("decided %s, want warn", d.Action)
+				}
+				return
+			}
+			if d.Action != actionExempt || d.Rule != ruleWaitingOnReview || s.summary.WaitingOnReview != 1 {
+				t.Errorf("decided %s by %s with %d counted waiting on review, want exempt by %s and 1", d.Action, d.Rule, s.summary.WaitingOnReview, ruleWaitingOnReview)
+			}
+			if got := gh.written(); len(got) != 0 {
+				t.Errorf("the author of a PR waiting on review was warned: %q", got)
+			}
+		})
+	}
+}
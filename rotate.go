@@ -0,0 +1,214 @@
+package main
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotation is how the files the bot appends to are rotated: once a file
+// reaches maxSize it is compressed to <file>.1.gz, the older backups moving
+// up to <file>.2.gz and so on. At most maxBackups are kept, and backups
+// older than maxAge are removed.
+//
+// Rotation never renames or removes a file that is open, so it works on
+// Windows too: the file is closed, copied into the compressed backup, and
+// truncated. A crash midway can duplicate lines in the backup, but loses
+// none.
+type rotation struct {
+	maxSize    int64
+	maxBackups int
+	// maxAge is zero to keep backups regardless of age.
+	maxAge time.Duration
+	now    func() time.Time
+}
+
+// backupPath returns the path of backup n of path.
+func backupPath(path string, n int) string {
+	return path + "." + strconv.Itoa(n) + ".gz"
+}
+
+// rotatedBackups returns the numbers of path's backups, newest first.
+func rotatedBackups(path string) ([]int, error) {
+	matches, err := filepath.Glob(path + ".*.gz")
+	if err != nil {
+		return nil, err
+	}
+	var numbers []int
+	for _, m := range matches {
+		n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(m, path+"."), ".gz"))
+		if err == nil && n > 0 {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+// rotate compresses path, which must not be open, into its first backup and
+// truncates it, shifting and pruning the older backups.
+func (r rotation) rotate(path string) error {
+	backups, err := rotatedBackups(path)
+	if err != nil {
+		return fmt.Errorf("failed to list backups of %s: %v", path, err)
+	}
+	for i := len(backups) - 1; i >= 0; i-- {
+		n := backups[i]
+		if n >= r.maxBackups {
+			if err := os.Remove(backupPath(path, n)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to remove old backup of %s: %v", path, err)
+			}
+			continue
+		}
+		if err := os.Rename(backupPath(path, n), backupPath(path, n+1)); err != nil {
+			return fmt.Errorf("failed to rotate backup of %s: %v", path, err)
+		}
+	}
+	if r.maxBackups > 0 {
+		if err := compressFile(path, backupPath(path, 1)); err != nil {
+			return err
+		}
+	}
+	if err := os.Truncate(path, 0); err != nil {
+		return fmt.Errorf("failed to truncate %s after rotating it: %v", path, err)
+	}
+	return r.prune(path)
+}
+
+// prune removes the backups of path older than maxAge.
+func (r rotation) prune(path string) error {
+	if r.maxAge <= 0 {
+		return nil
+	}
+	backups, err := rotatedBackups(path)
+	if err != nil {
+		return fmt.Errorf("failed to list backups of %s: %v", path, err)
+	}
+	for _, n := range backups {
+		info, err := os.Stat(backupPath(path, n))
+		if err != nil {
+			continue
+		}
+		if r.now().Sub(info.ModTime()) > r.maxAge {
+			if err := os.Remove(backupPath(path, n)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to remove expired backup of %s: %v", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// compressFile writes a gzip copy of src to dst, through a temporary file so
+// dst never holds a partial copy.
+func compressFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open %s for rotation: %v", src, err)
+	}
+	defer in.Close()
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create backup of %s: %v", src, err)
+	}
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if err == nil {
+		err = zw.Close()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write backup of %s: %v", src, err)
+	}
+	return nil
+}
+
+// rotatingFile appends to a file, rotating it before a write would take it
+// past maxSize. Every Write must be whole lines, as the log handlers and the
+// event stream write them: writes and rotations are serialized, so a line
+// is never split across files nor lost in a rotation.
+type rotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	rotation rotation
+	f        *os.File
+	size     int64
+}
+
+// openRotatingFile opens path for appending, creating it if needed.
+func openRotatingFile(path string, r rotation) (*rotatingFile, error) {
+	w := &rotatingFile{path: path, rotation: r}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *rotatingFile) open() error {
+	f, err := os.OpenFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", w.path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to open %s: %v", w.path, err)
+	}
+	w.f, w.size = f, info.Size()
+	return nil
+}
+
+func (w *rotatingFile) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return 0, fmt.Errorf("%s is closed", w.path)
+	}
+	if w.rotation.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.rotation.maxSize {
+		// Keep writing to the file if it cannot be rotated: losing the
+		// lines would be worse than a file above the limit.
+		if err := w.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "rotating %s failed: %v\n", w.path, err)
+		}
+	}
+	n, err := w.f.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// rotate closes the file, rotates it and opens it again.
+func (w *rotatingFile) rotate() error {
+	if err := w.f.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %v", w.path, err)
+	}
+	rotateErr := w.rotation.rotate(w.path)
+	if err := w.open(); err != nil {
+		return err
+	}
+	return rotateErr
+}
+
+func (w *rotatingFile) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// readBackup returns the decompressed content of a backup.
+func readBackup(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestRotatingFile(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		maxBackups int
+		// want is the content of the file and then of each backup, newest
+		// first.
+		want []string
+	}{
+		{"two backups", 2, []string{"line 9\nline 10\n", "line 7\nline 8\n", "line 5\nline 6\n"}},
+		{"no backups", 0, []string{"line 9\nline 10\n"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "bot.log")
+			// Each file holds two lines of "line N\n": the third would take
+			// it past 20 bytes.
+			w, err := openRotatingFile(path, rotation{maxSize: 20, maxBackups: tc.maxBackups, now: time.Now})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 1; i <= 10; i++ {
+				if _, err := fmt.Fprintf(w, "line %d\n", i); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{string(data)}
+			backups, err := rotatedBackups(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, n := range backups {
+				if n != i+1 {
+					t.Fatalf("backups %v, want them numbered from 1", backups)
+				}
+				got = append(got, readBackup(t, backupPath(path, n)))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("file and backups hold %q, want %q", got, tc.want)
+			}
+			if temps, _ := filepath.Glob(path + ".*.tmp"); len(temps) > 0 {
+				t.Errorf("temporary files left behind: %v", temps)
+			}
+		})
+	}
+}
+
+func TestRotatingFileAppends(t *testing.T) {
+	path := writeTestFile(t, "bot.log", "earlier run\n")
+	w, err := openRotatingFile(path, rotation{maxSize: 1 << 20, maxBackups: 1, now: time.Now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintln(w, "this run")
+	w.Close()
+	if _, err := w.Write([]byte("after closing\n")); err == nil {
+		t.Error("writing to a closed file succeeded")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), "earlier run\nthis run\n"; got != want {
+		t.Errorf("the file holds %q, want %q", got, want)
+	}
+}
+
+func TestRotationPrunesOldBackups(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	path := writeTestFile(t, "events.jsonl", "{}\n")
+	for n, age := range map[int]time.Duration{1: time.Hour, 2: 10 * 24 * time.Hour} {
+		backup := backupPath(path, n)
+		if err := os.WriteFile(backup, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(backup, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := rotation{maxSize: 1, maxBackups: 5, maxAge: 7 * 24 * time.Hour, now: func() time.Time { return now }}
+	if err := r.rotate(path); err != nil {
+		t.Fatal(err)
+	}
+	// The new backup 1 is recent, the old one moved to 2, and the old 2, moved
+	// to 3, expired.
+	backups, err := rotatedBackups(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(backups) != "[1 2]" {
+		t.Errorf("backups %v after pruning, want [1 2]", backups)
+	}
+	if got := readBackup(t, backupPath(path, 1)); got != "{}\n" {
+		t.Errorf("backup 1 holds %q, want the rotated file", got)
+	}
+}
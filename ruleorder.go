@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rules of the decision engine, named as in --rule-order and the decision
+// trace.
+//
+// A PR is evaluated in three phases, each short-circuiting on the first rule
+// that decides it:
+//
+//  1. Hard exemptions, which leave the PR alone whatever its activity.
+//  2. Classification, which decides whether the PR is stale. It is fixed.
+//  3. Action modifiers, which replace the warning or closure of a stale PR
+//     with something else.
+//
+// A stale PR no modifier applies to follows the warning lifecycle. A close
+// request from a maintainer is honored before any phase.
+const (
+	ruleCloseRequest = "close-request"
+
+	ruleParked            = "parked"
+	ruleDraft             = "draft"
+	ruleExemptAuthor      = "exempt-author"
+	ruleExemptLabel       = "exempt-label"
+	ruleExemptUntil       = "exempt-until"
+	ruleSecurityExemption = "security-exemption"
+	ruleAuthorPolicy      = "author-policy"
+	ruleWaitingOnReview   = "waiting-on-review"
+
+	ruleReopenGrace = "reopen-grace"
+	ruleActivity    = "activity"
+
+	ruleSecurityUpdate = "security-update"
+	ruleQuestion       = "question"
+	ruleAway           = "away"
+
+	ruleLifecycle = "lifecycle"
+)
+
+// Phases of the decision engine whose rules --rule-order can reorder.
+var (
+	exemptionRules = []string{ruleParked, ruleDraft, ruleExemptAuthor, ruleExemptLabel, ruleExemptUntil, ruleSecurityExemption, ruleAuthorPolicy, ruleWaitingOnReview}
+	modifierRules  = []string{ruleSecurityUpdate, ruleQuestion, ruleAway}
+)
+
+// parseRuleOrder parses --rule-order, a comma-separated list of exemption and
+// modifier rules. Rules are only reordered within their own phase: those
+// listed come first, in the given order, and the others follow in their
+// default order.
+func parseRuleOrder(s string) ([]string, error) {
+	listed := splitList(s)
+	if len(listed) == 0 {
+		return nil, nil
+	}
+	known := map[string]bool{}
+	for _, name := range append(append([]string{}, exemptionRules...), modifierRules...) {
+		known[name] = true
+	}
+	seen := map[string]bool{}
+	for _, name := range listed {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown rule %q (valid rules: %s, %s)", name, strings.Join(exemptionRules, ", "), strings.Join(modifierRules, ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("rule %q is listed twice", name)
+		}
+		seen[name] = true
+	}
+	return append(orderPhase(exemptionRules, listed), orderPhase(modifierRules, listed)...), nil
+}
+
+// orderPhase returns the rules of a phase with those in order first.
+func orderPhase(phase, order []string) []string {
+	in := map[string]bool{}
+	for _, name := range phase {
+		in[name] = true
+	}
+	var ordered []string
+	listed := map[string]bool{}
+	for _, name := range order {
+		if in[name] {
+			ordered = append(ordered, name)
+			listed[name] = true
+		}
+	}
+	for _, name := range phase {
+		if !listed[name] {
+			ordered = append(ordered, name)
+		}
+	}
+	return ordered
+}
+
+// phase returns the rules of a phase in the order they are evaluated.
+func (r evaluationRules) phase(rules []string) []string {
+	if r.Order == nil {
+		return rules
+	}
+	return orderPhase(rules, r.Order)
+}
+
+// ruleOrder describes the order rules are evaluated in, for the decision
+// trace.
+func (r evaluationRules) ruleOrder() string {
+	phases := []string{
+		ruleCloseRequest,
+		strings.Join(r.phase(exemptionRules), ", "),
+		ruleReopenGrace + ", " + ruleActivity,
+		strings.Join(r.phase(modifierRules), ", "),
+		ruleLifecycle,
+	}
+	return strings.Join(phases, " > ")
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseRuleOrder(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want []string
+		err  string
+	}{
+		{in: ""},
+		{in: " , "},
+		{
+			in:   "exempt-label",
+			want: []string{"exempt-label", "parked", "draft", "exempt-author", "exempt-until", "security-exemption", "author-policy", "waiting-on-review", "security-update", "question", "away"},
+		},
+		{
+			// Modifiers listed among the exemptions are still evaluated
+			// after them.
+			in:   "away, waiting-on-review, question, draft",
+			want: []string{"waiting-on-review", "draft", "parked", "exempt-author", "exempt-label", "exempt-until", "security-exemption", "author-policy", "away", "question", "security-update"},
+		},
+		{in: "activity", err: `unknown rule "activity"`},
+		{in: "Draft", err: `unknown rule "Draft"`},
+		{in: "draft,parked,draft", err: `rule "draft" is listed twice`},
+	} {
+		got, err := parseRuleOrder(tc.in)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("parseRuleOrder(%q) returned %v, want an error containing %q", tc.in, err, tc.err)
+			}
+			continue
+		}
+		if err != nil || !slices.Equal(got, tc.want) {
+			t.Errorf("parseRuleOrder(%q) = %q, %v; want %q", tc.in, got, err, tc.want)
+		}
+	}
+}
+
+func TestRuleOrderTrace(t *testing.T) {
+	var rules evaluationRules
+	const defaults = "close-request > parked, draft, exempt-author, exempt-label, exempt-until, security-exemption, author-policy, waiting-on-review > reopen-grace, activity > security-update, question, away > lifecycle"
+	if got := rules.ruleOrder(); got != defaults {
+		t.Errorf("the default order is %q, want %q", got, defaults)
+	}
+	var err error
+	if rules.Order, err = parseRuleOrder("question,exempt-label"); err != nil {
+		t.Fatal(err)
+	}
+	const reordered = "close-request > exempt-label, parked, draft, exempt-author, exempt-until, security-exemption, author-policy, waiting-on-review > reopen-grace, activity > question, security-update, away > lifecycle"
+	if got := rules.ruleOrder(); got != reordered {
+		t.Errorf("the order is %q, want %q", got, reordered)
+	}
+}
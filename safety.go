@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// passesCloseSafetyCheck is the final check before a PR is closed. Regardless
+// of how the PR was classified, it looks for issue or, if reviews is set,
+// review comments by a human within the safety window and refuses the closure
+// if there are any, so stale UpdatedAt values (e.g. from clock skew) cannot
+// close an active PR. A failed lookup also refuses the closure.
+func passesCloseSafetyCheck(out *prOutput, client *github.Client, owner, repo string, pr *github.PullRequest, reviews bool, botLogin string, window time.Duration) bool {
+	if window <= 0 {
+		return true
+	}
+	latest, err := latestHumanComment(client, owner, repo, pr.GetNumber(), reviews, botLogin, time.Now().Add(-window))
+	if err != nil {
+		out.Warn("not closing: safety check failed", "action", "close", "err", err)
+		return false
+	}
+	if !latest.IsZero() {
+		out.Info("not closing: safety check found recent human activity", "at", latest.Format(time.RFC3339))
+		return false
+	}
+	return true
+}
+
+// latestHumanComment returns the time of the newest issue or review comment
+// created or edited since the given time by anyone other than the bot, or the
+// zero time if there is none.
+func latestHumanComment(client *github.Client, owner, repo string, number int, reviews bool, botLogin string, since time.Time) (time.Time, error) {
+	ctx := context.Background()
+	var latest time.Time
+	isHuman := func(user *github.User) bool {
+		return user.GetType() != "Bot" && !strings.EqualFold(user.GetLogin(), botLogin)
+	}
+
+	issueComments, _, err := client.Issues.ListComments(ctx, owner, repo, number, &github.IssueListCommentsOptions{
+		Since:       &since,
+		ListOptions: github.ListOptions{PerPage: 100},
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to list issue comments: %v", err)
+	}
+	for _, c := range issueComments {
+		if isHuman(c.GetUser()) && c.GetUpdatedAt().Time.After(latest) {
+			latest = c.GetUpdatedAt().Time
+		}
+	}
+
+	if reviews {
+		reviewComments, _, err := client.PullRequests.ListComments(ctx, owner, repo, number, &github.PullRequestListCommentsOptions{
+			Since:       since,
+			ListOptions: github.ListOptions{PerPage: 100},
+		})
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to list review comments: %v", err)
+		}
+		for _, c := range reviewComments {
+			if isHuman(c.GetUser()) && c.GetUpdatedAt().Time.After(latest) {
+				latest = c.GetUpdatedAt().Time
+			}
+		}
+	}
+
+	if latest.Before(since) {
+		return time.Time{}, nil
+	}
+	return latest, nil
+}
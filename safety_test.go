@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// safetyComment is a comment served to the safety check.
+type safetyComment struct {
+	login   string
+	bot     bool
+	updated time.Time
+}
+
+func (c safetyComment) MarshalJSON() ([]byte, error) {
+	userType := "User"
+	if c.bot {
+		userType = "Bot"
+	}
+	return json.Marshal(map[string]interface{}{
+		"user":       map[string]string{"login": c.login, "type": userType},
+		"created_at": c.updated.Add(-time.Minute),
+		"updated_at": c.updated,
+	})
+}
+
+func TestCloseSafetyCheck(t *testing.T) {
+	const window = 48 * time.Hour
+	now := time.Now()
+	for _, tc := range []struct {
+		name string
+		// issue and review are the comments the server returns, whatever
+		// the since parameter; a server with a skewed clock may do so.
+		issue, review []safetyComment
+		reviews       bool
+		status        int
+		want          bool
+		wantLog       string
+	}{
+		{name: "no comments", want: true},
+		{
+			// GitHub's clock is ahead of the bot's, so the author's comment
+			// an hour ago is stamped in the future.
+			name:    "comment stamped ahead of local time",
+			issue:   []safetyComment{{login: "alice", updated: now.Add(2 * time.Hour)}},
+			want:    false,
+			wantLog: "not closing: safety check found recent human activity",
+		},
+		{
+			name:  "recent comment",
+			issue: []safetyComment{{login: "alice", updated: now.Add(-time.Hour)}},
+			want:  false,
+		},
+		{
+			name:  "comment just inside the window",
+			issue: []safetyComment{{login: "alice", updated: now.Add(-window + time.Hour)}},
+			want:  false,
+		},
+		{
+			// GitHub's clock is behind the bot's, so it returns a comment
+			// older than the window despite the since parameter.
+			name:  "comment stamped behind the window",
+			issue: []safetyComment{{login: "alice", updated: now.Add(-window - time.Hour)}},
+			want:  true,
+		},
+		{
+			// The bot's own login is matched whatever its case.
+			name:  "bot comments",
+			issue: []safetyComment{{login: "renovate[bot]", bot: true, updated: now}, {login: "Stale-Bot", updated: now}},
+			want:  true,
+		},
+		{
+			name:    "recent review comment",
+			review:  []safetyComment{{login: "bob", updated: now.Add(-time.Hour)}},
+			reviews: true,
+			want:    false,
+		},
+		{
+			name:   "review comments not checked",
+			review: []safetyComment{{login: "bob", updated: now.Add(-time.Hour)}},
+			want:   true,
+		},
+		{
+			name:    "lookup fails",
+			status:  http.StatusBadGateway,
+			want:    false,
+			wantLog: "not closing: safety check failed",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var since []string
+			client, _ := newTestGitHubClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				since = append(since, r.URL.Query().Get("since"))
+				mu.Unlock()
+				if tc.status != 0 {
+					w.WriteHeader(tc.status)
+					return
+				}
+				comments := tc.issue
+				if r.URL.Path == "/repos/acme/api/pulls/7/comments" {
+					comments = tc.review
+				} else if r.URL.Path != "/repos/acme/api/issues/7/comments" {
+					http.NotFound(w, r)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(append([]safetyComment{}, comments...))
+			}))
+			pr := testPR("alice", now.AddDate(0, 0, -60))
+			pr.Number = github.Ptr(7)
+			out := &prOutput{}
+			if got := passesCloseSafetyCheck(out, client, "acme", "api", pr, tc.reviews, "stale-bot", window); got != tc.want {
+				t.Errorf("passesCloseSafetyCheck = %v, want %v", got, tc.want)
+			}
+			if tc.wantLog != "" && (len(out.records) == 0 || out.records[len(out.records)-1].Message != tc.wantLog) {
+				t.Errorf("logged %v, want %q", out.records, tc.wantLog)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(since) == 0 {
+				t.Fatal("the safety check made no request")
+			}
+			// The window is measured from the bot's clock.
+			at, err := time.Parse(time.RFC3339, since[0])
+			if err != nil || at.Sub(now.Add(-window)).Abs() > time.Minute {
+				t.Errorf("since = %q, want about %s", since[0], now.Add(-window).UTC().Format(time.RFC3339))
+			}
+		})
+	}
+}
+
+func TestCloseSafetyCheckDisabled(t *testing.T) {
+	client, _ := newTestGitHubClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+	}))
+	if !passesCloseSafetyCheck(&prOutput{}, client, "acme", "api", testPR("alice", time.Now()), true, "stale-bot", 0) {
+		t.Error("a disabled safety check refused the closure")
+	}
+}
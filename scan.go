@@ -0,0 +1,1573 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// scanner scans the repositories of a run with the settings, budgets and
+// clients they share.
+type scanner struct {
+	flags      *cliFlags
+	cfg        *config
+	client     *github.Client
+	state      *botState
+	budget     *runBudget
+	rateLimits *rateLimitWait
+	guard      *writeGuard
+	pool       *prPool
+	deadline   *runDeadline
+	interlock  *closeInterlock
+	tmpl       *templateRenderer
+	mail       *mailer
+	events     *eventStream
+	// output takes the human-readable per-PR output: stdout, or stderr
+	// when stdout carries the ndjson events.
+	output           io.Writer
+	plan             *actionPlan
+	reminders        []time.Duration
+	baseBranches     baseBranchFilter
+	protectedPaths   []protectedPath
+	questionPatterns []*regexp.Regexp
+	botLogin         string
+	rotate           rotation
+	// started is when the run started.
+	started time.Time
+	// httpClient posts the webhooks.
+	httpClient *http.Client
+}
+
+// scanAll scans every repository once, returning the results and the run's
+// exit code.
+func (sc *scanner) scanAll(owner string, repos []string) ([]repoResult, int) {
+	results := make([]repoResult, len(repos))
+	for i, repo := range repos {
+		if sc.deadline.reached() {
+			slog.Warn("time-boxed: stopping early; the remaining repositories are not scanned", "unscanned", repos[i:], "reason", sc.deadline.reason())
+			results = results[:i]
+			break
+		}
+		if len(repos) > 1 {
+			slog.Info("scanning repository", "repo", owner+"/"+repo, "index", i+1, "of", len(repos))
+		}
+		sc.scanRepo(owner, repo, &results[i])
+		if sc.rateLimits.isAborted() {
+			break
+		}
+	}
+	sc.mail.closeConnections()
+	if len(repos) > 1 {
+		logRepoResults(results)
+	}
+	if waited := sc.rateLimits.total(); waited > 0 {
+		slog.Info("waited for GitHub rate limits", "waited", waited.Round(time.Second))
+	}
+	if sc.rateLimits.isAborted() {
+		slog.Error("aborted: waiting out GitHub rate limits would exceed --max-rate-limit-wait", "max_wait", *sc.flags.maxRateLimitWait)
+	}
+	return results, runExitCode(results, sc.rateLimits.isAborted(), sc.deadline.hit)
+}
+
+// scanRepo runs the whole evaluation for one repository. Labels and
+// thresholds are shared; a repository that cannot be read is reported in
+// result and skipped.
+func (sc *scanner) scanRepo(owner, repo string, result *repoResult) {
+	result.Name = owner + "/" + repo
+	apiCallsBefore, emailsBefore := sc.budget.usage()
+	sc.guard.reset()
+	sc.mail.suppressed = nil
+
+	// Detect a renamed or transferred repository. GitHub redirects reads made
+	// to the old name, but writes must not act on an unexpected repository.
+	newOwner, newRepo, err := canonicalRepo(sc.client, owner, repo)
+	if err != nil {
+		slog.Error("repository preflight failed, skipping it", "repo", result.Name, "err", err)
+		result.Err = err
+		return
+	}
+	if isRenamed(owner, repo, newOwner, newRepo) {
+		if !*sc.flags.followRenames {
+			slog.Warn("repository has been renamed or transferred, skipping it; update --owner/--repo, or pass --follow-renames to follow the new name", "repo", result.Name, "new_repo", newOwner+"/"+newRepo)
+			result.Err = fmt.Errorf("renamed to %s/%s", newOwner, newRepo)
+			return
+		}
+		slog.Warn("repository has been renamed or transferred, following the rename and moving its state", "repo", result.Name, "new_repo", newOwner+"/"+newRepo)
+		sc.state.renameRepo(owner, repo, newOwner, newRepo)
+		owner, repo = newOwner, newRepo
+		result.Name = owner + "/" + repo
+	}
+
+	s := newRepoScanner(sc, owner, repo)
+	s.apiCallsBefore, s.emailsBefore = apiCallsBefore, emailsBefore
+	if err := s.scan(); err != nil {
+		result.Err = err
+		return
+	}
+	result.Summary = s.summary
+}
+
+// repoScanner scans one repository. It holds what the scan learns about the
+// repository and its PRs along the way.
+type repoScanner struct {
+	*scanner
+	owner    string
+	repo     string
+	repoName string
+	repoSt   *repoState
+	logger   *slog.Logger
+	summary  *runSummary
+
+	// issues holds the numbers of the issues processed with
+	// --include-issues. They go through the same pipeline as PRs, minus the
+	// steps that only apply to code: checks, changed files and review
+	// comments.
+	issues map[int]bool
+	checks *checksCache
+	// canPush caches per login whether they may have PRs closed with the
+	// close-now label.
+	canPush map[string]bool
+	// reviewStates holds the latest reviews of the PRs whose timeline was
+	// fetched, for the review details of evaluated events.
+	reviewStates map[int]map[string]string
+	// prCommits caches the commits of a PR: a notification may look up the
+	// author's address more than once.
+	prCommits map[int][]*github.RepositoryCommit
+
+	dispatch *dispatcher
+	hooks    *webhookSender
+
+	// milestone is the number of the milestone stale PRs are parked in,
+	// and codeOwners the rules of the CODEOWNERS file, both looked up by
+	// prepareActions before the workers act on the PRs.
+	milestone     int
+	milestoneErr  error
+	codeOwners    []codeOwnersRule
+	codeOwnersErr error
+
+	fullScan       bool
+	backfilling    bool
+	openPRs        []*github.PullRequest
+	statusRequests map[int]string
+	newPlan        *actionPlan
+	sink           *outputSink
+	stalePRs       []*github.PullRequest
+	escalated      []escalatedUpdate
+	newlyEscalated []int
+
+	apiCallsBefore int
+	emailsBefore   int
+}
+
+func newRepoScanner(sc *scanner, owner, repo string) *repoScanner {
+	s := &repoScanner{
+		scanner:      sc,
+		owner:        owner,
+		repo:         repo,
+		repoName:     owner + "/" + repo,
+		repoSt:       sc.state.repo(owner, repo),
+		issues:       map[int]bool{},
+		checks:       newChecksCache(sc.client, owner, repo),
+		canPush:      map[string]bool{},
+		reviewStates: map[int]map[string]string{},
+		prCommits:    map[int][]*github.RepositoryCommit{},
+	}
+	s.logger = slog.With("repo", s.repoName)
+	s.summary = &runSummary{
+		Exemptions:      map[string]int{},
+		PolicyOverrides: map[string]int{},
+		CloseReasons:    map[string]int{},
+		ParkedIn:        sc.cfg.Rules.ParkedMilestone,
+		Delta:           &runDelta{FirstRun: s.repoSt.ClassifiedAt.IsZero()},
+		SLA:             &slaSample{},
+	}
+
+	sc.mail.emails.profiles = newUserProfiles(sc.client)
+	sc.mail.emails.commits = func(number int) ([]*github.RepositoryCommit, error) {
+		if commits, ok := s.prCommits[number]; ok {
+			return commits, nil
+		}
+		commits, err := listPRCommits(sc.client, owner, repo, number)
+		if err == nil {
+			s.prCommits[number] = commits
+		}
+		return commits, err
+	}
+	sc.mail.comment = func(number int, body string) error {
+		return postComment(sc.client, owner, repo, number, body)
+	}
+
+	if *sc.flags.emitDispatch {
+		s.dispatch = &dispatcher{
+			client: sc.client,
+			owner:  owner,
+			repo:   repo,
+			runID:  envString("GITHUB_RUN_ID", sc.started.UTC().Format("20060102T150405Z")),
+			max:    *sc.flags.dispatchMax,
+			budget: sc.budget,
+		}
+	}
+	if *sc.flags.webhookURL != "" {
+		s.hooks = &webhookSender{
+			client: sc.httpClient,
+			url:    *sc.flags.webhookURL,
+			secret: *sc.flags.webhookSecret,
+			repo:   s.repoName,
+			actor:  sc.botLogin,
+			rules:  sc.cfg.Rules,
+			guard:  sc.guard,
+		}
+	}
+	return s
+}
+
+// scan evaluates and acts on the repository's PRs, then reports the run. It
+// returns an error if the PRs could not be listed.
+func (s *repoScanner) scan() error {
+	s.retryPass()
+	if err := s.listPRs(); err != nil {
+		return err
+	}
+
+	// While backfilling, handle the oldest PRs first.
+	s.backfilling = *s.flags.backfill && !s.repoSt.Backfill.Complete
+	if s.backfilling {
+		sort.SliceStable(s.openPRs, func(i, j int) bool {
+			return s.openPRs[i].GetUpdatedAt().Time.Before(s.openPRs[j].GetUpdatedAt().Time)
+		})
+		s.logger.Info("backfill mode: warning the oldest PRs first", "daily_cap", *s.flags.backfillDailyCap)
+	}
+
+	// Process PRs that were deferred by an exhausted budget last run first.
+	s.openPRs = prioritizePRs(s.openPRs, s.repoSt.Deferred)
+	s.repoSt.Deferred = nil
+
+	// Find "/stale status" requests made since the last search.
+	if *s.flags.statusCommand {
+		since := s.repoSt.StatusSince
+		if since.IsZero() {
+			since = time.Now().Add(-statusLookback)
+		}
+		searched := time.Now()
+		requests, err := listStatusRequests(s.client, s.owner, s.repo, s.botLogin, since)
+		if err != nil {
+			s.logger.Error("looking for status requests failed", "action", "search-status-requests", "err", err)
+		} else {
+			s.statusRequests = requests
+			s.repoSt.StatusSince = searched
+		}
+	}
+
+	// Switch to warn-only mode when maintainers themselves are inactive.
+	if !*s.flags.noAutoSoften {
+		health, err := getRepoHealth(s.client, s.owner, s.repo, *s.flags.autoSoftenWindow, time.Now())
+		if err != nil {
+			s.logger.Error("measuring maintainer activity failed, not auto-softening", "action", "measure-activity", "err", err)
+		} else if reason := softenReason(health, *s.flags.autoSoftenMinActivity); reason != "" {
+			s.summary.Softened = reason
+			s.logger.Warn("warn-only mode: no PRs will be closed this run", "reason", reason)
+		}
+	}
+
+	if *s.flags.planFile != "" {
+		s.newPlan = &actionPlan{Version: planVersion, Repo: s.repoName, CreatedAt: time.Now().UTC()}
+	}
+
+	// Process PRs.
+	s.sink = newOutputSink(s.output, *s.flags.quiet, *s.flags.logFormat == logFormatText, len(s.openPRs), s.events, s.repoName)
+	// Evaluate every PR before acting on any, so that the close
+	// interlock knows how many PRs the run would close.
+	outs := make([]*prOutput, len(s.openPRs))
+	decisions := make([]prDecision, len(s.openPRs))
+	closing := 0
+	// PRs from index processed on are left for the next run when the
+	// run reaches --max-run-duration.
+	processed := s.pool.run(len(s.openPRs), s.deadline.reached, func(i int) {
+		outs[i], decisions[i] = s.evaluate(s.openPRs[i])
+		if decisions[i].Action == actionClose || decisions[i].Action == actionCloseNow {
+			closing++
+		}
+	})
+	if s.summary.Softened == "" {
+		if reason := s.interlock.check(s.repoName, closing); reason != "" {
+			s.summary.Softened = reason
+			s.logger.Warn("warn-only mode: no PRs will be closed this run; pass --enable-close to close them", "reason", reason)
+		}
+	}
+
+	s.prepareActions(decisions[:processed])
+	processed = s.pool.run(processed, s.deadline.reached, func(i int) {
+		s.act(outs[i], s.openPRs[i], decisions[i])
+		s.sink.Finish(outs[i], len(s.summary.Warned), len(s.summary.Closed))
+	})
+	s.sortByListing()
+	// Leave the PRs not reached before --max-run-duration to the next
+	// run, which processes them first.
+	if rest := s.openPRs[processed:]; len(rest) > 0 {
+		s.summary.Unprocessed = len(rest)
+		for _, pr := range rest {
+			s.repoSt.Deferred = append(s.repoSt.Deferred, pr.GetNumber())
+		}
+		s.logger.Warn("time-boxed: stopping early; the remaining PRs are deferred to the next run", "unprocessed", len(rest), "reason", s.deadline.reason(), "max_run_duration", *s.flags.maxRunDuration)
+	}
+	if *s.flags.detectDuplicates && !s.deadline.reached() {
+		s.duplicatesPass()
+	}
+	if len(s.newlyEscalated) > 0 {
+		s.escalationPass()
+	}
+	if !s.fullScan && s.plan == nil && !s.deadline.reached() {
+		s.cleanupPass()
+	}
+	s.sink.Close()
+	s.summary.Failed += s.sink.failures()
+
+	if s.newPlan != nil {
+		if err := writePlan(*s.flags.planFile, s.newPlan); err != nil {
+			s.logger.Error("writing plan failed", "file", *s.flags.planFile, "err", err)
+		} else {
+			s.logger.Info("wrote plan", "file", *s.flags.planFile, "actions", len(s.newPlan.Actions))
+		}
+	}
+
+	s.repoSt.finishClassification(s.openPRs, s.fullScan, time.Now())
+	if *s.flags.authorStats != authorStatsOff {
+		s.summary.AuthorStats = collectAuthorStats(s.repoSt, *s.flags.authorStatsMin)
+		s.summary.PublishAuthorStats = *s.flags.authorStats == authorStatsFull
+	}
+
+	// Backfill is complete once a full scan leaves no capped warnings.
+	if s.backfilling && s.fullScan && s.summary.BackfillRemaining == 0 && len(s.repoSt.Deferred) == 0 {
+		s.repoSt.Backfill.Complete = true
+		s.summary.BackfillComplete = true
+	}
+	s.summary.Backfilling = s.backfilling
+
+	s.report()
+	return nil
+}
+
+// sortByListing puts the PRs the workers collected back in the order the PRs
+// were listed in, as workers finish in no particular order, so that the
+// passes after them and the report do not depend on it. PRs that were not
+// listed, retried before the workers started, stay first.
+func (s *repoScanner) sortByListing() {
+	pos := make(map[int]int, len(s.openPRs))
+	for i, pr := range s.openPRs {
+		pos[pr.GetNumber()] = i + 1
+	}
+	for _, prs := range [][]*github.PullRequest{s.stalePRs, s.summary.Warned, s.summary.Closed} {
+		sort.SliceStable(prs, func(i, j int) bool { return pos[prs[i].GetNumber()] < pos[prs[j].GetNumber()] })
+	}
+	sort.SliceStable(s.escalated, func(i, j int) bool { return pos[s.escalated[i].Number] < pos[s.escalated[j].Number] })
+	sort.SliceStable(s.newlyEscalated, func(i, j int) bool { return pos[s.newlyEscalated[i]] < pos[s.newlyEscalated[j]] })
+}
+
+// kindOf returns whether a PR is a pull request or an issue.
+func (s *repoScanner) kindOf(pr *github.PullRequest) string {
+	if s.issues[pr.GetNumber()] {
+		return kindIssue
+	}
+	return kindPullRequest
+}
+
+// pathsOf returns the protected paths that apply to a PR.
+func (s *repoScanner) pathsOf(pr *github.PullRequest) []protectedPath {
+	if s.issues[pr.GetNumber()] {
+		return nil
+	}
+	return s.protectedPaths
+}
+
+// pending returns a notification to queue for retry.
+func (s *repoScanner) pending(kind string, pr *github.PullRequest) pendingNotification {
+	n := newPendingNotification(kind, pr)
+	n.Issue = s.issues[pr.GetNumber()]
+	return n
+}
+
+// closeRequestOf finds who applied the close-now label to a PR and whether
+// they may have it closed. Permissions are checked once per login and run.
+func (s *repoScanner) closeRequestOf(pr *github.PullRequest) (*closeRequest, error) {
+	issueEvents, err := getIssueEvents(s.client, s.owner, s.repo, pr.GetNumber())
+	if err != nil {
+		return nil, err
+	}
+	cr := &closeRequest{Label: *s.flags.closeNowLabel, By: lastLabeler(issueEvents, *s.flags.closeNowLabel)}
+	if cr.By == "" {
+		return cr, nil
+	}
+	allowed, ok := s.canPush[strings.ToLower(cr.By)]
+	if !ok {
+		if allowed, err = hasPushAccess(s.client, s.owner, s.repo, cr.By); err != nil {
+			return nil, err
+		}
+		s.canPush[strings.ToLower(cr.By)] = allowed
+	}
+	cr.Allowed = allowed
+	return cr, nil
+}
+
+// signalsFor fetches the signals the configuration makes relevant: the
+// failing checks when they can make a PR stale, and the timeline when edits
+// do not count as activity. Errors leave a signal unknown.
+func (s *repoScanner) signalsFor(out *prOutput, pr *github.PullRequest) prSignals {
+	var signals prSignals
+	if *s.flags.closeNowLabel != "" && hasLabel(pr, *s.flags.closeNowLabel) {
+		cr, err := s.closeRequestOf(pr)
+		if err != nil {
+			out.Error("checking the close request label failed", "action", "check-close-request", "label", *s.flags.closeNowLabel, "err", err)
+		}
+		signals.CloseRequest = cr
+	}
+	// Skipped drafts and exempt authors need no signals.
+	if (s.cfg.Rules.SkipDrafts && pr.GetDraft()) || matchExemptAuthor(s.cfg.Rules.ExemptAuthors, pr.GetUser().GetLogin()) != "" {
+		return signals
+	}
+	if s.cfg.Rules.FailingChecksStaleAfter > 0 && !s.issues[pr.GetNumber()] {
+		cs, err := s.checks.status(pr)
+		if err != nil {
+			out.Error("reading checks failed", "action", "read-checks", "err", err)
+		} else {
+			signals.Checks = cs
+		}
+	}
+	if hasLabel(pr, s.cfg.Rules.StaleLabel) {
+		labeledAt, err := getLabeledAt(s.client, s.owner, s.repo, pr.GetNumber(), s.cfg.Rules.StaleLabel)
+		if err != nil {
+			out.Error("reading events failed", "action", "read-events", "err", err)
+		} else if labeledAt.IsZero() {
+			out.Warn("no event found for the stale label; timing the warning period from the last update", "label", s.cfg.Rules.StaleLabel)
+		}
+		signals.WarnedAt = labeledAt
+		signals.TitlePrefixedAt = s.repoSt.TitlePrefixed[pr.GetNumber()]
+	}
+	// The handoff to reviewers and an earlier closure only matter for PRs
+	// that may be stale.
+	mayBeStale := !isParked(pr, s.cfg.Rules.ParkedMilestone) &&
+		(signals.Checks != nil || time.Since(pr.GetUpdatedAt().Time) > time.Duration(s.cfg.Rules.DaysInactive)*24*time.Hour)
+	handoff := s.cfg.Rules.ExemptWaitingOnReview && mayBeStale
+	// Drafts are paused by a conversion their updates may not show.
+	pausable := s.cfg.Rules.PauseDrafts && pr.GetDraft()
+	if !*s.flags.editsCountAsActivity || mayBeStale || pausable {
+		timeline, err := getTimeline(s.client, s.owner, s.repo, pr.GetNumber())
+		if err != nil {
+			out.Error("reading timeline failed", "action", "read-timeline", "err", err)
+			return signals
+		}
+		if !*s.flags.editsCountAsActivity {
+			act := timelineActivity(pr, timeline, s.botLogin)
+			signals.Activity = &act
+		}
+		if handoff {
+			signals.WaitingOnReview = waitingOnReview(pr, timeline, s.botLogin)
+		}
+		s.reviewStates[pr.GetNumber()] = latestReviews(timeline)
+		if mayBeStale && !s.cfg.Rules.CloseWaitingOnMaintainer {
+			signals.Question = pendingQuestion(pr, timeline, s.botLogin, s.questionPatterns)
+		}
+		signals.ReopenedAt = reopenedAfterClosure(pr, timeline, s.botLogin)
+		signals.CloseReason = closeReasonOf(pr, timeline, s.botLogin)
+		signals.Transitions = draftTransitionsOf(pr, timeline, s.cfg.Rules.StaleLabel)
+	}
+	return signals
+}
+
+// announce emits a warned or closed event and dispatches it to workflows.
+func (s *repoScanner) announce(out *prOutput, ev botEvent) {
+	s.events.Emit(ev)
+	s.dispatch.send(out, ev)
+}
+
+// prepareActions looks up what the actions decided for the PRs share: the
+// milestone stale PRs are parked in if any is closed, and the CODEOWNERS
+// file if maintainers are nudged. Filling them lazily from the workers would
+// let two of them look them up, or create the milestone, at once, as the
+// pool's lock is released while they wait on GitHub.
+func (s *repoScanner) prepareActions(decisions []prDecision) {
+	var closing, nudging bool
+	for _, d := range decisions {
+		switch d.Action {
+		case actionClose, actionCloseNow:
+			closing = true
+		case actionQuestion:
+			nudging = true
+		}
+	}
+	// A dry run closes nothing, so it creates no milestone.
+	if closing && s.cfg.Rules.ParkedMilestone != "" && !*s.flags.dryRun {
+		s.milestone, s.milestoneErr = findOrCreateMilestone(s.client, s.owner, s.repo, s.cfg.Rules.ParkedMilestone)
+	}
+	if nudging {
+		s.codeOwners, s.codeOwnersErr = getCodeOwners(s.client, s.owner, s.repo)
+	}
+}
+
+// staleMilestone returns the number of the milestone stale PRs are parked
+// in, or 0 when they are closed.
+func (s *repoScanner) staleMilestone() (int, error) {
+	return s.milestone, s.milestoneErr
+}
+
+// codeOwnersOfPR returns the code owners of a PR's files.
+func (s *repoScanner) codeOwnersOfPR(pr *github.PullRequest) ([]string, error) {
+	if s.issues[pr.GetNumber()] {
+		return nil, nil
+	}
+	if s.codeOwnersErr != nil {
+		return nil, s.codeOwnersErr
+	}
+	if len(s.codeOwners) == 0 {
+		return nil, nil
+	}
+	files, err := changedFiles(s.client, s.owner, s.repo, pr, s.repoSt)
+	if err != nil {
+		return nil, err
+	}
+	return codeOwnersOf(s.codeOwners, files), nil
+}
+
+// warningAttachments returns the attachments for a PR's warning email.
+func (s *repoScanner) warningAttachments(pr *github.PullRequest, deadline time.Time) []emailAttachment {
+	if !*s.flags.attachICS {
+		return nil
+	}
+	return []emailAttachment{{
+		Filename:    fmt.Sprintf("pr-%d-deadline.ics", pr.GetNumber()),
+		ContentType: "text/calendar; charset=utf-8; method=PUBLISH",
+		Data:        buildClosureICS(s.owner, s.repo, pr, deadline, time.Now()),
+	}}
+}
+
+// addTitlePrefix puts --title-prefix-on-warn in front of the title of a
+// warned PR once per warning cycle, so a prefix removed by hand is not added
+// again. A new warning starts a new cycle.
+func (s *repoScanner) addTitlePrefix(out *prOutput, pr *github.PullRequest, newWarning bool) {
+	prefix := *s.flags.titlePrefixOnWarn
+	if prefix == "" {
+		return
+	}
+	if newWarning {
+		delete(s.repoSt.TitlePrefixed, pr.GetNumber())
+	}
+	if _, done := s.repoSt.TitlePrefixed[pr.GetNumber()]; done {
+		if !hasTitlePrefix(pr.GetTitle(), prefix) {
+			out.Debug("the title prefix was removed by hand during this warning; not adding it again", "prefix", prefix)
+		}
+		return
+	}
+	if hasTitlePrefix(pr.GetTitle(), prefix) {
+		s.repoSt.TitlePrefixed[pr.GetNumber()] = time.Time{}
+		return
+	}
+	if s.guard.would(out, "title prefix", "prefix the title of PR #%d with '%s'", pr.GetNumber(), prefix) {
+		return
+	}
+	if err := editTitle(s.client, s.owner, s.repo, pr.GetNumber(), prefixTitle(pr.GetTitle(), prefix)); err != nil {
+		out.Error("prefixing the title failed", "action", "title-prefix", "err", err)
+		return
+	}
+	out.Info("prefixed the title", "prefix", prefix)
+	s.repoSt.TitlePrefixed[pr.GetNumber()] = time.Now()
+}
+
+// removeTitlePrefix removes --title-prefix-on-warn from the title of a PR
+// that is no longer warned.
+func (s *repoScanner) removeTitlePrefix(out *prOutput, pr *github.PullRequest) {
+	prefix := *s.flags.titlePrefixOnWarn
+	if prefix == "" || !hasTitlePrefix(pr.GetTitle(), prefix) {
+		return
+	}
+	if s.guard.would(out, "title prefix", "remove the '%s' prefix from the title of PR #%d", prefix, pr.GetNumber()) {
+		return
+	}
+	if err := editTitle(s.client, s.owner, s.repo, pr.GetNumber(), unprefixTitle(pr.GetTitle(), prefix)); err != nil {
+		out.Error("removing the title prefix failed", "action", "title-prefix", "err", err)
+		return
+	}
+	out.Info("removed the title prefix", "prefix", prefix)
+}
+
+// failNotification queues a failed notification for retry, or reports it for
+// manual follow-up once it has used all its attempts.
+func (s *repoScanner) failNotification(out *prOutput, n pendingNotification, sendErr error) {
+	if errors.Is(sendErr, errReadOnly) {
+		return
+	}
+	n = s.repoSt.queueNotification(n, sendErr, time.Now())
+	if n.Attempts < *s.flags.notificationMaxAttempts {
+		out.Warn("queued email for retry on the next run", "kind", n.Kind, "attempt", n.Attempts, "max_attempts", *s.flags.notificationMaxAttempts)
+		return
+	}
+	out.Error("giving up on email", "action", "email", "kind", n.Kind, "attempts", n.Attempts, "err", n.LastError)
+	s.summary.DeadLettered = append(s.summary.DeadLettered, fmt.Sprintf("PR #%d: %s email to @%s (%s)", n.Number, n.Kind, n.Recipient, n.LastError))
+	s.repoSt.dropNotification(n.Key)
+}
+
+// retryPass retries the notifications that failed in earlier runs, before
+// any new work.
+func (s *repoScanner) retryPass() {
+	if len(s.repoSt.Pending) == 0 {
+		return
+	}
+	s.logger.Info("retrying failed notifications from earlier runs", "count", len(s.repoSt.Pending))
+	queued := append([]pendingNotification(nil), s.repoSt.Pending...)
+	retrySink := newOutputSink(s.output, *s.flags.quiet, *s.flags.logFormat == logFormatText, len(queued), s.events, s.repoName)
+	for _, n := range queued {
+		out := retrySink.Begin(n.Number)
+		s.retryNotification(out, n)
+		retrySink.Finish(out, len(s.summary.Warned), len(s.summary.Closed))
+	}
+	retrySink.Close()
+	s.summary.Failed += retrySink.failures()
+}
+
+// retryNotification makes another attempt at a queued notification.
+func (s *repoScanner) retryNotification(out *prOutput, n pendingNotification) {
+	if _, sent := s.repoSt.Sent[n.Key]; sent {
+		out.Info("email was already delivered; dropping it from the retry queue", "kind", n.Kind)
+		s.repoSt.dropNotification(n.Key)
+		return
+	}
+	if reason := s.budget.exhausted(true); reason != "" {
+		out.Warn("budget exhausted; keeping email queued", "reason", reason, "kind", n.Kind)
+		s.summary.BudgetExhausted = reason
+		return
+	}
+	var pr *github.PullRequest
+	if n.Issue {
+		issue, _, err := s.client.Issues.Get(context.Background(), s.owner, s.repo, n.Number)
+		if err != nil {
+			s.failNotification(out, n, fmt.Errorf("failed to fetch issue: %v", err))
+			return
+		}
+		s.issues[n.Number] = true
+		pr = issueAsPR(issue)
+	} else {
+		var err error
+		pr, _, err = s.client.PullRequests.Get(context.Background(), s.owner, s.repo, n.Number)
+		if err != nil {
+			s.failNotification(out, n, fmt.Errorf("failed to fetch PR: %v", err))
+			return
+		}
+	}
+	data := newNotificationData(s.cfg, pr, s.owner, s.repo, time.Now())
+	data.Kind = s.kindOf(pr)
+	data.CloseReason = n.CloseReason
+	if n.Kind == notificationWarning {
+		decision := evaluatePR(pr, s.signalsFor(out, pr), s.cfg.Rules, time.Now())
+		if pr.GetState() != "open" || decision.Action != actionWarn {
+			out.Info("warning email no longer needed; dropping it from the retry queue")
+			s.repoSt.dropNotification(n.Key)
+			return
+		}
+		data.Inactive = time.Since(decision.LastActivity)
+		data.ReopenedAt = decision.ReopenedAt
+		data.FailingChecks = decision.FailingChecks
+		data.PathProtected = cachedProtectedPathMatch(pr, s.pathsOf(pr), s.repoSt)
+	}
+
+	if s.guard.would(out, "retry email", "retry the %s email to %s for PR #%d", n.Kind, s.mail.recipient(out, pr), n.Number) {
+		return
+	}
+	out.Info("retrying email", "kind", n.Kind, "attempt", n.Attempts+1)
+	var err error
+	if n.Kind == notificationWarning {
+		err = warnPRAuthor(out, pr, data, s.mail, s.warningAttachments(pr, data.Deadline)...)
+	} else {
+		err = notifyPRClosure(out, pr, data, s.mail)
+	}
+	if errors.Is(err, errEmailBudgetExhausted) {
+		out.Warn("budget exhausted; keeping email queued", "reason", s.budget.exhausted(true), "kind", n.Kind)
+		s.summary.BudgetExhausted = s.budget.exhausted(true)
+		return
+	}
+	if err != nil {
+		out.Error("sending email failed", "action", "email", "kind", n.Kind, "err", err)
+		s.failNotification(out, n, err)
+		return
+	}
+	out.Info("sent email", "kind", n.Kind)
+	s.repoSt.dropNotification(n.Key)
+	s.repoSt.markNotificationSent(n.Key, time.Now())
+	if n.Kind == notificationWarning {
+		s.summary.Warned = append(s.summary.Warned, pr)
+		s.repoSt.slaWarned(s.summary.SLA, pr.GetNumber(), time.Now())
+		s.announce(out, newPREvent(eventWarned, s.repoName, pr))
+		s.hooks.send(out, webhookWarned, pr, "", nil)
+		if err := addLabel(s.client, s.owner, s.repo, pr.GetNumber(), s.cfg.Rules.StaleLabel); err != nil {
+			out.Error("adding label failed", "action", "add-label", "label", s.cfg.Rules.StaleLabel, "err", err)
+		} else {
+			s.hooks.send(out, webhookLabelAdded, pr, s.cfg.Rules.StaleLabel, nil)
+			s.repoSt.Deadlines[pr.GetNumber()] = data.Deadline
+			s.addTitlePrefix(out, pr, true)
+			_, s.repoSt.Reminders[pr.GetNumber()] = dueReminder(s.reminders, nil, data.Deadline, time.Now())
+		}
+	}
+}
+
+// listPRs lists the PRs to evaluate: those of a plan being executed, those
+// with new events in incremental mode, or all open PRs, and open issues with
+// --include-issues. PRs targeting base branches out of scope are dropped.
+func (s *repoScanner) listPRs() error {
+	var err error
+	// In incremental mode, re-evaluate only PRs with new events and PRs whose
+	// warning deadline has arrived, unless a full scan is due.
+	s.fullScan = true
+	if s.plan != nil {
+		// Execute a reviewed plan: only its PRs, and only if they are still in
+		// the state they were planned in.
+		s.fullScan = false
+		s.logger.Info("executing plan", "file", *s.flags.executePlan, "created", isoUTC(s.plan.CreatedAt), "actions", len(s.plan.Actions))
+		prs, err := getPRsByNumber(s.client, s.owner, s.repo, s.plan.numbers())
+		if err != nil {
+			s.logger.Error("fetching PRs failed, skipping the repository", "action", "list-prs", "err", err)
+			return err
+		}
+		fetched := map[int]*github.PullRequest{}
+		for _, pr := range prs {
+			fetched[pr.GetNumber()] = pr
+		}
+		for _, a := range s.plan.Actions {
+			pr, ok := fetched[a.Number]
+			drift := "no longer open"
+			if ok {
+				drift = a.drift(pr)
+			}
+			if drift != "" {
+				s.logger.Warn("skipping planned action", "pr", a.Number, "action", a.Action, "drift", drift)
+				s.summary.PlanDrift = append(s.summary.PlanDrift, fmt.Sprintf("PR #%d: %s skipped, %s", a.Number, a.Action, drift))
+				continue
+			}
+			s.openPRs = append(s.openPRs, pr)
+		}
+	} else if *s.flags.incremental {
+		s.fullScan = time.Since(s.repoSt.LastFullScan) >= *s.flags.fullScanInterval
+		changed, newestEvent, reachedCursor, err := listChangedPRNumbers(s.client, s.owner, s.repo, s.repoSt.EventsCursor)
+		switch {
+		case err != nil:
+			s.logger.Error("reading repository events failed, falling back to a full scan", "action", "list-events", "err", err)
+			s.fullScan = true
+		case !s.fullScan && !reachedCursor:
+			s.logger.Warn("events feed does not reach back to the stored cursor; events may have been missed, running a full scan")
+			s.fullScan = true
+		}
+		if err == nil {
+			s.repoSt.EventsCursor = newestEvent
+		}
+		if !s.fullScan {
+			due := dueDeadlines(s.repoSt, time.Now())
+			reminding := dueReminders(s.repoSt, s.reminders, time.Now())
+			s.logger.Info("incremental scan", "changed", len(changed), "past_deadline", len(due), "due_reminder", len(reminding))
+			numbers := append(append(append(s.repoSt.Deferred, changed...), due...), reminding...)
+			s.openPRs, err = getPRsByNumber(s.client, s.owner, s.repo, numbers)
+			if err != nil {
+				s.logger.Error("fetching PRs failed, skipping the repository", "action", "list-prs", "err", err)
+				return err
+			}
+		}
+	}
+
+	// Get open PRs, and issues with --include-issues.
+	openIssues := 0
+	if s.fullScan {
+		s.logger.Debug("fetching open PRs")
+		s.openPRs, err = getOpenPRs(s.client, s.owner, s.repo)
+		if err != nil {
+			s.logger.Error("fetching PRs failed, skipping the repository", "action", "list-prs", "err", err)
+			return err
+		}
+		if *s.flags.includeIssues {
+			s.logger.Debug("fetching open issues")
+			open, err := getOpenIssues(s.client, s.owner, s.repo)
+			if err != nil {
+				s.logger.Error("fetching issues failed, skipping the repository", "action", "list-issues", "err", err)
+				return err
+			}
+			s.logger.Info("found open issues", "count", len(open))
+			openIssues = len(open)
+			for _, issue := range open {
+				s.issues[issue.GetNumber()] = true
+				s.openPRs = append(s.openPRs, issueAsPR(issue))
+			}
+		}
+		s.repoSt.LastFullScan = time.Now()
+		// Forget deadlines of PRs that are no longer open.
+		open := map[int]bool{}
+		for _, pr := range s.openPRs {
+			open[pr.GetNumber()] = true
+		}
+		for number := range s.repoSt.Deadlines {
+			if !open[number] {
+				s.repoSt.forget(number)
+			}
+		}
+		for number := range s.repoSt.Nudges {
+			if !open[number] {
+				delete(s.repoSt.Nudges, number)
+			}
+		}
+		for number := range s.repoSt.DuplicateComments {
+			if !open[number] {
+				delete(s.repoSt.DuplicateComments, number)
+			}
+		}
+		for number := range s.repoSt.Timelines {
+			if !open[number] {
+				s.repoSt.slaResolved(s.summary.SLA, number, time.Now())
+			}
+		}
+	}
+	s.logger.Info("found open PRs", "count", len(s.openPRs)-openIssues)
+	// PRs targeting other base branches are left alone entirely.
+	inScope := s.openPRs[:0]
+	for _, pr := range s.openPRs {
+		if s.issues[pr.GetNumber()] || s.baseBranches.allows(pr.GetBase().GetRef()) {
+			inScope = append(inScope, pr)
+		} else {
+			s.summary.OtherBase++
+		}
+	}
+	s.openPRs = inScope
+	if s.summary.OtherBase > 0 {
+		s.logger.Info("skipping PRs targeting base branches out of scope", "count", s.summary.OtherBase)
+	}
+	return nil
+}
+
+// deferAction records an action skipped because a run budget is exhausted so
+// the next run picks the PR up first.
+func (s *repoScanner) deferAction(out *prOutput, pr *github.PullRequest, action, reason string) {
+	out.Warn("budget exhausted; deferring the action to the next run", "reason", reason, "action", action)
+	s.summary.BudgetExhausted = reason
+	s.summary.Deferred = append(s.summary.Deferred, fmt.Sprintf("PR #%d: %s", pr.GetNumber(), action))
+	s.repoSt.Deferred = append(s.repoSt.Deferred, pr.GetNumber())
+}
+
+// escalateSecurityUpdates emails the security contact the stale security
+// updates and reports whether they were escalated.
+func (s *repoScanner) escalateSecurityUpdates(out *prOutput, updates []escalatedUpdate) bool {
+	contact := *s.flags.securityContact
+	if contact == "" {
+		out.Info("no --security-contact set; stale security updates are only labeled", "count", len(updates))
+		return true
+	}
+	if reason := s.budget.exhausted(true); reason != "" {
+		out.Warn("budget exhausted; escalating on the next run", "reason", reason)
+		return false
+	}
+	if s.guard.would(out, "escalation email", "email %s about %d stale security update(s)", contact, len(updates)) {
+		return true
+	}
+	body, err := s.tmpl.render("security escalation email", securityEscalationTemplate, securityEscalationData{
+		Owner:        s.owner,
+		Repo:         s.repo,
+		DaysInactive: s.cfg.Rules.DaysInactive,
+		Label:        securityStaleLabel,
+		Updates:      updates,
+		Location:     s.cfg.DisplayLocation,
+	})
+	if err != nil {
+		out.Error("rendering security escalation failed", "action", "escalate", "err", err)
+		return false
+	}
+	if !s.budget.takeEmail() {
+		out.Warn("budget exhausted; escalating on the next run", "reason", s.budget.exhausted(true))
+		return false
+	}
+	subject := fmt.Sprintf("%d stale security update(s) in %s", len(updates), s.repoName)
+	if err := s.mail.sendEmail(out, "", contact, subject, body, ""); err != nil {
+		s.budget.refundEmail()
+		out.Error("escalating security updates failed", "action", "escalate", "to", contact, "err", err)
+		return false
+	}
+	out.Info("escalated stale security updates", "count", len(updates), "to", contact)
+	return true
+}
+
+// unwarn removes the 'stale-warning' label from a PR that no longer needs it
+// and reports whether the label was removed, or in a dry run would be.
+func (s *repoScanner) unwarn(out *prOutput, pr *github.PullRequest) (removed bool) {
+	s.removeTitlePrefix(out, pr)
+	if !hasLabel(pr, s.cfg.Rules.StaleLabel) {
+		return false
+	}
+	if reason := s.budget.exhausted(false); reason != "" {
+		s.deferAction(out, pr, fmt.Sprintf("remove '%s' label", s.cfg.Rules.StaleLabel), reason)
+		return false
+	}
+	if s.guard.would(out, "unwarn", "remove the '%s' label from PR #%d", s.cfg.Rules.StaleLabel, pr.GetNumber()) {
+		return true
+	}
+	out.Info("removing label", "label", s.cfg.Rules.StaleLabel)
+	err := removeLabel(s.client, s.owner, s.repo, pr.GetNumber(), s.cfg.Rules.StaleLabel)
+	if err != nil {
+		out.Error("removing label failed", "action", "remove-label", "label", s.cfg.Rules.StaleLabel, "err", err)
+	} else {
+		removed = true
+		out.Info("removed label", "label", s.cfg.Rules.StaleLabel)
+		s.hooks.send(out, webhookLabelRemoved, pr, s.cfg.Rules.StaleLabel, nil)
+		s.repoSt.forget(pr.GetNumber())
+	}
+	// A PR moved out of the parked milestone also loses its terminal label.
+	if s.cfg.Rules.ParkedMilestone != "" && hasLabel(pr, "closed-stale") {
+		if err := removeLabel(s.client, s.owner, s.repo, pr.GetNumber(), "closed-stale"); err != nil {
+			out.Error("removing label failed", "action", "remove-label", "label", "closed-stale", "err", err)
+		}
+	}
+	return removed
+}
+
+// thankForUpdate tells the author of a PR whose warning was lifted because it
+// became active again when it can next go stale, with --notify-on-unstale.
+func (s *repoScanner) thankForUpdate(out *prOutput, pr *github.PullRequest, data notificationData, decision prDecision) {
+	if !*s.flags.notifyOnUnstale || decision.Action != actionActive || decision.StaleAt.IsZero() {
+		return
+	}
+	if s.repoSt.unstaleNotifiedWithin(pr.GetNumber(), *s.flags.unstaleCooldown, time.Now()) {
+		out.Info("not thanking the author: they were thanked for this PR recently", "cooldown", *s.flags.unstaleCooldown)
+		return
+	}
+	if reason := s.budget.exhausted(s.mail.byEmail(pr)); reason != "" {
+		out.Warn("budget exhausted; not thanking the author", "reason", reason)
+		return
+	}
+	data.NextReview = decision.StaleAt
+	if s.guard.would(out, "notify-unstale", "thank %s for updating PR #%d", s.mail.recipient(out, pr), pr.GetNumber()) {
+		return
+	}
+	if err := thankPRAuthor(out, pr, data, s.mail); errors.Is(err, errEmailBudgetExhausted) {
+		out.Warn("budget exhausted; not thanking the author", "reason", s.budget.exhausted(true))
+		return
+	} else if err != nil {
+		out.Error("thanking the author failed", "action", "notify-unstale", "err", err)
+		return
+	}
+	out.Info("thanked the author", "next_review", decision.StaleAt.Format(time.RFC3339))
+	s.repoSt.markNotificationSent(unstaleKey(pr.GetNumber(), decision.LastActivity), time.Now())
+}
+
+// evaluate decides what to do with a PR, or takes the action a plan being
+// executed gives it.
+func (s *repoScanner) evaluate(pr *github.PullRequest) (*prOutput, prDecision) {
+	out := s.sink.Begin(pr.GetNumber())
+	out.Info("processing PR", "title", pr.GetTitle())
+	decision := evaluatePR(pr, s.signalsFor(out, pr), s.cfg.Rules, time.Now())
+	if s.plan != nil {
+		if planned, _ := s.plan.action(pr.GetNumber()); planned.Action != decision.Action {
+			decision.tracef("planned action %s replaces %s", planned.Action, decision.Action)
+			decision.Action = planned.Action
+		}
+	}
+	return out, decision
+}
+
+// act records a PR's decision and takes its action.
+func (s *repoScanner) act(out *prOutput, pr *github.PullRequest, decision prDecision) {
+	s.summary.Evaluated++
+	if s.summary.Softened != "" {
+		switch decision.Action {
+		case actionClose:
+			decision.tracef("not closing: the repository is in warn-only mode")
+			decision.Action = actionWait
+		case actionCloseNow:
+			decision.tracef("not closing: the repository is in warn-only mode")
+			decision.Action = actionWarn
+			if hasLabel(pr, s.cfg.Rules.StaleLabel) {
+				decision.Action = actionWait
+			}
+		}
+	}
+	if s.newPlan != nil {
+		s.newPlan.add(pr, decision.Action)
+	}
+	if *s.flags.slaMetrics && !s.issues[pr.GetNumber()] {
+		s.repoSt.slaSeen(pr, s.cfg.Rules.StaleLabel, time.Now())
+	}
+	if *s.flags.detectDuplicates && isStaleAction(decision.Action) && !s.issues[pr.GetNumber()] {
+		s.stalePRs = append(s.stalePRs, pr)
+	}
+	newlyExempt := decision.Action == actionExempt && s.repoSt.Classifications[pr.GetNumber()] != actionExempt
+	s.repoSt.classify(s.summary.Delta, pr, decision.Action)
+	for _, line := range decision.Trace {
+		out.Info(line)
+	}
+	evaluated := newPREvent(eventEvaluated, s.repoName, pr)
+	evaluated.Action = decision.Action
+	evaluated.Rule = decision.Rule
+	evaluated.CloseReason = decision.CloseReason
+	if s.events != nil {
+		reviews, known := s.reviewStates[pr.GetNumber()]
+		if !known && *s.flags.reportDetail == reportDetailFull && !s.issues[pr.GetNumber()] {
+			if timeline, err := getTimeline(s.client, s.owner, s.repo, pr.GetNumber()); err != nil {
+				out.Error("reading reviews failed", "action", "read-reviews", "err", err)
+			} else {
+				reviews = latestReviews(timeline)
+			}
+		}
+		evaluated.Review = newReviewDetail(pr, decision, reviews)
+	}
+	s.events.Emit(evaluated)
+	if decision.SecurityReason != "" {
+		s.summary.SecurityExempt = append(s.summary.SecurityExempt, fmt.Sprintf("PR #%d (@%s): %s", pr.GetNumber(), pr.GetUser().GetLogin(), decision.SecurityReason))
+	}
+	if decision.Paused {
+		out.Info("pausing draft PR: its author converted it while warned")
+		s.summary.Paused++
+	} else if decision.Draft {
+		out.Info("skipping draft PR")
+		s.summary.Drafts++
+	}
+	if decision.ExemptAuthor != "" {
+		s.summary.AuthorExempt++
+	}
+	if decision.SecurityUpdate != "" {
+		s.summary.SecurityUpdates++
+	}
+	if decision.ExemptLabel != "" {
+		s.summary.Exemptions[decision.ExemptLabel]++
+	}
+	if decision.WaitingOnReview != "" {
+		s.summary.WaitingOnReview++
+	}
+	if decision.Override != nil {
+		s.summary.PolicyOverrides[fmt.Sprintf("%s=%s", decision.Override.Pattern, decision.Override.Policy)]++
+	}
+
+	data := newNotificationData(s.cfg, pr, s.owner, s.repo, time.Now())
+	data.Kind = s.kindOf(pr)
+	data.PathProtected = cachedProtectedPathMatch(pr, s.pathsOf(pr), s.repoSt)
+	data.FailingChecks = decision.FailingChecks
+	data.Inactive = time.Since(decision.LastActivity)
+	data.ReopenedAt = decision.ReopenedAt
+	data.CloseReason = decision.CloseReason
+
+	// Answer a "/stale status" request with the assessment.
+	if requester, ok := s.statusRequests[pr.GetNumber()]; ok {
+		body, err := s.tmpl.render("status reply", statusReplyTemplate, statusReplyData{
+			Marker:       statusMarker,
+			Requester:    requester,
+			Now:          time.Now(),
+			Location:     s.cfg.location(requester),
+			LastActivity: decision.LastActivity,
+			Decision:     decision,
+		})
+		if err != nil {
+			out.Error("rendering status reply failed", "action", "status-reply", "err", err)
+		} else if reason := s.budget.exhausted(false); reason != "" {
+			out.Warn("budget exhausted; not replying to the status request", "reason", reason)
+		} else if s.guard.would(out, "status reply", "reply to the status request from @%s on PR #%d", requester, pr.GetNumber()) {
+		} else if err := postStatusReply(s.client, s.owner, s.repo, pr.GetNumber(), s.botLogin, body); err != nil {
+			out.Error("replying to status request failed", "action", "status-reply", "err", err)
+		} else {
+			out.Info("replied to status request", "requester", requester)
+		}
+	}
+
+	// Expired time-boxed exemptions are removed so the labels do not
+	// linger.
+	for _, label := range decision.TimeBoxed.Invalid {
+		out.Warn("time-boxed exemption label has no valid YYYY-MM-DD date; it does not exempt the PR", "label", label)
+	}
+	for _, label := range decision.TimeBoxed.Expired {
+		if reason := s.budget.exhausted(false); reason != "" {
+			s.deferAction(out, pr, fmt.Sprintf("remove '%s' label", label), reason)
+		} else if s.guard.would(out, "unwarn", "remove the expired '%s' label from PR #%d", label, pr.GetNumber()) {
+		} else if err := removeLabel(s.client, s.owner, s.repo, pr.GetNumber(), label); err != nil {
+			out.Error("removing label failed", "action", "remove-label", "label", label, "err", err)
+		} else {
+			out.Info("removed expired exemption label", "label", label)
+			s.hooks.send(out, webhookLabelRemoved, pr, label, &decision)
+			s.summary.ExemptionsExpired++
+		}
+	}
+	if decision.Rule == ruleExemptUntil {
+		s.summary.ExemptUntil++
+	}
+
+	// A close request by someone who cannot push is dropped.
+	if cr := decision.CloseRequest; cr != nil && !cr.Allowed {
+		s.summary.CloseRequestsIgnored++
+		if reason := s.budget.exhausted(false); reason != "" {
+			s.deferAction(out, pr, fmt.Sprintf("remove '%s' label", cr.Label), reason)
+		} else if s.guard.would(out, "unwarn", "remove the '%s' label from PR #%d", cr.Label, pr.GetNumber()) {
+		} else if err := removeLabel(s.client, s.owner, s.repo, pr.GetNumber(), cr.Label); err != nil {
+			out.Error("removing label failed", "action", "remove-label", "label", cr.Label, "err", err)
+		} else {
+			out.Info("removed close request label: only maintainers who can push may use it", "label", cr.Label, "by", cr.By)
+		}
+	}
+
+	switch decision.Action {
+	case actionCloseRequested:
+		s.closeRequested(out, pr, decision, data)
+	case actionExempt, actionActive:
+		if newlyExempt {
+			s.hooks.send(out, webhookExempted, pr, "", &decision)
+		}
+		if s.unwarn(out, pr) {
+			s.thankForUpdate(out, pr, data, decision)
+		}
+		if decision.SecurityUpdate != "" && hasLabel(pr, securityStaleLabel) {
+			if reason := s.budget.exhausted(false); reason != "" {
+				s.deferAction(out, pr, fmt.Sprintf("remove '%s' label", securityStaleLabel), reason)
+			} else if s.guard.would(out, "unwarn", "remove the '%s' label from PR #%d", securityStaleLabel, pr.GetNumber()) {
+			} else if err := removeLabel(s.client, s.owner, s.repo, pr.GetNumber(), securityStaleLabel); err != nil {
+				out.Error("removing label failed", "action", "remove-label", "label", securityStaleLabel, "err", err)
+			} else {
+				out.Info("removed label", "label", securityStaleLabel)
+			}
+		}
+	case actionParked:
+	case actionEscalate:
+		// Escalated PRs leave the stale pipeline.
+		s.unwarn(out, pr)
+		s.summary.Escalated = append(s.summary.Escalated, fmt.Sprintf("PR #%d: %s", pr.GetNumber(), decision.SecurityUpdate))
+		update := escalatedUpdate{
+			Number:       pr.GetNumber(),
+			Title:        pr.GetTitle(),
+			URL:          pr.GetHTMLURL(),
+			Reason:       decision.SecurityUpdate,
+			LastActivity: decision.LastActivity,
+		}
+		s.escalated = append(s.escalated, update)
+		if hasLabel(pr, securityStaleLabel) {
+			out.Info("PR was already escalated")
+		} else {
+			out.Info("PR will be escalated")
+			s.newlyEscalated = append(s.newlyEscalated, pr.GetNumber())
+		}
+	case actionQuestion:
+		s.nudge(out, pr, decision)
+	case actionAway:
+		s.summary.Away = append(s.summary.Away, fmt.Sprintf("PR #%d (@%s): %s", pr.GetNumber(), pr.GetUser().GetLogin(), decision.AwayReason))
+		if _, warned := s.repoSt.Deadlines[pr.GetNumber()]; warned && !decision.CloseAt.IsZero() {
+			s.repoSt.Deadlines[pr.GetNumber()] = decision.CloseAt
+		}
+	case actionCloseNow:
+		s.closeNow(out, pr, decision, data)
+	case actionClose:
+		s.closeStale(out, pr, decision, data)
+	case actionWait:
+		s.remind(out, pr, decision, data)
+	case actionWarn:
+		s.warn(out, pr, decision, data)
+	}
+}
+
+// closeRequested closes a PR at the request of a maintainer who applied the
+// close-now label.
+func (s *repoScanner) closeRequested(out *prOutput, pr *github.PullRequest, decision prDecision, data notificationData) {
+	by := decision.CloseRequest.By
+	out.Info("closing PR at a maintainer's request", "by", by)
+	if reason := s.budget.exhausted(false); reason != "" {
+		s.deferAction(out, pr, "close", reason)
+	} else if s.guard.would(out, "close", "close PR #%d at the request of @%s and remove the '%s' label", pr.GetNumber(), by, decision.CloseRequest.Label) {
+	} else if err := closeOnRequest(s.client, s.tmpl, s.owner, s.repo, pr.GetNumber(), closeRequestData{data, by}, decision.CloseRequest.Label); err != nil {
+		out.Error("closing PR failed", "action", "close", "err", err)
+	} else {
+		out.Info("closed PR at a maintainer's request", "by", by)
+		s.summary.Closed = append(s.summary.Closed, pr)
+		s.summary.CloseRequested++
+		s.repoSt.slaResolved(s.summary.SLA, pr.GetNumber(), time.Now())
+		s.announce(out, newPREvent(eventClosed, s.repoName, pr))
+		s.hooks.send(out, webhookClosed, pr, "", &decision)
+		s.repoSt.forget(pr.GetNumber())
+		s.removeTitlePrefix(out, pr)
+	}
+}
+
+// nudge mentions the maintainers on a PR whose author's question went
+// unanswered.
+func (s *repoScanner) nudge(out *prOutput, pr *github.PullRequest, decision prDecision) {
+	q := decision.Question
+	s.summary.WaitingOnMaintainer = append(s.summary.WaitingOnMaintainer, fmt.Sprintf("PR #%d (@%s): %s", pr.GetNumber(), pr.GetUser().GetLogin(), q.Excerpt))
+	if nudged, ok := s.repoSt.Nudges[pr.GetNumber()]; ok && nudged.Equal(q.At) {
+		out.Info("maintainers were already nudged about the question")
+		return
+	}
+	if reason := s.budget.exhausted(false); reason != "" {
+		s.deferAction(out, pr, "nudge maintainers", reason)
+		return
+	}
+	mentions, err := maintainersToNudge(pr, func() ([]string, error) { return s.codeOwnersOfPR(pr) })
+	if err != nil {
+		out.Error("finding maintainers failed, nudging without mentions", "action", "nudge", "err", err)
+	}
+	who := "the maintainers"
+	if len(mentions) > 0 {
+		who = strings.Join(mentions, ", ")
+	}
+	if s.guard.would(out, "nudge", "nudge %s about the unanswered question on PR #%d", who, pr.GetNumber()) {
+		return
+	}
+	body, err := s.tmpl.render("question nudge", questionNudgeTemplate, questionNudgeData{
+		Mentions: mentions,
+		Author:   pr.GetUser().GetLogin(),
+		AskedAt:  q.At,
+		Excerpt:  q.Excerpt,
+		Inactive: time.Since(decision.LastActivity),
+		Location: s.cfg.DisplayLocation,
+	})
+	if err != nil {
+		out.Error("rendering nudge failed", "action", "nudge", "err", err)
+	} else if err := postComment(s.client, s.owner, s.repo, pr.GetNumber(), body); err != nil {
+		out.Error("nudging maintainers failed", "action", "nudge", "err", err)
+	} else {
+		out.Info("nudged maintainers about the unanswered question", "who", who)
+		s.repoSt.Nudges[pr.GetNumber()] = q.At
+	}
+}
+
+// closeNow closes a PR without a warning, as its author's policy says.
+func (s *repoScanner) closeNow(out *prOutput, pr *github.PullRequest, decision prDecision, data notificationData) {
+	out.Info("closing PR immediately by author policy")
+	if reason := s.budget.exhausted(false); reason != "" {
+		s.deferAction(out, pr, "close", reason)
+	} else if file, pattern, err := protectedPathMatch(s.client, s.owner, s.repo, pr, s.pathsOf(pr), s.repoSt); err != nil {
+		out.Error("not closing PR", "action", "close", "err", err)
+	} else if pattern != "" {
+		out.Info("not closing PR: it changes a protected path; a maintainer will follow up", "path", file, "pattern", pattern)
+		s.summary.PathProtected++
+	} else if !passesCloseSafetyCheck(out, s.client, s.owner, s.repo, pr, !s.issues[pr.GetNumber()], s.botLogin, *s.flags.closeSafetyWindow) {
+		s.summary.SafetyAborted++
+	} else if s.guard.would(out, "close", "close PR #%d%s", pr.GetNumber(), parkedSuffix(s.cfg.Rules.ParkedMilestone)) {
+	} else if ms, err := s.staleMilestone(); err != nil {
+		out.Error("not closing PR", "action", "close", "err", err)
+	} else if err := closeStalePRImmediately(s.client, s.tmpl, s.owner, s.repo, pr, data, ms); err != nil {
+		out.Error("closing PR failed", "action", "close", "err", err)
+	} else {
+		out.Info("closed PR"+parkedSuffix(s.cfg.Rules.ParkedMilestone), "close_reason", decision.CloseReason)
+		s.summary.Closed = append(s.summary.Closed, pr)
+		if decision.CloseReason != "" {
+			s.summary.CloseReasons[decision.CloseReason]++
+		}
+		closed := newPREvent(eventClosed, s.repoName, pr)
+		closed.CloseReason = decision.CloseReason
+		s.repoSt.slaResolved(s.summary.SLA, pr.GetNumber(), time.Now())
+		s.announce(out, closed)
+		s.hooks.send(out, webhookClosed, pr, "", &decision)
+		s.repoSt.forget(pr.GetNumber())
+		s.removeTitlePrefix(out, pr)
+	}
+}
+
+// closeStale closes, or parks, a PR that stayed inactive for the warning
+// period and notifies its author.
+func (s *repoScanner) closeStale(out *prOutput, pr *github.PullRequest, decision prDecision, data notificationData) {
+	out.Info("closing PR: inactive after the warning period")
+	if reason := s.budget.exhausted(s.mail.byEmail(pr)); reason != "" {
+		s.deferAction(out, pr, "close", reason)
+	} else if file, pattern, err := protectedPathMatch(s.client, s.owner, s.repo, pr, s.pathsOf(pr), s.repoSt); err != nil {
+		out.Error("not closing PR", "action", "close", "err", err)
+	} else if pattern != "" {
+		out.Info("not closing PR: it changes a protected path; a maintainer will follow up", "path", file, "pattern", pattern)
+		s.summary.PathProtected++
+	} else if !passesCloseSafetyCheck(out, s.client, s.owner, s.repo, pr, !s.issues[pr.GetNumber()], s.botLogin, *s.flags.closeSafetyWindow) {
+		s.summary.SafetyAborted++
+	} else if s.guard.would(out, "close", "close PR #%d%s and notify %s", pr.GetNumber(), parkedSuffix(s.cfg.Rules.ParkedMilestone), s.mail.recipient(out, pr)) {
+	} else if ms, err := s.staleMilestone(); err != nil {
+		out.Error("not closing PR", "action", "close", "err", err)
+	} else if err := closeOrParkPR(s.client, s.owner, s.repo, pr.GetNumber(), ms); err != nil {
+		out.Error("closing PR failed", "action", "close", "err", err)
+	} else {
+		out.Info("closed PR"+parkedSuffix(s.cfg.Rules.ParkedMilestone), "close_reason", decision.CloseReason)
+		s.summary.Closed = append(s.summary.Closed, pr)
+		if decision.CloseReason != "" {
+			s.summary.CloseReasons[decision.CloseReason]++
+		}
+		closed := newPREvent(eventClosed, s.repoName, pr)
+		closed.CloseReason = decision.CloseReason
+		s.repoSt.slaResolved(s.summary.SLA, pr.GetNumber(), time.Now())
+		s.announce(out, closed)
+		s.hooks.send(out, webhookClosed, pr, "", &decision)
+		s.repoSt.forget(pr.GetNumber())
+		s.removeTitlePrefix(out, pr)
+		// Notify PR author of closure.
+		err = notifyPRClosure(out, pr, data, s.mail)
+		if err != nil {
+			out.Error("sending closure notification failed", "action", "email", "kind", notificationClosure, "err", err)
+			n := s.pending(notificationClosure, pr)
+			n.CloseReason = decision.CloseReason
+			s.failNotification(out, n, err)
+		} else {
+			out.Info("sent closure notification")
+			s.repoSt.markNotificationSent(notificationKey(notificationClosure, pr), time.Now())
+		}
+	}
+}
+
+// remind sends a warned PR's author the reminders that are due.
+func (s *repoScanner) remind(out *prOutput, pr *github.PullRequest, decision prDecision, data notificationData) {
+	// PRs warned before the prefix was enabled get it now; only
+	// the state file remembers a prefix removed by hand.
+	if *s.flags.stateFile != "" && hasLabel(pr, s.cfg.Rules.StaleLabel) {
+		s.addTitlePrefix(out, pr, false)
+	}
+	deadline, ok := s.repoSt.Deadlines[pr.GetNumber()]
+	if !ok {
+		deadline = decision.CloseAt
+	}
+	offset, keys := dueReminder(s.reminders, s.repoSt.Reminders[pr.GetNumber()], deadline, time.Now())
+	if len(keys) == 0 {
+		return
+	}
+	if reason := s.budget.exhausted(s.mail.byEmail(pr)); reason != "" {
+		s.deferAction(out, pr, "remind", reason)
+		return
+	}
+	if s.guard.would(out, "remind", "send %s the %s reminder for PR #%d", s.mail.recipient(out, pr), reminderKey(offset), pr.GetNumber()) {
+		return
+	}
+	out.Info("sending reminder", "reminder", reminderKey(offset))
+	data.Deadline = deadline
+	data.DaysRemaining = int(math.Ceil(deadline.Sub(time.Now()).Hours() / 24))
+	if err := remindPRAuthor(out, pr, data, s.mail); errors.Is(err, errEmailBudgetExhausted) {
+		s.deferAction(out, pr, "remind", s.budget.exhausted(true))
+	} else if err != nil {
+		out.Error("sending reminder failed", "action", "remind", "err", err)
+	} else {
+		out.Info("sent reminder")
+		s.repoSt.Reminders[pr.GetNumber()] = append(s.repoSt.Reminders[pr.GetNumber()], keys...)
+	}
+}
+
+// warn warns the author of a stale PR and labels it.
+func (s *repoScanner) warn(out *prOutput, pr *github.PullRequest, decision prDecision, data notificationData) {
+	if s.repoSt.hasPendingNotification(pr.GetNumber()) {
+		out.Info("warning email is queued for retry")
+	} else if s.backfilling && !s.repoSt.Backfill.takeWarning(*s.flags.backfillDailyCap, time.Now()) {
+		out.Info("backfill: daily cap reached; the PR will be warned on a later day")
+		s.summary.BackfillRemaining++
+	} else if reason := s.budget.exhausted(s.mail.byEmail(pr)); reason != "" {
+		if s.backfilling {
+			s.repoSt.Backfill.releaseWarning()
+		}
+		s.deferAction(out, pr, "warn", reason)
+	} else if s.guard.would(out, "warn", "warn %s about PR #%d and label it '%s'", s.mail.recipient(out, pr), pr.GetNumber(), s.cfg.Rules.StaleLabel) {
+	} else {
+		out.Info("sending warning")
+		err := warnPRAuthor(out, pr, data, s.mail, s.warningAttachments(pr, data.Deadline)...)
+		if errors.Is(err, errEmailBudgetExhausted) {
+			if s.backfilling {
+				s.repoSt.Backfill.releaseWarning()
+			}
+			s.deferAction(out, pr, "warn", s.budget.exhausted(true))
+		} else if err != nil {
+			out.Error("sending warning failed", "action", "warn", "err", err)
+			s.failNotification(out, s.pending(notificationWarning, pr), err)
+		} else {
+			out.Info("sent warning")
+			s.repoSt.markNotificationSent(notificationKey(notificationWarning, pr), time.Now())
+			s.summary.Warned = append(s.summary.Warned, pr)
+			s.repoSt.slaWarned(s.summary.SLA, pr.GetNumber(), time.Now())
+			s.announce(out, newPREvent(eventWarned, s.repoName, pr))
+			s.hooks.send(out, webhookWarned, pr, "", &decision)
+			err = addLabel(s.client, s.owner, s.repo, pr.GetNumber(), s.cfg.Rules.StaleLabel)
+			if err != nil {
+				out.Error("adding label failed", "action", "add-label", "label", s.cfg.Rules.StaleLabel, "err", err)
+			} else {
+				s.hooks.send(out, webhookLabelAdded, pr, s.cfg.Rules.StaleLabel, &decision)
+				s.repoSt.Deadlines[pr.GetNumber()] = data.Deadline
+				s.addTitlePrefix(out, pr, true)
+				// Reminders already due are covered by the warning itself.
+				_, s.repoSt.Reminders[pr.GetNumber()] = dueReminder(s.reminders, nil, data.Deadline, time.Now())
+			}
+		}
+	}
+}
+
+// duplicatesPass points the authors of stale PRs that duplicate each other to
+// the newest.
+func (s *repoScanner) duplicatesPass() {
+	out := s.sink.Begin(0)
+	candidates := duplicateCandidates(s.stalePRs)
+	if len(candidates) > *s.flags.duplicateMaxPRs {
+		out.Warn("too many stale PRs to compare for duplicates; comparing the first ones", "candidates", len(candidates), "max", *s.flags.duplicateMaxPRs)
+		candidates = candidates[:*s.flags.duplicateMaxPRs]
+	}
+	files := map[int][]string{}
+	for _, pr := range candidates {
+		if reason := s.budget.exhausted(false); reason != "" {
+			out.Warn("not comparing the remaining PRs for duplicates", "reason", reason)
+			break
+		}
+		f, err := changedFiles(s.client, s.owner, s.repo, pr, s.repoSt)
+		if err != nil {
+			out.Error("listing changed files failed", "action", "detect-duplicates", "number", pr.GetNumber(), "err", err)
+			continue
+		}
+		files[pr.GetNumber()] = f
+	}
+	s.summary.Duplicates = findDuplicateGroups(candidates, files, *s.flags.duplicateSimilarity)
+	for _, g := range s.summary.Duplicates {
+		out.Info("possible duplicate PRs", "author", g.Author, "prs", g.Numbers)
+		if !*s.flags.commentOnDuplicates {
+			continue
+		}
+		for _, number := range g.Numbers[:len(g.Numbers)-1] {
+			if s.repoSt.DuplicateComments[number] == g.Newest() {
+				continue
+			}
+			var others []int
+			for _, n := range g.Numbers[:len(g.Numbers)-1] {
+				if n != number {
+					others = append(others, n)
+				}
+			}
+			if reason := s.budget.exhausted(false); reason != "" {
+				out.Warn("not commenting on duplicate PR", "number", number, "reason", reason)
+			} else if s.guard.would(out, "duplicate", "point PR #%d to its newer duplicate #%d", number, g.Newest()) {
+			} else if body, err := s.tmpl.render("duplicate comment", duplicateCommentTemplate, duplicateCommentData{g.Author, g.Newest(), others}); err != nil {
+				out.Error("rendering duplicate comment failed", "action", "duplicate", "err", err)
+			} else if err := postComment(s.client, s.owner, s.repo, number, body); err != nil {
+				out.Error("commenting on duplicate PR failed", "action", "duplicate", "number", number, "err", err)
+			} else {
+				out.Info("pointed duplicate PR to the newest", "number", number, "newest", g.Newest())
+				s.repoSt.DuplicateComments[number] = g.Newest()
+			}
+		}
+	}
+	s.sink.Log(out)
+}
+
+// escalationPass escalates stale security updates when new ones appear,
+// listing all of them, and labels the new ones once the contact has been
+// told.
+func (s *repoScanner) escalationPass() {
+	out := s.sink.Begin(0)
+	if s.escalateSecurityUpdates(out, s.escalated) {
+		for _, number := range s.newlyEscalated {
+			if reason := s.budget.exhausted(false); reason != "" {
+				out.Warn("budget exhausted; the PR will be escalated again on the next run", "pr", number, "reason", reason)
+				continue
+			}
+			if s.guard.would(out, "escalate", "label PR #%d '%s'", number, securityStaleLabel) {
+				continue
+			}
+			if err := addLabel(s.client, s.owner, s.repo, number, securityStaleLabel); err != nil {
+				out.Error("adding label failed", "pr", number, "action", "add-label", "label", securityStaleLabel, "err", err)
+			} else {
+				out.Info("added label", "pr", number, "label", securityStaleLabel)
+				s.summary.NewlyEscalated++
+			}
+		}
+	}
+	s.sink.Log(out)
+}
+
+// cleanupPass checks the warned PRs a partial scan did not list for activity
+// and un-warns those no longer stale: their activity may have left no event.
+func (s *repoScanner) cleanupPass() {
+	s.logger.Info("checking the other warned PRs for activity", "label", s.cfg.Rules.StaleLabel)
+	evaluated := map[int]bool{}
+	for _, pr := range s.openPRs {
+		evaluated[pr.GetNumber()] = true
+	}
+	var numbers []int
+	var warned []*github.PullRequest
+	labeled, err := getLabeledIssues(s.client, s.owner, s.repo, s.cfg.Rules.StaleLabel)
+	if err != nil {
+		s.logger.Error("listing warned PRs failed, skipping the cleanup pass", "action", "list-warned", "err", err)
+	}
+	for _, issue := range labeled {
+		switch {
+		case evaluated[issue.GetNumber()]:
+		case issue.IsPullRequest():
+			numbers = append(numbers, issue.GetNumber())
+		case *s.flags.includeIssues:
+			s.issues[issue.GetNumber()] = true
+			warned = append(warned, issueAsPR(issue))
+		}
+	}
+	prs, err := getPRsByNumber(s.client, s.owner, s.repo, numbers)
+	if err != nil {
+		s.logger.Error("fetching warned PRs failed, skipping the cleanup pass", "action", "list-warned", "err", err)
+	}
+	for _, pr := range append(prs, warned...) {
+		if !s.issues[pr.GetNumber()] && !s.baseBranches.allows(pr.GetBase().GetRef()) {
+			continue
+		}
+		out := s.sink.Begin(pr.GetNumber())
+		s.summary.CleanupChecked++
+		decision := evaluatePR(pr, s.signalsFor(out, pr), s.cfg.Rules, time.Now())
+		if decision.Action == actionExempt || decision.Action == actionActive {
+			for _, line := range decision.Trace {
+				out.Info(line)
+			}
+			s.summary.CleanupUnwarned++
+			if s.unwarn(out, pr) {
+				data := newNotificationData(s.cfg, pr, s.owner, s.repo, time.Now())
+				data.Kind = s.kindOf(pr)
+				data.Inactive = time.Since(decision.LastActivity)
+				s.thankForUpdate(out, pr, data, decision)
+			}
+		}
+		s.sink.Log(out)
+	}
+}
+
+// report logs the repository's run summary and records and publishes it.
+func (s *repoScanner) report() {
+	apiCalls, emails := s.budget.usage()
+	s.summary.APICalls, s.summary.Emails = apiCalls-s.apiCallsBefore, emails-s.emailsBefore
+	s.summary.BlockedWrites = s.guard.blockedWrites()
+	s.summary.Would = s.guard.wouldCounts()
+	s.summary.Suppressed = s.mail.suppressed
+	if s.dispatch != nil {
+		s.summary.DispatchesFailed, s.summary.DispatchesSkipped = s.dispatch.failed, s.dispatch.skipped
+	}
+	if s.hooks != nil {
+		s.summary.WebhooksFailed = s.hooks.failed
+	}
+	logSummary(s.logger, s.summary)
+	s.events.Emit(newSummaryEvent(s.repoName, s.summary))
+
+	// Record aggregate metrics for trend analysis.
+	if *s.flags.trendsFile != "" && !*s.flags.readOnly {
+		rec := newTrendRecord(s.owner, s.repo, s.fullScan, s.openPRs, s.summary, s.started, time.Now())
+		trendsRotation := s.rotate
+		trendsRotation.maxSize = int64(*s.flags.trendsMaxBytes)
+		if err := appendTrend(*s.flags.trendsFile, rec, trendsRotation); err != nil {
+			s.logger.Error("writing trends file failed", "file", *s.flags.trendsFile, "err", err)
+		}
+	}
+
+	// Publish the run summary to the marker issue and/or gist.
+	if *s.flags.summaryIssue || *s.flags.summaryGistID != "" {
+		body, err := renderRunSummary(s.tmpl, s.owner, s.repo, s.summary, time.Now())
+		if err != nil {
+			s.logger.Error("rendering run summary failed", "action", "publish-summary", "err", err)
+		} else {
+			if *s.flags.summaryIssue {
+				if err := updateSummaryIssue(s.client, s.owner, s.repo, body); err != nil {
+					s.logger.Error("updating summary issue failed", "action", "publish-summary", "err", err)
+				}
+			}
+			if *s.flags.summaryGistID != "" {
+				if err := updateSummaryGist(s.client, *s.flags.summaryGistID, s.owner, s.repo, body); err != nil {
+					s.logger.Error("updating summary gist failed", "action", "publish-summary", "err", err)
+				}
+			}
+		}
+	}
+
+	// Post the run summary to a GitHub Discussion.
+	if *s.flags.discussionCategory != "" {
+		s.logger.Info("posting run summary to GitHub Discussions", "category", *s.flags.discussionCategory)
+		err := postDiscussionSummary(s.client, s.tmpl, *s.flags.githubBaseURL, s.owner, s.repo, *s.flags.discussionCategory, *s.flags.discussionMode, s.summary, time.Now())
+		if err != nil {
+			s.logger.Error("posting discussion summary failed", "action", "publish-summary", "err", err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// fakeGitHub serves the GitHub API for scanner tests. GETs of the paths in
+// get are answered with their JSON and other GETs with an empty list; every
+// write is recorded and answered with an empty object, or an empty list
+// for the label additions GitHub answers with the issue's labels. Requests for paths in
+// redirects are redirected permanently, as GitHub does for renamed
+// repositories.
+type fakeGitHub struct {
+	mu        sync.Mutex
+	get       map[string]string
+	redirects map[string]string
+	writes    []string
+}
+
+func (f *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if to, ok := f.redirects[r.URL.Path]; ok {
+		http.Redirect(w, r, to, http.StatusMovedPermanently)
+		return
+	}
+	if r.Method == http.MethodGet {
+		if body, ok := f.get[r.URL.Path]; ok {
+			fmt.Fprint(w, body)
+			return
+		}
+		fmt.Fprint(w, `[]`)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	f.writes = append(f.writes, strings.TrimSpace(r.Method+" "+r.URL.Path+" "+string(body)))
+	if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/labels") {
+		fmt.Fprint(w, `[]`)
+		return
+	}
+	fmt.Fprint(w, `{}`)
+}
+
+// written returns the writes made so far, sorted.
+func (f *fakeGitHub) written() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	writes := append([]string(nil), f.writes...)
+	sort.Strings(writes)
+	return writes
+}
+
+// newTestRepoScanner returns a scanner for acme/api on gh, with the built-in
+// defaults of the flags overridden by args. A nil cfg uses testRules and
+// notifies authors in comments.
+func newTestRepoScanner(t *testing.T, gh *fakeGitHub, cfg *config, args ...string) *repoScanner {
+	t.Helper()
+	captureLog(t)
+	fs := flag.NewFlagSet("stale-pr-bot", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	flags := defineFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if cfg == nil {
+		cfg = &config{Rules: testRules(), DisplayLocation: time.UTC, NotifyVia: notifyComment}
+	}
+	client, _ := newTestGitHubClient(t, gh)
+	tmpl := newTemplateRenderer(cfg)
+	guard := newWriteGuard(*flags.readOnly, *flags.dryRun)
+	budget := newRunBudget(0, 0)
+	mail := newMailer(cfg, tmpl, guard, nil)
+	mail.budget = budget
+	sc := &scanner{
+		flags:     flags,
+		cfg:       cfg,
+		client:    client,
+		state:     newBotState(),
+		budget:    budget,
+		guard:     guard,
+		tmpl:      tmpl,
+		mail:      mail,
+		output:    io.Discard,
+		deadline:  newRunDeadline(context.Background(), time.Now(), 0, 0, time.Now),
+		interlock: newCloseInterlock(true, 0, strings.NewReader(""), io.Discard, nil),
+		botLogin:  "stale-bot",
+		started:   time.Now(),
+	}
+	s := newRepoScanner(sc, "acme", "api")
+	s.sink = newOutputSink(sc.output, true, false, 0, nil, s.repoName)
+	return s
+}
+
+// actOn evaluates pr and takes the action decided, as a scan does, and
+// returns the decision.
+func (s *repoScanner) actOn(pr *github.PullRequest) prDecision {
+	out, decision := s.evaluate(pr)
+	s.prepareActions([]prDecision{decision})
+	s.act(out, pr, decision)
+	s.sink.Finish(out, len(s.summary.Warned), len(s.summary.Closed))
+	return decision
+}
+
+// TestExemptLabelsCountedAndUnwarned acts on warned PRs carrying one or
+// several exempt labels: each loses its warning, and each exemption is
+// counted under the label that made it.
+func TestExemptLabelsCountedAndUnwarned(t *testing.T) {
+	gh := &fakeGitHub{}
+	cfg := &config{Rules: testRules(), DisplayLocation: time.UTC, NotifyVia: notifyComment}
+	cfg.Rules.ExemptLabels = []string{"do not stale", "pinned", "roadmap"}
+	s := newTestRepoScanner(t, gh, cfg)
+	old := time.Now().AddDate(0, 0, -45)
+	for number, labels := range map[int][]string{
+		1: {"stale-warning", "pinned"},
+		2: {"stale-warning", "roadmap", "pinned"},
+		3: {"stale-warning", "do not stale", "roadmap"},
+		4: {"roadmap"},
+	} {
+		pr := testPR("alice", old, labels...)
+		pr.Number = github.Ptr(number)
+		if d := s.actOn(pr); d.Action != actionExempt {
+			t.Errorf("PR #%d with %q decided %s, want exempt", number, labels, d.Action)
+		}
+	}
+
+	if want := map[string]int{"pinned": 2, "do not stale": 1, "roadmap": 1}; fmt.Sprint(s.summary.Exemptions) != fmt.Sprint(want) {
+		t.Errorf("exemptions counted %v, want %v", s.summary.Exemptions, want)
+	}
+	want := []string{
+		"DELETE /repos/acme/api/issues/1/labels/stale-warning",
+		"DELETE /repos/acme/api/issues/2/labels/stale-warning",
+		"DELETE /repos/acme/api/issues/3/labels/stale-warning",
+	}
+	if got := gh.written(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("writes %q, want the warning removed from the warned PRs:\n%q", got, want)
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultShutdownGrace is how long a daemon stopped by a signal waits for the
+// run in progress to wrap up.
+const defaultShutdownGrace = 5 * time.Minute
+
+// cronSchedule is a five-field cron schedule: minute, hour, day of month,
+// month and day of week, each a bit set of the values it matches.
+type cronSchedule struct {
+	spec                          string
+	minute, hour, dom, month, dow uint64
+	// domAll and dowAll are set when the field is "*". As in cron, a day
+	// matches if either day field does, unless one of them is "*".
+	domAll, dowAll bool
+	// hourAll is set when the hour field starts with "*", for schedules
+	// that run every hour or every few hours rather than at set hours.
+	hourAll bool
+}
+
+// cronMacros are the shorthands accepted in place of the five fields.
+var cronMacros = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// parseCronSchedule parses a schedule such as "0 3 * * *". Fields may be
+// "*", values, ranges ("1-5"), steps ("*/15", "0-30/10") and lists of
+// these; day of week 7 is Sunday, like 0. Names of months and days are not
+// supported.
+func parseCronSchedule(spec string) (*cronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 1 {
+		if expanded, ok := cronMacros[fields[0]]; ok {
+			fields = strings.Fields(expanded)
+		}
+	}
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule %q must have five fields: minute hour day-of-month month day-of-week", spec)
+	}
+	s := &cronSchedule{spec: spec, domAll: fields[2] == "*", dowAll: fields[4] == "*", hourAll: strings.HasPrefix(fields[1], "*")}
+	var err error
+	for _, f := range []struct {
+		name     string
+		min, max int
+		field    string
+		set      *uint64
+	}{
+		{"minute", 0, 59, fields[0], &s.minute},
+		{"hour", 0, 23, fields[1], &s.hour},
+		{"day of month", 1, 31, fields[2], &s.dom},
+		{"month", 1, 12, fields[3], &s.month},
+		{"day of week", 0, 7, fields[4], &s.dow},
+	} {
+		if *f.set, err = parseCronField(f.field, f.min, f.max); err != nil {
+			return nil, fmt.Errorf("invalid %s in schedule %q: %v", f.name, spec, err)
+		}
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	if s.next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("schedule %q never runs", spec)
+	}
+	return s, nil
+}
+
+// parseCronField parses one field of a schedule into a bit set of the values
+// between min and max it matches.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepText)
+			}
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			loText, hiText, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(loText); err != nil {
+				return 0, fmt.Errorf("invalid value %q", loText)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(hiText); err != nil {
+					return 0, fmt.Errorf("invalid value %q", hiText)
+				}
+			} else if hasStep {
+				hi = max
+			}
+			if lo < min || hi > max || lo > hi {
+				return 0, fmt.Errorf("%q is out of range %d-%d", rng, min, max)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// next returns the first time after t the schedule matches, in t's
+// location, or the zero time if there is none within five years. Like cron,
+// a schedule set to run at given hours runs once a day across daylight
+// saving time changes; see step.
+func (s *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		var run bool
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			// Hours are stepped through in elapsed time, so each hour
+			// around a clock change is visited in turn.
+			t, run = s.step(t, t.Add(time.Duration(60-t.Minute())*time.Minute))
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t, run = s.step(t, t.Add(time.Minute))
+		default:
+			return t
+		}
+		if run {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// step moves the search for the next run from t to the later time next.
+// When the clocks go forward between them, a schedule with set hours runs
+// at once if one of them was skipped, which next then reports with run.
+// When the clocks go back, such a schedule skips the repeated hour, so it
+// does not run twice; schedules running every hour run through both.
+func (s *cronSchedule) step(t, next time.Time) (_ time.Time, run bool) {
+	if s.hourAll {
+		return next, false
+	}
+	_, before := t.Zone()
+	_, after := next.Zone()
+	switch {
+	case after > before && next.YearDay() == t.YearDay():
+		for h := t.Hour() + 1; h < next.Hour(); h++ {
+			if s.hour&(1<<uint(h)) != 0 {
+				return next, true
+			}
+		}
+	case after < before:
+		return next.Add(time.Duration(before-after) * time.Second), false
+	}
+	return next, false
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAll || s.dowAll {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// runTotals sums the results of a run for the run-end log line.
+func runTotals(results []repoResult) (evaluated, warned, closed, failed int) {
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+		if r.Summary != nil {
+			evaluated += r.Summary.Evaluated
+			warned += len(r.Summary.Warned)
+			closed += len(r.Summary.Closed)
+		}
+	}
+	return evaluated, warned, closed, failed
+}
+
+// runScheduled runs the bot as a daemon for --schedule: run is called at
+// every time of schedule, with a context of its own, until a signal arrives
+// on stop. A signal during a run cancels its context and waits up to grace
+// for it to wrap up. A run that panics is logged and counted as failed, and
+// the daemon carries on. runScheduled returns the exit code of the run a
+// signal interrupted, exitSuccess if none was in progress, or
+// exitPartialFailed if it did not finish within grace.
+func runScheduled(schedule *cronSchedule, grace time.Duration, stop <-chan os.Signal, run func(ctx context.Context) ([]repoResult, int)) int {
+	slog.Info("running as a daemon", "schedule", schedule.spec, "shutdown_grace", grace)
+	for n := 1; ; n++ {
+		next := schedule.next(time.Now())
+		slog.Info("next run scheduled", "at", next.Format(time.RFC3339), "in", time.Until(next).Round(time.Second))
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case sig := <-stop:
+			timer.Stop()
+			slog.Info("stopping the scheduler", "signal", sig)
+			return exitSuccess
+		case <-timer.C:
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan int, 1)
+		go func() { done <- scheduledRun(ctx, n, run) }()
+		select {
+		case <-done:
+			cancel()
+		case sig := <-stop:
+			slog.Info("stopping the scheduler; waiting for the run in progress to finish", "signal", sig, "run", n, "grace", grace)
+			cancel()
+			select {
+			case code := <-done:
+				return code
+			case <-time.After(grace):
+				slog.Error("the run in progress did not finish within the shutdown grace period; exiting without saving its state", "run", n, "grace", grace)
+				return exitPartialFailed
+			}
+		}
+	}
+}
+
+// scheduledRun makes run number n of a daemon, logging its start and end,
+// and returns its exit code.
+func scheduledRun(ctx context.Context, n int, run func(ctx context.Context) ([]repoResult, int)) (code int) {
+	started := time.Now()
+	slog.Info("run started", "run", n)
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("run panicked; the daemon carries on", "run", n, "panic", r, "stack", string(debug.Stack()))
+			code = exitPartialFailed
+		}
+	}()
+	results, code := run(ctx)
+	evaluated, warned, closed, failed := runTotals(results)
+	slog.Info("run finished", "run", n, "repos", len(results), "evaluated", evaluated, "warned", warned, "closed", closed, "failed_repos", failed, "exit_code", code, "duration", time.Since(started).Round(time.Second))
+	return code
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	// A Sunday.
+	from := time.Date(2026, 3, 15, 12, 34, 56, 0, time.UTC)
+	for _, tc := range []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 3, 15, 12, 35, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2026, 3, 16, 3, 0, 0, 0, time.UTC)},
+		{"30 12 * * *", time.Date(2026, 3, 16, 12, 30, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 3, 15, 12, 45, 0, 0, time.UTC)},
+		{"5,35 * * * *", time.Date(2026, 3, 15, 12, 35, 0, 0, time.UTC)},
+		{"0-30/10 13 * * *", time.Date(2026, 3, 15, 13, 0, 0, 0, time.UTC)},
+		{"10/20 * * * *", time.Date(2026, 3, 15, 12, 50, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2026, 3, 16, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2026, 3, 22, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 3, 22, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * 6 *", time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)},
+		// Either day field matches when neither is "*".
+		{"0 0 13 * 5", time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC)},
+		{"0 0 16 * 5", time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 3, 15, 13, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2026, 3, 22, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		s, err := parseCronSchedule(tc.spec)
+		if err != nil {
+			t.Errorf("parseCronSchedule(%q): %v", tc.spec, err)
+			continue
+		}
+		if got := s.next(from); !got.Equal(tc.want) {
+			t.Errorf("%q runs next at %v, want %v", tc.spec, got, tc.want)
+		}
+	}
+}
+
+func TestCronScheduleNextInLocation(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	s, err := parseCronSchedule("0 3 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := s.next(time.Date(2026, 3, 15, 12, 0, 0, 0, loc))
+	if want := time.Date(2026, 3, 16, 3, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("runs next at %v, want %v", got, want)
+	}
+}
+
+func TestCronScheduleAcrossDSTChanges(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	// In 2026 the clocks go forward from 02:00 to 03:00 on March 29 and
+	// back from 03:00 to 02:00 on October 25.
+	spring := time.Date(2026, 3, 28, 23, 0, 0, 0, loc)
+	fall := time.Date(2026, 10, 24, 23, 0, 0, 0, loc)
+	for _, tc := range []struct {
+		name string
+		spec string
+		from time.Time
+		want []string
+	}{
+		{
+			name: "spring forward: a skipped time runs at the change",
+			spec: "30 2 * * *",
+			from: spring,
+			want: []string{"Mar 29 03:00 CEST", "Mar 30 02:30 CEST"},
+		},
+		{
+			name: "spring forward: the hour after the change",
+			spec: "0 3 * * *",
+			from: spring,
+			want: []string{"Mar 29 03:00 CEST", "Mar 30 03:00 CEST"},
+		},
+		{
+			name: "spring forward: set hours around the change",
+			spec: "30 1-3 * * *",
+			from: spring,
+			want: []string{"Mar 29 01:30 CET", "Mar 29 03:00 CEST", "Mar 29 03:30 CEST", "Mar 30 01:30 CEST"},
+		},
+		{
+			name: "spring forward: every hour",
+			spec: "15 * * * *",
+			from: spring.Add(2 * time.Hour),
+			want: []string{"Mar 29 01:15 CET", "Mar 29 03:15 CEST", "Mar 29 04:15 CEST"},
+		},
+		{
+			name: "fall back: a repeated time runs once",
+			spec: "30 2 * * *",
+			from: fall,
+			want: []string{"Oct 25 02:30 CEST", "Oct 26 02:30 CET"},
+		},
+		{
+			name: "fall back: set hours around the change",
+			spec: "30 1-3 * * *",
+			from: fall,
+			want: []string{"Oct 25 01:30 CEST", "Oct 25 02:30 CEST", "Oct 25 03:30 CET", "Oct 26 01:30 CET"},
+		},
+		{
+			name: "fall back: every half hour runs through the repeated hour",
+			spec: "*/30 * * * *",
+			from: fall.Add(3 * time.Hour),
+			want: []string{"Oct 25 02:30 CEST", "Oct 25 02:00 CET", "Oct 25 02:30 CET", "Oct 25 03:00 CET"},
+		},
+	} {
+		s, err := parseCronSchedule(tc.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for next := tc.from; len(got) < len(tc.want); {
+			next = s.next(next)
+			got = append(got, next.Format("Jan 2 15:04 MST"))
+		}
+		if strings.Join(got, ", ") != strings.Join(tc.want, ", ") {
+			t.Errorf("%s: %q runs at %q, want %q", tc.name, tc.spec, got, tc.want)
+		}
+	}
+}
+
+func TestParseCronScheduleErrors(t *testing.T) {
+	for _, tc := range []struct {
+		spec, err string
+	}{
+		{"", "must have five fields"},
+		{"0 3 * *", "must have five fields"},
+		{"0 3 * * * *", "must have five fields"},
+		{"@yearly", "must have five fields"},
+		{"60 * * * *", "invalid minute"},
+		{"* 24 * * *", "invalid hour"},
+		{"* * 0 * *", "invalid day of month"},
+		{"* * * 13 *", "invalid month"},
+		{"* * * * 8", "invalid day of week"},
+		{"* * * * mon", `invalid value "mon"`},
+		{"5-1 * * * *", "out of range"},
+		{"1- * * * *", `invalid value ""`},
+		{"*/0 * * * *", `invalid step "0"`},
+		{"*/x * * * *", `invalid step "x"`},
+		{"0 0 31 2 *", "never runs"},
+	} {
+		if _, err := parseCronSchedule(tc.spec); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("parseCronSchedule(%q) returned %v, want an error containing %q", tc.spec, err, tc.err)
+		}
+	}
+}
+
+func TestRunScheduledStops(t *testing.T) {
+	s, err := parseCronSchedule("* * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan os.Signal, 1)
+	stop <- os.Interrupt
+	code := runScheduled(s, time.Second, stop, func(ctx context.Context) ([]repoResult, int) {
+		t.Error("a run started after the stop signal")
+		return nil, exitSuccess
+	})
+	if code != exitSuccess {
+		t.Errorf("exit code %d, want %d", code, exitSuccess)
+	}
+}
+
+func TestScheduledRunRecoversFromPanics(t *testing.T) {
+	code := scheduledRun(context.Background(), 1, func(ctx context.Context) ([]repoResult, int) {
+		panic("boom")
+	})
+	if code != exitPartialFailed {
+		t.Errorf("exit code %d after a panic, want %d", code, exitPartialFailed)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// advisoryForkPattern matches the names of the temporary private forks GitHub
+// creates for security advisories, e.g. "repo-ghsa-2x4w-8g9c-q6v5".
+var advisoryForkPattern = regexp.MustCompile(`-ghsa(-[23456789cfghjmpqrvwx]{4}){3}$`)
+
+// securityAdvisoryPattern matches the link to the GitHub advisory in the
+// body of a Dependabot security update, capturing the advisory ID.
+var securityAdvisoryPattern = regexp.MustCompile(`(?i)github\.com/advisories/(GHSA(?:-[23456789cfghjmpqrvwx]{4}){3})\b`)
+
+// securityStaleLabel marks stale security updates that were escalated.
+const securityStaleLabel = "security-stale"
+
+// securityUpdate returns how a PR was identified as a Dependabot security
+// update, or "" if it is not one. Dependabot labels these "security" and
+// links the advisory in the body; version updates have neither.
+func securityUpdate(pr *github.PullRequest) string {
+	switch strings.ToLower(pr.GetUser().GetLogin()) {
+	case "dependabot[bot]", "dependabot-preview[bot]":
+	default:
+		return ""
+	}
+	if hasLabel(pr, "security") {
+		return "Dependabot security update (label 'security')"
+	}
+	if m := securityAdvisoryPattern.FindStringSubmatch(pr.GetBody()); m != nil {
+		return fmt.Sprintf("Dependabot security update (advisory %s)", m[1])
+	}
+	return ""
+}
+
+const securityEscalationTemplate = `{{len .Updates}} Dependabot security update(s) in {{.Owner}}/{{.Repo}} have had no activity for over {{.DaysInactive}} days:
+{{range .Updates}}
+- #{{.Number}} {{.Title}}
+  {{.Reason}}; last activity {{formatDateIn .LastActivity $.Location}}
+  {{.URL}}
+{{end}}
+They are labeled '{{.Label}}' and will not be closed for inactivity. Please review and merge them, or close them if the vulnerable dependency is no longer used.
+`
+
+// securityEscalationData is the data passed to the security escalation
+// template.
+type securityEscalationData struct {
+	Owner        string
+	Repo         string
+	DaysInactive int
+	Label        string
+	Updates      []escalatedUpdate
+	Location     *time.Location
+}
+
+// escalatedUpdate is a stale security update listed in an escalation.
+type escalatedUpdate struct {
+	Number       int
+	Title        string
+	URL          string
+	Reason       string
+	LastActivity time.Time
+}
+
+// securityExemption returns why a PR is exempt as security work, or "" if it
+// is not.
+func securityExemption(pr *github.PullRequest, rules evaluationRules) string {
+	for _, label := range rules.SecurityLabels {
+		if hasLabel(pr, label) {
+			return fmt.Sprintf("security label '%s'", label)
+		}
+	}
+	if name := pr.GetHead().GetRepo().GetName(); advisoryForkPattern.MatchString(strings.ToLower(name)) {
+		return fmt.Sprintf("opened from security advisory fork %s", pr.GetHead().GetRepo().GetFullName())
+	}
+	login := strings.ToLower(pr.GetUser().GetLogin())
+	if rules.SecurityTeam[login] {
+		return fmt.Sprintf("author %s is a security team member", pr.GetUser().GetLogin())
+	}
+	return ""
+}
+
+// getTeamMembers returns the lower-cased logins of the members of an
+// organization team.
+func getTeamMembers(client *github.Client, org, slug string) (map[string]bool, error) {
+	ctx := context.Background()
+	members := map[string]bool{}
+	opt := &github.TeamListTeamMembersOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		users, resp, err := client.Teams.ListTeamMembersBySlug(ctx, org, slug, opt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list members of team %s/%s: %v", org, slug, err)
+		}
+		for _, u := range users {
+			members[strings.ToLower(u.GetLogin())] = true
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return members, nil
+}
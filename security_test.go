@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+func TestSecurityUpdate(t *testing.T) {
+	const advisory = "Bumps lodash from 4.17.15 to 4.17.21.\n\nSee https://github.com/advisories/GHSA-35jh-r3h4-6jhm for details."
+	for _, tc := range []struct {
+		name, login, body string
+		labels            []string
+		want              string
+	}{
+		{name: "labeled", login: "dependabot[bot]", labels: []string{"dependencies", "Security"}, want: "Dependabot security update (label 'security')"},
+		{name: "advisory link", login: "dependabot[bot]", body: advisory, want: "Dependabot security update (advisory GHSA-35jh-r3h4-6jhm)"},
+		{name: "preview app", login: "Dependabot-Preview[bot]", body: advisory, want: "Dependabot security update (advisory GHSA-35jh-r3h4-6jhm)"},
+		{name: "version update", login: "dependabot[bot]", body: "Bumps lodash from 4.17.20 to 4.17.21.", labels: []string{"dependencies"}},
+		{name: "malformed advisory", login: "dependabot[bot]", body: "https://github.com/advisories/GHSA-35jh-r3h4"},
+		{name: "other author", login: "alice", body: advisory, labels: []string{"security"}},
+		{name: "lookalike app", login: "dependabot", body: advisory},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pr := testPR(tc.login, time.Now(), tc.labels...)
+			pr.Body = github.Ptr(tc.body)
+			if got := securityUpdate(pr); got != tc.want {
+				t.Errorf("securityUpdate = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSecurityEscalationTemplate(t *testing.T) {
+	r := newTemplateRenderer(&config{DisplayLocation: time.UTC})
+	got, err := r.render("security escalation email", securityEscalationTemplate, securityEscalationData{
+		Owner:        "acme",
+		Repo:         "api",
+		DaysInactive: 30,
+		Label:        securityStaleLabel,
+		Updates: []escalatedUpdate{{
+			Number:       12,
+			Title:        "Bump lodash from 4.17.15 to 4.17.21",
+			URL:          "https://github.com/acme/api/pull/12",
+			Reason:       "Dependabot security update (label 'security')",
+			LastActivity: time.Date(2026, 2, 1, 10, 0, 0, 0, time.UTC),
+		}},
+		Location: time.UTC,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `1 Dependabot security update(s) in acme/api have had no activity for over 30 days:
+
+- #12 Bump lodash from 4.17.15 to 4.17.21
+  Dependabot security update (label 'security'); last activity February 1, 2026 (UTC)
+  https://github.com/acme/api/pull/12
+
+They are labeled 'security-stale' and will not be closed for inactivity. Please review and merge them, or close them if the vulnerable dependency is no longer used.
+`
+	if got != want {
+		t.Errorf("escalation:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestSecurityExemption exempts stale PRs by each trigger of --exempt-security
+// on its own, and checks that nothing is exempt without the flag.
+func TestSecurityExemption(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	old := now.AddDate(0, 0, -45)
+	for _, tc := range []struct {
+		name   string
+		login  string
+		labels []string
+		fork   string
+		want   string
+	}{
+		{name: "security label", login: "alice", labels: []string{"bug", "Embargoed"}, want: "security label 'embargoed'"},
+		{name: "advisory fork", login: "alice", fork: "api-ghsa-2345-6789-cfgh", want: "opened from security advisory fork acme/api-ghsa-2345-6789-cfgh"},
+		{name: "security team", login: "Mallory", want: "author Mallory is a security team member"},
+		{name: "lookalike fork", login: "alice", fork: "api-ghsa-1111-2222-3333"},
+		{name: "ordinary PR", login: "alice", labels: []string{"bug"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pr := testPR(tc.login, old, tc.labels...)
+			if tc.fork != "" {
+				pr.Head = &github.PullRequestBranch{Repo: &github.Repository{Name: github.Ptr(tc.fork), FullName: github.Ptr("acme/" + tc.fork)}}
+			}
+			rules := testRules()
+			rules.SecurityLabels = []string{"security", "embargoed"}
+			rules.SecurityTeam = map[string]bool{"mallory": true}
+			if got := securityExemption(pr, rules); got != tc.want {
+				t.Errorf("securityExemption = %q, want %q", got, tc.want)
+			}
+
+			rules.ExemptSecurity = true
+			d := evaluatePR(pr, prSignals{}, rules, now)
+			if exempt := d.Action == actionExempt && d.Rule == ruleSecurityExemption; exempt != (tc.want != "") || d.SecurityReason != tc.want {
+				t.Errorf("with --exempt-security decided %s by '%s' (%q), want exempt: %v", d.Action, d.Rule, d.SecurityReason, tc.want != "")
+			}
+			rules.ExemptSecurity = false
+			if d := evaluatePR(pr, prSignals{}, rules, now); d.Action != actionWarn {
+				t.Errorf("without --exempt-security decided %s, want warn", d.Action)
+			}
+		})
+	}
+}
+
+// TestSecurityExemptionsReported checks that the reasons of security
+// exemptions are listed in the run summary for the security team to audit.
+func TestSecurityExemptionsReported(t *testing.T) {
+	cfg := &config{Rules: testRules(), DisplayLocation: time.UTC, NotifyVia: notifyComment}
+	cfg.Rules.ExemptSecurity = true
+	cfg.Rules.SecurityLabels = []string{"security"}
+	cfg.Rules.SecurityTeam = map[string]bool{"mallory": true}
+	s := newTestRepoScanner(t, &fakeGitHub{}, cfg)
+	old := time.Now().AddDate(0, 0, -45)
+	labeled := testPR("alice", old, "security")
+	labeled.Number = github.Ptr(3)
+	s.actOn(labeled)
+	s.actOn(testPR("mallory", old))
+
+	want := []string{"PR #3 (@alice): security label 'security'", "PR #42 (@mallory): author mallory is a security team member"}
+	if fmt.Sprint(s.summary.SecurityExempt) != fmt.Sprint(want) {
+		t.Errorf("reported security exemptions %q, want %q", s.summary.SecurityExempt, want)
+	}
+	var buf strings.Builder
+	logSummary(slog.New(slog.NewTextHandler(&buf, nil)), s.summary)
+	if !strings.Contains(buf.String(), "security label 'security'") {
+		t.Errorf("the logged summary does not list the security exemptions:\n%s", buf.String())
+	}
+}
+
+func TestGetTeamMembers(t *testing.T) {
+	var srv string
+	client, ts := newTestGitHubClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/orgs/acme/teams/security/members" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("page") != "2" {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/orgs/acme/teams/security/members?page=2>; rel="next"`, srv))
+			fmt.Fprint(w, `[{"login":"Mallory"},{"login":"trent"}]`)
+			return
+		}
+		fmt.Fprint(w, `[{"login":"peggy"}]`)
+	}))
+	srv = ts.URL
+	members, err := getTeamMembers(client, "acme", "security")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]bool{"mallory": true, "trent": true, "peggy": true}; fmt.Sprint(members) != fmt.Sprint(want) {
+		t.Errorf("getTeamMembers returned %v, want %v", members, want)
+	}
+	if _, err := getTeamMembers(client, "acme", "red-team"); err == nil || !strings.Contains(err.Error(), "team acme/red-team") {
+		t.Errorf("getTeamMembers of a missing team returned %v, want an error naming it", err)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// prTimeline is what the state file records of a PR's life for the SLA
+// metrics of --sla-metrics. Times the bot did not observe are zero.
+type prTimeline struct {
+	OpenedAt time.Time `json:"opened_at"`
+	// WarnedAt is when the bot first warned about the PR.
+	WarnedAt time.Time `json:"warned_at,omitempty"`
+	// WarnedBefore is set when the PR already carried the stale label the
+	// first time it was seen, so its first warning was not observed.
+	WarnedBefore bool `json:"warned_before,omitempty"`
+}
+
+// slaSample holds the SLA intervals completed during one run, in days. It is
+// recorded in the trends file, where the trends subcommand aggregates it by
+// month; it holds no PR numbers.
+type slaSample struct {
+	// OpenedToWarned are measured when a PR is first warned.
+	OpenedToWarned []float64 `json:"opened_to_warned_days,omitempty"`
+	// WarnedToResolved and OpenedToResolved are measured when a PR is
+	// closed or merged. Resolutions the bot did not perform are timed by the
+	// run that noticed them, so they are only as precise as the schedule.
+	WarnedToResolved []float64 `json:"warned_to_resolved_days,omitempty"`
+	OpenedToResolved []float64 `json:"opened_to_resolved_days,omitempty"`
+	// Excluded counts the PRs resolved this run that were opened before the
+	// bot started recording SLA history, and so are left out.
+	Excluded int `json:"excluded,omitempty"`
+}
+
+func (s *slaSample) empty() bool {
+	return s == nil || len(s.OpenedToWarned) == 0 && len(s.OpenedToResolved) == 0 && s.Excluded == 0
+}
+
+func durationDays(d time.Duration) float64 {
+	return round1(d.Hours() / 24)
+}
+
+// slaSeen records a PR evaluated at now, starting its timeline the first
+// time. SLA history starts with the first PR seen.
+func (rs *repoState) slaSeen(pr *github.PullRequest, staleLabel string, now time.Time) {
+	if rs.SLASince.IsZero() {
+		rs.SLASince = now
+	}
+	if _, ok := rs.Timelines[pr.GetNumber()]; ok {
+		return
+	}
+	rs.Timelines[pr.GetNumber()] = &prTimeline{
+		OpenedAt:     pr.GetCreatedAt().Time,
+		WarnedBefore: hasLabel(pr, staleLabel),
+	}
+}
+
+// predates reports whether a PR was opened before the SLA history started.
+func (rs *repoState) predates(t *prTimeline) bool {
+	return t.OpenedAt.Before(rs.SLASince)
+}
+
+// slaWarned records the first warning of a PR at now, adding its time from
+// opening to the sample.
+func (rs *repoState) slaWarned(sample *slaSample, number int, now time.Time) {
+	t := rs.Timelines[number]
+	if t == nil || !t.WarnedAt.IsZero() || t.WarnedBefore {
+		return
+	}
+	t.WarnedAt = now
+	if !rs.predates(t) {
+		sample.OpenedToWarned = append(sample.OpenedToWarned, durationDays(now.Sub(t.OpenedAt)))
+	}
+}
+
+// slaResolved records that a PR was closed or merged at now, adding its
+// intervals to the sample and ending its timeline. Intervals whose start was
+// not observed are left out.
+func (rs *repoState) slaResolved(sample *slaSample, number int, now time.Time) {
+	t := rs.Timelines[number]
+	if t == nil {
+		return
+	}
+	delete(rs.Timelines, number)
+	if rs.predates(t) {
+		sample.Excluded++
+		return
+	}
+	sample.OpenedToResolved = append(sample.OpenedToResolved, durationDays(now.Sub(t.OpenedAt)))
+	if !t.WarnedAt.IsZero() {
+		sample.WarnedToResolved = append(sample.WarnedToResolved, durationDays(now.Sub(t.WarnedAt)))
+	}
+}
+
+// slaMonth aggregates the SLA samples of one repository and month.
+type slaMonth struct {
+	Repo   string
+	Month  string
+	Sample slaSample
+}
+
+// slaByMonth merges the SLA samples of records by repository and calendar
+// month (UTC), in repository and month order.
+func slaByMonth(records []trendRecord) []slaMonth {
+	byKey := map[string]*slaMonth{}
+	var keys []string
+	for _, rec := range records {
+		if rec.SLA.empty() {
+			continue
+		}
+		month := rec.Time.UTC().Format("2006-01")
+		key := rec.Repo + " " + month
+		m, ok := byKey[key]
+		if !ok {
+			m = &slaMonth{Repo: rec.Repo, Month: month}
+			byKey[key] = m
+			keys = append(keys, key)
+		}
+		m.Sample.OpenedToWarned = append(m.Sample.OpenedToWarned, rec.SLA.OpenedToWarned...)
+		m.Sample.WarnedToResolved = append(m.Sample.WarnedToResolved, rec.SLA.WarnedToResolved...)
+		m.Sample.OpenedToResolved = append(m.Sample.OpenedToResolved, rec.SLA.OpenedToResolved...)
+		m.Sample.Excluded += rec.SLA.Excluded
+	}
+	sort.Strings(keys)
+	months := make([]slaMonth, 0, len(keys))
+	for _, key := range keys {
+		months = append(months, *byKey[key])
+	}
+	return months
+}
+
+// slaRows renders the monthly SLA percentiles as table rows under header.
+func slaRows(months []slaMonth) (header []string, rows [][]string) {
+	header = []string{"repo", "month", "warned", "opened_to_warned_p50", "opened_to_warned_p90", "resolved", "warned_to_resolved_p50", "warned_to_resolved_p90", "opened_to_resolved_p50", "opened_to_resolved_p90", "excluded"}
+	f := func(v float64) string { return strconv.FormatFloat(round1(v), 'f', 1, 64) }
+	for _, m := range months {
+		s := m.Sample
+		rows = append(rows, []string{
+			m.Repo,
+			m.Month,
+			strconv.Itoa(len(s.OpenedToWarned)),
+			f(percentile(s.OpenedToWarned, 50)),
+			f(percentile(s.OpenedToWarned, 90)),
+			strconv.Itoa(len(s.OpenedToResolved)),
+			f(percentile(s.WarnedToResolved, 50)),
+			f(percentile(s.WarnedToResolved, 90)),
+			f(percentile(s.OpenedToResolved, 50)),
+			f(percentile(s.OpenedToResolved, 90)),
+			strconv.Itoa(s.Excluded),
+		})
+	}
+	return header, rows
+}
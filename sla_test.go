@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+func TestSLATimelines(t *testing.T) {
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	day := func(n int) time.Time { return start.AddDate(0, 0, n) }
+	opened := func(number int, at time.Time, labels ...string) *github.PullRequest {
+		pr := testPR("alice", at, labels...)
+		pr.Number = github.Ptr(number)
+		pr.CreatedAt = &github.Timestamp{Time: at}
+		return pr
+	}
+	rs := &repoState{}
+	rs.initMaps()
+
+	// PR 1 was open before the history started, PR 2 is opened after it
+	// and PR 3 already carries the stale label when first seen.
+	rs.slaSeen(opened(1, day(-10)), "stale-warning", day(0))
+	rs.slaSeen(opened(2, day(3)), "stale-warning", day(5))
+	rs.slaSeen(opened(3, day(4), "stale-warning"), "stale-warning", day(5))
+	rs.slaSeen(opened(2, day(3)), "stale-warning", day(6))
+	if !rs.SLASince.Equal(day(0)) {
+		t.Errorf("SLA history starts %v, want %v", rs.SLASince, day(0))
+	}
+
+	var warned slaSample
+	for _, number := range []int{1, 2, 3} {
+		rs.slaWarned(&warned, number, day(20))
+	}
+	// A second warning of PR 2 is not its first.
+	rs.slaWarned(&warned, 2, day(21))
+	if want := []float64{17}; !slices.Equal(warned.OpenedToWarned, want) {
+		t.Errorf("opened to warned %v, want %v", warned.OpenedToWarned, want)
+	}
+
+	var resolved slaSample
+	rs.slaResolved(&resolved, 1, day(22))
+	rs.slaResolved(&resolved, 2, day(25))
+	rs.slaResolved(&resolved, 3, day(30))
+	rs.slaResolved(&resolved, 4, day(30))
+	if got, want := fmt.Sprint(resolved), fmt.Sprint(slaSample{WarnedToResolved: []float64{5}, OpenedToResolved: []float64{22, 26}, Excluded: 1}); got != want {
+		t.Errorf("resolved sample %s, want %s", got, want)
+	}
+	if len(rs.Timelines) != 0 {
+		t.Errorf("timelines %v remain after every PR was resolved", rs.Timelines)
+	}
+}
+
+func TestSLAByMonth(t *testing.T) {
+	at := func(month time.Month, day int) time.Time { return time.Date(2026, month, day, 12, 0, 0, 0, time.UTC) }
+	records := []trendRecord{
+		{Time: at(3, 2), Repo: "acme/web", SLA: &slaSample{OpenedToWarned: []float64{10}}},
+		{Time: at(3, 1), Repo: "acme/api", SLA: &slaSample{OpenedToWarned: []float64{30, 10}, OpenedToResolved: []float64{40}, WarnedToResolved: []float64{7}}},
+		{Time: at(3, 20), Repo: "acme/api"},
+		{Time: at(3, 31), Repo: "acme/api", SLA: &slaSample{OpenedToWarned: []float64{20}, Excluded: 2}},
+		{Time: at(4, 1), Repo: "acme/api", SLA: &slaSample{OpenedToResolved: []float64{5}}},
+	}
+	header, rows := slaRows(slaByMonth(records))
+	if len(header) != len(rows[0]) {
+		t.Fatalf("header %q does not fit the rows", header)
+	}
+	want := [][]string{
+		{"acme/api", "2026-03", "3", "20.0", "28.0", "1", "7.0", "7.0", "40.0", "40.0", "2"},
+		{"acme/api", "2026-04", "0", "0.0", "0.0", "1", "0.0", "0.0", "5.0", "5.0", "0"},
+		{"acme/web", "2026-03", "1", "10.0", "10.0", "0", "0.0", "0.0", "0.0", "0.0", "0"},
+	}
+	if fmt.Sprint(rows) != fmt.Sprint(want) {
+		t.Errorf("rows\n%q\nwant\n%q", rows, want)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// slackNotifier posts notifications to a Slack incoming webhook, one message
+// per PR.
+type slackNotifier struct {
+	client *http.Client
+	url    string
+	// channel overrides the webhook's channel, for webhooks that allow it.
+	channel string
+	// retryDelay is how long to wait before the single retry of a failed
+	// post.
+	retryDelay time.Duration
+}
+
+func newSlackNotifier(client *http.Client, url, channel string) *slackNotifier {
+	return &slackNotifier{
+		client:     client,
+		url:        url,
+		channel:    channel,
+		retryDelay: 2 * time.Second,
+	}
+}
+
+func (s *slackNotifier) destination() string {
+	if s.channel != "" {
+		return "Slack channel " + s.channel
+	}
+	return "Slack"
+}
+
+// slackMessage is the payload of an incoming webhook. Text is the fallback
+// shown in notifications; Blocks is the layout shown in the channel.
+type slackMessage struct {
+	Channel string       `json:"channel,omitempty"`
+	Text    string       `json:"text"`
+	Blocks  []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Fields   []slackText `json:"fields,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// slackEscape escapes the characters Slack's mrkdwn gives a meaning to.
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// newSlackMessage lays out a notification about a PR: the subject, then the
+// PR's link and title, its author, how long it has been inactive and its
+// repository.
+func newSlackMessage(channel, subject string, pr *github.PullRequest, data notificationData) slackMessage {
+	days := int(data.Inactive.Hours() / 24)
+	mrkdwn := func(format string, a ...interface{}) slackText {
+		return slackText{Type: "mrkdwn", Text: fmt.Sprintf(format, a...)}
+	}
+	title := mrkdwn("*<%s|%s #%d>* %s", data.URL, data.KindShort(), data.Number, slackEscape(truncate(data.Title, 150)))
+	author := mrkdwn("*Author*\n@%s", slackEscape(pr.GetUser().GetLogin()))
+	if url := pr.GetUser().GetHTMLURL(); url != "" {
+		author = mrkdwn("*Author*\n<%s|@%s>", url, slackEscape(pr.GetUser().GetLogin()))
+	}
+	return slackMessage{
+		Channel: channel,
+		Text:    fmt.Sprintf("%s: %s", subject, data.URL),
+		Blocks: []slackBlock{
+			{Type: "section", Text: &slackText{Type: "mrkdwn", Text: "*" + slackEscape(subject) + "*"}},
+			{Type: "section", Text: &title, Fields: []slackText{
+				author,
+				mrkdwn("*Inactive*\n%d %s", days, pluralize(days, "day", "days")),
+			}},
+			{Type: "context", Elements: []slackText{mrkdwn("%s/%s", slackEscape(data.Owner), slackEscape(data.Repo))}},
+		},
+	}
+}
+
+// post sends a notification about a PR, retrying once if it fails.
+func (s *slackNotifier) post(out *prOutput, subject string, pr *github.PullRequest, data notificationData) error {
+	payload, err := json.Marshal(newSlackMessage(s.channel, subject, pr, data))
+	if err != nil {
+		return fmt.Errorf("failed to encode Slack message: %v", err)
+	}
+	if err = s.send(payload); err == nil {
+		return nil
+	}
+	out.Warn("posting to Slack failed, retrying once", "action", "slack", "err", err)
+	time.Sleep(s.retryDelay)
+	if err = s.send(payload); err != nil {
+		return fmt.Errorf("failed to post to Slack: %v", err)
+	}
+	return nil
+}
+
+func (s *slackNotifier) send(payload []byte) error {
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
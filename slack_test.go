@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// startChatWebhook starts an endpoint that replies to successive posts with
+// statuses, repeating the last one, and returns the bodies it received.
+func startChatWebhook(t *testing.T, statuses ...int) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		status := statuses[min(len(bodies), len(statuses))-1]
+		mu.Unlock()
+		w.WriteHeader(status)
+		io.WriteString(w, http.StatusText(status))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), bodies...)
+	}
+}
+
+// testChatPR returns a PR and its notification data for the chat notifiers.
+func testChatPR() (*github.PullRequest, notificationData) {
+	pr := testPR("alice", time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	pr.Number = github.Ptr(42)
+	pr.User.HTMLURL = github.Ptr("https://github.com/alice")
+	data := notificationData{
+		Number:   42,
+		Title:    "Use <b> & friends",
+		URL:      "https://github.com/acme/api/pull/42",
+		Owner:    "acme",
+		Repo:     "api",
+		Inactive: 31 * 24 * time.Hour,
+		Location: time.UTC,
+	}
+	return pr, data
+}
+
+func TestNewSlackMessage(t *testing.T) {
+	pr, data := testChatPR()
+	msg := newSlackMessage("#stale-prs", "PR #42 will be closed", pr, data)
+	if msg.Channel != "#stale-prs" {
+		t.Errorf("channel = %q, want the override", msg.Channel)
+	}
+	if want := "PR #42 will be closed: https://github.com/acme/api/pull/42"; msg.Text != want {
+		t.Errorf("fallback text = %q, want %q", msg.Text, want)
+	}
+	var types []string
+	for _, b := range msg.Blocks {
+		types = append(types, b.Type)
+	}
+	if got := strings.Join(types, " "); got != "section section context" {
+		t.Fatalf("blocks %s, want section section context", got)
+	}
+	if got, want := msg.Blocks[1].Text.Text, "*<https://github.com/acme/api/pull/42|PR #42>* Use &lt;b&gt; &amp; friends"; got != want {
+		t.Errorf("title block %q, want %q", got, want)
+	}
+	fields := msg.Blocks[1].Fields
+	if len(fields) != 2 || fields[0].Text != "*Author*\n<https://github.com/alice|@alice>" || fields[1].Text != "*Inactive*\n31 days" {
+		t.Errorf("fields %+v, want the author and days inactive", fields)
+	}
+	if got := msg.Blocks[2].Elements[0].Text; got != "acme/api" {
+		t.Errorf("context %q, want the repository", got)
+	}
+
+	payload, err := json.Marshal(newSlackMessage("", "subject", pr, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(payload), `"channel"`) {
+		t.Errorf("payload %s sets a channel without an override", payload)
+	}
+}
+
+func TestSlackPost(t *testing.T) {
+	pr, data := testChatPR()
+	for _, tc := range []struct {
+		name     string
+		statuses []int
+		// wantPosts is how many posts the webhook must get.
+		wantPosts int
+		wantErr   string
+	}{
+		{name: "ok", statuses: []int{http.StatusOK}, wantPosts: 1},
+		{name: "retried once", statuses: []int{http.StatusInternalServerError, http.StatusOK}, wantPosts: 2},
+		{name: "fails twice", statuses: []int{http.StatusInternalServerError, http.StatusNotFound}, wantPosts: 2, wantErr: "404 Not Found"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, bodies := startChatWebhook(t, tc.statuses...)
+			s := newSlackNotifier(http.DefaultClient, srv.URL, "#stale-prs")
+			s.retryDelay = 0
+			err := s.post(&prOutput{}, "Stale PR", pr, data)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("post returned %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Errorf("post returned %v, want an error containing %q", err, tc.wantErr)
+			}
+			got := bodies()
+			if len(got) != tc.wantPosts {
+				t.Fatalf("the webhook got %d posts, want %d", len(got), tc.wantPosts)
+			}
+			var msg slackMessage
+			if err := json.Unmarshal([]byte(got[0]), &msg); err != nil || msg.Channel != "#stale-prs" || len(msg.Blocks) != 3 {
+				t.Errorf("posted %s, want a message with blocks for #stale-prs", got[0])
+			}
+		})
+	}
+}
+
+func TestChatNotifiersReadOnly(t *testing.T) {
+	pr, data := testChatPR()
+	for _, via := range []string{notifySlack, notifyTeams} {
+		srv, bodies := startChatWebhook(t, http.StatusOK)
+		cfg := &config{NotifyVia: via, SlackWebhookURL: srv.URL, TeamsWebhookURL: srv.URL}
+		guard := newWriteGuard(true, false)
+		m := newMailer(cfg, newTemplateRenderer(cfg), guard, http.DefaultClient)
+		if err := m.notify(&prOutput{}, pr, data, "Stale PR", ""); !errors.Is(err, errReadOnly) {
+			t.Errorf("%s notify returned %v, want the post blocked", via, err)
+		}
+		if got := bodies(); len(got) != 0 {
+			t.Errorf("%s webhook got %d posts in read-only mode", via, len(got))
+		}
+		if blocked := guard.blockedWrites(); len(blocked) != 1 || !strings.Contains(blocked[0], "Stale PR") {
+			t.Errorf("%s blocked writes %q, want the message", via, blocked)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/smtp"
+	"sync"
+)
+
+// smtpPool keeps a run's SMTP connections open between emails, so that each
+// email does not dial, negotiate TLS and authenticate again. At most size
+// connections are open at once, --smtp-concurrency; an email waits while all
+// of them are sending. A nil pool opens a connection for every email and
+// quits it afterwards.
+type smtpPool struct {
+	// slots holds a slot for each connection sending an email.
+	slots chan struct{}
+
+	mu   sync.Mutex
+	idle []*smtp.Client
+}
+
+func newSMTPPool(size int) *smtpPool {
+	return &smtpPool{slots: make(chan struct{}, size)}
+}
+
+// get returns an idle connection that still answers, or else one opened with
+// dial. Idle connections the server has dropped are closed and skipped.
+func (p *smtpPool) get(dial func() (*smtp.Client, error)) (*smtp.Client, error) {
+	if p == nil {
+		return dial()
+	}
+	p.slots <- struct{}{}
+	for {
+		p.mu.Lock()
+		if len(p.idle) == 0 {
+			p.mu.Unlock()
+			break
+		}
+		c := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		p.mu.Unlock()
+		if c.Reset() == nil {
+			return c, nil
+		}
+		c.Close()
+	}
+	c, err := dial()
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return c, nil
+}
+
+// put hands back a connection from get once its email is sent, or failed
+// with err. A connection whose email failed is closed rather than reused, so
+// the failure cannot affect the emails sent after it.
+func (p *smtpPool) put(c *smtp.Client, err error) {
+	switch {
+	case err != nil:
+		c.Close()
+	case p == nil:
+		c.Quit()
+	default:
+		p.mu.Lock()
+		p.idle = append(p.idle, c)
+		p.mu.Unlock()
+	}
+	if p != nil {
+		<-p.slots
+	}
+}
+
+// close quits the idle connections at the end of a run.
+func (p *smtpPool) close() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+	for _, c := range idle {
+		if c.Quit() != nil {
+			c.Close()
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// SMTP encryption modes, selected with --smtp-encryption.
+const (
+	// smtpEncryptionSTARTTLS upgrades a plain connection with STARTTLS
+	// when the server offers it.
+	smtpEncryptionSTARTTLS = "starttls"
+	// smtpEncryptionTLS speaks TLS from the start (SMTPS), as on port 465.
+	smtpEncryptionTLS = "tls"
+	// smtpEncryptionNone never encrypts, for relays on a trusted network.
+	smtpEncryptionNone = "none"
+)
+
+// smtpsPort is the port of SMTP over implicit TLS.
+const smtpsPort = 465
+
+// smtpEncryption returns the encryption mode for --smtp-encryption value
+// mode, which defaults by port when empty: implicit TLS on 465, STARTTLS
+// otherwise.
+func smtpEncryption(mode string, port int) (string, error) {
+	switch mode {
+	case "":
+		if port == smtpsPort {
+			return smtpEncryptionTLS, nil
+		}
+		return smtpEncryptionSTARTTLS, nil
+	case smtpEncryptionSTARTTLS, smtpEncryptionTLS, smtpEncryptionNone:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown SMTP encryption %q: must be starttls, tls or none", mode)
+}
+
+// newSMTPTLSConfig returns the TLS configuration for STARTTLS or implicit TLS
+// with server.
+// The certificate is verified against the system roots, plus the CA
+// certificates in caFile if it is set. insecure skips verification, for lab
+// relays with certificates that cannot be verified.
+func newSMTPTLSConfig(server, caFile string, insecure bool) (*tls.Config, error) {
+	config := &tls.Config{ServerName: server, InsecureSkipVerify: insecure}
+	if caFile == "" {
+		return config, nil
+	}
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SMTP CA file: %v", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM certificates found in SMTP CA file %s", caFile)
+	}
+	config.RootCAs = pool
+	return config, nil
+}
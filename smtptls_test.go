@@ -0,0 +1,374 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestCertificate returns a self-signed certificate for 127.0.0.1 and
+// its PEM encoding, for the client to trust.
+func newTestCertificate(t *testing.T) (tls.Certificate, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "stale-pr-bot test SMTP server"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// testSMTPServer is an SMTP server on 127.0.0.1 that accepts every email,
+// offering STARTTLS if it has a certificate and is not already behind TLS.
+type testSMTPServer struct {
+	ln       net.Listener
+	cert     *tls.Certificate
+	implicit bool
+	// hold delays the reply to each email, keeping its connection open.
+	hold time.Duration
+	// reject refuses emails to this recipient.
+	reject string
+	// dropAfter closes each connection after it has sent this many emails,
+	// as a server dropping idle connections does; 0 never drops them.
+	dropAfter int
+
+	mu sync.Mutex
+	// received holds the data of the emails accepted, and encrypted
+	// whether each was sent over TLS.
+	received  []string
+	encrypted []bool
+	// open counts the connections open now, and maxOpen the most open at
+	// once.
+	open, maxOpen int
+	// dials counts the connections opened, and quits those the client quit.
+	dials, quits int
+}
+
+// startTestSMTPServer starts a server, listening with implicit TLS if
+// implicit is set. It offers STARTTLS if cert is set and implicit is not.
+func startTestSMTPServer(t *testing.T, cert *tls.Certificate, implicit bool) *testSMTPServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if implicit {
+		ln = tls.NewListener(ln, &tls.Config{Certificates: []tls.Certificate{*cert}})
+	}
+	s := &testSMTPServer{ln: ln, cert: cert, implicit: implicit}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// port returns the port the server listens on.
+func (s *testSMTPServer) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *testSMTPServer) serve(conn net.Conn) {
+	s.mu.Lock()
+	s.open++
+	s.maxOpen = max(s.maxOpen, s.open)
+	s.dials++
+	s.mu.Unlock()
+	// The connection counts as closed once the client quits, before the
+	// reply lets it open the next one.
+	quit := false
+	closed := func() {
+		s.mu.Lock()
+		s.open--
+		s.mu.Unlock()
+	}
+	defer func() {
+		if !quit {
+			closed()
+		}
+	}()
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	encrypted := s.implicit
+	sent := 0
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 127.0.0.1 ESMTP test")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, _, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			if s.cert != nil && !encrypted {
+				tp.PrintfLine("250-127.0.0.1")
+				tp.PrintfLine("250 STARTTLS")
+			} else {
+				tp.PrintfLine("250 127.0.0.1")
+			}
+		case "STARTTLS":
+			tp.PrintfLine("220 ready to start TLS")
+			tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{*s.cert}})
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, encrypted = tlsConn, true
+			tp = textproto.NewConn(conn)
+		case "RCPT":
+			if s.reject != "" && strings.Contains(line, "<"+s.reject+">") {
+				tp.PrintfLine("550 no such user")
+			} else {
+				tp.PrintfLine("250 OK")
+			}
+		case "MAIL", "RSET", "NOOP":
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 end with <CR><LF>.<CR><LF>")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.received = append(s.received, string(data))
+			s.encrypted = append(s.encrypted, encrypted)
+			s.mu.Unlock()
+			time.Sleep(s.hold)
+			tp.PrintfLine("250 OK")
+			if sent++; sent == s.dropAfter {
+				return
+			}
+		case "QUIT":
+			quit = true
+			s.mu.Lock()
+			s.quits++
+			s.mu.Unlock()
+			closed()
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 unknown command")
+		}
+	}
+}
+
+// emails returns the emails the server accepted and whether each was sent
+// over TLS.
+func (s *testSMTPServer) emails() ([]string, []bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.received...), append([]bool(nil), s.encrypted...)
+}
+
+// newTestMailer returns a mailer for the server that trusts caPEM, if set.
+func newTestMailer(t *testing.T, s *testSMTPServer, encryption string, caPEM []byte) *mailer {
+	t.Helper()
+	caFile := ""
+	if caPEM != nil {
+		caFile = writeTestFile(t, "ca.pem", string(caPEM))
+	}
+	config, err := newSMTPTLSConfig("127.0.0.1", caFile, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &mailer{Server: "127.0.0.1", Port: s.port(), From: "bot@example.com", encryption: encryption, tls: config}
+}
+
+func TestDeliverEmail(t *testing.T) {
+	cert, caPEM := newTestCertificate(t)
+	for _, tc := range []struct {
+		name     string
+		offerTLS bool
+		// implicit serves implicit TLS (SMTPS) instead of STARTTLS.
+		implicit   bool
+		encryption string
+		trust      bool
+		wantErr    string
+		// wantEncrypted is whether the email must arrive over TLS.
+		wantEncrypted bool
+	}{
+		{"starttls", true, false, smtpEncryptionSTARTTLS, true, "", true},
+		{"starttls not offered", false, false, smtpEncryptionSTARTTLS, true, "does not support STARTTLS", false},
+		{"starttls untrusted certificate", true, false, smtpEncryptionSTARTTLS, false, "failed to initiate STARTTLS", false},
+		{"none without starttls", false, false, smtpEncryptionNone, false, "", false},
+		{"none ignores starttls", true, false, smtpEncryptionNone, false, "", false},
+		{"implicit tls", true, true, smtpEncryptionTLS, true, "", true},
+		{"implicit tls untrusted certificate", true, true, smtpEncryptionTLS, false, "failed to create SMTP client", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var serverCert *tls.Certificate
+			if tc.offerTLS {
+				serverCert = &cert
+			}
+			s := startTestSMTPServer(t, serverCert, tc.implicit)
+			var trusted []byte
+			if tc.trust {
+				trusted = caPEM
+			}
+			m := newTestMailer(t, s, tc.encryption, trusted)
+			err := m.deliverEmail(&prOutput{}, "Alice", "alice@example.com", "Your PR is stale", "Please update it.", "")
+			received, encrypted := s.emails()
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("deliverEmail returned %v, want an error containing %q", err, tc.wantErr)
+				}
+				if len(received) > 0 {
+					t.Errorf("the server received %d email(s) despite the error", len(received))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("deliverEmail: %v", err)
+			}
+			if len(received) != 1 {
+				t.Fatalf("the server received %d emails, want 1", len(received))
+			}
+			if !strings.Contains(received[0], "Subject: Your PR is stale") {
+				t.Errorf("the email lacks its subject:\n%s", received[0])
+			}
+			if encrypted[0] != tc.wantEncrypted {
+				t.Errorf("the email was sent with TLS %v, want %v", encrypted[0], tc.wantEncrypted)
+			}
+		})
+	}
+}
+
+func TestSendEmailLimitsSMTPConnections(t *testing.T) {
+	s := startTestSMTPServer(t, nil, false)
+	s.hold = 50 * time.Millisecond
+	m := newTestMailer(t, s, smtpEncryptionNone, nil)
+	m.pool = newPRPool(4)
+	m.conns = newSMTPPool(2)
+	errs := make([]error, 8)
+	m.pool.run(len(errs), func() bool { return false }, func(i int) {
+		errs[i] = m.sendEmail(&prOutput{}, "", "author@example.com", "Your PR is stale", "Please update it.", "")
+	})
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("email %d: %v", i, err)
+		}
+	}
+	received, _ := s.emails()
+	if len(received) != len(errs) {
+		t.Errorf("the server received %d emails, want %d", len(received), len(errs))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maxOpen != 2 {
+		t.Errorf("%d SMTP connections were open at once, want --smtp-concurrency 2", s.maxOpen)
+	}
+}
+
+// counts returns how many connections the server accepted and how many the
+// client quit.
+func (s *testSMTPServer) counts() (dials, quits int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dials, s.quits
+}
+
+func TestSMTPPoolReusesConnections(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		pool      bool
+		dropAfter int
+		wantDials int
+		wantQuits int
+	}{
+		// Without a pool every email opens and quits its own connection.
+		{"no pool", false, 0, 4, 4},
+		{"pool", true, 0, 1, 1},
+		// A connection the server dropped while idle fails its RSET and
+		// is replaced.
+		{"dropped while idle", true, 3, 2, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := startTestSMTPServer(t, nil, false)
+			s.dropAfter = tc.dropAfter
+			m := newTestMailer(t, s, smtpEncryptionNone, nil)
+			if tc.pool {
+				m.conns = newSMTPPool(1)
+			}
+			for i := 0; i < 4; i++ {
+				if err := m.sendEmail(&prOutput{}, "", "author@example.com", "Your PR is stale", "Please update it.", ""); err != nil {
+					t.Fatalf("email %d: %v", i, err)
+				}
+			}
+			m.closeConnections()
+			received, _ := s.emails()
+			if len(received) != 4 {
+				t.Errorf("the server received %d emails, want 4", len(received))
+			}
+			// The server counts a QUIT after replying to it.
+			deadline := time.Now().Add(time.Second)
+			for {
+				dials, quits := s.counts()
+				if dials == tc.wantDials && quits == tc.wantQuits {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("the client opened %d connections and quit %d, want %d and %d", dials, quits, tc.wantDials, tc.wantQuits)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestSMTPPoolIsolatesFailures(t *testing.T) {
+	s := startTestSMTPServer(t, nil, false)
+	s.reject = "gone@example.com"
+	m := newTestMailer(t, s, smtpEncryptionNone, nil)
+	m.conns = newSMTPPool(1)
+	for i, to := range []string{"author@example.com", "gone@example.com", "author@example.com", "other@example.com"} {
+		err := m.sendEmail(&prOutput{}, "", to, "Your PR is stale", "Please update it.", "")
+		if to == s.reject {
+			if err == nil || !strings.Contains(err.Error(), "failed to set recipient") {
+				t.Errorf("email %d to %s returned %v, want a recipient error", i, to, err)
+			}
+		} else if err != nil {
+			t.Errorf("email %d to %s: %v", i, to, err)
+		}
+	}
+	m.closeConnections()
+	received, _ := s.emails()
+	if len(received) != 3 {
+		t.Errorf("the server received %d emails, want the 3 it accepts", len(received))
+	}
+	// The rejected email's connection is closed rather than reused, so the
+	// emails after it go over a fresh one.
+	if dials, _ := s.counts(); dials != 2 {
+		t.Errorf("the client opened %d connections, want 2", dials)
+	}
+}
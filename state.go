@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// stateVersion is the version of the state file format.
+const stateVersion = 1
+
+// botState is the state persisted between runs in the --state-file.
+type botState struct {
+	Version int                   `json:"version"`
+	Repos   map[string]*repoState `json:"repos"`
+}
+
+// repoState is the persisted state for a single owner/repo.
+type repoState struct {
+	// EventsCursor is the ID of the newest repository event already seen.
+	EventsCursor string `json:"events_cursor,omitempty"`
+	// LastFullScan is when all open PRs were last evaluated.
+	LastFullScan time.Time `json:"last_full_scan,omitempty"`
+	// StatusSince is when comments were last searched for "/stale status".
+	StatusSince time.Time `json:"status_since,omitempty"`
+	// Deadlines maps warned PR numbers to the time they become eligible for closure.
+	Deadlines map[int]time.Time `json:"deadlines,omitempty"`
+	// Reminders maps warned PR numbers to the reminder offsets already sent.
+	Reminders map[int][]string `json:"reminders,omitempty"`
+	// Deferred lists PRs whose actions were skipped because a run budget ran
+	// out, or that --max-run-duration left unprocessed; they are processed
+	// first on the next run.
+	Deferred []int `json:"deferred,omitempty"`
+	// Backfill tracks progress of --backfill.
+	Backfill backfillState `json:"backfill,omitempty"`
+	// Files caches the changed files of PRs by head SHA.
+	Files map[int]fileListCache `json:"files,omitempty"`
+	// Pending lists failed notification emails to retry on the next run.
+	Pending []pendingNotification `json:"pending_notifications,omitempty"`
+	// Sent maps the idempotency keys of delivered notifications to when they
+	// were sent.
+	Sent map[string]time.Time `json:"sent_notifications,omitempty"`
+	// Classifications maps open PR numbers to the action decided for them
+	// when last evaluated, and ClassifiedAt is when that snapshot was last
+	// updated.
+	Classifications map[int]string `json:"classifications,omitempty"`
+	ClassifiedAt    time.Time      `json:"classified_at,omitempty"`
+	// Nudges maps PR numbers to the time of the author's question their
+	// maintainers were last nudged about.
+	Nudges map[int]time.Time `json:"nudges,omitempty"`
+	// PRAuthors maps the classified PR numbers to their authors, and
+	// Authors maps authors to what the bot did to their PRs, for
+	// --author-stats.
+	PRAuthors map[int]string            `json:"pr_authors,omitempty"`
+	Authors   map[string]*authorHistory `json:"authors,omitempty"`
+	// TitlePrefixed maps warned PRs whose title got the
+	// --title-prefix-on-warn prefix during their current warning to when the
+	// bot added it, or the zero time if the title already had it. A title
+	// that lacks the prefix afterwards had it removed by hand.
+	TitlePrefixed map[int]time.Time `json:"title_prefixed,omitempty"`
+	// DuplicateComments maps PRs commented on as duplicates to the newer PR
+	// they were pointed to.
+	DuplicateComments map[int]int `json:"duplicate_comments,omitempty"`
+	// SLASince is when SLA history started, and Timelines holds the
+	// timelines of the open PRs, for --sla-metrics.
+	SLASince  time.Time           `json:"sla_since,omitempty"`
+	Timelines map[int]*prTimeline `json:"timelines,omitempty"`
+}
+
+// fileListCache is the list of files changed by a PR at a given head SHA.
+type fileListCache struct {
+	HeadSHA string   `json:"head_sha"`
+	Files   []string `json:"files"`
+}
+
+func newBotState() *botState {
+	return &botState{Version: stateVersion, Repos: map[string]*repoState{}}
+}
+
+// loadState reads the state file at path. A missing file yields an empty state.
+func loadState(path string) (*botState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return newBotState(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %v", err)
+	}
+	st := newBotState()
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %v", path, err)
+	}
+	if st.Version > stateVersion {
+		return nil, fmt.Errorf("state file %s has unsupported version %d", path, st.Version)
+	}
+	if st.Repos == nil {
+		st.Repos = map[string]*repoState{}
+	}
+	st.Version = stateVersion
+	return st, nil
+}
+
+// save writes the state to path atomically by writing a temporary file in the
+// same directory and renaming it into place.
+func (s *botState) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary state file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace state file: %v", err)
+	}
+	return nil
+}
+
+// repo returns the state for owner/repo, creating it if needed.
+func (s *botState) repo(owner, repo string) *repoState {
+	key := owner + "/" + repo
+	rs, ok := s.Repos[key]
+	if !ok {
+		rs = &repoState{}
+		s.Repos[key] = rs
+	}
+	rs.initMaps()
+	return rs
+}
+
+// initMaps creates the maps of a repository's state that are nil, as they
+// are when empty in the state file.
+func (rs *repoState) initMaps() {
+	if rs.Deadlines == nil {
+		rs.Deadlines = map[int]time.Time{}
+	}
+	if rs.Reminders == nil {
+		rs.Reminders = map[int][]string{}
+	}
+	if rs.Files == nil {
+		rs.Files = map[int]fileListCache{}
+	}
+	if rs.Sent == nil {
+		rs.Sent = map[string]time.Time{}
+	}
+	if rs.Classifications == nil {
+		rs.Classifications = map[int]string{}
+	}
+	if rs.Nudges == nil {
+		rs.Nudges = map[int]time.Time{}
+	}
+	if rs.PRAuthors == nil {
+		rs.PRAuthors = map[int]string{}
+	}
+	if rs.Authors == nil {
+		rs.Authors = map[string]*authorHistory{}
+	}
+	if rs.TitlePrefixed == nil {
+		rs.TitlePrefixed = map[int]time.Time{}
+	}
+	if rs.DuplicateComments == nil {
+		rs.DuplicateComments = map[int]int{}
+	}
+	if rs.Timelines == nil {
+		rs.Timelines = map[int]*prTimeline{}
+	}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stateBundleFormat and stateBundleVersion identify the bundles written by
+// "state export".
+const (
+	stateBundleFormat  = "stale-pr-bot-state"
+	stateBundleVersion = 1
+)
+
+// stateBundle is the state of a --state-file in a form that can be carried to
+// another runner and imported there.
+type stateBundle struct {
+	Format       string                `json:"format"`
+	Version      int                   `json:"version"`
+	ExportedAt   time.Time             `json:"exported_at"`
+	StateVersion int                   `json:"state_version"`
+	Repos        map[string]*repoState `json:"repos"`
+}
+
+// readStateBundle reads and validates a bundle.
+func readStateBundle(r io.Reader) (*stateBundle, error) {
+	var b stateBundle
+	if err := json.NewDecoder(r).Decode(&b); err != nil {
+		return nil, fmt.Errorf("failed to parse state bundle: %v", err)
+	}
+	if b.Format != stateBundleFormat {
+		return nil, fmt.Errorf("not a state bundle: format is %q, want %q", b.Format, stateBundleFormat)
+	}
+	if b.Version < 1 || b.Version > stateBundleVersion {
+		return nil, fmt.Errorf("state bundle has unsupported version %d", b.Version)
+	}
+	if b.StateVersion < 1 || b.StateVersion > stateVersion {
+		return nil, fmt.Errorf("state bundle holds unsupported state version %d", b.StateVersion)
+	}
+	for key, rs := range b.Repos {
+		if owner, repo, ok := strings.Cut(key, "/"); !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+			return nil, fmt.Errorf("state bundle has invalid repository %q: want owner/repo", key)
+		}
+		if rs == nil {
+			return nil, fmt.Errorf("state bundle has no state for %s", key)
+		}
+	}
+	return &b, nil
+}
+
+// mergeState merges the state of a repository from a bundle into the state
+// already stored for it. Entries only one side has are kept. For entries both
+// have, the newer wins:
+//
+//   - timestamps such as the last full scan and sent notifications: the later;
+//   - the events cursor: the later event;
+//   - queued notifications: the one attempted last;
+//   - authors' histories: the PRs of both;
+//   - deadlines, reminders, classifications, PR authors and cached files,
+//     which carry no
+//     time of their own: the side whose state was updated last, as of its
+//     last full scan or classification.
+//
+// With force the bundle wins every conflict.
+func mergeState(dst, src *repoState, force bool) {
+	srcNewer := force || !stateUpdatedAt(src).Before(stateUpdatedAt(dst))
+	later := func(a, b time.Time) bool { return force || b.After(a) }
+
+	if force || eventID(src.EventsCursor) > eventID(dst.EventsCursor) {
+		dst.EventsCursor = src.EventsCursor
+	}
+	if later(dst.LastFullScan, src.LastFullScan) {
+		dst.LastFullScan = src.LastFullScan
+	}
+	if later(dst.StatusSince, src.StatusSince) {
+		dst.StatusSince = src.StatusSince
+	}
+	if later(dst.ClassifiedAt, src.ClassifiedAt) {
+		dst.ClassifiedAt = src.ClassifiedAt
+	}
+	if force || src.Backfill.Day > dst.Backfill.Day {
+		dst.Backfill = src.Backfill
+	} else if src.Backfill.Day == dst.Backfill.Day {
+		dst.Backfill.Warned = max(dst.Backfill.Warned, src.Backfill.Warned)
+		dst.Backfill.Complete = dst.Backfill.Complete || src.Backfill.Complete
+	}
+
+	for number, t := range src.Deadlines {
+		if _, ok := dst.Deadlines[number]; !ok || srcNewer {
+			dst.Deadlines[number] = t
+		}
+	}
+	for number, sent := range src.Reminders {
+		if _, ok := dst.Reminders[number]; !ok || srcNewer {
+			dst.Reminders[number] = sent
+		}
+	}
+	for number, action := range src.Classifications {
+		if _, ok := dst.Classifications[number]; !ok || srcNewer {
+			dst.Classifications[number] = action
+		}
+	}
+	for number, files := range src.Files {
+		if _, ok := dst.Files[number]; !ok || srcNewer {
+			dst.Files[number] = files
+		}
+	}
+	for number, author := range src.PRAuthors {
+		if _, ok := dst.PRAuthors[number]; !ok || srcNewer {
+			dst.PRAuthors[number] = author
+		}
+	}
+	for author, h := range src.Authors {
+		have := dst.Authors[author]
+		if have == nil {
+			have = &authorHistory{}
+			dst.Authors[author] = have
+		}
+		for _, number := range h.Warned {
+			have.Warned = addPR(have.Warned, number)
+		}
+		for _, number := range h.Closed {
+			have.Closed = addPR(have.Closed, number)
+		}
+		for _, number := range h.Resurrected {
+			have.Resurrected = addPR(have.Resurrected, number)
+		}
+	}
+	for key, t := range src.Sent {
+		if have, ok := dst.Sent[key]; !ok || later(have, t) {
+			dst.Sent[key] = t
+		}
+	}
+	for number, t := range src.Nudges {
+		if have, ok := dst.Nudges[number]; !ok || later(have, t) {
+			dst.Nudges[number] = t
+		}
+	}
+
+	deferred := map[int]bool{}
+	for _, number := range dst.Deferred {
+		deferred[number] = true
+	}
+	for _, number := range src.Deferred {
+		if !deferred[number] {
+			dst.Deferred = append(dst.Deferred, number)
+		}
+	}
+
+	pending := map[string]int{}
+	for i, n := range dst.Pending {
+		pending[n.Key] = i
+	}
+	for _, n := range src.Pending {
+		i, ok := pending[n.Key]
+		switch {
+		case !ok:
+			dst.Pending = append(dst.Pending, n)
+		case later(dst.Pending[i].LastAttempt, n.LastAttempt):
+			dst.Pending[i] = n
+		}
+	}
+}
+
+// stateUpdatedAt returns when a repository's state was last updated by a run,
+// as far as it records.
+func stateUpdatedAt(rs *repoState) time.Time {
+	if rs.ClassifiedAt.After(rs.LastFullScan) {
+		return rs.ClassifiedAt
+	}
+	return rs.LastFullScan
+}
+
+// eventID parses an events cursor; IDs grow over time.
+func eventID(cursor string) int64 {
+	id, _ := strconv.ParseInt(cursor, 10, 64)
+	return id
+}
+
+// diffState describes how a repository's state would change, one line per
+// added, removed or changed entry, e.g. "deadlines.42: added".
+func diffState(before, after *repoState) ([]string, error) {
+	b, err := stateFields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := stateFields(after)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, field := range unionKeys(b, a) {
+		bm, bIsMap := b[field].(map[string]interface{})
+		am, aIsMap := a[field].(map[string]interface{})
+		if !bIsMap || !aIsMap {
+			if line := diffValue(field, b[field], a[field]); line != "" {
+				lines = append(lines, line)
+			}
+			continue
+		}
+		for _, key := range unionKeys(bm, am) {
+			if line := diffValue(field+"."+key, bm[key], am[key]); line != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+	return lines, nil
+}
+
+// stateFields returns a repository's state as its JSON fields.
+func stateFields(rs *repoState) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	data, err := json.Marshal(rs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode state: %v", err)
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to decode state: %v", err)
+	}
+	return fields, nil
+}
+
+func diffValue(name string, before, after interface{}) string {
+	switch {
+	case before == nil && after == nil, reflect.DeepEqual(before, after):
+		return ""
+	case before == nil:
+		return name + ": added"
+	case after == nil:
+		return name + ": removed"
+	}
+	return name + ": changed"
+}
+
+// unionKeys returns the keys of a and b, sorted.
+func unionKeys(a, b map[string]interface{}) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, m := range []map[string]interface{}{a, b} {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// runStateCommand runs "state export" and "state import", which carry the
+// state file over to another runner.
+func runStateCommand(args []string, w io.Writer) error {
+	if len(args) == 0 || (args[0] != "export" && args[0] != "import") {
+		return fmt.Errorf("usage: state export|import [flags]")
+	}
+	fs := flag.NewFlagSet("state "+args[0], flag.ContinueOnError)
+	stateFileFlag := fs.String("state-file", os.Getenv("STATE_FILE"), "State file to export from or import into")
+	outFlag := fs.String("out", "", "With export: file to write the bundle to (default: standard output)")
+	inFlag := fs.String("in", "", "With import: bundle to import (default: standard input)")
+	forceFlag := fs.Bool("force", false, "With import: let the bundle win every conflict instead of the newer entry")
+	dryRunFlag := fs.Bool("dry-run", false, "With import: print what would change without writing the state file")
+	// Handled before the subcommand runs; accepted here so they parse.
+	fs.Bool("no-dotenv", false, "Do not load a .env file")
+	fs.String("dotenv-path", "", ".env file to load instead of ./.env")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *stateFileFlag == "" {
+		return fmt.Errorf("--state-file is required")
+	}
+	st, err := loadState(*stateFileFlag)
+	if err != nil {
+		return err
+	}
+
+	if args[0] == "export" {
+		data, err := json.MarshalIndent(stateBundle{
+			Format:       stateBundleFormat,
+			Version:      stateBundleVersion,
+			ExportedAt:   time.Now().UTC(),
+			StateVersion: st.Version,
+			Repos:        st.Repos,
+		}, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode state bundle: %v", err)
+		}
+		data = append(data, '\n')
+		if *outFlag == "" {
+			_, err = w.Write(data)
+			return err
+		}
+		if err := os.WriteFile(*outFlag, data, 0o600); err != nil {
+			return fmt.Errorf("failed to write state bundle: %v", err)
+		}
+		fmt.Fprintf(w, "Exported the state of %d repositories to %s.\n", len(st.Repos), *outFlag)
+		return nil
+	}
+
+	in := os.Stdin
+	if *inFlag != "" {
+		if in, err = os.Open(*inFlag); err != nil {
+			return fmt.Errorf("failed to open state bundle: %v", err)
+		}
+		defer in.Close()
+	}
+	bundle, err := readStateBundle(in)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(bundle.Repos))
+	for key := range bundle.Repos {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	changes := 0
+	for _, key := range keys {
+		owner, repo, _ := strings.Cut(key, "/")
+		snapshot := &repoState{}
+		if before, ok := st.Repos[key]; ok {
+			if err := copyState(snapshot, before); err != nil {
+				return err
+			}
+		}
+		src := bundle.Repos[key]
+		src.initMaps()
+		mergeState(st.repo(owner, repo), src, *forceFlag)
+		lines, err := diffState(snapshot, st.Repos[key])
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			fmt.Fprintf(w, "%s: %s\n", key, line)
+		}
+		changes += len(lines)
+	}
+	if *dryRunFlag {
+		fmt.Fprintf(w, "Dry run: the import would make %d change(s) to %s.\n", changes, *stateFileFlag)
+		return nil
+	}
+	if err := st.save(*stateFileFlag); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Imported the state of %d repositories into %s with %d change(s).\n", len(keys), *stateFileFlag, changes)
+	return nil
+}
+
+// copyState deep-copies a repository's state.
+func copyState(dst, src *repoState) error {
+	data, err := json.Marshal(src)
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %v", err)
+	}
+	return json.Unmarshal(data, dst)
+}
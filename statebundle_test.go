@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadStateBundle(t *testing.T) {
+	for _, tc := range []struct {
+		name, json, err string
+	}{
+		{"valid", `{"format":"stale-pr-bot-state","version":1,"state_version":1,"repos":{"acme/api":{}}}`, ""},
+		{"no repositories", `{"format":"stale-pr-bot-state","version":1,"state_version":1}`, ""},
+		{"not json", `format: stale-pr-bot-state`, "failed to parse state bundle"},
+		{"a state file", `{"version":1,"repos":{}}`, "not a state bundle"},
+		{"newer bundle", `{"format":"stale-pr-bot-state","version":2,"state_version":1}`, "unsupported version 2"},
+		{"newer state", `{"format":"stale-pr-bot-state","version":1,"state_version":2}`, "unsupported state version 2"},
+		{"no state version", `{"format":"stale-pr-bot-state","version":1}`, "unsupported state version 0"},
+		{"repository without owner", `{"format":"stale-pr-bot-state","version":1,"state_version":1,"repos":{"api":{}}}`, `invalid repository "api"`},
+		{"repository path", `{"format":"stale-pr-bot-state","version":1,"state_version":1,"repos":{"acme/api/x":{}}}`, `invalid repository "acme/api/x"`},
+		{"null state", `{"format":"stale-pr-bot-state","version":1,"state_version":1,"repos":{"acme/api":null}}`, "no state for acme/api"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := readStateBundle(strings.NewReader(tc.json))
+			if tc.err == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+				t.Fatalf("readStateBundle returned %v, want an error containing %q", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestMergeState(t *testing.T) {
+	day := func(n int) time.Time { return time.Date(2026, 3, n, 0, 0, 0, 0, time.UTC) }
+	newState := func(scanned time.Time, cursor string) *repoState {
+		rs := &repoState{LastFullScan: scanned, EventsCursor: cursor}
+		rs.initMaps()
+		return rs
+	}
+	local := func() *repoState {
+		rs := newState(day(10), "500")
+		rs.Deadlines[1] = day(20)
+		rs.Deadlines[2] = day(21)
+		rs.Sent["warn:1"] = day(9)
+		rs.Authors["alice"] = &authorHistory{Warned: []int{1}}
+		rs.Deferred = []int{7}
+		rs.Pending = []pendingNotification{{Key: "warn:2", Attempts: 1, LastAttempt: day(9)}}
+		rs.Backfill = backfillState{Day: "2026-03-10", Warned: 3}
+		return rs
+	}
+	bundle := func(scanned time.Time) *repoState {
+		rs := newState(scanned, "400")
+		rs.Deadlines[1] = day(25)
+		rs.Deadlines[3] = day(26)
+		rs.Sent["warn:1"] = day(12)
+		rs.Sent["warn:3"] = day(5)
+		rs.Authors["alice"] = &authorHistory{Warned: []int{3}, Closed: []int{1}}
+		rs.Deferred = []int{7, 8}
+		rs.Pending = []pendingNotification{{Key: "warn:2", Attempts: 2, LastAttempt: day(11)}, {Key: "warn:3"}}
+		rs.Backfill = backfillState{Day: "2026-03-10", Warned: 5, Complete: true}
+		return rs
+	}
+	for _, tc := range []struct {
+		name  string
+		src   *repoState
+		force bool
+		// deadline1 is PR 1's deadline after the merge, and cursor the
+		// events cursor.
+		deadline1 time.Time
+		cursor    string
+	}{
+		{name: "older bundle", src: bundle(day(5)), deadline1: day(20), cursor: "500"},
+		{name: "newer bundle", src: bundle(day(15)), deadline1: day(25), cursor: "500"},
+		{name: "forced", src: bundle(day(5)), force: true, deadline1: day(25), cursor: "400"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dst := local()
+			mergeState(dst, tc.src, tc.force)
+			if !dst.Deadlines[1].Equal(tc.deadline1) || dst.EventsCursor != tc.cursor {
+				t.Errorf("PR 1's deadline %v and cursor %s, want %v and %s", dst.Deadlines[1], dst.EventsCursor, tc.deadline1, tc.cursor)
+			}
+			// Entries only one side has are kept either way.
+			if !dst.Deadlines[2].Equal(day(21)) || !dst.Deadlines[3].Equal(day(26)) {
+				t.Errorf("deadlines %v, want those of both sides", dst.Deadlines)
+			}
+			if !dst.Sent["warn:1"].Equal(day(12)) || !dst.Sent["warn:3"].Equal(day(5)) {
+				t.Errorf("sent %v, want the later time of each", dst.Sent)
+			}
+			if got := fmt.Sprint(*dst.Authors["alice"]); got != "{[1 3] [1] []}" {
+				t.Errorf("alice's history %s, want the PRs of both", got)
+			}
+			if fmt.Sprint(dst.Deferred) != "[7 8]" {
+				t.Errorf("deferred %v, want [7 8]", dst.Deferred)
+			}
+			if len(dst.Pending) != 2 || dst.Pending[0].Attempts != 2 || dst.Pending[1].Key != "warn:3" {
+				t.Errorf("pending %+v, want the later attempt of warn:2 and warn:3", dst.Pending)
+			}
+			if !tc.force && (dst.Backfill.Warned != 5 || !dst.Backfill.Complete) {
+				t.Errorf("backfill %+v, want the most warned of the day, complete", dst.Backfill)
+			}
+		})
+	}
+}
+
+func TestStateExportImport(t *testing.T) {
+	dir := t.TempDir()
+	from, to, bundle := filepath.Join(dir, "from.json"), filepath.Join(dir, "to.json"), filepath.Join(dir, "bundle.json")
+	st := newBotState()
+	rs := st.repo("acme", "api")
+	rs.LastFullScan = time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	rs.Deadlines[42] = time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC)
+	if err := st.save(from); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	if err := runStateCommand([]string{"export", "--state-file", from, "--out", bundle}, &out); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	out.Reset()
+	if err := runStateCommand([]string{"import", "--state-file", to, "--in", bundle, "--dry-run"}, &out); err != nil {
+		t.Fatalf("import --dry-run: %v", err)
+	}
+	if !strings.Contains(out.String(), "acme/api: deadlines: added") || !strings.Contains(out.String(), "Dry run") {
+		t.Errorf("the dry run printed:\n%s", out.String())
+	}
+	if _, err := os.Stat(to); err == nil {
+		t.Fatal("the dry run wrote the state file")
+	}
+	if err := runStateCommand([]string{"import", "--state-file", to, "--in", bundle}, &out); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	imported, err := loadState(to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := imported.Repos["acme/api"]; got == nil || !got.Deadlines[42].Equal(rs.Deadlines[42]) {
+		t.Errorf("imported state %+v lacks PR 42's deadline", got)
+	}
+
+	// Importing the same bundle again changes nothing.
+	out.Reset()
+	if err := runStateCommand([]string{"import", "--state-file", to, "--in", bundle}, &out); err != nil {
+		t.Fatalf("second import: %v", err)
+	}
+	if !strings.Contains(out.String(), "with 0 change(s)") {
+		t.Errorf("the second import printed:\n%s", out.String())
+	}
+
+	for _, args := range [][]string{nil, {"show"}, {"import", "--state-file", to, "--in", filepath.Join(dir, "missing.json")}} {
+		if err := runStateCommand(args, &out); err == nil {
+			t.Errorf("state %q succeeded", args)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// statusMarker identifies the bot's reply to "/stale status" so that later
+// requests edit it instead of adding more comments.
+const statusMarker = "<!-- stale-pr-bot:status -->"
+
+// statusLookback is how far back comments are searched for status requests
+// when no earlier search is recorded in the state file.
+const statusLookback = 24 * time.Hour
+
+// isStatusCommand reports whether a comment asks for the bot's status: a
+// line reading "/stale status".
+func isStatusCommand(body string) bool {
+	for _, line := range strings.Split(body, "\n") {
+		if strings.EqualFold(strings.Join(strings.Fields(line), " "), "/stale status") {
+			return true
+		}
+	}
+	return false
+}
+
+// listStatusRequests returns the PRs and issues with a "/stale status"
+// comment created since the given time, mapped to the login of the latest
+// requester. Comments by the bot itself are ignored.
+func listStatusRequests(client *github.Client, owner, repo, botLogin string, since time.Time) (map[int]string, error) {
+	ctx := context.Background()
+	requests := map[int]string{}
+	opt := &github.IssueListCommentsOptions{
+		Sort:        github.Ptr("created"),
+		Direction:   github.Ptr("asc"),
+		Since:       &since,
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		comments, resp, err := client.Issues.ListComments(ctx, owner, repo, 0, opt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list comments: %v", err)
+		}
+		for _, c := range comments {
+			login := c.GetUser().GetLogin()
+			if strings.EqualFold(login, botLogin) || c.GetCreatedAt().Time.Before(since) || !isStatusCommand(c.GetBody()) {
+				continue
+			}
+			number, err := strconv.Atoi(path.Base(c.GetIssueURL()))
+			if err != nil {
+				continue
+			}
+			requests[number] = login
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return requests, nil
+}
+
+// statusReplyData is the data passed to the status reply template.
+type statusReplyData struct {
+	Marker    string
+	Requester string
+	Now       time.Time
+	Location  *time.Location
+	// LastActivity is the last activity the bot computed for the PR.
+	LastActivity time.Time
+	Decision     prDecision
+}
+
+// Assessment describes the decision in words.
+func (d statusReplyData) Assessment() string {
+	switch d.Decision.Action {
+	case actionExempt:
+		return "exempt from staleness"
+	case actionActive:
+		return "active"
+	case actionWarn:
+		return "stale; a warning will be sent on the next run"
+	case actionWait:
+		return "stale and warned"
+	case actionAway:
+		return "stale, but the author is away: " + d.Decision.AwayReason
+	case actionParked:
+		return "parked as stale"
+	case actionQuestion:
+		return "stale, but waiting on a maintainer to answer the author's question"
+	case actionCloseRequested:
+		return "to be closed at a maintainer's request"
+	case actionEscalate:
+		return "stale security update, escalated to the security contact"
+	default:
+		return "stale and eligible for closure"
+	}
+}
+
+const statusReplyTemplate = `{{.Marker}}
+@{{.Requester}} here is where this pull request stands as of {{formatDateIn .Now .Location}}:
+
+- **Last activity:** {{formatDateIn .LastActivity .Location}} ({{.Decision.LastActivitySource}})
+- **Assessment:** {{.Assessment}}
+{{range .Decision.Trace}}  - {{.}}
+{{end}}
+{{- if eq .Decision.Action "parked"}}
+A maintainer can move it out of the milestone to have it evaluated again.
+{{- else if eq .Decision.Action "away"}}
+{{- if not .Decision.CloseAt.IsZero}}
+No action will be taken before {{formatDateIn .Decision.CloseAt .Location}}.{{else}}
+No action will be taken until the author is back.{{end}}
+{{- else if eq .Decision.Action "waiting-on-maintainer"}}
+It will not be closed for inactivity while the question is unanswered.
+{{- else if eq .Decision.Action "escalate"}}
+Security updates are never closed for inactivity.
+{{- else if eq .Decision.Action "exempt"}}
+No staleness actions will be taken while the exemption applies.
+{{- else if eq .Decision.Action "active"}}
+Without further activity, a stale warning would be sent on {{formatDateIn .Decision.StaleAt .Location}} and the pull request could be closed from {{formatDateIn .Decision.CloseAt .Location}}.
+{{- else if or (eq .Decision.Action "warn") (eq .Decision.Action "wait")}}
+Without further activity, the pull request could be closed from {{formatDateIn .Decision.CloseAt .Location}}. Any update resets this.
+{{- else}}
+The pull request may be closed on the next run unless it is updated.
+{{- end}}
+`
+
+// postStatusReply edits the bot's existing status reply on a PR, or creates
+// one if there is none.
+func postStatusReply(client *github.Client, owner, repo string, number int, botLogin, body string) error {
+	ctx := context.Background()
+	opt := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		comments, resp, err := client.Issues.ListComments(ctx, owner, repo, number, opt)
+		if err != nil {
+			return fmt.Errorf("failed to list comments: %v", err)
+		}
+		for _, c := range comments {
+			if strings.EqualFold(c.GetUser().GetLogin(), botLogin) && strings.Contains(c.GetBody(), statusMarker) {
+				_, _, err := client.Issues.EditComment(ctx, owner, repo, c.GetID(), &github.IssueComment{Body: &body})
+				if err != nil {
+					return fmt.Errorf("failed to update status reply: %v", err)
+				}
+				return nil
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return postComment(client, owner, repo, number, body)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsStatusCommand(t *testing.T) {
+	for body, want := range map[string]bool{
+		"/stale status":                     true,
+		"  /Stale   STATUS  ":               true,
+		"Thanks!\n/stale status\nback soon": true,
+		"/stale status please":              false,
+		"what is the /stale status?":        false,
+		"/stale":                            false,
+		"> /stale status":                   false,
+	} {
+		if got := isStatusCommand(body); got != want {
+			t.Errorf("isStatusCommand(%q) = %v, want %v", body, got, want)
+		}
+	}
+}
+
+func TestListStatusRequests(t *testing.T) {
+	since := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	client, _ := newTestGitHubClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/acme/api/issues/comments" {
+			http.NotFound(w, r)
+			return
+		}
+		if got := r.URL.Query().Get("since"); got != "2026-03-10T00:00:00Z" {
+			t.Errorf("listed comments since %q, want 2026-03-10T00:00:00Z", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `[
+			{"body":"/stale status","user":{"login":"alice"},"created_at":"2026-03-10T09:00:00Z","issue_url":"https://api.github.com/repos/acme/api/issues/7"},
+			{"body":"/stale status","user":{"login":"bob"},"created_at":"2026-03-11T09:00:00Z","issue_url":"https://api.github.com/repos/acme/api/issues/7"},
+			{"body":"LGTM","user":{"login":"carol"},"created_at":"2026-03-11T10:00:00Z","issue_url":"https://api.github.com/repos/acme/api/issues/8"},
+			{"body":"/stale status","user":{"login":"Stale-Bot"},"created_at":"2026-03-11T11:00:00Z","issue_url":"https://api.github.com/repos/acme/api/issues/9"},
+			{"body":"/stale status","user":{"login":"dave"},"created_at":"2026-03-09T11:00:00Z","issue_url":"https://api.github.com/repos/acme/api/issues/10"},
+			{"body":"/stale status","user":{"login":"erin"},"created_at":"2026-03-12T11:00:00Z","issue_url":"https://api.github.com/repos/acme/api/issues/11"}
+		]`)
+	}))
+	requests, err := listStatusRequests(client, "acme", "api", "stale-bot", since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int]string{7: "bob", 11: "erin"}; fmt.Sprint(requests) != fmt.Sprint(want) {
+		t.Errorf("status requests %v, want %v", requests, want)
+	}
+}
+
+// TestStatusReplyGolden renders the status reply for active, warned and
+// exempt PRs and compares it with testdata/status/<name>.golden. Run with
+// -update to rewrite them.
+func TestStatusReplyGolden(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	rules := testRules()
+	cfg := &config{Rules: rules, DisplayLocation: time.UTC}
+	for _, tc := range []struct {
+		name    string
+		updated time.Time
+		labels  []string
+		signals prSignals
+	}{
+		{name: "active", updated: now.AddDate(0, 0, -10)},
+		{name: "warned", updated: now.AddDate(0, 0, -40), labels: []string{"stale-warning"}, signals: prSignals{WarnedAt: now.AddDate(0, 0, -3)}},
+		{name: "exempt", updated: now.AddDate(0, 0, -90), labels: []string{"pinned"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := evaluatePR(testPR("alice", tc.updated, tc.labels...), tc.signals, rules, now)
+			got, err := newTemplateRenderer(cfg).render("status reply", statusReplyTemplate, statusReplyData{
+				Marker:       statusMarker,
+				Requester:    "bob",
+				Now:          now,
+				Location:     time.UTC,
+				LastActivity: d.LastActivity,
+				Decision:     d,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", "status", tc.name+".golden")
+			if *updateGolden {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v; run the test with -update to create it", err)
+			}
+			if got != string(want) {
+				t.Errorf("status reply differs from %s; run the test with -update to accept it:\n--- got ---\n%s--- want ---\n%s", filepath.Base(golden), got, want)
+			}
+		})
+	}
+}
+
+// TestStatusReplyEditsPrevious answers status requests on a PR twice: the
+// first reply is posted, the second edits it.
+func TestStatusReplyEditsPrevious(t *testing.T) {
+	gh := &fakeGitHub{get: map[string]string{}}
+	s := newTestRepoScanner(t, gh, nil)
+	s.statusRequests = map[int]string{42: "bob"}
+	pr := testPR("alice", time.Now().AddDate(0, 0, -10))
+	s.actOn(pr)
+	writes := gh.written()
+	if len(writes) != 1 || !strings.HasPrefix(writes[0], "POST /repos/acme/api/issues/42/comments ") || !strings.Contains(writes[0], statusMarker) || !strings.Contains(writes[0], "@bob here is where this pull request stands") {
+		t.Fatalf("writes %q, want a status reply to @bob", writes)
+	}
+
+	gh.get["/repos/acme/api/issues/42/comments"] = `[
+		{"id":5,"body":"` + statusMarker + ` earlier","user":{"login":"someone-else"}},
+		{"id":6,"body":"` + statusMarker + ` earlier","user":{"login":"stale-bot"}}
+	]`
+	gh.writes = nil
+	s.actOn(pr)
+	if writes := gh.written(); len(writes) != 1 || !strings.HasPrefix(writes[0], "PATCH /repos/acme/api/issues/comments/6 ") {
+		t.Errorf("writes %q, want the bot's earlier reply edited", writes)
+	}
+}
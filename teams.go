@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// Throttling of Teams webhooks: a 429 response is retried after its
+// Retry-After, or after teamsBackoff doubled on each attempt, up to
+// teamsMaxAttempts posts in all.
+const (
+	teamsMaxAttempts = 4
+	teamsBackoff     = 2 * time.Second
+	teamsMaxBackoff  = time.Minute
+)
+
+// teamsNotifier posts notifications to a Microsoft Teams webhook as Adaptive
+// Cards, one message per PR.
+type teamsNotifier struct {
+	client  *http.Client
+	url     string
+	backoff time.Duration
+}
+
+func newTeamsNotifier(client *http.Client, url string) *teamsNotifier {
+	return &teamsNotifier{
+		client:  client,
+		url:     url,
+		backoff: teamsBackoff,
+	}
+}
+
+func (t *teamsNotifier) destination() string { return "Teams" }
+
+// teamsMessage is the payload of a Teams webhook: a message carrying a
+// single Adaptive Card.
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string       `json:"contentType"`
+	Content     adaptiveCard `json:"content"`
+}
+
+type adaptiveCard struct {
+	Type    string            `json:"type"`
+	Schema  string            `json:"$schema"`
+	Version string            `json:"version"`
+	Body    []adaptiveElement `json:"body"`
+	Actions []adaptiveAction  `json:"actions"`
+}
+
+type adaptiveElement struct {
+	Type   string         `json:"type"`
+	Text   string         `json:"text,omitempty"`
+	Weight string         `json:"weight,omitempty"`
+	Size   string         `json:"size,omitempty"`
+	Wrap   bool           `json:"wrap,omitempty"`
+	Facts  []adaptiveFact `json:"facts,omitempty"`
+}
+
+type adaptiveFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+type adaptiveAction struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// newTeamsMessage lays out a notification about a PR: the subject, the PR's
+// number and title, its author and last activity, and a button opening it.
+func newTeamsMessage(subject string, pr *github.PullRequest, data notificationData) teamsMessage {
+	days := int(data.Inactive.Hours() / 24)
+	return teamsMessage{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content: adaptiveCard{
+				Type:    "AdaptiveCard",
+				Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+				Version: "1.4",
+				Body: []adaptiveElement{
+					{Type: "TextBlock", Text: subject, Weight: "Bolder", Size: "Medium", Wrap: true},
+					{Type: "TextBlock", Text: fmt.Sprintf("%s #%d: %s", data.KindShort(), data.Number, truncate(data.Title, 150)), Wrap: true},
+					{Type: "FactSet", Facts: []adaptiveFact{
+						{Title: "Author", Value: "@" + pr.GetUser().GetLogin()},
+						{Title: "Last activity", Value: fmt.Sprintf("%s, %d %s ago", formatDateIn(pr.GetUpdatedAt().Time, data.Location), days, pluralize(days, "day", "days"))},
+						{Title: "Repository", Value: data.Owner + "/" + data.Repo},
+					}},
+				},
+				Actions: []adaptiveAction{{Type: "Action.OpenUrl", Title: fmt.Sprintf("Open %s #%d", data.KindShort(), data.Number), URL: data.URL}},
+			},
+		}},
+	}
+}
+
+// post sends a notification about a PR, backing off while Teams throttles
+// the webhook.
+func (t *teamsNotifier) post(out *prOutput, subject string, pr *github.PullRequest, data notificationData) error {
+	payload, err := json.Marshal(newTeamsMessage(subject, pr, data))
+	if err != nil {
+		return fmt.Errorf("failed to encode Teams message: %v", err)
+	}
+	backoff := t.backoff
+	for attempt := 1; ; attempt++ {
+		wait, err := t.send(payload)
+		if err == nil {
+			return nil
+		}
+		if wait < 0 || attempt == teamsMaxAttempts {
+			return fmt.Errorf("failed to post to Teams: %v", err)
+		}
+		if wait == 0 {
+			wait = backoff
+			backoff *= 2
+		}
+		if wait > teamsMaxBackoff {
+			wait = teamsMaxBackoff
+		}
+		out.Warn("Teams is throttling the webhook, backing off", "action", "teams", "wait", wait, "attempt", attempt)
+		time.Sleep(wait)
+	}
+}
+
+// send posts payload once. On a 429 response it also returns how long Teams
+// asked to wait, or 0 if it did not say; other errors return a negative wait
+// and are not retried.
+func (t *teamsNotifier) send(payload []byte) (time.Duration, error) {
+	resp, err := t.client.Post(t.url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return -1, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return 0, nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	if resp.StatusCode != http.StatusTooManyRequests {
+		return -1, err
+	}
+	if secs, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil && secs > 0 {
+		return time.Duration(secs) * time.Second, err
+	}
+	return 0, err
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewTeamsMessage(t *testing.T) {
+	pr, data := testChatPR()
+	payload, err := json.Marshal(newTeamsMessage("PR #42 will be closed", pr, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Decode generically, to check the JSON Teams gets rather than the
+	// structs it is built from.
+	var msg struct {
+		Type        string `json:"type"`
+		Attachments []struct {
+			ContentType string                 `json:"contentType"`
+			Content     map[string]interface{} `json:"content"`
+		} `json:"attachments"`
+	}
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != "message" || len(msg.Attachments) != 1 || msg.Attachments[0].ContentType != "application/vnd.microsoft.card.adaptive" {
+		t.Fatalf("payload %s, want a message with one Adaptive Card", payload)
+	}
+	card := msg.Attachments[0].Content
+	for key, want := range map[string]string{
+		"type":    "AdaptiveCard",
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"version": "1.4",
+	} {
+		if card[key] != want {
+			t.Errorf("card %s = %v, want %q", key, card[key], want)
+		}
+	}
+	var want struct {
+		Body    interface{} `json:"body"`
+		Actions interface{} `json:"actions"`
+	}
+	if err := json.Unmarshal([]byte(`{
+		"body": [
+			{"type": "TextBlock", "text": "PR #42 will be closed", "weight": "Bolder", "size": "Medium", "wrap": true},
+			{"type": "TextBlock", "text": "PR #42: Use <b> & friends", "wrap": true},
+			{"type": "FactSet", "facts": [
+				{"title": "Author", "value": "@alice"},
+				{"title": "Last activity", "value": "March 1, 2026 (UTC), 31 days ago"},
+				{"title": "Repository", "value": "acme/api"}
+			]}
+		],
+		"actions": [{"type": "Action.OpenUrl", "title": "Open PR #42", "url": "https://github.com/acme/api/pull/42"}]
+	}`), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(card["body"], want.Body) {
+		t.Errorf("card body %v, want %v", card["body"], want.Body)
+	}
+	if !reflect.DeepEqual(card["actions"], want.Actions) {
+		t.Errorf("card actions %v, want %v", card["actions"], want.Actions)
+	}
+}
+
+func TestTeamsPost(t *testing.T) {
+	pr, data := testChatPR()
+	for _, tc := range []struct {
+		name      string
+		statuses  []int
+		wantPosts int
+		wantErr   string
+	}{
+		{name: "ok", statuses: []int{http.StatusOK}, wantPosts: 1},
+		{name: "throttled", statuses: []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusAccepted}, wantPosts: 3},
+		{name: "throttled too long", statuses: []int{http.StatusTooManyRequests}, wantPosts: teamsMaxAttempts, wantErr: "429 Too Many Requests"},
+		{name: "rejected", statuses: []int{http.StatusBadRequest}, wantPosts: 1, wantErr: "400 Bad Request"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, bodies := startChatWebhook(t, tc.statuses...)
+			n := newTeamsNotifier(http.DefaultClient, srv.URL)
+			n.backoff = 0
+			err := n.post(&prOutput{}, "Stale PR", pr, data)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("post returned %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Errorf("post returned %v, want an error containing %q", err, tc.wantErr)
+			}
+			if got := len(bodies()); got != tc.wantPosts {
+				t.Errorf("the webhook got %d posts, want %d", got, tc.wantPosts)
+			}
+		})
+	}
+}
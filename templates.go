@@ -0,0 +1,363 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// templateFuncs returns the function library available to every notification
+// template (emails, PR comments and discussion summaries), rendering dates in
+// loc by default:
+//
+//	humanizeDuration .Inactive        -> "3 weeks"
+//	pluralize .WarningPeriod "day" "days" -> "day" or "days"
+//	truncate .Title 60                -> title cut to 60 characters with "…"
+//	formatDate .Deadline              -> "July 15, 2024" in the default display timezone
+//	formatDateIn .Deadline .Location  -> "July 15, 2024 (IST)" in the given timezone
+//	isoUTC .Deadline                  -> "2024-07-15T09:30:00Z"
+//	mdEscape .Title                   -> text safe to embed in Markdown
+func templateFuncs(loc *time.Location) template.FuncMap {
+	return template.FuncMap{
+		"humanizeDuration": humanizeDuration,
+		"pluralize":        pluralize,
+		"truncate":         truncate,
+		"formatDate": func(t time.Time) string {
+			return formatDate(t, loc)
+		},
+		"formatDateIn": func(t time.Time, in *time.Location) string {
+			if in == nil {
+				in = loc
+			}
+			return formatDateIn(t, in)
+		},
+		"isoUTC":   isoUTC,
+		"mdEscape": mdEscape,
+	}
+}
+
+// templateRenderer renders notification templates with dates in the
+// configured default display timezone.
+type templateRenderer struct {
+	funcs template.FuncMap
+	// overrides maps template names to text replacing the built-in
+	// template; see loadTemplateOverrides.
+	overrides map[string]string
+}
+
+func newTemplateRenderer(cfg *config) *templateRenderer {
+	return &templateRenderer{funcs: templateFuncs(cfg.DisplayLocation)}
+}
+
+// notificationData is the data passed to notification templates.
+type notificationData struct {
+	Login string
+	// DisplayName is the recipient's GitHub display name, if they set one.
+	DisplayName string
+	// Greeting is the name the recipient is greeted by: their display
+	// name or their login.
+	Greeting string
+	Number   int
+	Title    string
+	URL      string
+	Owner    string
+	Repo     string
+	// Inactive is how long the PR has gone without activity.
+	Inactive time.Duration
+	// DaysInactive is the configured staleness threshold in days.
+	DaysInactive int
+	// WarningPeriod is the configured warning period in days.
+	WarningPeriod int
+	// Deadline is when a warned PR becomes eligible for closure.
+	Deadline time.Time
+	// DaysRemaining is the number of whole days left before Deadline, set
+	// for reminders.
+	DaysRemaining int
+	// Location is the recipient's timezone for rendering dates.
+	Location *time.Location
+	// FailingChecks lists the failing checks that made the PR stale, if any.
+	FailingChecks []string
+	// PathProtected is set when the PR changes protected paths and so will
+	// not be closed automatically.
+	PathProtected bool
+	// ReopenedAt is when the PR was reopened after an earlier closure for
+	// inactivity, if it was.
+	ReopenedAt time.Time
+	// ParkedIn is the milestone stale PRs are parked in instead of being
+	// closed, if any.
+	ParkedIn string
+	// Kind is what the item is: a pull request, or an issue with
+	// --include-issues.
+	Kind string
+	// CloseReason is why the PR is closed, for closure messages:
+	// never-reviewed, author-unresponsive, discussion-quiet or "" if
+	// unknown.
+	CloseReason string
+	// NextReview is the earliest a PR that became active again can go
+	// stale, set for unstale notifications.
+	NextReview time.Time
+}
+
+// KindShort is the short name of the item's kind, for link labels.
+func (d notificationData) KindShort() string {
+	if d.Kind == kindIssue {
+		return "Issue"
+	}
+	return "PR"
+}
+
+func newNotificationData(cfg *config, pr *github.PullRequest, owner, repo string, now time.Time) notificationData {
+	daysInactive, warningPeriod := cfg.Rules.DaysInactive, cfg.Rules.WarningPeriod
+	return notificationData{
+		Login:         pr.GetUser().GetLogin(),
+		Greeting:      pr.GetUser().GetLogin(),
+		Number:        pr.GetNumber(),
+		Title:         pr.GetTitle(),
+		URL:           pr.GetHTMLURL(),
+		Owner:         owner,
+		Repo:          repo,
+		Inactive:      now.Sub(pr.GetUpdatedAt().Time),
+		DaysInactive:  daysInactive,
+		WarningPeriod: warningPeriod,
+		Deadline:      now.Add(time.Duration(warningPeriod) * 24 * time.Hour),
+		Location:      cfg.location(pr.GetUser().GetLogin()),
+		ParkedIn:      cfg.Rules.ParkedMilestone,
+		Kind:          kindPullRequest,
+	}
+}
+
+const warningEmailTemplate = `Hello {{.Greeting}},
+
+Your {{.Kind}} #{{.Number}} "{{truncate .Title 80}}" has been inactive for {{humanizeDuration .Inactive}}. Please update it within the next {{.WarningPeriod}} {{pluralize .WarningPeriod "day" "days"}} (by {{formatDateIn .Deadline .Location}})
+{{- if .PathProtected}}. It changes protected paths, so it will not be closed automatically; a maintainer will follow up.{{else}}, or it may be closed.{{end}}
+{{- if not .ReopenedAt.IsZero}}
+
+This {{.Kind}} was previously closed for inactivity and reopened on {{formatDateIn .ReopenedAt .Location}}.{{end}}
+
+{{.KindShort}} Link: {{.URL}}
+
+Best regards,
+The Bot`
+
+const failingChecksWarningEmailTemplate = `Hello {{.Greeting}},
+
+The checks on your {{.Kind}} #{{.Number}} "{{truncate .Title 80}}" have been failing without a fix for a while:
+{{range .FailingChecks}}
+  - {{.}}{{end}}
+
+Please push a fix within the next {{.WarningPeriod}} {{pluralize .WarningPeriod "day" "days"}} (by {{formatDateIn .Deadline .Location}})
+{{- if .PathProtected}}. It changes protected paths, so it will not be closed automatically; a maintainer will follow up.{{else}}, or it may be closed.{{end}}
+{{- if not .ReopenedAt.IsZero}}
+
+This {{.Kind}} was previously closed for inactivity and reopened on {{formatDateIn .ReopenedAt .Location}}.{{end}}
+
+{{.KindShort}} Link: {{.URL}}
+
+Best regards,
+The Bot`
+
+const reminderEmailTemplate = `Hello {{.Greeting}},
+
+This is a reminder that your {{.Kind}} #{{.Number}} "{{truncate .Title 80}}" is still inactive.
+{{- if .PathProtected}} It changes protected paths, so it will not be closed automatically; a maintainer will follow up.{{else}} It will be closed in {{.DaysRemaining}} {{pluralize .DaysRemaining "day" "days"}} (on {{formatDateIn .Deadline .Location}}) unless it is updated.{{end}}
+
+{{.KindShort}} Link: {{.URL}}
+
+Best regards,
+The Bot`
+
+const unstaleEmailTemplate = `Hello {{.Greeting}},
+
+Thanks for updating your {{.Kind}} #{{.Number}} "{{truncate .Title 80}}". The stale warning has been removed and the inactivity timer has been reset, so it will not be closed.
+
+The next review of this {{.Kind}} for inactivity will be no earlier than {{formatDateIn .NextReview .Location}}.
+
+{{.KindShort}} Link: {{.URL}}
+
+Best regards,
+The Bot`
+
+const closureEmailTemplate = `Hello {{.Greeting}},
+
+{{- if .ParkedIn}}
+Your {{.Kind}} #{{.Number}} "{{truncate .Title 80}}" has been parked in the "{{.ParkedIn}}" milestone due to inactivity after {{humanizeDuration .Inactive}} without updates. It remains open.
+
+{{.KindShort}} Link: {{.URL}}
+
+If you wish to continue working, please ask a maintainer to move it out of the milestone.
+{{- else}}
+Your {{.Kind}} #{{.Number}} "{{truncate .Title 80}}" has been closed due to inactivity after {{humanizeDuration .Inactive}} without updates.
+
+{{.KindShort}} Link: {{.URL}}
+
+If you wish to continue working, please feel free to reopen it or open a new {{.Kind}}.
+{{- end}}
+
+Best regards,
+The Bot`
+
+const closeCommentTemplate = `@{{.Login}} this {{.Kind}} has been {{if .ParkedIn}}parked in the "{{.ParkedIn}}" milestone{{else}}closed{{end}} automatically because it has had no activity for more than {{.DaysInactive}} {{pluralize .DaysInactive "day" "days"}}.
+{{- if .ParkedIn}} It remains open; move it out of the milestone to have it evaluated again.{{end}}`
+
+const discussionSummaryTemplate = `### Stale PR bot run on {{formatDate .Now}} ({{isoUTC .Now}})
+{{with .Softened}}
+> **Warn-only mode:** {{.}}. No PRs were closed.
+{{end}}{{with .ParkedIn}}
+> **Milestone mode:** stale PRs are parked in the "{{.}}" milestone and left open; the closed PRs below were parked.
+{{end}}{{with .Unprocessed}}
+> **Time-boxed:** {{.}} {{pluralize . "PR" "PRs"}} unprocessed; the next run processes {{pluralize . "it" "them"}} first.
+{{end}}
+**Closed for inactivity**
+
+{{range .Closed}}- #{{.GetNumber}} {{mdEscape (truncate .GetTitle 100)}} (@{{.GetUser.GetLogin}})
+{{else}}_None._
+{{end}}
+**Warned as stale**
+
+{{range .Warned}}- #{{.GetNumber}} {{mdEscape (truncate .GetTitle 100)}} (@{{.GetUser.GetLogin}})
+{{else}}_None._
+{{end}}{{with .Duplicates}}
+**Possible duplicates**
+
+{{range .}}- {{mdEscape .String}}
+{{end}}{{end}}`
+
+const runSummaryTemplate = `<!-- stale-pr-bot:last-run -->
+**Last run:** {{formatDate .Now}} ({{isoUTC .Now}})
+**Repository:** {{.Owner}}/{{.Repo}}
+{{- with .Summary.Softened}}
+
+> **Warn-only mode:** {{.}}. No PRs were closed.{{end}}
+{{- with .Summary.ParkedIn}}
+
+> **Milestone mode:** stale PRs are parked in the "{{.}}" milestone and left open; closed PRs were parked.{{end}}
+{{- with .Summary.Unprocessed}}
+
+> **Time-boxed:** {{.}} {{pluralize . "PR" "PRs"}} unprocessed; the next run processes {{pluralize . "it" "them"}} first.{{end}}
+
+| Action | Count |
+| --- | --- |
+| Open PRs evaluated | {{.Summary.Evaluated}} |
+| Warned | {{len .Summary.Warned}} |
+| Closed | {{len .Summary.Closed}} |
+{{- range $reason, $n := .Summary.CloseReasons}}
+| Closed, {{$reason}} | {{$n}} |
+{{- end}}
+| Closures skipped for protected paths | {{.Summary.PathProtected}} |
+| Closures aborted by safety check | {{.Summary.SafetyAborted}} |
+{{- if and .Summary.PublishAuthorStats .Summary.AuthorStats}}
+
+| Author | Stale now | Closed by the bot | Resurrected after warning |
+| --- | --- | --- | --- |
+{{- range .Summary.AuthorStats}}
+| @{{.Login}} | {{.Stale}} | {{.Closed}} | {{.Resurrected}} of {{.Warned}} ({{.ResurrectionRate}}) |
+{{- end}}
+{{- end}}
+{{range .Summary.SecurityExempt}}
+- Exempt as security work: {{mdEscape .}}{{end}}{{range .Summary.Duplicates}}
+- Possible duplicates by {{mdEscape .String}}{{end}}{{range .Summary.Escalated}}
+- Escalated stale security update: {{mdEscape .}}{{end}}{{range .Summary.DeadLettered}}
+- Needs manual follow-up: {{mdEscape .}}{{end}}{{range .Summary.Closed}}
+- Closed #{{.GetNumber}} {{mdEscape (truncate .GetTitle 100)}} (@{{.GetUser.GetLogin}}){{end}}{{range .Summary.Warned}}
+- Warned #{{.GetNumber}} {{mdEscape (truncate .GetTitle 100)}} (@{{.GetUser.GetLogin}}){{end}}
+`
+
+// render executes a notification template with the shared function library.
+func (r *templateRenderer) render(name, text string, data interface{}) (string, error) {
+	if override, ok := r.overrides[name]; ok {
+		text = override
+	}
+	tmpl, err := template.New(name).Funcs(r.funcs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s template: %v", name, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %v", name, err)
+	}
+	return b.String(), nil
+}
+
+// renderClosure renders a closure template, using the override variant for
+// the close reason if there is one and the template itself otherwise.
+func (r *templateRenderer) renderClosure(name, text string, data notificationData) (string, error) {
+	if variant := closureVariant(name, data.CloseReason); data.CloseReason != "" && r.overrides[variant] != "" {
+		name = variant
+	}
+	return r.render(name, text, data)
+}
+
+// humanizeDuration renders a duration in its largest whole unit, e.g. "3 weeks".
+func humanizeDuration(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+	units := []struct {
+		size     time.Duration
+		singular string
+		plural   string
+	}{
+		{365 * 24 * time.Hour, "year", "years"},
+		{30 * 24 * time.Hour, "month", "months"},
+		{7 * 24 * time.Hour, "week", "weeks"},
+		{24 * time.Hour, "day", "days"},
+		{time.Hour, "hour", "hours"},
+		{time.Minute, "minute", "minutes"},
+	}
+	for _, u := range units {
+		if n := int(d / u.size); n >= 1 {
+			return fmt.Sprintf("%d %s", n, pluralize(n, u.singular, u.plural))
+		}
+	}
+	return "less than a minute"
+}
+
+// pluralize returns singular when n is 1 and plural otherwise.
+func pluralize(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
+
+// truncate shortens s to at most n characters, ending with an ellipsis when cut.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if n <= 0 || len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// formatDate renders t as a date in loc.
+func formatDate(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format("January 2, 2006")
+}
+
+// formatDateIn renders t as a date in loc followed by the zone abbreviation,
+// e.g. "July 15, 2024 (IST)".
+func formatDateIn(t time.Time, loc *time.Location) string {
+	t = t.In(loc)
+	return fmt.Sprintf("%s (%s)", t.Format("January 2, 2006"), t.Format("MST"))
+}
+
+// isoUTC renders t as an ISO-8601 UTC timestamp.
+func isoUTC(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// mdEscape backslash-escapes Markdown syntax so user-controlled text such as
+// PR titles renders literally in comments.
+func mdEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune("\\`*_{}[]()#+!|<>~", r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
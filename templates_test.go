@@ -0,0 +1,271 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHumanizeDuration(t *testing.T) {
+	day := 24 * time.Hour
+	for _, tc := range []struct {
+		d    time.Duration
+		want string
+	}{
+		{30 * time.Second, "less than a minute"},
+		{time.Minute, "1 minute"},
+		{90 * time.Minute, "1 hour"},
+		{day, "1 day"},
+		{6 * day, "6 days"},
+		{21 * day, "3 weeks"},
+		{45 * day, "1 month"},
+		{400 * day, "1 year"},
+		{800 * day, "2 years"},
+		{-3 * day, "3 days"},
+	} {
+		if got := humanizeDuration(tc.d); got != tc.want {
+			t.Errorf("humanizeDuration(%v) = %q, want %q", tc.d, got, tc.want)
+		}
+	}
+}
+
+func TestPluralize(t *testing.T) {
+	for _, tc := range []struct {
+		n    int
+		want string
+	}{
+		{0, "days"},
+		{1, "day"},
+		{2, "days"},
+		{-1, "days"},
+	} {
+		if got := pluralize(tc.n, "day", "days"); got != tc.want {
+			t.Errorf("pluralize(%d) = %q, want %q", tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"Add retries", 20, "Add retries"},
+		{"Add retries", 11, "Add retries"},
+		{"Add retries", 10, "Add retri…"},
+		{"Ajouter les réglages", 10, "Ajouter l…"},
+		{"日本語のタイトル", 4, "日本語…"},
+		{"Add retries", 0, "Add retries"},
+	} {
+		if got := truncate(tc.s, tc.n); got != tc.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tc.s, tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skip(err)
+	}
+	// 20:00 UTC is already the next day in India.
+	ts := time.Date(2024, 7, 14, 20, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"formatDate UTC", formatDate(ts, time.UTC), "July 14, 2024"},
+		{"formatDate Kolkata", formatDate(ts, kolkata), "July 15, 2024"},
+		{"formatDateIn UTC", formatDateIn(ts, time.UTC), "July 14, 2024 (UTC)"},
+		{"formatDateIn Kolkata", formatDateIn(ts, kolkata), "July 15, 2024 (IST)"},
+		{"isoUTC", isoUTC(ts.In(kolkata)), "2024-07-14T20:00:00Z"},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("%s = %q, want %q", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestMdEscape(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		want string
+	}{
+		{"Add retries", "Add retries"},
+		{"Fix *bold* and _italic_", `Fix \*bold\* and \_italic\_`},
+		{"[link](https://evil.example)", `\[link\]\(https://evil.example\)`},
+		{"<img src=x> | `code`", "\\<img src=x\\> \\| \\`code\\`"},
+		{"# heading ~strike~ !", `\# heading \~strike\~ \!`},
+		{`back\slash`, `back\\slash`},
+	} {
+		if got := mdEscape(tc.s); got != tc.want {
+			t.Errorf("mdEscape(%q) = %q, want %q", tc.s, got, tc.want)
+		}
+	}
+}
+
+func TestTemplateFuncsHonorTimezone(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skip(err)
+	}
+	r := newTemplateRenderer(&config{DisplayLocation: kolkata})
+	data := notificationData{
+		Title:    "Rework the *retry* loop",
+		Inactive: 21 * 24 * time.Hour,
+		Deadline: time.Date(2024, 7, 14, 20, 0, 0, 0, time.UTC),
+	}
+	for _, tc := range []struct {
+		text string
+		want string
+	}{
+		{`{{formatDate .Deadline}}`, "July 15, 2024"},
+		// A nil recipient location falls back to the default timezone.
+		{`{{formatDateIn .Deadline .Location}}`, "July 15, 2024 (IST)"},
+		{`{{isoUTC .Deadline}}`, "2024-07-14T20:00:00Z"},
+		{`{{humanizeDuration .Inactive}}`, "3 weeks"},
+		{`{{pluralize 1 "day" "days"}}`, "day"},
+		{`{{mdEscape (truncate .Title 16)}}`, `Rework the \*ret…`},
+	} {
+		got, err := r.render("test", tc.text, data)
+		if err != nil {
+			t.Errorf("render(%s): %v", tc.text, err)
+		} else if got != tc.want {
+			t.Errorf("render(%s) = %q, want %q", tc.text, got, tc.want)
+		}
+	}
+}
+
+// writeTemplateDir writes files, mapping names to content, to a new
+// directory.
+func writeTemplateDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestTemplateOverrideResolution(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		files map[string]string
+		// want maps template names to the text they render, "" for the
+		// built-in template.
+		want map[string]string
+	}{
+		{
+			name: "built-in",
+			want: map[string]string{"closure email": "", "close comment": ""},
+		},
+		{
+			name:  "shared action",
+			files: map[string]string{"closure.tmpl": "closed {{.Number}}"},
+			want:  map[string]string{"closure email": "closed 7", "close comment": "closed 7", "warning email": ""},
+		},
+		{
+			name:  "channel beats shared action",
+			files: map[string]string{"closure.tmpl": "closed {{.Number}}", "email-closure.tmpl": "email: closed {{.Number}}"},
+			want:  map[string]string{"closure email": "email: closed 7", "close comment": "closed 7"},
+		},
+		{
+			name:  "channel only",
+			files: map[string]string{"comment-closure.tmpl": "comment: closed"},
+			want:  map[string]string{"closure email": "", "close comment": "comment: closed"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newTemplateRenderer(&config{DisplayLocation: time.UTC})
+			var err error
+			r.overrides, err = loadTemplateOverrides(writeTemplateDir(t, tc.files), r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tc.want {
+				got, err := r.render(name, "built-in", notificationData{Number: 7})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want == "" {
+					want = "built-in"
+				}
+				if got != want {
+					t.Errorf("%s rendered %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestInvalidTemplateOverride(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{name: "syntax", files: map[string]string{"warning.tmpl": "{{.Number"}, wantErr: "warning.tmpl for the warning email"},
+		{name: "unknown field", files: map[string]string{"email-reminder.tmpl": "{{.Nope}}"}, wantErr: "email-reminder.tmpl for the reminder email"},
+		{name: "unknown function", files: map[string]string{"closure.tmpl": "{{shout .Title}}"}, wantErr: `function "shout" not defined`},
+	} {
+		r := newTemplateRenderer(&config{DisplayLocation: time.UTC})
+		if _, err := loadTemplateOverrides(writeTemplateDir(t, tc.files), r); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: loadTemplateOverrides returned %v, want an error containing %q", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestClosureReasonVariants(t *testing.T) {
+	r := newTemplateRenderer(&config{DisplayLocation: time.UTC})
+	var err error
+	r.overrides, err = loadTemplateOverrides(writeTemplateDir(t, map[string]string{
+		"closure.tmpl":                          "closed",
+		"email-closure-never-reviewed.tmpl":     "email: sorry nobody reviewed it",
+		"closure-author-unresponsive.tmpl":      "we waited for you",
+		"comment-closure-discussion-quiet.tmpl": "comment: the discussion went quiet",
+	}), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		template, reason, want string
+	}{
+		{"closure email", closeNeverReviewed, "email: sorry nobody reviewed it"},
+		{"close comment", closeNeverReviewed, "closed"},
+		{"closure email", closeAuthorUnresponsive, "we waited for you"},
+		{"close comment", closeAuthorUnresponsive, "we waited for you"},
+		{"closure email", closeDiscussionQuiet, "closed"},
+		{"close comment", closeDiscussionQuiet, "comment: the discussion went quiet"},
+		{"closure email", "", "closed"},
+	} {
+		got, err := r.renderClosure(tc.template, "built-in", notificationData{CloseReason: tc.reason})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("%s for %q rendered %q, want %q", tc.template, tc.reason, got, tc.want)
+		}
+	}
+}
+
+func TestCheckTemplateDir(t *testing.T) {
+	dir := writeTemplateDir(t, map[string]string{
+		"warning.tmpl":                      "",
+		"email-closure-never-reviewed.tmpl": "",
+		"email-warnign.tmpl":                "",
+		"README.md":                         "",
+	})
+	unknown, err := checkTemplateDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unknown) != 1 || unknown[0] != "email-warnign.tmpl" {
+		t.Errorf("checkTemplateDir reported %q, want only the misspelt email-warnign.tmpl", unknown)
+	}
+}
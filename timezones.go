@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// userTimezones maps lower-cased GitHub logins to the timezone dates in their
+// notifications are rendered in.
+type userTimezones map[string]*time.Location
+
+// loadUserTimezones reads a timezone mapping file with one "login: Area/City"
+// entry per line. Blank lines and lines starting with # are ignored. Entries
+// naming an unknown timezone are reported and skipped so those users fall
+// back to the default display timezone.
+func loadUserTimezones(path string) (userTimezones, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open timezone file: %v", err)
+	}
+	defer f.Close()
+
+	zones := userTimezones{}
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		login, zone, ok := strings.Cut(line, ":")
+		login = strings.TrimSpace(login)
+		zone = strings.Trim(strings.TrimSpace(zone), `"'`)
+		if !ok || login == "" || zone == "" {
+			slog.Warn("expected \"login: Area/City\", ignoring line", "file", path, "line", lineNo)
+			continue
+		}
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			slog.Warn("unknown timezone, using the default display timezone", "file", path, "line", lineNo, "timezone", zone, "author", login)
+			continue
+		}
+		zones[strings.ToLower(login)] = loc
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read timezone file: %v", err)
+	}
+	return zones, nil
+}
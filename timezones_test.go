@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+func TestLoadUserTimezones(t *testing.T) {
+	path := writeTestFile(t, "timezones.txt", `# login: Area/City
+alice: Asia/Kolkata
+Bob: "America/Los_Angeles"
+
+carol Europe/Paris
+dave: Mars/Olympus_Mons
+erin:
+`)
+	zones, err := loadUserTimezones(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for login, loc := range zones {
+		got[login] = loc.String()
+	}
+	want := map[string]string{"alice": "Asia/Kolkata", "bob": "America/Los_Angeles"}
+	if len(got) != len(want) || got["alice"] != want["alice"] || got["bob"] != want["bob"] {
+		t.Errorf("loaded %v, want %v", got, want)
+	}
+
+	if _, err := loadUserTimezones(path + ".missing"); err == nil || !strings.Contains(err.Error(), "failed to open timezone file") {
+		t.Errorf("loading a missing file returned %v", err)
+	}
+}
+
+func TestRecipientLocation(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skip(err)
+	}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	cfg := &config{DisplayLocation: berlin, Timezones: userTimezones{"alice": kolkata}}
+	for _, tc := range []struct {
+		login string
+		want  *time.Location
+	}{
+		{"alice", kolkata},
+		{"Alice", kolkata},
+		// Users without a timezone, including those whose entry named an
+		// unknown one, get the default display timezone.
+		{"dave", berlin},
+	} {
+		if got := cfg.location(tc.login); got != tc.want {
+			t.Errorf("location(%q) = %v, want %v", tc.login, got, tc.want)
+		}
+	}
+}
+
+func TestDeadlinesAcrossDSTChanges(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip(err)
+	}
+	london, _ := time.LoadLocation("Europe/London")
+	kolkata, _ := time.LoadLocation("Asia/Kolkata")
+	cfg := &config{
+		DisplayLocation: time.UTC,
+		Timezones:       userTimezones{"alice": la, "bob": london, "chandra": kolkata},
+		Rules:           evaluationRules{DaysInactive: 30, WarningPeriod: 7},
+	}
+	r := newTemplateRenderer(cfg)
+	for _, tc := range []struct {
+		name  string
+		login string
+		// now is when the warning is sent; the deadline is a week later.
+		now  time.Time
+		want string
+	}{
+		// Los Angeles moves to PDT at 10:00 UTC on March 8, 2026.
+		{"before spring forward", "alice", time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC), "March 8, 2026 (PST)"},
+		{"after spring forward", "alice", time.Date(2026, 3, 1, 10, 30, 0, 0, time.UTC), "March 8, 2026 (PDT)"},
+		// Los Angeles moves back to PST at 09:00 UTC on November 1, 2026;
+		// 06:30 UTC that day is still October 31 in PDT.
+		{"day before fall back", "alice", time.Date(2026, 10, 25, 6, 30, 0, 0, time.UTC), "October 31, 2026 (PDT)"},
+		{"after fall back", "alice", time.Date(2026, 10, 25, 9, 30, 0, 0, time.UTC), "November 1, 2026 (PST)"},
+		// London moves back to GMT at 01:00 UTC on October 25, 2026.
+		{"London before fall back", "bob", time.Date(2026, 10, 18, 0, 30, 0, 0, time.UTC), "October 25, 2026 (BST)"},
+		{"London after fall back", "bob", time.Date(2026, 10, 18, 1, 30, 0, 0, time.UTC), "October 25, 2026 (GMT)"},
+		{"no DST", "chandra", time.Date(2026, 3, 1, 20, 0, 0, 0, time.UTC), "March 9, 2026 (IST)"},
+		{"default timezone", "dave", time.Date(2026, 3, 1, 20, 0, 0, 0, time.UTC), "March 8, 2026 (UTC)"},
+	} {
+		pr := testPR(tc.login, tc.now.AddDate(0, 0, -31))
+		pr.Number = github.Ptr(7)
+		body, err := r.render("warning email", warningEmailTemplate, newNotificationData(cfg, pr, "acme", "api", tc.now))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "(by " + tc.want + ")"; !strings.Contains(body, want) {
+			t.Errorf("%s: the warning says:\n%s\nwant the deadline %s", tc.name, body, want)
+		}
+	}
+}
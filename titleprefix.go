@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// hasTitlePrefix reports whether title starts with prefix.
+func hasTitlePrefix(title, prefix string) bool {
+	return strings.HasPrefix(title, prefix)
+}
+
+// prefixTitle returns title with prefix and a space in front, unless it
+// already starts with prefix.
+func prefixTitle(title, prefix string) string {
+	if hasTitlePrefix(title, prefix) {
+		return title
+	}
+	return prefix + " " + title
+}
+
+// unprefixTitle returns title without prefix and the spaces after it.
+func unprefixTitle(title, prefix string) string {
+	if !hasTitlePrefix(title, prefix) {
+		return title
+	}
+	return strings.TrimLeft(strings.TrimPrefix(title, prefix), " ")
+}
+
+// editTitle sets the title of a PR or an issue; the issues API edits both.
+func editTitle(client *github.Client, owner, repo string, number int, title string) error {
+	ctx := context.Background()
+	_, _, err := client.Issues.Edit(ctx, owner, repo, number, &github.IssueRequest{Title: github.Ptr(title)})
+	return err
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestTitlePrefix(t *testing.T) {
+	const prefix = "[stale]"
+	for _, tc := range []struct {
+		title, prefixed, unprefixed string
+	}{
+		{"Add a feature", "[stale] Add a feature", "Add a feature"},
+		{"[stale] Add a feature", "[stale] Add a feature", "Add a feature"},
+		{"[stale]Add a feature", "[stale]Add a feature", "Add a feature"},
+		{"[stale]   Add a feature", "[stale]   Add a feature", "Add a feature"},
+		{"[Stale] Add a feature", "[stale] [Stale] Add a feature", "[Stale] Add a feature"},
+		{"WIP [stale] Add a feature", "[stale] WIP [stale] Add a feature", "WIP [stale] Add a feature"},
+		{"", "[stale] ", ""},
+	} {
+		if got := prefixTitle(tc.title, prefix); got != tc.prefixed {
+			t.Errorf("prefixTitle(%q) = %q, want %q", tc.title, got, tc.prefixed)
+		}
+		if got := unprefixTitle(tc.title, prefix); got != tc.unprefixed {
+			t.Errorf("unprefixTitle(%q) = %q, want %q", tc.title, got, tc.unprefixed)
+		}
+		// Prefixing and unprefixing round-trips a title without the prefix.
+		if !hasTitlePrefix(tc.title, prefix) {
+			if got := unprefixTitle(prefixTitle(tc.title, prefix), prefix); got != tc.title {
+				t.Errorf("unprefixing the prefixed %q gives %q", tc.title, got)
+			}
+		}
+	}
+}
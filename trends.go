@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// trendRecord is one line of the --trends-file: aggregate metrics for a
+// single run against a single repository. It deliberately holds no logins,
+// titles or PR numbers.
+type trendRecord struct {
+	Time time.Time `json:"time"`
+	Repo string    `json:"repo"`
+	// FullScan is false for incremental runs, whose OpenPRs and percentiles
+	// only cover the PRs re-evaluated.
+	FullScan bool `json:"full_scan"`
+	OpenPRs  int  `json:"open_prs"`
+	Warned   int  `json:"warned"`
+	Closed   int  `json:"closed"`
+	// InactiveDaysP50 and InactiveDaysP90 are percentiles of the days since
+	// each evaluated PR was last updated.
+	InactiveDaysP50 float64 `json:"inactive_days_p50"`
+	InactiveDaysP90 float64 `json:"inactive_days_p90"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	// SLA holds the SLA intervals completed during the run, with
+	// --sla-metrics.
+	SLA *slaSample `json:"sla,omitempty"`
+}
+
+// newTrendRecord builds the trend record for a finished run.
+func newTrendRecord(owner, repo string, fullScan bool, prs []*github.PullRequest, summary *runSummary, started, now time.Time) trendRecord {
+	days := make([]float64, 0, len(prs))
+	for _, pr := range prs {
+		days = append(days, now.Sub(pr.GetUpdatedAt().Time).Hours()/24)
+	}
+	rec := trendRecord{
+		Time:            now.UTC(),
+		Repo:            owner + "/" + repo,
+		FullScan:        fullScan,
+		OpenPRs:         len(prs),
+		Warned:          len(summary.Warned),
+		Closed:          len(summary.Closed),
+		InactiveDaysP50: round1(percentile(days, 50)),
+		InactiveDaysP90: round1(percentile(days, 90)),
+		DurationSeconds: round1(now.Sub(started).Seconds()),
+	}
+	if !summary.SLA.empty() {
+		rec.SLA = summary.SLA
+	}
+	return rec
+}
+
+// percentile returns the p-th percentile (0-100) of values using linear
+// interpolation between closest ranks. It returns 0 for no values.
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	if p <= 0 {
+		return sorted[0]
+	}
+	if p >= 100 {
+		return sorted[len(sorted)-1]
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo))
+}
+
+func round1(f float64) float64 {
+	return math.Round(f*10) / 10
+}
+
+// appendTrend appends rec to the trends file at path. When the file has
+// reached the rotation's maxSize it is first rotated. A maxSize of zero
+// disables rotation.
+func appendTrend(path string, rec trendRecord, r rotation) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode trend record: %v", err)
+	}
+	if r.maxSize > 0 {
+		if info, err := os.Stat(path); err == nil && info.Size() >= r.maxSize {
+			if err := r.rotate(path); err != nil {
+				return fmt.Errorf("failed to rotate trends file: %v", err)
+			}
+		}
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open trends file: %v", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write trends file: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write trends file: %v", err)
+	}
+	return nil
+}
+
+// readTrends reads the trend records from path, preceded by those in its
+// rotated backups, oldest first, and in the uncompressed <path>.1 older
+// versions rotated to. Malformed lines are skipped.
+func readTrends(path string) ([]trendRecord, error) {
+	backups, err := rotatedBackups(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trends file backups: %v", err)
+	}
+	paths := []string{path + ".1"}
+	for i := len(backups) - 1; i >= 0; i-- {
+		paths = append(paths, backupPath(path, backups[i]))
+	}
+	paths = append(paths, path)
+	var records []trendRecord
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read trends file: %v", err)
+		}
+		var r io.Reader = f
+		if strings.HasSuffix(p, ".gz") {
+			zr, err := gzip.NewReader(f)
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("failed to read trends file %s: %v", p, err)
+			}
+			r = zr
+		}
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			var rec trendRecord
+			if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+				continue
+			}
+			records = append(records, rec)
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read trends file %s: %v", p, err)
+		}
+	}
+	return records, nil
+}
+
+// lastTrendsPerRepo returns the last n records of each repository, grouped
+// by repository name and in time order within each group.
+func lastTrendsPerRepo(records []trendRecord, n int) []trendRecord {
+	byRepo := map[string][]trendRecord{}
+	var repos []string
+	for _, rec := range records {
+		if _, ok := byRepo[rec.Repo]; !ok {
+			repos = append(repos, rec.Repo)
+		}
+		byRepo[rec.Repo] = append(byRepo[rec.Repo], rec)
+	}
+	sort.Strings(repos)
+	var out []trendRecord
+	for _, repo := range repos {
+		recs := byRepo[repo]
+		sort.SliceStable(recs, func(i, j int) bool { return recs[i].Time.Before(recs[j].Time) })
+		if n > 0 && len(recs) > n {
+			recs = recs[len(recs)-n:]
+		}
+		out = append(out, recs...)
+	}
+	return out
+}
+
+// runTrendsCommand implements the "trends" subcommand, printing the recorded
+// trends as a text table or CSV.
+func runTrendsCommand(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("trends", flag.ContinueOnError)
+	fileFlag := fs.String("trends-file", os.Getenv("TRENDS_FILE"), "Trends file written by --trends-file")
+	lastFlag := fs.Int("last", 10, "Number of most recent runs to show per repository (0 = all)")
+	formatFlag := fs.String("format", "table", "Output format: table or csv")
+	slaFlag := fs.Bool("sla", false, "Show the SLA percentiles recorded with --sla-metrics per repository and month, in days, instead of the runs")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *fileFlag == "" {
+		return fmt.Errorf("--trends-file is required")
+	}
+	if *formatFlag != "table" && *formatFlag != "csv" {
+		return fmt.Errorf("invalid format %q: must be table or csv", *formatFlag)
+	}
+
+	records, err := readTrends(*fileFlag)
+	if err != nil {
+		return err
+	}
+	var header []string
+	var rows [][]string
+	if *slaFlag {
+		header, rows = slaRows(slaByMonth(records))
+	} else {
+		header, rows = trendRows(lastTrendsPerRepo(records, *lastFlag))
+	}
+
+	if *formatFlag == "csv" {
+		cw := csv.NewWriter(w)
+		cw.Write(header)
+		cw.WriteAll(rows)
+		return cw.Error()
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	writeTabRow(tw, header)
+	for _, row := range rows {
+		writeTabRow(tw, row)
+	}
+	return tw.Flush()
+}
+
+// trendRows renders trend records as table rows under header.
+func trendRows(records []trendRecord) (header []string, rows [][]string) {
+	header = []string{"repo", "time", "scan", "open_prs", "warned", "closed", "inactive_days_p50", "inactive_days_p90", "duration_s"}
+	for _, rec := range records {
+		scan := "full"
+		if !rec.FullScan {
+			scan = "incremental"
+		}
+		rows = append(rows, []string{
+			rec.Repo,
+			rec.Time.Format(time.RFC3339),
+			scan,
+			strconv.Itoa(rec.OpenPRs),
+			strconv.Itoa(rec.Warned),
+			strconv.Itoa(rec.Closed),
+			strconv.FormatFloat(rec.InactiveDaysP50, 'f', 1, 64),
+			strconv.FormatFloat(rec.InactiveDaysP90, 'f', 1, 64),
+			strconv.FormatFloat(rec.DurationSeconds, 'f', 1, 64),
+		})
+	}
+	return header, rows
+}
+
+func writeTabRow(w io.Writer, cells []string) {
+	for i, c := range cells {
+		if i > 0 {
+			fmt.Fprint(w, "\t")
+		}
+		fmt.Fprint(w, c)
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+func TestPercentile(t *testing.T) {
+	for _, tc := range []struct {
+		values []float64
+		p      float64
+		want   float64
+	}{
+		{nil, 50, 0},
+		{[]float64{7}, 50, 7},
+		{[]float64{7}, 90, 7},
+		{[]float64{3, 1, 2}, 50, 2},
+		{[]float64{4, 1, 3, 2}, 50, 2.5},
+		{[]float64{4, 1, 3, 2}, 90, 3.7},
+		{[]float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 90, 9.1},
+		{[]float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 50, 5.5},
+		{[]float64{10, 20, 30, 40, 50}, 25, 20},
+		{[]float64{10, 20, 30, 40, 50}, 0, 10},
+		{[]float64{10, 20, 30, 40, 50}, 100, 50},
+		{[]float64{10, 20, 30, 40, 50}, -5, 10},
+		{[]float64{10, 20, 30, 40, 50}, 150, 50},
+		{[]float64{2, 2, 2, 90}, 50, 2},
+	} {
+		in := append([]float64(nil), tc.values...)
+		if got := percentile(tc.values, tc.p); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("percentile(%v, %v) = %v, want %v", tc.values, tc.p, got, tc.want)
+		}
+		for i := range in {
+			if in[i] != tc.values[i] {
+				t.Errorf("percentile(%v, %v) reordered its input", in, tc.p)
+				break
+			}
+		}
+	}
+}
+
+func TestNewTrendRecord(t *testing.T) {
+	now := time.Date(2026, 3, 20, 3, 0, 0, 0, time.UTC)
+	var prs []*github.PullRequest
+	for _, days := range []int{1, 2, 3, 10, 40} {
+		pr := testPR(fmt.Sprintf("author%d", days), now.AddDate(0, 0, -days))
+		pr.Title = github.Ptr("Secret project")
+		prs = append(prs, pr)
+	}
+	summary := &runSummary{Warned: prs[3:4], Closed: prs[4:]}
+	rec := newTrendRecord("acme", "api", true, prs, summary, now.Add(-83*time.Second-450*time.Millisecond), now)
+	want := trendRecord{Time: now, Repo: "acme/api", FullScan: true, OpenPRs: 5, Warned: 1, Closed: 1, InactiveDaysP50: 3, InactiveDaysP90: 28, DurationSeconds: 83.5}
+	if rec != want {
+		t.Errorf("newTrendRecord = %+v, want %+v", rec, want)
+	}
+	line, _ := json.Marshal(rec)
+	for _, pii := range []string{"author", "Secret", "pull"} {
+		if strings.Contains(string(line), pii) {
+			t.Errorf("trend record %s contains %q", line, pii)
+		}
+	}
+}
+
+func TestAppendTrendRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trends.jsonl")
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	record := func(i int) trendRecord {
+		return trendRecord{Time: start.AddDate(0, 0, i), Repo: "acme/api", FullScan: true, OpenPRs: i}
+	}
+	line, _ := json.Marshal(record(0))
+	// Rotate before appending to a file holding two records.
+	r := rotation{maxSize: int64(2 * (len(line) + 1)), maxBackups: 2, now: time.Now}
+	for i := 0; i < 7; i++ {
+		if err := appendTrend(path, record(i), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Records 0 and 1 were rotated out of the two backups kept.
+	for file, want := range map[string]string{
+		path:                 "6",
+		backupPath(path, 1):  "4 5",
+		backupPath(path, 2):  "2 3",
+		backupPath(path, 3):  "",
+		path + ".unexpected": "",
+	} {
+		var content string
+		if strings.HasSuffix(file, ".gz") {
+			if _, err := os.Stat(file); err == nil {
+				content = readBackup(t, file)
+			}
+		} else if data, err := os.ReadFile(file); err == nil {
+			content = string(data)
+		}
+		var got []string
+		for _, l := range strings.Split(strings.TrimSpace(content), "\n") {
+			var rec trendRecord
+			if json.Unmarshal([]byte(l), &rec) == nil {
+				got = append(got, fmt.Sprint(rec.OpenPRs))
+			}
+		}
+		if strings.Join(got, " ") != want {
+			t.Errorf("%s holds records %q, want %q", filepath.Base(file), got, want)
+		}
+	}
+
+	// Readers see the records kept, oldest first, across the backups.
+	records, err := readTrends(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, rec := range records {
+		got = append(got, fmt.Sprint(rec.OpenPRs))
+	}
+	if strings.Join(got, " ") != "2 3 4 5 6" {
+		t.Errorf("readTrends returned records %q, want 2 to 6", got)
+	}
+}
+
+func TestReadTrendsSkipsMalformedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trends.jsonl")
+	// An uncompressed backup left by older versions is read first.
+	if err := os.WriteFile(path+".1", []byte(`{"repo":"acme/api","open_prs":1}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("not json\n"+`{"repo":"acme/api","open_prs":2}`+"\n{\"repo\":"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	records, err := readTrends(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].OpenPRs != 1 || records[1].OpenPRs != 2 {
+		t.Errorf("readTrends returned %+v, want the two valid records", records)
+	}
+}
+
+func TestRunTrendsCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trends.jsonl")
+	start := time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+	for i, repo := range []string{"acme/web", "acme/api", "acme/web", "acme/api", "acme/web"} {
+		rec := trendRecord{Time: start.AddDate(0, 0, i), Repo: repo, FullScan: i != 4, OpenPRs: 10 + i, Warned: i, InactiveDaysP50: 4.3, InactiveDaysP90: 30, DurationSeconds: 12}
+		if err := appendTrend(path, rec, rotation{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var csvOut strings.Builder
+	if err := runTrendsCommand([]string{"--trends-file", path, "--last", "2", "--format", "csv"}, &csvOut); err != nil {
+		t.Fatal(err)
+	}
+	want := `repo,time,scan,open_prs,warned,closed,inactive_days_p50,inactive_days_p90,duration_s
+acme/api,2026-01-02T03:00:00Z,full,11,1,0,4.3,30.0,12.0
+acme/api,2026-01-04T03:00:00Z,full,13,3,0,4.3,30.0,12.0
+acme/web,2026-01-03T03:00:00Z,full,12,2,0,4.3,30.0,12.0
+acme/web,2026-01-05T03:00:00Z,incremental,14,4,0,4.3,30.0,12.0
+`
+	if csvOut.String() != want {
+		t.Errorf("CSV output:\n%s\nwant:\n%s", csvOut.String(), want)
+	}
+
+	var table strings.Builder
+	if err := runTrendsCommand([]string{"--trends-file", path, "--last", "1"}, &table); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "repo      time") || !strings.HasPrefix(lines[2], "acme/web  2026-01-05T03:00:00Z  incremental") {
+		t.Errorf("table output:\n%s", table.String())
+	}
+
+	for _, args := range [][]string{{}, {"--trends-file", path, "--format", "xml"}} {
+		if err := runTrendsCommand(args, &table); err == nil {
+			t.Errorf("runTrendsCommand(%q) succeeded, want an error", args)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// notificationUnstale is the kind of the notification thanking the author of
+// a warned PR that became active again, sent with --notify-on-unstale.
+const notificationUnstale = "unstale"
+
+// defaultUnstaleCooldown is how long after thanking the author of a PR the
+// bot does not thank them again for it, so a PR that keeps going stale and
+// coming back does not notify on every cycle.
+const defaultUnstaleCooldown = 7 * 24 * time.Hour
+
+// unstaleKey returns the idempotency key of the notification for a PR that
+// became active again with its activity at activeAt.
+func unstaleKey(number int, activeAt time.Time) string {
+	return fmt.Sprintf("%s#%d@%d", notificationUnstale, number, activeAt.Unix())
+}
+
+// unstaleNotifiedWithin reports whether the author of a PR was thanked for
+// it within cooldown before now.
+func (rs *repoState) unstaleNotifiedWithin(number int, cooldown time.Duration, now time.Time) bool {
+	prefix := fmt.Sprintf("%s#%d@", notificationUnstale, number)
+	for key, sent := range rs.Sent {
+		if strings.HasPrefix(key, prefix) && now.Sub(sent) < cooldown {
+			return true
+		}
+	}
+	return false
+}
+
+// thankPRAuthor tells the author of a warned PR that became active again that
+// the warning was lifted and when the PR can next go stale.
+func thankPRAuthor(out *prOutput, pr *github.PullRequest, data notificationData, mail *mailer) error {
+	subject := fmt.Sprintf("Thanks for updating your %s #%d; it will not go stale before %s", data.Kind, pr.GetNumber(), formatDate(data.NextReview, data.Location))
+	mail.greet(out, pr.GetUser(), &data)
+	body, err := mail.templates.render("unstale email", unstaleEmailTemplate, data)
+	if err != nil {
+		return err
+	}
+	return mail.notify(out, pr, data, subject, body)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+func TestUnstaleNotifiedWithin(t *testing.T) {
+	now := time.Date(2026, 3, 20, 9, 0, 0, 0, time.UTC)
+	rs := &repoState{}
+	rs.initMaps()
+	rs.markNotificationSent(unstaleKey(7, now.Add(-50*time.Hour)), now.Add(-48*time.Hour))
+	rs.markNotificationSent(unstaleKey(8, now.Add(-10*24*time.Hour)), now.Add(-9*24*time.Hour))
+	rs.markNotificationSent(unstaleKey(70, now), now)
+	if key := unstaleKey(7, now); key != fmt.Sprintf("unstale#7@%d", now.Unix()) {
+		t.Errorf("unstaleKey = %q", key)
+	}
+	for _, tc := range []struct {
+		number int
+		want   bool
+	}{
+		{7, true},
+		{8, false},
+		{9, false},
+	} {
+		if got := rs.unstaleNotifiedWithin(tc.number, defaultUnstaleCooldown, now); got != tc.want {
+			t.Errorf("PR #%d thanked within a week = %v, want %v", tc.number, got, tc.want)
+		}
+	}
+	if rs.unstaleNotifiedWithin(7, 24*time.Hour, now) {
+		t.Error("PR #7 thanked two days ago counts within a day")
+	}
+}
+
+func TestThankPRAuthor(t *testing.T) {
+	cfg := &config{NotifyVia: notifyComment, DisplayLocation: time.UTC}
+	pr := testPR("alice", time.Date(2026, 3, 19, 9, 0, 0, 0, time.UTC))
+	pr.Number = github.Ptr(7)
+	pr.Title = github.Ptr("Add retries")
+	pr.HTMLURL = github.Ptr("https://github.com/acme/api/pull/7")
+	data := newNotificationData(cfg, pr, "acme", "api", time.Date(2026, 3, 20, 9, 0, 0, 0, time.UTC))
+	data.NextReview = time.Date(2026, 4, 18, 9, 0, 0, 0, time.UTC)
+
+	m := newMailer(cfg, newTemplateRenderer(cfg), nil, nil)
+	var comments []string
+	m.comment = func(number int, body string) error {
+		comments = append(comments, fmt.Sprintf("#%d %s", number, body))
+		return nil
+	}
+	if err := thankPRAuthor(&prOutput{}, pr, data, m); err != nil {
+		t.Fatal(err)
+	}
+	want := `#7 @alice
+
+Hello alice,
+
+Thanks for updating your pull request #7 "Add retries". The stale warning has been removed and the inactivity timer has been reset, so it will not be closed.
+
+The next review of this pull request for inactivity will be no earlier than April 18, 2026 (UTC).
+
+PR Link: https://github.com/acme/api/pull/7
+
+Best regards,
+The Bot`
+	if len(comments) != 1 || comments[0] != want {
+		t.Errorf("comments %q, want %q", comments, want)
+	}
+
+	m.prefs = notificationPrefs{"alice": notifyNone}
+	if err := thankPRAuthor(&prOutput{}, pr, data, m); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"PR #7 (@alice): Thanks for updating your pull request #7; it will not go stale before April 18, 2026"}; fmt.Sprint(m.suppressed) != fmt.Sprint(want) {
+		t.Errorf("suppressed %q, want %q", m.suppressed, want)
+	}
+}
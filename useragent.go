@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// version is the bot's version, set at build time with
+// -ldflags "-X main.version=v1.2.3".
+var version = "dev"
+
+// projectURL identifies the bot in its User-Agent.
+const projectURL = "https://github.com/Kamalesh-Seervi/stale-pr-bot"
+
+// requestTagHeader carries the --request-tag on every request.
+const requestTagHeader = "X-Stale-PR-Bot-Tag"
+
+// userAgent returns the User-Agent the bot identifies itself with, followed
+// by the request tag if there is one.
+func userAgent(tag string) string {
+	ua := fmt.Sprintf("stale-pr-bot/%s (+%s)", version, projectURL)
+	if tag != "" {
+		ua += " " + tag
+	}
+	return ua
+}
+
+// userAgentTransport sets the bot's User-Agent, and the request tag header if
+// configured, on every request, so GitHub Enterprise admins can attribute
+// the bot's traffic in their audit logs.
+type userAgentTransport struct {
+	base http.RoundTripper
+	tag  string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", userAgent(t.tag))
+	if t.tag != "" {
+		req.Header.Set(requestTagHeader, t.tag)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// newHTTPClient returns the client shared by the requests the bot makes
+// outside of the GitHub API: Slack and Teams messages and webhooks. It
+// identifies the bot like the GitHub client does.
+func newHTTPClient(tag string) *http.Client {
+	return &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &userAgentTransport{base: http.DefaultTransport, tag: tag},
+	}
+}
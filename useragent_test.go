@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+func TestEveryRequestIdentifiesTheBot(t *testing.T) {
+	const tag = "team-platform"
+	var mu sync.Mutex
+	seen := map[string]http.Header{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.URL.Path] = r.Header.Clone()
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/repos/acme/api/pulls":
+			w.Write([]byte(`[]`))
+		case "/graphql":
+			w.Write([]byte(`{"data":{"viewer":{"login":"stale-bot"}}}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := getGithubClient("token", srv.URL+"/", "", tag, sshProxyOptions{}, newRunBudget(0, 0), 0, nil, newWriteGuard(false, false), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpClient := newHTTPClient(tag)
+	pr := testPR("alice", time.Now().AddDate(0, 0, -40))
+	pr.Number = github.Ptr(7)
+	data := notificationData{Number: 7, Title: "Add retries", URL: "https://github.com/acme/api/pull/7", Owner: "acme", Repo: "api", Location: time.UTC}
+
+	for _, path := range []struct {
+		name, path string
+		request    func() error
+	}{
+		{"GitHub REST", "/repos/acme/api/issues/7", func() error {
+			_, _, err := client.Issues.Get(context.Background(), "acme", "api", 7)
+			return err
+		}},
+		{"GitHub GraphQL", "/graphql", func() error {
+			var out struct{}
+			return githubGraphQL(client, srv.URL+"/", "query { viewer { login } }", nil, &out)
+		}},
+		{"capability probe", "/user", func() error {
+			var login string
+			_, err := probeCapabilities(tokenCapabilities(client, "acme", "api", capabilityNeeds{}, &login))
+			return err
+		}},
+		{"Slack", "/slack", func() error {
+			return newSlackNotifier(httpClient, srv.URL+"/slack", "").post(&prOutput{}, "Stale PR", pr, data)
+		}},
+		{"Teams", "/teams", func() error {
+			return newTeamsNotifier(httpClient, srv.URL+"/teams").post(&prOutput{}, "Stale PR", pr, data)
+		}},
+		{"webhook", "/webhook", func() error {
+			w := &webhookSender{client: httpClient, url: srv.URL + "/webhook", guard: newWriteGuard(false, false)}
+			w.send(&prOutput{}, webhookWarned, pr, "", nil)
+			if w.failed != 0 {
+				t.Errorf("the webhook failed")
+			}
+			return nil
+		}},
+	} {
+		if err := path.request(); err != nil {
+			t.Errorf("%s request failed: %v", path.name, err)
+			continue
+		}
+		mu.Lock()
+		header, ok := seen[path.path]
+		mu.Unlock()
+		if !ok {
+			t.Errorf("%s request did not reach %s", path.name, path.path)
+			continue
+		}
+		if got, want := header.Get("User-Agent"), userAgent(tag); got != want {
+			t.Errorf("%s request User-Agent = %q, want %q", path.name, got, want)
+		}
+		if got := header.Get(requestTagHeader); got != tag {
+			t.Errorf("%s request %s = %q, want %q", path.name, requestTagHeader, got, tag)
+		}
+	}
+}
+
+func TestUserAgentWithoutTag(t *testing.T) {
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+	}))
+	t.Cleanup(srv.Close)
+	resp, err := newHTTPClient("").Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got, want := header.Get("User-Agent"), "stale-pr-bot/"+version+" (+"+projectURL+")"; got != want {
+		t.Errorf("User-Agent = %q, want %q", got, want)
+	}
+	if _, ok := header[requestTagHeader]; ok {
+		t.Errorf("the %s header was sent without a --request-tag", requestTagHeader)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// Names notifications greet their recipient by, selected with
+// --greeting-name.
+const (
+	greetDisplayName = "display"
+	greetLogin       = "login"
+)
+
+// userProfiles looks up GitHub user profiles, which the users in PR payloads
+// lack, once per login per run; with --concurrency, PRs by one author
+// processed at the same time may each look theirs up. A nil userProfiles
+// looks nothing up.
+type userProfiles struct {
+	client *github.Client
+	mu     sync.Mutex
+	cache  map[string]*github.User
+}
+
+func newUserProfiles(client *github.Client) *userProfiles {
+	return &userProfiles{client: client, cache: map[string]*github.User{}}
+}
+
+// get returns the full profile of user, or user itself if it cannot be
+// fetched. Failed lookups are not retried within the run.
+func (p *userProfiles) get(out *prOutput, user *github.User) *github.User {
+	if p == nil || user.GetLogin() == "" {
+		return user
+	}
+	login := strings.ToLower(user.GetLogin())
+	p.mu.Lock()
+	profile, ok := p.cache[login]
+	p.mu.Unlock()
+	if ok {
+		return profile
+	}
+	// The lock is not held while fetching: PRs processed concurrently
+	// release the worker pool's lock for the request, and must not wait
+	// for this one while holding it.
+	profile, _, err := p.client.Users.Get(context.Background(), user.GetLogin())
+	if err != nil {
+		out.Warn("could not fetch user profile", "user", user.GetLogin(), "err", err)
+		profile = user
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if cached, ok := p.cache[login]; ok {
+		return cached
+	}
+	p.cache[login] = profile
+	return profile
+}
+
+// commitEmail returns the author email of the most recent of a PR's commits,
+// oldest first, that login authored with a deliverable address, or "".
+// Commits GitHub attributes to another account are skipped, and so are the
+// noreply addresses of authors who keep their email private.
+func commitEmail(commits []*github.RepositoryCommit, login string) string {
+	for i := len(commits) - 1; i >= 0; i-- {
+		c := commits[i]
+		if author := c.GetAuthor().GetLogin(); author != "" && !strings.EqualFold(author, login) {
+			continue
+		}
+		if email := strings.TrimSpace(c.GetCommit().GetAuthor().GetEmail()); email != "" && !isNoreplyEmail(email) {
+			return email
+		}
+	}
+	return ""
+}
+
+// isNoreplyEmail reports whether email cannot receive mail: GitHub's
+// users.noreply addresses, on github.com or a GitHub Enterprise host, and
+// noreply@github.com of web commits.
+func isNoreplyEmail(email string) bool {
+	email = strings.ToLower(email)
+	_, domain, ok := strings.Cut(email, "@")
+	return !ok || strings.HasPrefix(domain, "users.noreply.") || email == "noreply@github.com"
+}
+
+// listPRCommits returns the commits of a PR, oldest first.
+func listPRCommits(client *github.Client, owner, repo string, number int) ([]*github.RepositoryCommit, error) {
+	ctx := context.Background()
+	var commits []*github.RepositoryCommit
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := client.PullRequests.ListCommits(ctx, owner, repo, number, opt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list commits of PR #%d: %v", number, err)
+		}
+		commits = append(commits, page...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return commits, nil
+}
+
+// displayName returns a user's display name from their profile, or "".
+func (r *emailResolver) displayName(out *prOutput, user *github.User) string {
+	return strings.TrimSpace(r.profiles.get(out, user).GetName())
+}
+
+// headerName cleans a display name for an email header: control and format
+// characters, emoji and other symbols are dropped and whitespace is
+// collapsed. Bodies get the name as is.
+func headerName(name string) string {
+	clean := strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsSpace(r):
+			return ' '
+		case unicode.IsControl(r), unicode.In(r, unicode.Cf, unicode.Co, unicode.Cs, unicode.So, unicode.Sk, unicode.Variation_Selector), r == unicode.ReplacementChar:
+			return -1
+		}
+		return r
+	}, name)
+	return strings.Join(strings.Fields(clean), " ")
+}
+
+// formatRecipient returns the To header value for an address, with the
+// recipient's name if there is one.
+func formatRecipient(name, address string) string {
+	if name = headerName(name); name == "" {
+		return address
+	}
+	return (&mail.Address{Name: name, Address: address}).String()
+}
+
+// greet sets the name a notification greets its recipient by: their display
+// name unless --greeting-name is login, falling back to their login.
+func (m *mailer) greet(out *prOutput, user *github.User, data *notificationData) {
+	data.DisplayName = m.emails.displayName(out, user)
+	data.Greeting = data.Login
+	if m.greeting != greetLogin && data.DisplayName != "" {
+		data.Greeting = data.DisplayName
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// testCommit returns a commit GitHub attributes to login, or to no account if
+// login is empty, authored with email.
+func testCommit(login, email string) *github.RepositoryCommit {
+	c := &github.RepositoryCommit{Commit: &github.Commit{Author: &github.CommitAuthor{Email: github.Ptr(email)}}}
+	if login != "" {
+		c.Author = &github.User{Login: github.Ptr(login)}
+	}
+	return c
+}
+
+func TestCommitEmail(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		commits []*github.RepositoryCommit
+		want    string
+	}{
+		{name: "no commits"},
+		{name: "author", commits: []*github.RepositoryCommit{testCommit("alice", "alice@example.com")}, want: "alice@example.com"},
+		{name: "latest wins", commits: []*github.RepositoryCommit{testCommit("alice", "old@example.com"), testCommit("alice", "new@example.com")}, want: "new@example.com"},
+		{name: "login ignores case", commits: []*github.RepositoryCommit{testCommit("Alice", "alice@example.com")}, want: "alice@example.com"},
+		{name: "unattributed commit", commits: []*github.RepositoryCommit{testCommit("", "alice@laptop.example.com")}, want: "alice@laptop.example.com"},
+		{name: "other author", commits: []*github.RepositoryCommit{testCommit("alice", "alice@example.com"), testCommit("bob", "bob@example.com")}, want: "alice@example.com"},
+		{name: "noreply skipped", commits: []*github.RepositoryCommit{testCommit("alice", "alice@example.com"), testCommit("alice", "123+alice@users.noreply.github.com")}, want: "alice@example.com"},
+		{name: "only noreply", commits: []*github.RepositoryCommit{testCommit("alice", "alice@users.noreply.github.example.com"), testCommit("alice", "noreply@github.com")}},
+		{name: "empty email", commits: []*github.RepositoryCommit{testCommit("alice", " ")}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := commitEmail(tc.commits, "alice"); got != tc.want {
+				t.Errorf("commitEmail = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsNoreplyEmail(t *testing.T) {
+	for email, want := range map[string]bool{
+		"alice@example.com":                    false,
+		"noreply@example.com":                  false,
+		"123+alice@users.noreply.github.com":   true,
+		"alice@Users.Noreply.github.acme.corp": true,
+		"noreply@github.com":                   true,
+		"not an address":                       true,
+	} {
+		if got := isNoreplyEmail(email); got != want {
+			t.Errorf("isNoreplyEmail(%q) = %v, want %v", email, got, want)
+		}
+	}
+}
+
+func TestEmailResolverLookup(t *testing.T) {
+	commits := func(email string, err error) func(int) ([]*github.RepositoryCommit, error) {
+		return func(int) ([]*github.RepositoryCommit, error) {
+			return []*github.RepositoryCommit{testCommit("alice", email)}, err
+		}
+	}
+	for _, tc := range []struct {
+		name string
+		// public is the email of the author's profile.
+		public     string
+		commits    func(int) ([]*github.RepositoryCommit, error)
+		noGuess    bool
+		want, from string
+	}{
+		{name: "profile", public: "alice@home.example.com", commits: commits("alice@work.example.com", nil), want: "alice@home.example.com", from: emailSourceProfile},
+		{name: "commit", commits: commits("alice@work.example.com", nil), want: "alice@work.example.com", from: emailSourceCommit},
+		{name: "noreply commit", commits: commits("alice@users.noreply.github.com", nil), want: "alice@example.org", from: emailSourceFallback},
+		{name: "commits not listed", commits: commits("", errors.New("boom")), want: "alice@example.org", from: emailSourceFallback},
+		{name: "no client", want: "alice@example.org", from: emailSourceFallback},
+		{name: "no guess", commits: commits("alice@users.noreply.github.com", nil), noGuess: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newEmailResolver(&config{FallbackEmailDomain: "example.org", NoEmailGuess: tc.noGuess})
+			r.commits = tc.commits
+			pr := testPR("Alice", time.Now())
+			if tc.public != "" {
+				pr.User.Email = github.Ptr(tc.public)
+			}
+			email, source := r.lookup(&prOutput{}, pr)
+			if email != tc.want || source != tc.from {
+				t.Errorf("lookup = %q from %q, want %q from %q", email, source, tc.want, tc.from)
+			}
+		})
+	}
+}
+
+func TestHeaderName(t *testing.T) {
+	for name, want := range map[string]string{
+		"Alice Liddell":            "Alice Liddell",
+		"  Alice\t\nLiddell  ":     "Alice Liddell",
+		"Zoë Ångström":             "Zoë Ångström",
+		"李小龍":                      "李小龍",
+		"Alice 🚀 Liddell":          "Alice Liddell",
+		"Alice\u200b\u202eLiddell": "AliceLiddell",
+		"Alice\x00\x1b[31m":        "Alice[31m",
+		"🎉✨":                       "",
+	} {
+		if got := headerName(name); got != want {
+			t.Errorf("headerName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestFormatRecipient(t *testing.T) {
+	for _, tc := range []struct{ name, want string }{
+		{"", "alice@example.com"},
+		{"🚀", "alice@example.com"},
+		{"Alice Liddell", `"Alice Liddell" <alice@example.com>`},
+		{"Zoë", "=?utf-8?q?Zo=C3=AB?= <alice@example.com>"},
+	} {
+		if got := formatRecipient(tc.name, "alice@example.com"); got != tc.want {
+			t.Errorf("formatRecipient(%q) = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestUserProfiles(t *testing.T) {
+	var mu sync.Mutex
+	fetched := map[string]int{}
+	client, _ := newTestGitHubClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		login := strings.TrimPrefix(r.URL.Path, "/users/")
+		mu.Lock()
+		fetched[login]++
+		mu.Unlock()
+		if login == "ghost" {
+			http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"login":%q,"name":"  Alice Liddell "}`, login)
+	}))
+	p := newUserProfiles(client)
+	out := &prOutput{}
+
+	for _, login := range []string{"alice", "Alice", "ALICE"} {
+		if got := p.get(out, &github.User{Login: github.Ptr(login)}).GetName(); got != "  Alice Liddell " {
+			t.Errorf("the profile of %s has name %q, want the fetched one", login, got)
+		}
+	}
+	ghost := &github.User{Login: github.Ptr("ghost"), Name: github.Ptr("from the payload")}
+	for range 2 {
+		if got := p.get(out, ghost); got != ghost {
+			t.Errorf("the profile of a user that cannot be fetched is %v, want the user itself", got)
+		}
+	}
+	if fetched["alice"] != 1 || fetched["ghost"] != 1 {
+		t.Errorf("fetched profiles %v, want each login once", fetched)
+	}
+	if len(out.records) != 1 {
+		t.Errorf("logged %d records, want a warning for the failed lookup", len(out.records))
+	}
+
+	anonymous := &github.User{}
+	if got := p.get(out, anonymous); got != anonymous {
+		t.Errorf("the profile of a user without a login is %v, want the user itself", got)
+	}
+	var none *userProfiles
+	if got := none.get(out, ghost); got != ghost {
+		t.Errorf("a nil userProfiles returned %v, want the user itself", got)
+	}
+}
+
+func TestGreet(t *testing.T) {
+	client, _ := newTestGitHubClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/users/alice" {
+			fmt.Fprint(w, `{"login":"alice","name":" Alice Liddell "}`)
+			return
+		}
+		fmt.Fprint(w, `{"login":"bob","name":""}`)
+	}))
+	for _, tc := range []struct {
+		login, greeting string
+		wantName, want  string
+	}{
+		{"alice", greetDisplayName, "Alice Liddell", "Alice Liddell"},
+		{"alice", greetLogin, "Alice Liddell", "alice"},
+		{"bob", greetDisplayName, "", "bob"},
+	} {
+		r := newEmailResolver(&config{})
+		r.profiles = newUserProfiles(client)
+		m := &mailer{emails: r, greeting: tc.greeting}
+		data := notificationData{Login: tc.login}
+		m.greet(&prOutput{}, &github.User{Login: github.Ptr(tc.login)}, &data)
+		if data.DisplayName != tc.wantName || data.Greeting != tc.want {
+			t.Errorf("greeting %s by %s: display name %q, greeting %q; want %q, %q", tc.login, tc.greeting, data.DisplayName, data.Greeting, tc.wantName, tc.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// Actions reported to --webhook-url.
+const (
+	webhookWarned       = "warned"
+	webhookClosed       = "closed"
+	webhookLabelAdded   = "label-added"
+	webhookLabelRemoved = "label-removed"
+	webhookExempted     = "exempted"
+)
+
+// webhookSignatureHeader carries the HMAC-SHA256 of the payload, as
+// "sha256=<hex>", when --webhook-secret is set. It mirrors GitHub's own
+// X-Hub-Signature-256.
+const webhookSignatureHeader = "X-Signature-256"
+
+// webhookPayload is the JSON body POSTed for each action.
+type webhookPayload struct {
+	Action string    `json:"action"`
+	Time   time.Time `json:"time"`
+	Repo   string    `json:"repo"`
+	PR     int       `json:"pr"`
+	Title  string    `json:"title"`
+	URL    string    `json:"url"`
+	Author string    `json:"author"`
+	// Actor is the bot's login, which took the action.
+	Actor string `json:"actor"`
+	// Label is the label added or removed.
+	Label  string        `json:"label,omitempty"`
+	Inputs webhookInputs `json:"inputs"`
+}
+
+// webhookInputs are what the decision about the PR was based on.
+type webhookInputs struct {
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	LastActivity time.Time `json:"last_activity"`
+	// DaysInactive is the number of whole days since LastActivity.
+	DaysInactive          int `json:"days_inactive"`
+	DaysInactiveThreshold int `json:"days_inactive_threshold"`
+	WarningPeriod         int `json:"warning_period"`
+	// Decision and Rule are the decided action and the rule that decided
+	// it, when known.
+	Decision    string `json:"decision,omitempty"`
+	Rule        string `json:"rule,omitempty"`
+	CloseReason string `json:"close_reason,omitempty"`
+}
+
+// webhookSender POSTs a payload to --webhook-url after each bot action.
+// Failures are counted but never stop the run. A nil sender sends nothing.
+type webhookSender struct {
+	client *http.Client
+	url    string
+	secret string
+	repo   string
+	actor  string
+	rules  evaluationRules
+	guard  *writeGuard
+
+	failed int
+}
+
+// send reports action on pr, with the decision that led to it if known.
+func (w *webhookSender) send(out *prOutput, action string, pr *github.PullRequest, label string, decision *prDecision) {
+	if w == nil {
+		return
+	}
+	now := time.Now().UTC()
+	inputs := webhookInputs{
+		CreatedAt:             pr.GetCreatedAt().Time,
+		UpdatedAt:             pr.GetUpdatedAt().Time,
+		LastActivity:          pr.GetUpdatedAt().Time,
+		DaysInactiveThreshold: w.rules.DaysInactive,
+		WarningPeriod:         w.rules.WarningPeriod,
+	}
+	if decision != nil {
+		if !decision.LastActivity.IsZero() {
+			inputs.LastActivity = decision.LastActivity
+		}
+		inputs.Decision, inputs.Rule, inputs.CloseReason = decision.Action, decision.Rule, decision.CloseReason
+	}
+	inputs.DaysInactive = int(now.Sub(inputs.LastActivity).Hours() / 24)
+	payload, err := json.Marshal(webhookPayload{
+		Action: action,
+		Time:   now,
+		Repo:   w.repo,
+		PR:     pr.GetNumber(),
+		Title:  pr.GetTitle(),
+		URL:    pr.GetHTMLURL(),
+		Author: pr.GetUser().GetLogin(),
+		Actor:  w.actor,
+		Label:  label,
+		Inputs: inputs,
+	})
+	if err != nil {
+		out.Error("encoding webhook payload failed", "action", "webhook", "err", err)
+		w.failed++
+		return
+	}
+	if w.guard.block(fmt.Sprintf("webhook POST for %s of PR #%d", action, pr.GetNumber())) {
+		return
+	}
+	if err := w.post(payload); err != nil {
+		out.Error("sending webhook failed", "action", "webhook", "event", action, "err", err)
+		w.failed++
+	}
+}
+
+func (w *webhookSender) post(payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.secret != "" {
+		req.Header.Set(webhookSignatureHeader, webhookSignature(w.secret, payload))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// webhookSignature returns the X-Signature-256 value for payload.
+func webhookSignature(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v68/github"
+)
+
+// webhookRequest is a request received by the test webhook endpoint.
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// startTestWebhook starts an endpoint that records each request and replies
+// with status.
+func startTestWebhook(t *testing.T, status int) (*httptest.Server, chan webhookRequest) {
+	t.Helper()
+	requests := make(chan webhookRequest, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost {
+			t.Errorf("webhook request method %s, want POST", r.Method)
+		}
+		requests <- webhookRequest{r.Header, body}
+		w.WriteHeader(status)
+		io.WriteString(w, http.StatusText(status))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, requests
+}
+
+func TestWebhookSend(t *testing.T) {
+	updated := time.Now().UTC().AddDate(0, 0, -40)
+	pr := testPR("alice", updated, "stale-warning")
+	pr.Title = github.Ptr("Add a feature")
+	pr.HTMLURL = github.Ptr("https://github.com/acme/api/pull/42")
+	lastActivity := time.Now().UTC().AddDate(0, 0, -45)
+	decision := &prDecision{Action: actionClose, Rule: ruleLifecycle, LastActivity: lastActivity, CloseReason: closeAuthorUnresponsive}
+
+	for _, tc := range []struct {
+		name     string
+		status   int
+		secret   string
+		readOnly bool
+		decision *prDecision
+		// wantSent is whether the endpoint must receive the payload, and
+		// wantFailed whether the sender must count it as failed.
+		wantSent, wantFailed bool
+	}{
+		{name: "signed", status: http.StatusOK, secret: "s3cret", decision: decision, wantSent: true},
+		{name: "unsigned", status: http.StatusNoContent, wantSent: true},
+		{name: "endpoint fails", status: http.StatusInternalServerError, decision: decision, wantSent: true, wantFailed: true},
+		{name: "read-only", status: http.StatusOK, readOnly: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, requests := startTestWebhook(t, tc.status)
+			w := &webhookSender{
+				client: newHTTPClient("nightly"),
+				url:    srv.URL,
+				secret: tc.secret,
+				repo:   "acme/api",
+				actor:  "stale-bot",
+				rules:  testRules(),
+				guard:  newWriteGuard(tc.readOnly, false),
+			}
+			out := &prOutput{}
+			w.send(out, webhookClosed, pr, "", tc.decision)
+
+			if gotFailed := w.failed > 0; gotFailed != tc.wantFailed {
+				t.Errorf("failed = %d, want failure %v; errors: %q", w.failed, tc.wantFailed, out.errors)
+			}
+			if tc.wantFailed && (len(out.errors) != 1 || !strings.Contains(out.errors[0], "500 Internal Server Error")) {
+				t.Errorf("logged errors %q, want the endpoint's status", out.errors)
+			}
+			if tc.readOnly {
+				if blocked := w.guard.blockedWrites(); len(blocked) != 1 {
+					t.Errorf("the guard blocked %q, want the webhook POST", blocked)
+				}
+			}
+			var req webhookRequest
+			select {
+			case req = <-requests:
+			default:
+				if tc.wantSent {
+					t.Fatal("the endpoint received no request")
+				}
+				return
+			}
+			if !tc.wantSent {
+				t.Fatalf("the endpoint received %s", req.body)
+			}
+
+			if got := req.header.Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type %q, want application/json", got)
+			}
+			if got := req.header.Get("User-Agent"); got != userAgent("nightly") {
+				t.Errorf("User-Agent %q, want %q", got, userAgent("nightly"))
+			}
+			if got := req.header.Get(requestTagHeader); got != "nightly" {
+				t.Errorf("%s %q, want nightly", requestTagHeader, got)
+			}
+			signature := req.header.Get(webhookSignatureHeader)
+			switch {
+			case tc.secret == "" && signature != "":
+				t.Errorf("%s %q without a secret", webhookSignatureHeader, signature)
+			case tc.secret != "" && signature != webhookSignature(tc.secret, req.body):
+				t.Errorf("%s %q does not sign the payload", webhookSignatureHeader, signature)
+			}
+
+			var got webhookPayload
+			if err := json.Unmarshal(req.body, &got); err != nil {
+				t.Fatalf("decoding %s: %v", req.body, err)
+			}
+			if got.Action != webhookClosed || got.Repo != "acme/api" || got.PR != 42 || got.Title != "Add a feature" ||
+				got.URL != pr.GetHTMLURL() || got.Author != "alice" || got.Actor != "stale-bot" {
+				t.Errorf("payload %s does not describe the closing of PR #42", req.body)
+			}
+			in := got.Inputs
+			if in.DaysInactiveThreshold != 30 || in.WarningPeriod != 7 || !in.UpdatedAt.Equal(updated) {
+				t.Errorf("inputs %+v do not carry the rules and the PR", in)
+			}
+			wantActivity, wantDays := updated, 40
+			if tc.decision != nil {
+				wantActivity, wantDays = lastActivity, 45
+				if in.Decision != actionClose || in.Rule != ruleLifecycle || in.CloseReason != closeAuthorUnresponsive {
+					t.Errorf("inputs %+v do not carry the decision", in)
+				}
+			}
+			if !in.LastActivity.Equal(wantActivity) || in.DaysInactive != wantDays {
+				t.Errorf("last activity %v, %d days ago; want %v, %d days ago", in.LastActivity, in.DaysInactive, wantActivity, wantDays)
+			}
+		})
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	// The HMAC-SHA256 test vector of RFC 4231, test case 2.
+	got := webhookSignature("Jefe", []byte("what do ya want for nothing?"))
+	want := "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+	if got != want {
+		t.Errorf("webhookSignature = %s, want %s", got, want)
+	}
+}
+
+func TestNilWebhookSender(t *testing.T) {
+	var w *webhookSender
+	w.send(&prOutput{}, webhookWarned, testPR("alice", time.Now()), "", nil)
+}
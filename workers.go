@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// prPool processes the PRs of a repository with up to --concurrency
+// workers. The workers take turns holding the pool's lock, so the run
+// counters, the state and the summary are only touched by the worker holding
+// it, as they were when PRs were processed one at a time. A worker releases
+// the lock while it waits on the network, for GitHub API calls and SMTP,
+// which is where a run spends its time, so those waits overlap.
+//
+// The lock is not held across those waits, so a check made before one no
+// longer holds after it: a cache filled from the network on first use could
+// be filled by two workers at once. Look such data up before the workers
+// start instead.
+//
+// Code run by blocking must not touch shared state, and code holding another
+// lock must not call it, as taking the pool's lock back could deadlock.
+type prPool struct {
+	workers int
+	mu      sync.Mutex
+	// running is set while workers run; outside of them blocking has no
+	// lock to release.
+	running atomic.Bool
+}
+
+func newPRPool(workers int) *prPool {
+	return &prPool{workers: workers}
+}
+
+// run calls process for the indexes from 0 to n-1, starting them in order,
+// until stop, checked before each, returns true. It returns the number of
+// indexes started. With one worker, or a nil pool, it processes them one at
+// a time without any locking. A panic in a worker is re-raised once the
+// others have stopped.
+func (p *prPool) run(n int, stop func() bool, process func(i int)) int {
+	if p == nil || p.workers <= 1 || n <= 1 {
+		for i := 0; i < n; i++ {
+			if stop() {
+				return i
+			}
+			process(i)
+		}
+		return n
+	}
+	p.running.Store(true)
+	defer p.running.Store(false)
+	next, stopped := 0, false
+	var panicked any
+	var wg sync.WaitGroup
+	for range min(p.workers, n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			defer func() {
+				if r := recover(); r != nil {
+					panicked, stopped = r, true
+				}
+			}()
+			for next < n && !stopped {
+				if stop() {
+					stopped = true
+					break
+				}
+				i := next
+				next++
+				process(i)
+			}
+		}()
+	}
+	wg.Wait()
+	if panicked != nil {
+		panic(panicked)
+	}
+	return next
+}
+
+// blocking calls f, which waits on the network, with the pool's lock
+// released if the caller is a worker.
+func (p *prPool) blocking(f func()) {
+	if p == nil || !p.running.Load() {
+		f()
+		return
+	}
+	p.mu.Unlock()
+	defer p.mu.Lock()
+	f()
+}
+
+// poolTransport releases the pool's lock while a GitHub API request waits
+// for its response, including any rate-limit and retry waits.
+type poolTransport struct {
+	base http.RoundTripper
+	pool *prPool
+}
+
+func (t *poolTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	t.pool.blocking(func() { resp, err = t.base.RoundTrip(req) })
+	return resp, err
+}
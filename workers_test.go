@@ -0,0 +1,90 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPRPoolRun(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		pool      *prPool
+		n, stopAt int
+		want      int
+	}{
+		{name: "nil pool", n: 5, stopAt: -1, want: 5},
+		{name: "one worker", pool: newPRPool(1), n: 5, stopAt: 3, want: 3},
+		{name: "workers", pool: newPRPool(4), n: 20, stopAt: -1, want: 20},
+		{name: "workers stopped", pool: newPRPool(4), n: 20, stopAt: 8, want: 8},
+		{name: "more workers than PRs", pool: newPRPool(8), n: 3, stopAt: -1, want: 3},
+		{name: "no PRs", pool: newPRPool(4), stopAt: -1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// The counters are only touched by the worker holding the
+			// pool's lock, as the run's own state is.
+			checked := 0
+			processed := make([]int, tc.n)
+			started := tc.pool.run(tc.n, func() bool {
+				checked++
+				return checked-1 == tc.stopAt
+			}, func(i int) { processed[i]++ })
+			if started != tc.want {
+				t.Errorf("run started %d, want %d", started, tc.want)
+			}
+			for i, count := range processed {
+				if want := map[bool]int{true: 1, false: 0}[i < tc.want]; count != want {
+					t.Errorf("index %d processed %d times, want %d", i, count, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPRPoolBlockingOverlaps(t *testing.T) {
+	const workers = 3
+	p := newPRPool(workers)
+	var arrived sync.WaitGroup
+	arrived.Add(workers)
+	done := make(chan int)
+	go func() {
+		// Each worker waits in blocking until all of them got there,
+		// which they only can if blocking releases the pool's lock.
+		done <- p.run(workers, func() bool { return false }, func(int) {
+			p.blocking(func() {
+				arrived.Done()
+				arrived.Wait()
+			})
+		})
+	}()
+	select {
+	case n := <-done:
+		if n != workers {
+			t.Errorf("run started %d, want %d", n, workers)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the workers' blocking calls did not overlap")
+	}
+
+	// Outside of run, blocking just calls f.
+	called := false
+	p.blocking(func() { called = true })
+	if !called {
+		t.Error("blocking outside of run did not call f")
+	}
+}
+
+func TestPRPoolPanics(t *testing.T) {
+	p := newPRPool(4)
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("run recovered %v, want the worker's panic", r)
+		}
+	}()
+	p.run(10, func() bool { return false }, func(i int) {
+		if i == 2 {
+			panic("boom")
+		}
+	})
+	t.Error("run returned though a worker panicked")
+}